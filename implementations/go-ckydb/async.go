@@ -0,0 +1,58 @@
+package ckydb
+
+import (
+	"context"
+
+	"github.com/sopherapps/ckydb/implementations/go-ckydb/internal"
+)
+
+// ConnectAsync creates a new Ckydb instance like Connect, but returns it right away, loading it from disk and
+// starting its background tasks in the background, for services with strict startup deadlines and big databases.
+// The returned channel receives the error of loading and opening it, nil once it is ready, and is then closed.
+// Until then its State is StateLoading, and Open and Close wait for the load to finish. Meanwhile, Get, Keys and
+// Prefix are served from what has been loaded so far: once the keys and their expiries are loaded, values are read
+// from the log file and the ".cky" files without waiting for the rest of the load e.g. the cache warm-up. Every
+// other operation fails with an ErrLoading error, which IsRetryable reports as retryable, as does Get until the
+// keys are loaded, or throughout for Engines, while Keys returns none. If the load fails, the database is left
+// closed and Open returns the error
+func ConnectAsync(dbPath string, maxFileSizeKB float64, vacuumIntervalSec float64, opts ...Option) (*Ckydb, <-chan error) {
+	db := newUnloadedCkydb(dbPath, maxFileSizeKB, vacuumIntervalSec, opts...)
+	db.state.Store(int32(StateLoading))
+
+	ready := make(chan error, 1)
+	db.lifecycleLock.Lock()
+	go func() {
+		defer close(ready)
+		ready <- db.loadAndOpen()
+	}()
+
+	return db, ready
+}
+
+// getWhileLoading is get while ConnectAsync loads the database, served from what has been loaded so far
+func (c *Ckydb) getWhileLoading(ctx context.Context, key string) (string, error) {
+	var value string
+	err := c.measureContext(ctx, opGet, key, func(st *internal.OpStats) error {
+		var err error
+		value, err = c.store.GetWhileLoadingWithStats(key, st)
+		return err
+	})
+
+	return value, err
+}
+
+// loadAndOpen loads the database from disk and opens it, releasing the lifecycleLock taken by ConnectAsync
+func (c *Ckydb) loadAndOpen() error {
+	defer c.lifecycleLock.Unlock()
+
+	err := c.load()
+	if err == nil {
+		err = c.open()
+	}
+	if err != nil {
+		c.loadErr = err
+		c.state.Store(int32(StateClosed))
+	}
+
+	return err
+}
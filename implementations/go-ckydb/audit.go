@@ -0,0 +1,87 @@
+package ckydb
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"maps"
+	"time"
+)
+
+// AuditRecord is a line of the audit log, written as a JSON object
+type AuditRecord struct {
+	Time time.Time `json:"time"`
+	Op   OpType    `json:"op"`
+	// Key is the key that was set or deleted, unless it is hashed. It is empty for OpClear
+	Key string `json:"key,omitempty"`
+	// KeyHash is the FNV-1a hash of the key if keys are hashed, as in traces
+	KeyHash string `json:"key_hash,omitempty"`
+	// Replicated is whether the mutation was applied from another database by Apply
+	Replicated bool `json:"replicated,omitempty"`
+	// Metadata is what the caller passed in the context of the mutation with AuditContext
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+type auditMetadataKey struct{}
+
+// WithAuditLog writes an AuditRecord for every committed Set, Delete and Clear, including those of Import,
+// SetWithTTL, Expire, Undelete, Copy, Swap, SetGet, SetIfAbsent, DeleteGet, SetReader and Apply, to w as a line of
+// JSON, so that compliance environments can trace who changed what. Failed writes to w are logged, as the mutation
+// has already been committed
+func WithAuditLog(w io.Writer) Option {
+	return func(o *options) {
+		o.auditLog = w
+	}
+}
+
+// WithHashedAuditKeys records the hashes of keys in the audit log instead of the keys themselves,
+// for keys that are sensitive in their own right e.g. email addresses
+func WithHashedAuditKeys() Option {
+	return func(o *options) {
+		o.hashAuditKeys = true
+	}
+}
+
+// AuditContext returns a copy of ctx carrying the given metadata e.g. the user or request behind a mutation,
+// which SetContext, DeleteContext and ClearContext record in the audit log. It is added to any metadata
+// that ctx already carries, replacing the values of the same names
+func AuditContext(ctx context.Context, metadata map[string]string) context.Context {
+	merged := maps.Clone(auditMetadataOf(ctx))
+	if merged == nil {
+		merged = map[string]string{}
+	}
+	maps.Copy(merged, metadata)
+
+	return context.WithValue(ctx, auditMetadataKey{}, merged)
+}
+
+// audit writes the record of the committed op to the audit log, if any. It is called with
+// the write lock held, so records are written one at a time in the order of the ops
+func (c *Ckydb) audit(ctx context.Context, op Op, isReplicated bool) {
+	if c.auditLog == nil {
+		return
+	}
+
+	record := AuditRecord{Time: op.Time, Op: op.Type, Replicated: isReplicated, Metadata: auditMetadataOf(ctx)}
+	switch {
+	case op.Key == "":
+	case c.hashAuditKeys:
+		record.KeyHash = hashKey(op.Key)
+	default:
+		record.Key = op.Key
+	}
+
+	line, err := json.Marshal(record)
+	if err == nil {
+		_, err = c.auditLog.Write(append(line, '\n'))
+	}
+	if err != nil {
+		c.logger.Printf("error: failed to write audit record of %s: %s", op.Type, err)
+	}
+}
+
+// auditMetadataOf returns the audit metadata carried by ctx, if any
+func auditMetadataOf(ctx context.Context) map[string]string {
+	metadata, _ := ctx.Value(auditMetadataKey{}).(map[string]string)
+	return metadata
+}
@@ -0,0 +1,148 @@
+// Package cachelayer lets ckydb act as a persistent cache in front of a slower origin
+// e.g. a remote API or another database
+package cachelayer
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sopherapps/ckydb/implementations/go-ckydb"
+	"golang.org/x/sync/singleflight"
+)
+
+// expirySeparator separates the expiry timestamp from the actual value in the cached entries
+const expirySeparator = "|"
+
+// Loader fetches the value corresponding to the given key from the origin
+type Loader func(key string) (string, error)
+
+// Writer persists the given key-value pair to the origin
+type Writer func(key string, value string) error
+
+type ReadThrough struct {
+	db     ckydb.Controller
+	loader Loader
+	ttl    time.Duration
+	group  singleflight.Group
+}
+
+// NewReadThrough creates a new ReadThrough cache which gets values from db, falling back to the loader
+// whenever the key is missing or has expired. Loaded values are kept in db for ttl. A ttl of zero
+// or less means the loaded values never expire.
+func NewReadThrough(db ckydb.Controller, loader Loader, ttl time.Duration) *ReadThrough {
+	return &ReadThrough{db: db, loader: loader, ttl: ttl}
+}
+
+// Get retrieves the value corresponding to the given key from db if it is fresh, or from the loader
+// otherwise. Concurrent misses of the same key result in only one call to the loader
+func (r *ReadThrough) Get(key string) (string, error) {
+	value, err := r.getFresh(key)
+	if err == nil {
+		return value, nil
+	}
+
+	if !errors.Is(err, ckydb.ErrNotFound) {
+		return "", err
+	}
+
+	result, err, _ := r.group.Do(key, func() (interface{}, error) {
+		// another caller might have just loaded it
+		value, err := r.getFresh(key)
+		if err == nil {
+			return value, nil
+		}
+
+		value, err = r.loader(key)
+		if err != nil {
+			return "", err
+		}
+
+		err = r.db.Set(key, encodeEntry(value, r.ttl))
+		return value, err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return result.(string), nil
+}
+
+// Invalidate removes the given key from db so that it is reloaded from the origin on next Get
+func (r *ReadThrough) Invalidate(key string) error {
+	err := r.db.Delete(key)
+	if err != nil && !errors.Is(err, ckydb.ErrNotFound) {
+		return err
+	}
+
+	return nil
+}
+
+// getFresh gets the value of the given key from db, returning ckydb.ErrNotFound if it has expired
+func (r *ReadThrough) getFresh(key string) (string, error) {
+	entry, err := r.db.Get(key)
+	if err != nil {
+		return "", err
+	}
+
+	value, expiresAt, err := decodeEntry(entry)
+	if err != nil || (expiresAt != 0 && time.Now().UnixNano() >= expiresAt) {
+		return "", ckydb.ErrNotFound
+	}
+
+	return value, nil
+}
+
+type WriteThrough struct {
+	db     ckydb.Controller
+	writer Writer
+}
+
+// NewWriteThrough creates a new WriteThrough cache which writes every key-value pair
+// to the origin via writer, before it is saved in db
+func NewWriteThrough(db ckydb.Controller, writer Writer) *WriteThrough {
+	return &WriteThrough{db: db, writer: writer}
+}
+
+// Set saves the key-value pair in the origin and then in db.
+// If saving to the origin fails, db is not touched
+func (w *WriteThrough) Set(key string, value string) error {
+	err := w.writer(key, value)
+	if err != nil {
+		return err
+	}
+
+	return w.db.Set(key, value)
+}
+
+// Get retrieves the value corresponding to the given key from db
+func (w *WriteThrough) Get(key string) (string, error) {
+	return w.db.Get(key)
+}
+
+// encodeEntry prefixes the value with the timestamp when it expires
+func encodeEntry(value string, ttl time.Duration) string {
+	var expiresAt int64
+	if ttl > 0 {
+		expiresAt = time.Now().Add(ttl).UnixNano()
+	}
+
+	return fmt.Sprintf("%d%s%s", expiresAt, expirySeparator, value)
+}
+
+// decodeEntry extracts the value and its expiry timestamp from the given entry
+func decodeEntry(entry string) (string, int64, error) {
+	parts := strings.SplitN(entry, expirySeparator, 2)
+	if len(parts) != 2 {
+		return "", 0, ckydb.ErrCorruptedData
+	}
+
+	expiresAt, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return "", 0, ckydb.ErrCorruptedData
+	}
+
+	return parts[1], expiresAt, nil
+}
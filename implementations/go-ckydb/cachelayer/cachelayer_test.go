@@ -0,0 +1,187 @@
+package cachelayer
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sopherapps/ckydb/implementations/go-ckydb"
+	"github.com/sopherapps/ckydb/implementations/go-ckydb/internal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCacheLayer(t *testing.T) {
+	dbPath, err := filepath.Abs("testCacheLayerDb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vacuumIntervalSec := 60.0
+	maxFileSizeKB := 4.0
+	origin := map[string]string{
+		"hey":   "English",
+		"salut": "French",
+	}
+
+	t.Run("ReadThroughGetShouldLoadMissingKeysFromOriginOnce", func(t *testing.T) {
+		var loads int32
+		db := connectToTestDb(t, dbPath, maxFileSizeKB, vacuumIntervalSec)
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+
+		cache := NewReadThrough(db, func(key string) (string, error) {
+			atomic.AddInt32(&loads, 1)
+			return origin[key], nil
+		}, time.Minute)
+
+		for i := 0; i < 3; i++ {
+			value, err := cache.Get("hey")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, "English", value)
+		}
+
+		assert.Equal(t, int32(1), atomic.LoadInt32(&loads))
+	})
+
+	t.Run("ReadThroughGetShouldReloadExpiredKeysFromOrigin", func(t *testing.T) {
+		var loads int32
+		db := connectToTestDb(t, dbPath, maxFileSizeKB, vacuumIntervalSec)
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+
+		cache := NewReadThrough(db, func(key string) (string, error) {
+			atomic.AddInt32(&loads, 1)
+			return origin[key], nil
+		}, time.Millisecond)
+
+		_, err := cache.Get("salut")
+		if err != nil {
+			t.Fatal(err)
+		}
+		<-time.After(5 * time.Millisecond)
+		value, err := cache.Get("salut")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, "French", value)
+		assert.Equal(t, int32(2), atomic.LoadInt32(&loads))
+	})
+
+	t.Run("ReadThroughGetShouldCoalesceConcurrentMisses", func(t *testing.T) {
+		var loads int32
+		var wg sync.WaitGroup
+		db := connectToTestDb(t, dbPath, maxFileSizeKB, vacuumIntervalSec)
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+
+		cache := NewReadThrough(db, func(key string) (string, error) {
+			atomic.AddInt32(&loads, 1)
+			<-time.After(50 * time.Millisecond)
+			return origin[key], nil
+		}, 0)
+
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				value, err := cache.Get("hey")
+				assert.Nil(t, err)
+				assert.Equal(t, "English", value)
+			}()
+		}
+		wg.Wait()
+
+		assert.Equal(t, int32(1), atomic.LoadInt32(&loads))
+	})
+
+	t.Run("ReadThroughGetShouldReturnLoaderErrors", func(t *testing.T) {
+		db := connectToTestDb(t, dbPath, maxFileSizeKB, vacuumIntervalSec)
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+
+		cache := NewReadThrough(db, func(key string) (string, error) {
+			return "", ckydb.ErrNotFound
+		}, time.Minute)
+
+		_, err := cache.Get("non-existent")
+		_, errInDb := db.Get("non-existent")
+
+		assert.True(t, errors.Is(err, ckydb.ErrNotFound))
+		assert.True(t, errors.Is(errInDb, ckydb.ErrNotFound))
+	})
+
+	t.Run("WriteThroughSetShouldSaveInOriginThenInDb", func(t *testing.T) {
+		written := map[string]string{}
+		db := connectToTestDb(t, dbPath, maxFileSizeKB, vacuumIntervalSec)
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+
+		cache := NewWriteThrough(db, func(key string, value string) error {
+			written[key] = value
+			return nil
+		})
+
+		err := cache.Set("hola", "Spanish")
+		if err != nil {
+			t.Fatal(err)
+		}
+		value, err := cache.Get("hola")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, "Spanish", value)
+		assert.Equal(t, map[string]string{"hola": "Spanish"}, written)
+	})
+
+	t.Run("WriteThroughSetShouldNotSaveInDbIfOriginFails", func(t *testing.T) {
+		errOrigin := errors.New("origin is down")
+		db := connectToTestDb(t, dbPath, maxFileSizeKB, vacuumIntervalSec)
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+
+		cache := NewWriteThrough(db, func(key string, value string) error {
+			return errOrigin
+		})
+
+		err := cache.Set("hola", "Spanish")
+		_, errInDb := db.Get("hola")
+
+		assert.True(t, errors.Is(err, errOrigin))
+		assert.True(t, errors.Is(errInDb, ckydb.ErrNotFound))
+	})
+}
+
+// connectToTestDb opens an empty db at the given path after
+// clearing out old data
+func connectToTestDb(t *testing.T, dbPath string, maxFileSizeKB float64, vacuumIntervalSec float64) *ckydb.Ckydb {
+	err := internal.ClearDummyFileDataInDb(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := ckydb.Connect(dbPath, maxFileSizeKB, vacuumIntervalSec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
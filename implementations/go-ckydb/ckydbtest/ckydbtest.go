@@ -0,0 +1,319 @@
+// Package ckydbtest has an in-memory fake of a ckydb database for the tests of applications using ckydb,
+// so that they do not have to write their own mocks or touch the disk
+package ckydbtest
+
+import (
+	"iter"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sopherapps/ckydb/implementations/go-ckydb"
+)
+
+// Op is an operation of the Fake that errors can be injected into, named like the operations in ckydb.Stats
+type Op string
+
+const (
+	OpOpen    Op = "open"
+	OpClose   Op = "close"
+	OpSet     Op = "set"
+	OpGet     Op = "get"
+	OpDelete  Op = "delete"
+	OpClear   Op = "clear"
+	OpVacuum  Op = "vacuum"
+	OpCompact Op = "compact"
+)
+
+// failure is an operation, on a given key or on any key if it is empty, that fails with an injected error
+type failure struct {
+	op  Op
+	key string
+}
+
+// Fake is an in-memory ckydb.Database, open from the start like the databases returned by ckydb.Connect.
+// Like them, it returns a ckydb.ErrNotFound error on getting or deleting a nonexistent key, a ckydb.ErrReservedKey
+// error on setting or deleting a reserved key, and a ckydb.ErrDatabaseClosed error once it is closed.
+// It is safe for concurrent use
+type Fake struct {
+	data     map[string]string
+	isClosed bool
+	failures map[failure]error
+	next     map[Op][]error
+	ops      map[string]int64
+	errors   map[string]int64
+	lock     sync.Mutex
+}
+
+// New creates a new empty Fake
+func New() *Fake {
+	return &Fake{
+		data:     map[string]string{},
+		failures: map[failure]error{},
+		next:     map[Op][]error{},
+		ops:      map[string]int64{},
+		errors:   map[string]int64{},
+	}
+}
+
+// Seed sets the given key-value pairs, whether the Fake is open or not and without any injected error
+func (f *Fake) Seed(data map[string]string) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	for key, value := range data {
+		f.data[key] = value
+	}
+}
+
+// FailOn makes every call of op on the given key, or on any key if it is empty, fail with err without changing
+// anything. Errors set for the key are returned before those set for any key. A nil err removes the failure
+func (f *Fake) FailOn(op Op, key string, err error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if err == nil {
+		delete(f.failures, failure{op: op, key: key})
+		return
+	}
+
+	f.failures[failure{op: op, key: key}] = err
+}
+
+// FailNext makes the next n calls of op, whatever their keys, fail with err without changing anything.
+// They fail before any of the failures set with FailOn
+func (f *Fake) FailNext(op Op, n int, err error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	for i := 0; i < n; i++ {
+		f.next[op] = append(f.next[op], err)
+	}
+}
+
+// AssertKey reports an error on t unless the key has the expected value
+func (f *Fake) AssertKey(t testing.TB, key string, expected string) {
+	t.Helper()
+	f.lock.Lock()
+	value, ok := f.data[key]
+	f.lock.Unlock()
+
+	if !ok {
+		t.Errorf("ckydbtest: key %q is nonexistent, expected it to be %q", key, expected)
+	} else if value != expected {
+		t.Errorf("ckydbtest: key %q is %q, expected it to be %q", key, value, expected)
+	}
+}
+
+// AssertNoKey reports an error on t if the key exists
+func (f *Fake) AssertNoKey(t testing.TB, key string) {
+	t.Helper()
+	f.lock.Lock()
+	value, ok := f.data[key]
+	f.lock.Unlock()
+
+	if ok {
+		t.Errorf("ckydbtest: key %q is %q, expected it to be nonexistent", key, value)
+	}
+}
+
+// Open reopens the Fake if it was closed
+func (f *Fake) Open() error {
+	return f.run(OpOpen, "", func() error {
+		f.isClosed = false
+		return nil
+	})
+}
+
+// Close closes the Fake, after which every operation but Open, Stats and Tasks fails with a
+// ckydb.ErrDatabaseClosed error or returns nothing. The key-value pairs are kept for when it is reopened
+func (f *Fake) Close() error {
+	return f.run(OpClose, "", func() error {
+		f.isClosed = true
+		return nil
+	})
+}
+
+// Set sets the value of the key
+func (f *Fake) Set(key string, value string) error {
+	return f.runOpen(OpSet, key, func() error {
+		if strings.HasPrefix(key, ckydb.ReservedKeyPrefix) {
+			return ckydb.ErrReservedKey
+		}
+
+		f.data[key] = value
+		return nil
+	})
+}
+
+// Get returns the value of the key
+func (f *Fake) Get(key string) (string, error) {
+	var value string
+	err := f.runOpen(OpGet, key, func() error {
+		var ok bool
+		value, ok = f.data[key]
+		if !ok {
+			return ckydb.ErrNotFound
+		}
+
+		return nil
+	})
+
+	return value, err
+}
+
+// Delete deletes the key
+func (f *Fake) Delete(key string) error {
+	return f.runOpen(OpDelete, key, func() error {
+		if strings.HasPrefix(key, ckydb.ReservedKeyPrefix) {
+			return ckydb.ErrReservedKey
+		}
+
+		if _, ok := f.data[key]; !ok {
+			return ckydb.ErrNotFound
+		}
+
+		delete(f.data, key)
+		return nil
+	})
+}
+
+// Clear deletes all keys
+func (f *Fake) Clear() error {
+	return f.runOpen(OpClear, "", func() error {
+		f.data = map[string]string{}
+		return nil
+	})
+}
+
+// Vacuum does nothing as the Fake has no files, unless an error is injected into it
+func (f *Fake) Vacuum() error {
+	return f.runOpen(OpVacuum, "", func() error { return nil })
+}
+
+// Compact does nothing as the Fake has no files, unless an error is injected into it
+func (f *Fake) Compact() error {
+	return f.runOpen(OpCompact, "", func() error { return nil })
+}
+
+// Stats returns the number of calls and errors of each operation, and the number of keys
+func (f *Fake) Stats() ckydb.Stats {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	stats := ckydb.Stats{Ops: map[string]int64{}, Errors: map[string]int64{}, Keys: len(f.data)}
+	for op, n := range f.ops {
+		stats.Ops[op] = n
+	}
+	for op, n := range f.errors {
+		stats.Errors[op] = n
+	}
+
+	return stats
+}
+
+// Tasks returns nothing as the Fake has no background tasks
+func (f *Fake) Tasks() []ckydb.TaskStatus {
+	return nil
+}
+
+// Keys returns all keys in ascending order
+func (f *Fake) Keys() []string {
+	return f.keysWithPrefix("")
+}
+
+// Exists checks whether the key exists
+func (f *Fake) Exists(key string) bool {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	_, ok := f.data[key]
+	return !f.isClosed && ok
+}
+
+// All returns an iterator over all key-value pairs in ascending order of keys
+func (f *Fake) All() iter.Seq2[string, string] {
+	return f.Prefix("")
+}
+
+// Prefix returns an iterator over the key-value pairs whose keys start with the given prefix,
+// in ascending order of keys. Keys deleted after the iteration started are skipped
+func (f *Fake) Prefix(prefix string) iter.Seq2[string, string] {
+	return func(yield func(string, string) bool) {
+		for _, key := range f.keysWithPrefix(prefix) {
+			value, err := f.Get(key)
+			if err != nil {
+				continue
+			}
+
+			if !yield(key, value) {
+				return
+			}
+		}
+	}
+}
+
+// keysWithPrefix returns the keys starting with the given prefix in ascending order
+func (f *Fake) keysWithPrefix(prefix string) []string {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.isClosed {
+		return nil
+	}
+
+	keys := make([]string, 0)
+	for key := range f.data {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// runOpen runs fn like run, failing with a ckydb.ErrDatabaseClosed error if the Fake is closed
+func (f *Fake) runOpen(op Op, key string, fn func() error) error {
+	return f.run(op, key, func() error {
+		if f.isClosed {
+			return ckydb.ErrDatabaseClosed
+		}
+
+		return fn()
+	})
+}
+
+// run runs fn holding the lock unless an error is injected into op on the key, counting the call and its error
+func (f *Fake) run(op Op, key string, fn func() error) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.ops[string(op)]++
+	err := f.injectedError(op, key)
+	if err == nil {
+		err = fn()
+	}
+	if err != nil {
+		f.errors[string(op)]++
+	}
+
+	return err
+}
+
+// injectedError returns the error injected into op on the key, if any, consuming those of FailNext
+func (f *Fake) injectedError(op Op, key string) error {
+	if errs := f.next[op]; len(errs) > 0 {
+		f.next[op] = errs[1:]
+		return errs[0]
+	}
+
+	if err, ok := f.failures[failure{op: op, key: key}]; ok {
+		return err
+	}
+
+	return f.failures[failure{op: op}]
+}
+
+var _ ckydb.Database = (*Fake)(nil)
@@ -0,0 +1,125 @@
+package ckydbtest
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/sopherapps/ckydb/implementations/go-ckydb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFake(t *testing.T) {
+	errDiskFull := errors.New("disk is full")
+
+	t.Run("FakeShouldBehaveLikeADatabase", func(t *testing.T) {
+		db := New()
+		db.Seed(map[string]string{"cow": "500 months", "dog": "23 months"})
+
+		err := db.Set("goat", "678 months")
+		assert.Nil(t, err)
+		value, err := db.Get("cow")
+		assert.Nil(t, err)
+		assert.Equal(t, "500 months", value)
+		err = db.Delete("dog")
+		assert.Nil(t, err)
+
+		_, err = db.Get("dog")
+		assert.ErrorIs(t, err, ckydb.ErrNotFound)
+		err = db.Delete("dog")
+		assert.ErrorIs(t, err, ckydb.ErrNotFound)
+		err = db.Set(ckydb.ReservedKeyPrefix+"config", "x")
+		assert.ErrorIs(t, err, ckydb.ErrReservedKey)
+
+		assert.Equal(t, []string{"cow", "goat"}, db.Keys())
+		assert.True(t, db.Exists("goat"))
+		pairs := map[string]string{}
+		for key, value := range db.Prefix("g") {
+			pairs[key] = value
+		}
+		assert.Equal(t, map[string]string{"goat": "678 months"}, pairs)
+		db.AssertKey(t, "goat", "678 months")
+		db.AssertNoKey(t, "dog")
+
+		stats := db.Stats()
+		assert.Equal(t, 2, stats.Keys)
+		assert.Equal(t, int64(2), stats.Ops["delete"])
+		assert.Equal(t, int64(1), stats.Errors["delete"])
+
+		err = db.Clear()
+		assert.Nil(t, err)
+		assert.Empty(t, db.Keys())
+	})
+
+	t.Run("ClosedFakeShouldFailUntilReopened", func(t *testing.T) {
+		db := New()
+		db.Seed(map[string]string{"cow": "500 months"})
+
+		err := db.Close()
+		assert.Nil(t, err)
+		_, err = db.Get("cow")
+		assert.ErrorIs(t, err, ckydb.ErrDatabaseClosed)
+		assert.False(t, db.Exists("cow"))
+		assert.Empty(t, db.Keys())
+
+		err = db.Open()
+		assert.Nil(t, err)
+		value, err := db.Get("cow")
+		assert.Nil(t, err)
+		assert.Equal(t, "500 months", value)
+	})
+
+	t.Run("FailOnShouldFailTheOperationOnTheKeyUntilRemoved", func(t *testing.T) {
+		db := New()
+		db.FailOn(OpSet, "cow", errDiskFull)
+
+		for i := 0; i < 2; i++ {
+			err := db.Set("cow", "500 months")
+			assert.ErrorIs(t, err, errDiskFull)
+		}
+		db.AssertNoKey(t, "cow")
+		err := db.Set("dog", "23 months")
+		assert.Nil(t, err)
+
+		db.FailOn(OpSet, "", errDiskFull)
+		err = db.Set("goat", "678 months")
+		assert.ErrorIs(t, err, errDiskFull)
+
+		db.FailOn(OpSet, "cow", nil)
+		db.FailOn(OpSet, "", nil)
+		err = db.Set("cow", "500 months")
+		assert.Nil(t, err)
+		db.AssertKey(t, "cow", "500 months")
+	})
+
+	t.Run("FailNextShouldFailOnlyTheNextCalls", func(t *testing.T) {
+		db := New()
+		db.Seed(map[string]string{"cow": "500 months"})
+		db.FailNext(OpGet, 2, ckydb.ErrCorruptedData)
+
+		for i := 0; i < 2; i++ {
+			_, err := db.Get("cow")
+			assert.ErrorIs(t, err, ckydb.ErrCorruptedData)
+		}
+		value, err := db.Get("cow")
+		assert.Nil(t, err)
+		assert.Equal(t, "500 months", value)
+
+		db.FailNext(OpVacuum, 1, errDiskFull)
+		assert.ErrorIs(t, db.Vacuum(), errDiskFull)
+		assert.Nil(t, db.Vacuum())
+	})
+
+	t.Run("AssertKeyShouldReportMissingAndDifferentValues", func(t *testing.T) {
+		db := New()
+		db.Seed(map[string]string{"cow": "500 months"})
+
+		for key, expected := range map[string]string{"cow": "23 months", "dog": "23 months"} {
+			fakeT := &testing.T{}
+			db.AssertKey(fakeT, key, expected)
+			assert.True(t, fakeT.Failed())
+		}
+		fakeT := &testing.T{}
+		db.AssertNoKey(fakeT, "cow")
+		assert.True(t, fakeT.Failed())
+	})
+}
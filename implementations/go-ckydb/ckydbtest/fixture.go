@@ -0,0 +1,197 @@
+package ckydbtest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/sopherapps/ckydb/implementations/go-ckydb/internal"
+)
+
+// fixtureStartTimestamp is the timestamp of the first key of a fixture, that of the first data file
+// of ckydb's own dummy data
+const fixtureStartTimestamp = 1655375120328185000
+
+// Corruption is a way of damaging the files of a fixture, to test how a database recovers from it
+type Corruption int
+
+const (
+	// CorruptTornRecord appends a record cut short to the ".log" file, as a crash in the middle of appending it
+	// would leave behind. Connect repairs the file by dropping it
+	CorruptTornRecord Corruption = iota
+	// CorruptMalformedRecords appends a record that is not a key-value pair to the ".log", ".idx" and ".del" files,
+	// which Connect fails on with an ErrCorruptedData error unless ckydb.WithStrictLoad(false) makes it skip them
+	CorruptMalformedRecords
+)
+
+// fixture is the database folder built by BuildFixture
+type fixture struct {
+	keys        int
+	valueSize   int
+	dataFiles   int
+	deletedKeys int
+	corruptions []Corruption
+}
+
+// FixtureOption configures the database folder built by BuildFixture
+type FixtureOption func(f *fixture)
+
+// WithFixtureKeys sets the number of keys of the fixture, named "key-000000", "key-000001" and so on in the order
+// they were set in. It defaults to 10
+func WithFixtureKeys(n int) FixtureOption {
+	return func(f *fixture) {
+		f.keys = n
+	}
+}
+
+// WithFixtureValueSize sets the size in bytes of each value, padded after its key e.g. "value-of-key-000000xxx".
+// Values are never shorter than that. It defaults to 0
+func WithFixtureValueSize(bytes int) FixtureOption {
+	return func(f *fixture) {
+		f.valueSize = bytes
+	}
+}
+
+// WithFixtureDataFiles spreads the keys of the fixture evenly across the given number of ".cky" files and the
+// ".log" file, oldest first. It defaults to 1
+func WithFixtureDataFiles(n int) FixtureOption {
+	return func(f *fixture) {
+		f.dataFiles = n
+	}
+}
+
+// WithFixtureDeletedKeys marks the given number of keys, spread across the files, as deleted in the ".del" file
+// without removing their records, as a database stopped before vacuuming them would have it.
+// They are not in the pairs returned by BuildFixture
+func WithFixtureDeletedKeys(n int) FixtureOption {
+	return func(f *fixture) {
+		f.deletedKeys = n
+	}
+}
+
+// WithFixtureCorruption damages the files of the fixture in the given way. It can be given several times
+func WithFixtureCorruption(c Corruption) FixtureOption {
+	return func(f *fixture) {
+		f.corruptions = append(f.corruptions, c)
+	}
+}
+
+// BuildFixture writes a database folder at dbPath, emptying it first, in the format that ckydb's own dummy data is
+// in, as generated from the given options instead of hardcoded. It returns the key-value pairs that a database
+// connected to it should have, for the tests of applications using ckydb and for conformance and fuzz suites
+func BuildFixture(dbPath string, opts ...FixtureOption) (map[string]string, error) {
+	f := fixture{keys: 10, dataFiles: 1}
+	for _, opt := range opts {
+		opt(&f)
+	}
+
+	err := os.RemoveAll(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	err = os.MkdirAll(dbPath, 0777)
+	if err != nil {
+		return nil, err
+	}
+
+	files, logFilename := f.files()
+	deleted := f.deleted()
+	pairs := make(map[string]string, f.keys)
+	contents := map[string]*strings.Builder{logFilename: {}, internal.IndexFilename: {}, internal.DelFilename: {}}
+	for i := 0; i < f.keys; i++ {
+		key, value := fixtureKey(i), f.value(i)
+		timestampedKey := fixtureTimestampedKey(i, key)
+
+		if contents[files[i]] == nil {
+			contents[files[i]] = &strings.Builder{}
+		}
+		contents[files[i]].WriteString(timestampedKey + internal.KeyValueSeparator + value + internal.TokenSeparator)
+
+		if deleted[i] {
+			contents[internal.DelFilename].WriteString(timestampedKey + internal.TokenSeparator)
+			continue
+		}
+
+		contents[internal.IndexFilename].WriteString(key + internal.KeyValueSeparator + timestampedKey + internal.TokenSeparator)
+		pairs[key] = value
+	}
+
+	for _, c := range f.corruptions {
+		switch c {
+		case CorruptTornRecord:
+			contents[logFilename].WriteString(fixtureTimestampedKey(f.keys, "torn") + internal.KeyValueSeparator + "cut sh")
+		case CorruptMalformedRecords:
+			for _, filename := range []string{logFilename, internal.IndexFilename, internal.DelFilename} {
+				contents[filename].WriteString("garbage" + internal.TokenSeparator)
+			}
+		}
+	}
+
+	for filename, content := range contents {
+		err = os.WriteFile(filepath.Join(dbPath, filename), []byte(content.String()), 0666)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return pairs, nil
+}
+
+// files returns the name of the file holding each key, and that of the ".log" file. The keys are split into
+// chunks of the same size, the ".log" file holding the last one, and each file is named after its first key
+func (f fixture) files() ([]string, string) {
+	chunks := f.dataFiles + 1
+	chunkSize := (f.keys + chunks - 1) / chunks
+	logStart := min(f.dataFiles*chunkSize, f.keys)
+	logFilename := fmt.Sprintf("%d.%s", fixtureTimestamp(logStart), internal.LogFileExt)
+
+	files := make([]string, f.keys)
+	for i := range files {
+		start := i - i%chunkSize
+		if start >= logStart {
+			files[i] = logFilename
+		} else {
+			files[i] = fmt.Sprintf("%d.%s", fixtureTimestamp(start), internal.DataFileExt)
+		}
+	}
+
+	return files, logFilename
+}
+
+// deleted returns whether each key is marked as deleted, spreading the deleted keys evenly across the keys
+func (f fixture) deleted() []bool {
+	deleted := make([]bool, f.keys)
+	n := min(f.deletedKeys, f.keys)
+	for i := 0; i < n; i++ {
+		deleted[i*f.keys/n] = true
+	}
+
+	return deleted
+}
+
+// value returns the value of the i-th key, padded to the value size
+func (f fixture) value(i int) string {
+	value := "value-of-" + fixtureKey(i)
+	if len(value) < f.valueSize {
+		value += strings.Repeat("x", f.valueSize-len(value))
+	}
+
+	return value
+}
+
+// fixtureKey returns the i-th key of a fixture
+func fixtureKey(i int) string {
+	return fmt.Sprintf("key-%06d", i)
+}
+
+// fixtureTimestamp returns the timestamp of the i-th key of a fixture, a microsecond after the previous one
+func fixtureTimestamp(i int) int64 {
+	return fixtureStartTimestamp + int64(i)*1000
+}
+
+// fixtureTimestampedKey returns the timestamped key of the i-th key of a fixture
+func fixtureTimestampedKey(i int, key string) string {
+	return strconv.FormatInt(fixtureTimestamp(i), 10) + "-" + key
+}
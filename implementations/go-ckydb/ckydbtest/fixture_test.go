@@ -0,0 +1,106 @@
+package ckydbtest
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sopherapps/ckydb/implementations/go-ckydb"
+	"github.com/sopherapps/ckydb/implementations/go-ckydb/internal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFixture(t *testing.T) {
+	dbPath, err := filepath.Abs("testFixtureDb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = internal.ClearDummyFileDataInDb(dbPath) }()
+
+	// assertPairs asserts that the database has exactly the given key-value pairs
+	assertPairs := func(t *testing.T, db *ckydb.Ckydb, expected map[string]string) {
+		got := map[string]string{}
+		for key, value := range db.All() {
+			got[key] = value
+		}
+		assert.Equal(t, expected, got)
+	}
+
+	t.Run("BuildFixtureShouldSpreadTheKeysAcrossTheDataFiles", func(t *testing.T) {
+		pairs, err := BuildFixture(dbPath, WithFixtureKeys(100), WithFixtureDataFiles(3), WithFixtureValueSize(64), WithFixtureDeletedKeys(10))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Len(t, pairs, 90)
+		assert.Equal(t, "value-of-key-000001"+strings.Repeat("x", 45), pairs["key-000001"])
+		assert.NotContains(t, pairs, "key-000000")
+
+		db, err := ckydb.Connect(dbPath, 1024, 3600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = db.Close() }()
+
+		assertPairs(t, db, pairs)
+		segments, err := db.Segments()
+		assert.Nil(t, err)
+		assert.Len(t, segments, 3)
+	})
+
+	t.Run("BuildFixtureShouldPutAllKeysInTheLogFileWithoutDataFiles", func(t *testing.T) {
+		pairs, err := BuildFixture(dbPath, WithFixtureDataFiles(0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Len(t, pairs, 10)
+
+		db, err := ckydb.Connect(dbPath, 1024, 3600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = db.Close() }()
+
+		assertPairs(t, db, pairs)
+		segments, err := db.Segments()
+		assert.Nil(t, err)
+		assert.Empty(t, segments)
+	})
+
+	t.Run("CorruptTornRecordShouldBeRepairedOnConnect", func(t *testing.T) {
+		pairs, err := BuildFixture(dbPath, WithFixtureCorruption(CorruptTornRecord))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		db, err := ckydb.Connect(dbPath, 1024, 3600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = db.Close() }()
+
+		assertPairs(t, db, pairs)
+	})
+
+	t.Run("CorruptMalformedRecordsShouldFailStrictLoads", func(t *testing.T) {
+		_, err := BuildFixture(dbPath, WithFixtureCorruption(CorruptMalformedRecords))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = ckydb.Connect(dbPath, 1024, 3600)
+		assert.ErrorIs(t, err, ckydb.ErrCorruptedData)
+
+		pairs, err := BuildFixture(dbPath, WithFixtureCorruption(CorruptMalformedRecords))
+		if err != nil {
+			t.Fatal(err)
+		}
+		db, err := ckydb.Connect(dbPath, 1024, 3600, ckydb.WithStrictLoad(false))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = db.Close() }()
+
+		assertPairs(t, db, pairs)
+		assert.Equal(t, int64(3), db.Stats().SkippedRecords)
+	})
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"math/rand/v2"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sopherapps/ckydb/implementations/go-ckydb"
+)
+
+// benchKeyPrefix is the prefix of the keys written by bench, so that they do not overwrite the other keys
+// of the database
+const benchKeyPrefix = "bench-"
+
+// benchConfig is the configuration of a run of bench
+type benchConfig struct {
+	dbPath            string
+	writers           int
+	readers           int
+	keys              int
+	valueSize         int
+	duration          time.Duration
+	maxFileSizeKB     float64
+	vacuumIntervalSec float64
+	opts              []ckydb.Option
+}
+
+// benchPhase is what was measured over a phase of bench
+type benchPhase struct {
+	name    string
+	elapsed time.Duration
+	stats   ckydb.Stats
+}
+
+// bench loads the database at the path given as the only argument with keys and then reads and overwrites them
+// at random, reporting the throughput and latencies of each phase
+func bench(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("bench", flag.ContinueOnError)
+	flags.SetOutput(stdout)
+	flags.Usage = func() {
+		_, _ = fmt.Fprintf(stdout, "Usage:\n\n\tckydb bench <path> [flags]\n\nThe flags are:\n\n")
+		flags.PrintDefaults()
+	}
+	writers := flags.Int("writers", 8, "number of goroutines setting keys")
+	readers := flags.Int("readers", 8, "number of goroutines getting keys while the writers overwrite them")
+	keys := flags.Float64("keys", 1e4, "number of keys to load, e.g. 1e6")
+	valueSize := flags.Int("value-size", 256, "size in bytes of the values")
+	duration := flags.Duration("duration", 10*time.Second, "how long the readers and writers run once the keys are loaded")
+	maxFileSizeKB := flags.Float64("max-file-size-kb", defaultMaxFileSizeKB, "size in kilobytes beyond which the log file is rolled")
+	vacuumIntervalSec := flags.Float64("vacuum-interval-sec", defaultVacuumIntervalSec, "interval in seconds between vacuums")
+	cachePrefetch := flags.Bool("cache-prefetch", false, "load the neighbouring data file into the cache in the background")
+	compressionThreshold := flags.Int("compression-threshold", 0, "size in bytes from which values are compressed, 0 for never")
+
+	// the path comes before the flags in "ckydb bench <path> -writers 8", which flag would stop at
+	err := flags.Parse(args)
+	var dbPath string
+	if err == nil && flags.NArg() > 0 {
+		dbPath = flags.Arg(0)
+		err = flags.Parse(flags.Args()[1:])
+	}
+	if err != nil {
+		return err
+	}
+
+	if dbPath == "" || flags.NArg() != 0 || *writers < 1 || *readers < 0 || *keys < 1 || *keys != math.Trunc(*keys) || *valueSize < 1 {
+		flags.Usage()
+		return fmt.Errorf("expected the path to the database folder, at least 1 writer, whole -keys of at least 1 and a -value-size of at least 1")
+	}
+
+	config := benchConfig{
+		dbPath:            dbPath,
+		writers:           *writers,
+		readers:           *readers,
+		keys:              int(*keys),
+		valueSize:         *valueSize,
+		duration:          *duration,
+		maxFileSizeKB:     *maxFileSizeKB,
+		vacuumIntervalSec: *vacuumIntervalSec,
+		opts:              []ckydb.Option{ckydb.WithCachePrefetch(*cachePrefetch)},
+	}
+	if *compressionThreshold > 0 {
+		config.opts = append(config.opts, ckydb.WithValueCompressionThreshold(*compressionThreshold))
+	}
+
+	phases, err := runBench(config)
+	if err != nil {
+		return err
+	}
+
+	return printBenchReport(stdout, config, phases)
+}
+
+// runBench runs the phases of bench, each on a new connection so that its stats are its own:
+// "load" sets every key, split between the writers, and "mixed" has the readers get and the writers
+// set random keys for the duration
+func runBench(config benchConfig) ([]benchPhase, error) {
+	load, err := runBenchPhase(config, "load", func(db *ckydb.Ckydb) error {
+		return runBenchWorkers(config.writers, func(worker int, _ *rand.Rand) (bool, error) {
+			return false, runBenchLoader(db, config, worker)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	mixed, err := runBenchPhase(config, "mixed", func(db *ckydb.Ckydb) error {
+		deadline := time.Now().Add(config.duration)
+		return runBenchWorkers(config.writers+config.readers, func(worker int, r *rand.Rand) (bool, error) {
+			if time.Now().After(deadline) {
+				return false, nil
+			}
+
+			key := benchKey(r.IntN(config.keys))
+			if worker < config.writers {
+				return true, db.Set(key, benchValue(r, config.valueSize))
+			}
+
+			_, err := db.Get(key)
+			return true, err
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return []benchPhase{load, mixed}, nil
+}
+
+// runBenchPhase connects to the database, runs the phase on it and closes it, returning the stats of the connection.
+// The time taken to load the database when connecting is reported as the "open" row of the phase
+func runBenchPhase(config benchConfig, name string, run func(db *ckydb.Ckydb) error) (benchPhase, error) {
+	db, err := ckydb.Connect(config.dbPath, config.maxFileSizeKB, config.vacuumIntervalSec, config.opts...)
+	if err != nil {
+		return benchPhase{}, err
+	}
+
+	start := time.Now()
+	err = run(db)
+	phase := benchPhase{name: name, elapsed: time.Since(start), stats: db.Stats()}
+	return phase, errors.Join(err, db.Close())
+}
+
+// runBenchLoader sets the keys whose index modulo the number of writers is the worker
+func runBenchLoader(db *ckydb.Ckydb, config benchConfig, worker int) error {
+	r := rand.New(rand.NewPCG(uint64(worker), 0))
+	value := benchValue(r, config.valueSize)
+	for i := worker; i < config.keys; i += config.writers {
+		err := db.Set(benchKey(i), value)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// runBenchWorkers runs the given number of goroutines, each calling step with its index until step returns false
+// or any goroutine fails, and returns the first error
+func runBenchWorkers(workers int, step func(worker int, r *rand.Rand) (bool, error)) error {
+	var wg sync.WaitGroup
+	var failed atomic.Bool
+	errs := make([]error, workers)
+	for worker := 0; worker < workers; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r := rand.New(rand.NewPCG(uint64(worker), 1))
+			for !failed.Load() {
+				more, err := step(worker, r)
+				if err != nil {
+					errs[worker] = err
+					failed.Store(true)
+				}
+				if !more {
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// printBenchReport writes the throughput and latencies of the operations of each phase to w
+func printBenchReport(w io.Writer, config benchConfig, phases []benchPhase) error {
+	_, err := fmt.Fprintf(w, "%d keys of %d bytes, %d writers, %d readers, max file size %gKB\n\n",
+		config.keys, config.valueSize, config.writers, config.readers, config.maxFileSizeKB)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "phase\top\tcalls\tops/s\tmean\tp50\tp90\tp99\tp99.9\tmax")
+	for _, phase := range phases {
+		if load, ok := phase.stats.Latency["load"]; ok {
+			_, _ = fmt.Fprintf(tw, "%s\topen\t1\t-\t%v\t%v\t%v\t%v\t%v\t%v\n", phase.name, load.Mean, load.P50, load.P90, load.P99, load.P999, load.Max)
+		}
+
+		for _, op := range []string{"set", "get"} {
+			latency, ok := phase.stats.Latency[op]
+			if !ok {
+				continue
+			}
+
+			throughput := float64(latency.Count) / phase.elapsed.Seconds()
+			_, _ = fmt.Fprintf(tw, "%s\t%s\t%d\t%.0f\t%v\t%v\t%v\t%v\t%v\t%v\n", phase.name, op, latency.Count, throughput,
+				latency.Mean, latency.P50, latency.P90, latency.P99, latency.P999, latency.Max)
+		}
+	}
+	err = tw.Flush()
+	if err != nil {
+		return err
+	}
+
+	last := phases[len(phases)-1].stats
+	_, err = fmt.Fprintf(w, "\n%d keys in %d data files, %d cache hits, %d cache misses\n", last.Keys, last.DataFiles, last.CacheHits, last.CacheMisses)
+	return err
+}
+
+// benchKey returns the key of index i
+func benchKey(i int) string {
+	return fmt.Sprintf("%s%d", benchKeyPrefix, i)
+}
+
+// benchValue returns a value of the given size made of random lowercase letters
+func benchValue(r *rand.Rand, size int) string {
+	var b strings.Builder
+	b.Grow(size)
+	for i := 0; i < size; i++ {
+		b.WriteByte(byte('a' + r.IntN(26)))
+	}
+
+	return b.String()
+}
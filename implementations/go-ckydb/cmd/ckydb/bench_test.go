@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/sopherapps/ckydb/implementations/go-ckydb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBench(t *testing.T) {
+	t.Run("BenchShouldLoadTheKeysAndReportTheLatenciesOfEachPhase", func(t *testing.T) {
+		dbPath := t.TempDir()
+		var stdout bytes.Buffer
+		err := run([]string{"bench", dbPath, "--writers", "2", "--readers", "2", "--keys", "1e2", "--value-size", "16", "-duration", "50ms"}, &stdout)
+		assert.Nil(t, err)
+
+		report := stdout.String()
+		assert.Contains(t, report, "100 keys of 16 bytes, 2 writers, 2 readers")
+		assert.Regexp(t, `(?m)^load\s+set\s+100\s`, report)
+		assert.Regexp(t, `(?m)^mixed\s+open\s+1\s`, report)
+		assert.Regexp(t, `(?m)^mixed\s+get\s+\d+\s`, report)
+		assert.Contains(t, report, "100 keys in")
+
+		db, err := ckydb.Connect(dbPath, defaultMaxFileSizeKB, defaultVacuumIntervalSec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = db.Close() }()
+		value, err := db.Get(benchKey(99))
+		assert.Nil(t, err)
+		assert.Len(t, value, 16)
+	})
+
+	t.Run("BenchShouldFailOnInvalidFlags", func(t *testing.T) {
+		for _, args := range [][]string{
+			{"bench"},
+			{"bench", t.TempDir(), "-writers", "0"},
+			{"bench", t.TempDir(), "-keys", "1.5"},
+			{"bench", t.TempDir(), "-value-size", "0"},
+			{"bench", t.TempDir(), "extra"},
+		} {
+			var stdout bytes.Buffer
+			err := run(args, &stdout)
+			assert.NotNil(t, err, args)
+		}
+	})
+}
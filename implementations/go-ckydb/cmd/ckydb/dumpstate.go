@@ -0,0 +1,50 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/sopherapps/ckydb/implementations/go-ckydb/internal"
+)
+
+// dumpState writes a JSON description of the state of the database folder given as the only argument, as it is
+// loaded by a store, to stdout. The folder is loaded as a follower, so that it is only read, even if it is open
+func dumpState(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("dump-state", flag.ContinueOnError)
+	flags.SetOutput(stdout)
+	flags.Usage = func() {
+		_, _ = fmt.Fprintf(stdout, "Usage:\n\n\tckydb dump-state [flags] <path>\n\nThe flags are:\n\n")
+		flags.PrintDefaults()
+	}
+	hmacKey := flags.String("hmac-key", os.Getenv("CKYDB_HMAC_KEY"), "key the records of the database are authenticated with, if they are")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf("expected the path to the database folder")
+	}
+
+	opts := []internal.StoreOption{internal.WithFollower(true)}
+	if *hmacKey != "" {
+		opts = append(opts, internal.WithRecordHMAC([]byte(*hmacKey)))
+	}
+
+	store := internal.NewStore(flags.Arg(0), defaultMaxFileSizeKB, opts...)
+	err = store.Load()
+	if err != nil {
+		return err
+	}
+
+	state, err := internal.DumpState(store)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(stdout, "%s\n", state)
+	return err
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/sopherapps/ckydb/implementations/go-ckydb"
+	"github.com/sopherapps/ckydb/implementations/go-ckydb/internal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDumpState(t *testing.T) {
+	t.Run("DumpStateShouldWriteTheStateOfTheDatabaseAsJSON", func(t *testing.T) {
+		dbPath := t.TempDir()
+		db, err := ckydb.Connect(dbPath, defaultMaxFileSizeKB, defaultVacuumIntervalSec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = db.Close() }()
+		err = db.Set("foo", "bar")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var stdout bytes.Buffer
+		err = run([]string{"dump-state", dbPath}, &stdout)
+		assert.Nil(t, err)
+
+		var state internal.StoreState
+		err = json.Unmarshal(stdout.Bytes(), &state)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, state.IndexSize)
+		assert.True(t, state.IsFollower)
+		assert.Len(t, state.MemtableKeys, 1)
+		assert.Contains(t, state.MemtableKeys[0], "-foo")
+	})
+
+	t.Run("DumpStateShouldFailOnMissingDatabases", func(t *testing.T) {
+		var stdout bytes.Buffer
+		err := run([]string{"dump-state", t.TempDir() + "/missing"}, &stdout)
+		assert.NotNil(t, err)
+		err = run([]string{"dump-state"}, &stdout)
+		assert.NotNil(t, err)
+	})
+}
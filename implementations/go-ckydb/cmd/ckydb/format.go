@@ -0,0 +1,58 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/sopherapps/ckydb/implementations/go-ckydb"
+)
+
+// migrateFormat converts the database folder given as the only argument, which must not be open, to the format
+// version of the -to flag, resuming the conversion if a previous run was interrupted
+func migrateFormat(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	flags.SetOutput(stdout)
+	flags.Usage = func() {
+		_, _ = fmt.Fprintf(stdout, "Usage:\n\n\tckydb migrate -to <v1|v2|v3> [flags] <path>\n\nThe flags are:\n\n")
+		flags.PrintDefaults()
+	}
+	to := flags.String("to", fmt.Sprintf("v%d", ckydb.CurrentFormatVersion), "format version to convert the database to: v3 for a compact index, v2 for length-prefixed records, or v1")
+	compressionThreshold := flags.Int("compression-threshold", 1024, "size in bytes from which values are compressed when converting to v2")
+	hmacKey := flags.String("hmac-key", os.Getenv("CKYDB_HMAC_KEY"), "key the records of the database are authenticated with, if they are")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf("expected the path to the database folder")
+	}
+
+	version, err := strconv.Atoi(strings.TrimPrefix(*to, "v"))
+	if err != nil {
+		return fmt.Errorf("%w: %q", ckydb.ErrUnsupportedFormatVersion, *to)
+	}
+
+	from, err := ckydb.ReadFormatVersion(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	opts := []ckydb.Option{ckydb.WithValueCompressionThreshold(*compressionThreshold)}
+	if *hmacKey != "" {
+		opts = append(opts, ckydb.WithRecordHMAC([]byte(*hmacKey)))
+	}
+
+	err = ckydb.MigrateFormatTo(flags.Arg(0), version, opts...)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(stdout, "migrated: v%d -> v%d\n", from, version)
+	return err
+}
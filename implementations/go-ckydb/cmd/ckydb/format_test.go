@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/sopherapps/ckydb/implementations/go-ckydb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrateFormat(t *testing.T) {
+	t.Run("MigrateShouldConvertTheDatabaseToTheGivenVersion", func(t *testing.T) {
+		dbPath := t.TempDir()
+		db, err := ckydb.Connect(dbPath, defaultMaxFileSizeKB, defaultVacuumIntervalSec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = db.Set("foo", "bar")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = db.Close()
+
+		var stdout bytes.Buffer
+		err = run([]string{"migrate", "--to", "v2", dbPath}, &stdout)
+		assert.Nil(t, err)
+		assert.Equal(t, "migrated: v1 -> v2\n", stdout.String())
+		version, err := ckydb.ReadFormatVersion(dbPath)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, version)
+
+		stdout.Reset()
+		err = run([]string{"migrate", "-to", "1", dbPath}, &stdout)
+		assert.Nil(t, err)
+		assert.Equal(t, "migrated: v2 -> v1\n", stdout.String())
+
+		db, err = ckydb.Connect(dbPath, defaultMaxFileSizeKB, defaultVacuumIntervalSec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = db.Close() }()
+		value, err := db.Get("foo")
+		assert.Nil(t, err)
+		assert.Equal(t, "bar", value)
+	})
+
+	t.Run("MigrateShouldFailOnUnknownVersions", func(t *testing.T) {
+		var stdout bytes.Buffer
+		err := run([]string{"migrate", "-to", "v9", t.TempDir()}, &stdout)
+		assert.ErrorIs(t, err, ckydb.ErrUnsupportedFormatVersion)
+		err = run([]string{"migrate", "-to", "latest", t.TempDir()}, &stdout)
+		assert.ErrorIs(t, err, ckydb.ErrUnsupportedFormatVersion)
+	})
+}
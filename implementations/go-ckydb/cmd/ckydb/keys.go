@@ -0,0 +1,61 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/sopherapps/ckydb/implementations/go-ckydb"
+)
+
+// listKeys writes the keys of the database folder given as the only argument that match the regular expression
+// of the -regex flag to stdout, one per line in ascending order. The folder is opened as a follower, so that it is
+// only read, even if it is open
+func listKeys(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("keys", flag.ContinueOnError)
+	flags.SetOutput(stdout)
+	flags.Usage = func() {
+		_, _ = fmt.Fprintf(stdout, "Usage:\n\n\tckydb keys [flags] <path>\n\nThe flags are:\n\n")
+		flags.PrintDefaults()
+	}
+	pattern := flags.String("regex", "", "regular expression that the keys listed match, all of them if empty")
+	limit := flags.Int("limit", 0, "maximum number of keys listed, all of them if 0")
+	hmacKey := flags.String("hmac-key", os.Getenv("CKYDB_HMAC_KEY"), "key the records of the database are authenticated with, if they are")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf("expected the path to the database folder")
+	}
+
+	// the follower is never refreshed as the keys are listed right away
+	opts := []ckydb.Option{ckydb.WithFollower(time.Hour)}
+	if *hmacKey != "" {
+		opts = append(opts, ckydb.WithRecordHMAC([]byte(*hmacKey)))
+	}
+
+	db, err := ckydb.Connect(flags.Arg(0), defaultMaxFileSizeKB, defaultVacuumIntervalSec, opts...)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+
+	keys, err := db.ScanRegex(*pattern, *limit)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		_, err = fmt.Fprintln(stdout, key)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/sopherapps/ckydb/implementations/go-ckydb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeys(t *testing.T) {
+	t.Run("KeysShouldListTheKeysMatchingTheRegex", func(t *testing.T) {
+		dbPath := t.TempDir()
+		db, err := ckydb.Connect(dbPath, defaultMaxFileSizeKB, defaultVacuumIntervalSec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = db.Close() }()
+		for _, key := range []string{"user:1", "user:22", "order:1", "user:x"} {
+			err = db.Set(key, "value")
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		var stdout bytes.Buffer
+		err = run([]string{"keys", "-regex", `^user:\d+$`, dbPath}, &stdout)
+		assert.Nil(t, err)
+		assert.Equal(t, "user:1\nuser:22\n", stdout.String())
+
+		stdout.Reset()
+		err = run([]string{"keys", "-limit", "1", dbPath}, &stdout)
+		assert.Nil(t, err)
+		assert.Equal(t, "order:1\n", stdout.String())
+	})
+
+	t.Run("KeysShouldFailOnInvalidRegexesAndMissingDatabases", func(t *testing.T) {
+		var stdout bytes.Buffer
+		err := run([]string{"keys", "-regex", "(", t.TempDir()}, &stdout)
+		assert.NotNil(t, err)
+		err = run([]string{"keys", t.TempDir() + "/missing"}, &stdout)
+		assert.NotNil(t, err)
+		err = run([]string{"keys"}, &stdout)
+		assert.NotNil(t, err)
+	})
+}
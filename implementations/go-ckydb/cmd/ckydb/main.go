@@ -0,0 +1,113 @@
+// Command ckydb is a tool for managing ckydb databases.
+//
+// Usage:
+//
+//	ckydb <command> [flags] [args]
+//
+// The commands are:
+//
+//	bench           load a database with keys and report the throughput and latencies of getting and setting them
+//	dump-state      describe the in-memory state of a database and its files as JSON, for bug reports
+//	export          copy all key-value pairs of a database into a bbolt or Badger database, or a file
+//	import          copy all key-value pairs of a bbolt or Badger database, or a file, into a database
+//	import-redis    load the string keys of a Redis RDB or AOF file into a database
+//	keys            list the keys of a database that match a regular expression
+//	migrate         convert a database to another format version
+//	serve           serve a database over HTTP
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+const (
+	defaultMaxFileSizeKB     = 4 * 1024
+	defaultVacuumIntervalSec = 5 * 60
+)
+
+var ErrUnknownCommand = errors.New("unknown command")
+
+// command is a subcommand of the ckydb tool
+type command struct {
+	// usage is the one-line description of the command
+	usage string
+	// run runs the command with the arguments following its name, writing its output to stdout
+	run func(args []string, stdout io.Writer) error
+}
+
+var commands = map[string]command{
+	"bench": {
+		usage: "load a database with keys and report the throughput and latencies of getting and setting them",
+		run:   bench,
+	},
+	"dump-state": {
+		usage: "describe the in-memory state of a database and its files as JSON, for bug reports",
+		run:   dumpState,
+	},
+	"export": {
+		usage: "copy all key-value pairs of a database into a bbolt or Badger database, or a file",
+		run:   exportStore,
+	},
+	"import": {
+		usage: "copy all key-value pairs of a bbolt or Badger database, or a file, into a database",
+		run:   importStore,
+	},
+	"import-redis": {
+		usage: "load the string keys of a Redis RDB or AOF file into a database",
+		run:   importRedis,
+	},
+	"keys": {
+		usage: "list the keys of a database that match a regular expression",
+		run:   listKeys,
+	},
+	"migrate": {
+		usage: "convert a database to another format version",
+		run:   migrateFormat,
+	},
+	"serve": {
+		usage: "serve a database over HTTP",
+		run:   serve,
+	},
+}
+
+func main() {
+	err := run(os.Args[1:], os.Stdout)
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+// run runs the command named by the first of the args
+func run(args []string, stdout io.Writer) error {
+	if len(args) == 0 {
+		printUsage(stdout)
+		return nil
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		printUsage(stdout)
+		return fmt.Errorf("%w: %s", ErrUnknownCommand, args[0])
+	}
+
+	return cmd.run(args[1:], stdout)
+}
+
+// printUsage writes the list of commands to w
+func printUsage(w io.Writer) {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	_, _ = fmt.Fprintf(w, "Usage:\n\n\tckydb <command> [flags] [args]\n\nThe commands are:\n\n")
+	for _, name := range names {
+		_, _ = fmt.Fprintf(w, "\t%-15s %s\n", name, commands[name].usage)
+	}
+}
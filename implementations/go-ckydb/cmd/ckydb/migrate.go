@@ -0,0 +1,163 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/sopherapps/ckydb/implementations/go-ckydb"
+	"github.com/sopherapps/ckydb/implementations/go-ckydb/codec"
+	"github.com/sopherapps/ckydb/implementations/go-ckydb/migrate"
+)
+
+var ErrUnknownFormat = errors.New("unknown format")
+
+// importStore copies all key-value pairs of the bbolt or Badger database, or of the file written by export
+// with a codec, given as the only argument into a database
+func importStore(args []string, stdout io.Writer) error {
+	flags, opts := newMigrateFlagSet("import", "<source>", stdout)
+	policyName := flags.String("policy", "overwrite", "what to do with keys that already exist: overwrite, skip-existing or fail-on-conflict")
+	err := opts.parse(flags, args)
+	if err != nil {
+		return err
+	}
+
+	policy, err := parseImportPolicy(*policyName)
+	if err != nil {
+		return err
+	}
+
+	db, err := ckydb.Connect(opts.dbPath, opts.maxFileSizeKB, defaultVacuumIntervalSec)
+	if err != nil {
+		return err
+	}
+
+	var result ckydb.ImportResult
+	switch opts.format {
+	case "bolt":
+		result, err = migrate.ImportBolt(db, flags.Arg(0), opts.bucket, policy)
+	case "badger":
+		result, err = migrate.ImportBadger(db, flags.Arg(0), policy)
+	default:
+		result, err = importFile(db, flags.Arg(0), opts.codec, policy)
+	}
+	err = errors.Join(err, db.Close())
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(stdout, "inserted: %d, skipped: %d, overwritten: %d\n", result.Inserted, result.Skipped, result.Overwritten)
+	return err
+}
+
+// exportStore copies all key-value pairs of a database into the bbolt or Badger database, or into the file
+// encoded with a codec, given as the only argument
+func exportStore(args []string, stdout io.Writer) error {
+	flags, opts := newMigrateFlagSet("export", "<destination>", stdout)
+	err := opts.parse(flags, args)
+	if err != nil {
+		return err
+	}
+
+	db, err := ckydb.Connect(opts.dbPath, opts.maxFileSizeKB, defaultVacuumIntervalSec)
+	if err != nil {
+		return err
+	}
+
+	var count int
+	switch opts.format {
+	case "bolt":
+		count, err = migrate.ExportBolt(db, flags.Arg(0), opts.bucket)
+	case "badger":
+		count, err = migrate.ExportBadger(db, flags.Arg(0))
+	default:
+		count, err = exportFile(db, flags.Arg(0), opts.codec)
+	}
+	err = errors.Join(err, db.Close())
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(stdout, "exported: %d\n", count)
+	return err
+}
+
+// importFile sets the key-value pairs of the file at path, encoded with the codec, in the database
+func importFile(db *ckydb.Ckydb, path string, c codec.Codec, policy ckydb.ImportPolicy) (ckydb.ImportResult, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return ckydb.ImportResult{}, err
+	}
+	defer func() { _ = f.Close() }()
+
+	return db.ImportWith(f, c, policy)
+}
+
+// exportFile writes all key-value pairs of the database to the file at path, encoded with the codec,
+// returning their number. The command holds the only connection to the database so the number of keys
+// does not change during the export
+func exportFile(db *ckydb.Ckydb, path string, c codec.Codec) (int, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+
+	err = db.ExportWith(f, c)
+	err = errors.Join(err, f.Close())
+	if err != nil {
+		return 0, err
+	}
+
+	return db.Stats().Keys, nil
+}
+
+// migrateOptions are the flags shared by the import and export commands
+type migrateOptions struct {
+	dbPath        string
+	maxFileSizeKB float64
+	format        string
+	bucket        string
+	// codec is the codec named by the format, if it is not bolt or badger
+	codec codec.Codec
+}
+
+// newMigrateFlagSet creates the flag set of the import or export command, whose only argument is described by arg
+func newMigrateFlagSet(name string, arg string, stdout io.Writer) (*flag.FlagSet, *migrateOptions) {
+	opts := &migrateOptions{}
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(stdout)
+	flags.Usage = func() {
+		_, _ = fmt.Fprintf(stdout, "Usage:\n\n\tckydb %s -db <path> -format <bolt|badger|codec> [flags] %s\n\nThe flags are:\n\n", name, arg)
+		flags.PrintDefaults()
+	}
+	flags.StringVar(&opts.dbPath, "db", "", "path to the ckydb database folder, created if it does not exist")
+	flags.Float64Var(&opts.maxFileSizeKB, "max-file-size-kb", defaultMaxFileSizeKB, "size in kilobytes beyond which the log file is rolled")
+	flags.StringVar(&opts.format, "format", "", "format of the other database: bolt, badger, or a codec for a file e.g. json, gob, msgpack or protobuf")
+	flags.StringVar(&opts.bucket, "bucket", "kv", "bucket of the bolt database holding the key-value pairs")
+
+	return flags, opts
+}
+
+// parse parses the args into the options, checking that the required flags and the argument are given
+func (o *migrateOptions) parse(flags *flag.FlagSet, args []string) error {
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if o.dbPath == "" || flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf("expected -db and the path to the other database")
+	}
+
+	if o.format != "bolt" && o.format != "badger" {
+		o.codec, err = codec.Get(o.format)
+		if err != nil {
+			return fmt.Errorf("%w: %q", ErrUnknownFormat, o.format)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/sopherapps/ckydb/implementations/go-ckydb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrate(t *testing.T) {
+	for _, format := range []string{"bolt", "badger", "json", "gob", "msgpack", "protobuf"} {
+		t.Run("ExportThenImportShouldCopyAllKeyValuesVia"+format, func(t *testing.T) {
+			dir := t.TempDir()
+			sourcePath := filepath.Join(dir, "source")
+			targetPath := filepath.Join(dir, "target")
+			otherPath := filepath.Join(dir, format)
+
+			db, err := ckydb.Connect(sourcePath, defaultMaxFileSizeKB, defaultVacuumIntervalSec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = db.Set("foo", "bar")
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = db.Close()
+
+			var stdout bytes.Buffer
+			err = run([]string{"export", "-db", sourcePath, "-format", format, otherPath}, &stdout)
+			assert.Nil(t, err)
+			assert.Equal(t, "exported: 1\n", stdout.String())
+
+			stdout.Reset()
+			err = run([]string{"import", "-db", targetPath, "-format", format, otherPath}, &stdout)
+			assert.Nil(t, err)
+			assert.Equal(t, "inserted: 1, skipped: 0, overwritten: 0\n", stdout.String())
+
+			db, err = ckydb.Connect(targetPath, defaultMaxFileSizeKB, defaultVacuumIntervalSec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() { _ = db.Close() }()
+			value, err := db.Get("foo")
+			assert.Nil(t, err)
+			assert.Equal(t, "bar", value)
+		})
+	}
+
+	t.Run("ImportShouldFailOnUnknownFormats", func(t *testing.T) {
+		var stdout bytes.Buffer
+		err := run([]string{"import", "-db", t.TempDir(), "-format", "sqlite", "file"}, &stdout)
+		assert.ErrorIs(t, err, ErrUnknownFormat)
+	})
+}
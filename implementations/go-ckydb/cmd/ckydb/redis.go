@@ -0,0 +1,490 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sopherapps/ckydb/implementations/go-ckydb"
+)
+
+const rdbMagic = "REDIS"
+
+// RDB opcodes and value types, as defined in Redis' rdb.h
+const (
+	rdbTypeString      = 0
+	rdbOpcodeSlotInfo  = 0xF4
+	rdbOpcodeIdle      = 0xF8
+	rdbOpcodeFreq      = 0xF9
+	rdbOpcodeAux       = 0xFA
+	rdbOpcodeResizeDB  = 0xFB
+	rdbOpcodeExpireMs  = 0xFC
+	rdbOpcodeExpireSec = 0xFD
+	rdbOpcodeSelectDB  = 0xFE
+	rdbOpcodeEOF       = 0xFF
+	rdbEncodingInt8    = 0
+	rdbEncodingInt16   = 1
+	rdbEncodingInt32   = 2
+	rdbEncodingLZF     = 3
+	rdbLength6Bit      = 0
+	rdbLength14Bit     = 1
+	rdbLength32Or64Bit = 2
+)
+
+var (
+	ErrUnsupportedRedisType = errors.New("unsupported redis type")
+	ErrInvalidRedisDump     = errors.New("invalid redis dump")
+)
+
+// importRedis loads the string keys of the Redis RDB or AOF file given as the only argument into a database
+func importRedis(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("import-redis", flag.ContinueOnError)
+	flags.SetOutput(stdout)
+	flags.Usage = func() {
+		_, _ = fmt.Fprintf(stdout, "Usage:\n\n\tckydb import-redis -db <path> [flags] <dump.rdb|appendonly.aof>\n\nThe flags are:\n\n")
+		flags.PrintDefaults()
+	}
+	dbPath := flags.String("db", "", "path to the ckydb database folder, created if it does not exist")
+	maxFileSizeKB := flags.Float64("max-file-size-kb", defaultMaxFileSizeKB, "size in kilobytes beyond which the log file is rolled")
+	policyName := flags.String("policy", "overwrite", "what to do with keys that already exist: overwrite, skip-existing or fail-on-conflict")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if *dbPath == "" || flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf("%w: expected -db and the path to a redis dump", ErrInvalidRedisDump)
+	}
+
+	policy, err := parseImportPolicy(*policyName)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+
+	data, err := readRedisDump(file, time.Now())
+	if err != nil {
+		return err
+	}
+
+	db, err := ckydb.Connect(*dbPath, *maxFileSizeKB, defaultVacuumIntervalSec)
+	if err != nil {
+		return err
+	}
+
+	result, err := db.Import(data, policy)
+	err = errors.Join(err, db.Close())
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(stdout, "inserted: %d, skipped: %d, overwritten: %d\n", result.Inserted, result.Skipped, result.Overwritten)
+	return err
+}
+
+// parseImportPolicy returns the import policy of the given name
+func parseImportPolicy(name string) (ckydb.ImportPolicy, error) {
+	switch name {
+	case "overwrite":
+		return ckydb.Overwrite, nil
+	case "skip-existing":
+		return ckydb.SkipExisting, nil
+	case "fail-on-conflict":
+		return ckydb.FailOnConflict, nil
+	default:
+		return 0, fmt.Errorf("unknown policy: %s", name)
+	}
+}
+
+// readRedisDump reads the string keys in the Redis RDB or AOF file read from r,
+// telling the two apart by the magic string at the start of RDB files.
+// Keys that had expired by now are left out
+func readRedisDump(r io.Reader, now time.Time) (map[string]string, error) {
+	reader := bufio.NewReader(r)
+	magic, err := reader.Peek(len(rdbMagic))
+	if err == nil && string(magic) == rdbMagic {
+		return readRDB(reader, now)
+	}
+
+	return readAOF(reader)
+}
+
+// readRDB reads the string keys in the Redis RDB file read from r. The keys of all databases
+// are merged, and it returns an ErrUnsupportedRedisType error if a value is not a string
+func readRDB(r *bufio.Reader, now time.Time) (map[string]string, error) {
+	header := make([]byte, len(rdbMagic)+4)
+	_, err := io.ReadFull(r, header)
+	if err != nil || string(header[:len(rdbMagic)]) != rdbMagic {
+		return nil, fmt.Errorf("%w: bad rdb header", ErrInvalidRedisDump)
+	}
+
+	data := map[string]string{}
+	var expiresAt *time.Time
+	for {
+		opcode, err := r.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidRedisDump, err)
+		}
+
+		switch opcode {
+		case rdbOpcodeEOF:
+			return data, nil
+
+		case rdbOpcodeSelectDB:
+			_, err = readRDBLength(r)
+
+		case rdbOpcodeResizeDB:
+			_, err = readRDBLength(r)
+			if err == nil {
+				_, err = readRDBLength(r)
+			}
+
+		case rdbOpcodeSlotInfo:
+			for i := 0; i < 3 && err == nil; i++ {
+				_, err = readRDBLength(r)
+			}
+
+		case rdbOpcodeAux:
+			_, err = readRDBString(r)
+			if err == nil {
+				_, err = readRDBString(r)
+			}
+
+		case rdbOpcodeIdle:
+			_, err = readRDBLength(r)
+
+		case rdbOpcodeFreq:
+			_, err = r.ReadByte()
+
+		case rdbOpcodeExpireSec:
+			var seconds uint32
+			err = binary.Read(r, binary.LittleEndian, &seconds)
+			at := time.Unix(int64(seconds), 0)
+			expiresAt = &at
+
+		case rdbOpcodeExpireMs:
+			var milliseconds uint64
+			err = binary.Read(r, binary.LittleEndian, &milliseconds)
+			at := time.UnixMilli(int64(milliseconds))
+			expiresAt = &at
+
+		case rdbTypeString:
+			var key, value string
+			key, err = readRDBString(r)
+			if err == nil {
+				value, err = readRDBString(r)
+			}
+
+			if err == nil && (expiresAt == nil || expiresAt.After(now)) {
+				data[key] = value
+			}
+			expiresAt = nil
+
+		default:
+			return nil, fmt.Errorf("%w: %d", ErrUnsupportedRedisType, opcode)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidRedisDump, err)
+		}
+	}
+}
+
+// readRDBLength reads a length-encoded integer from r. Special encodings are not lengths
+// so they are an error here
+func readRDBLength(r *bufio.Reader) (uint64, error) {
+	length, isEncoded, err := readRDBLengthOrEncoding(r)
+	if err != nil {
+		return 0, err
+	}
+
+	if isEncoded {
+		return 0, fmt.Errorf("unexpected string encoding %d", length)
+	}
+
+	return length, nil
+}
+
+// readRDBLengthOrEncoding reads a length-encoded integer from r, or if isEncoded is true,
+// the type of the special encoding of the string that follows
+func readRDBLengthOrEncoding(r *bufio.Reader) (length uint64, isEncoded bool, err error) {
+	first, err := r.ReadByte()
+	if err != nil {
+		return 0, false, err
+	}
+
+	switch first >> 6 {
+	case rdbLength6Bit:
+		return uint64(first & 0x3F), false, nil
+
+	case rdbLength14Bit:
+		next, err := r.ReadByte()
+		if err != nil {
+			return 0, false, err
+		}
+		return uint64(first&0x3F)<<8 | uint64(next), false, nil
+
+	case rdbLength32Or64Bit:
+		if first == 0x80 {
+			var value uint32
+			err = binary.Read(r, binary.BigEndian, &value)
+			return uint64(value), false, err
+		}
+
+		if first == 0x81 {
+			var value uint64
+			err = binary.Read(r, binary.BigEndian, &value)
+			return value, false, err
+		}
+
+		return 0, false, fmt.Errorf("unknown length encoding %d", first)
+
+	default:
+		return uint64(first & 0x3F), true, nil
+	}
+}
+
+// readRDBString reads a string from r, be it length-prefixed, an integer or LZF-compressed
+func readRDBString(r *bufio.Reader) (string, error) {
+	length, isEncoded, err := readRDBLengthOrEncoding(r)
+	if err != nil {
+		return "", err
+	}
+
+	if !isEncoded {
+		return readRDBBytes(r, length)
+	}
+
+	switch length {
+	case rdbEncodingInt8:
+		var value int8
+		err = binary.Read(r, binary.LittleEndian, &value)
+		return strconv.FormatInt(int64(value), 10), err
+
+	case rdbEncodingInt16:
+		var value int16
+		err = binary.Read(r, binary.LittleEndian, &value)
+		return strconv.FormatInt(int64(value), 10), err
+
+	case rdbEncodingInt32:
+		var value int32
+		err = binary.Read(r, binary.LittleEndian, &value)
+		return strconv.FormatInt(int64(value), 10), err
+
+	case rdbEncodingLZF:
+		compressedLength, err := readRDBLength(r)
+		if err != nil {
+			return "", err
+		}
+
+		uncompressedLength, err := readRDBLength(r)
+		if err != nil {
+			return "", err
+		}
+
+		compressed, err := readRDBBytes(r, compressedLength)
+		if err != nil {
+			return "", err
+		}
+
+		return decompressLZF([]byte(compressed), int(uncompressedLength))
+
+	default:
+		return "", fmt.Errorf("unknown string encoding %d", length)
+	}
+}
+
+// readRDBBytes reads exactly length bytes from r
+func readRDBBytes(r *bufio.Reader, length uint64) (string, error) {
+	var buf strings.Builder
+	_, err := io.CopyN(&buf, r, int64(length))
+	if err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+// decompressLZF decompresses data compressed with the LZF algorithm used by Redis to
+// the given uncompressed length
+func decompressLZF(in []byte, length int) (string, error) {
+	out := make([]byte, 0, length)
+	for i := 0; i < len(in); {
+		ctrl := int(in[i])
+		i++
+
+		// a literal run of ctrl + 1 bytes
+		if ctrl < 32 {
+			end := i + ctrl + 1
+			if end > len(in) {
+				return "", errors.New("lzf literal run out of bounds")
+			}
+
+			out = append(out, in[i:end]...)
+			i = end
+			continue
+		}
+
+		// a back reference to runLength bytes that were already decompressed
+		runLength := ctrl >> 5
+		if runLength == 7 {
+			if i >= len(in) {
+				return "", errors.New("lzf back reference out of bounds")
+			}
+			runLength += int(in[i])
+			i++
+		}
+
+		if i >= len(in) {
+			return "", errors.New("lzf back reference out of bounds")
+		}
+		ref := len(out) - (ctrl&0x1F)<<8 - int(in[i]) - 1
+		i++
+		if ref < 0 {
+			return "", errors.New("lzf back reference out of bounds")
+		}
+
+		// the run may overlap the bytes it is appending so it is copied one byte at a time
+		for j := 0; j < runLength+2; j++ {
+			out = append(out, out[ref+j])
+		}
+	}
+
+	if len(out) != length {
+		return "", fmt.Errorf("lzf data decompressed to %d bytes instead of %d", len(out), length)
+	}
+
+	return string(out), nil
+}
+
+// readAOF replays the commands in the Redis AOF file read from r that change string keys
+// i.e. SET, SETNX, MSET, APPEND, DEL, UNLINK, FLUSHDB and FLUSHALL. The keys of all databases
+// are merged and every other command is ignored, keys being kept even if they are expired
+func readAOF(r *bufio.Reader) (map[string]string, error) {
+	data := map[string]string{}
+	for {
+		args, err := readRESPArray(r)
+		if err == io.EOF {
+			return data, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidRedisDump, err)
+		}
+
+		if len(args) == 0 {
+			continue
+		}
+
+		switch strings.ToUpper(args[0]) {
+		case "SET":
+			if len(args) >= 3 {
+				data[args[1]] = args[2]
+			}
+
+		case "SETNX":
+			if len(args) == 3 {
+				if _, ok := data[args[1]]; !ok {
+					data[args[1]] = args[2]
+				}
+			}
+
+		case "MSET":
+			for i := 1; i+1 < len(args); i += 2 {
+				data[args[i]] = args[i+1]
+			}
+
+		case "APPEND":
+			if len(args) == 3 {
+				data[args[1]] += args[2]
+			}
+
+		case "DEL", "UNLINK":
+			for _, key := range args[1:] {
+				delete(data, key)
+			}
+
+		case "FLUSHDB", "FLUSHALL":
+			data = map[string]string{}
+		}
+	}
+}
+
+// readRESPArray reads a RESP array of bulk strings, as written to AOF files, from r.
+// It returns io.EOF if r has no more data
+func readRESPArray(r *bufio.Reader) ([]string, error) {
+	header, err := readRESPLine(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.HasPrefix(header, "*") {
+		return nil, fmt.Errorf("expected an array, got %q", header)
+	}
+
+	count, err := strconv.Atoi(header[1:])
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		line, err := readRESPLine(r)
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+
+		if !strings.HasPrefix(line, "$") {
+			return nil, fmt.Errorf("expected a bulk string, got %q", line)
+		}
+
+		length, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+
+		buf := make([]byte, length+2)
+		_, err = io.ReadFull(r, buf)
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+
+		args = append(args, string(buf[:length]))
+	}
+
+	return args, nil
+}
+
+// readRESPLine reads a line terminated by "\r\n" from r, without the terminator
+func readRESPLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err == io.EOF && line != "" {
+		return "", io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSuffix(line, "\r\n"), nil
+}
+
+// unexpectedEOF converts io.EOF to io.ErrUnexpectedEOF, for reads that stop midway through a command
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+
+	return err
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sopherapps/ckydb/implementations/go-ckydb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImportRedis(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("ReadRedisDumpShouldReadStringKeysFromRDB", func(t *testing.T) {
+		got, err := readRedisDump(bytes.NewReader(buildTestRDB(now)), now)
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]string{
+			"foo":         "bar",
+			"small-int":   "123",
+			"int16":       "-300",
+			"int32":       "70000",
+			"compressed":  "aaaaaaaaaa",
+			"not-expired": "value",
+			"other-db":    "value",
+		}, got)
+	})
+
+	t.Run("ReadRedisDumpShouldFailOnNonStringRDBValues", func(t *testing.T) {
+		var rdb bytes.Buffer
+		rdb.WriteString("REDIS0009")
+		rdb.WriteByte(4) // a hash
+		writeRDBString(&rdb, "hash")
+
+		_, err := readRedisDump(&rdb, now)
+		assert.ErrorIs(t, err, ErrUnsupportedRedisType)
+	})
+
+	t.Run("ReadRedisDumpShouldFailOnTruncatedRDB", func(t *testing.T) {
+		rdb := buildTestRDB(now)
+		_, err := readRedisDump(bytes.NewReader(rdb[:len(rdb)-12]), now)
+		assert.ErrorIs(t, err, ErrInvalidRedisDump)
+	})
+
+	t.Run("ReadRedisDumpShouldReplayAOF", func(t *testing.T) {
+		aof := buildTestAOF(
+			[]string{"SELECT", "0"},
+			[]string{"SET", "foo", "bar"},
+			[]string{"set", "gone", "value", "EX", "10"},
+			[]string{"MSET", "a", "1", "b", "2"},
+			[]string{"APPEND", "a", "1"},
+			[]string{"SETNX", "b", "3"},
+			[]string{"DEL", "gone", "non-existent"},
+			[]string{"HSET", "hash", "field", "value"},
+			[]string{"SET", "multi\r\nline", "value\r\n"},
+		)
+
+		got, err := readRedisDump(strings.NewReader(aof), now)
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]string{"foo": "bar", "a": "11", "b": "2", "multi\r\nline": "value\r\n"}, got)
+
+		aof = buildTestAOF([]string{"SET", "foo", "bar"}, []string{"FLUSHALL"}, []string{"SET", "hi", "there"})
+		got, err = readRedisDump(strings.NewReader(aof), now)
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]string{"hi": "there"}, got)
+	})
+
+	t.Run("ReadRedisDumpShouldFailOnTruncatedAOF", func(t *testing.T) {
+		aof := buildTestAOF([]string{"SET", "foo", "bar"})
+		_, err := readRedisDump(strings.NewReader(aof[:len(aof)-4]), now)
+		assert.ErrorIs(t, err, ErrInvalidRedisDump)
+	})
+
+	t.Run("ImportRedisShouldLoadTheKeysIntoTheDatabase", func(t *testing.T) {
+		dir := t.TempDir()
+		dbPath := filepath.Join(dir, "db")
+		dumpPath := filepath.Join(dir, "appendonly.aof")
+		aof := buildTestAOF([]string{"SET", "foo", "bar"}, []string{"SET", "hi", "there"})
+		err := os.WriteFile(dumpPath, []byte(aof), 0666)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var stdout bytes.Buffer
+		err = run([]string{"import-redis", "-db", dbPath, dumpPath}, &stdout)
+		assert.Nil(t, err)
+		assert.Equal(t, "inserted: 2, skipped: 0, overwritten: 0\n", stdout.String())
+
+		stdout.Reset()
+		err = run([]string{"import-redis", "-db", dbPath, "-policy", "skip-existing", dumpPath}, &stdout)
+		assert.Nil(t, err)
+		assert.Equal(t, "inserted: 0, skipped: 2, overwritten: 0\n", stdout.String())
+
+		err = run([]string{"import-redis", "-db", dbPath, "-policy", "fail-on-conflict", dumpPath}, &stdout)
+		assert.ErrorIs(t, err, ckydb.ErrConflict)
+
+		db, err := ckydb.Connect(dbPath, defaultMaxFileSizeKB, defaultVacuumIntervalSec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = db.Close() }()
+		value, err := db.Get("hi")
+		assert.Nil(t, err)
+		assert.Equal(t, "there", value)
+	})
+
+	t.Run("RunShouldFailOnUnknownCommands", func(t *testing.T) {
+		var stdout bytes.Buffer
+		err := run([]string{"unknown"}, &stdout)
+		assert.ErrorIs(t, err, ErrUnknownCommand)
+		assert.Contains(t, stdout.String(), "import-redis")
+	})
+}
+
+// buildTestRDB returns an RDB file with string keys in all the encodings, an expired key
+// and keys in two databases
+func buildTestRDB(now time.Time) []byte {
+	var rdb bytes.Buffer
+	rdb.WriteString("REDIS0009")
+	rdb.WriteByte(rdbOpcodeAux)
+	writeRDBString(&rdb, "redis-ver")
+	writeRDBString(&rdb, "7.0.0")
+	rdb.WriteByte(rdbOpcodeSelectDB)
+	rdb.WriteByte(0)
+	rdb.WriteByte(rdbOpcodeResizeDB)
+	rdb.Write([]byte{6, 2})
+
+	rdb.WriteByte(rdbTypeString)
+	writeRDBString(&rdb, "foo")
+	writeRDBString(&rdb, "bar")
+
+	rdb.WriteByte(rdbTypeString)
+	writeRDBString(&rdb, "small-int")
+	rdb.Write([]byte{0xC0 | rdbEncodingInt8, 123})
+
+	rdb.WriteByte(rdbTypeString)
+	writeRDBString(&rdb, "int16")
+	rdb.WriteByte(0xC0 | rdbEncodingInt16)
+	_ = binary.Write(&rdb, binary.LittleEndian, int16(-300))
+
+	rdb.WriteByte(rdbTypeString)
+	writeRDBString(&rdb, "int32")
+	rdb.WriteByte(0xC0 | rdbEncodingInt32)
+	_ = binary.Write(&rdb, binary.LittleEndian, int32(70000))
+
+	// "a" followed by a back reference to 9 more "a"s
+	rdb.WriteByte(rdbTypeString)
+	writeRDBString(&rdb, "compressed")
+	rdb.Write([]byte{0xC0 | rdbEncodingLZF, 5, 10, 0x00, 'a', 0xE0, 0x00, 0x00})
+
+	rdb.WriteByte(rdbOpcodeExpireMs)
+	_ = binary.Write(&rdb, binary.LittleEndian, uint64(now.Add(-time.Second).UnixMilli()))
+	rdb.WriteByte(rdbTypeString)
+	writeRDBString(&rdb, "expired")
+	writeRDBString(&rdb, "value")
+
+	rdb.WriteByte(rdbOpcodeExpireSec)
+	_ = binary.Write(&rdb, binary.LittleEndian, uint32(now.Add(time.Hour).Unix()))
+	rdb.WriteByte(rdbOpcodeFreq)
+	rdb.WriteByte(5)
+	rdb.WriteByte(rdbTypeString)
+	writeRDBString(&rdb, "not-expired")
+	writeRDBString(&rdb, "value")
+
+	rdb.WriteByte(rdbOpcodeSelectDB)
+	rdb.WriteByte(1)
+	rdb.WriteByte(rdbOpcodeIdle)
+	rdb.WriteByte(0x40 | 1)
+	rdb.WriteByte(0x2C)
+	rdb.WriteByte(rdbTypeString)
+	writeRDBString(&rdb, "other-db")
+	writeRDBString(&rdb, "value")
+
+	rdb.WriteByte(rdbOpcodeEOF)
+	rdb.Write(make([]byte, 8))
+	return rdb.Bytes()
+}
+
+// writeRDBString writes s, which must be shorter than 64 bytes, as a length-prefixed RDB string
+func writeRDBString(w *bytes.Buffer, s string) {
+	w.WriteByte(byte(len(s)))
+	w.WriteString(s)
+}
+
+// buildTestAOF returns an AOF file with the given commands
+func buildTestAOF(commands ...[]string) string {
+	var aof strings.Builder
+	for _, args := range commands {
+		aof.WriteString("*" + strconv.Itoa(len(args)) + "\r\n")
+		for _, arg := range args {
+			aof.WriteString("$" + strconv.Itoa(len(arg)) + "\r\n" + arg + "\r\n")
+		}
+	}
+
+	return aof.String()
+}
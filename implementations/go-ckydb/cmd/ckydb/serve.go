@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"github.com/sopherapps/ckydb/implementations/go-ckydb"
+	"github.com/sopherapps/ckydb/implementations/go-ckydb/codec"
+	"github.com/sopherapps/ckydb/implementations/go-ckydb/httpapi"
+)
+
+// serve serves a database over HTTP until the process is interrupted
+func serve(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	flags.SetOutput(stdout)
+	flags.Usage = func() {
+		_, _ = fmt.Fprintf(stdout, "Usage:\n\n\tckydb serve -db <path> [flags]\n\nThe flags are:\n\n")
+		flags.PrintDefaults()
+	}
+	dbPath := flags.String("db", "", "path to the ckydb database folder, created if it does not exist")
+	maxFileSizeKB := flags.Float64("max-file-size-kb", defaultMaxFileSizeKB, "size in kilobytes beyond which the log file is rolled")
+	addr := flags.String("addr", "127.0.0.1:6380", "TCP address to listen on")
+	token := flags.String("token", os.Getenv("CKYDB_TOKEN"), "bearer token required of clients, $CKYDB_TOKEN by default")
+	readOnlyToken := flags.String("read-only-token", os.Getenv("CKYDB_READ_ONLY_TOKEN"), "bearer token of clients that may only get keys, $CKYDB_READ_ONLY_TOKEN by default")
+	user := flags.String("user", "", "user whose basic auth password is required of clients")
+	password := flags.String("password", os.Getenv("CKYDB_PASSWORD"), "basic auth password of -user, $CKYDB_PASSWORD by default")
+	certFile := flags.String("tls-cert", "", "PEM file of the certificate of the server, enabling TLS")
+	keyFile := flags.String("tls-key", "", "PEM file of the private key of -tls-cert")
+	clientCAFile := flags.String("tls-client-ca", "", "PEM file of the certificate authorities that must have signed the certificates of clients")
+	codecName := flags.String("codec", "json", "codec of the commands and results of batches e.g. json, gob, msgpack or protobuf")
+	admin := flags.Bool("admin", false, "serve the admin page at /admin")
+	shards := flags.Int("shards", 1, "number of databases, in shard-<i> subfolders of -db, to spread keys across")
+	prefixSeparator := flags.String("partition-by-prefix", "", "with -shards, keep the keys with the same prefix up to this separator, e.g. a tenant ID, on the same shard")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if *dbPath == "" || flags.NArg() != 0 || *shards < 1 {
+		flags.Usage()
+		return fmt.Errorf("expected -db, a -shards of at least 1 and no arguments")
+	}
+
+	batchCodec, err := codec.Get(*codecName)
+	if err != nil {
+		return err
+	}
+
+	opts := []httpapi.Option{httpapi.WithCodec(batchCodec)}
+	if *token != "" {
+		opts = append(opts, httpapi.WithToken(*token))
+	}
+	if *readOnlyToken != "" {
+		opts = append(opts, httpapi.WithTokenACL(*readOnlyToken, httpapi.ACL{ReadOnly: true}))
+	}
+	if *user != "" {
+		opts = append(opts, httpapi.WithPassword(*user, *password))
+	}
+	if *certFile != "" || *keyFile != "" || *clientCAFile != "" {
+		opts = append(opts, httpapi.WithTLS(httpapi.TLSConfig{CertFile: *certFile, KeyFile: *keyFile, ClientCAFile: *clientCAFile}))
+	}
+
+	if *admin {
+		opts = append(opts, httpapi.WithAdminUI())
+	}
+
+	db, err := connectShards(*dbPath, *maxFileSizeKB, *shards, *prefixSeparator)
+	if err != nil {
+		return err
+	}
+
+	server, err := httpapi.NewServer(db, opts...)
+	if err != nil {
+		return errors.Join(err, db.Close())
+	}
+
+	listener, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return errors.Join(err, db.Close())
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		_ = listener.Close()
+	}()
+
+	_, _ = fmt.Fprintf(stdout, "serving %s on %s\n", *dbPath, listener.Addr())
+	err = server.Serve(listener)
+	if ctx.Err() != nil {
+		err = nil
+	}
+
+	return errors.Join(err, db.Close())
+}
+
+// connectShards connects to the database at dbPath or, if there is more than one shard, to the databases in
+// its shard-<i> subfolders behind a ring, partitioning keys by their prefix if prefixSeparator is set
+func connectShards(dbPath string, maxFileSizeKB float64, shards int, prefixSeparator string) (ckydb.Controller, error) {
+	if shards == 1 {
+		return ckydb.Connect(dbPath, maxFileSizeKB, defaultVacuumIntervalSec)
+	}
+
+	nodes := make([]ckydb.Controller, shards)
+	for i := range nodes {
+		db, err := ckydb.Connect(filepath.Join(dbPath, fmt.Sprintf("shard-%d", i)), maxFileSizeKB, defaultVacuumIntervalSec)
+		if err != nil {
+			for _, node := range nodes[:i] {
+				err = errors.Join(err, node.Close())
+			}
+			return nil, err
+		}
+		nodes[i] = db
+	}
+
+	var opts []ckydb.RingOption
+	if prefixSeparator != "" {
+		opts = append(opts, ckydb.WithPartitioner(&ckydb.PrefixPartitioner{Separator: prefixSeparator}))
+	}
+
+	return ckydb.NewRing(nodes, opts...)
+}
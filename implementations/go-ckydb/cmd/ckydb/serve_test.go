@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sopherapps/ckydb/implementations/go-ckydb"
+	"github.com/sopherapps/ckydb/implementations/go-ckydb/httpapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServe(t *testing.T) {
+	t.Run("ServeShouldRequireTheDatabasePath", func(t *testing.T) {
+		var stdout bytes.Buffer
+		err := run([]string{"serve", "-addr", "127.0.0.1:0"}, &stdout)
+		assert.NotNil(t, err)
+		assert.Contains(t, stdout.String(), "ckydb serve -db <path>")
+	})
+
+	t.Run("ServeShouldFailOnInvalidTLSFiles", func(t *testing.T) {
+		dir := t.TempDir()
+		var stdout bytes.Buffer
+		args := []string{"serve", "-db", filepath.Join(dir, "db"), "-addr", "127.0.0.1:0", "-tls-cert", filepath.Join(dir, "cert.pem"), "-tls-key", filepath.Join(dir, "key.pem")}
+		err := run(args, &stdout)
+		assert.ErrorIs(t, err, httpapi.ErrInvalidTLSConfig)
+	})
+
+	t.Run("ServeShouldRequireAtLeastOneShard", func(t *testing.T) {
+		var stdout bytes.Buffer
+		err := run([]string{"serve", "-db", filepath.Join(t.TempDir(), "db"), "-shards", "0"}, &stdout)
+		assert.NotNil(t, err)
+		assert.Contains(t, stdout.String(), "ckydb serve -db <path>")
+	})
+
+	t.Run("ConnectShardsShouldKeepKeysWithTheSamePrefixOnTheSameShard", func(t *testing.T) {
+		dbPath := filepath.Join(t.TempDir(), "db")
+		db, err := connectShards(dbPath, defaultMaxFileSizeKB, 3, ":")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = db.Close() }()
+
+		ring := db.(*ckydb.Ring)
+		for i := 0; i < 20; i++ {
+			err = ring.Set(fmt.Sprintf("tenant%d:a", i), "value")
+			assert.Nil(t, err)
+			err = ring.Set(fmt.Sprintf("tenant%d:b", i), "value")
+			assert.Nil(t, err)
+			assert.Equal(t, ring.NodeFor(fmt.Sprintf("tenant%d:a", i)), ring.NodeFor(fmt.Sprintf("tenant%d:b", i)))
+		}
+
+		for i := 0; i < 3; i++ {
+			_, err = os.Stat(filepath.Join(dbPath, fmt.Sprintf("shard-%d", i)))
+			assert.Nil(t, err)
+		}
+	})
+}
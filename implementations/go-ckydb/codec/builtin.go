@@ -0,0 +1,102 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// The codecs registered by default
+var (
+	// JSON encodes values with encoding/json
+	JSON Codec = jsonCodec{}
+	// Gob encodes values with encoding/gob
+	Gob Codec = gobCodec{}
+	// Msgpack encodes values in the MessagePack format, see msgpack.go
+	Msgpack Codec = msgpackCodec{}
+	// Protobuf encodes proto.Message values in the protobuf wire format. Other values are encoded as
+	// a google.protobuf.Value holding their JSON form, so numbers in them are held as doubles
+	Protobuf Codec = protobufCodec{}
+)
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string        { return "json" }
+func (jsonCodec) ContentType() string { return "application/json" }
+func (jsonCodec) Binary() bool        { return false }
+
+func (jsonCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+type gobCodec struct{}
+
+func (gobCodec) Name() string        { return "gob" }
+func (gobCodec) ContentType() string { return "application/x-gob" }
+func (gobCodec) Binary() bool        { return true }
+
+func (gobCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+type protobufCodec struct{}
+
+func (protobufCodec) Name() string        { return "protobuf" }
+func (protobufCodec) ContentType() string { return "application/x-protobuf" }
+func (protobufCodec) Binary() bool        { return true }
+
+func (protobufCodec) Marshal(v any) ([]byte, error) {
+	if m, ok := v.(proto.Message); ok {
+		return proto.Marshal(m)
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	value := &structpb.Value{}
+	err = protojson.Unmarshal(data, value)
+	if err != nil {
+		return nil, err
+	}
+
+	return proto.Marshal(value)
+}
+
+func (protobufCodec) Unmarshal(data []byte, v any) error {
+	if m, ok := v.(proto.Message); ok {
+		return proto.Unmarshal(data, m)
+	}
+
+	value := &structpb.Value{}
+	err := proto.Unmarshal(data, value)
+	if err != nil {
+		return err
+	}
+
+	data, err = protojson.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, v)
+}
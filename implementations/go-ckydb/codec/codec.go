@@ -0,0 +1,109 @@
+// Package codec holds the registry of the codecs that encode typed values into ckydb values, so that the same
+// codec choice applies to the Typed wrapper, export and import, and the batch endpoint of the HTTP API.
+//
+// JSON, gob, msgpack and protobuf are registered by default, under the names "json", "gob", "msgpack" and
+// "protobuf". Other codecs, or other implementations of these, can be registered with Register.
+package codec
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+var (
+	ErrUnknownCodec    = errors.New("unknown codec")
+	ErrUnsupportedType = errors.New("unsupported type")
+)
+
+// Codec encodes values into bytes and decodes them back
+type Codec interface {
+	// Name is the name the codec is registered under
+	Name() string
+	// ContentType is the media type of the encoded values, as sent in HTTP headers
+	ContentType() string
+	// Binary tells whether the encoded values may not be valid text, in which case they are
+	// base64-encoded when stored as ckydb values
+	Binary() bool
+	// Marshal encodes v
+	Marshal(v any) ([]byte, error)
+	// Unmarshal decodes data into the value pointed to by v
+	Unmarshal(data []byte, v any) error
+}
+
+var (
+	registryLock sync.RWMutex
+	registry     = map[string]Codec{}
+)
+
+func init() {
+	for _, c := range []Codec{JSON, Gob, Msgpack, Protobuf} {
+		Register(c)
+	}
+}
+
+// Register adds the codec to the registry under its name, replacing any codec registered under the same name
+func Register(c Codec) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+
+	registry[c.Name()] = c
+}
+
+// Get returns the codec registered under the given name, or an ErrUnknownCodec error if there is none
+func Get(name string) (Codec, error) {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+
+	c, ok := registry[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownCodec, name)
+	}
+
+	return c, nil
+}
+
+// Names returns the names of the registered codecs in ascending order
+func Names() []string {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// EncodeString encodes v with the codec into a string to store as a ckydb value, base64-encoding
+// the output of binary codecs
+func EncodeString(c Codec, v any) (string, error) {
+	data, err := c.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+
+	if c.Binary() {
+		return base64.StdEncoding.EncodeToString(data), nil
+	}
+
+	return string(data), nil
+}
+
+// DecodeString decodes the string, as encoded by EncodeString with the same codec, into the value pointed to by v
+func DecodeString(c Codec, s string, v any) error {
+	data := []byte(s)
+	if c.Binary() {
+		var err error
+		data, err = base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return err
+		}
+	}
+
+	return c.Unmarshal(data, v)
+}
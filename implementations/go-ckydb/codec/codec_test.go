@@ -0,0 +1,167 @@
+package codec
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type animal struct {
+	Name   string
+	Age    int
+	Weight float64
+	Tags   []string
+	Owners map[string]bool `msgpack:"owners"`
+	secret string
+}
+
+func TestCodecs(t *testing.T) {
+	cow := animal{Name: "cow", Age: 500, Weight: 450.5, Tags: []string{"farm", "milk"}, Owners: map[string]bool{"ann": true}}
+
+	t.Run("BuiltInCodecsShouldBeRegistered", func(t *testing.T) {
+		assert.Equal(t, []string{"gob", "json", "msgpack", "protobuf"}, Names())
+		for _, name := range Names() {
+			c, err := Get(name)
+			assert.Nil(t, err)
+			assert.Equal(t, name, c.Name())
+		}
+
+		_, err := Get("yaml")
+		assert.ErrorIs(t, err, ErrUnknownCodec)
+	})
+
+	t.Run("EveryCodecShouldRoundTripStructs", func(t *testing.T) {
+		for _, c := range []Codec{JSON, Gob, Msgpack, Protobuf} {
+			encoded, err := EncodeString(c, cow)
+			assert.Nil(t, err, c.Name())
+
+			var decoded animal
+			err = DecodeString(c, encoded, &decoded)
+			assert.Nil(t, err, c.Name())
+			assert.Equal(t, cow, decoded, c.Name())
+		}
+	})
+
+	t.Run("EveryCodecShouldRoundTripStringMaps", func(t *testing.T) {
+		data := map[string]string{"cow": "500 months", "dog": "23 months", "": ""}
+		for _, c := range []Codec{JSON, Gob, Msgpack, Protobuf} {
+			encoded, err := c.Marshal(data)
+			assert.Nil(t, err, c.Name())
+
+			decoded := map[string]string{}
+			err = c.Unmarshal(encoded, &decoded)
+			assert.Nil(t, err, c.Name())
+			assert.Equal(t, data, decoded, c.Name())
+		}
+	})
+
+	t.Run("BinaryCodecsShouldBeStoredAsBase64", func(t *testing.T) {
+		encoded, err := EncodeString(JSON, cow)
+		assert.Nil(t, err)
+		assert.True(t, strings.HasPrefix(encoded, `{"Name":"cow"`))
+
+		encoded, err = EncodeString(Gob, cow)
+		assert.Nil(t, err)
+		assert.NotContains(t, encoded, "\x00")
+	})
+
+	t.Run("MsgpackShouldEncodeEachKindInItsShortestForm", func(t *testing.T) {
+		for _, tc := range []struct {
+			value    any
+			expected []byte
+		}{
+			{nil, []byte{0xc0}},
+			{true, []byte{0xc3}},
+			{7, []byte{0x07}},
+			{-3, []byte{0xfd}},
+			{200, []byte{0xcc, 0xc8}},
+			{-200, []byte{0xd1, 0xff, 0x38}},
+			{uint64(math.MaxUint64), []byte{0xcf, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+			{1.5, []byte{0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0}},
+			{"hi", []byte{0xa2, 'h', 'i'}},
+			{[]byte("hi"), []byte{0xc4, 0x02, 'h', 'i'}},
+			{[]int{1, 2}, []byte{0x92, 0x01, 0x02}},
+			{map[string]int{"b": 2, "a": 1}, []byte{0x82, 0xa1, 'a', 0x01, 0xa1, 'b', 0x02}},
+		} {
+			encoded, err := Msgpack.Marshal(tc.value)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expected, encoded, tc.value)
+		}
+	})
+
+	t.Run("MsgpackShouldDecodeIntoEmptyInterfaces", func(t *testing.T) {
+		encoded, err := Msgpack.Marshal(map[string]any{"n": -70000, "s": "x", "l": []any{true, nil}})
+		assert.Nil(t, err)
+
+		var decoded any
+		err = Msgpack.Unmarshal(encoded, &decoded)
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]any{"n": int64(-70000), "s": "x", "l": []any{true, nil}}, decoded)
+	})
+
+	t.Run("MsgpackShouldRejectMismatchedAndTruncatedData", func(t *testing.T) {
+		encoded, err := Msgpack.Marshal(cow)
+		assert.Nil(t, err)
+
+		var n int
+		err = Msgpack.Unmarshal(encoded, &n)
+		assert.ErrorIs(t, err, ErrUnsupportedType)
+
+		var decoded animal
+		err = Msgpack.Unmarshal(encoded[:len(encoded)-1], &decoded)
+		assert.NotNil(t, err)
+
+		var small int8
+		err = Msgpack.Unmarshal([]byte{0xcc, 0xc8}, &small)
+		assert.ErrorIs(t, err, ErrUnsupportedType)
+	})
+
+	t.Run("MsgpackShouldRejectValuesNestedTooDeeply", func(t *testing.T) {
+		for _, format := range []byte{0x91, 0x81} {
+			var decoded any
+			err := Msgpack.Unmarshal(bytes.Repeat([]byte{format}, 20_000_000), &decoded)
+			assert.ErrorContains(t, err, "nested more than")
+		}
+
+		nested := append(bytes.Repeat([]byte{0x91}, 100), 0xc0)
+		var decoded any
+		err := Msgpack.Unmarshal(nested, &decoded)
+		assert.Nil(t, err)
+	})
+
+	t.Run("ProtobufShouldEncodeMessagesInTheWireFormat", func(t *testing.T) {
+		encoded, err := Protobuf.Marshal(wrapperspb.String("cow"))
+		assert.Nil(t, err)
+		assert.Equal(t, []byte{0x0a, 0x03, 'c', 'o', 'w'}, encoded)
+
+		decoded := &wrapperspb.StringValue{}
+		err = Protobuf.Unmarshal(encoded, decoded)
+		assert.Nil(t, err)
+		assert.Equal(t, "cow", decoded.GetValue())
+	})
+
+	t.Run("RegisterShouldReplaceCodecsOfTheSameName", func(t *testing.T) {
+		defer Register(JSON)
+
+		Register(upperJSON{})
+		c, err := Get("json")
+		assert.Nil(t, err)
+		encoded, err := EncodeString(c, "cow")
+		assert.Nil(t, err)
+		assert.Equal(t, `"COW"`, encoded)
+	})
+}
+
+// upperJSON is a JSON codec that upper-cases what it encodes
+type upperJSON struct {
+	jsonCodec
+}
+
+func (upperJSON) Marshal(v any) ([]byte, error) {
+	data, err := JSON.Marshal(v)
+	return []byte(strings.ToUpper(string(data))), err
+}
@@ -0,0 +1,612 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+)
+
+// msgpackCodec encodes values in the MessagePack format. It handles nil, booleans, numbers, strings, byte slices,
+// slices, arrays, maps, pointers and structs, whose exported fields are encoded as a map keyed by the name in their
+// "msgpack" tag, or by their name if they have none. A field tagged "-" is skipped. Extension types are not supported
+type msgpackCodec struct{}
+
+func (msgpackCodec) Name() string        { return "msgpack" }
+func (msgpackCodec) ContentType() string { return "application/msgpack" }
+func (msgpackCodec) Binary() bool        { return true }
+
+func (msgpackCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	err := encodeMsgpack(&buf, reflect.ValueOf(v))
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (msgpackCodec) Unmarshal(data []byte, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("%w: msgpack needs a non-nil pointer, got %T", ErrUnsupportedType, v)
+	}
+
+	r := bytes.NewReader(data)
+	x, err := decodeMsgpack(r, 0)
+	if err != nil {
+		return err
+	}
+
+	if r.Len() != 0 {
+		return fmt.Errorf("msgpack: %d bytes left after the value", r.Len())
+	}
+
+	return assignMsgpack(rv.Elem(), x)
+}
+
+// msgpackPair is a key-value pair of a decoded msgpack map
+type msgpackPair struct {
+	key   any
+	value any
+}
+
+// msgpackField is an exported field of a struct
+type msgpackField struct {
+	name  string
+	index int
+}
+
+// encodeMsgpack writes v to buf in the msgpack format
+func encodeMsgpack(buf *bytes.Buffer, v reflect.Value) error {
+	if !v.IsValid() {
+		buf.WriteByte(0xc0)
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			buf.WriteByte(0xc0)
+			return nil
+		}
+		return encodeMsgpack(buf, v.Elem())
+	case reflect.Bool:
+		if v.Bool() {
+			buf.WriteByte(0xc3)
+		} else {
+			buf.WriteByte(0xc2)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		encodeMsgpackInt(buf, v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		encodeMsgpackUint(buf, v.Uint())
+	case reflect.Float32:
+		buf.WriteByte(0xca)
+		_ = binary.Write(buf, binary.BigEndian, math.Float32bits(float32(v.Float())))
+	case reflect.Float64:
+		buf.WriteByte(0xcb)
+		_ = binary.Write(buf, binary.BigEndian, math.Float64bits(v.Float()))
+	case reflect.String:
+		encodeMsgpackHeader(buf, v.Len(), 0xa0, 32, 0xd9, 0xda, 0xdb)
+		buf.WriteString(v.String())
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			buf.WriteByte(0xc0)
+			return nil
+		}
+
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			encodeMsgpackHeader(buf, v.Len(), 0, 0, 0xc4, 0xc5, 0xc6)
+			for i := 0; i < v.Len(); i++ {
+				buf.WriteByte(byte(v.Index(i).Uint()))
+			}
+			return nil
+		}
+
+		encodeMsgpackHeader(buf, v.Len(), 0x90, 16, 0, 0xdc, 0xdd)
+		for i := 0; i < v.Len(); i++ {
+			err := encodeMsgpack(buf, v.Index(i))
+			if err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if v.IsNil() {
+			buf.WriteByte(0xc0)
+			return nil
+		}
+
+		return encodeMsgpackMap(buf, v)
+	case reflect.Struct:
+		fields := getMsgpackFields(v.Type())
+		encodeMsgpackHeader(buf, len(fields), 0x80, 16, 0, 0xde, 0xdf)
+		for _, field := range fields {
+			encodeMsgpackHeader(buf, len(field.name), 0xa0, 32, 0xd9, 0xda, 0xdb)
+			buf.WriteString(field.name)
+			err := encodeMsgpack(buf, v.Field(field.index))
+			if err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("%w: msgpack cannot encode %s", ErrUnsupportedType, v.Type())
+	}
+
+	return nil
+}
+
+// encodeMsgpackMap writes the map to buf with its entries ordered by their encoded keys, so that equal maps
+// are always encoded the same way
+func encodeMsgpackMap(buf *bytes.Buffer, v reflect.Value) error {
+	type entry struct {
+		key   []byte
+		value reflect.Value
+	}
+
+	entries := make([]entry, 0, v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		var key bytes.Buffer
+		err := encodeMsgpack(&key, iter.Key())
+		if err != nil {
+			return err
+		}
+		entries = append(entries, entry{key: key.Bytes(), value: iter.Value()})
+	}
+	sort.Slice(entries, func(i, j int) bool { return bytes.Compare(entries[i].key, entries[j].key) < 0 })
+
+	encodeMsgpackHeader(buf, len(entries), 0x80, 16, 0, 0xde, 0xdf)
+	for _, e := range entries {
+		buf.Write(e.key)
+		err := encodeMsgpack(buf, e.value)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// encodeMsgpackInt writes the signed integer to buf in its shortest form
+func encodeMsgpackInt(buf *bytes.Buffer, n int64) {
+	switch {
+	case n >= 0:
+		encodeMsgpackUint(buf, uint64(n))
+	case n >= -32:
+		buf.WriteByte(byte(n))
+	case n >= math.MinInt8:
+		buf.Write([]byte{0xd0, byte(n)})
+	case n >= math.MinInt16:
+		buf.WriteByte(0xd1)
+		_ = binary.Write(buf, binary.BigEndian, int16(n))
+	case n >= math.MinInt32:
+		buf.WriteByte(0xd2)
+		_ = binary.Write(buf, binary.BigEndian, int32(n))
+	default:
+		buf.WriteByte(0xd3)
+		_ = binary.Write(buf, binary.BigEndian, n)
+	}
+}
+
+// encodeMsgpackUint writes the unsigned integer to buf in its shortest form
+func encodeMsgpackUint(buf *bytes.Buffer, n uint64) {
+	switch {
+	case n <= math.MaxInt8:
+		buf.WriteByte(byte(n))
+	case n <= math.MaxUint8:
+		buf.Write([]byte{0xcc, byte(n)})
+	case n <= math.MaxUint16:
+		buf.WriteByte(0xcd)
+		_ = binary.Write(buf, binary.BigEndian, uint16(n))
+	case n <= math.MaxUint32:
+		buf.WriteByte(0xce)
+		_ = binary.Write(buf, binary.BigEndian, uint32(n))
+	default:
+		buf.WriteByte(0xcf)
+		_ = binary.Write(buf, binary.BigEndian, n)
+	}
+}
+
+// encodeMsgpackHeader writes the header of a string, binary, array or map of the given length, using the fixed
+// format if the length is below fixedLimit, and otherwise the 8, 16 or 32-bit format. A zero format is not available
+func encodeMsgpackHeader(buf *bytes.Buffer, n int, fixed byte, fixedLimit int, format8 byte, format16 byte, format32 byte) {
+	switch {
+	case n < fixedLimit:
+		buf.WriteByte(fixed | byte(n))
+	case n <= math.MaxUint8 && format8 != 0:
+		buf.Write([]byte{format8, byte(n)})
+	case n <= math.MaxUint16:
+		buf.WriteByte(format16)
+		_ = binary.Write(buf, binary.BigEndian, uint16(n))
+	default:
+		buf.WriteByte(format32)
+		_ = binary.Write(buf, binary.BigEndian, uint32(n))
+	}
+}
+
+// maxMsgpackDepth is how deeply arrays and maps may nest in decoded msgpack values, as in encoding/json,
+// so that deeply nested data fails to decode rather than overflowing the stack
+const maxMsgpackDepth = 10000
+
+// decodeMsgpack reads a msgpack value, nested in depth arrays and maps, from r. Integers are returned as int64
+// or uint64, floats as float64, strings as string, binaries as []byte, arrays as []any and maps as []msgpackPair
+func decodeMsgpack(r *bytes.Reader, depth int) (any, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	switch {
+	case b <= 0x7f:
+		return int64(b), nil
+	case b >= 0xe0:
+		return int64(int8(b)), nil
+	case b&0xe0 == 0xa0:
+		return readMsgpackString(r, int(b&0x1f))
+	case b&0xf0 == 0x90:
+		return readMsgpackArray(r, int(b&0x0f), depth)
+	case b&0xf0 == 0x80:
+		return readMsgpackMap(r, int(b&0x0f), depth)
+	}
+
+	switch b {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		n, err := readMsgpackUint(r, 1<<(b-0xcc))
+		return n, err
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (b - 0xd0)
+		n, err := readMsgpackUint(r, size)
+		// sign-extend the integer from its size
+		shift := 64 - 8*size
+		return int64(n<<shift) >> shift, err
+	case 0xca:
+		n, err := readMsgpackUint(r, 4)
+		return float64(math.Float32frombits(uint32(n))), err
+	case 0xcb:
+		n, err := readMsgpackUint(r, 8)
+		return math.Float64frombits(n), err
+	case 0xd9, 0xda, 0xdb:
+		n, err := readMsgpackUint(r, 1<<(b-0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return readMsgpackString(r, int(n))
+	case 0xc4, 0xc5, 0xc6:
+		n, err := readMsgpackUint(r, 1<<(b-0xc4))
+		if err != nil {
+			return nil, err
+		}
+		s, err := readMsgpackString(r, int(n))
+		return []byte(s), err
+	case 0xdc, 0xdd:
+		n, err := readMsgpackUint(r, 2<<(b-0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return readMsgpackArray(r, int(n), depth)
+	case 0xde, 0xdf:
+		n, err := readMsgpackUint(r, 2<<(b-0xde))
+		if err != nil {
+			return nil, err
+		}
+		return readMsgpackMap(r, int(n), depth)
+	default:
+		return nil, fmt.Errorf("%w: msgpack format 0x%x", ErrUnsupportedType, b)
+	}
+}
+
+// readMsgpackUint reads a big-endian unsigned integer of the given size in bytes from r
+func readMsgpackUint(r *bytes.Reader, size int) (uint64, error) {
+	data := make([]byte, 8)
+	_, err := io.ReadFull(r, data[8-size:])
+	if err != nil {
+		return 0, io.ErrUnexpectedEOF
+	}
+
+	return binary.BigEndian.Uint64(data), nil
+}
+
+// readMsgpackString reads n bytes from r as a string
+func readMsgpackString(r *bytes.Reader, n int) (string, error) {
+	if n > r.Len() {
+		return "", io.ErrUnexpectedEOF
+	}
+
+	data := make([]byte, n)
+	_, err := io.ReadFull(r, data)
+	return string(data), err
+}
+
+// readMsgpackArray reads the n elements of an array, nested in depth arrays and maps, from r
+func readMsgpackArray(r *bytes.Reader, n int, depth int) ([]any, error) {
+	err := checkMsgpackDepth(depth)
+	if err != nil {
+		return nil, err
+	}
+
+	if n > r.Len() {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	elems := make([]any, n)
+	for i := range elems {
+		elems[i], err = decodeMsgpack(r, depth+1)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return elems, nil
+}
+
+// readMsgpackMap reads the n key-value pairs of a map, nested in depth arrays and maps, from r
+func readMsgpackMap(r *bytes.Reader, n int, depth int) ([]msgpackPair, error) {
+	err := checkMsgpackDepth(depth)
+	if err != nil {
+		return nil, err
+	}
+
+	if n > r.Len() {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	pairs := make([]msgpackPair, n)
+	for i := range pairs {
+		pairs[i].key, err = decodeMsgpack(r, depth+1)
+		if err != nil {
+			return nil, err
+		}
+
+		pairs[i].value, err = decodeMsgpack(r, depth+1)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return pairs, nil
+}
+
+// checkMsgpackDepth returns an error if an array or map nested in depth others is nested too deeply
+func checkMsgpackDepth(depth int) error {
+	if depth >= maxMsgpackDepth {
+		return fmt.Errorf("msgpack: arrays and maps nested more than %d deep", maxMsgpackDepth)
+	}
+
+	return nil
+}
+
+// assignMsgpack sets v to the decoded msgpack value x
+func assignMsgpack(v reflect.Value, x any) error {
+	if x == nil {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return assignMsgpack(v.Elem(), x)
+	case reflect.Interface:
+		if v.NumMethod() == 0 {
+			generic, err := toGenericMsgpack(x)
+			if err != nil {
+				return err
+			}
+			v.Set(reflect.ValueOf(generic))
+			return nil
+		}
+	}
+
+	mismatch := fmt.Errorf("%w: msgpack cannot decode %T into %s", ErrUnsupportedType, x, v.Type())
+	switch x := x.(type) {
+	case bool:
+		if v.Kind() != reflect.Bool {
+			return mismatch
+		}
+		v.SetBool(x)
+	case int64:
+		switch {
+		case v.CanInt() && !v.OverflowInt(x):
+			v.SetInt(x)
+		case v.CanUint() && x >= 0 && !v.OverflowUint(uint64(x)):
+			v.SetUint(uint64(x))
+		case v.CanFloat():
+			v.SetFloat(float64(x))
+		default:
+			return mismatch
+		}
+	case uint64:
+		switch {
+		case v.CanUint() && !v.OverflowUint(x):
+			v.SetUint(x)
+		case v.CanInt() && x <= math.MaxInt64 && !v.OverflowInt(int64(x)):
+			v.SetInt(int64(x))
+		case v.CanFloat():
+			v.SetFloat(float64(x))
+		default:
+			return mismatch
+		}
+	case float64:
+		if !v.CanFloat() {
+			return mismatch
+		}
+		v.SetFloat(x)
+	case string:
+		return assignMsgpackBytes(v, []byte(x), mismatch)
+	case []byte:
+		return assignMsgpackBytes(v, x, mismatch)
+	case []any:
+		switch v.Kind() {
+		case reflect.Slice:
+			v.Set(reflect.MakeSlice(v.Type(), len(x), len(x)))
+		case reflect.Array:
+			if v.Len() != len(x) {
+				return mismatch
+			}
+		default:
+			return mismatch
+		}
+
+		for i, elem := range x {
+			err := assignMsgpack(v.Index(i), elem)
+			if err != nil {
+				return err
+			}
+		}
+	case []msgpackPair:
+		switch v.Kind() {
+		case reflect.Map:
+			return assignMsgpackMap(v, x)
+		case reflect.Struct:
+			return assignMsgpackStruct(v, x)
+		default:
+			return mismatch
+		}
+	}
+
+	return nil
+}
+
+// assignMsgpackBytes sets v, a string or a byte slice, to the decoded string or binary data
+func assignMsgpackBytes(v reflect.Value, data []byte, mismatch error) error {
+	switch {
+	case v.Kind() == reflect.String:
+		v.SetString(string(data))
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8:
+		v.SetBytes(bytes.Clone(data))
+	default:
+		return mismatch
+	}
+
+	return nil
+}
+
+// assignMsgpackMap sets the map v to the decoded key-value pairs
+func assignMsgpackMap(v reflect.Value, pairs []msgpackPair) error {
+	m := reflect.MakeMapWithSize(v.Type(), len(pairs))
+	for _, pair := range pairs {
+		key := reflect.New(v.Type().Key()).Elem()
+		err := assignMsgpack(key, pair.key)
+		if err != nil {
+			return err
+		}
+
+		value := reflect.New(v.Type().Elem()).Elem()
+		err = assignMsgpack(value, pair.value)
+		if err != nil {
+			return err
+		}
+
+		m.SetMapIndex(key, value)
+	}
+
+	v.Set(m)
+	return nil
+}
+
+// assignMsgpackStruct sets the fields of the struct v to the decoded key-value pairs, ignoring unknown keys
+func assignMsgpackStruct(v reflect.Value, pairs []msgpackPair) error {
+	fields := map[string]int{}
+	for _, field := range getMsgpackFields(v.Type()) {
+		fields[field.name] = field.index
+	}
+
+	for _, pair := range pairs {
+		name, ok := pair.key.(string)
+		if !ok {
+			return fmt.Errorf("%w: msgpack cannot decode a %T key into %s", ErrUnsupportedType, pair.key, v.Type())
+		}
+
+		index, ok := fields[name]
+		if !ok {
+			continue
+		}
+
+		err := assignMsgpack(v.Field(index), pair.value)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// toGenericMsgpack converts the decoded msgpack value x into the value an empty interface gets. Maps become
+// map[string]any if all their keys are strings, and map[any]any otherwise
+func toGenericMsgpack(x any) (any, error) {
+	switch x := x.(type) {
+	case []any:
+		for i, elem := range x {
+			var err error
+			x[i], err = toGenericMsgpack(elem)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return x, nil
+	case []msgpackPair:
+		stringKeyed := map[string]any{}
+		anyKeyed := map[any]any{}
+		for _, pair := range x {
+			value, err := toGenericMsgpack(pair.value)
+			if err != nil {
+				return nil, err
+			}
+
+			key, err := toGenericMsgpack(pair.key)
+			if err != nil {
+				return nil, err
+			}
+
+			if key != nil && !reflect.TypeOf(key).Comparable() {
+				return nil, fmt.Errorf("%w: msgpack map key of type %T", ErrUnsupportedType, key)
+			}
+			if s, ok := key.(string); ok {
+				stringKeyed[s] = value
+			}
+			anyKeyed[key] = value
+		}
+
+		if len(stringKeyed) == len(anyKeyed) {
+			return stringKeyed, nil
+		}
+		return anyKeyed, nil
+	default:
+		return x, nil
+	}
+}
+
+// getMsgpackFields returns the exported fields of the struct type t, with their msgpack names
+func getMsgpackFields(t reflect.Type) []msgpackField {
+	var fields []msgpackField
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name := field.Tag.Get("msgpack")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		fields = append(fields, msgpackField{name: name, index: i})
+	}
+
+	return fields
+}
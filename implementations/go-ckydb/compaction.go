@@ -0,0 +1,53 @@
+package ckydb
+
+import (
+	"time"
+)
+
+// CompactionPolicy configures the background compaction task
+type CompactionPolicy struct {
+	// Interval is how often the compaction task runs
+	Interval time.Duration
+	// MinDataFiles is the number of data files below which compaction is skipped
+	MinDataFiles int
+}
+
+// WithCompaction runs Compact in a background task, alongside the vacuum task, as configured by the policy
+func WithCompaction(policy CompactionPolicy) Option {
+	return func(o *options) {
+		o.compactionPolicy = &policy
+	}
+}
+
+// Compact merges adjacent data files whose combined size does not exceed maxFileSizeKB.
+// Such small data files accumulate as vacuuming removes deleted key-values from them
+func (c *Ckydb) Compact() error {
+	c.mutLock.Lock()
+	defer c.mutLock.Unlock()
+
+	return c.instrument(opCompact, "", c.store.CompactWithStats)
+}
+
+// compactIfNeeded compacts the database if it has at least as many data files as the
+// compaction policy requires. It is the work of the compaction task
+func (c *Ckydb) compactIfNeeded() error {
+	c.mutLock.RLock()
+	numOfDataFiles := c.store.Stats().DataFiles
+	c.mutLock.RUnlock()
+
+	if numOfDataFiles < c.compactionPolicy.MinDataFiles {
+		return nil
+	}
+
+	compact := c.Compact
+	if c.throttle != nil {
+		compact = c.throttledCompact
+	}
+
+	err := compact()
+	if err != nil {
+		c.logger.Printf("error: %s", err)
+	}
+
+	return err
+}
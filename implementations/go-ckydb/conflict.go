@@ -0,0 +1,161 @@
+package ckydb
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/sopherapps/ckydb/implementations/go-ckydb/internal"
+)
+
+// ConflictResolver decides the value of a key that is set by a replicated op while it already
+// has a value, given both values and the times in nanoseconds since the unix epoch when they were written.
+// It must be deterministic so that databases replicating to each other end up with the same values
+type ConflictResolver func(key, localVal, remoteVal string, localTs, remoteTs int64) string
+
+// LastWriterWins is the default ConflictResolver. It keeps the value written last, and the greater
+// of the two values if they were written at the same time
+func LastWriterWins(key, localVal, remoteVal string, localTs, remoteTs int64) string {
+	if remoteTs > localTs || (remoteTs == localTs && remoteVal > localVal) {
+		return remoteVal
+	}
+
+	return localVal
+}
+
+// WithConflictResolver sets how the values of keys that both the database and a replicated op set are merged
+// by Apply, e.g. to add up counters, instead of keeping the value written last
+func WithConflictResolver(resolver ConflictResolver) Option {
+	return func(o *options) {
+		o.conflictResolver = resolver
+	}
+}
+
+// Apply applies a replicated op from another database, making Ckydb a ReplicationSink. A set of an existing key
+// stores the value picked by the ConflictResolver, and a delete is ignored if the key was written after it.
+// Databases track the time of every write from their first Apply. Keys last written before it are taken
+// to be written when they were created. Deleted keys are not remembered, so a replicated set of a key
+// deleted after it still adds the key back. Applied ops are forwarded to the database's own replication sink
+func (c *Ckydb) Apply(op Op) error {
+	op.Key = c.normalizeKey(op.Key)
+	c.mutLock.Lock()
+	defer c.mutLock.Unlock()
+
+	if c.writeTimes == nil {
+		c.writeTimes = map[string]int64{}
+	}
+
+	switch op.Type {
+	case OpSet:
+		return c.applySet(op)
+	case OpDelete:
+		return c.applyDelete(op)
+	case OpClear:
+		err := c.instrument(opClear, "", func(st *internal.OpStats) error {
+			return c.store.Clear()
+		})
+		if err != nil {
+			return err
+		}
+
+		c.audit(context.Background(), op, true)
+		c.forward(op)
+		return nil
+	default:
+		return ErrOutOfBounds
+	}
+}
+
+// applySet sets the key of the replicated op to the value picked by the conflict resolver
+func (c *Ckydb) applySet(op Op) error {
+	localVal, err := c.store.Get(op.Key)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+
+	if err == nil {
+		localTs, err := c.getWriteTime(op.Key)
+		if err != nil {
+			return err
+		}
+
+		resolve := c.conflictResolver
+		if resolve == nil {
+			resolve = LastWriterWins
+		}
+
+		op.Value = resolve(op.Key, localVal, op.Value, localTs, op.Time.UnixNano())
+		op.Time = time.Unix(0, max(localTs, op.Time.UnixNano()))
+		if op.Value == localVal {
+			c.writeTimes[op.Key] = op.Time.UnixNano()
+			return nil
+		}
+	}
+
+	err = c.instrument(opSet, op.Key, func(st *internal.OpStats) error {
+		return c.store.SetWithStats(op.Key, op.Value, st)
+	})
+	if err != nil {
+		return err
+	}
+
+	c.audit(context.Background(), op, true)
+	c.forward(op)
+	return nil
+}
+
+// applyDelete deletes the key of the replicated op unless it was written after the op
+func (c *Ckydb) applyDelete(op Op) error {
+	if !c.store.Has(op.Key) {
+		return nil
+	}
+
+	localTs, err := c.getWriteTime(op.Key)
+	if err != nil {
+		return err
+	}
+
+	if localTs > op.Time.UnixNano() {
+		return nil
+	}
+
+	err = c.instrument(opDelete, op.Key, func(st *internal.OpStats) error {
+		return c.store.DeleteWithStats(op.Key, st)
+	})
+	if err != nil {
+		return err
+	}
+
+	c.audit(context.Background(), op, true)
+	c.forward(op)
+	return nil
+}
+
+// getWriteTime returns when the given key was last written, in nanoseconds since the unix epoch,
+// falling back to when it was created for keys not written since the first Apply
+func (c *Ckydb) getWriteTime(key string) (int64, error) {
+	if ts, ok := c.writeTimes[key]; ok {
+		return ts, nil
+	}
+
+	info, err := c.store.DescribeWithStats(key, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	return info.CreatedAt.UnixNano(), nil
+}
+
+// recordWriteTime records the time of the committed op, once the database has applied a replicated op
+func (c *Ckydb) recordWriteTime(op Op) {
+	if c.writeTimes == nil {
+		return
+	}
+
+	if op.Type == OpClear {
+		c.writeTimes = map[string]int64{}
+		return
+	}
+
+	c.writeTimes[op.Key] = op.Time.UnixNano()
+}
@@ -0,0 +1,216 @@
+package conformance
+
+import (
+	"flag"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/sopherapps/ckydb/implementations/go-ckydb"
+	"github.com/stretchr/testify/assert"
+)
+
+var update = flag.Bool("update", false, "regenerate the golden folders of the go implementation and of the enabled drivers")
+
+const (
+	maxFileSizeKB     = 320.0 / 1024
+	vacuumIntervalSec = 3600.0
+	scriptsDir        = "testdata/scripts"
+	goldenDir         = "testdata/golden"
+	goImplementation  = "go"
+)
+
+// drivers are the commands that run the drivers of the other implementations, keyed by implementation.
+// The arguments of the driver are appended to the command
+var drivers = map[string]func() *exec.Cmd{
+	"py": func() *exec.Cmd {
+		cmd := exec.Command("python3", filepath.Join("drivers", "py_driver.py"))
+		cmd.Env = append(os.Environ(), "PYTHONPATH="+filepath.Join("..", "..", "py_ckydb"))
+		return cmd
+	},
+	"rs": func() *exec.Cmd {
+		if path := os.Getenv("CKYDB_CONFORMANCE_RS_DRIVER"); path != "" {
+			return exec.Command(path)
+		}
+
+		manifest := filepath.Join("..", "..", "rs_ckydb", "Cargo.toml")
+		return exec.Command("cargo", "run", "--quiet", "--manifest-path", manifest, "--example", "conformance_driver", "--")
+	},
+}
+
+func TestConformance(t *testing.T) {
+	scripts, err := LoadScripts(scriptsDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	enabledDrivers := getEnabledDrivers(t)
+
+	if *update {
+		for _, script := range scripts {
+			path := filepath.Join(goldenDir, goImplementation, script.Name)
+			err = os.RemoveAll(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			writeWithGo(t, path, script)
+
+			for _, name := range enabledDrivers {
+				path := filepath.Join(goldenDir, name, script.Name)
+				err = os.RemoveAll(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				runDriver(t, name, "write", path, script)
+			}
+		}
+	}
+
+	implementations, err := os.ReadDir(goldenDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, implementation := range implementations {
+		for _, script := range scripts {
+			t.Run("GoShouldReadAndWriteGoldenFolderOf"+implementation.Name()+"For"+script.Name, func(t *testing.T) {
+				src := filepath.Join(goldenDir, implementation.Name(), script.Name)
+				if _, err := os.Stat(src); os.IsNotExist(err) {
+					if implementation.Name() == goImplementation {
+						t.Fatalf("%s is missing; run the tests with -update", src)
+					}
+					t.Skipf("%s is missing", src)
+				}
+
+				dbPath := filepath.Join(t.TempDir(), "db")
+				copyDir(t, src, dbPath)
+				verifyWithGo(t, dbPath, script)
+			})
+		}
+	}
+
+	for _, name := range enabledDrivers {
+		for _, script := range scripts {
+			t.Run(name+"ShouldReadFolderWrittenByGoFor"+script.Name, func(t *testing.T) {
+				dbPath := filepath.Join(t.TempDir(), "db")
+				writeWithGo(t, dbPath, script)
+				runDriver(t, name, "verify", dbPath, script)
+			})
+
+			t.Run("GoShouldReadFolderWrittenBy"+name+"For"+script.Name, func(t *testing.T) {
+				dbPath := filepath.Join(t.TempDir(), "db")
+				runDriver(t, name, "write", dbPath, script)
+				verifyWithGo(t, dbPath, script)
+			})
+		}
+	}
+}
+
+// getEnabledDrivers returns the implementations listed in the comma-separated CKYDB_CONFORMANCE_DRIVERS
+// environment variable, failing the test if any of them has no driver
+func getEnabledDrivers(t *testing.T) []string {
+	var names []string
+	for _, name := range strings.Split(os.Getenv("CKYDB_CONFORMANCE_DRIVERS"), ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		if _, ok := drivers[name]; !ok {
+			t.Fatalf("unknown driver %q", name)
+		}
+		names = append(names, name)
+	}
+
+	return names
+}
+
+// writeWithGo runs the script against a new database at dbPath
+func writeWithGo(t *testing.T, dbPath string, script *Script) {
+	t.Helper()
+
+	db, err := ckydb.Connect(dbPath, maxFileSizeKB, vacuumIntervalSec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = db.Close() }()
+
+	err = script.Run(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// verifyWithGo checks that the database at dbPath holds what the script leaves behind,
+// and that it is still usable after another write and a reopen
+func verifyWithGo(t *testing.T, dbPath string, script *Script) {
+	t.Helper()
+
+	db, err := ckydb.Connect(dbPath, maxFileSizeKB, vacuumIntervalSec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = db.Close() }()
+
+	assert.Nil(t, script.Verify(db))
+
+	err = db.Set("written-by-go", "value")
+	assert.Nil(t, err)
+	err = db.Close()
+	assert.Nil(t, err)
+	err = db.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	value, err := db.Get("written-by-go")
+	assert.Nil(t, err)
+	assert.Equal(t, "value", value)
+}
+
+// runDriver runs the driver of the given implementation with the given command on the database at dbPath
+func runDriver(t *testing.T, name string, command string, dbPath string, script *Script) {
+	t.Helper()
+
+	scriptPath, err := filepath.Abs(filepath.Join(scriptsDir, script.Name+"."+ScriptExt))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := drivers[name]()
+	cmd.Args = append(cmd.Args, command, dbPath, scriptPath, strconv.FormatFloat(maxFileSizeKB, 'f', -1, 64))
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("%s driver failed to %s %s: %s\n%s", name, command, script.Name, err, output)
+	}
+}
+
+// copyDir copies the files in the folder src to a new folder dst
+func copyDir(t *testing.T, src string, dst string) {
+	t.Helper()
+
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.MkdirAll(dst, 0777)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, entry := range entries {
+		data, err := os.ReadFile(filepath.Join(src, entry.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = os.WriteFile(filepath.Join(dst, entry.Name()), data, 0666)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+"""
+Conformance driver for the Python implementation of ckydb.
+
+Usage:
+    python py_driver.py write <db_path> <script_path> <max_file_size_kb>
+    python py_driver.py verify <db_path> <script_path> <max_file_size_kb>
+
+It expects the py_ckydb folder to be on the PYTHONPATH
+"""
+import sys
+from typing import Dict, List, Tuple
+
+from ckydb import Store, exc
+
+
+def parse_script(path: str) -> List[List[str]]:
+    """Parses the script at path into a list of operations, each a list of fields"""
+    ops = []
+    with open(path) as f:
+        for line_number, line in enumerate(f, start=1):
+            line = line.strip()
+            if line == "" or line.startswith("#"):
+                continue
+
+            fields = line.split()
+            if (fields[0], len(fields)) not in (("set", 3), ("delete", 2), ("clear", 1)):
+                raise ValueError(f"invalid script: {path} line {line_number}: {line!r}")
+
+            ops.append(fields)
+    return ops
+
+
+def expected(ops: List[List[str]]) -> Tuple[Dict[str, str], List[str]]:
+    """Returns the key-values the operations leave behind, and the keys set at some point but gone by the end"""
+    present = {}
+    touched = set()
+    for op in ops:
+        if op[0] == "set":
+            present[op[1]] = op[2]
+            touched.add(op[1])
+        elif op[0] == "delete":
+            present.pop(op[1], None)
+        else:
+            present = {}
+
+    return present, sorted(touched - present.keys())
+
+
+def write(store: Store, ops: List[List[str]]):
+    """Runs the operations against the store"""
+    for op in ops:
+        if op[0] == "set":
+            store.set(op[1], op[2])
+        elif op[0] == "delete":
+            store.delete(op[1])
+        else:
+            store.clear()
+
+
+def verify(store: Store, ops: List[List[str]]) -> List[str]:
+    """Returns the differences between the key-values in the store and those the operations leave behind"""
+    present, absent = expected(ops)
+    errors = []
+    for key, value in present.items():
+        try:
+            got = store.get(key)
+        except exc.NotFoundError:
+            got = None
+
+        if got != value:
+            errors.append(f"expected {value!r} for {key!r}, got {got!r}")
+
+    for key in absent:
+        try:
+            got = store.get(key)
+            errors.append(f"expected {key!r} to be not found, got {got!r}")
+        except exc.NotFoundError:
+            pass
+
+    return errors
+
+
+def main():
+    if len(sys.argv) != 5 or sys.argv[1] not in ("write", "verify"):
+        print(__doc__, file=sys.stderr)
+        sys.exit(2)
+
+    command, db_path, script_path, max_file_size_kb = sys.argv[1:]
+    ops = parse_script(script_path)
+    store = Store(db_path=db_path, max_file_size_kb=float(max_file_size_kb))
+    store.load()
+
+    if command == "write":
+        write(store, ops)
+        return
+
+    errors = verify(store, ops)
+    if errors:
+        print("\n".join(errors), file=sys.stderr)
+        sys.exit(1)
+
+
+if __name__ == "__main__":
+    main()
@@ -0,0 +1,179 @@
+// Package conformance checks that the ckydb implementations in the different languages
+// can read and write each other's database folders, since they share the same disk format.
+//
+// The checks are driven by scripts in testdata/scripts, each a list of operations, one per line:
+//
+//	set <key> <value>
+//	delete <key>
+//	clear
+//
+// Blank lines and lines starting with "#" are ignored, and keys and values cannot contain whitespace.
+// Every implementation has a driver that runs a script against a database folder or verifies that
+// a database folder holds the key-value pairs the script leaves behind. The folders each
+// implementation produced for the scripts are kept in testdata/golden/<implementation>/<script>.
+package conformance
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/sopherapps/ckydb/implementations/go-ckydb"
+)
+
+const ScriptExt = "txt"
+
+var ErrInvalidScript = errors.New("invalid script")
+
+// Op is an operation in a script
+type Op struct {
+	Name  string
+	Key   string
+	Value string
+}
+
+// Script is a named list of operations
+type Script struct {
+	Name string
+	Ops  []Op
+}
+
+// ParseScript parses the script read from r, returning an ErrInvalidScript error
+// if any of its lines is not a valid operation
+func ParseScript(name string, r io.Reader) (*Script, error) {
+	script := &Script{Name: name}
+	scanner := bufio.NewScanner(r)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		op := Op{Name: fields[0]}
+		switch {
+		case op.Name == "set" && len(fields) == 3:
+			op.Key, op.Value = fields[1], fields[2]
+		case op.Name == "delete" && len(fields) == 2:
+			op.Key = fields[1]
+		case op.Name == "clear" && len(fields) == 1:
+		default:
+			return nil, fmt.Errorf("%w: %s line %d: %q", ErrInvalidScript, name, lineNumber, line)
+		}
+
+		script.Ops = append(script.Ops, op)
+	}
+
+	return script, scanner.Err()
+}
+
+// LoadScripts parses all the scripts in dir, sorted by name. A script's name is its filename without the extension
+func LoadScripts(dir string) ([]*Script, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*."+ScriptExt))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	scripts := make([]*Script, 0, len(paths))
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+
+		script, err := ParseScript(strings.TrimSuffix(filepath.Base(path), "."+ScriptExt), file)
+		_ = file.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		scripts = append(scripts, script)
+	}
+
+	return scripts, nil
+}
+
+// Run runs the operations of the script against db in order, stopping at the first error
+func (s *Script) Run(db ckydb.Controller) error {
+	for i, op := range s.Ops {
+		var err error
+		switch op.Name {
+		case "set":
+			err = db.Set(op.Key, op.Value)
+		case "delete":
+			err = db.Delete(op.Key)
+		case "clear":
+			err = db.Clear()
+		}
+
+		if err != nil {
+			return fmt.Errorf("%s operation %d (%s %s): %w", s.Name, i+1, op.Name, op.Key, err)
+		}
+	}
+
+	return nil
+}
+
+// Expected returns the key-value pairs the script leaves behind, and the keys it sets
+// at some point but are gone by its end
+func (s *Script) Expected() (present map[string]string, absent []string) {
+	present = map[string]string{}
+	touched := map[string]bool{}
+	for _, op := range s.Ops {
+		switch op.Name {
+		case "set":
+			present[op.Key] = op.Value
+			touched[op.Key] = true
+		case "delete":
+			delete(present, op.Key)
+		case "clear":
+			present = map[string]string{}
+		}
+	}
+
+	for key := range touched {
+		if _, ok := present[key]; !ok {
+			absent = append(absent, key)
+		}
+	}
+	sort.Strings(absent)
+
+	return present, absent
+}
+
+// Verify checks that db holds exactly the key-value pairs the script leaves behind
+func (s *Script) Verify(db *ckydb.Ckydb) error {
+	present, absent := s.Expected()
+	got := map[string]string{}
+	for k, v := range db.All() {
+		got[k] = v
+	}
+
+	var errs []error
+	for key, value := range present {
+		if got[key] != value {
+			errs = append(errs, fmt.Errorf("%s: expected %q for %q, got %q", s.Name, value, key, got[key]))
+		}
+	}
+
+	for key := range got {
+		if _, ok := present[key]; !ok {
+			errs = append(errs, fmt.Errorf("%s: unexpected key %q", s.Name, key))
+		}
+	}
+
+	for _, key := range absent {
+		_, err := db.Get(key)
+		if !errors.Is(err, ckydb.ErrNotFound) {
+			errs = append(errs, fmt.Errorf("%s: expected %q to be not found, got %v", s.Name, key, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -1,21 +1,58 @@
 package ckydb
 
 import (
-	"log"
+	"context"
+	"errors"
+	"io"
+	"iter"
+	"regexp"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/sopherapps/ckydb/implementations/go-ckydb/internal"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var (
-	ErrAlreadyRunning = internal.ErrAlreadyRunning
-	ErrNotRunning     = internal.ErrNotRunning
-	ErrNotFound       = internal.ErrNotFound
-	ErrCorruptedData  = internal.ErrCorruptedData
-	ErrOutOfBounds    = internal.ErrOutOfBounds
+	ErrAlreadyRunning  = internal.ErrAlreadyRunning
+	ErrNotRunning      = internal.ErrNotRunning
+	ErrNotFound        = internal.ErrNotFound
+	ErrCorruptedData   = internal.ErrCorruptedData
+	ErrOutOfBounds     = internal.ErrOutOfBounds
+	ErrInvalidKeyValue = internal.ErrInvalidKeyValue
+	ErrConflict        = internal.ErrConflict
+	ErrQuotaExceeded   = internal.ErrQuotaExceeded
+	ErrReadOnly        = internal.ErrReadOnly
+	ErrFollower        = internal.ErrFollower
+	ErrReservedKey     = internal.ErrReservedKey
+
+	ErrInvariantViolated = internal.ErrInvariantViolated
+	ErrHistoryDisabled   = internal.ErrHistoryDisabled
+	ErrTrashDisabled     = internal.ErrTrashDisabled
+
+	ErrUnsupportedFormatVersion = internal.ErrUnsupportedFormatVersion
+	ErrOutdatedFormatVersion    = internal.ErrOutdatedFormatVersion
+	ErrMigrationInProgress      = internal.ErrMigrationInProgress
+	ErrInvalidSeparators        = internal.ErrInvalidSeparators
+
+	ErrTamperedRecord = internal.ErrTamperedRecord
+	ErrInvalidHMACKey = internal.ErrInvalidHMACKey
+	ErrHMACDisabled   = internal.ErrHMACDisabled
+	ErrNoManifest     = internal.ErrNoManifest
+	ErrNoNodes        = internal.ErrNoNodes
 )
 
+type Result = internal.GetResult
+
+type KeyInfo = internal.KeyInfo
+
+// ReservedKeyPrefix starts the keys reserved for ckydb itself. Setting or deleting them fails with an
+// ErrReservedKey error, and All, Prefix and the other enumerations leave them out
+const ReservedKeyPrefix = internal.ReservedKeyPrefix
+
 type Controller interface {
 	Open() error
 	Close() error
@@ -25,17 +62,79 @@ type Controller interface {
 	Clear() error
 }
 
+// StatsProvider is a database reporting its statistics and the status of its background tasks
+type StatsProvider interface {
+	Stats() Stats
+	Tasks() []TaskStatus
+}
+
+// Enumerator is a database whose keys and key-value pairs can be listed
+type Enumerator interface {
+	Keys() []string
+	Exists(key string) bool
+	All() iter.Seq2[string, string]
+	Prefix(prefix string) iter.Seq2[string, string]
+}
+
+// Maintainer is a database whose files can be vacuumed and compacted on demand
+type Maintainer interface {
+	Vacuum() error
+	Compact() error
+}
+
+// Database is the Controller with the richer surface of *Ckydb that wrappers, mocks and servers may rely on
+type Database interface {
+	Controller
+	StatsProvider
+	Enumerator
+	Maintainer
+}
+
+var _ Database = (*Ckydb)(nil)
+
 type Ckydb struct {
-	tasks             []internal.Worker
+	// tasks are the background tasks started by the last Open, replaced while holding lifecycleLock
+	tasks             atomic.Pointer[[]internal.Worker]
 	store             internal.Storage
 	vacuumIntervalSec float64
-	isOpen            bool
-	mutLock           sync.Mutex
+	// state is the State of the database, changed by Open and Close while holding lifecycleLock
+	state             atomic.Int32
+	lifecycleLock     sync.Mutex
+	loadErr           error
+	counters          *opCounters
+	expvarPrefix      string
+	tracer            trace.Tracer
+	logger            Logger
+	slowOpThreshold   time.Duration
+	clock             internal.Clock
+	vacuumTaskOptions []internal.TaskOption
+	compactionPolicy  *CompactionPolicy
+	indexBatchPolicy  *IndexBatchPolicy
+	memoryPolicy      *MemoryPolicy
+	// memoryShedSkips and memoryShedBackoff are the checks the memory governor still skips after its last shed,
+	// and those it is to skip after the next one, under sustained memory pressure. Only its task uses them
+	memoryShedSkips   int
+	memoryShedBackoff int
+	onOperation       func(op OpInfo)
+	replicator        *replicator
+	droppedOps        []Op
+	conflictResolver  ConflictResolver
+	writeTimes        map[string]int64
+	isFollower        bool
+	followerInterval  time.Duration
+	auditLog          io.Writer
+	hashAuditKeys     bool
+	throttle          *MaintenanceThrottle
+	keyNormalization  KeyNormalization
+	caseInsensitive   bool
+	quarantine        bool
+	lastForegroundOp  atomic.Int64
+	mutLock           sync.RWMutex
 }
 
 // Connect creates a new Ckydb instance, starts its background tasks and returns it
-func Connect(dbPath string, maxFileSizeKB float64, vacuumIntervalSec float64) (*Ckydb, error) {
-	db, err := newCkydb(dbPath, maxFileSizeKB, vacuumIntervalSec)
+func Connect(dbPath string, maxFileSizeKB float64, vacuumIntervalSec float64, opts ...Option) (*Ckydb, error) {
+	db, err := newCkydb(dbPath, maxFileSizeKB, vacuumIntervalSec, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -50,94 +149,605 @@ func Connect(dbPath string, maxFileSizeKB float64, vacuumIntervalSec float64) (*
 
 // newCkydb creates a new instance of Ckydb. This is used internally.
 // Use Connect() for external code
-func newCkydb(dbPath string, maxFileSizeKB float64, vacuumIntervalSec float64) (*Ckydb, error) {
-	store := internal.NewStore(dbPath, maxFileSizeKB)
-	err := store.Load()
+func newCkydb(dbPath string, maxFileSizeKB float64, vacuumIntervalSec float64, opts ...Option) (*Ckydb, error) {
+	db := newUnloadedCkydb(dbPath, maxFileSizeKB, vacuumIntervalSec, opts...)
+	err := db.load()
 	if err != nil {
 		return nil, err
 	}
 
+	return db, nil
+}
+
+// newUnloadedCkydb creates a new instance of Ckydb without loading its store from disk
+func newUnloadedCkydb(dbPath string, maxFileSizeKB float64, vacuumIntervalSec float64, opts ...Option) *Ckydb {
+	o := newOptions(opts)
 	db := Ckydb{
-		tasks:             make([]internal.Worker, 0),
-		store:             store,
+		store:             engineStorage{engine: o.engine},
 		vacuumIntervalSec: vacuumIntervalSec,
-		isOpen:            false,
+		counters:          newOpCounters(),
+		expvarPrefix:      o.expvarPrefix,
+		tracer:            o.tracer,
+		logger:            o.logger,
+		slowOpThreshold:   o.slowOpThreshold,
+		clock:             o.clock,
+		vacuumTaskOptions: o.vacuumTaskOptions,
+		compactionPolicy:  o.compactionPolicy,
+		indexBatchPolicy:  o.indexBatchPolicy,
+		memoryPolicy:      o.memoryPolicy,
+		onOperation:       o.onOperation,
+		conflictResolver:  o.conflictResolver,
+		isFollower:        o.isFollower,
+		followerInterval:  o.followerInterval,
+		auditLog:          o.auditLog,
+		hashAuditKeys:     o.hashAuditKeys,
+		throttle:          o.throttle,
+		keyNormalization:  o.keyNormalization,
+		caseInsensitive:   o.caseInsensitive,
+		quarantine:        o.quarantine,
+	}
+
+	if o.replicationSink != nil {
+		db.replicator = newReplicator(o.replicationSink, o.replicationPolicy, o.logger, o.clock)
 	}
 
-	return &db, nil
+	if o.engine == nil {
+		storeOptions := append(slices.Clip(o.storeOptions), internal.WithDroppedKeyHandler(db.forwardDroppedKey))
+		db.store = internal.NewStore(dbPath, maxFileSizeKB, storeOptions...)
+	}
+
+	return &db
+}
+
+// load loads the store from disk
+func (c *Ckydb) load() error {
+	return c.measure(opLoad, "", func(st *internal.OpStats) error {
+		return c.store.Load()
+	})
 }
 
-// Open initializes all background tasks
+// Open initializes all background tasks, opening a new database or reopening a closed one
 func (c *Ckydb) Open() error {
-	if c.isOpen {
-		return nil
+	c.lifecycleLock.Lock()
+	defer c.lifecycleLock.Unlock()
+
+	if c.loadErr != nil {
+		return c.loadErr
 	}
 
-	vacuumTask := internal.NewTask(time.Second*time.Duration(c.vacuumIntervalSec), func() {
-		c.mutLock.Lock()
-		defer c.mutLock.Unlock()
+	return c.open()
+}
+
+// open is Open without taking the lifecycleLock
+func (c *Ckydb) open() error {
+	if c.State() == StateOpen {
+		return nil
+	}
 
-		err := c.store.Vacuum()
+	tasks := c.newTasks()
+	c.tasks.Store(&tasks)
+	for _, task := range tasks {
+		err := task.Start()
 		if err != nil {
-			log.Printf("error: %s", err)
+			return err
 		}
-	})
-	err := vacuumTask.Start()
-	if err != nil {
-		return err
 	}
 
-	c.tasks = append(c.tasks, vacuumTask)
-	c.isOpen = true
+	if c.replicator != nil {
+		c.replicator.start()
+	}
+
+	for _, op := range c.droppedOps {
+		c.forward(op)
+	}
+	c.droppedOps = nil
+
+	c.state.Store(int32(StateOpen))
+
+	if c.expvarPrefix != "" {
+		publishExpvar(c.expvarPrefix, c)
+	}
 
 	return nil
 }
 
-// Close stops any background tasks
+// Close stops any background tasks, waits for the operations under way, appends the index entries buffered by
+// WithIndexBatching, and then waits for buffered mutations to be applied to the replication sink. Closing a database that is not open only marks it as closed
 func (c *Ckydb) Close() error {
-	if !c.isOpen {
+	c.lifecycleLock.Lock()
+	defer c.lifecycleLock.Unlock()
+
+	if c.State() != StateOpen {
+		c.state.Store(int32(StateClosed))
 		return nil
 	}
 
-	for _, task := range c.tasks {
+	for _, task := range c.getTasks() {
 		err := task.Stop()
 		if err != nil {
 			return err
 		}
 	}
 
-	c.isOpen = false
-	return nil
+	c.mutLock.Lock()
+	flushErr := c.store.Flush()
+	c.state.Store(int32(StateClosed))
+	c.mutLock.Unlock()
+
+	if c.replicator != nil {
+		c.replicator.close()
+	}
+
+	if c.expvarPrefix != "" {
+		unpublishExpvar(c.expvarPrefix, c)
+	}
+
+	return flushErr
+}
+
+// SetMaxFileSize changes the size in kilobytes beyond which the log file is rolled into a data file,
+// without reopening the database. Files already on disk are left as they are
+func (c *Ckydb) SetMaxFileSize(maxFileSizeKB float64) error {
+	c.mutLock.Lock()
+	defer c.mutLock.Unlock()
+
+	err := c.checkState()
+	if err != nil {
+		return err
+	}
+
+	return c.store.SetMaxFileSize(maxFileSizeKB)
 }
 
 // Set adds or updates the value corresponding to the given key in store
-// It might return an ErrCorruptedData error but if it succeeds, no error is returned
+// It might return an ErrCorruptedData error but if it succeeds, no error is returned.
+// It returns an ErrInvalidKeyValue error if the key or value contains any of the separators,
+// and an ErrQuotaExceeded error if the pair does not fit in the maximum database size
 func (c *Ckydb) Set(key string, value string) error {
+	return c.SetContext(context.Background(), key, value)
+}
+
+// SetContext is Set, recording the audit metadata of ctx in the audit log and tracing it within the span of ctx
+func (c *Ckydb) SetContext(ctx context.Context, key string, value string) error {
+	key = c.normalizeKey(key)
 	c.mutLock.Lock()
 	defer c.mutLock.Unlock()
 
-	return c.store.Set(key, value)
+	err := c.instrumentContext(ctx, opSet, key, func(st *internal.OpStats) error {
+		return c.store.SetWithStats(key, value, st)
+	})
+	if err != nil {
+		return err
+	}
+
+	c.replicate(ctx, OpSet, key, value)
+	return nil
 }
 
 // Get retrieves the value corresponding to the given key
 // It returns a ErrNotFound error if the key is nonexistent
 func (c *Ckydb) Get(key string) (string, error) {
-	return c.store.Get(key)
+	return c.GetContext(context.Background(), key)
+}
+
+// GetContext is Get, tracing it within the span of ctx
+func (c *Ckydb) GetContext(ctx context.Context, key string) (string, error) {
+	key = c.normalizeKey(key)
+	value, err := c.get(ctx, key)
+	if errors.Is(err, ErrCorruptedData) {
+		c.quarantineIfEnabled(key)
+	}
+
+	return value, err
+}
+
+// get is Get without the quarantine of corrupted records
+func (c *Ckydb) get(ctx context.Context, key string) (string, error) {
+	c.mutLock.RLock()
+	defer c.mutLock.RUnlock()
+
+	if c.State() == StateLoading {
+		return c.getWhileLoading(ctx, key)
+	}
+
+	var value string
+	err := c.instrumentContext(ctx, opGet, key, func(st *internal.OpStats) error {
+		var err error
+		value, err = c.store.GetWithStats(key, st)
+		return err
+	})
+
+	return value, err
+}
+
+// Describe returns the metadata of the given key i.e. when it was created, which file holds its record
+// and the size of its value. It returns an ErrNotFound error if the key is nonexistent
+func (c *Ckydb) Describe(key string) (KeyInfo, error) {
+	key = c.normalizeKey(key)
+	c.mutLock.RLock()
+	defer c.mutLock.RUnlock()
+
+	var info KeyInfo
+	err := c.instrument(opDescribe, key, func(st *internal.OpStats) error {
+		var err error
+		info, err = c.store.DescribeWithStats(key, st)
+		return err
+	})
+
+	return info, err
+}
+
+// Exists checks whether the key is in the database by consulting only the in-memory index,
+// without reading any file, loading the cache or recording stats and traces. It is a fast path
+// for e.g. deduplicating millions of keys, which is only eventually accurate: a key whose data file
+// is lost or corrupted on disk still exists until the next Get of it fails
+func (c *Ckydb) Exists(key string) bool {
+	key = c.normalizeKey(key)
+	c.mutLock.RLock()
+	defer c.mutLock.RUnlock()
+
+	return c.checkState() == nil && c.store.Has(key)
+}
+
+// GetMany retrieves the values of the given keys at once, loading each data file at most once.
+// The result of each key is at the same index as the key. Missing keys are not found rather than
+// errors, telling them apart from keys with empty values
+func (c *Ckydb) GetMany(keys []string) []Result {
+	if c.keyNormalization != NormalizeNone {
+		normalized := make([]string, len(keys))
+		for i, key := range keys {
+			normalized[i] = c.normalizeKey(key)
+		}
+		keys = normalized
+	}
+
+	c.mutLock.RLock()
+	defer c.mutLock.RUnlock()
+
+	var results []Result
+	_ = c.instrument(opGetMany, "", func(st *internal.OpStats) error {
+		results = c.store.GetManyWithStats(keys, st)
+		for _, result := range results {
+			if result.Err != nil {
+				return result.Err
+			}
+		}
+
+		return nil
+	})
+
+	return results
 }
 
 // Delete removes the key-value pair corresponding to the passed key
 // It returns an ErrNotFound error if the key is nonexistent
 func (c *Ckydb) Delete(key string) error {
+	return c.DeleteContext(context.Background(), key)
+}
+
+// DeleteContext is Delete, recording the audit metadata of ctx in the audit log and tracing it within the span of ctx
+func (c *Ckydb) DeleteContext(ctx context.Context, key string) error {
+	key = c.normalizeKey(key)
+	c.mutLock.Lock()
+	defer c.mutLock.Unlock()
+
+	err := c.instrumentContext(ctx, opDelete, key, func(st *internal.OpStats) error {
+		return c.store.DeleteWithStats(key, st)
+	})
+	if err != nil {
+		return err
+	}
+
+	c.replicate(ctx, OpDelete, key, "")
+	return nil
+}
+
+// SetGet is like Set but it also returns the value that the key had before, and whether it existed,
+// with no other operation in between, instead of a Get followed by a Set that another writer can come between
+func (c *Ckydb) SetGet(key string, value string) (string, bool, error) {
+	key = c.normalizeKey(key)
+	c.mutLock.Lock()
+	defer c.mutLock.Unlock()
+
+	var old string
+	var existed bool
+	err := c.instrument(opSetGet, key, func(st *internal.OpStats) error {
+		var err error
+		old, err = c.store.GetWithStats(key, st)
+		existed = err == nil
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+
+		return c.store.SetWithStats(key, value, st)
+	})
+	if err != nil {
+		return "", false, err
+	}
+
+	c.replicate(context.Background(), OpSet, key, value)
+	return old, existed, nil
+}
+
+// SetIfAbsent is like Set but it only sets the key if it does not exist, reporting whether it did,
+// with no other operation in between, instead of a Get followed by a Set that another writer can come between
+func (c *Ckydb) SetIfAbsent(key string, value string) (bool, error) {
+	key = c.normalizeKey(key)
+	c.mutLock.Lock()
+	defer c.mutLock.Unlock()
+
+	var isSet bool
+	err := c.instrument(opSetIfAbsent, key, func(st *internal.OpStats) error {
+		if c.store.Has(key) {
+			return nil
+		}
+
+		isSet = true
+		return c.store.SetWithStats(key, value, st)
+	})
+	if err != nil || !isSet {
+		return false, err
+	}
+
+	c.replicate(context.Background(), OpSet, key, value)
+	return true, nil
+}
+
+// DeleteGet is like Delete but it also returns the value that the key had, with no other operation in between.
+// It returns an ErrNotFound error if the key is nonexistent
+func (c *Ckydb) DeleteGet(key string) (string, error) {
+	key = c.normalizeKey(key)
 	c.mutLock.Lock()
 	defer c.mutLock.Unlock()
 
-	return c.store.Delete(key)
+	var old string
+	err := c.instrument(opDeleteGet, key, func(st *internal.OpStats) error {
+		var err error
+		old, err = c.store.GetWithStats(key, st)
+		if err != nil {
+			return err
+		}
+
+		return c.store.DeleteWithStats(key, st)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	c.replicate(context.Background(), OpDelete, key, "")
+	return old, nil
+}
+
+// Copy sets dstKey to the value of srcKey, passed through transform if it is not nil, with no other
+// operation in between, e.g. to migrate the values of renamed keys to a new schema. The expiry of srcKey
+// is not copied. It returns an ErrNotFound error if srcKey is nonexistent
+func (c *Ckydb) Copy(srcKey string, dstKey string, transform func(string) string) error {
+	srcKey, dstKey = c.normalizeKey(srcKey), c.normalizeKey(dstKey)
+	c.mutLock.Lock()
+	defer c.mutLock.Unlock()
+
+	var value string
+	err := c.instrument(opCopy, srcKey, func(st *internal.OpStats) error {
+		var err error
+		value, err = c.store.GetWithStats(srcKey, st)
+		if err != nil {
+			return err
+		}
+
+		if transform != nil {
+			value = transform(value)
+		}
+		return c.store.SetWithStats(dstKey, value, st)
+	})
+	if err != nil {
+		return err
+	}
+
+	c.replicate(context.Background(), OpSet, dstKey, value)
+	return nil
+}
+
+// Swap exchanges the values of keyA and keyB with no other operation in between, e.g. to flip a pointer between
+// blue and green configurations. Should setting keyB fail, keyA is set back to its value. Like a Set, it removes
+// the expiries of both keys. It returns an ErrNotFound error if either key is nonexistent
+func (c *Ckydb) Swap(keyA string, keyB string) error {
+	keyA, keyB = c.normalizeKey(keyA), c.normalizeKey(keyB)
+	c.mutLock.Lock()
+	defer c.mutLock.Unlock()
+
+	var valueA, valueB string
+	err := c.instrument(opSwap, keyA, func(st *internal.OpStats) error {
+		var err error
+		valueA, err = c.store.GetWithStats(keyA, st)
+		if err != nil {
+			return err
+		}
+
+		valueB, err = c.store.GetWithStats(keyB, st)
+		if err != nil {
+			return err
+		}
+
+		err = c.store.SetWithStats(keyA, valueB, st)
+		if err != nil {
+			return err
+		}
+
+		err = c.store.SetWithStats(keyB, valueA, st)
+		if err != nil {
+			_ = c.store.SetWithStats(keyA, valueA, st)
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	c.replicate(context.Background(), OpSet, keyA, valueB)
+	c.replicate(context.Background(), OpSet, keyB, valueA)
+	return nil
 }
 
 // Clear resets the entire Store, and clears everything on disk
 func (c *Ckydb) Clear() error {
+	return c.ClearContext(context.Background())
+}
+
+// ClearContext is Clear, recording the audit metadata of ctx in the audit log and tracing it within the span of ctx
+func (c *Ckydb) ClearContext(ctx context.Context) error {
 	c.mutLock.Lock()
 	defer c.mutLock.Unlock()
 
-	return c.store.Clear()
+	err := c.instrumentContext(ctx, opClear, "", func(st *internal.OpStats) error {
+		return c.store.Clear()
+	})
+	if err != nil {
+		return err
+	}
+
+	c.replicate(ctx, OpClear, "", "")
+	return nil
+}
+
+// ClearData deletes all key-values like Clear, but keeps the files written when the database was created,
+// i.e. its format version, separators, value flags and the HMAC key check of WithRecordHMAC, and the metadata
+// segment of SetMeta. The database thus keeps the format it was created with instead of taking the one of the
+// options it was opened with. It is replicated as a Clear
+func (c *Ckydb) ClearData() error {
+	c.mutLock.Lock()
+	defer c.mutLock.Unlock()
+
+	err := c.instrument(opClearData, "", func(st *internal.OpStats) error {
+		return c.store.ClearData()
+	})
+	if err != nil {
+		return err
+	}
+
+	c.replicate(context.Background(), OpClear, "", "")
+	return nil
+}
+
+// All returns an iterator over all key-value pairs in the store, in ascending order of keys.
+// Keys deleted after the iteration started are skipped
+func (c *Ckydb) All() iter.Seq2[string, string] {
+	return c.Prefix("")
+}
+
+// Keys returns all keys in the database, in ascending order, without reading their values
+func (c *Ckydb) Keys() []string {
+	return c.keysWithPrefix("")
+}
+
+// Prefix returns an iterator over the key-value pairs whose keys start with the given prefix,
+// in ascending order of keys. Values are retrieved one at a time as the iteration proceeds
+func (c *Ckydb) Prefix(prefix string) iter.Seq2[string, string] {
+	return func(yield func(string, string) bool) {
+		for _, key := range c.keysWithPrefix(prefix) {
+			value, err := c.Get(key)
+			if err != nil {
+				continue
+			}
+
+			if !yield(key, value) {
+				return
+			}
+		}
+	}
+}
+
+// Find returns at most limit of the key-value pairs for which predicate returns true, for occasional lookups
+// by the contents of values. It reads every data file, one at a time, so that they never all have to fit in memory,
+// and holds the database locked until it is done. A limit of zero or less returns all of the pairs found
+func (c *Ckydb) Find(predicate func(key string, value string) bool, limit int) (map[string]string, error) {
+	c.mutLock.RLock()
+	defer c.mutLock.RUnlock()
+
+	var found map[string]string
+	err := c.instrument(opFind, "", func(st *internal.OpStats) error {
+		var err error
+		found, err = c.store.FindWithStats(predicate, limit, st)
+		return err
+	})
+
+	return found, err
+}
+
+// KeysByAge returns at most limit of the keys in the store, the oldest first if ascending and the newest first
+// otherwise, e.g. for retention jobs or to see what was ingested last. The age of a key is from when it was first
+// set, as updating it keeps its place. A limit of zero or less returns all of them
+func (c *Ckydb) KeysByAge(limit int, ascending bool) ([]string, error) {
+	c.mutLock.RLock()
+	defer c.mutLock.RUnlock()
+
+	var keys []string
+	err := c.instrument(opKeysByAge, "", func(st *internal.OpStats) error {
+		var err error
+		keys, err = c.store.KeysByAge(limit, ascending)
+		return err
+	})
+
+	return keys, err
+}
+
+// ScanRegex returns at most limit of the keys in the store that match the regular expression pattern, in ascending
+// order. The pattern is compiled once and matched against the keys in the index, without reading any value.
+// A limit of zero or less returns all of them. It returns an error if the pattern is not a valid regular expression
+func (c *Ckydb) ScanRegex(pattern string, limit int) ([]string, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mutLock.RLock()
+	defer c.mutLock.RUnlock()
+
+	var matches []string
+	err = c.instrument(opScanRegex, "", func(st *internal.OpStats) error {
+		for _, key := range c.store.Keys() {
+			if limit > 0 && len(matches) >= limit {
+				break
+			}
+
+			if re.MatchString(key) {
+				matches = append(matches, key)
+			}
+		}
+
+		return nil
+	})
+
+	return matches, err
+}
+
+// keysWithPrefix returns a snapshot of the sorted keys in the store that start with the given prefix
+func (c *Ckydb) keysWithPrefix(prefix string) []string {
+	prefix = c.normalizeKey(prefix)
+	if c.caseInsensitive {
+		prefix = strings.ToLower(prefix)
+	}
+	c.mutLock.RLock()
+	defer c.mutLock.RUnlock()
+
+	var keys []string
+	if c.State() == StateLoading {
+		keys, _ = c.store.KeysWhileLoading()
+	} else if c.checkState() == nil {
+		keys = c.store.Keys()
+	}
+
+	if prefix == "" || keys == nil {
+		return keys
+	}
+
+	matches := make([]string, 0)
+	for _, key := range keys {
+		if strings.HasPrefix(key, prefix) {
+			matches = append(matches, key)
+		}
+	}
+
+	return matches
 }
@@ -1,15 +1,34 @@
 package ckydb
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
+	"expvar"
 	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"maps"
+	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"testing"
 	"time"
 
+	"github.com/sopherapps/ckydb/implementations/go-ckydb/codec"
 	"github.com/sopherapps/ckydb/implementations/go-ckydb/internal"
 	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 func TestCkydb(t *testing.T) {
@@ -39,8 +58,8 @@ func TestCkydb(t *testing.T) {
 			_ = internal.ClearDummyFileDataInDb(dbPath)
 		}()
 
-		assert.Greater(t, len(db.tasks), 0)
-		for _, task := range db.tasks {
+		assert.Greater(t, len(db.getTasks()), 0)
+		for _, task := range db.getTasks() {
 			assert.True(t, task.IsRunning())
 		}
 	})
@@ -60,8 +79,8 @@ func TestCkydb(t *testing.T) {
 			_ = internal.ClearDummyFileDataInDb(dbPath)
 		}()
 
-		assert.Greater(t, len(db.tasks), 0)
-		for _, task := range db.tasks {
+		assert.Greater(t, len(db.getTasks()), 0)
+		for _, task := range db.getTasks() {
 			assert.True(t, task.IsRunning())
 		}
 	})
@@ -80,8 +99,8 @@ func TestCkydb(t *testing.T) {
 			t.Fatal(err)
 		}
 
-		assert.Greater(t, len(db.tasks), 0)
-		for _, task := range db.tasks {
+		assert.Greater(t, len(db.getTasks()), 0)
+		for _, task := range db.getTasks() {
 			assert.False(t, task.IsRunning())
 		}
 	})
@@ -324,129 +343,3153 @@ func TestCkydb(t *testing.T) {
 
 	t.Run("VacuumTaskRunsAtTheGivenInterval", func(t *testing.T) {
 		keyToDelete := "salut"
+		vacuumed := make(chan struct{}, 1)
+		db, err := connectToTestDb(dbPath, maxFileSizeKB*80, vacuumIntervalSec, WithOnOperation(func(op OpInfo) {
+			if op.Name == opVacuum {
+				select {
+				case vacuumed <- struct{}{}:
+				default:
+				}
+			}
+		}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+
+		for k, v := range testRecords {
+			err = db.Set(k, v)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		err = db.Delete(keyToDelete)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		idxFileContents, err := internal.ReadFilesWithExtension(dbPath, "idx")
+		if err != nil {
+			t.Fatal(err)
+		}
+		delFileContents, err := internal.ReadFilesWithExtension(dbPath, "del")
+		if err != nil {
+			t.Fatal(err)
+		}
+		logFileContents, err := internal.ReadFilesWithExtension(dbPath, "log")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the vacuum task ticks at the interval, so wait for its vacuum rather than for the interval to pass
+		select {
+		case <-vacuumed:
+		case <-time.After(2 * time.Second * time.Duration(vacuumIntervalSec)):
+			t.Fatal("the vacuum task did not run")
+		}
+
+		idxFileContentsAfterVacuum, err := internal.ReadFilesWithExtension(dbPath, "idx")
+		if err != nil {
+			t.Fatal(err)
+		}
+		delFileContentsAfterVacuum, err := internal.ReadFilesWithExtension(dbPath, "del")
+		if err != nil {
+			t.Fatal(err)
+		}
+		logFileContentsAfterVacuum, err := internal.ReadFilesWithExtension(dbPath, "log")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.NotContains(t, idxFileContents[0], keyToDelete)
+		assert.Contains(t, delFileContents[0], keyToDelete)
+		assert.Contains(t, logFileContents[0], keyToDelete)
+		assert.NotContains(t, idxFileContentsAfterVacuum[0], keyToDelete)
+		assert.NotContains(t, delFileContentsAfterVacuum[0], keyToDelete)
+		assert.NotContains(t, logFileContentsAfterVacuum[0], keyToDelete)
+	})
+
+	t.Run("LogFileShouldBeTurnedToCkyFileAfterItExceedsTheMaxFileSizeKB", func(t *testing.T) {
+		var preRollData []map[string]string
+		postRollData := map[string]string{
+			"hey": "English",
+			"hi":  "English",
+		}
+
+		err := internal.ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		db, err := Connect(dbPath, maxFileSizeKB, vacuumIntervalSec)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+
+		for i := 0; i < 3; i++ {
+			data := map[string]string{}
+
+			for k, v := range testRecords {
+				key := fmt.Sprintf("%s-%d", k, i)
+				data[key] = v
+
+				err := db.Set(key, v)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			preRollData = append(preRollData, data)
+		}
+
+		for k, v := range postRollData {
+			err = db.Set(k, v)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		ckyFileContentsAfterRoll, err := internal.ReadFilesWithExtension(dbPath, "cky")
+		if err != nil {
+			t.Fatal(err)
+		}
+		logFileContentsAfterRoll, err := internal.ReadFilesWithExtension(dbPath, "log")
+		if err != nil {
+			t.Fatal(err)
+		}
+		sort.Strings(ckyFileContentsAfterRoll)
+
+		assert.Equal(t, len(preRollData), len(ckyFileContentsAfterRoll))
+		for i, keySet := range preRollData {
+			for k, v := range keySet {
+				keyValuePair := fmt.Sprintf("%s%s%s", k, internal.KeyValueSeparator, v)
+				assert.Contains(t, ckyFileContentsAfterRoll[i], keyValuePair)
+			}
+		}
+
+		for k, v := range postRollData {
+			keyValuePair := fmt.Sprintf("%s%s%s", k, internal.KeyValueSeparator, v)
+			assert.Contains(t, logFileContentsAfterRoll[0], keyValuePair)
+		}
+	})
+
+	t.Run("AllShouldIterateOverAllKeyValuePairsInOrder", func(t *testing.T) {
+		expected := map[string]string{
+			"cow":  "500 months",
+			"dog":  "23 months",
+			"goat": "678 months",
+			"hen":  "567 months",
+			"pig":  "70 months",
+			"fish": "8990 months",
+		}
+		for k, v := range testRecords {
+			expected[k] = v
+		}
+
+		db, err := connectToTestDb(dbPath, maxFileSizeKB, vacuumIntervalSec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+
+		for k, v := range testRecords {
+			err = db.Set(k, v)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		actual := map[string]string{}
+		var keys []string
+		for k, v := range db.All() {
+			actual[k] = v
+			keys = append(keys, k)
+		}
+
+		assert.Equal(t, expected, actual)
+		assert.True(t, sort.StringsAreSorted(keys))
+	})
+
+	t.Run("PrefixShouldIterateOnlyOverKeysWithThePrefix", func(t *testing.T) {
+		db, err := connectToTestDb(dbPath, maxFileSizeKB, vacuumIntervalSec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+
+		for k, v := range testRecords {
+			err = db.Set(k, v)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		var keys []string
+		for k := range db.Prefix("h") {
+			keys = append(keys, k)
+			if len(keys) == 3 {
+				break
+			}
+		}
+
+		assert.Equal(t, []string{"hen", "hey", "hi"}, keys)
+	})
+
+	t.Run("StatsShouldCountOperationsErrorsAndCacheHits", func(t *testing.T) {
+		db, err := connectToTestDb(dbPath, maxFileSizeKB, vacuumIntervalSec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+
+		err = db.Set("hey", "English")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 2; i++ {
+			_, err = db.Get("cow")
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		_, _ = db.Get("non-existent")
+
+		stats := db.Stats()
+
+		assert.Equal(t, int64(1), stats.Ops[opSet])
+		assert.Equal(t, int64(3), stats.Ops[opGet])
+		assert.Equal(t, int64(1), stats.Errors[opGet])
+		assert.Equal(t, int64(0), stats.Errors[opSet])
+		assert.Equal(t, int64(1), stats.CacheHits)
+		assert.Equal(t, int64(1), stats.CacheMisses)
+		assert.Equal(t, 7, stats.Keys)
+		assert.Equal(t, 2, stats.DataFiles)
+	})
+
+	t.Run("WithExpvarShouldPublishStatsUnderThePrefix", func(t *testing.T) {
+		prefix := "ckydb_test"
+		db, err := connectToTestDb(dbPath, maxFileSizeKB, vacuumIntervalSec, WithExpvar(prefix))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+
+		err = db.Set("hey", "English")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		published := map[string]interface{}{}
+		err = json.Unmarshal([]byte(expvar.Get(prefix).String()), &published)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, 1.0, published["Ops"].(map[string]interface{})[opSet])
+		assert.Equal(t, 7.0, published["Keys"])
+	})
+
+	t.Run("WithTracerProviderShouldCreateSpansForOperations", func(t *testing.T) {
+		recorder := tracetest.NewSpanRecorder()
+		provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+		db, err := connectToTestDb(dbPath, maxFileSizeKB, vacuumIntervalSec, WithTracerProvider(provider))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+
+		err = db.Set("hey", "English")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = db.Get("cow")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = db.Get("non-existent")
+
+		spans := recorder.Ended()
+		spanNames := make([]string, len(spans))
+		for i, span := range spans {
+			spanNames[i] = span.Name()
+		}
+		setAttributes := attributesAsMap(spans[1].Attributes())
+		getAttributes := attributesAsMap(spans[2].Attributes())
+
+		assert.Equal(t, []string{"ckydb.load", "ckydb.set", "ckydb.get", "ckydb.get"}, spanNames)
+		assert.Equal(t, hashKey("hey"), setAttributes["ckydb.key_hash"].AsString())
+		assert.True(t, setAttributes["ckydb.log_rewrite"].AsBool())
+		assert.Greater(t, setAttributes["ckydb.bytes_written"].AsInt64(), int64(0))
+		assert.True(t, getAttributes["ckydb.cache_reload"].AsBool())
+		assert.False(t, getAttributes["ckydb.cache_hit"].AsBool())
+		assert.Contains(t, getAttributes["ckydb.files_touched"].AsStringSlice()[0], "1655375120328185000.cky")
+		assert.Equal(t, codes.Error, spans[3].Status().Code)
+	})
+
+	t.Run("WithTracerProviderShouldNestSpansInThoseOfTheContext", func(t *testing.T) {
+		recorder := tracetest.NewSpanRecorder()
+		provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+		db, err := connectToTestDb(dbPath, maxFileSizeKB, vacuumIntervalSec, WithTracerProvider(provider))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+
+		ctx, parent := provider.Tracer("test").Start(context.Background(), "request")
+		assert.Nil(t, db.SetContext(ctx, "hey", "English"))
+		_, err = db.GetContext(ctx, "hey")
+		assert.Nil(t, err)
+		assert.Nil(t, db.DeleteContext(ctx, "hey"))
+		assert.Nil(t, db.ClearContext(ctx))
+		parent.End()
+		_, err = db.Get("cow")
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		spans := recorder.Ended()
+		assert.Equal(t, 7, len(spans))
+		for _, span := range spans[1:5] {
+			assert.Equal(t, parent.SpanContext().SpanID(), span.Parent().SpanID(), span.Name())
+			assert.Equal(t, parent.SpanContext().TraceID(), span.SpanContext().TraceID(), span.Name())
+		}
+		assert.Equal(t, "request", spans[5].Name())
+		assert.False(t, spans[6].Parent().IsValid())
+	})
+
+	t.Run("WithSlowOpThresholdShouldLogOperationsSlowerThanTheThreshold", func(t *testing.T) {
+		logs := &bytes.Buffer{}
+		logger := log.New(logs, "", 0)
+
+		db, err := connectToTestDb(dbPath, maxFileSizeKB, vacuumIntervalSec,
+			WithLogger(logger), WithSlowOpThreshold(time.Nanosecond))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+
+		err = db.Set("hey", "English")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = db.Get("cow")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		logLines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+
+		assert.Equal(t, 3, len(logLines))
+		assert.Contains(t, logLines[0], "ckydb: slow load took")
+		assert.Contains(t, logLines[1], "ckydb: slow set took")
+		assert.Contains(t, logLines[1], fmt.Sprintf("key_hash=%s", hashKey("hey")))
+		assert.Contains(t, logLines[1], "log_rewrite=true")
+		assert.Contains(t, logLines[2], "ckydb: slow get took")
+		assert.Contains(t, logLines[2], "cache_reload=true")
+	})
+
+	t.Run("WithSlowOpThresholdShouldNotLogOperationsFasterThanTheThreshold", func(t *testing.T) {
+		logs := &bytes.Buffer{}
+		logger := log.New(logs, "", 0)
+
+		db, err := connectToTestDb(dbPath, maxFileSizeKB, vacuumIntervalSec,
+			WithLogger(logger), WithSlowOpThreshold(time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+
+		err = db.Set("hey", "English")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, "", logs.String())
+	})
+
+	t.Run("VacuumShouldDeleteMarkedKeysAtOnce", func(t *testing.T) {
+		db, err := connectToTestDb(dbPath, maxFileSizeKB, 3600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+
+		err = db.Delete("cow")
+		if err != nil {
+			t.Fatal(err)
+		}
+		delFileContents, err := internal.ReadFilesWithExtension(dbPath, "del")
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Contains(t, delFileContents[0], "cow")
+
+		err = db.Vacuum()
+		assert.Nil(t, err)
+		delFileContents, err = internal.ReadFilesWithExtension(dbPath, "del")
+		assert.Nil(t, err)
+		assert.Equal(t, []string{""}, delFileContents)
+		dataFileContents, err := internal.ReadFilesWithExtension(dbPath, "cky")
+		assert.Nil(t, err)
+		for _, content := range dataFileContents {
+			assert.NotContains(t, content, "-cow")
+		}
+		assert.Equal(t, int64(1), db.Stats().Ops[opVacuum])
+	})
+
+	t.Run("WithClockShouldPaceTheVacuumTask", func(t *testing.T) {
+		clock := internal.NewFakeClock(time.Now())
+		db, err := connectToTestDb(dbPath, maxFileSizeKB*80, vacuumIntervalSec, WithClock(clock))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+
+		err = db.Set("foo", "bar")
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = db.Delete("foo")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		delFileContents, err := internal.ReadFilesWithExtension(dbPath, "del")
+		assert.Nil(t, err)
+		assert.NotEqual(t, []string{""}, delFileContents)
+
+		clock.Advance(time.Second * time.Duration(vacuumIntervalSec))
+		assert.Eventually(t, func() bool {
+			delFileContents, err := internal.ReadFilesWithExtension(dbPath, "del")
+			return err == nil && len(delFileContents) == 1 && delFileContents[0] == ""
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("WithVacuumInitialDelayShouldVacuumAfterTheDelay", func(t *testing.T) {
+		clock := internal.NewFakeClock(time.Now())
+		db, err := connectToTestDb(dbPath, maxFileSizeKB*80, vacuumIntervalSec, WithClock(clock), WithVacuumInitialDelay(time.Millisecond))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+
+		err = db.Set("foo", "bar")
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = db.Delete("foo")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		clock.Advance(time.Millisecond)
+		assert.Eventually(t, func() bool {
+			delFileContents, err := internal.ReadFilesWithExtension(dbPath, "del")
+			return err == nil && len(delFileContents) == 1 && delFileContents[0] == ""
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("WithCompactionShouldCompactInTheBackgroundAndReportTasks", func(t *testing.T) {
+		start := time.Now()
+		clock := internal.NewFakeClock(start)
+		interval := time.Minute
+		db, err := connectToTestDb(dbPath, maxFileSizeKB*80, vacuumIntervalSec,
+			WithClock(clock), WithCompaction(CompactionPolicy{Interval: interval, MinDataFiles: 2}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+
+		tasks := db.Tasks()
+		assert.Equal(t, 2, len(tasks))
+		assert.Equal(t, "vacuum", tasks[0].Name)
+		assert.Equal(t, "compaction", tasks[1].Name)
+		assert.True(t, tasks[1].IsRunning)
+		assert.True(t, tasks[1].LastRun.IsZero())
+		assert.Equal(t, start.Add(interval), tasks[1].NextRun)
+		assert.Equal(t, 2, db.Stats().DataFiles)
+
+		clock.Advance(interval)
+		assert.Eventually(t, func() bool {
+			return db.Stats().Ops["compact"] == 1
+		}, time.Second, 10*time.Millisecond)
+
+		status := db.Tasks()[1]
+		assert.Equal(t, start.Add(interval), status.LastRun)
+		assert.Nil(t, status.LastError)
+		assert.Equal(t, 1, db.Stats().DataFiles)
+		value, err := db.Get("cow")
+		assert.Nil(t, err)
+		assert.Equal(t, "500 months", value)
+
+		err = db.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, status := range db.Tasks() {
+			assert.False(t, status.IsRunning)
+			assert.True(t, status.NextRun.IsZero())
+		}
+	})
+
+	t.Run("SetVacuumIntervalShouldRescheduleTheVacuumTask", func(t *testing.T) {
+		start := time.Now()
+		clock := internal.NewFakeClock(start)
+		db, err := connectToTestDb(dbPath, maxFileSizeKB*80, vacuumIntervalSec, WithClock(clock))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+
+		err = db.SetVacuumInterval(time.Hour)
+		assert.Nil(t, err)
+		assert.Eventually(t, func() bool {
+			return db.Tasks()[0].NextRun.Equal(start.Add(time.Hour))
+		}, time.Second, 10*time.Millisecond)
+		assert.Equal(t, ErrOutOfBounds, db.SetVacuumInterval(0))
+
+		// the new interval should survive a reopen
+		err = db.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = db.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, start.Add(time.Hour), db.Tasks()[0].NextRun)
+	})
+
+	t.Run("SetMaxFileSizeShouldChangeWhenTheLogFileIsRolled", func(t *testing.T) {
+		db, err := connectToTestDb(dbPath, maxFileSizeKB*80, vacuumIntervalSec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+		dataFiles := db.Stats().DataFiles
+
+		err = db.SetMaxFileSize(0.1)
+		assert.Nil(t, err)
+		err = db.Set("foo", "bar")
+		assert.Nil(t, err)
+
+		assert.Equal(t, dataFiles+1, db.Stats().DataFiles)
+		assert.Equal(t, ErrOutOfBounds, db.SetMaxFileSize(-1))
+	})
+
+	t.Run("WithOnOperationShouldBeCalledAfterEachOperation", func(t *testing.T) {
+		var ops []OpInfo
+		db, err := connectToTestDb(dbPath, maxFileSizeKB*80, vacuumIntervalSec, WithOnOperation(func(op OpInfo) {
+			ops = append(ops, op)
+		}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+
+		err = db.Set("foo", "bar")
+		assert.Nil(t, err)
+		_, err = db.Get("non-existent")
+		assert.Equal(t, ErrNotFound, err)
+
+		assert.Equal(t, 3, len(ops))
+		assert.Equal(t, "load", ops[0].Name)
+		assert.Equal(t, "set", ops[1].Name)
+		assert.Equal(t, "foo", ops[1].Key)
+		assert.Greater(t, ops[1].BytesWritten, int64(0))
+		assert.Greater(t, ops[1].Duration, time.Duration(0))
+		assert.Nil(t, ops[1].Err)
+		assert.Equal(t, "get", ops[2].Name)
+		assert.Equal(t, ErrNotFound, ops[2].Err)
+	})
+
+	t.Run("ExportJSONShouldNotBlockOrSeeWritesMadeDuringTheExport", func(t *testing.T) {
+		db, err := connectToTestDb(dbPath, maxFileSizeKB*8, vacuumIntervalSec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+
+		for i := 0; i < 100; i++ {
+			err = db.Set(fmt.Sprintf("key-%d", i), strings.Repeat(fmt.Sprintf("%d", i), 50))
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		expected := map[string]string{}
+		for k, v := range db.All() {
+			expected[k] = v
+		}
+
+		writes := 0
+		var exported bytes.Buffer
+		w := writerFunc(func(p []byte) (int, error) {
+			writes++
+			done := make(chan error, 1)
+			go func() {
+				done <- errors.Join(
+					db.Set(fmt.Sprintf("new-%d", writes), "value"),
+					db.Set("key-1", "updated"),
+					db.Delete(fmt.Sprintf("key-%d", writes*10)),
+					db.vacuum(),
+					db.Compact(),
+				)
+			}()
+
+			select {
+			case err := <-done:
+				assert.Nil(t, err)
+			case <-time.After(5 * time.Second):
+				t.Fatal("writes were blocked by the export")
+			}
+
+			return exported.Write(p)
+		})
+
+		err = db.ExportJSON(w)
+		assert.Nil(t, err)
+		assert.Greater(t, writes, 1)
+
+		got := map[string]string{}
+		err = json.Unmarshal(exported.Bytes(), &got)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, got)
+
+		value, err := db.Get("key-1")
+		assert.Nil(t, err)
+		assert.Equal(t, "updated", value)
+
+		snapshots, err := internal.GetFileOrFolderNamesInFolder(filepath.Join(dbPath, internal.SnapshotsFolderName))
+		assert.Nil(t, err)
+		assert.Empty(t, snapshots)
+	})
+
+	t.Run("ExportJSONOfEmptyDatabaseShouldBeEmptyObject", func(t *testing.T) {
+		db, err := Connect(dbPath, maxFileSizeKB, vacuumIntervalSec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+
+		var exported bytes.Buffer
+		err = db.ExportJSON(&exported)
+		assert.Nil(t, err)
+		assert.Equal(t, "{}", exported.String())
+	})
+
+	t.Run("ImportJSONShouldTreatExistingKeysAsThePolicyDictates", func(t *testing.T) {
+		exported := `{"existing":"imported","new-1":"value-1","new-2":"value-2"}`
+		testData := []struct {
+			policy   ImportPolicy
+			expected ImportResult
+			err      error
+			values   map[string]string
+		}{
+			{
+				policy:   Overwrite,
+				expected: ImportResult{Inserted: 2, Overwritten: 1},
+				values:   map[string]string{"existing": "imported", "new-1": "value-1", "new-2": "value-2"},
+			},
+			{
+				policy:   SkipExisting,
+				expected: ImportResult{Inserted: 2, Skipped: 1},
+				values:   map[string]string{"existing": "original", "new-1": "value-1", "new-2": "value-2"},
+			},
+			{
+				policy:   FailOnConflict,
+				expected: ImportResult{},
+				err:      ErrConflict,
+				values:   map[string]string{"existing": "original"},
+			},
+		}
+
+		for _, tr := range testData {
+			func() {
+				db, err := Connect(dbPath, maxFileSizeKB, vacuumIntervalSec)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer func() {
+					_ = db.Close()
+					_ = internal.ClearDummyFileDataInDb(dbPath)
+				}()
+				err = db.Set("existing", "original")
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				result, err := db.ImportJSON(strings.NewReader(exported), tr.policy)
+				assert.ErrorIs(t, err, tr.err)
+				assert.Equal(t, tr.expected, result)
+
+				got := map[string]string{}
+				for k, v := range db.All() {
+					got[k] = v
+				}
+				assert.Equal(t, tr.values, got)
+			}()
+		}
+	})
+
+	t.Run("ImportJSONShouldRoundTripExportJSON", func(t *testing.T) {
+		db, err := connectToTestDb(dbPath, maxFileSizeKB, vacuumIntervalSec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+		expected := map[string]string{}
+		for k, v := range db.All() {
+			expected[k] = v
+		}
+
+		var exported bytes.Buffer
+		err = db.ExportJSON(&exported)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = db.Clear()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		result, err := db.ImportJSON(&exported, FailOnConflict)
+		assert.Nil(t, err)
+		assert.Equal(t, ImportResult{Inserted: len(expected)}, result)
+		got := map[string]string{}
+		for k, v := range db.All() {
+			got[k] = v
+		}
+		assert.Equal(t, expected, got)
+
+		_, err = db.ImportJSON(strings.NewReader("not json"), Overwrite)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("ImportWithShouldRoundTripExportWithEveryCodec", func(t *testing.T) {
+		db, err := connectToTestDb(dbPath, maxFileSizeKB, vacuumIntervalSec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+		expected := map[string]string{}
+		for k, v := range db.All() {
+			expected[k] = v
+		}
+
+		for _, name := range codec.Names() {
+			c, err := codec.Get(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var exported bytes.Buffer
+			err = db.ExportWith(&exported, c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = db.Clear()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			result, err := db.ImportWith(&exported, c, FailOnConflict)
+			assert.Nil(t, err, name)
+			assert.Equal(t, ImportResult{Inserted: len(expected)}, result, name)
+			got := map[string]string{}
+			for k, v := range db.All() {
+				got[k] = v
+			}
+			assert.Equal(t, expected, got, name)
+		}
+	})
+
+	t.Run("TypedShouldSetAndGetValuesEncodedWithTheCodec", func(t *testing.T) {
+		db, err := connectToTestDb(dbPath, maxFileSizeKB, vacuumIntervalSec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+
+		type animal struct {
+			Name string
+			Age  int
+		}
+		for _, name := range codec.Names() {
+			c, err := codec.Get(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			animals := NewTyped[animal](db, c)
+			err = animals.Set("animal", animal{Name: "cow", Age: 500})
+			assert.Nil(t, err, name)
+			value, err := animals.Get("animal")
+			assert.Nil(t, err, name)
+			assert.Equal(t, animal{Name: "cow", Age: 500}, value, name)
+		}
+
+		raw, err := db.Get("animal")
+		assert.Nil(t, err)
+		decoded := animal{}
+		assert.Nil(t, codec.DecodeString(codec.Protobuf, raw, &decoded))
+
+		animals := NewTyped[animal](db, codec.JSON)
+		assert.Nil(t, animals.Delete("animal"))
+		_, err = animals.Get("animal")
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("WithReplicationSinkShouldForwardCommittedMutationsInOrder", func(t *testing.T) {
+		_ = internal.ClearDummyFileDataInDb(dbPath)
+		var ops []Op
+		sink := replicationSinkFunc(func(op Op) error {
+			ops = append(ops, op)
+			return nil
+		})
+
+		db, err := Connect(dbPath, maxFileSizeKB, vacuumIntervalSec, WithReplicationSink(sink, ReplicationPolicy{}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = internal.ClearDummyFileDataInDb(dbPath) }()
+
+		assert.Nil(t, db.Set("cow", "500 months"))
+		assert.Nil(t, db.Set("goat", "678 months"))
+		assert.Nil(t, db.Delete("cow"))
+		assert.ErrorIs(t, db.Delete("cow"), ErrNotFound)
+		_, err = db.Import(map[string]string{"hen": "567 months"}, Overwrite)
+		assert.Nil(t, err)
+		assert.Nil(t, db.Clear())
+		assert.Nil(t, db.Close())
+
+		expected := []Op{
+			{Type: OpSet, Key: "cow", Value: "500 months"},
+			{Type: OpSet, Key: "goat", Value: "678 months"},
+			{Type: OpDelete, Key: "cow"},
+			{Type: OpSet, Key: "hen", Value: "567 months"},
+			{Type: OpClear},
+		}
+		assert.Equal(t, len(expected), len(ops))
+		for i, op := range ops {
+			assert.False(t, op.Time.IsZero())
+			op.Time = time.Time{}
+			assert.Equal(t, expected[i], op)
+		}
+		assert.Equal(t, 0, db.Stats().ReplicationPending)
+	})
+
+	t.Run("WithReplicationSinkShouldRetryFailedApplies", func(t *testing.T) {
+		_ = internal.ClearDummyFileDataInDb(dbPath)
+		attempts := 0
+		var ops []Op
+		sink := replicationSinkFunc(func(op Op) error {
+			attempts++
+			if attempts <= 2 {
+				return errors.New("sink is down")
+			}
+
+			ops = append(ops, op)
+			return nil
+		})
+
+		db, err := Connect(dbPath, maxFileSizeKB, vacuumIntervalSec,
+			WithReplicationSink(sink, ReplicationPolicy{MaxRetries: 2, RetryBackoff: time.Millisecond}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = internal.ClearDummyFileDataInDb(dbPath) }()
+
+		assert.Nil(t, db.Set("cow", "500 months"))
+		assert.Nil(t, db.Close())
+
+		assert.Equal(t, 3, attempts)
+		assert.Equal(t, 1, len(ops))
+		assert.Equal(t, int64(0), db.Stats().ReplicationDropped)
+	})
+
+	t.Run("WithReplicationSinkShouldDropOpsThatFailEveryRetry", func(t *testing.T) {
+		_ = internal.ClearDummyFileDataInDb(dbPath)
+		logs := &bytes.Buffer{}
+		attempts := 0
+		sink := replicationSinkFunc(func(op Op) error {
+			attempts++
+			return errors.New("sink is down")
+		})
+
+		db, err := Connect(dbPath, maxFileSizeKB, vacuumIntervalSec, WithLogger(log.New(logs, "", 0)),
+			WithReplicationSink(sink, ReplicationPolicy{MaxRetries: 1, RetryBackoff: time.Millisecond}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = internal.ClearDummyFileDataInDb(dbPath) }()
+
+		assert.Nil(t, db.Set("cow", "500 months"))
+		assert.Nil(t, db.Delete("cow"))
+		assert.Nil(t, db.Close())
+
+		assert.Equal(t, 4, attempts)
+		assert.Equal(t, int64(2), db.Stats().ReplicationDropped)
+		assert.Contains(t, logs.String(), "error: dropped replication of set: sink is down")
+		assert.Contains(t, logs.String(), "error: dropped replication of delete: sink is down")
+	})
+
+	t.Run("WithReplicationSinkShouldNotRetryPermanentErrors", func(t *testing.T) {
+		_ = internal.ClearDummyFileDataInDb(dbPath)
+		attempts := 0
+		sink := replicationSinkFunc(func(op Op) error {
+			attempts++
+			return ErrInvalidKeyValue
+		})
+
+		db, err := Connect(dbPath, maxFileSizeKB, vacuumIntervalSec,
+			WithReplicationSink(sink, ReplicationPolicy{MaxRetries: 3, RetryBackoff: time.Millisecond}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = internal.ClearDummyFileDataInDb(dbPath) }()
+
+		assert.Nil(t, db.Set("cow", "500 months"))
+		assert.Nil(t, db.Close())
+
+		assert.Equal(t, 1, attempts)
+		assert.Equal(t, int64(1), db.Stats().ReplicationDropped)
+	})
+
+	t.Run("WithAuditLogShouldRecordEveryMutation", func(t *testing.T) {
+		auditLog := &bytes.Buffer{}
+		db, err := connectToTestDb(dbPath, maxFileSizeKB, vacuumIntervalSec, WithAuditLog(auditLog))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+
+		ctx := AuditContext(context.Background(), map[string]string{"user": "alice", "request_id": "1"})
+		assert.Nil(t, db.SetContext(AuditContext(ctx, map[string]string{"request_id": "2"}), "cow", "501 months"))
+		assert.Nil(t, db.DeleteContext(ctx, "dog"))
+		assert.NotNil(t, db.Delete("horse"))
+		_, err = db.Import(map[string]string{"pig": "71 months"}, Overwrite)
+		assert.Nil(t, err)
+		assert.Nil(t, db.Apply(Op{Type: OpSet, Key: "goat", Value: "679 months", Time: time.Now()}))
+		assert.Nil(t, db.ClearContext(ctx))
+
+		var records []AuditRecord
+		for _, line := range strings.Split(strings.TrimSpace(auditLog.String()), "\n") {
+			var record AuditRecord
+			assert.Nil(t, json.Unmarshal([]byte(line), &record))
+			assert.False(t, record.Time.IsZero())
+			record.Time = time.Time{}
+			records = append(records, record)
+		}
+		assert.Equal(t, []AuditRecord{
+			{Op: OpSet, Key: "cow", Metadata: map[string]string{"user": "alice", "request_id": "2"}},
+			{Op: OpDelete, Key: "dog", Metadata: map[string]string{"user": "alice", "request_id": "1"}},
+			{Op: OpSet, Key: "pig"},
+			{Op: OpSet, Key: "goat", Replicated: true},
+			{Op: OpClear, Metadata: map[string]string{"user": "alice", "request_id": "1"}},
+		}, records)
+	})
+
+	t.Run("WithHashedAuditKeysShouldNotRecordTheKeys", func(t *testing.T) {
+		auditLog := &bytes.Buffer{}
+		db, err := connectToTestDb(dbPath, maxFileSizeKB, vacuumIntervalSec, WithAuditLog(auditLog), WithHashedAuditKeys())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+
+		assert.Nil(t, db.Set("alice@example.com", "500 months"))
+		assert.NotContains(t, auditLog.String(), "alice")
+
+		var record AuditRecord
+		assert.Nil(t, json.Unmarshal(auditLog.Bytes(), &record))
+		assert.Equal(t, hashKey("alice@example.com"), record.KeyHash)
+		assert.Equal(t, "", record.Key)
+	})
+
+	t.Run("WithAuditLogShouldLogFailedWrites", func(t *testing.T) {
+		logs := &bytes.Buffer{}
+		db, err := connectToTestDb(dbPath, maxFileSizeKB, vacuumIntervalSec, WithLogger(log.New(logs, "", 0)),
+			WithAuditLog(writerFunc(func(p []byte) (int, error) { return 0, errors.New("disk is full") })))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+
+		assert.Nil(t, db.Set("cow", "501 months"))
+		value, err := db.Get("cow")
+		assert.Nil(t, err)
+		assert.Equal(t, "501 months", value)
+		assert.Contains(t, logs.String(), "error: failed to write audit record of set: disk is full")
+	})
+
+	t.Run("WithOplogShouldLetConsumersFollowMutationsAcrossRestarts", func(t *testing.T) {
+		_ = internal.ClearDummyFileDataInDb(dbPath)
+		db, err := Connect(dbPath, maxFileSizeKB, vacuumIntervalSec, WithOplog(true))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = internal.ClearDummyFileDataInDb(dbPath) }()
+
+		assert.Nil(t, db.Set("cow", "500 months"))
+		assert.Nil(t, db.Delete("cow"))
+		assert.Nil(t, db.Close())
+
+		var lastSeq uint64
+		var ops []Op
+		for entry, err := range db.ReadOplog(0) {
+			assert.Nil(t, err)
+			assert.Equal(t, lastSeq+1, entry.Seq)
+			lastSeq = entry.Seq
+			ops = append(ops, Op{Type: entry.Type, Key: entry.Key, Value: entry.Value})
+		}
+		assert.Equal(t, []Op{{Type: OpSet, Key: "cow", Value: "500 months"}, {Type: OpDelete, Key: "cow"}}, ops)
+
+		db, err = Connect(dbPath, maxFileSizeKB, vacuumIntervalSec, WithOplog(true))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Nil(t, db.Set("goat", "678 months"))
+		assert.Nil(t, db.Clear())
+		assert.Nil(t, db.Close())
+
+		var entries []OplogEntry
+		for entry, err := range ReadOplog(dbPath, lastSeq+1) {
+			assert.Nil(t, err)
+			entries = append(entries, entry)
+		}
+		assert.Equal(t, 2, len(entries))
+		assert.Equal(t, uint64(3), entries[0].Seq)
+		assert.Equal(t, Op{Type: OpSet, Key: "goat", Value: "678 months", Time: entries[0].Time}, entries[0].Op)
+		assert.Equal(t, uint64(4), entries[1].Seq)
+		assert.Equal(t, OpClear, entries[1].Type)
+	})
+
+	t.Run("GetManyShouldReturnTheResultOfEachKeyAtItsIndex", func(t *testing.T) {
+		db, err := connectToTestDb(dbPath, maxFileSizeKB, vacuumIntervalSec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+		err = db.Set("empty", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		results := db.GetMany([]string{"cow", "missing", "empty"})
+		assert.Equal(t, []Result{{Value: "500 months", Found: true}, {}, {Value: "", Found: true}}, results)
+		assert.Equal(t, int64(1), db.Stats().Ops["get_many"])
+		assert.Equal(t, int64(0), db.Stats().Errors["get_many"])
+	})
+
+	t.Run("ExistsShouldCheckTheIndexWithoutLoadingTheCache", func(t *testing.T) {
+		db, err := connectToTestDb(dbPath, maxFileSizeKB, vacuumIntervalSec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+
+		for _, k := range []string{"cow", "dog", "goat", "hen"} {
+			assert.True(t, db.Exists(k))
+		}
+		assert.False(t, db.Exists("bar"))
+		assert.False(t, db.Exists("missing"))
+		assert.Equal(t, int64(0), db.Stats().CacheLoads)
+
+		err = db.Delete("goat")
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.False(t, db.Exists("goat"))
+	})
+
+	t.Run("DescribeShouldReturnTheMetadataOfTheKey", func(t *testing.T) {
+		db, err := connectToTestDb(dbPath, maxFileSizeKB, vacuumIntervalSec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+
+		info, err := db.Describe("cow")
+		assert.Nil(t, err)
+		assert.Equal(t, KeyInfo{
+			CreatedAt:   time.Unix(0, 1655375120328185000),
+			File:        "1655375120328185000.cky",
+			Size:        len("500 months"),
+			OriginalKey: "cow",
+		}, info)
+
+		_, err = db.Describe("missing")
+		assert.ErrorIs(t, err, ErrNotFound)
+		assert.Equal(t, int64(2), db.Stats().Ops["describe"])
+		assert.Equal(t, int64(1), db.Stats().Errors["describe"])
+	})
+
+	t.Run("SetWithTTLShouldExpireTheKey", func(t *testing.T) {
+		clock := internal.NewFakeClock(time.Date(2022, 6, 16, 10, 0, 0, 0, time.UTC))
+		db, err := connectToTestDb(dbPath, maxFileSizeKB, vacuumIntervalSec, WithClock(clock))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+
+		err = db.SetWithTTL("foo", "bar", time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Nil(t, db.Expire("cow", time.Hour))
+
+		ttl, err := db.TTL("foo")
+		assert.Nil(t, err)
+		assert.Equal(t, time.Minute, ttl)
+
+		clock.Advance(time.Minute)
+		_, err = db.Get("foo")
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		assert.Nil(t, db.Persist("cow"))
+		ttl, err = db.TTL("cow")
+		assert.Nil(t, err)
+		assert.Equal(t, NoExpiry, ttl)
+		assert.Equal(t, int64(1), db.Stats().Ops["set_with_ttl"])
+	})
+
+	t.Run("WithMaxKeysShouldEvictKeysBeyondTheLimit", func(t *testing.T) {
+		db, err := connectToTestDb(dbPath, maxFileSizeKB, vacuumIntervalSec, WithMaxKeys(6, EvictLRU))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+
+		for _, k := range []string{"cow", "dog", "goat", "hen", "fish"} {
+			_, err = db.Get(k)
+			assert.Nil(t, err)
+		}
+
+		err = db.Set("foo", "bar")
+		assert.Nil(t, err)
+		assert.False(t, db.Exists("pig"))
+		assert.True(t, db.Exists("foo"))
+		assert.Equal(t, 6, db.Stats().Keys)
+		assert.Equal(t, int64(1), db.Stats().EvictedKeys)
+	})
+
+	t.Run("WithInvariantChecksShouldKeepReadsOfWritesAcrossLogRolls", func(t *testing.T) {
+		db, err := connectToTestDb(dbPath, 0.1, vacuumIntervalSec, WithInvariantChecks(true))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+
+		for i := 0; i < 50; i++ {
+			key, value := fmt.Sprintf("key-%d", i), fmt.Sprintf("value-%d", i)
+			err = db.Set(key, value)
+			assert.Nil(t, err)
+
+			got, err := db.Get(key)
+			assert.Nil(t, err)
+			assert.Equal(t, value, got)
+		}
+		assert.Less(t, 5, db.Stats().DataFiles)
+	})
+
+	t.Run("BackupToDirShouldMakeACopyThatCanBeConnectedTo", func(t *testing.T) {
+		backupPath, err := filepath.Abs("testBackupDb")
+		if err != nil {
+			t.Fatal(err)
+		}
+		db, err := connectToTestDb(dbPath, maxFileSizeKB, vacuumIntervalSec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+			_ = os.RemoveAll(backupPath)
+		}()
+
+		err = db.BackupToDir(backupPath)
+		assert.Nil(t, err)
+		err = db.Set("cow", "501 months")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		backup, err := Connect(backupPath, maxFileSizeKB, vacuumIntervalSec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = backup.Close() }()
+
+		value, err := backup.Get("cow")
+		assert.Nil(t, err)
+		assert.Equal(t, "500 months", value)
+		assert.Equal(t, db.Stats().Keys, backup.Stats().Keys)
+
+		err = db.BackupToDir(backupPath)
+		assert.ErrorIs(t, err, fs.ErrExist)
+	})
+
+	t.Run("ForkShouldShareDataFilesUntilTheyAreRewritten", func(t *testing.T) {
+		forkPath, err := filepath.Abs("testForkDb")
+		if err != nil {
+			t.Fatal(err)
+		}
+		db, err := connectToTestDb(dbPath, maxFileSizeKB, vacuumIntervalSec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+			_ = os.RemoveAll(forkPath)
+		}()
+
+		fork, err := db.Fork(forkPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = fork.Close() }()
+
+		// isShared checks whether the database and the fork share the data file holding cow
+		isShared := func() bool {
+			dataFile := "1655375120328185000.cky"
+			original, err := os.Stat(filepath.Join(dbPath, dataFile))
+			assert.Nil(t, err)
+			forked, err := os.Stat(filepath.Join(forkPath, dataFile))
+			assert.Nil(t, err)
+			return os.SameFile(original, forked)
+		}
+		assert.True(t, isShared())
+
+		err = fork.Set("cow", "501 months")
+		assert.Nil(t, err)
+		err = db.Set("goat", "679 months")
+		assert.Nil(t, err)
+		assert.False(t, isShared())
+
+		for k, v := range map[string]string{"cow": "500 months", "goat": "679 months"} {
+			value, err := db.Get(k)
+			assert.Nil(t, err)
+			assert.Equal(t, v, value)
+		}
+		for k, v := range map[string]string{"cow": "501 months", "goat": "678 months"} {
+			value, err := fork.Get(k)
+			assert.Nil(t, err)
+			assert.Equal(t, v, value)
+		}
+	})
+
+	t.Run("WithFollowerShouldRefreshFromTheFolderOfAnotherDatabase", func(t *testing.T) {
+		leader, err := connectToTestDb(dbPath, maxFileSizeKB, vacuumIntervalSec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = leader.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+
+		follower, err := Connect(dbPath, maxFileSizeKB, vacuumIntervalSec, WithFollower(10*time.Millisecond))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = follower.Close() }()
+
+		assert.ErrorIs(t, follower.Set("foo", "bar"), ErrFollower)
+		assert.Len(t, follower.Tasks(), 1)
+		assert.Equal(t, "refresh", follower.Tasks()[0].Name)
+
+		err = leader.Set("foo", "bar")
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Eventually(t, func() bool {
+			value, err := follower.Get("foo")
+			return err == nil && value == "bar"
+		}, time.Second, 10*time.Millisecond)
+		assert.Equal(t, int64(0), follower.Stats().Errors["refresh"])
+	})
+
+	t.Run("ApplyShouldKeepTheValueWrittenLast", func(t *testing.T) {
+		clock := internal.NewFakeClock(time.Date(2022, 6, 16, 11, 0, 0, 0, time.UTC))
+		db, err := connectToTestDb(dbPath, maxFileSizeKB, vacuumIntervalSec, WithClock(clock))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+		createdAt := time.Unix(0, 1655375120328185000)
+
+		// cow was not written since it was created
+		assert.Nil(t, db.Apply(Op{Type: OpSet, Key: "cow", Value: "older", Time: createdAt.Add(-time.Second)}))
+		assert.Nil(t, db.Apply(Op{Type: OpSet, Key: "cow", Value: "newer", Time: createdAt.Add(time.Second)}))
+		value, err := db.Get("cow")
+		assert.Nil(t, err)
+		assert.Equal(t, "newer", value)
+
+		// local writes after the first Apply are tracked
+		assert.Nil(t, db.Set("cow", "local"))
+		assert.Nil(t, db.Apply(Op{Type: OpSet, Key: "cow", Value: "remote", Time: clock.Now().Add(-time.Second)}))
+		assert.Nil(t, db.Apply(Op{Type: OpDelete, Key: "cow", Time: clock.Now().Add(-time.Second)}))
+		value, err = db.Get("cow")
+		assert.Nil(t, err)
+		assert.Equal(t, "local", value)
+
+		assert.Nil(t, db.Apply(Op{Type: OpDelete, Key: "cow", Time: clock.Now().Add(time.Second)}))
+		assert.False(t, db.Exists("cow"))
+
+		assert.Nil(t, db.Apply(Op{Type: OpSet, Key: "foo", Value: "bar", Time: clock.Now()}))
+		value, err = db.Get("foo")
+		assert.Nil(t, err)
+		assert.Equal(t, "bar", value)
+	})
+
+	t.Run("WithConflictResolverShouldMergeReplicatedValues", func(t *testing.T) {
+		sum := func(key, localVal, remoteVal string, localTs, remoteTs int64) string {
+			local, _ := strconv.Atoi(localVal)
+			remote, _ := strconv.Atoi(remoteVal)
+			return strconv.Itoa(local + remote)
+		}
+		db, err := connectToTestDb(dbPath, maxFileSizeKB, vacuumIntervalSec, WithConflictResolver(sum))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+
+		assert.Nil(t, db.Set("hits", "2"))
+		assert.Nil(t, db.Apply(Op{Type: OpSet, Key: "hits", Value: "3", Time: time.Now()}))
+		value, err := db.Get("hits")
+		assert.Nil(t, err)
+		assert.Equal(t, "5", value)
+	})
+
+	t.Run("CkydbShouldBeTheReplicationSinkOfAnother", func(t *testing.T) {
+		replicaPath, err := filepath.Abs("testReplicaDb")
+		if err != nil {
+			t.Fatal(err)
+		}
+		replica, err := connectToTestDb(replicaPath, maxFileSizeKB, vacuumIntervalSec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = replica.Close()
+			_ = internal.ClearDummyFileDataInDb(replicaPath)
+		}()
+
+		err = internal.ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		db, err := Connect(dbPath, maxFileSizeKB, vacuumIntervalSec, WithReplicationSink(replica, ReplicationPolicy{}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+
+		assert.Nil(t, db.Set("cow", "501 months"))
+		assert.Nil(t, db.Delete("cow"))
+		assert.Nil(t, db.Set("foo", "bar"))
+		assert.Eventually(t, func() bool { return replica.Exists("foo") }, time.Second, 10*time.Millisecond)
+		assert.False(t, replica.Exists("cow"))
+	})
+
+	t.Run("RingShouldSpreadKeysAcrossItsNodes", func(t *testing.T) {
+		nodes := make([]Controller, 3)
+		for i := range nodes {
+			nodePath := filepath.Join(dbPath, fmt.Sprintf("node-%d", i))
+			db, err := Connect(nodePath, maxFileSizeKB, vacuumIntervalSec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			nodes[i] = db
+		}
+		defer func() { _ = internal.ClearDummyFileDataInDb(dbPath) }()
+
+		ring, err := NewRing(nodes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = ring.Close() }()
+		for i := 0; i < 300; i++ {
+			err := ring.Set(fmt.Sprintf("key-%d", i), fmt.Sprintf("value-%d", i))
+			assert.Nil(t, err)
+		}
+
+		for i, node := range nodes {
+			keys := node.(*Ckydb).keysWithPrefix("")
+			assert.Less(t, 50, len(keys), "node %d", i)
+			for _, key := range keys {
+				assert.Equal(t, node, ring.NodeFor(key))
+			}
+		}
+
+		for i := 0; i < 300; i++ {
+			value, err := ring.Get(fmt.Sprintf("key-%d", i))
+			assert.Nil(t, err)
+			assert.Equal(t, fmt.Sprintf("value-%d", i), value)
+		}
+
+		assert.Nil(t, ring.Delete("key-0"))
+		_, err = ring.Get("key-0")
+		assert.ErrorIs(t, err, ErrNotFound)
+		assert.Nil(t, ring.Clear())
+		for _, node := range nodes {
+			assert.Empty(t, node.(*Ckydb).keysWithPrefix(""))
+		}
+	})
+
+	t.Run("RingAddNodeShouldOnlyMoveTheKeysOfTheNewNode", func(t *testing.T) {
+		connectNode := func(i int) *Ckydb {
+			db, err := Connect(filepath.Join(dbPath, fmt.Sprintf("node-%d", i)), maxFileSizeKB, vacuumIntervalSec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return db
+		}
+		defer func() { _ = internal.ClearDummyFileDataInDb(dbPath) }()
+
+		ring, err := NewRing([]Controller{connectNode(0), connectNode(1)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = ring.Close() }()
+		owners := map[string]Controller{}
+		for i := 0; i < 300; i++ {
+			key := fmt.Sprintf("key-%d", i)
+			assert.Nil(t, ring.Set(key, fmt.Sprintf("value-%d", i)))
+			owners[key] = ring.NodeFor(key)
+		}
+
+		newNode := connectNode(2)
+		moved, err := ring.AddNode(newNode)
+		assert.Nil(t, err)
+		assert.Less(t, 50, moved)
+		assert.Equal(t, moved, len(newNode.keysWithPrefix("")))
+
+		for i := 0; i < 300; i++ {
+			key := fmt.Sprintf("key-%d", i)
+			owner := ring.NodeFor(key)
+			if owner != Controller(newNode) {
+				assert.Equal(t, owners[key], owner)
+			}
+
+			value, err := owner.Get(key)
+			assert.Nil(t, err)
+			assert.Equal(t, fmt.Sprintf("value-%d", i), value)
+		}
+
+		moved, err = ring.Rebalance()
+		assert.Nil(t, err)
+		assert.Equal(t, 0, moved)
+	})
+
+	t.Run("HistoryModeShouldRetainPreviousVersionsOfKeys", func(t *testing.T) {
+		clock := internal.NewFakeClock(time.Date(2022, 6, 16, 10, 0, 0, 0, time.UTC))
+		db, err := connectToTestDb(dbPath, maxFileSizeKB, 3600, WithClock(clock), WithHistory(HistoryPolicy{MaxVersions: 3}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+
+		setAt := clock.Now()
+		err = db.Set("cow", "501 months")
+		if err != nil {
+			t.Fatal(err)
+		}
+		clock.Advance(time.Minute)
+		err = db.Delete("cow")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		value, err := db.GetVersion("cow", setAt)
+		assert.Nil(t, err)
+		assert.Equal(t, "501 months", value)
+		_, err = db.GetVersion("cow", clock.Now())
+		assert.ErrorIs(t, err, ErrNotFound)
+		versions, err := db.History("cow", 10)
+		assert.Nil(t, err)
+		assert.Len(t, versions, 2)
+		assert.True(t, versions[0].IsDeleted)
+		assert.Equal(t, "501 months", versions[1].Value)
+		assert.Equal(t, int64(2), db.Stats().Ops[opGetVersion])
+	})
+
+	t.Run("UndeleteShouldRestoreKeysDeletedInTrashMode", func(t *testing.T) {
+		db, err := connectToTestDb(dbPath, maxFileSizeKB, 3600, WithTrash(time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+
+		err = db.Delete("cow")
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = db.Vacuum()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = db.Undelete("cow")
+		assert.Nil(t, err)
+		value, err := db.Get("cow")
+		assert.Nil(t, err)
+		assert.Equal(t, "500 months", value)
+		err = db.Undelete("cow")
+		assert.ErrorIs(t, err, ErrNotFound)
+		assert.Equal(t, int64(2), db.Stats().Ops[opUndelete])
+	})
+
+	t.Run("CopyShouldSetTheTransformedValueOfTheSourceKey", func(t *testing.T) {
+		db, err := connectToTestDb(dbPath, maxFileSizeKB, 3600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+
+		err = db.Copy("cow", "cattle", strings.ToUpper)
+		assert.Nil(t, err)
+		value, err := db.Get("cattle")
+		assert.Nil(t, err)
+		assert.Equal(t, "500 MONTHS", value)
+		value, err = db.Get("cow")
+		assert.Nil(t, err)
+		assert.Equal(t, "500 months", value)
+
+		err = db.Copy("dog", "puppy", nil)
+		assert.Nil(t, err)
+		value, err = db.Get("puppy")
+		assert.Nil(t, err)
+		assert.Equal(t, "23 months", value)
+
+		err = db.Copy("horse", "pony", nil)
+		assert.ErrorIs(t, err, ErrNotFound)
+		assert.False(t, db.Exists("pony"))
+		assert.Equal(t, int64(3), db.Stats().Ops[opCopy])
+	})
+
+	t.Run("MaintenanceThrottleShouldLetForegroundOperationsRunDuringPauses", func(t *testing.T) {
+		throttle := MaintenanceThrottle{PauseBetweenFiles: 50 * time.Millisecond}
+		db, err := connectToTestDb(dbPath, maxFileSizeKB, 3600, WithMaintenanceThrottle(throttle))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+
+		// without foreground operations, there is no pause
+		db.lastForegroundOp.Store(0)
+		db.mutLock.Lock()
+		start := time.Now()
+		db.pauseMaintenance(0)
+		assert.Less(t, time.Since(start), throttle.PauseBetweenFiles)
+		db.mutLock.Unlock()
+
+		_, err = db.Get("cow")
+		if err != nil {
+			t.Fatal(err)
+		}
+		db.mutLock.Lock()
+		done := make(chan struct{})
+		go func() {
+			_, _ = db.Get("dog")
+			close(done)
+		}()
+		db.pauseMaintenance(0)
+		select {
+		case <-done:
+		default:
+			t.Error("get did not run during the pause")
+		}
+		db.mutLock.Unlock()
+
+		err = db.Delete("cow")
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = db.vacuum()
+		assert.Nil(t, err)
+		delFileContents, err := internal.ReadFilesWithExtension(dbPath, "del")
+		assert.Nil(t, err)
+		assert.Equal(t, []string{""}, delFileContents)
+	})
+
+	t.Run("CustomEnginesShouldRunUnderTheController", func(t *testing.T) {
+		err := internal.ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ops []OpInfo
+		db, err := Connect(dbPath, maxFileSizeKB, 3600, WithEngine(NewMemoryEngine()), WithOnOperation(func(op OpInfo) {
+			ops = append(ops, op)
+		}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = db.Close() }()
+
+		err = db.Set("cow", "500 months")
+		assert.Nil(t, err)
+		err = db.Set("dog", "23 months")
+		assert.Nil(t, err)
+		value, err := db.Get("cow")
+		assert.Nil(t, err)
+		assert.Equal(t, "500 months", value)
+		assert.Equal(t, []Result{{Value: "23 months", Found: true}, {}}, db.GetMany([]string{"dog", "goat"}))
+		assert.Equal(t, map[string]string{"cow": "500 months", "dog": "23 months"}, maps.Collect(db.All()))
+
+		err = db.Delete("cow")
+		assert.Nil(t, err)
+		_, err = db.Get("cow")
+		assert.ErrorIs(t, err, ErrNotFound)
+		assert.False(t, db.Exists("cow"))
+		assert.Nil(t, db.Vacuum())
+		assert.Equal(t, 1, db.Stats().Keys)
+
+		err = db.SetWithTTL("cow", "500 months", time.Minute)
+		assert.ErrorIs(t, err, ErrUnsupportedByEngine)
+		_, err = os.Stat(dbPath)
+		assert.True(t, os.IsNotExist(err))
+		assert.NotEmpty(t, ops)
+	})
+
+	t.Run("RingShouldRouteKeysByItsPartitioner", func(t *testing.T) {
+		nodes := make([]Controller, 3)
+		for i := range nodes {
+			db, err := Connect("", 0, vacuumIntervalSec, WithEngine(NewMemoryEngine()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			nodes[i] = db
+		}
+
+		partitioner := &PrefixPartitioner{Separator: ":", Routes: map[string]int{"eu": 0, "us": 1}}
+		ring, err := NewRing(nodes, WithPartitioner(partitioner))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = ring.Close() }()
+		for _, key := range []string{"eu:orders:1", "us:orders:1", "tenant7:a", "tenant7:b", "tenant7"} {
+			err := ring.Set(key, "value")
+			assert.Nil(t, err)
+		}
+
+		assert.Equal(t, []string{"eu:orders:1"}, nodes[0].(*Ckydb).keysWithPrefix("eu:"))
+		assert.Equal(t, []string{"us:orders:1"}, nodes[1].(*Ckydb).keysWithPrefix("us:"))
+		tenantNode := ring.NodeFor("tenant7:a").(*Ckydb)
+		assert.Equal(t, []string{"tenant7", "tenant7:a", "tenant7:b"}, tenantNode.keysWithPrefix("tenant7"))
+
+		last, err := NewRing(nodes, WithPartitioner(PartitionerFunc(func(key string, n int) int { return -1 })))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, nodes[2], last.NodeFor("eu:orders:1"))
+	})
+
+	t.Run("ValueCompressionShouldShrinkLargeValuesOfNewDatabases", func(t *testing.T) {
+		err := internal.ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		db, err := Connect(dbPath, maxFileSizeKB, 3600, WithValueCompressionThreshold(64))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+
+		blob := strings.Repeat("a value that compresses well ", 100)
+		err = db.Set("blob", blob)
+		assert.Nil(t, err)
+		err = db.Set("cow", "500 months")
+		assert.Nil(t, err)
+
+		value, err := db.Get("blob")
+		assert.Nil(t, err)
+		assert.Equal(t, blob, value)
+		value, err = db.Get("cow")
+		assert.Nil(t, err)
+		assert.Equal(t, "500 months", value)
+
+		logContents, err := internal.ReadFilesWithExtension(dbPath, internal.LogFileExt)
+		assert.Nil(t, err)
+		assert.NotContains(t, strings.Join(logContents, ""), blob)
+	})
+
+	t.Run("ValueDeduplicationShouldStoreIdenticalLargeValuesOnce", func(t *testing.T) {
+		err := internal.ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		db, err := Connect(dbPath, maxFileSizeKB, 3600, WithValueDeduplication(64))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+
+		blob := strings.Repeat("a value stored by many keys ", 100)
+		for _, key := range []string{"cow", "dog"} {
+			err = db.Set(key, blob)
+			assert.Nil(t, err)
+		}
+
+		blobs, err := internal.GetFileOrFolderNamesInFolder(filepath.Join(dbPath, internal.BlobsFolderName))
+		assert.Nil(t, err)
+		assert.Len(t, blobs, 1)
+
+		err = db.Delete("cow")
+		assert.Nil(t, err)
+		err = db.Vacuum()
+		assert.Nil(t, err)
+		value, err := db.Get("dog")
+		assert.Nil(t, err)
+		assert.Equal(t, blob, value)
+		blobs, err = internal.GetFileOrFolderNamesInFolder(filepath.Join(dbPath, internal.BlobsFolderName))
+		assert.Nil(t, err)
+		assert.Len(t, blobs, 1)
+	})
+
+	t.Run("BlobSpillShouldKeepLargeValuesOutOfTheLogFile", func(t *testing.T) {
+		err := internal.ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		db, err := Connect(dbPath, maxFileSizeKB, 3600, WithBlobSpillThreshold(1024))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+
+		blob := strings.Repeat("a very large value ", 1000)
+		err = db.Set("cow", blob)
+		assert.Nil(t, err)
+		value, err := db.Get("cow")
+		assert.Nil(t, err)
+		assert.Equal(t, blob, value)
+
+		logContents, err := internal.ReadFilesWithExtension(dbPath, internal.LogFileExt)
+		assert.Nil(t, err)
+		assert.NotContains(t, strings.Join(logContents, ""), blob)
+		blobs, err := internal.GetFileOrFolderNamesInFolder(filepath.Join(dbPath, internal.BlobsFolderName))
+		assert.Nil(t, err)
+		assert.Len(t, blobs, 1)
+	})
+
+	t.Run("SetReaderAndGetReaderShouldStreamValues", func(t *testing.T) {
+		err := internal.ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		db, err := Connect(dbPath, maxFileSizeKB, 3600, WithBlobSpillThreshold(1<<20))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+
+		blob := strings.Repeat("a streamed value ", 1000)
+		err = db.SetReader("cow", strings.NewReader(blob))
+		assert.Nil(t, err)
+
+		r, err := db.GetReader("cow")
+		assert.Nil(t, err)
+		data, err := io.ReadAll(r)
+		assert.Nil(t, err)
+		assert.Nil(t, r.Close())
+		assert.Equal(t, blob, string(data))
+
+		_, err = db.GetReader("goat")
+		assert.ErrorIs(t, err, ErrNotFound)
+		assert.Equal(t, int64(1), db.Stats().Ops[opSetReader])
+		assert.Equal(t, int64(2), db.Stats().Ops[opGetReader])
+	})
+
+	t.Run("RecordHMACShouldReportTamperedRecords", func(t *testing.T) {
+		err := internal.ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		key := []byte("a secret key")
+		db, err := Connect(dbPath, maxFileSizeKB, 3600, WithRecordHMAC(key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+
+		err = db.Set("cow", "500 months")
+		assert.Nil(t, err)
+		tampered, err := db.Verify()
+		assert.Nil(t, err)
+		assert.Empty(t, tampered)
+		err = db.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		logFiles, err := filepath.Glob(filepath.Join(dbPath, "*."+internal.LogFileExt))
+		if err != nil || len(logFiles) != 1 {
+			t.Fatal(logFiles, err)
+		}
+		data, err := os.ReadFile(logFiles[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(logFiles[0], bytes.Replace(data, []byte("500 months"), []byte("999 months"), 1), 0666)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = Connect(dbPath, maxFileSizeKB, 3600, WithRecordHMAC([]byte("another key")))
+		assert.ErrorIs(t, err, ErrInvalidHMACKey)
+
+		db, err = Connect(dbPath, maxFileSizeKB, 3600, WithRecordHMAC(key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = db.Get("cow")
+		assert.ErrorIs(t, err, ErrTamperedRecord)
+		tampered, err = db.Verify()
+		assert.Nil(t, err)
+		assert.Equal(t, []TamperedRecord{{Key: "cow", File: filepath.Base(logFiles[0])}}, tampered)
+	})
+
+	t.Run("SetMetaShouldKeepMetadataOutOfTheKeysAndExports", func(t *testing.T) {
+		db, err := connectToTestDb(dbPath, maxFileSizeKB, vacuumIntervalSec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+		expected := map[string]string{}
+		for k, v := range db.All() {
+			expected[k] = v
+		}
+
+		assert.Nil(t, db.SetMeta("schema_version", "2"))
+		value, err := db.GetMeta("schema_version")
+		assert.Nil(t, err)
+		assert.Equal(t, "2", value)
+
+		got := map[string]string{}
+		for k, v := range db.All() {
+			got[k] = v
+		}
+		assert.Equal(t, expected, got)
+		var exported bytes.Buffer
+		assert.Nil(t, db.ExportJSON(&exported))
+		assert.NotContains(t, exported.String(), "schema_version")
+
+		assert.Nil(t, db.Close())
+		db, err = Connect(dbPath, maxFileSizeKB, vacuumIntervalSec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		value, err = db.GetMeta("schema_version")
+		assert.Nil(t, err)
+		assert.Equal(t, "2", value)
+
+		assert.Nil(t, db.DeleteMeta("schema_version"))
+		_, err = db.GetMeta("schema_version")
+		assert.ErrorIs(t, err, ErrNotFound)
+		assert.Equal(t, int64(2), db.Stats().Ops[opGetMeta])
+	})
+
+	t.Run("MigrateFormatToShouldLetKeysAndValuesContainTheSeparators", func(t *testing.T) {
+		db, err := connectToTestDb(dbPath, maxFileSizeKB, vacuumIntervalSec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+		odd := "cow" + DefaultSeparators.Token + DefaultSeparators.KeyValue
+
+		assert.ErrorIs(t, db.Set(odd, "moo"), ErrInvalidKeyValue)
+		assert.Nil(t, db.MigrateFormatTo(2))
+		assert.Nil(t, db.Set(odd, odd))
+		value, err := db.Get(odd)
+		assert.Nil(t, err)
+		assert.Equal(t, odd, value)
+		value, err = db.Get("cow")
+		assert.Nil(t, err)
+		assert.Equal(t, "500 months", value)
+		assert.Equal(t, int64(1), db.Stats().Ops[opMigrate])
+
+		assert.Nil(t, db.Close())
+		err = MigrateFormatTo(dbPath, 1)
+		assert.ErrorIs(t, err, ErrInvalidKeyValue)
+		version, err := ReadFormatVersion(dbPath)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, version)
+	})
+
+	t.Run("StateShouldFollowTheLifecycleOfTheDatabase", func(t *testing.T) {
+		_ = internal.AddDummyFileDataInDb(dbPath)
+		db, err := newCkydb(dbPath, maxFileSizeKB, vacuumIntervalSec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+
+		assert.Equal(t, StateNew, db.State())
+		_, err = db.Get("cow")
+		assert.ErrorIs(t, err, ErrNotOpened)
+		assert.False(t, db.Exists("cow"))
+
+		assert.Nil(t, db.Open())
+		assert.Equal(t, StateOpen, db.State())
+		value, err := db.Get("cow")
+		assert.Nil(t, err)
+		assert.Equal(t, "500 months", value)
+
+		assert.Nil(t, db.Close())
+		assert.Equal(t, StateClosed, db.State())
+		assert.Equal(t, "closed", db.State().String())
+		_, err = db.Get("cow")
+		assert.ErrorIs(t, err, ErrDatabaseClosed)
+		assert.ErrorIs(t, db.Set("cow", "501 months"), ErrDatabaseClosed)
+		assert.ErrorIs(t, db.Vacuum(), ErrDatabaseClosed)
+		_, err = db.Snapshot()
+		assert.ErrorIs(t, err, ErrDatabaseClosed)
+		_, err = db.Import(map[string]string{}, Overwrite)
+		assert.ErrorIs(t, err, ErrDatabaseClosed)
+		assert.False(t, db.Exists("cow"))
+		for range db.All() {
+			t.Fatal("closed databases should have no keys to iterate over")
+		}
+		assert.Nil(t, db.Close())
+		assert.Equal(t, 6, db.Stats().Keys)
+
+		assert.Nil(t, db.Open())
+		assert.Equal(t, StateOpen, db.State())
+		value, err = db.Get("cow")
+		assert.Nil(t, err)
+		assert.Equal(t, "500 months", value)
+	})
+
+	t.Run("CloseShouldWaitForTheOperationsUnderWay", func(t *testing.T) {
 		db, err := connectToTestDb(dbPath, maxFileSizeKB*80, vacuumIntervalSec)
 		if err != nil {
 			t.Fatal(err)
 		}
+		defer func() { _ = internal.ClearDummyFileDataInDb(dbPath) }()
+
+		var wg sync.WaitGroup
+		var closed atomic.Bool
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for j := 0; ; j++ {
+					wasClosed := closed.Load()
+					err := db.Set(fmt.Sprintf("key-%d-%d", i, j), "value")
+					if err != nil {
+						assert.ErrorIs(t, err, ErrDatabaseClosed)
+						return
+					}
+					assert.False(t, wasClosed, "no write should succeed after Close returns")
+				}
+			}(i)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+		assert.Nil(t, db.Close())
+		closed.Store(true)
+		wg.Wait()
+	})
+
+	t.Run("StatsShouldSummarizeTheLatencyOfEachOperation", func(t *testing.T) {
+		db, err := connectToTestDb(dbPath, maxFileSizeKB, vacuumIntervalSec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+
+		for i := 0; i < 10; i++ {
+			_, err = db.Get("cow")
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		_, _ = db.Get("non-existent")
+		err = db.Set("hey", "English")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		stats := db.Stats()
+
+		for op, count := range map[string]int64{opGet: 11, opSet: 1} {
+			latency := stats.Latency[op]
+			assert.Equal(t, count, latency.Count, op)
+			assert.True(t, latency.P50 > 0, op)
+			assert.True(t, latency.P50 <= latency.P90 && latency.P90 <= latency.P99 && latency.P99 <= latency.P999, op)
+			assert.True(t, latency.P999 <= latency.Max && latency.Mean <= latency.Max, op)
+		}
+		_, ok := stats.Latency[opDelete]
+		assert.False(t, ok)
+	})
+
+	t.Run("LatencyHistogramShouldBeWithinASixteenthOfTheLatencies", func(t *testing.T) {
+		h := &latencyHistogram{}
+		for i := 1; i <= 100000; i++ {
+			h.record(time.Duration(i) * time.Microsecond)
+		}
+		h.record(time.Hour)
+
+		stats := h.stats()
+
+		assert.Equal(t, int64(100001), stats.Count)
+		assert.Equal(t, time.Hour, stats.Max)
+		for q, expected := range map[float64]time.Duration{0.5: 50 * time.Millisecond, 0.9: 90 * time.Millisecond, 0.99: 99 * time.Millisecond} {
+			got := h.quantile(q)
+			assert.True(t, got >= expected && got <= expected+expected/16, q, got)
+		}
+		assert.Equal(t, time.Duration(0), (&latencyHistogram{}).quantile(0.5))
+		assert.Equal(t, time.Hour, h.quantile(1))
+	})
+
+	t.Run("WithIndexBatchingShouldAppendTheIndexEntriesOnTheMaxDelayAndClose", func(t *testing.T) {
+		clock := internal.NewFakeClock(time.Now())
+		db, err := connectToTestDb(dbPath, maxFileSizeKB, vacuumIntervalSec,
+			WithClock(clock), WithIndexBatching(IndexBatchPolicy{MaxKeys: 100, MaxDelay: time.Minute}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+		indexFilePath := filepath.Join(dbPath, internal.IndexFilename)
+		isIndexed := func(key string) bool {
+			data, err := os.ReadFile(indexFilePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return strings.Contains(string(data), key+internal.KeyValueSeparator)
+		}
+
+		tasks := db.Tasks()
+		assert.Equal(t, 2, len(tasks))
+		assert.Equal(t, "index_flush", tasks[1].Name)
+
+		err = db.Set("hey", "English")
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.False(t, isIndexed("hey"))
+		value, err := db.Get("hey")
+		assert.Nil(t, err)
+		assert.Equal(t, "English", value)
+
+		clock.Advance(time.Minute)
+		assert.Eventually(t, func() bool {
+			return db.Stats().Ops[opFlush] == 1
+		}, time.Second, 10*time.Millisecond)
+		assert.True(t, isIndexed("hey"))
+
+		err = db.Set("hi", "Swahili")
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.False(t, isIndexed("hi"))
+		err = db.Close()
+		assert.Nil(t, err)
+		assert.True(t, isIndexed("hi"))
+	})
+
+	t.Run("StatsShouldReportTheReadsAndWritesOfEachFile", func(t *testing.T) {
+		db, err := connectToTestDb(dbPath, maxFileSizeKB*80, vacuumIntervalSec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+
+		_, err = db.Get("cow")
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = db.Set("hey", "English")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		files := db.Stats().Files
+		assert.Equal(t, 3, len(files))
+		assert.Equal(t, "1655375120328185000.cky", files[0].Name)
+		assert.Equal(t, int64(1), files[0].Reads)
+		assert.Equal(t, int64(0), files[0].Writes)
+		assert.Equal(t, "1655375171402014000.log", files[2].Name)
+		assert.Equal(t, int64(1), files[2].Writes)
+		assert.False(t, files[2].LastWrite.IsZero())
+	})
+
+	t.Run("WithStrictLoadFalseShouldSkipAndLogMalformedRecords", func(t *testing.T) {
+		defer func() { _ = internal.ClearDummyFileDataInDb(dbPath) }()
+		err := internal.ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = internal.AddDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		logFilePath := filepath.Join(dbPath, "1655375171402014000.log")
+		info, err := os.Stat(logFilePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f, err := os.OpenFile(logFilePath, os.O_APPEND|os.O_WRONLY, 0666)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = f.WriteString("garbage" + internal.TokenSeparator)
+		_ = f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = Connect(dbPath, maxFileSizeKB, vacuumIntervalSec)
+		assert.Equal(t, ErrCorruptedData, err)
+
+		logs := &strings.Builder{}
+		db, err := Connect(dbPath, maxFileSizeKB, vacuumIntervalSec, WithStrictLoad(false), WithLogger(log.New(logs, "", 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = db.Close() }()
+
+		assert.Equal(t, int64(1), db.Stats().SkippedRecords)
+		assert.Equal(t, fmt.Sprintf("error: skipped the malformed record at byte %d of 1655375171402014000.log\n", info.Size()), logs.String())
+		value, err := db.Get("goat")
+		assert.Nil(t, err)
+		assert.Equal(t, "678 months", value)
+	})
+
+	t.Run("WithKeyNormalizationShouldTreatEquivalentKeysAsOne", func(t *testing.T) {
+		composed, decomposed := "caf\u00e9", "cafe\u0301"
+		db, err := connectToTestDb(dbPath, maxFileSizeKB, vacuumIntervalSec, WithKeyNormalization(NormalizeNFC))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+
+		err = db.Set(composed, "coffee")
+		if err != nil {
+			t.Fatal(err)
+		}
+		value, err := db.Get(decomposed)
+		assert.Nil(t, err)
+		assert.Equal(t, "coffee", value)
+		assert.True(t, db.Exists(decomposed))
+		results := db.GetMany([]string{decomposed})
+		assert.Equal(t, "coffee", results[0].Value)
+		var keys []string
+		for key := range db.Prefix(decomposed) {
+			keys = append(keys, key)
+		}
+		assert.Equal(t, []string{composed}, keys)
+
+		result, err := db.Import(map[string]string{composed: "espresso", decomposed: "latte"}, Overwrite)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, result.Overwritten+result.Inserted)
+		err = db.Delete(decomposed)
+		assert.Nil(t, err)
+		_, err = db.Get(composed)
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		nfkc, err := connectToTestDb(dbPath, maxFileSizeKB, vacuumIntervalSec, WithKeyNormalization(NormalizeNFKC))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = nfkc.Close() }()
+		err = nfkc.Set("\ufb01le", "scroll")
+		if err != nil {
+			t.Fatal(err)
+		}
+		value, err = nfkc.Get("file")
+		assert.Nil(t, err)
+		assert.Equal(t, "scroll", value)
+	})
+
+	t.Run("KeysShouldNotBeNormalizedByDefault", func(t *testing.T) {
+		db, err := connectToTestDb(dbPath, maxFileSizeKB, vacuumIntervalSec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+
+		err = db.Set("caf\u00e9", "coffee")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = db.Get("cafe\u0301")
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("WithCaseInsensitiveKeysShouldIgnoreCaseButKeepTheOriginalCasing", func(t *testing.T) {
+		db, err := connectToTestDb(dbPath, maxFileSizeKB, vacuumIntervalSec, WithCaseInsensitiveKeys(true))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+
+		err = db.Set("Server.Port", "8080")
+		if err != nil {
+			t.Fatal(err)
+		}
+		value, err := db.Get("server.port")
+		assert.Nil(t, err)
+		assert.Equal(t, "8080", value)
+		assert.True(t, db.Exists("SERVER.PORT"))
+		var keys []string
+		for key := range db.Prefix("SERVER.") {
+			keys = append(keys, key)
+		}
+		assert.Equal(t, []string{"server.port"}, keys)
+		info, err := db.Describe("server.PORT")
+		assert.Nil(t, err)
+		assert.Equal(t, "Server.Port", info.OriginalKey)
+
+		err = db.Delete("Server.port")
+		assert.Nil(t, err)
+		assert.False(t, db.Exists("server.port"))
+	})
+
+	t.Run("ReservedKeysShouldBeRejected", func(t *testing.T) {
+		db, err := connectToTestDb(dbPath, maxFileSizeKB, vacuumIntervalSec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+
+		err = db.Set(ReservedKeyPrefix+"marker", "1")
+		assert.ErrorIs(t, err, ErrReservedKey)
+		err = db.Delete(ReservedKeyPrefix + "marker")
+		assert.ErrorIs(t, err, ErrReservedKey)
+		assert.False(t, db.Exists(ReservedKeyPrefix+"marker"))
+	})
+
+	t.Run("SetGetAndDeleteGetShouldReturnThePreviousValue", func(t *testing.T) {
+		db, err := connectToTestDb(dbPath, maxFileSizeKB, 3600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+
+		old, existed, err := db.SetGet("cow", "501 months")
+		assert.Nil(t, err)
+		assert.True(t, existed)
+		assert.Equal(t, "500 months", old)
+		value, err := db.Get("cow")
+		assert.Nil(t, err)
+		assert.Equal(t, "501 months", value)
+
+		old, existed, err = db.SetGet("horse", "12 months")
+		assert.Nil(t, err)
+		assert.False(t, existed)
+		assert.Equal(t, "", old)
+		assert.True(t, db.Exists("horse"))
+
+		old, err = db.DeleteGet("dog")
+		assert.Nil(t, err)
+		assert.Equal(t, "23 months", old)
+		assert.False(t, db.Exists("dog"))
+
+		_, err = db.DeleteGet("dog")
+		assert.ErrorIs(t, err, ErrNotFound)
+		assert.Equal(t, int64(2), db.Stats().Ops[opSetGet])
+		assert.Equal(t, int64(1), db.Stats().Errors[opDeleteGet])
+	})
+
+	t.Run("SwapShouldExchangeTheValuesOfTheKeys", func(t *testing.T) {
+		db, err := connectToTestDb(dbPath, maxFileSizeKB, 3600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+
+		err = db.Swap("cow", "goat")
+		assert.Nil(t, err)
+		for key, expected := range map[string]string{"cow": "678 months", "goat": "500 months"} {
+			value, err := db.Get(key)
+			assert.Nil(t, err)
+			assert.Equal(t, expected, value)
+		}
+
+		err = db.Swap("cow", "horse")
+		assert.ErrorIs(t, err, ErrNotFound)
+		value, err := db.Get("cow")
+		assert.Nil(t, err)
+		assert.Equal(t, "678 months", value)
+		assert.False(t, db.Exists("horse"))
+		assert.Equal(t, int64(2), db.Stats().Ops[opSwap])
+	})
+
+	t.Run("SegmentsShouldListTheDataFiles", func(t *testing.T) {
+		db, err := connectToTestDb(dbPath, maxFileSizeKB, 3600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+
+		segments, err := db.Segments()
+		assert.Nil(t, err)
+		var names []string
+		for _, segment := range segments {
+			names = append(names, segment.Name)
+		}
+		assert.Equal(t, []string{"1655375120328185000.cky", "1655375120328186000.cky"}, names)
+		assert.Equal(t, 2, segments[0].Keys)
+		assert.Equal(t, time.Unix(0, 1655375120328186000), segments[0].End)
+		assert.Equal(t, int64(1), db.Stats().Ops[opSegments])
+	})
+
+	t.Run("KeysByAgeShouldReturnTheOldestOrNewestKeys", func(t *testing.T) {
+		db, err := connectToTestDb(dbPath, maxFileSizeKB, 3600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+
+		err = db.Set("horse", "12 months")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		keys, err := db.KeysByAge(1, false)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"horse"}, keys)
+		keys, err = db.KeysByAge(2, true)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"cow", "dog"}, keys)
+		assert.Equal(t, int64(2), db.Stats().Ops[opKeysByAge])
+	})
+
+	t.Run("FindShouldReturnThePairsWhoseValuesMatch", func(t *testing.T) {
+		db, err := connectToTestDb(dbPath, maxFileSizeKB, 3600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+
+		found, err := db.Find(func(key string, value string) bool { return strings.HasSuffix(value, "0 months") }, 0)
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]string{"cow": "500 months", "pig": "70 months", "fish": "8990 months"}, found)
+
+		found, err = db.Find(func(key string, value string) bool { return key == "dog" }, 1)
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]string{"dog": "23 months"}, found)
+		assert.Equal(t, int64(2), db.Stats().Ops[opFind])
+	})
+
+	t.Run("ScanRegexShouldReturnTheKeysMatchingThePattern", func(t *testing.T) {
+		db, err := connectToTestDb(dbPath, maxFileSizeKB, 3600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+
+		keys, err := db.ScanRegex("^[cd]o", 0)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"cow", "dog"}, keys)
+		keys, err = db.ScanRegex("i", 1)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"fish"}, keys)
+		_, err = db.ScanRegex("(", 0)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("WithVacuumRetryShouldRetryFailedVacuumsAndReportTheFailures", func(t *testing.T) {
+		start := time.Date(2022, 6, 16, 10, 0, 0, 0, time.UTC)
+		clock := internal.NewFakeClock(start)
+		failures := make(chan int, 10)
+		db, err := connectToTestDb(dbPath, maxFileSizeKB, 60, WithClock(clock), WithVacuumRetry(time.Second, time.Minute),
+			WithVacuumFailureHandler(func(err error, consecutiveFailures int) { failures <- consecutiveFailures }))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+
+		// a del file that cannot be read makes every vacuum fail
+		delFilePath := filepath.Join(dbPath, internal.DelFilename)
+		err = os.Remove(delFilePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.Mkdir(delFilePath, 0777)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		clock.Advance(time.Minute)
+		assert.Equal(t, 1, <-failures)
+		assert.Eventually(t, func() bool { return db.Tasks()[0].NextRun.Equal(start.Add(time.Minute + time.Second)) }, time.Second, time.Millisecond)
+		assert.Equal(t, 1, db.Stats().VacuumFailures)
+
+		clock.Advance(time.Second)
+		assert.Equal(t, 2, <-failures)
+		assert.Eventually(t, func() bool { return db.Tasks()[0].NextRun.Equal(start.Add(time.Minute + 3*time.Second)) }, time.Second, time.Millisecond)
+		assert.Equal(t, 2, db.Stats().VacuumFailures)
+	})
+
+	t.Run("WithQuarantineShouldMoveCorruptedRecordsAsideOnGet", func(t *testing.T) {
+		err := internal.ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		db, err := Connect(dbPath, maxFileSizeKB, 3600, WithBlobSpillThreshold(1024), WithQuarantine(true))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+
+		err = db.Set("cow", strings.Repeat("a very large value ", 1000))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.RemoveAll(filepath.Join(dbPath, internal.BlobsFolderName))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = db.Get("cow")
+		assert.ErrorIs(t, err, ErrCorruptedData)
+		_, err = db.Get("cow")
+		assert.ErrorIs(t, err, ErrNotFound)
+		assert.Equal(t, int64(1), db.Stats().Ops[opQuarantine])
+
+		records, err := db.Quarantined()
+		assert.Nil(t, err)
+		if assert.Len(t, records, 1) {
+			assert.Equal(t, "cow", records[0].Key)
+		}
+
+		err = db.Set("cow", "500 months")
+		assert.Nil(t, err)
+		value, err := db.Get("cow")
+		assert.Nil(t, err)
+		assert.Equal(t, "500 months", value)
+	})
+
+	t.Run("KeysShouldReturnAllKeysInAscendingOrder", func(t *testing.T) {
+		db, err := connectToTestDb(dbPath, maxFileSizeKB, 3600)
+		if err != nil {
+			t.Fatal(err)
+		}
 		defer func() {
 			_ = db.Close()
 			_ = internal.ClearDummyFileDataInDb(dbPath)
 		}()
 
-		for k, v := range testRecords {
-			err = db.Set(k, v)
-			if err != nil {
-				t.Fatal(err)
-			}
+		var database Database = db
+		assert.Equal(t, []string{"cow", "dog", "fish", "goat", "hen", "pig"}, database.Keys())
+
+		err = db.Delete("dog")
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"cow", "fish", "goat", "hen", "pig"}, database.Keys())
+	})
+
+	t.Run("ErrorsShouldSayTheOperationKeyAndFileTheyAreAbout", func(t *testing.T) {
+		db, err := connectToTestDb(dbPath, maxFileSizeKB, 3600)
+		if err != nil {
+			t.Fatal(err)
 		}
-		err = db.Delete(keyToDelete)
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+
+		// a log file that cannot be written to makes every Set of a new key fail
+		logFilePath := filepath.Join(dbPath, "1655375171402014000.log")
+		err = os.Remove(logFilePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.Mkdir(logFilePath, 0777)
 		if err != nil {
 			t.Fatal(err)
 		}
 
-		idxFileContents, err := internal.ReadFilesWithExtension(dbPath, "idx")
+		err = db.Set("horse", "11 months")
+		var opErr *OpError
+		if assert.ErrorAs(t, err, &opErr) {
+			assert.Equal(t, opSet, opErr.Op)
+			assert.Equal(t, "horse", opErr.Key)
+		}
+		assert.Contains(t, err.Error(), `set key="horse": `)
+		assert.Contains(t, err.Error(), "1655375171402014000.log: ")
+
+		// the errors of ckydb itself are returned as they are
+		_, err = db.Get("unknown")
+		assert.Equal(t, ErrNotFound, err)
+	})
+
+	t.Run("ErrorClassificationShouldTellRetryableErrorsApart", func(t *testing.T) {
+		db, err := connectToTestDb(dbPath, maxFileSizeKB, 3600)
 		if err != nil {
 			t.Fatal(err)
 		}
-		delFileContents, err := internal.ReadFilesWithExtension(dbPath, "del")
+		defer func() { _ = internal.ClearDummyFileDataInDb(dbPath) }()
+
+		_, err = db.Get("unknown")
+		assert.True(t, IsNotFound(err))
+		assert.False(t, IsRetryable(err))
+		assert.False(t, IsCorruption(err))
+
+		assert.Nil(t, db.Close())
+		_, err = db.Get("cow")
+		assert.True(t, IsRetryable(err))
+		assert.False(t, IsNotFound(err))
+
+		diskFull := &OpError{Op: opSet, Key: "cow", Err: &os.PathError{Op: "write", Path: "x.log", Err: syscall.ENOSPC}}
+		assert.True(t, IsRetryable(diskFull))
+		assert.True(t, IsRetryable(fmt.Errorf("%w: %w", ErrReadOnly, diskFull)))
+		assert.True(t, IsRetryable(context.DeadlineExceeded))
+		assert.False(t, IsRetryable(context.Canceled))
+		assert.False(t, IsRetryable(errors.New("unknown")))
+		assert.False(t, IsRetryable(nil))
+		for _, err := range []error{ErrCorruptedData, ErrTamperedRecord, fmt.Errorf("wrapped: %w", ErrInvariantViolated)} {
+			assert.True(t, IsCorruption(err))
+			assert.False(t, IsRetryable(err))
+		}
+	})
+
+	t.Run("WithVacuumOnOpenFalseShouldLeaveDeletedKeysForTheVacuumTask", func(t *testing.T) {
+		db, err := connectToTestDb(dbPath, maxFileSizeKB, 3600, WithVacuumOnOpen(false))
 		if err != nil {
 			t.Fatal(err)
 		}
-		logFileContents, err := internal.ReadFilesWithExtension(dbPath, "log")
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+
+		assert.Equal(t, []string{"cow", "dog", "fish", "goat", "hen", "pig"}, db.Keys())
+		delContents, err := internal.ReadFilesWithExtension(dbPath, "del")
+		assert.Nil(t, err)
+		assert.Contains(t, strings.Join(delContents, ""), "bar")
+
+		err = db.Vacuum()
+		assert.Nil(t, err)
+		delContents, err = internal.ReadFilesWithExtension(dbPath, "del")
+		assert.Nil(t, err)
+		assert.Equal(t, "", strings.Join(delContents, ""))
+	})
+
+	t.Run("WithWarmupShouldServeTheFirstGetOfTheKeysFromTheCache", func(t *testing.T) {
+		db, err := connectToTestDb(dbPath, maxFileSizeKB, 3600, WithWarmup([]string{"cow"}))
 		if err != nil {
 			t.Fatal(err)
 		}
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
 
-		<-time.After(time.Second * time.Duration(vacuumIntervalSec))
+		value, err := db.Get("cow")
+		assert.Nil(t, err)
+		assert.Equal(t, "500 months", value)
+		stats := db.Stats()
+		assert.Equal(t, int64(1), stats.CacheHits)
+		assert.Equal(t, int64(0), stats.CacheMisses)
+	})
 
-		idxFileContentsAfterVacuum, err := internal.ReadFilesWithExtension(dbPath, "idx")
+	t.Run("ConnectAsyncShouldFailOperationsWithErrLoadingUntilReady", func(t *testing.T) {
+		engine := blockingEngine{Engine: NewMemoryEngine(), loaded: make(chan error)}
+		db, ready := ConnectAsync("", 0, vacuumIntervalSec, WithEngine(engine))
+		defer func() { _ = db.Close() }()
+
+		assert.Equal(t, StateLoading, db.State())
+		assert.Equal(t, "loading", db.State().String())
+		_, err := db.Get("cow")
+		assert.ErrorIs(t, err, ErrLoading)
+		assert.True(t, IsRetryable(err))
+		assert.ErrorIs(t, db.Set("cow", "500 months"), ErrLoading)
+		assert.Equal(t, 0, db.Stats().Keys)
+
+		close(engine.loaded)
+		assert.Nil(t, <-ready)
+		_, isOpen := <-ready
+		assert.False(t, isOpen)
+		assert.Equal(t, StateOpen, db.State())
+		assert.Nil(t, db.Set("cow", "500 months"))
+		value, err := db.Get("cow")
+		assert.Nil(t, err)
+		assert.Equal(t, "500 months", value)
+	})
+
+	t.Run("ConnectAsyncShouldLeaveTheDatabaseClosedIfLoadingFails", func(t *testing.T) {
+		engine := blockingEngine{Engine: NewMemoryEngine(), loaded: make(chan error, 1)}
+		engine.loaded <- errors.New("disk on fire")
+		db, ready := ConnectAsync("", 0, vacuumIntervalSec, WithEngine(engine))
+
+		err := <-ready
+		assert.ErrorContains(t, err, "disk on fire")
+		assert.Equal(t, StateClosed, db.State())
+		assert.ErrorContains(t, db.Open(), "disk on fire")
+		_, err = db.Get("cow")
+		assert.ErrorIs(t, err, ErrDatabaseClosed)
+		assert.Nil(t, db.Close())
+	})
+
+	t.Run("WithDataFileBucketsShouldKeepCkyFilesInSubFolders", func(t *testing.T) {
+		db, err := connectToTestDb(dbPath, maxFileSizeKB, 3600, WithDataFileBuckets(24*time.Hour))
 		if err != nil {
 			t.Fatal(err)
 		}
-		delFileContentsAfterVacuum, err := internal.ReadFilesWithExtension(dbPath, "del")
+		backupPath := dbPath + "Backup"
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+			_ = internal.ClearDummyFileDataInDb(backupPath)
+		}()
+
+		ckyFiles, err := internal.ReadFilesWithExtension(dbPath, "cky")
+		assert.Nil(t, err)
+		assert.Empty(t, ckyFiles)
+		value, err := db.Get("cow")
+		assert.Nil(t, err)
+		assert.Equal(t, "500 months", value)
+
+		// backups keep the layout of the database
+		err = db.BackupToDir(backupPath)
+		assert.Nil(t, err)
+		backup, err := Connect(backupPath, maxFileSizeKB, 3600)
 		if err != nil {
 			t.Fatal(err)
 		}
-		logFileContentsAfterVacuum, err := internal.ReadFilesWithExtension(dbPath, "log")
+		defer func() { _ = backup.Close() }()
+		value, err = backup.Get("dog")
+		assert.Nil(t, err)
+		assert.Equal(t, "23 months", value)
+	})
+
+	t.Run("ClearShouldLeaveTheOldFilesToTheVacuumTask", func(t *testing.T) {
+		// the files are deleted once the vacuum has released the write lock, after the operation is reported
+		var clearedFoldersDuringVacuum []string
+		db, err := connectToTestDb(dbPath, maxFileSizeKB, 3600, WithOnOperation(func(op OpInfo) {
+			if op.Name == opVacuum {
+				clearedFoldersDuringVacuum, _ = filepath.Glob(dbPath + internal.ClearedFolderInfix + "*")
+			}
+		}))
 		if err != nil {
 			t.Fatal(err)
 		}
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
 
-		assert.NotContains(t, idxFileContents[0], keyToDelete)
-		assert.Contains(t, delFileContents[0], keyToDelete)
-		assert.Contains(t, logFileContents[0], keyToDelete)
-		assert.NotContains(t, idxFileContentsAfterVacuum[0], keyToDelete)
-		assert.NotContains(t, delFileContentsAfterVacuum[0], keyToDelete)
-		assert.NotContains(t, logFileContentsAfterVacuum[0], keyToDelete)
+		err = db.Clear()
+		assert.Nil(t, err)
+		clearedFolders, err := filepath.Glob(dbPath + internal.ClearedFolderInfix + "*")
+		assert.Nil(t, err)
+		assert.Len(t, clearedFolders, 1)
+		assert.Empty(t, db.Keys())
+
+		err = db.Vacuum()
+		assert.Nil(t, err)
+		assert.Equal(t, clearedFolders, clearedFoldersDuringVacuum)
+		clearedFolders, err = filepath.Glob(dbPath + internal.ClearedFolderInfix + "*")
+		assert.Nil(t, err)
+		assert.Empty(t, clearedFolders)
 	})
 
-	t.Run("LogFileShouldBeTurnedToCkyFileAfterItExceedsTheMaxFileSizeKB", func(t *testing.T) {
-		var preRollData []map[string]string
-		postRollData := map[string]string{
-			"hey": "English",
-			"hi":  "English",
+	t.Run("ClearDataShouldKeepTheMetadataSegment", func(t *testing.T) {
+		db, err := connectToTestDb(dbPath, maxFileSizeKB, 3600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+
+		err = db.SetMeta("schema_version", "3")
+		if err != nil {
+			t.Fatal(err)
 		}
 
+		err = db.ClearData()
+		assert.Nil(t, err)
+		assert.Empty(t, db.Keys())
+		value, err := db.GetMeta("schema_version")
+		assert.Nil(t, err)
+		assert.Equal(t, "3", value)
+		assert.Equal(t, int64(1), db.Stats().Ops["clear_data"])
+	})
+
+	t.Run("ManifestShouldReportDamagedDataFiles", func(t *testing.T) {
 		err := internal.ClearDummyFileDataInDb(dbPath)
 		if err != nil {
 			t.Fatal(err)
 		}
+		err = internal.AddDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		db, err := Connect(dbPath, maxFileSizeKB, 3600, WithManifest(true))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
 
-		db, err := Connect(dbPath, maxFileSizeKB, vacuumIntervalSec)
+		tampered, err := db.Verify()
+		assert.Nil(t, err)
+		assert.Empty(t, tampered)
+
+		dataFilePath := filepath.Join(dbPath, "1655375120328185000.cky")
+		data, err := os.ReadFile(dataFilePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(dataFilePath, data[:len(data)/2], 0666)
 		if err != nil {
 			t.Fatal(err)
 		}
 
+		tampered, err = db.Verify()
+		assert.Nil(t, err)
+		assert.Equal(t, []TamperedRecord{{File: "1655375120328185000.cky"}}, tampered)
+		damaged, err := VerifyDataFiles(dbPath)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"1655375120328185000.cky"}, damaged)
+	})
+
+	t.Run("StatsShouldReportReadAndWriteAmplification", func(t *testing.T) {
+		db, err := connectToTestDb(dbPath, maxFileSizeKB, vacuumIntervalSec)
+		if err != nil {
+			t.Fatal(err)
+		}
 		defer func() {
 			_ = db.Close()
 			_ = internal.ClearDummyFileDataInDb(dbPath)
 		}()
 
-		for i := 0; i < 3; i++ {
-			data := map[string]string{}
+		err = db.Set("hey", "English")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, key := range []string{"cow", "cow", "hey"} {
+			_, err = db.Get(key)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
 
-			for k, v := range testRecords {
-				key := fmt.Sprintf("%s-%d", k, i)
-				data[key] = v
+		stats := db.Stats()
+		assert.Equal(t, int64(2), stats.HotGets)
+		assert.Equal(t, int64(1), stats.ColdGets)
+		assert.Equal(t, int64(len("500 months")*2+len("English")), stats.ValueBytesRead)
+		assert.Greater(t, stats.ReadAmplification, 1.0)
+		assert.Equal(t, int64(len("hey")+len("English")), stats.KeyValueBytesWritten)
+		assert.Greater(t, stats.WriteAmplification, 1.0)
+	})
 
-				err := db.Set(key, v)
-				if err != nil {
-					t.Fatal(err)
-				}
-			}
+	t.Run("WithMaintenanceWorkersShouldStillVacuumEveryFile", func(t *testing.T) {
+		db, err := connectToTestDb(dbPath, maxFileSizeKB, vacuumIntervalSec, WithMaintenanceWorkers(1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
 
-			preRollData = append(preRollData, data)
+		for _, key := range []string{"cow", "goat"} {
+			err = db.Delete(key)
+			if err != nil {
+				t.Fatal(err)
+			}
 		}
+		err = db.Vacuum()
+		assert.Nil(t, err)
 
-		for k, v := range postRollData {
-			err = db.Set(k, v)
+		for _, filename := range []string{"1655375120328185000.cky", "1655375171402014000.log"} {
+			data, err := os.ReadFile(filepath.Join(dbPath, filename))
 			if err != nil {
 				t.Fatal(err)
 			}
+			assert.NotContains(t, string(data), "-cow")
+			assert.NotContains(t, string(data), "-goat")
 		}
+		delData, err := os.ReadFile(filepath.Join(dbPath, internal.DelFilename))
+		assert.Nil(t, err)
+		assert.Empty(t, delData)
+	})
 
-		ckyFileContentsAfterRoll, err := internal.ReadFilesWithExtension(dbPath, "cky")
+	t.Run("WithMemoryGovernorShouldShedMemoryNearTheSoftLimit", func(t *testing.T) {
+		clock := internal.NewFakeClock(time.Now())
+		db, err := connectToTestDb(dbPath, maxFileSizeKB, vacuumIntervalSec,
+			WithClock(clock), WithMemoryGovernor(MemoryPolicy{SoftLimit: 1, Interval: time.Minute}))
 		if err != nil {
 			t.Fatal(err)
 		}
-		logFileContentsAfterRoll, err := internal.ReadFilesWithExtension(dbPath, "log")
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+
+		tasks := db.Tasks()
+		assert.Equal(t, 2, len(tasks))
+		assert.Equal(t, "memory_governor", tasks[0].Name)
+		assert.Equal(t, 2, db.Stats().DataFiles)
+
+		clock.Advance(time.Minute)
+		assert.Eventually(t, func() bool {
+			return db.Stats().Ops[opShedMemory] == 1
+		}, time.Second, 10*time.Millisecond)
+		assert.Nil(t, db.Tasks()[0].LastError)
+		assert.Equal(t, 3, db.Stats().DataFiles)
+
+		value, err := db.Get("goat")
+		assert.Nil(t, err)
+		assert.Equal(t, "678 months", value)
+	})
+
+	t.Run("VacuumShouldNotEnforceRetentionUnlessTheDatabaseIsOpen", func(t *testing.T) {
+		clock := internal.NewFakeClock(time.Unix(0, 1655375171402014000))
+		db, err := connectToTestDb(dbPath, maxFileSizeKB, vacuumIntervalSec, WithClock(clock), WithRetention(time.Hour))
 		if err != nil {
 			t.Fatal(err)
 		}
-		sort.Strings(ckyFileContentsAfterRoll)
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+		assert.Nil(t, db.Close())
+
+		clock.Advance(2 * time.Hour)
+		assert.ErrorIs(t, db.Vacuum(), ErrDatabaseClosed)
+		unopened := newUnloadedCkydb(dbPath, maxFileSizeKB, vacuumIntervalSec, WithClock(clock), WithRetention(time.Hour))
+		assert.ErrorIs(t, unopened.Vacuum(), ErrNotOpened)
+		for _, filename := range []string{"1655375120328185000.cky", "1655375120328186000.cky"} {
+			_, err = os.Stat(filepath.Join(dbPath, filename))
+			assert.Nil(t, err)
+		}
+		assert.Equal(t, int64(0), db.Stats().Ops[opVacuum])
 
-		assert.Equal(t, len(preRollData), len(ckyFileContentsAfterRoll))
-		for i, keySet := range preRollData {
-			for k, v := range keySet {
-				keyValuePair := fmt.Sprintf("%s%s%s", k, internal.KeyValueSeparator, v)
-				assert.Contains(t, ckyFileContentsAfterRoll[i], keyValuePair)
+		assert.Nil(t, db.Open())
+		assert.Nil(t, db.Vacuum())
+		assert.Equal(t, 0, db.Stats().DataFiles)
+		assert.Equal(t, int64(1), db.Stats().Ops[opVacuum])
+	})
+
+	t.Run("TasksAndStatsShouldBeSafeToPollDuringConnectAsync", func(t *testing.T) {
+		engine := blockingEngine{Engine: NewMemoryEngine(), loaded: make(chan error)}
+		db, ready := ConnectAsync("", 0, vacuumIntervalSec, WithEngine(engine))
+		defer func() { _ = db.Close() }()
+
+		polled := make(chan struct{})
+		go func() {
+			defer close(polled)
+			for db.State() == StateLoading {
+				_ = db.Tasks()
+				_ = db.Stats()
+			}
+		}()
+
+		close(engine.loaded)
+		assert.Nil(t, <-ready)
+		<-polled
+		assert.Equal(t, "vacuum", db.Tasks()[0].Name)
+	})
+
+	t.Run("SetVacuumIntervalShouldBeSafeToCallDuringOpenAndClose", func(t *testing.T) {
+		db, err := Connect("", 0, vacuumIntervalSec, WithEngine(NewMemoryEngine()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = db.Close() }()
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for i := 0; i < 100; i++ {
+				_ = db.SetVacuumInterval(time.Duration(i+1) * time.Minute)
 			}
+		}()
+
+		for i := 0; i < 100; i++ {
+			assert.Nil(t, db.Close())
+			assert.Nil(t, db.Open())
 		}
+		<-done
+	})
 
-		for k, v := range postRollData {
-			keyValuePair := fmt.Sprintf("%s%s%s", k, internal.KeyValueSeparator, v)
-			assert.Contains(t, logFileContentsAfterRoll[0], keyValuePair)
+	t.Run("RetentionShouldForwardTheDeletionOfTheKeysItDropsToTheReplicationSink", func(t *testing.T) {
+		_ = internal.ClearDummyFileDataInDb(dbPath)
+		err := internal.AddDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = internal.ClearDummyFileDataInDb(dbPath) }()
+
+		var ops []Op
+		sink := replicationSinkFunc(func(op Op) error {
+			ops = append(ops, op)
+			return nil
+		})
+
+		db, err := Connect(dbPath, maxFileSizeKB, vacuumIntervalSec, WithReplicationSink(sink, ReplicationPolicy{BufferSize: 1}),
+			WithRetentionPolicy(RetentionPolicy{MaxDataFiles: 1, Action: RetentionDelete}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Nil(t, db.Close())
+
+		var deleted []string
+		for _, op := range ops {
+			assert.Equal(t, OpDelete, op.Type)
+			deleted = append(deleted, op.Key)
+		}
+		assert.ElementsMatch(t, []string{"cow", "dog"}, deleted)
+	})
+
+	t.Run("WithMemoryGovernorShouldBackOffUnderSustainedMemoryPressure", func(t *testing.T) {
+		db, err := connectToTestDb(dbPath, maxFileSizeKB, vacuumIntervalSec,
+			WithMemoryGovernor(MemoryPolicy{SoftLimit: 1, Interval: time.Hour}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+
+		// a key is set before every check, so that each shed has a log file to roll
+		var shedChecks []int
+		for i := 0; i < 16; i++ {
+			err = db.Set(fmt.Sprintf("key%d", i), "value")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			sheds := db.Stats().Ops[opShedMemory]
+			assert.Nil(t, db.shedMemoryIfNeeded())
+			if db.Stats().Ops[opShedMemory] > sheds {
+				shedChecks = append(shedChecks, i)
+			}
+		}
+
+		assert.Equal(t, []int{0, 2, 5, 10}, shedChecks)
+		assert.Equal(t, 2+len(shedChecks), db.Stats().DataFiles)
+	})
+
+	t.Run("SetIfAbsentShouldOnlySetKeysThatDoNotExist", func(t *testing.T) {
+		db, err := connectToTestDb(dbPath, maxFileSizeKB, vacuumIntervalSec)
+		if err != nil {
+			t.Fatal(err)
 		}
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+
+		isSet, err := db.SetIfAbsent("goat", "1 month")
+		assert.Nil(t, err)
+		assert.False(t, isSet)
+		isSet, err = db.SetIfAbsent("unicorn", "1 month")
+		assert.Nil(t, err)
+		assert.True(t, isSet)
+		_, err = db.SetIfAbsent("horse", "1$%#@*&^&month")
+		assert.ErrorIs(t, err, ErrInvalidKeyValue)
+
+		value, err := db.Get("goat")
+		assert.Nil(t, err)
+		assert.Equal(t, "678 months", value)
+		value, err = db.Get("unicorn")
+		assert.Nil(t, err)
+		assert.Equal(t, "1 month", value)
+		assert.False(t, db.store.Has("horse"))
+	})
+
+	t.Run("NewRingShouldFailWithoutNodes", func(t *testing.T) {
+		_, err := NewRing(nil)
+		assert.ErrorIs(t, err, ErrNoNodes)
+		_, err = NewRing([]Controller{})
+		assert.ErrorIs(t, err, ErrNoNodes)
 	})
 }
 
@@ -520,7 +3563,7 @@ func BenchmarkCkydb(b *testing.B) {
 
 // connectToTestDb opens the db at the given path after
 // clearing out old data
-func connectToTestDb(dbPath string, maxFileSizeKB float64, vacuumIntervalSec float64) (*Ckydb, error) {
+func connectToTestDb(dbPath string, maxFileSizeKB float64, vacuumIntervalSec float64, opts ...Option) (*Ckydb, error) {
 	err := internal.ClearDummyFileDataInDb(dbPath)
 	if err != nil {
 		return nil, err
@@ -531,5 +3574,39 @@ func connectToTestDb(dbPath string, maxFileSizeKB float64, vacuumIntervalSec flo
 		return nil, err
 	}
 
-	return Connect(dbPath, maxFileSizeKB, vacuumIntervalSec)
+	return Connect(dbPath, maxFileSizeKB, vacuumIntervalSec, opts...)
+}
+
+// attributesAsMap converts the list of span attributes into a map of key to value
+func attributesAsMap(attributes []attribute.KeyValue) map[string]attribute.Value {
+	result := make(map[string]attribute.Value, len(attributes))
+	for _, attr := range attributes {
+		result[string(attr.Key)] = attr.Value
+	}
+
+	return result
+}
+
+// writerFunc is an io.Writer that calls the function for every write
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) {
+	return f(p)
+}
+
+// replicationSinkFunc is a ReplicationSink that calls the function for every op
+type replicationSinkFunc func(op Op) error
+
+func (f replicationSinkFunc) Apply(op Op) error {
+	return f(op)
+}
+
+// blockingEngine is an Engine whose Load waits until the channel is closed, or receives an error to fail with
+type blockingEngine struct {
+	Engine
+	loaded chan error
+}
+
+func (e blockingEngine) Load() error {
+	return <-e.loaded
 }
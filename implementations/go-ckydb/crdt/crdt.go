@@ -0,0 +1,155 @@
+// Package crdt provides values that several databases can write to at once, and that are merged without losing
+// any of the writes when they are replicated to each other, whatever the order the writes arrive in.
+//
+// Values are stored in ckydb as strings made of a type prefix followed by their JSON encoding, e.g.
+// `gcounter:{"node-a":3,"node-b":5}` or `lww:{"value":"500 months","time":1655375120328185000,"node":"node-a"}`.
+// Resolve merges such values, and can be given to ckydb.WithConflictResolver.
+package crdt
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sopherapps/ckydb/implementations/go-ckydb"
+)
+
+const (
+	gCounterPrefix    = "gcounter:"
+	lwwRegisterPrefix = "lww:"
+)
+
+var ErrInvalidValue = errors.New("value is not of the expected type")
+
+// GCounter is a grow-only counter holding the count of every node that increments it.
+// Each node only increments its own count, and merging keeps the highest count of every node
+type GCounter map[string]uint64
+
+// ParseGCounter decodes a GCounter from the value it was stored as. It returns an ErrInvalidValue error
+// if the value is not a GCounter
+func ParseGCounter(value string) (GCounter, error) {
+	data, ok := strings.CutPrefix(value, gCounterPrefix)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q is not a gcounter", ErrInvalidValue, value)
+	}
+
+	counter := GCounter{}
+	err := json.Unmarshal([]byte(data), &counter)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidValue, err)
+	}
+
+	return counter, nil
+}
+
+// Increment adds delta to the count of the given node
+func (g GCounter) Increment(node string, delta uint64) {
+	g[node] += delta
+}
+
+// Value returns the sum of the counts of all nodes
+func (g GCounter) Value() uint64 {
+	var total uint64
+	for _, count := range g {
+		total += count
+	}
+
+	return total
+}
+
+// Merge returns a new GCounter with the highest count of every node in g and other
+func (g GCounter) Merge(other GCounter) GCounter {
+	merged := make(GCounter, len(g))
+	for node, count := range g {
+		merged[node] = count
+	}
+	for node, count := range other {
+		merged[node] = max(merged[node], count)
+	}
+
+	return merged
+}
+
+// String encodes the GCounter as the value it is stored as, with the nodes in order
+func (g GCounter) String() string {
+	data, _ := json.Marshal(map[string]uint64(g))
+	return gCounterPrefix + string(data)
+}
+
+// LWWRegister is a register whose value is the one set last. Registers set at the same time
+// are told apart by the node that set them, and then by their values
+type LWWRegister struct {
+	Value string `json:"value"`
+	// Time is when the value was set, in nanoseconds since the unix epoch
+	Time int64 `json:"time"`
+	// Node is the node that set the value
+	Node string `json:"node"`
+}
+
+// NewLWWRegister returns a register with the value set by the given node at the given time
+func NewLWWRegister(value string, node string, t time.Time) LWWRegister {
+	return LWWRegister{Value: value, Time: t.UnixNano(), Node: node}
+}
+
+// ParseLWWRegister decodes an LWWRegister from the value it was stored as. It returns an ErrInvalidValue error
+// if the value is not an LWWRegister
+func ParseLWWRegister(value string) (LWWRegister, error) {
+	data, ok := strings.CutPrefix(value, lwwRegisterPrefix)
+	if !ok {
+		return LWWRegister{}, fmt.Errorf("%w: %q is not an lww register", ErrInvalidValue, value)
+	}
+
+	var register LWWRegister
+	err := json.Unmarshal([]byte(data), &register)
+	if err != nil {
+		return LWWRegister{}, fmt.Errorf("%w: %s", ErrInvalidValue, err)
+	}
+
+	return register, nil
+}
+
+// Merge returns whichever of r and other was set last
+func (r LWWRegister) Merge(other LWWRegister) LWWRegister {
+	switch {
+	case other.Time != r.Time:
+		if other.Time > r.Time {
+			return other
+		}
+	case other.Node != r.Node:
+		if other.Node > r.Node {
+			return other
+		}
+	case other.Value > r.Value:
+		return other
+	}
+
+	return r
+}
+
+// String encodes the LWWRegister as the value it is stored as
+func (r LWWRegister) String() string {
+	data, _ := json.Marshal(r)
+	return lwwRegisterPrefix + string(data)
+}
+
+// Resolve is a ckydb.ConflictResolver merging two GCounters, or two LWWRegisters, into one.
+// Any other values are resolved by ckydb.LastWriterWins
+func Resolve(key, localVal, remoteVal string, localTs, remoteTs int64) string {
+	if local, err := ParseGCounter(localVal); err == nil {
+		if remote, err := ParseGCounter(remoteVal); err == nil {
+			return local.Merge(remote).String()
+		}
+	}
+
+	if local, err := ParseLWWRegister(localVal); err == nil {
+		if remote, err := ParseLWWRegister(remoteVal); err == nil {
+			return local.Merge(remote).String()
+		}
+	}
+
+	return ckydb.LastWriterWins(key, localVal, remoteVal, localTs, remoteTs)
+}
+
+var _ ckydb.ConflictResolver = Resolve
@@ -0,0 +1,114 @@
+package crdt
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sopherapps/ckydb/implementations/go-ckydb"
+	"github.com/sopherapps/ckydb/implementations/go-ckydb/internal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCRDT(t *testing.T) {
+	dbPath, err := filepath.Abs("testCRDTDb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = internal.ClearDummyFileDataInDb(dbPath) }()
+
+	t.Run("GCounterMergeShouldKeepTheIncrementsOfEveryNode", func(t *testing.T) {
+		a, b := GCounter{}, GCounter{}
+		a.Increment("node-a", 3)
+		b.Increment("node-b", 5)
+		b.Increment("node-a", 1)
+
+		assert.Equal(t, GCounter{"node-a": 3, "node-b": 5}, a.Merge(b))
+		assert.Equal(t, a.Merge(b), b.Merge(a))
+		assert.Equal(t, a.Merge(b), a.Merge(b).Merge(b))
+		assert.Equal(t, uint64(8), a.Merge(b).Value())
+		assert.Equal(t, GCounter{"node-a": 3}, a)
+	})
+
+	t.Run("GCounterShouldBeParsedFromTheValueItIsStoredAs", func(t *testing.T) {
+		counter := GCounter{"node-b": 5, "node-a": 3}
+		assert.Equal(t, `gcounter:{"node-a":3,"node-b":5}`, counter.String())
+
+		parsed, err := ParseGCounter(counter.String())
+		assert.Nil(t, err)
+		assert.Equal(t, counter, parsed)
+
+		for _, value := range []string{"500 months", `lww:{"node-a":3}`, "gcounter:{"} {
+			_, err = ParseGCounter(value)
+			assert.ErrorIs(t, err, ErrInvalidValue)
+		}
+	})
+
+	t.Run("LWWRegisterMergeShouldKeepTheValueSetLast", func(t *testing.T) {
+		now := time.Now()
+		older := NewLWWRegister("500 months", "node-a", now)
+		newer := NewLWWRegister("501 months", "node-b", now.Add(time.Second))
+		assert.Equal(t, newer, older.Merge(newer))
+		assert.Equal(t, newer, newer.Merge(older))
+
+		// registers set at the same time are told apart by node, then by value
+		other := NewLWWRegister("499 months", "node-c", now)
+		assert.Equal(t, other, older.Merge(other))
+		assert.Equal(t, other, other.Merge(older))
+		same := NewLWWRegister("502 months", "node-a", now)
+		assert.Equal(t, same, older.Merge(same))
+		assert.Equal(t, same, same.Merge(older))
+	})
+
+	t.Run("LWWRegisterShouldBeParsedFromTheValueItIsStoredAs", func(t *testing.T) {
+		register := LWWRegister{Value: "500 months", Time: 1655375120328185000, Node: "node-a"}
+		assert.Equal(t, `lww:{"value":"500 months","time":1655375120328185000,"node":"node-a"}`, register.String())
+
+		parsed, err := ParseLWWRegister(register.String())
+		assert.Nil(t, err)
+		assert.Equal(t, register, parsed)
+
+		_, err = ParseLWWRegister(GCounter{}.String())
+		assert.ErrorIs(t, err, ErrInvalidValue)
+	})
+
+	t.Run("ResolveShouldMergeReplicatedCounters", func(t *testing.T) {
+		err := internal.ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		db, err := ckydb.Connect(dbPath, 4, 60, ckydb.WithConflictResolver(Resolve))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = db.Close() }()
+
+		local := GCounter{"node-a": 3}
+		assert.Nil(t, db.Set("visits", local.String()))
+		assert.Nil(t, db.Set("name", NewLWWRegister("cow", "node-a", time.Now()).String()))
+
+		remote := GCounter{"node-b": 5}
+		err = db.Apply(ckydb.Op{Type: ckydb.OpSet, Key: "visits", Value: remote.String(), Time: time.Now().Add(-time.Hour)})
+		assert.Nil(t, err)
+		register := NewLWWRegister("goat", "node-b", time.Now().Add(time.Hour))
+		err = db.Apply(ckydb.Op{Type: ckydb.OpSet, Key: "name", Value: register.String(), Time: time.Now().Add(-time.Hour)})
+		assert.Nil(t, err)
+
+		value, err := db.Get("visits")
+		assert.Nil(t, err)
+		counter, err := ParseGCounter(value)
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(8), counter.Value())
+
+		value, err = db.Get("name")
+		assert.Nil(t, err)
+		assert.Equal(t, register.String(), value)
+	})
+
+	t.Run("ResolveShouldKeepTheLastWriteOfOtherValues", func(t *testing.T) {
+		value := Resolve("cow", "500 months", GCounter{}.String(), 2, 1)
+		assert.Equal(t, "500 months", value)
+		value = Resolve("cow", "500 months", "501 months", 1, 2)
+		assert.Equal(t, "501 months", value)
+	})
+}
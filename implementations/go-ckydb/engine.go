@@ -0,0 +1,349 @@
+package ckydb
+
+import (
+	"errors"
+	"io"
+	"iter"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sopherapps/ckydb/implementations/go-ckydb/internal"
+)
+
+var ErrUnsupportedByEngine = errors.New("operation is not supported by the storage engine")
+
+// Engine is the storage under a Ckydb, which the Ckydb locks, instruments, replicates and vacuums.
+// Gets may be called concurrently with each other, but never with any other method
+type Engine interface {
+	// Load loads the data of the engine e.g. from disk. It is called once, by Connect
+	Load() error
+	Set(key string, value string) error
+	// Get returns an ErrNotFound error if the key is nonexistent
+	Get(key string) (string, error)
+	// Delete returns an ErrNotFound error if the key is nonexistent
+	Delete(key string) error
+	Clear() error
+	// Vacuum reclaims the space of deleted keys. It is called by Vacuum and the vacuum task
+	Vacuum() error
+	// Keys returns all the keys, sorted in ascending order
+	Keys() []string
+}
+
+// WithEngine stores the data in the given engine instead of the database folder, e.g. to keep it in
+// memory or in an object store, while keeping the locking, stats, tracing, replication, audit log and
+// background tasks of Ckydb. The dbPath and maxFileSizeKB passed to Connect, and the options of the
+// database folder e.g. WithHistory, are then ignored. Methods that the engine cannot do,
+// e.g. SetWithTTL or Snapshot, return an ErrUnsupportedByEngine error
+func WithEngine(engine Engine) Option {
+	return func(o *options) {
+		o.engine = engine
+	}
+}
+
+// NewMemoryEngine creates an Engine that keeps the data in memory only, e.g. for tests
+func NewMemoryEngine() Engine {
+	return &memoryEngine{data: map[string]string{}}
+}
+
+type memoryEngine struct {
+	data map[string]string
+}
+
+func (m *memoryEngine) Load() error {
+	return nil
+}
+
+func (m *memoryEngine) Set(key string, value string) error {
+	m.data[key] = value
+	return nil
+}
+
+func (m *memoryEngine) Get(key string) (string, error) {
+	value, ok := m.data[key]
+	if !ok {
+		return "", ErrNotFound
+	}
+
+	return value, nil
+}
+
+func (m *memoryEngine) Delete(key string) error {
+	if _, ok := m.data[key]; !ok {
+		return ErrNotFound
+	}
+
+	delete(m.data, key)
+	return nil
+}
+
+func (m *memoryEngine) Clear() error {
+	m.data = map[string]string{}
+	return nil
+}
+
+func (m *memoryEngine) Vacuum() error {
+	return nil
+}
+
+func (m *memoryEngine) Keys() []string {
+	keys := make([]string, 0, len(m.data))
+	for key := range m.data {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+// engineStorage adapts an Engine to the internal Storage of a Ckydb
+type engineStorage struct {
+	engine Engine
+}
+
+func (e engineStorage) Load() error {
+	return e.engine.Load()
+}
+
+func (e engineStorage) Set(key string, value string) error {
+	return e.engine.Set(key, value)
+}
+
+func (e engineStorage) Get(key string) (string, error) {
+	return e.engine.Get(key)
+}
+
+func (e engineStorage) Delete(key string) error {
+	return e.engine.Delete(key)
+}
+
+func (e engineStorage) Clear() error {
+	return e.engine.Clear()
+}
+
+// ClearData is Clear, as engines have no files written when the database was created
+func (e engineStorage) ClearData() error {
+	return e.engine.Clear()
+}
+
+func (e engineStorage) Vacuum() error {
+	return e.engine.Vacuum()
+}
+
+func (e engineStorage) SetWithStats(key string, value string, st *internal.OpStats) error {
+	return e.engine.Set(key, value)
+}
+
+func (e engineStorage) GetWithStats(key string, st *internal.OpStats) (string, error) {
+	return e.engine.Get(key)
+}
+
+func (e engineStorage) GetManyWithStats(keys []string, st *internal.OpStats) []internal.GetResult {
+	results := make([]internal.GetResult, len(keys))
+	for i, key := range keys {
+		value, err := e.engine.Get(key)
+		switch {
+		case err == nil:
+			results[i] = internal.GetResult{Value: value, Found: true}
+		case !errors.Is(err, ErrNotFound):
+			results[i] = internal.GetResult{Err: err}
+		}
+	}
+
+	return results
+}
+
+func (e engineStorage) DescribeWithStats(key string, st *internal.OpStats) (internal.KeyInfo, error) {
+	return internal.KeyInfo{}, ErrUnsupportedByEngine
+}
+
+func (e engineStorage) SetWithTTLAndStats(key string, value string, ttl time.Duration, st *internal.OpStats) error {
+	return ErrUnsupportedByEngine
+}
+
+func (e engineStorage) TTL(key string) (time.Duration, error) {
+	return 0, ErrUnsupportedByEngine
+}
+
+func (e engineStorage) ExpireWithStats(key string, ttl time.Duration, st *internal.OpStats) error {
+	return ErrUnsupportedByEngine
+}
+
+func (e engineStorage) PersistWithStats(key string, st *internal.OpStats) error {
+	return ErrUnsupportedByEngine
+}
+
+func (e engineStorage) GetVersion(key string, at time.Time) (string, error) {
+	return "", ErrUnsupportedByEngine
+}
+
+func (e engineStorage) History(key string, limit int) ([]internal.Version, error) {
+	return nil, ErrUnsupportedByEngine
+}
+
+func (e engineStorage) UndeleteWithStats(key string, st *internal.OpStats) (string, error) {
+	return "", ErrUnsupportedByEngine
+}
+
+// SetReaderWithStats reads the whole value into memory as engines only take values as strings
+func (e engineStorage) SetReaderWithStats(key string, r io.Reader, st *internal.OpStats) error {
+	value, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	return e.engine.Set(key, string(value))
+}
+
+func (e engineStorage) GetReaderWithStats(key string, st *internal.OpStats) (io.ReadCloser, error) {
+	value, err := e.engine.Get(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return io.NopCloser(strings.NewReader(value)), nil
+}
+
+func (e engineStorage) Verify() ([]internal.TamperedRecord, error) {
+	return nil, ErrUnsupportedByEngine
+}
+
+func (e engineStorage) Segments() ([]internal.SegmentInfo, error) {
+	return nil, ErrUnsupportedByEngine
+}
+
+func (e engineStorage) QuarantineWithStats(key string, st *internal.OpStats) error {
+	return ErrUnsupportedByEngine
+}
+
+func (e engineStorage) Quarantined() ([]internal.QuarantinedRecord, error) {
+	return nil, ErrUnsupportedByEngine
+}
+
+func (e engineStorage) SetMeta(key string, value string) error {
+	return ErrUnsupportedByEngine
+}
+
+func (e engineStorage) GetMeta(key string) (string, error) {
+	return "", ErrUnsupportedByEngine
+}
+
+func (e engineStorage) DeleteMeta(key string) error {
+	return ErrUnsupportedByEngine
+}
+
+func (e engineStorage) MigrateFormatTo(version int) error {
+	return ErrUnsupportedByEngine
+}
+
+func (e engineStorage) DeleteWithStats(key string, st *internal.OpStats) error {
+	return e.engine.Delete(key)
+}
+
+func (e engineStorage) VacuumWithStats(st *internal.OpStats) error {
+	return e.engine.Vacuum()
+}
+
+// Flush has nothing to do as engines do not batch index entries
+func (e engineStorage) Flush() error {
+	return nil
+}
+
+func (e engineStorage) FlushWithStats(st *internal.OpStats) error {
+	return nil
+}
+
+func (e engineStorage) Compact() error {
+	return ErrUnsupportedByEngine
+}
+
+func (e engineStorage) CompactWithStats(st *internal.OpStats) error {
+	return ErrUnsupportedByEngine
+}
+
+// ShedMemoryWithStats does nothing as engines manage their own memory
+func (e engineStorage) ShedMemoryWithStats(st *internal.OpStats) error {
+	return nil
+}
+
+func (e engineStorage) ThrottledVacuumWithStats(st *internal.OpStats, pause func(bytesRewritten int64)) error {
+	return e.engine.Vacuum()
+}
+
+func (e engineStorage) ThrottledCompactWithStats(st *internal.OpStats, pause func(bytesRewritten int64)) error {
+	return ErrUnsupportedByEngine
+}
+
+// EnforceRetention does nothing as engines have no data files to retain
+func (e engineStorage) EnforceRetention() error {
+	return nil
+}
+
+// ClearedFolders returns none as engines have no folders for Clear to move aside
+func (e engineStorage) ClearedFolders() ([]string, error) {
+	return nil, nil
+}
+
+// GetWhileLoadingWithStats fails as engines are not read until they are loaded
+func (e engineStorage) GetWhileLoadingWithStats(key string, st *internal.OpStats) (string, error) {
+	return "", ErrLoading
+}
+
+// KeysWhileLoading fails as engines are not read until they are loaded
+func (e engineStorage) KeysWhileLoading() ([]string, error) {
+	return nil, ErrLoading
+}
+
+func (e engineStorage) Keys() []string {
+	return e.engine.Keys()
+}
+
+func (e engineStorage) FindWithStats(predicate func(key string, value string) bool, limit int, st *internal.OpStats) (map[string]string, error) {
+	return nil, ErrUnsupportedByEngine
+}
+
+func (e engineStorage) KeysByAge(limit int, ascending bool) ([]string, error) {
+	return nil, ErrUnsupportedByEngine
+}
+
+func (e engineStorage) Has(key string) bool {
+	_, err := e.engine.Get(key)
+	return err == nil
+}
+
+func (e engineStorage) Stats() internal.Stats {
+	return internal.Stats{Keys: len(e.engine.Keys())}
+}
+
+func (e engineStorage) SetMaxFileSize(maxFileSizeKB float64) error {
+	return ErrUnsupportedByEngine
+}
+
+func (e engineStorage) MaxFileSize() float64 {
+	return 0
+}
+
+func (e engineStorage) Snapshot() (*internal.Snapshot, error) {
+	return nil, ErrUnsupportedByEngine
+}
+
+func (e engineStorage) BackupToDir(path string) error {
+	return ErrUnsupportedByEngine
+}
+
+func (e engineStorage) ReadFollowerState() (*internal.FollowerState, error) {
+	return nil, ErrUnsupportedByEngine
+}
+
+func (e engineStorage) SwapFollowerState(state *internal.FollowerState) {}
+
+// Health always reports engines as healthy as they handle their own failures
+func (e engineStorage) Health() internal.Health {
+	return internal.Health{}
+}
+
+func (e engineStorage) ReadOplog(fromSeq uint64) iter.Seq2[internal.OplogEntry, error] {
+	return func(yield func(internal.OplogEntry, error) bool) {
+		yield(internal.OplogEntry{}, ErrUnsupportedByEngine)
+	}
+}
@@ -0,0 +1,149 @@
+package ckydb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+)
+
+// OpError is an error of an operation of the database, with the operation and the key it was given, if any, e.g.
+// `set key="cow": appending to 1655375171402014000.log: write /db/1655375171402014000.log: no space left on device`.
+// The errors of ckydb itself, such as ErrNotFound, are returned as they are, while the others e.g. those of
+// the file system are wrapped in an OpError, so errors.Is and errors.As still find what caused them
+type OpError struct {
+	Op  string
+	Key string
+	Err error
+}
+
+func (e *OpError) Error() string {
+	if e.Key == "" {
+		return fmt.Sprintf("%s: %s", e.Op, e.Err)
+	}
+
+	return fmt.Sprintf("%s key=%q: %s", e.Op, e.Key, e.Err)
+}
+
+func (e *OpError) Unwrap() error {
+	return e.Err
+}
+
+// sentinelErrors are the errors of ckydb itself, which say what went wrong without the context of an OpError
+var sentinelErrors = map[error]bool{
+	ErrAlreadyRunning:           true,
+	ErrNotRunning:               true,
+	ErrNotFound:                 true,
+	ErrCorruptedData:            true,
+	ErrOutOfBounds:              true,
+	ErrInvalidKeyValue:          true,
+	ErrConflict:                 true,
+	ErrQuotaExceeded:            true,
+	ErrReadOnly:                 true,
+	ErrFollower:                 true,
+	ErrReservedKey:              true,
+	ErrInvariantViolated:        true,
+	ErrHistoryDisabled:          true,
+	ErrTrashDisabled:            true,
+	ErrUnsupportedFormatVersion: true,
+	ErrOutdatedFormatVersion:    true,
+	ErrMigrationInProgress:      true,
+	ErrInvalidSeparators:        true,
+	ErrTamperedRecord:           true,
+	ErrInvalidHMACKey:           true,
+	ErrHMACDisabled:             true,
+	ErrNoManifest:               true,
+	ErrNoNodes:                  true,
+	ErrNotOpened:                true,
+	ErrDatabaseClosed:           true,
+	ErrLoading:                  true,
+	ErrUnsupportedByEngine:      true,
+}
+
+// wrapOpError wraps err in an OpError of the operation op on the given key, unless it is nil,
+// one of the errors of ckydb itself or an OpError already
+func wrapOpError(op string, key string, err error) error {
+	if err == nil || sentinelErrors[err] {
+		return err
+	}
+
+	if _, ok := err.(*OpError); ok {
+		return err
+	}
+
+	return &OpError{Op: op, Key: key, Err: err}
+}
+
+// IsNotFound checks whether err is, or wraps, an ErrNotFound error, got for a nonexistent key
+func IsNotFound(err error) bool {
+	return errors.Is(err, ErrNotFound)
+}
+
+// IsCorruption checks whether err was caused by data of the database that is corrupted or was tampered with,
+// which retrying does not fix
+func IsCorruption(err error) bool {
+	return errors.Is(err, ErrCorruptedData) || errors.Is(err, ErrTamperedRecord) || errors.Is(err, ErrInvariantViolated)
+}
+
+// IsRetryable checks whether the operation that failed with err may succeed if it is retried later as it is,
+// for callers to implement retry policies with. This is the case for errors of the disk being full or failing,
+// which the database recovers from, of the database not being open yet or any longer e.g. while a server restarts,
+// of timeouts and refused or reset connections e.g. of replication sinks, and of errors with a Retryable method
+// returning true. ErrNotFound errors, corruption and invalid keys or values are not retryable
+func IsRetryable(err error) bool {
+	if err == nil || IsCorruption(err) {
+		return false
+	}
+
+	var retryable interface{ Retryable() bool }
+	if errors.As(err, &retryable) {
+		return retryable.Retryable()
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	for _, target := range retryableErrors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// retryableErrors are the errors that IsRetryable finds retryable
+var retryableErrors = []error{
+	ErrReadOnly,
+	ErrNotOpened,
+	ErrLoading,
+	ErrDatabaseClosed,
+	context.DeadlineExceeded,
+	syscall.ENOSPC,
+	syscall.EDQUOT,
+	syscall.EIO,
+	syscall.EAGAIN,
+	syscall.EBUSY,
+	syscall.EINTR,
+	syscall.ECONNREFUSED,
+	syscall.ECONNRESET,
+	syscall.ETIMEDOUT,
+}
+
+// isPermanent checks whether err is one of the errors of ckydb itself that retrying does not fix
+func isPermanent(err error) bool {
+	if IsRetryable(err) {
+		return false
+	}
+
+	for sentinel := range sentinelErrors {
+		if errors.Is(err, sentinel) {
+			return true
+		}
+	}
+
+	return false
+}
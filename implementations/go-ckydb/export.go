@@ -0,0 +1,108 @@
+package ckydb
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+
+	"github.com/sopherapps/ckydb/implementations/go-ckydb/codec"
+)
+
+// ExportJSON writes all key-value pairs in the database to w as a single JSON object.
+// It reads from a snapshot so writes made during the export proceed without waiting for it
+// and are not part of the export
+func (c *Ckydb) ExportJSON(w io.Writer) (err error) {
+	snap, err := c.Snapshot()
+	if err != nil {
+		return err
+	}
+	defer func() { err = errors.Join(err, snap.Close()) }()
+
+	buf := bufio.NewWriter(w)
+	separator := "{"
+	err = snap.ForEach(func(key string, value string) error {
+		_, err := buf.WriteString(separator)
+		if err != nil {
+			return err
+		}
+		separator = ","
+
+		return writeJSONPair(buf, key, value)
+	})
+	if err != nil {
+		return err
+	}
+
+	if separator == "{" {
+		_, err = buf.WriteString(separator)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = buf.WriteString("}")
+	if err != nil {
+		return err
+	}
+
+	return buf.Flush()
+}
+
+// ExportWith writes all key-value pairs in the database to w as a single map[string]string encoded
+// with the given codec. With codec.JSON it is the same as ExportJSON; other codecs need the whole map
+// in memory to encode it
+func (c *Ckydb) ExportWith(w io.Writer, cd codec.Codec) (err error) {
+	if cd.Name() == codec.JSON.Name() {
+		return c.ExportJSON(w)
+	}
+
+	snap, err := c.Snapshot()
+	if err != nil {
+		return err
+	}
+	defer func() { err = errors.Join(err, snap.Close()) }()
+
+	data := map[string]string{}
+	err = snap.ForEach(func(key string, value string) error {
+		data[key] = value
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	encoded, err := cd.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(encoded)
+	return err
+}
+
+// writeJSONPair writes the key and value to w as a member of a JSON object
+func writeJSONPair(w *bufio.Writer, key string, value string) error {
+	encodedKey, err := json.Marshal(key)
+	if err != nil {
+		return err
+	}
+
+	encodedValue, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(encodedKey)
+	if err != nil {
+		return err
+	}
+
+	err = w.WriteByte(':')
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(encodedValue)
+	return err
+}
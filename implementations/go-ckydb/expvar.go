@@ -0,0 +1,46 @@
+package ckydb
+
+import (
+	"expvar"
+	"sync"
+)
+
+// expvarRegistry maps each expvar prefix to the database whose stats it publishes,
+// since expvar variables cannot be unpublished or published twice
+var expvarRegistry = struct {
+	dbs  map[string]*Ckydb
+	lock sync.Mutex
+}{dbs: map[string]*Ckydb{}}
+
+// publishExpvar publishes the stats of the given database under the given expvar name
+func publishExpvar(name string, db *Ckydb) {
+	expvarRegistry.lock.Lock()
+	defer expvarRegistry.lock.Unlock()
+
+	expvarRegistry.dbs[name] = db
+	if expvar.Get(name) != nil {
+		return
+	}
+
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		expvarRegistry.lock.Lock()
+		db, ok := expvarRegistry.dbs[name]
+		expvarRegistry.lock.Unlock()
+
+		if !ok {
+			return nil
+		}
+
+		return db.Stats()
+	}))
+}
+
+// unpublishExpvar stops publishing the stats of the given database under the given expvar name
+func unpublishExpvar(name string, db *Ckydb) {
+	expvarRegistry.lock.Lock()
+	defer expvarRegistry.lock.Unlock()
+
+	if expvarRegistry.dbs[name] == db {
+		delete(expvarRegistry.dbs, name)
+	}
+}
@@ -0,0 +1,7 @@
+// Package failpoint lets applications embedding ckydb inject delays and errors at defined points of its writes
+// and loads, to test their own recovery logic on top of ckydb. It is only built with the ckydb_failpoints tag e.g.
+//
+//	go test -tags ckydb_failpoints ./...
+//
+// so that builds without the tag, which always skip the failpoints, cannot enable them by mistake
+package failpoint
@@ -0,0 +1,61 @@
+//go:build ckydb_failpoints
+
+package failpoint
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sopherapps/ckydb/implementations/go-ckydb/internal"
+)
+
+// The points of ckydb's writes and loads at which failpoints can be enabled
+const (
+	// BeforeLogAppend is hit before a new or updated value is written to the log file. An error
+	// fails the Set, leaving the value as it was
+	BeforeLogAppend = internal.FailpointBeforeLogAppend
+	// AfterIndexWrite is hit after a new key has been added to the index file. An error fails the Set
+	// although the key is set, as if the caller had timed out waiting for it
+	AfterIndexWrite = internal.FailpointAfterIndexWrite
+	// DuringRoll is hit while the log file is rolled into a data file, after the new log file has been created.
+	// An error fails the Set of a new key that triggered the roll, leaving the key unset, and the roll is retried
+	// by the next Set
+	DuringRoll = internal.FailpointDuringRoll
+	// DuringLoad is hit while a database is loaded, once its keys are, so that the reads ConnectAsync serves
+	// meanwhile can be tried. An error fails the load
+	DuringLoad = internal.FailpointDuringLoad
+)
+
+var ErrUnknownFailpoint = errors.New("unknown failpoint")
+
+// Enable runs fn every time the failpoint of the given name is hit, failing the operation under way with
+// the error fn returns, if any. fn runs while the database is locked. It returns an ErrUnknownFailpoint
+// error if there is no failpoint of that name
+func Enable(name string, fn func() error) error {
+	switch name {
+	case BeforeLogAppend, AfterIndexWrite, DuringRoll, DuringLoad:
+		internal.EnableFailpoint(name, fn)
+		return nil
+	}
+
+	return fmt.Errorf("%w: %s", ErrUnknownFailpoint, name)
+}
+
+// Disable stops running anything at the failpoint of the given name
+func Disable(name string) {
+	internal.DisableFailpoint(name)
+}
+
+// Return returns a function for Enable that fails with err
+func Return(err error) func() error {
+	return func() error { return err }
+}
+
+// Sleep returns a function for Enable that delays the operation by d
+func Sleep(d time.Duration) func() error {
+	return func() error {
+		time.Sleep(d)
+		return nil
+	}
+}
@@ -0,0 +1,171 @@
+//go:build ckydb_failpoints
+
+package failpoint
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/sopherapps/ckydb/implementations/go-ckydb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFailpoint(t *testing.T) {
+	errInjected := errors.New("injected")
+
+	// connect connects to a new database in a temporary folder, closing it at the end of the test
+	connect := func(t *testing.T, maxFileSizeKB float64) (*ckydb.Ckydb, string) {
+		dbPath := t.TempDir()
+		db, err := ckydb.Connect(dbPath, maxFileSizeKB, 300)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = db.Close() })
+		return db, dbPath
+	}
+
+	t.Run("BeforeLogAppendShouldFailTheSetWithoutChangingTheValue", func(t *testing.T) {
+		db, _ := connect(t, 4)
+		err := db.Set("cow", "500 months")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = Enable(BeforeLogAppend, Return(errInjected))
+		assert.Nil(t, err)
+		err = db.Set("cow", "501 months")
+		assert.ErrorIs(t, err, errInjected)
+		err = db.Set("dog", "23 months")
+		assert.ErrorIs(t, err, errInjected)
+		Disable(BeforeLogAppend)
+
+		value, err := db.Get("cow")
+		assert.Nil(t, err)
+		assert.Equal(t, "500 months", value)
+		_, err = db.Get("dog")
+		assert.ErrorIs(t, err, ckydb.ErrNotFound)
+		err = db.Set("dog", "23 months")
+		assert.Nil(t, err)
+	})
+
+	t.Run("AfterIndexWriteShouldFailTheSetOfANewKeyOnceItIsSet", func(t *testing.T) {
+		db, dbPath := connect(t, 4)
+		hits := 0
+		err := Enable(AfterIndexWrite, func() error {
+			hits++
+			return errInjected
+		})
+		assert.Nil(t, err)
+		defer Disable(AfterIndexWrite)
+
+		err = db.Set("cow", "500 months")
+		assert.ErrorIs(t, err, errInjected)
+		err = db.Set("cow", "501 months")
+		assert.Nil(t, err)
+		assert.Equal(t, 1, hits)
+		err = db.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		reopened, err := ckydb.Connect(dbPath, 4, 300)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = reopened.Close() }()
+		value, err := reopened.Get("cow")
+		assert.Nil(t, err)
+		assert.Equal(t, "501 months", value)
+	})
+
+	t.Run("DuringRollShouldLetTheNextSetRollTheLogFile", func(t *testing.T) {
+		db, _ := connect(t, 0.1)
+		err := Enable(DuringRoll, Return(errInjected))
+		assert.Nil(t, err)
+
+		failed := -1
+		for i := 0; i < 10 && failed < 0; i++ {
+			err = db.Set(fmt.Sprintf("key-%d", i), "some value")
+			if err != nil {
+				assert.ErrorIs(t, err, errInjected)
+				failed = i
+			}
+		}
+		assert.True(t, failed > 0)
+		assert.Equal(t, 0, db.Stats().DataFiles)
+		_, err = db.Get(fmt.Sprintf("key-%d", failed))
+		assert.ErrorIs(t, err, ckydb.ErrNotFound)
+
+		Disable(DuringRoll)
+		err = db.Set(fmt.Sprintf("key-%d", failed), "some value")
+		assert.Nil(t, err)
+		assert.Equal(t, 1, db.Stats().DataFiles)
+		for i := 0; i <= failed; i++ {
+			value, err := db.Get(fmt.Sprintf("key-%d", i))
+			assert.Nil(t, err, i)
+			assert.Equal(t, "some value", value, i)
+		}
+	})
+
+	t.Run("DuringLoadShouldLetConnectAsyncServeTheLoadedKeys", func(t *testing.T) {
+		db, dbPath := connect(t, 0.1)
+		for i := 0; i < 10; i++ {
+			err := db.Set(fmt.Sprintf("key-%d", i), fmt.Sprintf("value %d", i))
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		assert.Less(t, 0, db.Stats().DataFiles)
+		err := db.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		hit, release := make(chan struct{}), make(chan struct{})
+		err = Enable(DuringLoad, func() error {
+			close(hit)
+			<-release
+			return nil
+		})
+		assert.Nil(t, err)
+		defer Disable(DuringLoad)
+
+		loading, ready := ckydb.ConnectAsync(dbPath, 0.1, 300)
+		defer func() { _ = loading.Close() }()
+		<-hit
+		assert.Equal(t, ckydb.StateLoading, loading.State())
+		assert.Len(t, loading.Keys(), 10)
+		for i := 0; i < 10; i++ {
+			value, err := loading.Get(fmt.Sprintf("key-%d", i))
+			assert.Nil(t, err, i)
+			assert.Equal(t, fmt.Sprintf("value %d", i), value, i)
+		}
+		_, err = loading.Get("key-10")
+		assert.ErrorIs(t, err, ckydb.ErrNotFound)
+		assert.ErrorIs(t, loading.Set("key-10", "value 10"), ckydb.ErrLoading)
+
+		close(release)
+		assert.Nil(t, <-ready)
+		assert.Nil(t, loading.Set("key-10", "value 10"))
+		assert.Len(t, loading.Keys(), 11)
+	})
+
+	t.Run("SleepShouldDelayTheOperation", func(t *testing.T) {
+		db, _ := connect(t, 4)
+		err := Enable(BeforeLogAppend, Sleep(50*time.Millisecond))
+		assert.Nil(t, err)
+		defer Disable(BeforeLogAppend)
+
+		start := time.Now()
+		err = db.Set("cow", "500 months")
+		assert.Nil(t, err)
+		assert.True(t, time.Since(start) >= 50*time.Millisecond)
+	})
+
+	t.Run("EnableShouldFailForUnknownFailpoints", func(t *testing.T) {
+		err := Enable("before-everything", Return(errInjected))
+		assert.ErrorIs(t, err, ErrUnknownFailpoint)
+	})
+}
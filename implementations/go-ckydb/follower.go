@@ -0,0 +1,48 @@
+package ckydb
+
+import (
+	"time"
+
+	"github.com/sopherapps/ckydb/implementations/go-ckydb/internal"
+)
+
+// WithFollower opens the database as a read-only follower of a database folder that another process,
+// possibly on another host, writes to e.g. over NFS or rsync. The follower never writes to the folder,
+// so writes fail with an ErrFollower error, and it runs no vacuum or compaction. Every refreshInterval,
+// it reads the index and log file again and swaps in the new state at once, so it sees the changes made
+// since with a delay. A refreshInterval of zero or less leaves refreshing to calls of Refresh
+func WithFollower(refreshInterval time.Duration) Option {
+	return func(o *options) {
+		o.isFollower = true
+		o.followerInterval = refreshInterval
+		o.storeOptions = append(o.storeOptions, internal.WithFollower(true))
+	}
+}
+
+// Refresh makes a follower see the changes made to the folder it follows since it was last refreshed.
+// The folder is read without blocking reads of the follower, which only wait for the new state to be
+// swapped in. A failed refresh, e.g. because the log file was being rolled, leaves the follower as it was.
+// It does nothing for databases that are not followers
+func (c *Ckydb) Refresh() error {
+	if !c.isFollower {
+		return nil
+	}
+
+	err := c.instrument(opRefresh, "", func(st *internal.OpStats) error {
+		state, err := c.store.ReadFollowerState()
+		if err != nil {
+			return err
+		}
+
+		c.mutLock.Lock()
+		defer c.mutLock.Unlock()
+
+		c.store.SwapFollowerState(state)
+		return nil
+	})
+	if err != nil {
+		c.logger.Printf("error: %s", err)
+	}
+
+	return err
+}
@@ -0,0 +1,26 @@
+package ckydb
+
+// Fork makes a copy of the database at newPath, as BackupToDir does, and connects to it with the same
+// maxFileSizeKB and vacuumIntervalSec. The fork and the database share their ".cky" files through hard
+// links until either of them rewrites one, e.g. on a Set of an old key, a vacuum or a compaction, so even
+// big databases are forked quickly and with little extra disk space. Writes to either are not seen by the other.
+// The options of the database are not carried over, so that e.g. the fork does not replicate to the same
+// sink; the ones the fork needs are passed in opts
+func (c *Ckydb) Fork(newPath string, opts ...Option) (*Ckydb, error) {
+	c.mutLock.RLock()
+	err := c.checkState()
+	if err == nil {
+		err = c.store.BackupToDir(newPath)
+	}
+	maxFileSizeKB := c.store.MaxFileSize()
+	c.mutLock.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	c.lifecycleLock.Lock()
+	vacuumIntervalSec := c.vacuumIntervalSec
+	c.lifecycleLock.Unlock()
+
+	return Connect(newPath, maxFileSizeKB, vacuumIntervalSec, opts...)
+}
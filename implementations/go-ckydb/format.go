@@ -0,0 +1,105 @@
+package ckydb
+
+import (
+	"time"
+
+	"github.com/sopherapps/ckydb/implementations/go-ckydb/internal"
+)
+
+// CurrentFormatVersion is the version of the disk format written by this implementation
+const CurrentFormatVersion = internal.CurrentFormatVersion
+
+// ReadFormatVersion returns the version of the disk format of the database folder at dbPath
+func ReadFormatVersion(dbPath string) (int, error) {
+	return internal.ReadFormatVersion(dbPath)
+}
+
+// MigrateFormat upgrades the database folder at dbPath, which must not be open, to the CurrentFormatVersion.
+// See MigrateFormatTo
+func MigrateFormat(dbPath string, opts ...Option) error {
+	return MigrateFormatTo(dbPath, CurrentFormatVersion, opts...)
+}
+
+// MigrateFormatTo converts the database folder at dbPath, which must not be open, to the given format version.
+// Version 2 records are length-prefixed, so keys and values may contain the separators, and its values are
+// compressed from WithValueCompressionThreshold, 1KB by default, up; such databases cannot be shared with the
+// other implementations. Version 3 index records hold the timestamp of each key instead of its timestamped key.
+// Converting back to version 1 fails with an ErrInvalidKeyValue error, before anything is converted, if a key
+// or value contains one of the separators. Folders with record HMACs need WithRecordHMAC.
+// An interrupted conversion is resumed by calling it again, and opening the folder fails with an
+// ErrMigrationInProgress error until then
+func MigrateFormatTo(dbPath string, version int, opts ...Option) error {
+	return internal.MigrateFormatTo(dbPath, version, newOptions(opts).storeOptions...)
+}
+
+// MigrateFormatTo converts the open database to the given format version, like the MigrateFormatTo function,
+// and then reloads it. Other operations wait until it is done, and open snapshots are invalidated
+func (c *Ckydb) MigrateFormatTo(version int) error {
+	c.mutLock.Lock()
+	defer c.mutLock.Unlock()
+
+	return c.instrument(opMigrate, "", func(st *internal.OpStats) error {
+		return c.store.MigrateFormatTo(version)
+	})
+}
+
+type Separators = internal.Separators
+
+// DefaultSeparators are the separators of databases created without WithSeparators. They are the ones
+// all implementations of ckydb use, so only databases with them can be shared with the other implementations
+var DefaultSeparators = internal.DefaultSeparators
+
+// WithSeparators sets the separators of the records in the files of a database created by Connect,
+// e.g. to store values that contain the DefaultSeparators. Existing databases keep the separators
+// they were created with. Connect fails with an ErrInvalidSeparators error if either separator is empty
+// or contains the other
+func WithSeparators(sep Separators) Option {
+	return func(o *options) {
+		o.storeOptions = append(o.storeOptions, internal.WithSeparators(sep))
+	}
+}
+
+// WithValueCompressionThreshold compresses each value of at least the given number of bytes on its own when it
+// is saved, so that large values shrink while small ones stay cheap to read and write. It only applies to databases
+// created with it, as they flag every value as compressed or not, and those databases cannot be shared with the
+// other implementations. Zero means no compression
+func WithValueCompressionThreshold(bytes int) Option {
+	return func(o *options) {
+		o.storeOptions = append(o.storeOptions, internal.WithValueCompressionThreshold(bytes))
+	}
+}
+
+// WithValueDeduplication saves each value of at least minBytes bytes once, in the "blobs" folder named by the
+// hash of its contents, so that keys with identical large values share it. Vacuum removes the blobs that no key
+// references any more. Like WithValueCompressionThreshold, it only applies to databases created with it.
+// Zero means no deduplication
+func WithValueDeduplication(minBytes int) Option {
+	return func(o *options) {
+		o.storeOptions = append(o.storeOptions, internal.WithValueDeduplication(minBytes))
+	}
+}
+
+// WithBlobSpillThreshold saves each value of at least the given number of bytes in a file of its own in the "blobs"
+// folder, referenced from its record, so that the rewrites of the log and data files do not copy it over and over.
+// Vacuum removes the files of the values that have since been deleted or replaced. Like
+// WithValueCompressionThreshold, it only applies to databases created with it. Zero means no spilling
+func WithBlobSpillThreshold(bytes int) Option {
+	return func(o *options) {
+		o.storeOptions = append(o.storeOptions, internal.WithBlobSpillThreshold(bytes))
+	}
+}
+
+// WithDataFileBuckets keeps the ".cky" files in sub-folders of the database folder, one per period e.g. a day that
+// they were created in, for databases with so many of them that listing the database folder gets slow on some file
+// systems. Connect moves the existing ".cky" files into the sub-folders, or back into the database folder when
+// it is opened without the option, so that any database can switch between both layouts
+func WithDataFileBuckets(period time.Duration) Option {
+	return func(o *options) {
+		o.storeOptions = append(o.storeOptions, internal.WithDataFileBuckets(period))
+	}
+}
+
+// ReadSeparators returns the separators of the database folder at dbPath
+func ReadSeparators(dbPath string) (Separators, error) {
+	return internal.ReadSeparators(dbPath)
+}
@@ -0,0 +1,12 @@
+package ckydb
+
+import "github.com/sopherapps/ckydb/implementations/go-ckydb/internal"
+
+type Health = internal.Health
+
+// Health returns whether the database is read-only after a disk error, such as the disk being full.
+// While it is, writes fail with an ErrReadOnly error and reads keep working. The database accepts
+// writes again by itself once the disk can be written to, as checked by the next write or vacuum
+func (c *Ckydb) Health() Health {
+	return c.store.Health()
+}
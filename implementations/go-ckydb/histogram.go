@@ -0,0 +1,108 @@
+package ckydb
+
+import (
+	"math"
+	"math/bits"
+	"time"
+)
+
+const (
+	// histogramSubBits is the log2 of the number of buckets each power of two is split into,
+	// so that a latency is off by at most 1/16th i.e. 6.25% of it
+	histogramSubBits    = 4
+	histogramSubBuckets = 1 << histogramSubBits
+	// histogramMagnitudes is the number of powers of two covered, up to 2^40ns i.e. about 18 minutes.
+	// Longer latencies are counted in the last bucket
+	histogramMagnitudes = 40 - histogramSubBits + 1
+)
+
+// LatencyStats summarize the latencies of an operation since the database was connected
+type LatencyStats struct {
+	Count int64
+	Mean  time.Duration
+	P50   time.Duration
+	P90   time.Duration
+	P99   time.Duration
+	P999  time.Duration
+	Max   time.Duration
+}
+
+// latencyHistogram counts latencies in log-linear buckets like an HDR histogram: each power of two
+// is split into the same number of buckets, so that the precision is relative to the latency,
+// recording takes constant time and the memory used is fixed
+type latencyHistogram struct {
+	counts [(histogramMagnitudes + 1) * histogramSubBuckets]int64
+	count  int64
+	sum    int64
+	max    int64
+}
+
+// record counts the latency d
+func (h *latencyHistogram) record(d time.Duration) {
+	v := max(int64(d), 0)
+	h.counts[histogramBucketOf(v)]++
+	h.count++
+	h.sum += v
+	h.max = max(h.max, v)
+}
+
+// quantile returns the latency that the fraction q of the recorded latencies are at most,
+// as the highest latency of its bucket, or the maximum latency if that is lower
+func (h *latencyHistogram) quantile(q float64) time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+
+	rank := max(int64(math.Ceil(q*float64(h.count))), 1)
+	var seen int64
+	for i, n := range h.counts {
+		seen += n
+		if seen >= rank {
+			return time.Duration(min(histogramBucketMax(i), h.max))
+		}
+	}
+
+	return time.Duration(h.max)
+}
+
+// stats summarizes the recorded latencies
+func (h *latencyHistogram) stats() LatencyStats {
+	s := LatencyStats{Count: h.count, Max: time.Duration(h.max)}
+	if h.count > 0 {
+		s.Mean = time.Duration(h.sum / h.count)
+	}
+
+	s.P50, s.P90, s.P99, s.P999 = h.quantile(0.5), h.quantile(0.9), h.quantile(0.99), h.quantile(0.999)
+	return s
+}
+
+// histogramBucketOf returns the index of the bucket counting the latency of v nanoseconds. Latencies below
+// histogramSubBuckets have a bucket each, and those in [2^m, 2^(m+1)) share histogramSubBuckets buckets
+func histogramBucketOf(v int64) int {
+	if v < histogramSubBuckets {
+		return int(v)
+	}
+
+	magnitude := bits.Len64(uint64(v)) - 1 - histogramSubBits
+	if magnitude >= histogramMagnitudes {
+		return (histogramMagnitudes+1)*histogramSubBuckets - 1
+	}
+
+	sub := int(v>>magnitude) - histogramSubBuckets
+	return (magnitude+1)*histogramSubBuckets + sub
+}
+
+// histogramBucketMax returns the highest latency in nanoseconds counted by the bucket of index i.
+// The last bucket has no highest latency as it also counts the latencies too long for the others
+func histogramBucketMax(i int) int64 {
+	if i < histogramSubBuckets {
+		return int64(i)
+	}
+	if i == (histogramMagnitudes+1)*histogramSubBuckets-1 {
+		return math.MaxInt64
+	}
+
+	magnitude := i/histogramSubBuckets - 1
+	sub := int64(i%histogramSubBuckets + histogramSubBuckets)
+	return (sub+1)<<magnitude - 1
+}
@@ -0,0 +1,55 @@
+package ckydb
+
+import (
+	"time"
+
+	"github.com/sopherapps/ckydb/implementations/go-ckydb/internal"
+)
+
+type HistoryPolicy = internal.HistoryPolicy
+type Version = internal.Version
+
+// WithHistory enables history mode, which retains the previous versions of keys, and their deletions,
+// in the database folder so that they can be got with GetVersion and History. Versions beyond the count
+// or age of the policy are dropped, from disk on the next vacuum. A Clear drops the whole history
+func WithHistory(policy HistoryPolicy) Option {
+	return func(o *options) {
+		o.storeOptions = append(o.storeOptions, internal.WithHistory(policy))
+	}
+}
+
+// GetVersion retrieves the value that the given key had at the given time. It returns an ErrNotFound
+// error if the key did not exist then, or that version is no longer retained, and an ErrHistoryDisabled
+// error if the database is not in history mode
+func (c *Ckydb) GetVersion(key string, at time.Time) (string, error) {
+	key = c.normalizeKey(key)
+	c.mutLock.RLock()
+	defer c.mutLock.RUnlock()
+
+	var value string
+	err := c.instrument(opGetVersion, key, func(st *internal.OpStats) error {
+		var err error
+		value, err = c.store.GetVersion(key, at)
+		return err
+	})
+
+	return value, err
+}
+
+// History returns at most limit of the retained versions of the given key, newest first, including
+// its deletions. A limit of zero or less returns all of them. It returns an ErrHistoryDisabled
+// error if the database is not in history mode
+func (c *Ckydb) History(key string, limit int) ([]Version, error) {
+	key = c.normalizeKey(key)
+	c.mutLock.RLock()
+	defer c.mutLock.RUnlock()
+
+	var versions []Version
+	err := c.instrument(opHistory, key, func(st *internal.OpStats) error {
+		var err error
+		versions, err = c.store.History(key, limit)
+		return err
+	})
+
+	return versions, err
+}
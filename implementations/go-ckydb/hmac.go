@@ -0,0 +1,50 @@
+package ckydb
+
+import "github.com/sopherapps/ckydb/implementations/go-ckydb/internal"
+
+type TamperedRecord = internal.TamperedRecord
+
+// WithRecordHMAC authenticates every record in the log and data files of a database created by Connect with an
+// HMAC-SHA256 of the given key, for tamper evidence alongside encryption at rest. Gets of records changed outside
+// of ckydb fail with an ErrTamperedRecord error, and Verify reports them. Existing databases keep whether they have
+// record HMACs, and Connect fails with an ErrInvalidHMACKey error if the key of a database with them is missing or
+// wrong. The contents of blobs, see WithBlobSpillThreshold, are not covered
+func WithRecordHMAC(key []byte) Option {
+	return func(o *options) {
+		o.storeOptions = append(o.storeOptions, internal.WithRecordHMAC(key))
+	}
+}
+
+// Verify checks the HMAC of every record in the log and data files, returning the records that fail it, including
+// those of keys deleted since but not yet vacuumed, and, with WithManifest, the data files that fail their SHA-256
+// check, with no Key. It returns an ErrHMACDisabled error if the database has neither record HMACs nor a manifest
+func (c *Ckydb) Verify() ([]TamperedRecord, error) {
+	c.mutLock.RLock()
+	defer c.mutLock.RUnlock()
+
+	var tampered []TamperedRecord
+	err := c.instrument(opVerify, "", func(st *internal.OpStats) error {
+		var err error
+		tampered, err = c.store.Verify()
+		return err
+	})
+
+	return tampered, err
+}
+
+// WithManifest keeps the SHA-256 of every ".cky" file in the "manifest.sha" file of the database folder, updated on
+// every log roll and on every rewrite of a ".cky" file, so that Verify reports the ".cky" files that bit rot or a
+// partial copy has damaged, and BackupToDir fails with an ErrCorruptedData error instead of copying them.
+// Connect hashes the ".cky" files missing from the manifest, so it can be enabled on any database
+func WithManifest(isEnabled bool) Option {
+	return func(o *options) {
+		o.storeOptions = append(o.storeOptions, internal.WithManifest(isEnabled))
+	}
+}
+
+// VerifyDataFiles checks the ".cky" files of the database folder at dbPath against its manifest, see WithManifest,
+// without connecting to it, returning the names of those that are damaged or missing. It suits a backup or the copy
+// of a database that a replica is bootstrapped from. It returns an ErrNoManifest error if the folder has no manifest
+func VerifyDataFiles(dbPath string) ([]string, error) {
+	return internal.VerifyDataFiles(dbPath)
+}
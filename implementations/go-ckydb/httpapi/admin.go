@@ -0,0 +1,162 @@
+package httpapi
+
+import (
+	_ "embed"
+	"errors"
+	"html/template"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/sopherapps/ckydb/implementations/go-ckydb"
+)
+
+// maxBrowsedKeys is the number of keys listed by the key browser of the admin page
+const maxBrowsedKeys = 200
+
+var ErrAdminUnsupported = errors.New("database does not support the admin page")
+
+//go:embed admin.html
+var adminHTML string
+
+var adminTemplate = template.Must(template.New("admin").Parse(adminHTML))
+
+// adminDB is the database of the admin page, as implemented by *ckydb.Ckydb
+type adminDB = ckydb.Database
+
+// adminPage is the data of the admin page template
+type adminPage struct {
+	Stats    ckydb.Stats
+	Ops      []string
+	Tasks    []ckydb.TaskStatus
+	Prefix   string
+	Keys     []string
+	More     bool
+	Key      string
+	Value    string
+	Found    bool
+	CanWrite bool
+	Error    string
+}
+
+// WithAdminUI serves an admin page at /admin for support engineers inspecting a live database, showing its
+// stats and background tasks, with a key browser searching by prefix, a value viewer and editor,
+// and buttons to vacuum and compact. Only the holders of credentials whose ACL has no prefixes
+// may see it, and only those whose ACL is not read-only may change anything. NewServer returns an
+// ErrAdminUnsupported error if the database is not a *ckydb.Ckydb or does not have the same methods
+func WithAdminUI() Option {
+	return func(s *Server) {
+		s.hasAdminUI = true
+	}
+}
+
+// registerAdminRoutes adds the routes of the admin page to the server
+func (s *Server) registerAdminRoutes() error {
+	db, ok := s.db.(adminDB)
+	if !ok {
+		return ErrAdminUnsupported
+	}
+
+	s.mux.HandleFunc("GET /admin", restrict(readAccess, func(w http.ResponseWriter, r *http.Request) {
+		renderAdminPage(w, r, db, http.StatusOK, "")
+	}))
+	s.mux.HandleFunc("POST /admin/set", restrict(writeAccess, sameSiteOnly(func(w http.ResponseWriter, r *http.Request) {
+		key := r.PostFormValue("key")
+		runAdminAction(w, r, db, key, func() error { return db.Set(key, r.PostFormValue("value")) })
+	})))
+	s.mux.HandleFunc("POST /admin/delete", restrict(writeAccess, sameSiteOnly(func(w http.ResponseWriter, r *http.Request) {
+		runAdminAction(w, r, db, "", func() error { return db.Delete(r.PostFormValue("key")) })
+	})))
+	s.mux.HandleFunc("POST /admin/vacuum", restrict(writeAccess, sameSiteOnly(func(w http.ResponseWriter, r *http.Request) {
+		runAdminAction(w, r, db, r.PostFormValue("key"), db.Vacuum)
+	})))
+	s.mux.HandleFunc("POST /admin/compact", restrict(writeAccess, sameSiteOnly(func(w http.ResponseWriter, r *http.Request) {
+		runAdminAction(w, r, db, r.PostFormValue("key"), db.Compact)
+	})))
+	return nil
+}
+
+// sameSiteOnly wraps the handler of an admin form so that it rejects requests with 403 Forbidden if they are
+// posted from another site, as browsers send the basic auth credentials of the admin page along with
+// requests from any site
+func sameSiteOnly(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if isCrossSite(r) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		handler(w, r)
+	}
+}
+
+// runAdminAction runs the action of an admin form, then redirects back to the admin page showing
+// the given key, or shows the page with the error of the action
+func runAdminAction(w http.ResponseWriter, r *http.Request, db adminDB, key string, action func() error) {
+	err := action()
+	if err != nil {
+		renderAdminPage(w, r, db, statusOf(err), err.Error())
+		return
+	}
+
+	query := url.Values{"prefix": {r.PostFormValue("prefix")}}
+	if key != "" {
+		query.Set("key", key)
+	}
+	http.Redirect(w, r, "/admin?"+query.Encode(), http.StatusSeeOther)
+}
+
+// renderAdminPage renders the admin page, listing the keys starting with the prefix in the query, and showing
+// the value of its key if any
+func renderAdminPage(w http.ResponseWriter, r *http.Request, db adminDB, status int, errMsg string) {
+	acl, _ := r.Context().Value(aclContextKey{}).(ACL)
+	page := adminPage{
+		Stats:    db.Stats(),
+		Tasks:    db.Tasks(),
+		Prefix:   r.FormValue("prefix"),
+		Key:      r.FormValue("key"),
+		CanWrite: acl.allows(writeAccess, ""),
+		Error:    errMsg,
+	}
+
+	for op := range page.Stats.Ops {
+		page.Ops = append(page.Ops, op)
+	}
+	sort.Strings(page.Ops)
+
+	for key := range db.Prefix(page.Prefix) {
+		if len(page.Keys) == maxBrowsedKeys {
+			page.More = true
+			break
+		}
+		page.Keys = append(page.Keys, key)
+	}
+
+	if page.Key != "" {
+		value, err := db.Get(page.Key)
+		page.Value, page.Found = value, err == nil
+		if err != nil && !errors.Is(err, ckydb.ErrNotFound) && page.Error == "" {
+			page.Error = err.Error()
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	_ = adminTemplate.Execute(w, page)
+}
+
+// isCrossSite checks whether the request was sent by a browser from another site than the admin page
+func isCrossSite(r *http.Request) bool {
+	if site := r.Header.Get("Sec-Fetch-Site"); site != "" {
+		return site != "same-origin" && site != "none"
+	}
+
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return false
+	}
+
+	u, err := url.Parse(origin)
+	return err != nil || !strings.EqualFold(u.Host, r.Host)
+}
@@ -0,0 +1,101 @@
+package httpapi
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// ACL restricts what the holder of a token or password may do. The zero ACL grants full access
+type ACL struct {
+	// ReadOnly only allows getting keys
+	ReadOnly bool
+	// Prefixes, if not empty, only allows access to the keys starting with one of them,
+	// and forbids clearing the database
+	Prefixes []string
+}
+
+// access is the kind of access a route needs
+type access int
+
+const (
+	readAccess access = iota
+	writeAccess
+)
+
+// credential is a token or password along with the ACL of those holding it
+type credential struct {
+	secret string
+	acl    ACL
+}
+
+// aclContextKey is the key of the ACL of the credentials of a request in its context
+type aclContextKey struct{}
+
+// allows checks whether the ACL grants the given access to the key, an empty key standing for all keys
+func (a ACL) allows(access access, key string) bool {
+	if access == writeAccess && a.ReadOnly {
+		return false
+	}
+
+	if len(a.Prefixes) == 0 {
+		return true
+	}
+
+	for _, prefix := range a.Prefixes {
+		if key != "" && strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// authenticate returns the ACL of the token, or of the password of the user, that the request has.
+// All requests get full access if the server has neither tokens nor passwords
+func (s *Server) authenticate(r *http.Request) (ACL, bool) {
+	if len(s.tokens) == 0 && len(s.passwords) == 0 {
+		return ACL{}, true
+	}
+
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		for _, t := range s.tokens {
+			if secretsMatch(token, t.secret) {
+				return t.acl, true
+			}
+		}
+		return ACL{}, false
+	}
+
+	user, password, ok := r.BasicAuth()
+	if !ok {
+		return ACL{}, false
+	}
+
+	expected, ok := s.passwords[user]
+	if !ok || !secretsMatch(password, expected.secret) {
+		return ACL{}, false
+	}
+
+	return expected.acl, true
+}
+
+// restrict wraps the handler of a route so that it rejects requests with 403 Forbidden unless the ACL
+// of their credentials grants the given access to the key in their path
+func restrict(access access, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		acl, _ := r.Context().Value(aclContextKey{}).(ACL)
+		if !acl.allows(access, r.PathValue("key")) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		handler(w, r)
+	}
+}
+
+// secretsMatch compares the given secret with the expected one in constant time,
+// so the time taken does not leak how much of it is right
+func secretsMatch(secret string, expected string) bool {
+	return subtle.ConstantTimeCompare([]byte(secret), []byte(expected)) == 1
+}
@@ -0,0 +1,143 @@
+package httpapi
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/sopherapps/ckydb/implementations/go-ckydb"
+)
+
+// The ops of the commands of a batch
+const (
+	OpGet    = "get"
+	OpSet    = "set"
+	OpDelete = "delete"
+)
+
+// Command is one of the commands of a batch, sent to POST /batch as a JSON array
+// e.g. [{"op":"set","key":"cow","value":"500 months"},{"op":"get","key":"cow"}], or as an array
+// encoded with the codec of the server, so that many keys
+// are got or set in one round-trip. The commands are run in order, but not atomically, so other
+// requests may change the keys in between. Consecutive gets are run at once with GetMany if the
+// database has it, loading each data file at most once
+type Command struct {
+	// Op is OpGet, OpSet or OpDelete
+	Op    string `json:"op" msgpack:"op"`
+	Key   string `json:"key" msgpack:"key"`
+	Value string `json:"value,omitempty" msgpack:"value"`
+}
+
+// CommandResult is the result of a command of a batch, at the same index as the command in
+// the array of the response. Status is the status code that the command would have had
+// as a request of its own
+type CommandResult struct {
+	Status int    `json:"status" msgpack:"status"`
+	Value  string `json:"value,omitempty" msgpack:"value"`
+	Error  string `json:"error,omitempty" msgpack:"error"`
+}
+
+// batch runs the commands in the request body, responding with their results. The whole batch is
+// rejected, running none of its commands, if any of them is invalid or not allowed by the ACL
+func (s *Server) batch(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	var commands []Command
+	err = s.codec.Unmarshal(body, &commands)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	acl, _ := r.Context().Value(aclContextKey{}).(ACL)
+	for i, cmd := range commands {
+		access := writeAccess
+		switch cmd.Op {
+		case OpGet:
+			access = readAccess
+		case OpSet, OpDelete:
+		default:
+			http.Error(w, fmt.Sprintf("command %d: unknown op %q", i, cmd.Op), http.StatusBadRequest)
+			return
+		}
+
+		if !acl.allows(access, cmd.Key) {
+			http.Error(w, fmt.Sprintf("command %d: forbidden", i), http.StatusForbidden)
+			return
+		}
+	}
+
+	encoded, err := s.codec.Marshal(s.runBatch(commands))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", s.codec.ContentType())
+	_, _ = w.Write(encoded)
+}
+
+// runBatch runs the commands in order, returning their results
+func (s *Server) runBatch(commands []Command) []CommandResult {
+	results := make([]CommandResult, len(commands))
+	for i := 0; i < len(commands); i++ {
+		cmd := commands[i]
+		switch cmd.Op {
+		case OpGet:
+			end := i + 1
+			for end < len(commands) && commands[end].Op == OpGet {
+				end++
+			}
+
+			s.runGets(commands[i:end], results[i:end])
+			i = end - 1
+		case OpSet:
+			results[i] = resultOf("", s.db.Set(cmd.Key, cmd.Value), http.StatusNoContent)
+		case OpDelete:
+			results[i] = resultOf("", s.db.Delete(cmd.Key), http.StatusNoContent)
+		}
+	}
+
+	return results
+}
+
+// runGets gets the keys of the consecutive get commands into their results, at once if the database has GetMany
+func (s *Server) runGets(commands []Command, results []CommandResult) {
+	db, ok := s.db.(interface {
+		GetMany(keys []string) []ckydb.Result
+	})
+	if !ok {
+		for i, cmd := range commands {
+			value, err := s.db.Get(cmd.Key)
+			results[i] = resultOf(value, err, http.StatusOK)
+		}
+		return
+	}
+
+	keys := make([]string, len(commands))
+	for i, cmd := range commands {
+		keys[i] = cmd.Key
+	}
+
+	for i, result := range db.GetMany(keys) {
+		err := result.Err
+		if err == nil && !result.Found {
+			err = ckydb.ErrNotFound
+		}
+		results[i] = resultOf(result.Value, err, http.StatusOK)
+	}
+}
+
+// resultOf returns the result of a command that returned the given value and error, and that
+// has the given status if it succeeded
+func resultOf(value string, err error, status int) CommandResult {
+	if err != nil {
+		return CommandResult{Status: statusOf(err), Error: err.Error()}
+	}
+
+	return CommandResult{Status: status, Value: value}
+}
@@ -0,0 +1,281 @@
+// Package httpapi serves a ckydb database over HTTP, so other processes and hosts can use it.
+//
+// The routes are:
+//
+//	GET /keys/{key}       the value of the key, 404 Not Found if it does not exist
+//	PUT /keys/{key}       set the key to the request body
+//	DELETE /keys/{key}    delete the key, 404 Not Found if it does not exist
+//	DELETE /keys          delete all keys
+//	POST /batch           run an array of commands, see Command
+//
+// Keys may contain slashes. Values are sent as the plain text bodies of requests and responses, and errors as
+// plain text messages with a matching status code. Batches are encoded in JSON, or with the codec set by WithCodec.
+package httpapi
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+
+	"github.com/sopherapps/ckydb/implementations/go-ckydb"
+	"github.com/sopherapps/ckydb/implementations/go-ckydb/codec"
+)
+
+// maxBodySize is the largest request body accepted, be it the value of a key or a batch
+const maxBodySize = 64 << 20
+
+// retryAfterSeconds is the Retry-After header of the responses to requests failing with retryable errors
+const retryAfterSeconds = "1"
+
+var ErrInvalidTLSConfig = errors.New("invalid tls config")
+
+// TLSConfig holds the paths of the PEM files that the server uses for TLS
+type TLSConfig struct {
+	// CertFile is the certificate presented by the server
+	CertFile string
+	// KeyFile is the private key of the certificate
+	KeyFile string
+	// ClientCAFile, if set, holds the certificate authorities that must have signed the certificates of clients
+	ClientCAFile string
+}
+
+// Option is an option for configuring the Server
+type Option func(s *Server)
+
+// WithToken makes the server accept requests with the header "Authorization: Bearer <token>", with full access
+func WithToken(token string) Option {
+	return WithTokenACL(token, ACL{})
+}
+
+// WithTokenACL makes the server accept requests with the header "Authorization: Bearer <token>",
+// with the access granted by the given ACL
+func WithTokenACL(token string, acl ACL) Option {
+	return func(s *Server) {
+		s.tokens = append(s.tokens, credential{secret: token, acl: acl})
+	}
+}
+
+// WithPassword makes the server accept requests with the basic auth credentials of the given user and password,
+// with full access
+func WithPassword(user string, password string) Option {
+	return WithPasswordACL(user, password, ACL{})
+}
+
+// WithPasswordACL makes the server accept requests with the basic auth credentials of the given user and password,
+// with the access granted by the given ACL
+func WithPasswordACL(user string, password string, acl ACL) Option {
+	return func(s *Server) {
+		s.passwords[user] = credential{secret: password, acl: acl}
+	}
+}
+
+// WithTLS makes the server listen with TLS, using the given certificate, and optionally
+// requiring the certificates of clients to be signed by the given certificate authorities
+func WithTLS(config TLSConfig) Option {
+	return func(s *Server) {
+		s.tlsFiles = &config
+	}
+}
+
+// WithCodec makes the server encode the commands and results of batches with the given codec instead of JSON,
+// sending its content type in the Content-Type header of their responses
+func WithCodec(c codec.Codec) Option {
+	return func(s *Server) {
+		s.codec = c
+	}
+}
+
+// Server is an http.Handler serving the key-value pairs of a database. Once any token or password
+// is set, requests without any of them are rejected with 401 Unauthorized, and requests not allowed
+// by the ACL of their token or password with 403 Forbidden
+type Server struct {
+	db        ckydb.Controller
+	tokens    []credential
+	passwords map[string]credential
+	tlsFiles  *TLSConfig
+	tlsConfig *tls.Config
+	mux       *http.ServeMux
+	codec     codec.Codec
+	// hasAdminUI is whether the admin page is served
+	hasAdminUI bool
+}
+
+// NewServer creates a new Server for the given database. It returns an ErrInvalidTLSConfig error if
+// the files of the TLS config cannot be loaded, and an ErrAdminUnsupported error if the admin page
+// is enabled for a database that does not support it
+func NewServer(db ckydb.Controller, opts ...Option) (*Server, error) {
+	s := &Server{db: db, passwords: map[string]credential{}, mux: http.NewServeMux(), codec: codec.JSON}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if s.tlsFiles != nil {
+		var err error
+		s.tlsConfig, err = s.tlsFiles.load()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	s.mux.HandleFunc("GET /keys/{key...}", restrict(readAccess, s.get))
+	s.mux.HandleFunc("PUT /keys/{key...}", restrict(writeAccess, s.set))
+	s.mux.HandleFunc("DELETE /keys/{key...}", restrict(writeAccess, s.delete))
+	s.mux.HandleFunc("DELETE /keys", restrict(writeAccess, s.clear))
+	s.mux.HandleFunc("POST /batch", s.batch)
+	if s.hasAdminUI {
+		err := s.registerAdminRoutes()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// TLSConfig returns the TLS config of the server, or nil if it does not use TLS
+func (s *Server) TLSConfig() *tls.Config {
+	return s.tlsConfig
+}
+
+// ListenAndServe listens on the TCP address addr, with TLS if it is configured, and serves requests
+// until the listener fails
+func (s *Server) ListenAndServe(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	return s.Serve(listener)
+}
+
+// Serve serves requests on the given listener, with TLS if it is configured, until it fails
+func (s *Server) Serve(listener net.Listener) error {
+	if s.tlsConfig != nil {
+		listener = tls.NewListener(listener, s.tlsConfig)
+	}
+
+	return http.Serve(listener, s)
+}
+
+// ServeHTTP authenticates the request and routes it to its handler, along with the ACL of its credentials
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	acl, ok := s.authenticate(r)
+	if !ok {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="ckydb", Basic realm="ckydb"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	s.mux.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), aclContextKey{}, acl)))
+}
+
+// get responds with the value of the key in the path
+func (s *Server) get(w http.ResponseWriter, r *http.Request) {
+	value, err := s.db.Get(r.PathValue("key"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = io.WriteString(w, value)
+}
+
+// set sets the key in the path to the request body
+func (s *Server) set(w http.ResponseWriter, r *http.Request) {
+	value, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	err = s.db.Set(r.PathValue("key"), string(value))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// delete deletes the key in the path
+func (s *Server) delete(w http.ResponseWriter, r *http.Request) {
+	err := s.db.Delete(r.PathValue("key"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// clear deletes all keys
+func (s *Server) clear(w http.ResponseWriter, r *http.Request) {
+	err := s.db.Clear()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeError responds with the message of the error and the status code matching it, with a Retry-After
+// header if ckydb.IsRetryable finds the error retryable
+func writeError(w http.ResponseWriter, err error) {
+	if ckydb.IsRetryable(err) {
+		w.Header().Set("Retry-After", retryAfterSeconds)
+	}
+
+	http.Error(w, err.Error(), statusOf(err))
+}
+
+// statusOf returns the status code matching the error
+func statusOf(err error) int {
+	switch {
+	case errors.Is(err, ckydb.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ckydb.ErrInvalidKeyValue), errors.Is(err, ckydb.ErrReservedKey),
+		errors.Is(err, ckydb.ErrInvalidSeparators):
+		return http.StatusBadRequest
+	case errors.Is(err, ckydb.ErrQuotaExceeded):
+		return http.StatusInsufficientStorage
+	case errors.Is(err, ckydb.ErrFollower), errors.Is(err, ckydb.ErrReadOnly):
+		return http.StatusForbidden
+	case errors.Is(err, ckydb.ErrDatabaseClosed), errors.Is(err, ckydb.ErrNotOpened), errors.Is(err, ckydb.ErrLoading):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// load reads the certificate, key and client certificate authorities into a tls.Config
+func (c TLSConfig) load() (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidTLSConfig, err)
+	}
+
+	config := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	if c.ClientCAFile == "" {
+		return config, nil
+	}
+
+	data, err := os.ReadFile(c.ClientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidTLSConfig, err)
+	}
+
+	config.ClientCAs = x509.NewCertPool()
+	if !config.ClientCAs.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("%w: no certificates in %s", ErrInvalidTLSConfig, c.ClientCAFile)
+	}
+	config.ClientAuth = tls.RequireAndVerifyClientCert
+	return config, nil
+}
@@ -0,0 +1,482 @@
+package httpapi
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sopherapps/ckydb/implementations/go-ckydb"
+	"github.com/sopherapps/ckydb/implementations/go-ckydb/codec"
+	"github.com/sopherapps/ckydb/implementations/go-ckydb/internal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHttpApi(t *testing.T) {
+	dbPath, err := filepath.Abs("testHttpApiDb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = internal.ClearDummyFileDataInDb(dbPath) }()
+
+	// newTestServer returns a server, on a database holding the dummy data, served by an httptest server
+	newTestServer := func(t *testing.T, opts ...Option) (*httptest.Server, *ckydb.Ckydb) {
+		db := connectToTestDb(t, dbPath)
+		server, err := NewServer(db, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ts := httptest.NewServer(server)
+		t.Cleanup(func() {
+			ts.Close()
+			_ = db.Close()
+		})
+		return ts, db
+	}
+
+	t.Run("ServerShouldGetSetAndDeleteKeys", func(t *testing.T) {
+		ts, db := newTestServer(t)
+
+		status, body := doRequest(t, ts.Client(), http.MethodGet, ts.URL+"/keys/cow", "", nil)
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, "500 months", body)
+
+		status, _ = doRequest(t, ts.Client(), http.MethodPut, ts.URL+"/keys/pig", "70 months", nil)
+		assert.Equal(t, http.StatusNoContent, status)
+		value, err := db.Get("pig")
+		assert.Nil(t, err)
+		assert.Equal(t, "70 months", value)
+
+		status, _ = doRequest(t, ts.Client(), http.MethodDelete, ts.URL+"/keys/cow", "", nil)
+		assert.Equal(t, http.StatusNoContent, status)
+		status, _ = doRequest(t, ts.Client(), http.MethodGet, ts.URL+"/keys/cow", "", nil)
+		assert.Equal(t, http.StatusNotFound, status)
+		status, _ = doRequest(t, ts.Client(), http.MethodDelete, ts.URL+"/keys/cow", "", nil)
+		assert.Equal(t, http.StatusNotFound, status)
+
+		status, _ = doRequest(t, ts.Client(), http.MethodPut, ts.URL+"/keys/goat", "678"+internal.TokenSeparator, nil)
+		assert.Equal(t, http.StatusBadRequest, status)
+
+		status, _ = doRequest(t, ts.Client(), http.MethodDelete, ts.URL+"/keys", "", nil)
+		assert.Equal(t, http.StatusNoContent, status)
+		_, err = db.Get("dog")
+		assert.ErrorIs(t, err, ckydb.ErrNotFound)
+	})
+
+	t.Run("ServerShouldRespondToReservedKeysAndInvalidSeparatorsWithBadRequest", func(t *testing.T) {
+		ts, db := newTestServer(t)
+
+		status, _ := doRequest(t, ts.Client(), http.MethodPut, ts.URL+"/keys/"+ckydb.ReservedKeyPrefix+"pig", "70 months", nil)
+		assert.Equal(t, http.StatusBadRequest, status)
+		status, _ = doRequest(t, ts.Client(), http.MethodDelete, ts.URL+"/keys/"+ckydb.ReservedKeyPrefix+"pig", "", nil)
+		assert.Equal(t, http.StatusBadRequest, status)
+
+		server, err := NewServer(failingSetController{Controller: db, err: ckydb.ErrInvalidSeparators})
+		if err != nil {
+			t.Fatal(err)
+		}
+		separatorsTs := httptest.NewServer(server)
+		defer separatorsTs.Close()
+		status, _ = doRequest(t, separatorsTs.Client(), http.MethodPut, separatorsTs.URL+"/keys/pig", "70 months", nil)
+		assert.Equal(t, http.StatusBadRequest, status)
+	})
+
+	t.Run("ServerShouldBeUnavailableOnceTheDatabaseIsClosed", func(t *testing.T) {
+		ts, db := newTestServer(t)
+		assert.Nil(t, db.Close())
+
+		status, _ := doRequest(t, ts.Client(), http.MethodGet, ts.URL+"/keys/cow", "", nil)
+		assert.Equal(t, http.StatusServiceUnavailable, status)
+		status, _ = doRequest(t, ts.Client(), http.MethodPut, ts.URL+"/keys/pig", "70 months", nil)
+		assert.Equal(t, http.StatusServiceUnavailable, status)
+
+		resp, err := ts.Client().Get(ts.URL + "/keys/cow")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		assert.Equal(t, retryAfterSeconds, resp.Header.Get("Retry-After"))
+	})
+
+	t.Run("ServerShouldRejectRequestsWithoutAValidTokenOrPassword", func(t *testing.T) {
+		ts, _ := newTestServer(t, WithToken("s3cret"), WithPassword("admin", "pa55word"))
+
+		cases := []struct {
+			header   string
+			expected int
+		}{
+			{"", http.StatusUnauthorized},
+			{"Bearer wrong", http.StatusUnauthorized},
+			{"Bearer s3cret", http.StatusOK},
+			{basicAuth("admin", "wrong"), http.StatusUnauthorized},
+			{basicAuth("root", "pa55word"), http.StatusUnauthorized},
+			{basicAuth("admin", "pa55word"), http.StatusOK},
+		}
+		for _, c := range cases {
+			status, _ := doRequest(t, ts.Client(), http.MethodGet, ts.URL+"/keys/cow", "", map[string]string{"Authorization": c.header})
+			assert.Equal(t, c.expected, status, c.header)
+		}
+	})
+
+	t.Run("ServerShouldEnforceTheACLsOfTokensAndPasswords", func(t *testing.T) {
+		ts, _ := newTestServer(t,
+			WithToken("app"),
+			WithTokenACL("scraper", ACL{ReadOnly: true}),
+			WithPasswordACL("tenant", "pa55word", ACL{Prefixes: []string{"tenant-1/"}}),
+		)
+
+		cases := []struct {
+			header   string
+			method   string
+			path     string
+			expected int
+		}{
+			{"Bearer app", http.MethodPut, "/keys/pig", http.StatusNoContent},
+			{"Bearer scraper", http.MethodGet, "/keys/pig", http.StatusOK},
+			{"Bearer scraper", http.MethodPut, "/keys/pig", http.StatusForbidden},
+			{"Bearer scraper", http.MethodDelete, "/keys/pig", http.StatusForbidden},
+			{"Bearer scraper", http.MethodDelete, "/keys", http.StatusForbidden},
+			{basicAuth("tenant", "pa55word"), http.MethodPut, "/keys/tenant-1/pig", http.StatusNoContent},
+			{basicAuth("tenant", "pa55word"), http.MethodGet, "/keys/tenant-1/pig", http.StatusOK},
+			{basicAuth("tenant", "pa55word"), http.MethodGet, "/keys/pig", http.StatusForbidden},
+			{basicAuth("tenant", "pa55word"), http.MethodPut, "/keys/tenant-2/pig", http.StatusForbidden},
+			{basicAuth("tenant", "pa55word"), http.MethodDelete, "/keys", http.StatusForbidden},
+			{basicAuth("tenant", "pa55word"), http.MethodDelete, "/keys/tenant-1/pig", http.StatusNoContent},
+			{"Bearer app", http.MethodDelete, "/keys", http.StatusNoContent},
+		}
+		for _, c := range cases {
+			status, _ := doRequest(t, ts.Client(), c.method, ts.URL+c.path, "70 months", map[string]string{"Authorization": c.header})
+			assert.Equal(t, c.expected, status, "%s %s %s", c.header, c.method, c.path)
+		}
+	})
+
+	t.Run("BatchShouldRunCommandsInOrder", func(t *testing.T) {
+		ts, db := newTestServer(t)
+
+		body := `[
+			{"op": "get", "key": "cow"},
+			{"op": "set", "key": "pig", "value": "70 months"},
+			{"op": "get", "key": "pig"},
+			{"op": "get", "key": "goat"},
+			{"op": "get", "key": "horse"},
+			{"op": "delete", "key": "goat"},
+			{"op": "delete", "key": "goat"},
+			{"op": "set", "key": "dog", "value": "23` + internal.TokenSeparator + `"},
+			{"op": "get", "key": "goat"}
+		]`
+		status, resp := doRequest(t, ts.Client(), http.MethodPost, ts.URL+"/batch", body, nil)
+		assert.Equal(t, http.StatusOK, status)
+
+		var results []CommandResult
+		err := json.Unmarshal([]byte(resp), &results)
+		assert.Nil(t, err)
+		statuses := make([]int, len(results))
+		for i, result := range results {
+			statuses[i] = result.Status
+		}
+		assert.Equal(t, []int{200, 204, 200, 200, 404, 204, 404, 400, 404}, statuses)
+		assert.Equal(t, "500 months", results[0].Value)
+		assert.Equal(t, "70 months", results[2].Value)
+		assert.Equal(t, "678 months", results[3].Value)
+		assert.Equal(t, ckydb.ErrNotFound.Error(), results[4].Error)
+
+		value, err := db.Get("pig")
+		assert.Nil(t, err)
+		assert.Equal(t, "70 months", value)
+	})
+
+	t.Run("BatchShouldUseTheCodecOfTheServer", func(t *testing.T) {
+		ts, db := newTestServer(t, WithCodec(codec.Msgpack))
+
+		body, err := codec.Msgpack.Marshal([]Command{{Op: OpSet, Key: "pig", Value: "70 months"}, {Op: OpGet, Key: "cow"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := ts.Client().Post(ts.URL+"/batch", codec.Msgpack.ContentType(), strings.NewReader(string(body)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "application/msgpack", resp.Header.Get("Content-Type"))
+
+		var results []CommandResult
+		err = codec.Msgpack.Unmarshal(data, &results)
+		assert.Nil(t, err)
+		assert.Equal(t, []CommandResult{{Status: http.StatusNoContent}, {Status: http.StatusOK, Value: "500 months"}}, results)
+
+		value, err := db.Get("pig")
+		assert.Nil(t, err)
+		assert.Equal(t, "70 months", value)
+	})
+
+	t.Run("BatchShouldRunNothingIfAnyCommandIsRejected", func(t *testing.T) {
+		ts, db := newTestServer(t, WithToken("app"), WithTokenACL("scraper", ACL{ReadOnly: true}))
+
+		body := `[{"op": "set", "key": "horse", "value": "20 months"}, {"op": "get", "key": "cow"}]`
+		status, _ := doRequest(t, ts.Client(), http.MethodPost, ts.URL+"/batch", body, map[string]string{"Authorization": "Bearer scraper"})
+		assert.Equal(t, http.StatusForbidden, status)
+		body = `[{"op": "set", "key": "horse", "value": "20 months"}, {"op": "incr", "key": "cow"}]`
+		status, _ = doRequest(t, ts.Client(), http.MethodPost, ts.URL+"/batch", body, map[string]string{"Authorization": "Bearer app"})
+		assert.Equal(t, http.StatusBadRequest, status)
+		status, _ = doRequest(t, ts.Client(), http.MethodPost, ts.URL+"/batch", "[{", map[string]string{"Authorization": "Bearer app"})
+		assert.Equal(t, http.StatusBadRequest, status)
+
+		_, err := db.Get("horse")
+		assert.ErrorIs(t, err, ckydb.ErrNotFound)
+	})
+
+	t.Run("AdminUIShouldShowAndEditTheDatabase", func(t *testing.T) {
+		ts, db := newTestServer(t, WithAdminUI(), WithPassword("admin", "pa55word"), WithPasswordACL("support", "pa55word", ACL{ReadOnly: true}))
+		admin := map[string]string{"Authorization": basicAuth("admin", "pa55word")}
+		form := map[string]string{"Authorization": basicAuth("admin", "pa55word"), "Content-Type": "application/x-www-form-urlencoded"}
+
+		status, body := doRequest(t, ts.Client(), http.MethodGet, ts.URL+"/admin?prefix=f&key=fish", "", admin)
+		assert.Equal(t, http.StatusOK, status)
+		assert.Contains(t, body, "<td>vacuum</td>")
+		assert.Contains(t, body, ">fish</a>")
+		assert.NotContains(t, body, ">cow</a>")
+		assert.Contains(t, body, "8990 months</textarea>")
+
+		status, body = doRequest(t, ts.Client(), http.MethodPost, ts.URL+"/admin/set", "key=fish&value=8991+months&prefix=f", form)
+		assert.Equal(t, http.StatusOK, status)
+		assert.Contains(t, body, "8991 months</textarea>")
+		value, err := db.Get("fish")
+		assert.Nil(t, err)
+		assert.Equal(t, "8991 months", value)
+
+		for _, action := range []string{"delete", "vacuum", "compact"} {
+			status, _ = doRequest(t, ts.Client(), http.MethodPost, ts.URL+"/admin/"+action, "key=fish", form)
+			assert.Equal(t, http.StatusOK, status, action)
+		}
+		_, err = db.Get("fish")
+		assert.ErrorIs(t, err, ckydb.ErrNotFound)
+		assert.Equal(t, int64(1), db.Stats().Ops["vacuum"])
+
+		// forms posted from other sites are rejected
+		form["Origin"] = "https://example.com"
+		status, _ = doRequest(t, ts.Client(), http.MethodPost, ts.URL+"/admin/set", "key=fish&value=1", form)
+		assert.Equal(t, http.StatusForbidden, status)
+
+		support := map[string]string{"Authorization": basicAuth("support", "pa55word"), "Content-Type": "application/x-www-form-urlencoded"}
+		status, body = doRequest(t, ts.Client(), http.MethodGet, ts.URL+"/admin?key=cow", "", support)
+		assert.Equal(t, http.StatusOK, status)
+		assert.Contains(t, body, "<pre>500 months</pre>")
+		assert.NotContains(t, body, "/admin/vacuum")
+		status, _ = doRequest(t, ts.Client(), http.MethodPost, ts.URL+"/admin/set", "key=cow&value=1", support)
+		assert.Equal(t, http.StatusForbidden, status)
+	})
+
+	t.Run("AdminUIShouldNeedADatabaseSupportingIt", func(t *testing.T) {
+		ring, err := ckydb.NewRing([]ckydb.Controller{connectToTestDb(t, dbPath)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = NewServer(ring, WithAdminUI())
+		assert.ErrorIs(t, err, ErrAdminUnsupported)
+	})
+
+	t.Run("ServerShouldServeOverTLSRequiringClientCertificates", func(t *testing.T) {
+		certs := newTestCertificates(t)
+		db := connectToTestDb(t, dbPath)
+		defer func() { _ = db.Close() }()
+		server, err := NewServer(db, WithTLS(TLSConfig{CertFile: certs.serverCert, KeyFile: certs.serverKey, ClientCAFile: certs.ca}))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		go func() { _ = server.Serve(listener) }()
+		defer func() { _ = listener.Close() }()
+		url := "https://" + listener.Addr().String() + "/keys/cow"
+
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: certs.pool}}}
+		_, err = client.Get(url)
+		assert.NotNil(t, err)
+
+		client = &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: certs.pool, Certificates: []tls.Certificate{certs.client}}}}
+		status, body := doRequest(t, client, http.MethodGet, url, "", nil)
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, "500 months", body)
+	})
+
+	t.Run("NewServerShouldFailForInvalidTLSFiles", func(t *testing.T) {
+		certs := newTestCertificates(t)
+		configs := []TLSConfig{
+			{CertFile: certs.serverCert, KeyFile: filepath.Join(t.TempDir(), "missing.pem")},
+			{CertFile: certs.serverCert, KeyFile: certs.serverKey, ClientCAFile: certs.serverKey},
+		}
+		for _, config := range configs {
+			_, err := NewServer(nil, WithTLS(config))
+			assert.ErrorIs(t, err, ErrInvalidTLSConfig)
+		}
+	})
+}
+
+// testCertificates are the PEM files of a certificate authority, and of the server and client certificates it signed
+type testCertificates struct {
+	ca         string
+	serverCert string
+	serverKey  string
+	pool       *x509.CertPool
+	client     tls.Certificate
+}
+
+// newTestCertificates generates the certificates of a test certificate authority, server and client
+func newTestCertificates(t *testing.T) testCertificates {
+	dir := t.TempDir()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ckydb test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caCert, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// sign returns the PEM encoded certificate and key of a new certificate signed by the certificate authority
+	sign := func(serial int64, usage x509.ExtKeyUsage) ([]byte, []byte) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			Subject:      pkix.Name{CommonName: "localhost"},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+			IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, caKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keyDER, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	}
+
+	certs := testCertificates{
+		ca:         filepath.Join(dir, "ca.pem"),
+		serverCert: filepath.Join(dir, "server.pem"),
+		serverKey:  filepath.Join(dir, "server-key.pem"),
+		pool:       x509.NewCertPool(),
+	}
+	certs.pool.AddCert(caCert)
+	serverCert, serverKey := sign(2, x509.ExtKeyUsageServerAuth)
+	clientCert, clientKey := sign(3, x509.ExtKeyUsageClientAuth)
+	certs.client, err = tls.X509KeyPair(clientCert, clientKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string][]byte{
+		certs.ca:         pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}),
+		certs.serverCert: serverCert,
+		certs.serverKey:  serverKey,
+	}
+	for path, data := range files {
+		err = os.WriteFile(path, data, 0666)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return certs
+}
+
+// doRequest sends a request with the given body and headers, returning the status code and body of the response
+func doRequest(t *testing.T, client *http.Client, method string, url string, body string, headers map[string]string) (int, string) {
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, string(data)
+}
+
+// basicAuth returns the Authorization header of basic auth with the given credentials
+func basicAuth(user string, password string) string {
+	req := &http.Request{Header: http.Header{}}
+	req.SetBasicAuth(user, password)
+	return req.Header.Get("Authorization")
+}
+
+// failingSetController is a ckydb.Controller whose sets fail with the given error
+type failingSetController struct {
+	ckydb.Controller
+	err error
+}
+
+func (c failingSetController) Set(key string, value string) error {
+	return c.err
+}
+
+func connectToTestDb(t *testing.T, dbPath string) *ckydb.Ckydb {
+	err := internal.ClearDummyFileDataInDb(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = internal.AddDummyFileDataInDb(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := ckydb.Connect(dbPath, 4, 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
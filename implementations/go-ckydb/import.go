@@ -0,0 +1,128 @@
+package ckydb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/sopherapps/ckydb/implementations/go-ckydb/codec"
+	"github.com/sopherapps/ckydb/implementations/go-ckydb/internal"
+)
+
+// ImportPolicy decides what ImportJSON does with keys that already exist in the database
+type ImportPolicy int
+
+const (
+	// Overwrite replaces the values of existing keys with the imported ones
+	Overwrite ImportPolicy = iota
+	// SkipExisting keeps the values of existing keys, importing only the new keys
+	SkipExisting
+	// FailOnConflict imports nothing if any of the imported keys already exists
+	FailOnConflict
+)
+
+// ImportResult are the number of keys inserted, skipped and overwritten by ImportJSON
+type ImportResult struct {
+	Inserted    int
+	Skipped     int
+	Overwritten int
+}
+
+// ImportJSON sets the key-value pairs in the JSON object read from r, as written by ExportJSON,
+// treating keys that already exist as the policy dictates, just like Import
+func (c *Ckydb) ImportJSON(r io.Reader, policy ImportPolicy) (ImportResult, error) {
+	data := map[string]string{}
+	err := json.NewDecoder(r).Decode(&data)
+	if err != nil {
+		return ImportResult{}, err
+	}
+
+	return c.Import(data, policy)
+}
+
+// ImportWith sets the key-value pairs of the map[string]string read from r, as written by ExportWith
+// with the same codec, treating keys that already exist as the policy dictates, just like Import
+func (c *Ckydb) ImportWith(r io.Reader, cd codec.Codec, policy ImportPolicy) (ImportResult, error) {
+	encoded, err := io.ReadAll(r)
+	if err != nil {
+		return ImportResult{}, err
+	}
+
+	data := map[string]string{}
+	err = cd.Unmarshal(encoded, &data)
+	if err != nil {
+		return ImportResult{}, err
+	}
+
+	return c.Import(data, policy)
+}
+
+// Import sets the given key-value pairs, treating keys that already exist as the policy dictates.
+// The keys are set in ascending order. It returns an ErrConflict error, having imported nothing,
+// if the policy is FailOnConflict and some key already exists. If setting a key fails,
+// the keys set before it stay imported
+func (c *Ckydb) Import(data map[string]string, policy ImportPolicy) (ImportResult, error) {
+	var result ImportResult
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	// keys with the same normalized form are imported as one, with the value of the last of them
+	if c.keyNormalization != NormalizeNone {
+		normalized := make(map[string]string, len(data))
+		for _, key := range keys {
+			normalized[c.normalizeKey(key)] = data[key]
+		}
+
+		data = normalized
+		keys = keys[:0]
+		for key := range data {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+	}
+
+	c.mutLock.Lock()
+	defer c.mutLock.Unlock()
+
+	err := c.checkState()
+	if err != nil {
+		return result, err
+	}
+
+	if policy == FailOnConflict {
+		for _, key := range keys {
+			if c.store.Has(key) {
+				return result, fmt.Errorf("%w: %s", ErrConflict, key)
+			}
+		}
+	}
+
+	for _, key := range keys {
+		exists := c.store.Has(key)
+		if exists && policy == SkipExisting {
+			result.Skipped++
+			continue
+		}
+
+		err := c.instrument(opSet, key, func(st *internal.OpStats) error {
+			return c.store.SetWithStats(key, data[key], st)
+		})
+		if err != nil {
+			return result, err
+		}
+
+		c.replicate(context.Background(), OpSet, key, data[key])
+		if exists {
+			result.Overwritten++
+		} else {
+			result.Inserted++
+		}
+	}
+
+	return result, nil
+}
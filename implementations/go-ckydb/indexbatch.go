@@ -0,0 +1,45 @@
+package ckydb
+
+import (
+	"time"
+
+	"github.com/sopherapps/ckydb/implementations/go-ckydb/internal"
+)
+
+// IndexBatchPolicy configures the batching of the index entries of new keys
+type IndexBatchPolicy struct {
+	// MaxKeys is the number of new keys whose index entries are buffered before they are appended to the index file
+	MaxKeys int
+	// MaxDelay is the longest the index entry of a new key is buffered, enforced by a background task.
+	// Zero means that it is only bounded by MaxKeys, Flush and Close
+	MaxDelay time.Duration
+}
+
+// WithIndexBatching buffers the index entries of new keys and appends them to the index file in batches, as
+// configured by the policy, rather than on every Set of a new key. Close and Flush append any buffered entries.
+// The entries lost in a crash are rebuilt from the log file the next time the database is connected to,
+// while followers only see the keys of the entries appended so far
+func WithIndexBatching(policy IndexBatchPolicy) Option {
+	return func(o *options) {
+		o.storeOptions = append(o.storeOptions, internal.WithIndexBatching(policy.MaxKeys, policy.MaxDelay))
+		o.indexBatchPolicy = &policy
+	}
+}
+
+// Flush appends the index entries buffered by WithIndexBatching to the index file
+func (c *Ckydb) Flush() error {
+	c.mutLock.Lock()
+	defer c.mutLock.Unlock()
+
+	return c.instrument(opFlush, "", c.store.FlushWithStats)
+}
+
+// flush flushes the database, logging any error. It is the work of the index flush task
+func (c *Ckydb) flush() error {
+	err := c.Flush()
+	if err != nil {
+		c.logger.Printf("error: %s", err)
+	}
+
+	return err
+}
@@ -0,0 +1,53 @@
+package ckydb
+
+import (
+	"context"
+	"time"
+
+	"github.com/sopherapps/ckydb/implementations/go-ckydb/internal"
+)
+
+// instrument runs fn, the store operation op on the given key (if any), like measure if the database is open.
+// Otherwise it returns an ErrNotOpened, an ErrLoading or an ErrDatabaseClosed error without running it
+func (c *Ckydb) instrument(op string, key string, fn func(st *internal.OpStats) error) error {
+	return c.instrumentContext(context.Background(), op, key, fn)
+}
+
+// instrumentContext is instrument, the span of the operation, if any, being a child of the span of ctx
+func (c *Ckydb) instrumentContext(ctx context.Context, op string, key string, fn func(st *internal.OpStats) error) error {
+	err := c.checkState()
+	if err != nil {
+		return err
+	}
+
+	return c.measureContext(ctx, op, key, fn)
+}
+
+// measure runs fn, the store operation op on the given key (if any), counting it in
+// the stats, tracing it if tracing is enabled, logging it if it is slow and notifying the onOperation callback
+func (c *Ckydb) measure(op string, key string, fn func(st *internal.OpStats) error) error {
+	return c.measureContext(context.Background(), op, key, fn)
+}
+
+// measureContext is measure, the span of the operation, if any, being a child of the span of ctx
+func (c *Ckydb) measureContext(ctx context.Context, op string, key string, fn func(st *internal.OpStats) error) error {
+	var st *internal.OpStats
+	span := c.startSpan(ctx, op)
+	if span != nil || c.slowOpThreshold > 0 || c.onOperation != nil {
+		st = &internal.OpStats{}
+	}
+
+	c.recordForegroundOp(op)
+	start := time.Now()
+	err := fn(st)
+	duration := time.Since(start)
+
+	c.counters.record(op, duration, err)
+	if span != nil {
+		endSpan(span, key, st, err)
+	}
+	c.logSlowOp(op, key, duration, st, err)
+	c.notifyOperation(op, key, duration, st, err)
+
+	return wrapOpError(op, key, err)
+}
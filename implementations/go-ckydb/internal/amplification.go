@@ -0,0 +1,56 @@
+package internal
+
+import "sync/atomic"
+
+// ioCounters count the bytes that gets read from disk against those of the values they return, and the bytes
+// written to disk against those of the keys and values set, for the read and write amplification of Stats
+type ioCounters struct {
+	hotGets              atomic.Int64
+	coldGets             atomic.Int64
+	getBytesRead         atomic.Int64
+	valueBytesRead       atomic.Int64
+	bytesWritten         atomic.Int64
+	keyValueBytesWritten atomic.Int64
+}
+
+// recordHotGet records a get served from the memtable or the cache
+func (c *ioCounters) recordHotGet() {
+	c.hotGets.Add(1)
+}
+
+// recordColdGet records a get that read the given number of bytes of data files from disk, the data file it needed
+// being neither in the memtable nor in the cache
+func (c *ioCounters) recordColdGet(bytesRead int64) {
+	c.coldGets.Add(1)
+	c.getBytesRead.Add(bytesRead)
+}
+
+// countingFileSystem is a FileSystem counting the bytes written through it
+type countingFileSystem struct {
+	FileSystem
+	bytesWritten *atomic.Int64
+}
+
+func (fs countingFileSystem) WriteFile(path string, data []byte) error {
+	err := fs.FileSystem.WriteFile(path, data)
+	if err == nil {
+		fs.bytesWritten.Add(int64(len(data)))
+	}
+
+	return err
+}
+
+func (fs countingFileSystem) AppendFile(path string, data []byte) (int, error) {
+	n, err := fs.FileSystem.AppendFile(path, data)
+	fs.bytesWritten.Add(int64(n))
+	return n, err
+}
+
+// ratio returns n over d, or 0 if d is 0
+func ratio(n int64, d int64) float64 {
+	if d == 0 {
+		return 0
+	}
+
+	return float64(n) / float64(d)
+}
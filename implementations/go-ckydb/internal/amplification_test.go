@@ -0,0 +1,125 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAmplification(t *testing.T) {
+	dbPath, err := filepath.Abs("testAmplificationDb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = ClearDummyFileDataInDb(dbPath) }()
+
+	// newStore returns a store loaded on the dummy data
+	newStore := func(t *testing.T) *Store {
+		err := ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = AddDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		store := NewStore(dbPath, 4)
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return store
+	}
+
+	t.Run("GetsShouldCountTheBytesReadFromDisk", func(t *testing.T) {
+		store := newStore(t)
+		info, err := os.Stat(filepath.Join(dbPath, "1655375120328185000.cky"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, key := range []string{"cow", "cow", "goat"} {
+			_, err = store.Get(key)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		stats := store.Stats()
+		assert.Equal(t, int64(2), stats.HotGets)
+		assert.Equal(t, int64(1), stats.ColdGets)
+		assert.Equal(t, info.Size(), stats.GetBytesRead)
+		assert.Equal(t, int64(len("500 months")*2+len("678 months")), stats.ValueBytesRead)
+		assert.Equal(t, float64(info.Size())/float64(stats.ValueBytesRead), stats.ReadAmplification)
+	})
+
+	t.Run("SetsShouldCountTheBytesWrittenToDisk", func(t *testing.T) {
+		store := newStore(t)
+		before := store.Stats()
+		assert.Equal(t, int64(0), before.KeyValueBytesWritten)
+		assert.Equal(t, float64(0), before.WriteAmplification)
+
+		err := store.Set("hey", "English")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the whole log file is rewritten, and the key is appended to the index file
+		logInfo, err := os.Stat(store.currentLogFilePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stats := store.Stats()
+		assert.Equal(t, int64(len("hey")+len("English")), stats.KeyValueBytesWritten)
+		assert.Greater(t, stats.BytesWritten-before.BytesWritten, logInfo.Size())
+		assert.Greater(t, stats.WriteAmplification, 1.0)
+	})
+
+	t.Run("CoalescedReadsOfADataFileShouldEachRecordTheRead", func(t *testing.T) {
+		store := newStore(t)
+		dataFilePath := filepath.Join(dbPath, "1655375120328185000.cky")
+		info, err := os.Stat(dataFilePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		timestampRange := store.getTimestampRangeForKey("1655375120328185000-cow")
+
+		// a read of the data file is held in flight, for the reads started meanwhile to share it
+		started, release := make(chan struct{}), make(chan struct{})
+		go func() {
+			_, _, _ = store.cacheLoadGroup.Do(timestampRange.Start, func() (interface{}, error) {
+				close(started)
+				<-release
+				readSt := &OpStats{}
+				cache, err := store.readCache(timestampRange, readSt)
+				return sharedCacheRead{cache: cache, st: readSt}, err
+			})
+		}()
+		<-started
+
+		stats := make([]OpStats, 4)
+		var wg sync.WaitGroup
+		for i := range stats {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := store.readCacheOnce(timestampRange, &stats[i])
+				assert.Nil(t, err)
+			}()
+		}
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+		wg.Wait()
+
+		for _, st := range stats {
+			assert.Equal(t, info.Size(), st.BytesRead)
+			assert.Equal(t, []string{dataFilePath}, st.FilesTouched)
+		}
+	})
+}
@@ -0,0 +1,128 @@
+package internal
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// BackupToDir makes a copy of the store in a new folder at path, which the store can be loaded from.
+// Data files are hard linked, as the store replaces files instead of modifying them in place, while
+// the other files are copied. The snapshots, archive and oplog folders are left out, as are the
+// checksums, which are written again when the backup is loaded. The backup is built next to path
+// and renamed to it once complete, so an interrupted backup leaves nothing at path.
+// With WithManifest, the data files of the backup are checked against the manifest before it is renamed.
+// It returns an error wrapping fs.ErrExist if there is already something at path
+func (s *Store) BackupToDir(path string) error {
+	_, err := os.Stat(path)
+	if err == nil {
+		return &fs.PathError{Op: "backup", Path: path, Err: fs.ErrExist}
+	}
+	if !os.IsNotExist(err) {
+		return err
+	}
+
+	tmpPath := fmt.Sprintf("%s.%s", path, TempFileExt)
+	err = os.RemoveAll(tmpPath)
+	if err != nil {
+		return err
+	}
+
+	err = s.flushIndex(nil)
+	if err != nil {
+		return err
+	}
+
+	err = s.copyFilesTo(tmpPath)
+	if err == nil {
+		err = s.verifyBackup(tmpPath)
+	}
+	if err != nil {
+		_ = os.RemoveAll(tmpPath)
+		return err
+	}
+
+	return os.Rename(tmpPath, path)
+}
+
+// verifyBackup checks the data files of the backup being built at path against the manifest, if it is enabled,
+// failing with an ErrCorruptedData error naming the first data file that fails its SHA-256 check
+func (s *Store) verifyBackup(path string) error {
+	if !s.isManifestEnabled {
+		return nil
+	}
+
+	damaged, err := verifyDataFiles(path, s.separators)
+	if err != nil {
+		return err
+	}
+	if len(damaged) > 0 {
+		return fmt.Errorf("backing up %s: %w", damaged[0], ErrCorruptedData)
+	}
+
+	return nil
+}
+
+// copyFilesTo links the data files, and copies the other files, of the database folder and of the buckets of
+// WithDataFileBuckets into a new folder at path
+func (s *Store) copyFilesTo(path string) error {
+	buckets, err := getBuckets(s.dbPath)
+	if err != nil {
+		return err
+	}
+
+	for _, folder := range append([]string{""}, buckets...) {
+		err = copyFolderFilesTo(filepath.Join(s.dbPath, folder), filepath.Join(path, folder))
+		if err != nil {
+			return err
+		}
+	}
+
+	return s.linkBlobsTo(path)
+}
+
+// copyFolderFilesTo links the data files, and copies the other files, of the folder at src but not those of its
+// sub-folders into a new folder at dst
+func copyFolderFilesTo(src string, dst string) error {
+	err := os.MkdirAll(dst, 0777)
+	if err != nil {
+		return err
+	}
+
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		from, to := filepath.Join(src, entry.Name()), filepath.Join(dst, entry.Name())
+		switch filepath.Ext(entry.Name()) {
+		case "." + DataFileExt:
+			err = linkOrCopyFile(from, to)
+		case "." + TempFileExt, "." + ChecksumFileExt, "." + BackupFileExt:
+			continue
+		default:
+			err = copyFile(from, to)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// copyFile copies the contents of the file at src to a new file at dst
+func copyFile(src string, dst string) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(dst, data, 0666)
+}
@@ -0,0 +1,92 @@
+package internal
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackup(t *testing.T) {
+	dbPath, err := filepath.Abs("testBackupDb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	backupPath, err := filepath.Abs("testBackupDbBackup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = ClearDummyFileDataInDb(dbPath)
+		_ = os.RemoveAll(backupPath)
+	}()
+
+	// newStore returns a store loaded from the dummy data, with no backup of it yet
+	newStore := func(t *testing.T) *Store {
+		err := ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.RemoveAll(backupPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = AddDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		store := NewStore(dbPath, 320.0/1024)
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return store
+	}
+
+	t.Run("BackupToDirShouldMakeALoadableCopyOfTheStore", func(t *testing.T) {
+		store := newStore(t)
+		err := store.BackupToDir(backupPath)
+		assert.Nil(t, err)
+
+		expected := map[string]string{}
+		for _, k := range store.Keys() {
+			expected[k], err = store.Get(k)
+			assert.Nil(t, err)
+		}
+
+		// later writes to the store do not change the backup
+		assert.Nil(t, store.Set("cow", "501 months"))
+		assert.Nil(t, store.Set("goat", "679 months"))
+		assert.Nil(t, store.Delete("dog"))
+		assert.Nil(t, store.Vacuum())
+
+		backup := NewStore(backupPath, 320.0/1024)
+		err = backup.Load()
+		assert.Nil(t, err)
+		assert.Equal(t, store.dataFiles, backup.dataFiles)
+		got := map[string]string{}
+		for _, k := range backup.Keys() {
+			got[k], err = backup.Get(k)
+			assert.Nil(t, err)
+		}
+		assert.Equal(t, expected, got)
+
+		_, err = os.Stat(backupPath + "." + TempFileExt)
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("BackupToDirShouldNotOverwriteExistingFolders", func(t *testing.T) {
+		store := newStore(t)
+		err := os.MkdirAll(backupPath, 0777)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = store.BackupToDir(backupPath)
+		assert.ErrorIs(t, err, fs.ErrExist)
+	})
+}
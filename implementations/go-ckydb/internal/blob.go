@@ -0,0 +1,227 @@
+package internal
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	BlobsFolderName = "blobs"
+	BlobFileExt     = "blob"
+)
+
+// WithValueDeduplication saves each value of at least the given number of bytes once in the blobs folder, named
+// by the hash of its contents, so that keys with identical values share it. Their records only hold the hash.
+// Like WithValueCompressionThreshold, it only applies to databases created with value flags. Zero means
+// no deduplication
+func WithValueDeduplication(minBytes int) StoreOption {
+	return func(s *Store) {
+		s.dedupThreshold = minBytes
+	}
+}
+
+// WithBlobSpillThreshold saves each value of at least the given number of bytes in a file of its own in the blobs
+// folder, so that rewriting the log or data file of its key does not copy it. Its record only holds the name of
+// the file. Like WithValueCompressionThreshold, it only applies to databases created with value flags.
+// Zero means no spilling
+func WithBlobSpillThreshold(bytes int) StoreOption {
+	return func(s *Store) {
+		s.spillThreshold = bytes
+	}
+}
+
+// saveDedupedBlob saves the value in the blobs folder, named by the hash of its contents, unless it is already
+// there, returning the hash
+func (s *Store) saveDedupedBlob(value string, st *OpStats) (string, error) {
+	sum := sha256.Sum256([]byte(value))
+	hash := hex.EncodeToString(sum[:])
+	path := getBlobFilePath(s.blobsPath(), hash)
+	_, err := os.Stat(path)
+	if err == nil {
+		return hash, nil
+	}
+	if !os.IsNotExist(err) {
+		return "", err
+	}
+
+	err = os.MkdirAll(s.blobsPath(), 0777)
+	if err != nil {
+		return "", err
+	}
+
+	err = s.replaceFile(path, []byte(value))
+	if err != nil {
+		return "", err
+	}
+	st.recordWrite(path, len(value))
+
+	return hash, nil
+}
+
+// saveSpilledBlob saves the value in a new file of a random name in the blobs folder, returning the name
+func (s *Store) saveSpilledBlob(value string, st *OpStats) (string, error) {
+	name, err := newSpilledBlobName()
+	if err != nil {
+		return "", err
+	}
+
+	err = os.MkdirAll(s.blobsPath(), 0777)
+	if err != nil {
+		return "", err
+	}
+
+	path := getBlobFilePath(s.blobsPath(), name)
+	err = s.replaceFile(path, []byte(value))
+	if err != nil {
+		return "", err
+	}
+	st.recordWrite(path, len(value))
+
+	return name, nil
+}
+
+// newSpilledBlobName returns a random name for a spilled blob, which cannot be mistaken for the hash of a deduplicated one
+func newSpilledBlobName() (string, error) {
+	var name [16]byte
+	_, err := rand.Read(name[:])
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(name[:]), nil
+}
+
+// readBlob reads the blob of the given name from the blobs folder at blobsPath,
+// returning an ErrCorruptedData error if it is missing
+func readBlob(blobsPath string, name string) (string, error) {
+	data, err := os.ReadFile(getBlobFilePath(blobsPath, name))
+	if os.IsNotExist(err) {
+		return "", ErrCorruptedData
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
+
+// removeUnreferencedBlobs counts the references to the blobs from the records in the log and data files,
+// removing the blobs that none of them references any more e.g. as their keys have been deleted or set
+// to other values since. It is called at the end of every vacuum, once the deleted records are gone
+func (s *Store) removeUnreferencedBlobs(st *OpStats) error {
+	if !s.valueFlags {
+		return nil
+	}
+
+	blobFiles, err := GetFileOrFolderNamesInFolder(s.blobsPath())
+	if os.IsNotExist(err) || len(blobFiles) == 0 {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	refs, err := s.countBlobReferences()
+	if err != nil {
+		return err
+	}
+
+	for _, filename := range blobFiles {
+		name := strings.TrimSuffix(filename, "."+BlobFileExt)
+		if refs[name] > 0 {
+			continue
+		}
+
+		path := filepath.Join(s.blobsPath(), filename)
+		err = s.fs.Remove(path)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		st.recordFileRemoval(path)
+	}
+
+	return nil
+}
+
+// countBlobReferences returns the number of records in the log and data files referencing each blob
+func (s *Store) countBlobReferences() (map[string]int, error) {
+	filesInFolder, err := getLogAndDataFilenames(s.dbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	refs := map[string]int{}
+	for _, filename := range filesInFolder {
+		data, err := s.separators.readKeyValuesFromFile(filepath.Join(s.dbPath, filename))
+		if err != nil {
+			return nil, err
+		}
+
+		for timestampedKey, sealed := range data {
+			// a tampered record references no blob that can be trusted
+			stored, err := unsealRecord(timestampedKey, sealed, s.recordMACKey())
+			if err != nil {
+				continue
+			}
+
+			if name, ok := blobReference(stored); ok {
+				refs[name]++
+			}
+		}
+	}
+
+	return refs, nil
+}
+
+// blobReference returns the name of the blob that the stored value of a record with value flags references, if any
+func blobReference(stored string) (string, bool) {
+	if stored == "" || (stored[0] != dedupedValueFlag && stored[0] != spilledValueFlag) {
+		return "", false
+	}
+
+	return stored[1:], true
+}
+
+// linkBlobsTo hard links the blobs of the database folder into the blobs folder of path, as blobs are never
+// modified once saved
+func (s *Store) linkBlobsTo(path string) error {
+	blobFiles, err := GetFileOrFolderNamesInFolder(s.blobsPath())
+	if os.IsNotExist(err) || len(blobFiles) == 0 {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Join(path, BlobsFolderName), 0777)
+	if err != nil {
+		return err
+	}
+
+	for _, filename := range blobFiles {
+		if filepath.Ext(filename) != "."+BlobFileExt {
+			continue
+		}
+
+		err = linkOrCopyFile(filepath.Join(s.blobsPath(), filename), filepath.Join(path, BlobsFolderName, filename))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// blobsPath returns the path to the blobs folder of the database
+func (s *Store) blobsPath() string {
+	return filepath.Join(s.dbPath, BlobsFolderName)
+}
+
+// getBlobFilePath returns the path to the blob of the given name in the blobs folder at blobsPath
+func getBlobFilePath(blobsPath string, name string) string {
+	return filepath.Join(blobsPath, name+"."+BlobFileExt)
+}
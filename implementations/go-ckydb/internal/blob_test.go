@@ -0,0 +1,217 @@
+package internal
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValueDeduplication(t *testing.T) {
+	dbPath, err := filepath.Abs("testValueDeduplicationDb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	blob := strings.Repeat("a value stored by many keys ", 100)
+	otherBlob := strings.Repeat("another value stored by many keys ", 100)
+	defer func() { _ = ClearDummyFileDataInDb(dbPath) }()
+
+	// newStore returns a loaded store with value deduplication on an empty database folder
+	newStore := func(t *testing.T) *Store {
+		err := ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		store := NewStore(dbPath, 0.1, WithValueDeduplication(64))
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return store
+	}
+
+	// getBlobFiles returns the names of the files in the blobs folder
+	getBlobFiles := func(t *testing.T) []string {
+		filenames, err := GetFileOrFolderNamesInFolder(filepath.Join(dbPath, BlobsFolderName))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return filenames
+	}
+
+	t.Run("IdenticalValuesShouldShareOneBlob", func(t *testing.T) {
+		store := newStore(t)
+		for _, key := range []string{"cow", "dog", "goat"} {
+			assert.Nil(t, store.Set(key, blob))
+		}
+		assert.Nil(t, store.Set("hen", "5 months"))
+
+		assert.Len(t, getBlobFiles(t), 1)
+		logContents, err := ReadFilesWithExtension(dbPath, LogFileExt)
+		assert.Nil(t, err)
+		assert.NotContains(t, strings.Join(logContents, ""), blob)
+
+		for _, key := range []string{"cow", "dog", "goat"} {
+			value, err := store.Get(key)
+			assert.Nil(t, err)
+			assert.Equal(t, blob, value)
+		}
+		value, err := store.Get("hen")
+		assert.Nil(t, err)
+		assert.Equal(t, "5 months", value)
+	})
+
+	t.Run("VacuumShouldRemoveOnlyUnreferencedBlobs", func(t *testing.T) {
+		store := newStore(t)
+		assert.Nil(t, store.Set("cow", blob))
+		assert.Nil(t, store.Set("dog", blob))
+		assert.Nil(t, store.Set("goat", otherBlob))
+		assert.Len(t, getBlobFiles(t), 2)
+
+		assert.Nil(t, store.Delete("cow"))
+		assert.Nil(t, store.Set("goat", "2 months"))
+		assert.Nil(t, store.Vacuum())
+		assert.Len(t, getBlobFiles(t), 1)
+		value, err := store.Get("dog")
+		assert.Nil(t, err)
+		assert.Equal(t, blob, value)
+
+		assert.Nil(t, store.Delete("dog"))
+		assert.Nil(t, store.Vacuum())
+		assert.Empty(t, getBlobFiles(t))
+	})
+
+	t.Run("SnapshotsAndBackupsShouldKeepTheirBlobs", func(t *testing.T) {
+		store := newStore(t)
+		assert.Nil(t, store.Set("cow", blob))
+
+		snap, err := store.Snapshot()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = snap.Close() }()
+		backupPath := dbPath + "Backup"
+		defer func() { _ = ClearDummyFileDataInDb(backupPath) }()
+		err = store.BackupToDir(backupPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Nil(t, store.Delete("cow"))
+		assert.Nil(t, store.Vacuum())
+		assert.Empty(t, getBlobFiles(t))
+
+		err = snap.ForEach(func(key string, value string) error {
+			assert.Equal(t, "cow", key)
+			assert.Equal(t, blob, value)
+			return nil
+		})
+		assert.Nil(t, err)
+
+		backup := NewStore(backupPath, 0.1)
+		err = backup.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		value, err := backup.Get("cow")
+		assert.Nil(t, err)
+		assert.Equal(t, blob, value)
+	})
+
+	t.Run("MissingBlobsShouldBeReportedAsCorruption", func(t *testing.T) {
+		store := newStore(t)
+		assert.Nil(t, store.Set("cow", blob))
+		for _, filename := range getBlobFiles(t) {
+			err := store.fs.Remove(filepath.Join(dbPath, BlobsFolderName, filename))
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		_, err := store.Get("cow")
+		assert.ErrorIs(t, err, ErrCorruptedData)
+	})
+}
+
+func TestBlobSpill(t *testing.T) {
+	dbPath, err := filepath.Abs("testBlobSpillDb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	blob := strings.Repeat("a very large value ", 1000)
+	defer func() { _ = ClearDummyFileDataInDb(dbPath) }()
+
+	// newStore returns a loaded store spilling values to blobs on an empty database folder
+	newStore := func(t *testing.T) *Store {
+		err := ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		store := NewStore(dbPath, 4, WithBlobSpillThreshold(1024))
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return store
+	}
+
+	// getBlobFiles returns the names of the files in the blobs folder
+	getBlobFiles := func(t *testing.T) []string {
+		filenames, err := GetFileOrFolderNamesInFolder(filepath.Join(dbPath, BlobsFolderName))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return filenames
+	}
+
+	t.Run("LargeValuesShouldBeSpilledToFilesOfTheirOwn", func(t *testing.T) {
+		store := newStore(t)
+		assert.Nil(t, store.Set("cow", blob))
+		assert.Nil(t, store.Set("dog", blob))
+		assert.Nil(t, store.Set("hen", "5 months"))
+
+		assert.Len(t, getBlobFiles(t), 2)
+		logContents, err := ReadFilesWithExtension(dbPath, LogFileExt)
+		assert.Nil(t, err)
+		assert.Less(t, len(strings.Join(logContents, "")), len(blob))
+		// the memtable only holds the flag byte and the name of the blob
+		assert.Len(t, store.memtable[store.index["cow"]], 33)
+
+		for _, key := range []string{"cow", "dog"} {
+			value, err := store.Get(key)
+			assert.Nil(t, err)
+			assert.Equal(t, blob, value)
+		}
+		value, err := store.Get("hen")
+		assert.Nil(t, err)
+		assert.Equal(t, "5 months", value)
+	})
+
+	t.Run("VacuumShouldRemoveTheBlobsOfReplacedAndDeletedValues", func(t *testing.T) {
+		store := newStore(t)
+		assert.Nil(t, store.Set("cow", blob))
+		assert.Nil(t, store.Set("cow", "1"+blob))
+		assert.Nil(t, store.Set("dog", blob))
+		assert.Len(t, getBlobFiles(t), 3)
+
+		assert.Nil(t, store.Delete("dog"))
+		assert.Nil(t, store.Vacuum())
+		assert.Len(t, getBlobFiles(t), 1)
+
+		reloaded := NewStore(dbPath, 4)
+		err := reloaded.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		value, err := reloaded.Get("cow")
+		assert.Nil(t, err)
+		assert.Equal(t, "1"+blob, value)
+	})
+}
@@ -0,0 +1,155 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// WithDataFileBuckets keeps the data files in sub-folders of the database folder, one per period e.g. a day that
+// their timestamps fall in, each named after the timestamp of the start of its period, so that folders with
+// thousands of data files stay quick to read. Load moves the data files into the sub-folders of the given period,
+// or back into the database folder if it is 0 as it is by default, so that a database can switch between layouts
+func WithDataFileBuckets(period time.Duration) StoreOption {
+	return func(s *Store) {
+		s.bucketPeriod = period
+	}
+}
+
+// getBucket returns the name of the sub-folder that WithDataFileBuckets puts the data file of the given
+// timestamp in, or "" if it is put in the database folder
+func (s *Store) getBucket(dataFile string) string {
+	timestamp, err := strconv.ParseInt(dataFile, 10, 64)
+	if s.bucketPeriod <= 0 || err != nil {
+		return ""
+	}
+
+	return strconv.FormatInt(timestamp-timestamp%int64(s.bucketPeriod), 10)
+}
+
+// isBucket checks whether the sub-folder of the database folder with the given name is that of data files,
+// as opposed to e.g. the archive or oplog folders
+func isBucket(name string) bool {
+	_, err := strconv.ParseUint(name, 10, 64)
+	return err == nil
+}
+
+// getBuckets returns the names of the sub-folders of the data files in the database folder at dbPath, sorted
+func getBuckets(dbPath string) ([]string, error) {
+	entries, err := os.ReadDir(dbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var buckets []string
+	for _, entry := range entries {
+		if entry.IsDir() && isBucket(entry.Name()) {
+			buckets = append(buckets, entry.Name())
+		}
+	}
+
+	return buckets, nil
+}
+
+// getLogAndDataFilenames returns the paths, relative to the database folder at dbPath, of the log and data files
+// in it and of the data files in its buckets, whatever the period given to WithDataFileBuckets
+func getLogAndDataFilenames(dbPath string) ([]string, error) {
+	filenames, err := GetFileOrFolderNamesInFolder(dbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, filename := range filenames {
+		if isLogOrDataFile(filename) {
+			paths = append(paths, filename)
+		}
+	}
+
+	buckets, err := getBuckets(dbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, bucket := range buckets {
+		filenames, err := GetFileOrFolderNamesInFolder(filepath.Join(dbPath, bucket))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, filename := range filenames {
+			if filepath.Ext(filename) == "."+DataFileExt {
+				paths = append(paths, filepath.Join(bucket, filename))
+			}
+		}
+	}
+
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// getDataFileFolders returns the folder, relative to the database folder, that each data file of the given
+// relative paths is in, "" being the database folder itself
+func getDataFileFolders(paths []string) map[string]string {
+	folders := make(map[string]string, len(paths))
+	for _, path := range paths {
+		filename := filepath.Base(path)
+		if filepath.Ext(filename) != "."+DataFileExt {
+			continue
+		}
+
+		folder := filepath.Dir(path)
+		if folder == "." {
+			folder = ""
+		}
+		folders[filename[:len(filename)-len(DataFileExt)-1]] = folder
+	}
+
+	return folders
+}
+
+// moveDataFilesToBuckets moves the data files that are not in the folder that WithDataFileBuckets puts them in,
+// removing the buckets that are left empty. A move interrupted midway is finished by the next Load
+func (s *Store) moveDataFilesToBuckets() error {
+	for _, dataFile := range s.dataFiles {
+		folder, bucket := s.dataFileFolders[dataFile], s.getBucket(dataFile)
+		if folder == bucket {
+			continue
+		}
+
+		oldPath := s.getDataFilePath(dataFile)
+		newPath := filepath.Join(s.dbPath, bucket, filepath.Base(oldPath))
+		err := os.MkdirAll(filepath.Dir(newPath), 0777)
+		if err != nil {
+			return err
+		}
+
+		err = s.fs.Rename(oldPath, newPath)
+		if err != nil {
+			return err
+		}
+
+		s.dataFileFolders[dataFile] = bucket
+		s.removeBucketIfEmpty(oldPath)
+	}
+
+	return nil
+}
+
+// createDataFileFolder creates the folder of the data file of the given timestamp if it does not exist
+func (s *Store) createDataFileFolder(dataFile string) error {
+	return os.MkdirAll(filepath.Dir(s.getDataFilePath(dataFile)), 0777)
+}
+
+// removeBucketIfEmpty removes the bucket of the data file that was at the given path once it has no data files
+// left. Failing to is ignored, as the bucket is then still in use
+func (s *Store) removeBucketIfEmpty(dataFilePath string) {
+	folder := filepath.Dir(dataFilePath)
+	if folder == filepath.Clean(s.dbPath) {
+		return
+	}
+
+	_ = os.Remove(folder)
+}
@@ -0,0 +1,108 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDataFileBuckets(t *testing.T) {
+	dbPath, err := filepath.Abs("testDataFileBucketsDb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = ClearDummyFileDataInDb(dbPath) }()
+
+	// the day that the timestamps of the data files of the dummy data fall in
+	bucket := "1655337600000000000"
+
+	// newStore returns a store loaded with the given options on the dummy data
+	newStore := func(t *testing.T, opts ...StoreOption) *Store {
+		err := ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = AddDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		store := NewStore(dbPath, 4, opts...)
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return store
+	}
+
+	t.Run("LoadShouldMoveTheDataFilesIntoTheirBuckets", func(t *testing.T) {
+		store := newStore(t, WithDataFileBuckets(24*time.Hour))
+
+		bucketFiles, err := GetFileOrFolderNamesInFolder(filepath.Join(dbPath, bucket))
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"1655375120328185000.cky", "1655375120328186000.cky"}, bucketFiles)
+		ckyFiles, err := ReadFilesWithExtension(dbPath, DataFileExt)
+		assert.Nil(t, err)
+		assert.Empty(t, ckyFiles)
+
+		value, err := store.Get("cow")
+		assert.Nil(t, err)
+		assert.Equal(t, "500 months", value)
+
+		// the keys of the data files in buckets are vacuumed too
+		err = store.Delete("dog")
+		assert.Nil(t, err)
+		err = store.Vacuum()
+		assert.Nil(t, err)
+		data, err := DefaultSeparators.readKeyValuesFromFile(filepath.Join(dbPath, bucket, "1655375120328185000.cky"))
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]string{"1655375120328185000-cow": "500 months"}, data)
+	})
+
+	t.Run("RolledLogFilesShouldBeMovedIntoTheirBuckets", func(t *testing.T) {
+		err := ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		clock := NewFakeClock(time.Unix(0, 1655375120328185000))
+		store := NewStore(dbPath, 0.05, WithDataFileBuckets(time.Hour), WithClock(clock))
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		firstLogFile := store.currentLogFile
+
+		err = store.Set("goat", "678 months of grazing on the hills")
+		assert.Nil(t, err)
+		assert.NotEqual(t, firstLogFile, store.currentLogFile)
+
+		_, err = os.Stat(filepath.Join(dbPath, "1655373600000000000", firstLogFile+"."+DataFileExt))
+		assert.Nil(t, err)
+		value, err := store.Get("goat")
+		assert.Nil(t, err)
+		assert.Equal(t, "678 months of grazing on the hills", value)
+	})
+
+	t.Run("LoadWithoutBucketsShouldMoveTheDataFilesBack", func(t *testing.T) {
+		_ = newStore(t, WithDataFileBuckets(24*time.Hour))
+
+		store := NewStore(dbPath, 4)
+		err := store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = os.Stat(filepath.Join(dbPath, bucket))
+		assert.True(t, os.IsNotExist(err))
+		_, err = os.Stat(filepath.Join(dbPath, "1655375120328185000.cky"))
+		assert.Nil(t, err)
+		value, err := store.Get("dog")
+		assert.Nil(t, err)
+		assert.Equal(t, "23 months", value)
+	})
+}
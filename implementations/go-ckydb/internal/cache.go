@@ -14,9 +14,9 @@ func NewCache(data map[string]string, start string, end string) *Cache {
 }
 
 // IsInRange checks if the passed key is in the range between the start
-// and end of this Cache
+// and end of this Cache. The end is the name of the next file, so it is not in the range
 func (c *Cache) IsInRange(key string) bool {
-	return c.start <= key && key <= c.end
+	return c.start <= key && key < c.end
 }
 
 // Update updates the data of the givne cache with the new key value pair
@@ -0,0 +1,106 @@
+package internal
+
+import (
+	"os"
+	"strings"
+)
+
+const CasingFilename = "casing.kcs"
+
+// WithCaseInsensitiveKeys makes the keys of the store case-insensitive, for configuration stores, by converting
+// every key given to it to lower case, on writes and on lookups alike. The casing that a key was last set with
+// is kept in the casing file and returned by Describe. Keys set before it was enabled are only found if they
+// were in lower case already
+func WithCaseInsensitiveKeys(isEnabled bool) StoreOption {
+	return func(s *Store) {
+		s.caseInsensitiveKeys = isEnabled
+	}
+}
+
+// foldKey converts the key to lower case if the keys of the store are case-insensitive
+func (s *Store) foldKey(key string) string {
+	if !s.caseInsensitiveKeys {
+		return key
+	}
+
+	return strings.ToLower(key)
+}
+
+// originalKey returns the casing that the key, as folded, was last set with
+func (s *Store) originalKey(key string) string {
+	if original, ok := s.casings[key]; ok {
+		return original
+	}
+
+	return key
+}
+
+// setCasing appends the original casing of the folded key to the casing file, whose last record of a key wins.
+// Keys set in lower case have no record
+func (s *Store) setCasing(key string, original string, st *OpStats) error {
+	if original == key {
+		return s.removeCasing(key, st)
+	}
+
+	if s.casings[key] == original {
+		return nil
+	}
+
+	n, err := s.appendFile(s.casingFilePath, []byte(s.separators.encodeRecord(key, original)))
+	if err != nil {
+		return err
+	}
+	st.recordWrite(s.casingFilePath, n)
+
+	if s.casings == nil {
+		s.casings = map[string]string{}
+	}
+	s.casings[key] = original
+	return nil
+}
+
+// removeCasing removes the original casing of the given key, if any, from the casing file
+func (s *Store) removeCasing(key string, st *OpStats) error {
+	if _, ok := s.casings[key]; !ok {
+		return nil
+	}
+
+	err := s.deleteKeyValuesFromFile(s.casingFilePath, []string{key})
+	if err != nil {
+		return err
+	}
+	st.recordFileRewrite(s.casingFilePath)
+
+	delete(s.casings, key)
+	return nil
+}
+
+// deleteOrphanedCasings drops the casings of keys that no longer exist e.g. because retention dropped them
+func (s *Store) deleteOrphanedCasings(st *OpStats) error {
+	for key := range s.casings {
+		if _, ok := s.index[key]; !ok {
+			err := s.removeCasing(key, st)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// loadCasingsFromDisk loads the original casings of keys from the casing file, if there is one.
+// The file is only created once a key is set with upper case letters
+func (s *Store) loadCasingsFromDisk() error {
+	s.casings = map[string]string{}
+	err := s.repairTornRecord(s.casingFilePath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	s.casings, err = s.readLoadedKeyValues(s.casingFilePath, nil)
+	return err
+}
@@ -0,0 +1,151 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCaseInsensitiveKeys(t *testing.T) {
+	dbPath, err := filepath.Abs("testCaseInsensitiveKeysDb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = ClearDummyFileDataInDb(dbPath) }()
+
+	// newStore returns a loaded store with case-insensitive keys on an empty database folder
+	newStore := func(t *testing.T) *Store {
+		err := ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		store := NewStore(dbPath, 320.0/1024, WithCaseInsensitiveKeys(true))
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return store
+	}
+
+	t.Run("KeysDifferingOnlyInCaseShouldBeTheSameKey", func(t *testing.T) {
+		store := newStore(t)
+		err := store.Set("Timeout", "30s")
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = store.Set("TIMEOUT", "60s")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, key := range []string{"timeout", "Timeout", "tImEoUt"} {
+			value, err := store.Get(key)
+			assert.Nil(t, err)
+			assert.Equal(t, "60s", value)
+			assert.True(t, store.Has(key))
+		}
+		assert.Equal(t, []string{"timeout"}, store.Keys())
+		assert.Equal(t, []GetResult{{Value: "60s", Found: true}}, store.GetManyWithStats([]string{"TimeOut"}, nil))
+
+		err = store.Expire("TimeOut", time.Hour)
+		assert.Nil(t, err)
+		_, err = store.TTL("timeout")
+		assert.Nil(t, err)
+
+		err = store.Delete("timeOUT")
+		assert.Nil(t, err)
+		assert.False(t, store.Has("timeout"))
+		assert.Empty(t, store.Keys())
+	})
+
+	t.Run("DescribeShouldReturnTheCasingTheKeyWasLastSetWith", func(t *testing.T) {
+		store := newStore(t)
+		err := store.Set("MaxRetries", "3")
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = store.Set("port", "8080")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		info, err := store.Describe("maxretries")
+		assert.Nil(t, err)
+		assert.Equal(t, "MaxRetries", info.OriginalKey)
+
+		err = store.SetWithTTL("MAX_retries", "5", time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// so is the casing of a key loaded from disk
+		store = NewStore(dbPath, 320.0/1024, WithCaseInsensitiveKeys(true))
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		info, err = store.Describe("max_retries")
+		assert.Nil(t, err)
+		assert.Equal(t, "MAX_retries", info.OriginalKey)
+		info, err = store.Describe("PORT")
+		assert.Nil(t, err)
+		assert.Equal(t, "port", info.OriginalKey)
+	})
+
+	t.Run("DeletingAKeyShouldRemoveItsCasing", func(t *testing.T) {
+		store := newStore(t)
+		err := store.Set("Host", "localhost")
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = store.Set("Port", "8080")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = store.Delete("host")
+		assert.Nil(t, err)
+		casings, err := DefaultSeparators.readKeyValuesFromFile(filepath.Join(dbPath, CasingFilename))
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]string{"port": "Port"}, casings)
+
+		// setting a key in lower case drops the casing it had
+		err = store.Set("port", "9090")
+		assert.Nil(t, err)
+		casings, err = DefaultSeparators.readKeyValuesFromFile(filepath.Join(dbPath, CasingFilename))
+		assert.Nil(t, err)
+		assert.Empty(t, casings)
+	})
+
+	t.Run("KeysShouldBeCaseSensitiveByDefault", func(t *testing.T) {
+		err := ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		store := NewStore(dbPath, 320.0/1024)
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = store.Set("Timeout", "30s")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = store.Get("timeout")
+		assert.ErrorIs(t, err, ErrNotFound)
+		info, err := store.Describe("Timeout")
+		assert.Nil(t, err)
+		assert.Equal(t, "Timeout", info.OriginalKey)
+		_, err = os.Stat(filepath.Join(dbPath, CasingFilename))
+		assert.True(t, os.IsNotExist(err))
+	})
+}
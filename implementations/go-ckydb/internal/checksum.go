@@ -0,0 +1,281 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"strings"
+	"sync"
+)
+
+const (
+	ChecksumFileExt = "sum"
+	BackupFileExt   = "bak"
+)
+
+// checksummedFile is the state of a file that has a checksum file next to it, and a backup
+// of its last good contents, so that it can be restored if it gets corrupted.
+//
+// The checksum file holds the length and CRC-32 of the file, and its modification time.
+// Since appends only add to the end of the file, a file longer than its checksum says is fine
+// as long as the checksummed bytes are, e.g. when the process dies between an append and the
+// update of the checksum file. A file whose modification time differs from the one in the
+// checksum file was changed by someone else e.g. another implementation of ckydb, so it is
+// trusted if it can be parsed. Otherwise a mismatch means the file got corrupted
+type checksummedFile struct {
+	path    string
+	isValid func(data []byte) bool
+	length  int64
+	crc     uint32
+	lock    sync.Mutex
+}
+
+// checksum is the contents of a checksum file
+type checksum struct {
+	length  int64
+	crc     uint32
+	modTime int64
+}
+
+// verifies checks whether the first length bytes of data have the given CRC-32
+func (c checksum) verifies(data []byte) bool {
+	return int64(len(data)) >= c.length && crc32.ChecksumIEEE(data[:c.length]) == c.crc
+}
+
+// loadChecksummedFile checks the file at path against its checksum, restoring it from its
+// backup if it is corrupted, and loads its checksummed state
+func (s *Store) loadChecksummedFile(path string) error {
+	f := s.checksummedFiles[path]
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.length, f.crc = 0, 0
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	sum, err := readChecksum(path)
+	isUpToDate := err == nil && sum.length == int64(len(data)) && sum.verifies(data)
+	if !isUpToDate && !isGoodCopy(f, data, sum, err) {
+		restored, err := s.restoreFromBackup(f)
+		if err != nil && !(s.lenientLoad && errors.Is(err, ErrCorruptedData)) {
+			return err
+		}
+
+		// without a good backup, the malformed records are skipped when the file is parsed
+		if err == nil {
+			data = restored
+		}
+	}
+
+	f.length, f.crc = int64(len(data)), crc32.ChecksumIEEE(data)
+	if isUpToDate {
+		return nil
+	}
+
+	return s.writeChecksum(path, checksum{length: f.length, crc: f.crc, modTime: getModTime(path)})
+}
+
+// isGoodCopy checks whether data, the contents of the file, can be trusted given its checksum
+// or the error got reading it
+func isGoodCopy(f *checksummedFile, data []byte, sum checksum, sumErr error) bool {
+	if sumErr != nil {
+		// the file was written before checksums existed, or the checksum file is unusable,
+		// so the best that can be done is to check that the file can be parsed
+		return f.isValid(data)
+	}
+
+	if sum.verifies(data) {
+		return true
+	}
+
+	return getModTime(f.path) != sum.modTime && f.isValid(data)
+}
+
+// restoreFromBackup replaces the file with its backup, returning the restored contents.
+// It returns an ErrCorruptedData error if the backup is missing or corrupted as well
+func (s *Store) restoreFromBackup(f *checksummedFile) ([]byte, error) {
+	backupPath := getBackupFilePath(f.path)
+	data, err := os.ReadFile(backupPath)
+	if err != nil {
+		return nil, ErrCorruptedData
+	}
+
+	sum, err := readChecksum(backupPath)
+	if err != nil || sum.length != int64(len(data)) || !sum.verifies(data) {
+		return nil, ErrCorruptedData
+	}
+
+	err = s.replaceFile(f.path, data)
+	if err != nil {
+		return nil, err
+	}
+
+	s.restoredFiles.Add(1)
+	return data, nil
+}
+
+// appendChecksummedFile appends data to the checksummed file at path, creating it if it does
+// not exist, and updates its checksum file
+func (s *Store) appendChecksummedFile(path string, data []byte) (int, error) {
+	f := s.checksummedFiles[path]
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	n, err := s.fs.AppendFile(path, data)
+	f.length += int64(n)
+	f.crc = crc32.Update(f.crc, crc32.IEEETable, data[:n])
+	if err != nil {
+		return n, err
+	}
+
+	// the data is appended by now so failing to update the checksum file is not an error
+	// of the append. An outdated checksum still verifies the start of the file
+	_ = s.writeChecksum(path, checksum{length: f.length, crc: f.crc, modTime: getModTime(path)})
+	return n, nil
+}
+
+// rewriteChecksummedFile replaces the contents of the checksummed file with data, first backing up
+// its current contents if they are good. The new checksum is written before the file is replaced
+// so that at no point does a good file look corrupted
+func (s *Store) rewriteChecksummedFile(f *checksummedFile, data []byte) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	current, err := os.ReadFile(f.path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if err == nil && int64(len(current)) == f.length && crc32.ChecksumIEEE(current) == f.crc {
+		backupPath := getBackupFilePath(f.path)
+		err = s.replaceFile(backupPath, current)
+		if err != nil {
+			return err
+		}
+
+		err = s.writeChecksum(backupPath, checksum{length: f.length, crc: f.crc, modTime: getModTime(backupPath)})
+		if err != nil {
+			return err
+		}
+	}
+
+	// renaming keeps the modification time so that of the temporary file is the one to checksum
+	tempFilePath := f.path + "." + TempFileExt
+	err = s.fs.WriteFile(tempFilePath, data)
+	if err != nil {
+		return fileError("writing", f.path, err)
+	}
+
+	sum := checksum{length: int64(len(data)), crc: crc32.ChecksumIEEE(data), modTime: getModTime(tempFilePath)}
+	err = s.writeChecksum(f.path, sum)
+	if err != nil {
+		_ = s.fs.Remove(tempFilePath)
+		return err
+	}
+
+	err = s.fs.Rename(tempFilePath, f.path)
+	if err != nil {
+		return fileError("writing", f.path, err)
+	}
+
+	f.length, f.crc = sum.length, sum.crc
+	return nil
+}
+
+// writeChecksum writes the checksum file of the file at path
+func (s *Store) writeChecksum(path string, sum checksum) error {
+	content := fmt.Sprintf("%d %d %d", sum.length, sum.crc, sum.modTime)
+	return s.replaceFile(getChecksumFilePath(path), []byte(content))
+}
+
+// getModTime returns the modification time of the file at path in nanoseconds, or zero if it is unknown
+func getModTime(path string) int64 {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+
+	return info.ModTime().UnixNano()
+}
+
+// readChecksum reads the checksum file of the file at path
+func readChecksum(path string) (checksum, error) {
+	var sum checksum
+	content, err := os.ReadFile(getChecksumFilePath(path))
+	if err != nil {
+		return sum, err
+	}
+
+	_, err = fmt.Sscanf(string(content), "%d %d %d", &sum.length, &sum.crc, &sum.modTime)
+	if err != nil || sum.length < 0 {
+		return sum, errors.New("invalid checksum file")
+	}
+
+	return sum, nil
+}
+
+// isValidIndex checks whether data is a valid index i.e. its values are timestamped keys,
+// ignoring a torn record at its end
+func (s *Store) isValidIndex(data []byte) bool {
+	index, err := s.separators.extractKeyValues(s.separators.withoutTornRecord(data))
+	if err != nil {
+		return false
+	}
+
+	for key, value := range index {
+		if !s.separators.isValidIndexRecord(key, value) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isValidDel checks whether data is a valid del file i.e. its tokens are timestamped keys,
+// ignoring a torn record at its end
+func (s *Store) isValidDel(data []byte) bool {
+	timestampedKeys, err := s.separators.extractTokens(s.separators.withoutTornRecord(data))
+	if err != nil {
+		return false
+	}
+
+	for _, timestampedKey := range timestampedKeys {
+		if !isTimestampedKey(timestampedKey) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isTimestampedKey checks whether the given string is of the form "<timestamp>-<key>"
+func isTimestampedKey(timestampedKey string) bool {
+	timestamp, _, ok := strings.Cut(timestampedKey, "-")
+	if !ok || timestamp == "" {
+		return false
+	}
+
+	for _, c := range timestamp {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// getChecksumFilePath returns the path to the checksum file of the file at path
+func getChecksumFilePath(path string) string {
+	return path + "." + ChecksumFileExt
+}
+
+// getBackupFilePath returns the path to the backup of the file at path
+func getBackupFilePath(path string) string {
+	return path + "." + BackupFileExt
+}
@@ -0,0 +1,165 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChecksummedFiles(t *testing.T) {
+	dbPath, err := filepath.Abs("testChecksummedFilesDb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	indexFilePath := filepath.Join(dbPath, IndexFilename)
+	defer func() { _ = ClearDummyFileDataInDb(dbPath) }()
+
+	// newStoreWithBackup returns a loaded store whose index has been rewritten at least once,
+	// so that it has a backup
+	newStoreWithBackup := func(t *testing.T) *Store {
+		err := ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		store := NewStore(dbPath, 320.0/1024)
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, k := range []string{"cow", "goat", "hen"} {
+			err = store.Set(k, k+" value")
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		err = store.Delete("hen")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return store
+	}
+
+	// corruptFile overwrites the start of the file at path, keeping its modification time
+	// as if the disk rather than some program changed it
+	corruptFile := func(t *testing.T, path string) {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		f, err := os.OpenFile(path, os.O_WRONLY, 0666)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = f.WriteAt([]byte("garbage"), 0)
+		_ = f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = os.Chtimes(path, info.ModTime(), info.ModTime())
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("WritesShouldKeepChecksumsAndBackups", func(t *testing.T) {
+		newStoreWithBackup(t)
+
+		for _, path := range []string{indexFilePath, getBackupFilePath(indexFilePath)} {
+			data, err := os.ReadFile(path)
+			assert.Nil(t, err)
+			sum, err := readChecksum(path)
+			assert.Nil(t, err)
+			assert.Equal(t, int64(len(data)), sum.length)
+			assert.True(t, sum.verifies(data))
+		}
+	})
+
+	t.Run("LoadShouldRestoreCorruptedFilesFromTheirBackups", func(t *testing.T) {
+		newStoreWithBackup(t)
+		corruptFile(t, indexFilePath)
+
+		store := NewStore(dbPath, 320.0/1024)
+		err := store.Load()
+		assert.Nil(t, err)
+		assert.Equal(t, int64(1), store.Stats().RestoredFiles)
+
+		for _, k := range []string{"cow", "goat"} {
+			value, err := store.Get(k)
+			assert.Nil(t, err)
+			assert.Equal(t, k+" value", value)
+		}
+
+		backup, err := os.ReadFile(getBackupFilePath(indexFilePath))
+		assert.Nil(t, err)
+		index, err := os.ReadFile(indexFilePath)
+		assert.Nil(t, err)
+		assert.Equal(t, backup, index)
+	})
+
+	t.Run("LoadShouldTrustValidFilesChangedByOtherPrograms", func(t *testing.T) {
+		newStoreWithBackup(t)
+		later := time.Now().Add(time.Hour)
+		err := os.WriteFile(indexFilePath, []byte("cow><?&(^#1655404770518678-cow"), 0666)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.Chtimes(indexFilePath, later, later)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		store := NewStore(dbPath, 320.0/1024)
+		err = store.Load()
+		assert.Nil(t, err)
+		assert.Equal(t, int64(0), store.Stats().RestoredFiles)
+		_, err = store.Get("goat")
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		data, err := os.ReadFile(indexFilePath)
+		assert.Nil(t, err)
+		sum, err := readChecksum(indexFilePath)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(len(data)), sum.length)
+	})
+
+	t.Run("LoadShouldFailIfTheBackupIsCorruptedToo", func(t *testing.T) {
+		newStoreWithBackup(t)
+		corruptFile(t, indexFilePath)
+		corruptFile(t, getBackupFilePath(indexFilePath))
+
+		err := NewStore(dbPath, 320.0/1024).Load()
+		assert.ErrorIs(t, err, ErrCorruptedData)
+	})
+
+	t.Run("LoadShouldAcceptFoldersWithoutChecksums", func(t *testing.T) {
+		err := ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = AddDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		store := NewStore(dbPath, 320.0/1024)
+		err = store.Load()
+		assert.Nil(t, err)
+		value, err := store.Get("cow")
+		assert.Nil(t, err)
+		assert.Equal(t, "500 months", value)
+
+		for _, path := range []string{indexFilePath, filepath.Join(dbPath, DelFilename)} {
+			_, err = readChecksum(path)
+			assert.Nil(t, err)
+		}
+	})
+}
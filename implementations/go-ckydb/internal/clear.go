@@ -0,0 +1,107 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ClearedFolderInfix is put between the path of the database folder and a timestamp to name the folder that
+// Clear moves the files of the database folder into, e.g. "/data/db.cleared-1655375120328185000", which the
+// next vacuum deletes
+const ClearedFolderInfix = ".cleared-"
+
+// preservedByClearData are the files and folders of the database folder that ClearData keeps, those written when
+// the database was created and those that are not key-values
+var preservedByClearData = []string{
+	OplogFolderName,
+	FormatVersionFilename,
+	MetadataFilename,
+	FormatMigrationFilename,
+	MetaSegmentFilename,
+}
+
+// ClearData deletes all key-values like Clear, but keeps the files written when the database was created, such as
+// its format version, separators, value flags and HMAC key check, and the metadata segment of SetMeta,
+// so that the database keeps the format it was created with rather than taking that of the options of the store
+func (s *Store) ClearData() error {
+	return s.guardWrite(s.clearData)
+}
+
+// clearData is ClearData without the guard against writing to a failing disk
+func (s *Store) clearData() error {
+	s.index = nil
+	s.expiries = nil
+	s.usage = nil
+	s.history = nil
+	s.trash = nil
+	s.casings = nil
+	s.resetCache()
+	err := s.moveFilesAside(preservedByClearData...)
+	if err != nil {
+		return err
+	}
+
+	err = s.Load()
+	if err != nil {
+		return err
+	}
+
+	return s.appendToOplog(OplogClear, "", "")
+}
+
+// moveFilesAside moves the database folder aside at once, all but the given files and folders, such as the oplog
+// that outlives clears, leaving a database folder with only those behind, so that Clear does not wait for the
+// files to be deleted. If the folder cannot be renamed e.g. as it is a mount point, the other files are deleted
+// right away instead
+func (s *Store) moveFilesAside(kept ...string) error {
+	dbPath := filepath.Clean(s.dbPath)
+	_, err := os.Stat(dbPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	asidePath := fmt.Sprintf("%s%s%d", dbPath, ClearedFolderInfix, s.nextTimestamp())
+	err = s.fs.Rename(dbPath, asidePath)
+	if err != nil {
+		return s.clearDisk(kept...)
+	}
+
+	err = os.MkdirAll(dbPath, 0777)
+	for i := 0; err == nil && i < len(kept); i++ {
+		err = s.fs.Rename(filepath.Join(asidePath, kept[i]), filepath.Join(dbPath, kept[i]))
+		if os.IsNotExist(err) {
+			err = nil
+		}
+	}
+	if err != nil {
+		// the database folder is put back as it was, so that the kept files are not deleted with the rest
+		for _, name := range kept {
+			_ = s.fs.Rename(filepath.Join(dbPath, name), filepath.Join(asidePath, name))
+		}
+		_ = os.RemoveAll(dbPath)
+		_ = s.fs.Rename(asidePath, dbPath)
+		return err
+	}
+
+	return nil
+}
+
+// ClearedFolders returns the paths of the folders that Clear moved the files of the database folder into.
+// Clear fills them while holding the write lock of the database, so once they are listed under that lock,
+// nothing but RemoveClearedFolders touches them again, and they can be deleted without holding it
+func (s *Store) ClearedFolders() ([]string, error) {
+	return filepath.Glob(filepath.Clean(s.dbPath) + ClearedFolderInfix + "*")
+}
+
+// RemoveClearedFolders deletes the folders listed by ClearedFolders, trying every one of them even if
+// others fail to be deleted
+func RemoveClearedFolders(paths []string) error {
+	var errs []error
+	for _, path := range paths {
+		errs = append(errs, os.RemoveAll(path))
+	}
+
+	return errors.Join(errs...)
+}
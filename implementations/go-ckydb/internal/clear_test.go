@@ -0,0 +1,74 @@
+package internal
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClearData(t *testing.T) {
+	dbPath, err := filepath.Abs("testClearDataDb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	custom := Separators{Token: "\n", KeyValue: "\t"}
+	defer func() { _ = ClearDummyFileDataInDb(dbPath) }()
+
+	// newStore returns a store created with the custom separators, value flags and a metadata segment,
+	// loaded again without the options
+	newStore := func(t *testing.T) *Store {
+		err := ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		store := NewStore(dbPath, 320.0/1024, WithSeparators(custom), WithValueCompressionThreshold(64), WithOplog(true))
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Nil(t, store.Set("cow", "500 months"))
+		assert.Nil(t, store.SetMeta("schema_version", "3"))
+
+		store = NewStore(dbPath, 320.0/1024, WithOplog(true))
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return store
+	}
+
+	t.Run("ClearDataShouldKeepTheFormatAndTheMetadataSegment", func(t *testing.T) {
+		store := newStore(t)
+		err := store.ClearData()
+		assert.Nil(t, err)
+
+		assert.Empty(t, store.Keys())
+		sep, err := ReadSeparators(dbPath)
+		assert.Nil(t, err)
+		assert.Equal(t, custom, sep)
+		assert.True(t, store.valueFlags)
+		value, err := store.GetMeta("schema_version")
+		assert.Nil(t, err)
+		assert.Equal(t, "3", value)
+
+		var ops []string
+		for entry, err := range ReadOplog(dbPath, 0) {
+			assert.Nil(t, err)
+			ops = append(ops, entry.Op)
+		}
+		assert.Equal(t, []string{OplogSet, OplogClear}, ops)
+	})
+
+	t.Run("ClearShouldTakeTheValueFlagsOfTheOptionsAndDropTheMetadataSegment", func(t *testing.T) {
+		store := newStore(t)
+		err := store.Clear()
+		assert.Nil(t, err)
+
+		assert.False(t, store.valueFlags)
+		_, err = store.GetMeta("schema_version")
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
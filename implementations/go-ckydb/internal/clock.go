@@ -0,0 +1,149 @@
+package internal
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Clock is the source of the current time used for timestamps and intervals
+type Clock interface {
+	// Now returns the current time
+	Now() time.Time
+	// After returns a channel on which the current time is sent once d has elapsed
+	After(d time.Duration) <-chan time.Time
+}
+
+// RealClock is the Clock that tells the real time
+var RealClock Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// WithClock sets the Clock used by the store for timestamped keys, log filenames and retention.
+// It defaults to the RealClock
+func WithClock(clock Clock) StoreOption {
+	return func(s *Store) {
+		s.clock = clock
+	}
+}
+
+// nextTimestamp returns the current time of the clock in nanoseconds. It is always later
+// than the timestamps returned before it so that timestamped keys and log files never collide
+// even if the clock stands still or goes backwards
+func (s *Store) nextTimestamp() int64 {
+	for {
+		last := s.lastTimestamp.Load()
+		timestamp := s.clock.Now().UnixNano()
+		if timestamp <= last {
+			timestamp = last + 1
+		}
+
+		if s.lastTimestamp.CompareAndSwap(last, timestamp) {
+			return timestamp
+		}
+	}
+}
+
+// observeTimestamp ensures that the timestamps returned by nextTimestamp are later than
+// the given timestamp, ignoring it if it is not a valid timestamp
+func (s *Store) observeTimestamp(timestamp string) {
+	value, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return
+	}
+
+	for {
+		last := s.lastTimestamp.Load()
+		if value <= last || s.lastTimestamp.CompareAndSwap(last, value) {
+			return
+		}
+	}
+}
+
+// FakeClock is a Clock whose time only changes when it is set or advanced. It is meant for tests
+type FakeClock struct {
+	now     time.Time
+	waiters []fakeWaiter
+	lock    sync.Mutex
+}
+
+// fakeWaiter is a channel returned by FakeClock.After that is yet to receive the time
+type fakeWaiter struct {
+	at time.Time
+	c  chan time.Time
+}
+
+// NewFakeClock creates a FakeClock whose current time is now
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.now
+}
+
+func (c *FakeClock) After(d time.Duration) <-chan time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+
+	c.waiters = append(c.waiters, fakeWaiter{at: c.now.Add(d), c: ch})
+	return ch
+}
+
+// Waiters returns the number of channels returned by After that are yet to receive the time
+func (c *FakeClock) Waiters() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return len(c.waiters)
+}
+
+// Set sets the current time of the clock, which may be earlier than the current one
+func (c *FakeClock) Set(now time.Time) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.now = now
+	c.notifyDueWaiters()
+}
+
+// Advance moves the clock forward by d
+func (c *FakeClock) Advance(d time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.now = c.now.Add(d)
+	c.notifyDueWaiters()
+}
+
+// notifyDueWaiters sends the current time to the waiters that are due. It requires the lock to be held
+func (c *FakeClock) notifyDueWaiters() {
+	pending := c.waiters[:0]
+	for _, w := range c.waiters {
+		if c.now.Before(w.at) {
+			pending = append(pending, w)
+			continue
+		}
+
+		w.c <- c.now
+	}
+
+	c.waiters = pending
+}
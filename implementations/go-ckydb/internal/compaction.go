@@ -0,0 +1,44 @@
+package internal
+
+// Compact merges adjacent data files whose combined size does not exceed the maximum file size.
+// Such small data files accumulate as vacuuming removes the deleted key-values from them
+func (s *Store) Compact() error {
+	return s.CompactWithStats(nil)
+}
+
+// CompactWithStats is like Compact but it also records what it did in st
+func (s *Store) CompactWithStats(st *OpStats) error {
+	return s.guardWrite(func() error { return s.compact(st, nil) })
+}
+
+// compact is CompactWithStats without the guard against writing to a failing disk. If pause is not nil,
+// it is called with the number of bytes rewritten after every merge
+func (s *Store) compact(st *OpStats, pause func(bytesRewritten int64)) error {
+	for i := 0; i+1 < len(s.dataFiles); {
+		size, err := GetFileSize(s.getDataFilePath(s.dataFiles[i]))
+		if err != nil {
+			return err
+		}
+
+		nextSize, err := GetFileSize(s.getDataFilePath(s.dataFiles[i+1]))
+		if err != nil {
+			return err
+		}
+
+		if size+nextSize > s.maxFileSizeKB {
+			i++
+			continue
+		}
+
+		err = s.mergeDataFiles(i, st)
+		if err != nil {
+			return err
+		}
+
+		if pause != nil {
+			pause(int64((size + nextSize) * 1024))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,120 @@
+package internal
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/base64"
+	"io"
+)
+
+const (
+	plainValueFlag      = '0'
+	compressedValueFlag = '1'
+	dedupedValueFlag    = '2'
+	spilledValueFlag    = '3'
+)
+
+// WithValueCompressionThreshold compresses the values of at least the given number of bytes, each on its own,
+// when they are saved to the log and data files. Since that needs a flag byte in front of every value, it only
+// applies to databases created with it, which record it in their metadata file. Zero means no compression
+func WithValueCompressionThreshold(bytes int) StoreOption {
+	return func(s *Store) {
+		s.compressThreshold = bytes
+	}
+}
+
+// encodeValue returns the value as it is saved in the log and data files. In databases whose values are flagged,
+// values of at least the deduplication threshold are replaced by the hash of their blob, those of at least the spill
+// threshold by the name of their blob, while those of at least the compression threshold are deflated and
+// base64-encoded if that makes them smaller without producing any of the separators
+func (s *Store) encodeValue(value string, st *OpStats) (string, error) {
+	if !s.valueFlags {
+		return value, nil
+	}
+
+	if s.dedupThreshold > 0 && len(value) >= s.dedupThreshold {
+		hash, err := s.saveDedupedBlob(value, st)
+		if err != nil {
+			return "", err
+		}
+
+		return string(dedupedValueFlag) + hash, nil
+	}
+
+	if s.spillThreshold > 0 && len(value) >= s.spillThreshold {
+		name, err := s.saveSpilledBlob(value, st)
+		if err != nil {
+			return "", err
+		}
+
+		return string(spilledValueFlag) + name, nil
+	}
+
+	if s.compressThreshold > 0 && len(value) >= s.compressThreshold {
+		compressed, err := compressValue(value)
+		if err == nil && len(compressed) < len(value) && s.separators.validateKeyValue("", compressed) == nil {
+			return string(compressedValueFlag) + compressed, nil
+		}
+	}
+
+	return string(plainValueFlag) + value, nil
+}
+
+// decodeValue returns the value saved as stored in the log and data files of a database whose values
+// are flagged or not, reading it from the blobs folder at blobsPath if it is there
+func decodeValue(stored string, valueFlags bool, blobsPath string) (string, error) {
+	if !valueFlags {
+		return stored, nil
+	}
+
+	if stored == "" {
+		return "", ErrCorruptedData
+	}
+
+	switch stored[0] {
+	case plainValueFlag:
+		return stored[1:], nil
+	case compressedValueFlag:
+		return decompressValue(stored[1:])
+	case dedupedValueFlag, spilledValueFlag:
+		return readBlob(blobsPath, stored[1:])
+	}
+
+	return "", ErrCorruptedData
+}
+
+// compressValue deflates the value, encoding the result in base64 so that it is still text
+func compressValue(value string) (string, error) {
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, flate.BestSpeed)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = w.Write([]byte(value))
+	if err != nil {
+		return "", err
+	}
+
+	err = w.Close()
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawStdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// decompressValue reverses compressValue, returning an ErrCorruptedData error if the value was not got from it
+func decompressValue(compressed string) (string, error) {
+	data, err := base64.RawStdEncoding.DecodeString(compressed)
+	if err != nil {
+		return "", ErrCorruptedData
+	}
+
+	value, err := io.ReadAll(flate.NewReader(bytes.NewReader(data)))
+	if err != nil {
+		return "", ErrCorruptedData
+	}
+
+	return string(value), nil
+}
@@ -0,0 +1,144 @@
+package internal
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValueCompression(t *testing.T) {
+	dbPath, err := filepath.Abs("testValueCompressionDb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	blob := strings.Repeat("a value that compresses well ", 100)
+	defer func() { _ = ClearDummyFileDataInDb(dbPath) }()
+
+	// newStore returns a loaded store on an empty database folder with the given options
+	newStore := func(t *testing.T, opts ...StoreOption) *Store {
+		err := ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		store := NewStore(dbPath, 0.1, opts...)
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return store
+	}
+
+	// readDataAndLogFiles returns the contents of all the data and log files of the database
+	readDataAndLogFiles := func(t *testing.T) string {
+		dataContents, err := ReadFilesWithExtension(dbPath, DataFileExt)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		logContents, err := ReadFilesWithExtension(dbPath, LogFileExt)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return strings.Join(append(dataContents, logContents...), "")
+	}
+
+	t.Run("ValuesOfAtLeastTheThresholdShouldBeCompressed", func(t *testing.T) {
+		store := newStore(t, WithValueCompressionThreshold(64))
+		assert.Nil(t, store.Set("blob", blob))
+		assert.Nil(t, store.Set("cow", "500 months"))
+
+		content := readDataAndLogFiles(t)
+		assert.NotContains(t, content, blob)
+		assert.Contains(t, content, "-blob"+KeyValueSeparator+string(compressedValueFlag))
+		assert.Contains(t, content, "-cow"+KeyValueSeparator+string(plainValueFlag)+"500 months")
+		assert.Less(t, len(content), len(blob))
+
+		value, err := store.Get("blob")
+		assert.Nil(t, err)
+		assert.Equal(t, blob, value)
+		value, err = store.Get("cow")
+		assert.Nil(t, err)
+		assert.Equal(t, "500 months", value)
+	})
+
+	t.Run("CompressedValuesShouldSurviveReloadsSnapshotsAndLogRolls", func(t *testing.T) {
+		store := newStore(t, WithValueCompressionThreshold(64))
+		for i := 0; i < 20; i++ {
+			assert.Nil(t, store.Set(fmt.Sprintf("blob%d", i), fmt.Sprintf("%d %s", i, blob)))
+		}
+		assert.Greater(t, len(store.dataFiles), 0)
+
+		// the value flags are read from the metadata file, whatever the store is configured with
+		reloaded := NewStore(dbPath, 0.1)
+		err := reloaded.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.True(t, reloaded.valueFlags)
+
+		values := reloaded.GetManyWithStats([]string{"blob0", "blob19"}, nil)
+		assert.Equal(t, []GetResult{
+			{Value: "0 " + blob, Found: true},
+			{Value: "19 " + blob, Found: true},
+		}, values)
+
+		snap, err := reloaded.Snapshot()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = snap.Close() }()
+
+		count := 0
+		err = snap.ForEach(func(key string, value string) error {
+			assert.True(t, strings.HasSuffix(value, blob))
+			count++
+			return nil
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, 20, count)
+	})
+
+	t.Run("ExistingDatabasesShouldNotGetValueFlags", func(t *testing.T) {
+		store := newStore(t)
+		assert.Nil(t, store.Set("cow", "500 months"))
+
+		reloaded := NewStore(dbPath, 0.1, WithValueCompressionThreshold(64))
+		err := reloaded.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.False(t, reloaded.valueFlags)
+		assert.Nil(t, reloaded.Set("blob", blob))
+
+		assert.Contains(t, readDataAndLogFiles(t), blob)
+		value, err := reloaded.Get("cow")
+		assert.Nil(t, err)
+		assert.Equal(t, "500 months", value)
+	})
+
+	t.Run("ValuesThatDoNotShrinkShouldBeStoredAsIs", func(t *testing.T) {
+		store := newStore(t, WithValueCompressionThreshold(1))
+		assert.Nil(t, store.Set("cow", "500 months"))
+
+		assert.Contains(t, readDataAndLogFiles(t), KeyValueSeparator+string(plainValueFlag)+"500 months")
+	})
+
+	t.Run("DecodeValueShouldRejectUnflaggedValues", func(t *testing.T) {
+		_, err := decodeValue("", true, "")
+		assert.ErrorIs(t, err, ErrCorruptedData)
+		_, err = decodeValue("x500 months", true, "")
+		assert.ErrorIs(t, err, ErrCorruptedData)
+		_, err = decodeValue(string(compressedValueFlag)+"not deflated", true, "")
+		assert.ErrorIs(t, err, ErrCorruptedData)
+
+		value, err := decodeValue("x500 months", false, "")
+		assert.Nil(t, err)
+		assert.Equal(t, "x500 months", value)
+	})
+}
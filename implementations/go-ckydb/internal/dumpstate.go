@@ -0,0 +1,121 @@
+package internal
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+	"time"
+)
+
+// StoreState describes the in-memory state of a store and the files of its database folder, for bug reports
+// about inconsistent reads. It only holds keys and file names, never values, and round-trips through JSON and gob
+type StoreState struct {
+	DBPath        string `json:"db_path"`
+	FormatVersion int    `json:"format_version"`
+	IsFollower    bool   `json:"is_follower"`
+	IsReadOnly    bool   `json:"is_read_only"`
+	// ReadOnlyErr is the disk error that made the store read-only, if it is
+	ReadOnlyErr   string    `json:"read_only_err,omitempty"`
+	ReadOnlySince time.Time `json:"read_only_since"`
+	IndexSize     int       `json:"index_size"`
+	// PendingIndexEntries is the number of index entries buffered by index batching
+	PendingIndexEntries int `json:"pending_index_entries"`
+	// MemtableKeys are the timestamped keys in the memtable, in order
+	MemtableKeys   []string `json:"memtable_keys"`
+	CurrentLogFile string   `json:"current_log_file"`
+	DataFiles      []string `json:"data_files"`
+	// Cache is the range of the data file held in the cache, if any
+	Cache *CacheState `json:"cache,omitempty"`
+	// PrefetchedCache is the range of the data file read ahead by cache prefetching, if any
+	PrefetchedCache *CacheState  `json:"prefetched_cache,omitempty"`
+	Expiries        int          `json:"expiries"`
+	Files           []FolderFile `json:"files"`
+}
+
+// CacheState is the range of timestamped keys held by a cache
+type CacheState struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+	Keys  int    `json:"keys"`
+}
+
+// FolderFile is a file or folder in the database folder
+type FolderFile struct {
+	Name  string `json:"name"`
+	Size  int64  `json:"size"`
+	IsDir bool   `json:"is_dir,omitempty"`
+}
+
+// DumpState returns the state of the store as indented JSON
+func DumpState(store *Store) ([]byte, error) {
+	state, err := store.State()
+	if err != nil {
+		return nil, err
+	}
+
+	return json.MarshalIndent(state, "", "  ")
+}
+
+// State returns the state of the store. It must not be called while the store is written to
+func (s *Store) State() (*StoreState, error) {
+	version, err := ReadFormatVersion(s.dbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	health := s.Health()
+	state := &StoreState{
+		DBPath:              s.dbPath,
+		FormatVersion:       version,
+		IsFollower:          s.isFollower,
+		IsReadOnly:          health.IsReadOnly,
+		ReadOnlySince:       health.Since,
+		IndexSize:           len(s.index),
+		PendingIndexEntries: s.pendingIndexKeys,
+		MemtableKeys:        make([]string, 0, len(s.memtable)),
+		CurrentLogFile:      s.currentLogFile,
+		DataFiles:           append([]string{}, s.dataFiles...),
+		Expiries:            len(s.expiries),
+	}
+	if health.Err != nil {
+		state.ReadOnlyErr = health.Err.Error()
+	}
+
+	for timestampedKey := range s.memtable {
+		state.MemtableKeys = append(state.MemtableKeys, timestampedKey)
+	}
+	sort.Strings(state.MemtableKeys)
+
+	s.cacheLock.Lock()
+	state.Cache, state.PrefetchedCache = newCacheState(s.cache), newCacheState(s.prefetched)
+	s.cacheLock.Unlock()
+
+	entries, err := os.ReadDir(s.dbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+
+		file := FolderFile{Name: entry.Name(), IsDir: entry.IsDir()}
+		if !entry.IsDir() {
+			file.Size = info.Size()
+		}
+		state.Files = append(state.Files, file)
+	}
+
+	return state, nil
+}
+
+// newCacheState returns the state of the cache, or nil if it holds no data file
+func newCacheState(cache *Cache) *CacheState {
+	if cache == nil || (cache.start == "0" && cache.end == "0") {
+		return nil
+	}
+
+	return &CacheState{Start: cache.start, End: cache.end, Keys: len(cache.data)}
+}
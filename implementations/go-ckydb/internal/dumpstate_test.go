@@ -0,0 +1,77 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDumpState(t *testing.T) {
+	dbPath, err := filepath.Abs("testDumpStateDb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = ClearDummyFileDataInDb(dbPath) }()
+
+	err = AddDummyFileDataInDb(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := NewStore(dbPath, 4)
+	err = store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = store.Get("cow")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("DumpStateShouldDescribeTheIndexMemtableCacheAndFiles", func(t *testing.T) {
+		data, err := DumpState(store)
+		assert.Nil(t, err)
+
+		var state StoreState
+		err = json.Unmarshal(data, &state)
+		assert.Nil(t, err)
+		assert.Equal(t, dbPath, state.DBPath)
+		assert.Equal(t, 1, state.FormatVersion)
+		assert.Equal(t, 6, state.IndexSize)
+		assert.Equal(t, []string{"1655403775538278-fish", "1655404670510698-hen", "1655404770518678-goat", "1655404770534578-pig"}, state.MemtableKeys)
+		assert.Equal(t, "1655375171402014000", state.CurrentLogFile)
+		assert.Equal(t, []string{"1655375120328185000", "1655375120328186000"}, state.DataFiles)
+		assert.Equal(t, &CacheState{Start: "1655375120328185000", End: "1655375120328186000", Keys: 2}, state.Cache)
+		assert.Nil(t, state.PrefetchedCache)
+		assert.False(t, state.IsReadOnly)
+
+		names := map[string]bool{}
+		for _, file := range state.Files {
+			names[file.Name] = true
+		}
+		for _, name := range []string{IndexFilename, DelFilename, "1655375120328185000.cky", "1655375171402014000.log"} {
+			assert.True(t, names[name], name)
+		}
+		assert.NotContains(t, string(data), "500 months")
+	})
+
+	t.Run("StateShouldRoundTripThroughGob", func(t *testing.T) {
+		state, err := store.State()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var buf bytes.Buffer
+		err = gob.NewEncoder(&buf).Encode(state)
+		assert.Nil(t, err)
+		var decoded StoreState
+		err = gob.NewDecoder(&buf).Decode(&decoded)
+		assert.Nil(t, err)
+		assert.Equal(t, state.MemtableKeys, decoded.MemtableKeys)
+		assert.Equal(t, state.Cache, decoded.Cache)
+		assert.Equal(t, state.Files, decoded.Files)
+	})
+}
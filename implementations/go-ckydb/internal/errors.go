@@ -3,9 +3,34 @@ package internal
 import "errors"
 
 var (
-	ErrAlreadyRunning = errors.New("already running")
-	ErrNotRunning     = errors.New("not running")
-	ErrNotFound       = errors.New("not found")
-	ErrCorruptedData  = errors.New("data in database is corrupt")
-	ErrOutOfBounds    = errors.New("out of bounds")
+	ErrAlreadyRunning  = errors.New("already running")
+	ErrNotRunning      = errors.New("not running")
+	ErrNotFound        = errors.New("not found")
+	ErrCorruptedData   = errors.New("data in database is corrupt")
+	ErrOutOfBounds     = errors.New("out of bounds")
+	ErrInvalidKeyValue = errors.New("key or value contains a separator")
+	ErrConflict        = errors.New("key already exists")
+	ErrQuotaExceeded   = errors.New("maximum database size exceeded")
+	ErrReadOnly        = errors.New("database is read-only after a disk error")
+	ErrFollower        = errors.New("database is a read-only follower")
+	ErrReservedKey     = errors.New("key is in the namespace reserved for ckydb")
+	ErrLoading         = errors.New("database is still loading")
+	ErrNotOpened       = errors.New("database has not been opened")
+	ErrDatabaseClosed  = errors.New("database is closed")
+
+	ErrInvariantViolated = errors.New("store invariant violated")
+	ErrHistoryDisabled   = errors.New("history mode is not enabled")
+	ErrTrashDisabled     = errors.New("trash mode is not enabled")
+
+	ErrInvalidSeparators = errors.New("separators must not be empty and neither may contain the other")
+
+	ErrTamperedRecord = errors.New("record failed its HMAC check")
+	ErrInvalidHMACKey = errors.New("HMAC key is missing or not the one the database was created with")
+	ErrHMACDisabled   = errors.New("record HMACs are not enabled")
+	ErrNoManifest     = errors.New("database folder has no manifest of its data files")
+	ErrNoNodes        = errors.New("ring has no nodes")
+
+	ErrUnsupportedFormatVersion = errors.New("database folder is of a newer format version than is supported")
+	ErrOutdatedFormatVersion    = errors.New("database folder is of an older format version; migrate it with MigrateFormat")
+	ErrMigrationInProgress      = errors.New("database folder is midway through a format migration; resume it with MigrateFormatTo")
 )
@@ -0,0 +1,254 @@
+package internal
+
+import (
+	"container/heap"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const UsageFilename = "usage.evc"
+
+type EvictionPolicy int
+
+const (
+	// EvictLRU evicts the least recently used key
+	EvictLRU EvictionPolicy = iota
+	// EvictLFU evicts the least frequently used key, and the least recently used one among equals
+	EvictLFU
+)
+
+// WithMaxKeys limits the number of keys in the store to n. Setting a new key beyond the limit
+// first evicts a key as chosen by the policy. The usage of the keys is tracked in memory and
+// saved to the usage file on every Vacuum, so that it outlives restarts. Zero means no limit
+func WithMaxKeys(n int, policy EvictionPolicy) StoreOption {
+	return func(s *Store) {
+		s.maxKeys = n
+		s.evictionPolicy = policy
+	}
+}
+
+// keyUsage is how often and how recently a key was used
+type keyUsage struct {
+	key      string
+	uses     uint64
+	lastUsed uint64
+	// position is the index of the keyUsage in its usageHeap
+	position int
+}
+
+// usageHeap is a min-heap of the usage of keys, whose first item is the next key to evict
+type usageHeap struct {
+	items  []*keyUsage
+	policy EvictionPolicy
+}
+
+func (h *usageHeap) Len() int { return len(h.items) }
+
+func (h *usageHeap) Less(i, j int) bool {
+	a, b := h.items[i], h.items[j]
+	if h.policy == EvictLFU && a.uses != b.uses {
+		return a.uses < b.uses
+	}
+
+	return a.lastUsed < b.lastUsed
+}
+
+func (h *usageHeap) Swap(i, j int) {
+	h.items[i], h.items[j] = h.items[j], h.items[i]
+	h.items[i].position = i
+	h.items[j].position = j
+}
+
+func (h *usageHeap) Push(x any) {
+	item := x.(*keyUsage)
+	item.position = len(h.items)
+	h.items = append(h.items, item)
+}
+
+func (h *usageHeap) Pop() any {
+	last := len(h.items) - 1
+	item := h.items[last]
+	h.items[last] = nil
+	h.items = h.items[:last]
+	return item
+}
+
+// usageTracker tracks the usage of the keys of a store. Keys are used concurrently
+// by gets, so it has its own lock
+type usageTracker struct {
+	heap  usageHeap
+	byKey map[string]*keyUsage
+	// tick is a logical clock that is advanced on every use
+	tick uint64
+	lock sync.Mutex
+}
+
+// use counts a use of the given key, tracking it if it is not yet tracked
+func (u *usageTracker) use(key string) {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	u.tick++
+	if item, ok := u.byKey[key]; ok {
+		item.uses++
+		item.lastUsed = u.tick
+		heap.Fix(&u.heap, item.position)
+		return
+	}
+
+	u.add(&keyUsage{key: key, uses: 1, lastUsed: u.tick})
+}
+
+// add starts tracking the given usage of a key
+func (u *usageTracker) add(item *keyUsage) {
+	u.byKey[item.key] = item
+	heap.Push(&u.heap, item)
+}
+
+// forget stops tracking the given key
+func (u *usageTracker) forget(key string) {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	if item, ok := u.byKey[key]; ok {
+		heap.Remove(&u.heap, item.position)
+		delete(u.byKey, key)
+	}
+}
+
+// popVictim stops tracking the next key to evict and returns it
+func (u *usageTracker) popVictim() (string, bool) {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	if u.heap.Len() == 0 {
+		return "", false
+	}
+
+	item := heap.Pop(&u.heap).(*keyUsage)
+	delete(u.byKey, item.key)
+	return item.key, true
+}
+
+// useKey counts a use of the given key if the number of keys is limited
+func (s *Store) useKey(key string) {
+	if s.usage != nil {
+		s.usage.use(key)
+	}
+}
+
+// forgetKey stops tracking the usage of the given key, e.g. once it is deleted
+func (s *Store) forgetKey(key string) {
+	if s.usage != nil {
+		s.usage.forget(key)
+	}
+}
+
+// ensureCapacityFor evicts keys, as chosen by the eviction policy, until there is room for the key
+// if it is new. Keys that no longer exist, e.g. because retention dropped them, are skipped
+func (s *Store) ensureCapacityFor(key string, st *OpStats) error {
+	if s.usage == nil {
+		return nil
+	}
+
+	if _, ok := s.index[key]; ok {
+		return nil
+	}
+
+	for len(s.index) >= s.maxKeys {
+		victim, ok := s.usage.popVictim()
+		if !ok {
+			return nil
+		}
+
+		if _, ok = s.index[victim]; !ok {
+			continue
+		}
+
+		err := s.deleteWithStats(victim, st)
+		if err != nil {
+			return err
+		}
+		s.evictedKeys.Add(1)
+	}
+
+	return nil
+}
+
+// loadUsageFromDisk tracks the usage of every key in the index, as saved in the usage file.
+// Keys set after the file was last saved are the first to be evicted
+func (s *Store) loadUsageFromDisk() error {
+	if s.maxKeys <= 0 {
+		return nil
+	}
+
+	saved, err := s.readLoadedKeyValues(s.usageFilePath, func(_ string, value string) bool {
+		_, _, err := parseKeyUsage(value)
+		return err == nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	usage := &usageTracker{heap: usageHeap{policy: s.evictionPolicy}, byKey: make(map[string]*keyUsage, len(s.index))}
+	for key := range s.index {
+		item := &keyUsage{key: key}
+		if value, ok := saved[key]; ok {
+			item.uses, item.lastUsed, err = parseKeyUsage(value)
+			if err != nil {
+				return err
+			}
+		}
+
+		usage.tick = max(usage.tick, item.lastUsed)
+		usage.add(item)
+	}
+
+	s.usage = usage
+	return nil
+}
+
+// saveUsage replaces the usage file with the current usage of the keys
+func (s *Store) saveUsage(st *OpStats) error {
+	if s.usage == nil {
+		return nil
+	}
+
+	s.usage.lock.Lock()
+	data := make(map[string]string, len(s.usage.byKey))
+	for key, item := range s.usage.byKey {
+		data[key] = fmt.Sprintf("%d-%d", item.uses, item.lastUsed)
+	}
+	s.usage.lock.Unlock()
+
+	err := s.writeFile(s.usageFilePath, []byte(s.separators.encodeMapData(data)))
+	if err != nil {
+		return err
+	}
+	st.recordFileRewrite(s.usageFilePath)
+
+	return nil
+}
+
+// parseKeyUsage parses the "<uses>-<lastUsed>" value of a key in the usage file
+func parseKeyUsage(value string) (uint64, uint64, error) {
+	uses, lastUsed, ok := strings.Cut(value, "-")
+	if !ok {
+		return 0, 0, ErrCorruptedData
+	}
+
+	u, err := strconv.ParseUint(uses, 10, 64)
+	if err != nil {
+		return 0, 0, ErrCorruptedData
+	}
+
+	l, err := strconv.ParseUint(lastUsed, 10, 64)
+	if err != nil {
+		return 0, 0, ErrCorruptedData
+	}
+
+	return u, l, nil
+}
@@ -0,0 +1,102 @@
+package internal
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEviction(t *testing.T) {
+	dbPath, err := filepath.Abs("testEvictionDb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = ClearDummyFileDataInDb(dbPath) }()
+
+	// newStore returns a loaded store, on an empty database folder, with the keys set in order
+	newStore := func(t *testing.T, policy EvictionPolicy, keys ...string) *Store {
+		err := ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		store := NewStore(dbPath, 320.0/1024, WithMaxKeys(3, policy))
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, k := range keys {
+			err = store.Set(k, k+" value")
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		return store
+	}
+
+	t.Run("LRUShouldEvictTheLeastRecentlyUsedKey", func(t *testing.T) {
+		store := newStore(t, EvictLRU, "cow", "goat", "hen")
+		_, err := store.Get("cow")
+		assert.Nil(t, err)
+
+		err = store.Set("pig", "pig value")
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"cow", "hen", "pig"}, store.Keys())
+
+		// updating a key does not evict any other
+		err = store.Set("hen", "new hen value")
+		assert.Nil(t, err)
+		err = store.Set("fish", "fish value")
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"fish", "hen", "pig"}, store.Keys())
+		assert.Equal(t, int64(2), store.Stats().EvictedKeys)
+	})
+
+	t.Run("LFUShouldEvictTheLeastFrequentlyUsedKey", func(t *testing.T) {
+		store := newStore(t, EvictLFU, "cow", "goat", "hen")
+		for _, k := range []string{"cow", "cow", "goat", "hen", "hen"} {
+			_, err := store.Get(k)
+			assert.Nil(t, err)
+		}
+
+		err := store.Set("pig", "pig value")
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"cow", "hen", "pig"}, store.Keys())
+
+		// among keys used as often, the least recently used is evicted
+		err = store.Set("fish", "fish value")
+		assert.Nil(t, err)
+		err = store.Set("dog", "dog value")
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"cow", "dog", "hen"}, store.Keys())
+	})
+
+	t.Run("DeletedKeysShouldFreeTheirPlace", func(t *testing.T) {
+		store := newStore(t, EvictLRU, "cow", "goat", "hen")
+		err := store.Delete("goat")
+		assert.Nil(t, err)
+
+		err = store.Set("pig", "pig value")
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"cow", "hen", "pig"}, store.Keys())
+		assert.Equal(t, int64(0), store.Stats().EvictedKeys)
+	})
+
+	t.Run("UsageShouldBeSavedOnVacuum", func(t *testing.T) {
+		store := newStore(t, EvictLRU, "cow", "goat", "hen")
+		_, err := store.Get("cow")
+		assert.Nil(t, err)
+		err = store.Vacuum()
+		assert.Nil(t, err)
+
+		reloaded := NewStore(dbPath, 320.0/1024, WithMaxKeys(3, EvictLRU))
+		err = reloaded.Load()
+		assert.Nil(t, err)
+		err = reloaded.Set("pig", "pig value")
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"cow", "hen", "pig"}, reloaded.Keys())
+	})
+}
@@ -0,0 +1,14 @@
+package internal
+
+// The points at which failpoints can be enabled in builds with the ckydb_failpoints tag
+const (
+	// FailpointBeforeLogAppend is hit before a new or updated value is written to the log file
+	FailpointBeforeLogAppend = "before-log-append"
+	// FailpointAfterIndexWrite is hit after a new key has been added to the index and the index file
+	FailpointAfterIndexWrite = "after-index-write"
+	// FailpointDuringRoll is hit while the log file is rolled, after the new log file has been created
+	// and before the old one is renamed to a data file
+	FailpointDuringRoll = "during-roll"
+	// FailpointDuringLoad is hit while the store is loaded, once its keys can be read with GetWhileLoadingWithStats
+	FailpointDuringLoad = "during-load"
+)
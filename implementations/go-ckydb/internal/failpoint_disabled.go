@@ -0,0 +1,8 @@
+//go:build !ckydb_failpoints
+
+package internal
+
+// failpoint does nothing in builds without the ckydb_failpoints tag
+func failpoint(name string) error {
+	return nil
+}
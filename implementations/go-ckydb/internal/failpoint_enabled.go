@@ -0,0 +1,29 @@
+//go:build ckydb_failpoints
+
+package internal
+
+import "sync"
+
+// failpoints holds the function run at each enabled failpoint, by name
+var failpoints sync.Map
+
+// EnableFailpoint makes the store run fn whenever it hits the failpoint of the given name,
+// failing the operation under way with the error fn returns, if any
+func EnableFailpoint(name string, fn func() error) {
+	failpoints.Store(name, fn)
+}
+
+// DisableFailpoint stops the store from running anything at the failpoint of the given name
+func DisableFailpoint(name string) {
+	failpoints.Delete(name)
+}
+
+// failpoint runs the function enabled at the failpoint of the given name, if any, returning its error
+func failpoint(name string) error {
+	fn, ok := failpoints.Load(name)
+	if !ok {
+		return nil
+	}
+
+	return fn.(func() error)()
+}
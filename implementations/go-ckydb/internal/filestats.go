@@ -0,0 +1,158 @@
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// FileStats are the reads and writes of the keys of a log or data file since the store was loaded.
+// They are kept in memory only
+type FileStats struct {
+	// Name is the name of the file e.g. "1655375120328185000.cky"
+	Name   string
+	Reads  int64
+	Writes int64
+	// LastRead is when a key was last read from the file. It is zero if none has been since the store was loaded
+	LastRead time.Time
+	// LastWrite is when a key was last set in the file. It is zero if none has been since the store was loaded
+	LastWrite time.Time
+}
+
+// fileAccessTracker tracks the reads and writes of the log and data files, by their timestamps
+type fileAccessTracker struct {
+	loadedAt time.Time
+	files    map[string]*FileStats
+	lock     sync.Mutex
+}
+
+// reset forgets the reads and writes of all files, as of the given load time
+func (f *fileAccessTracker) reset(loadedAt time.Time) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.loadedAt = loadedAt
+	f.files = map[string]*FileStats{}
+}
+
+// recordRead records a read of a key of the file of the given timestamp at the given time
+func (f *fileAccessTracker) recordRead(file string, at time.Time) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	stats := f.get(file)
+	stats.Reads++
+	stats.LastRead = at
+}
+
+// recordWrite records a write of a key of the file of the given timestamp at the given time
+func (f *fileAccessTracker) recordWrite(file string, at time.Time) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	stats := f.get(file)
+	stats.Writes++
+	stats.LastWrite = at
+}
+
+// merge adds the reads and writes of the file of timestamp from to those of the file of timestamp into,
+// and forgets the former, as it has been merged into the latter
+func (f *fileAccessTracker) merge(from string, into string) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	src, ok := f.files[from]
+	if !ok {
+		return
+	}
+
+	dst := f.get(into)
+	dst.Reads += src.Reads
+	dst.Writes += src.Writes
+	dst.LastRead = latest(dst.LastRead, src.LastRead)
+	dst.LastWrite = latest(dst.LastWrite, src.LastWrite)
+	delete(f.files, from)
+}
+
+// remove forgets the reads and writes of the file of the given timestamp
+func (f *fileAccessTracker) remove(file string) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	delete(f.files, file)
+}
+
+// lastAccess returns when the file of the given timestamp was last read or written,
+// or when the store was loaded if it has not been since
+func (f *fileAccessTracker) lastAccess(file string) time.Time {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	stats, ok := f.files[file]
+	if !ok {
+		return f.loadedAt
+	}
+
+	return latest(f.loadedAt, latest(stats.LastRead, stats.LastWrite))
+}
+
+// get returns the stats of the file of the given timestamp, adding them if they are missing.
+// The lock must be held
+func (f *fileAccessTracker) get(file string) *FileStats {
+	if f.files == nil {
+		f.files = map[string]*FileStats{}
+	}
+
+	stats, ok := f.files[file]
+	if !ok {
+		stats = &FileStats{}
+		f.files[file] = stats
+	}
+
+	return stats
+}
+
+// fileStats returns the stats of the data files and the log file, from the oldest to the newest
+func (s *Store) fileStats() []FileStats {
+	s.fileAccess.lock.Lock()
+	defer s.fileAccess.lock.Unlock()
+
+	timestamps := append(append(make([]string, 0, len(s.dataFiles)+1), s.dataFiles...), s.currentLogFile)
+	sort.Strings(timestamps)
+	files := make([]FileStats, 0, len(timestamps))
+	for _, timestamp := range timestamps {
+		var stats FileStats
+		if recorded, ok := s.fileAccess.files[timestamp]; ok {
+			stats = *recorded
+		}
+
+		ext := DataFileExt
+		if timestamp == s.currentLogFile {
+			ext = LogFileExt
+		}
+		stats.Name = fmt.Sprintf("%s.%s", timestamp, ext)
+		files = append(files, stats)
+	}
+
+	return files
+}
+
+// isIdle returns true if the data file of the given timestamp has not been read or written
+// for the MinIdle of the retention policy, if any
+func (s *Store) isIdle(dataFile string) bool {
+	if s.retentionPolicy == nil || s.retentionPolicy.MinIdle <= 0 {
+		return true
+	}
+
+	return s.clock.Now().Sub(s.fileAccess.lastAccess(dataFile)) >= s.retentionPolicy.MinIdle
+}
+
+// latest returns the later of the two times
+func latest(a time.Time, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+
+	return b
+}
@@ -0,0 +1,114 @@
+package internal
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileStats(t *testing.T) {
+	dbPath, err := filepath.Abs("testFileStatsDb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2022, 6, 16, 10, 0, 0, 0, time.UTC)
+	defer func() { _ = ClearDummyFileDataInDb(dbPath) }()
+
+	// newStore returns a loaded store on the dummy data whose time is controlled by the clock
+	newStore := func(t *testing.T, opts ...StoreOption) (*Store, *FakeClock) {
+		err := ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = AddDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		clock := NewFakeClock(start)
+		store := NewStore(dbPath, 4, append([]StoreOption{WithClock(clock)}, opts...)...)
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return store, clock
+	}
+
+	t.Run("StatsShouldCountTheReadsAndWritesOfEachFile", func(t *testing.T) {
+		store, clock := newStore(t)
+		assert.Equal(t, []FileStats{
+			{Name: "1655375120328185000.cky"},
+			{Name: "1655375120328186000.cky"},
+			{Name: "1655375171402014000.log"},
+		}, store.Stats().Files)
+
+		for _, key := range []string{"cow", "dog"} {
+			_, err := store.Get(key)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		clock.Advance(time.Minute)
+		err := store.Set("cow", "501 months")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = store.Get("goat")
+		if err != nil {
+			t.Fatal(err)
+		}
+		clock.Advance(time.Minute)
+		err = store.Set("ram", "2 months")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, []FileStats{
+			{Name: "1655375120328185000.cky", Reads: 2, Writes: 1, LastRead: start, LastWrite: start.Add(time.Minute)},
+			{Name: "1655375120328186000.cky"},
+			{Name: "1655375171402014000.log", Reads: 1, Writes: 1, LastRead: start.Add(time.Minute), LastWrite: start.Add(2 * time.Minute)},
+		}, store.Stats().Files)
+	})
+
+	t.Run("CompactShouldAddUpTheStatsOfTheMergedFiles", func(t *testing.T) {
+		store, clock := newStore(t)
+		_, err := store.Get("cow")
+		if err != nil {
+			t.Fatal(err)
+		}
+		store.fileAccess.recordRead("1655375120328186000", start.Add(time.Minute))
+		clock.Advance(time.Hour)
+
+		err = store.Compact()
+		assert.Nil(t, err)
+
+		files := store.Stats().Files
+		assert.Equal(t, 2, len(files))
+		assert.Equal(t, FileStats{Name: "1655375120328185000.cky", Reads: 2, LastRead: start.Add(time.Minute)}, files[0])
+	})
+
+	t.Run("RetentionPolicyWithMinIdleShouldSpareTheDataFilesInUse", func(t *testing.T) {
+		store, clock := newStore(t, WithRetentionPolicy(RetentionPolicy{MaxDataFiles: 1, Action: RetentionDelete, MinIdle: time.Hour}))
+		assert.Equal(t, 2, store.Stats().DataFiles)
+
+		clock.Advance(time.Hour - time.Second)
+		_, err := store.Get("cow")
+		if err != nil {
+			t.Fatal(err)
+		}
+		clock.Advance(time.Second)
+		err = store.EnforceRetention()
+		assert.Nil(t, err)
+		assert.Equal(t, 2, store.Stats().DataFiles)
+
+		clock.Advance(time.Hour)
+		err = store.EnforceRetention()
+		assert.Nil(t, err)
+		assert.Equal(t, 1, store.Stats().DataFiles)
+		_, err = store.Get("cow")
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
@@ -0,0 +1,85 @@
+package internal
+
+import (
+	"os"
+	"strings"
+)
+
+// FindWithStats returns at most limit of the key-value pairs for which predicate returns true, recording what it
+// did in st. It goes through the data files one at a time, from the oldest, and then the memtable, without loading
+// any data file into the cache, so that only one of them is held in memory at a time. A data file already in the
+// cache is not read again. A limit of zero or less returns all of the pairs found
+func (s *Store) FindWithStats(predicate func(key string, value string) bool, limit int, st *OpStats) (map[string]string, error) {
+	found := map[string]string{}
+	for _, dataFile := range s.dataFiles {
+		records, err := s.readDataFileRecords(dataFile, st)
+		if err != nil {
+			return nil, err
+		}
+
+		isDone, err := s.findInRecords(records, predicate, limit, found)
+		if err != nil {
+			return nil, err
+		}
+		if isDone {
+			return found, nil
+		}
+	}
+
+	_, err := s.findInRecords(s.memtable, predicate, limit, found)
+	if err != nil {
+		return nil, err
+	}
+
+	return found, nil
+}
+
+// readDataFileRecords returns the records of the given data file, from the cache if it holds them
+func (s *Store) readDataFileRecords(dataFile string, st *OpStats) (map[string]string, error) {
+	s.cacheLock.Lock()
+	cache := s.cache
+	s.cacheLock.Unlock()
+	if cache.start == dataFile {
+		return cache.data, nil
+	}
+
+	path := s.getDataFilePath(dataFile)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	st.recordRead(path, len(data))
+
+	return s.separators.extractKeyValues(data)
+}
+
+// findInRecords adds the pairs of the current records of existing keys for which predicate returns true to found,
+// returning true once found holds limit pairs
+func (s *Store) findInRecords(records map[string]string, predicate func(key string, value string) bool, limit int, found map[string]string) (bool, error) {
+	for timestampedKey, sealed := range records {
+		_, key, _ := strings.Cut(timestampedKey, "-")
+		if _, ok := found[key]; ok || isReservedKey(key) {
+			continue
+		}
+
+		// records of deleted keys that are yet to be vacuumed are skipped
+		current, ok := s.lookup(key)
+		if !ok || current != timestampedKey {
+			continue
+		}
+
+		value, err := s.openValue(timestampedKey, sealed)
+		if err != nil {
+			return false, err
+		}
+
+		if predicate(key, value) {
+			found[key] = value
+			if limit > 0 && len(found) >= limit {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
@@ -0,0 +1,76 @@
+package internal
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFind(t *testing.T) {
+	dbPath, err := filepath.Abs("testFindDb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = ClearDummyFileDataInDb(dbPath) }()
+
+	// newStore returns a loaded store on the dummy data
+	newStore := func(t *testing.T) *Store {
+		err := ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = AddDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		store := NewStore(dbPath, 4)
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return store
+	}
+
+	t.Run("FindShouldReturnThePairsMatchingThePredicateInTheMemtableAndDataFiles", func(t *testing.T) {
+		store := newStore(t)
+		found, err := store.FindWithStats(func(key string, value string) bool {
+			return strings.HasPrefix(value, "5") || strings.HasPrefix(value, "6")
+		}, 0, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]string{"cow": "500 months", "hen": "567 months", "goat": "678 months"}, found)
+		assert.Equal(t, int64(0), store.Stats().CacheLoads, "no data file is loaded into the cache")
+	})
+
+	t.Run("FindShouldSkipDeletedAndUpdatedRecords", func(t *testing.T) {
+		store := newStore(t)
+		err := store.Delete("cow")
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = store.Set("dog", "24 months")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		found, err := store.FindWithStats(func(key string, value string) bool { return true }, 0, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]string{
+			"dog":  "24 months",
+			"goat": "678 months",
+			"hen":  "567 months",
+			"pig":  "70 months",
+			"fish": "8990 months",
+		}, found)
+	})
+
+	t.Run("FindShouldStopAtTheLimit", func(t *testing.T) {
+		store := newStore(t)
+		found, err := store.FindWithStats(func(key string, value string) bool { return true }, 2, nil)
+		assert.Nil(t, err)
+		assert.Len(t, found, 2)
+	})
+}
@@ -0,0 +1,179 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FollowerState is the in-memory state of a follower as read from its database folder
+type FollowerState struct {
+	separators         Separators
+	valueFlags         bool
+	recordMACs         bool
+	index              map[string]string
+	memtable           map[string]string
+	expiries           map[string]int64
+	dataFiles          []string
+	dataFileFolders    map[string]string
+	currentLogFile     string
+	currentLogFilePath string
+	metaSegment        map[string]string
+	casings            map[string]string
+}
+
+// WithFollower makes the store a read-only follower of a database folder written to by another store,
+// possibly in another process or on another host e.g. over NFS. Loading it only reads the folder, never
+// writing to it, and writes fail with an ErrFollower error. It sees the changes made since it was loaded
+// once its state is read again with ReadFollowerState and swapped in with SwapFollowerState
+func WithFollower(isEnabled bool) StoreOption {
+	return func(s *Store) {
+		s.isFollower = isEnabled
+	}
+}
+
+// ReadFollowerState reads the state of a follower from its database folder without changing the store,
+// so it can be called while the store is in use. The index is read before the log file, as the other
+// store sets values before adding their keys to the index. A record torn by a write in progress is ignored.
+// It returns an error if the folder changes while it is read e.g. when the log file is rolled, in which
+// case it is read again later
+func (s *Store) ReadFollowerState() (*FollowerState, error) {
+	_, err := checkLoadableFormatVersion(s.dbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	meta, err := readMetadata(s.dbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if meta.hmacKeyCheck != "" {
+		err = checkHMACKey(s.hmacKey, meta.hmacKeyCheck)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sep := meta.separators
+	state := &FollowerState{
+		separators: sep,
+		valueFlags: meta.valueFlags,
+		recordMACs: meta.hmacKeyCheck != "",
+		expiries:   map[string]int64{},
+	}
+	index, err := sep.readAppendOnlyFile(s.indexFilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	state.index, err = sep.expandIndex(index)
+	if err != nil {
+		return nil, err
+	}
+
+	filenames, err := getLogAndDataFilenames(s.dbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, path := range filenames {
+		filename := filepath.Base(path)
+		switch filepath.Ext(filename) {
+		case "." + LogFileExt:
+			state.currentLogFile = max(state.currentLogFile, strings.TrimSuffix(filename, "."+LogFileExt))
+		case "." + DataFileExt:
+			state.dataFiles = append(state.dataFiles, strings.TrimSuffix(filename, "."+DataFileExt))
+		}
+	}
+	sort.Strings(state.dataFiles)
+	state.dataFileFolders = getDataFileFolders(filenames)
+
+	if state.currentLogFile == "" {
+		return nil, ErrCorruptedData
+	}
+
+	// the older log files of an interrupted log roll are data files to the other store
+	for _, filename := range filenames {
+		logFile := strings.TrimSuffix(filename, "."+LogFileExt)
+		if filepath.Ext(filename) == "."+LogFileExt && logFile != state.currentLogFile {
+			return nil, fmt.Errorf("log file %s is being rolled", filename)
+		}
+	}
+
+	state.currentLogFilePath = filepath.Join(s.dbPath, fmt.Sprintf("%s.%s", state.currentLogFile, LogFileExt))
+	state.memtable, err = sep.readAppendOnlyFile(state.currentLogFilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	expiries, err := sep.readAppendOnlyFile(s.expiryFilePath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	for key, value := range expiries {
+		state.expiries[key], err = strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, ErrCorruptedData
+		}
+	}
+
+	state.metaSegment, err = readMetaSegment(sep, s.metaSegmentFilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	state.casings, err = sep.readAppendOnlyFile(s.casingFilePath)
+	if os.IsNotExist(err) {
+		state.casings, err = map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return state, nil
+}
+
+// SwapFollowerState replaces the in-memory state of the store with the given state at once,
+// emptying the cache as the data files may have been rewritten since it was loaded
+func (s *Store) SwapFollowerState(state *FollowerState) {
+	s.separators = state.separators
+	s.valueFlags = state.valueFlags
+	s.recordMACs = state.recordMACs
+	s.index = state.index
+	s.memtable = state.memtable
+	s.expiries = state.expiries
+	s.dataFiles = state.dataFiles
+	s.dataFileFolders = state.dataFileFolders
+	s.currentLogFile = state.currentLogFile
+	s.currentLogFilePath = state.currentLogFilePath
+	s.metaSegment = state.metaSegment
+	s.casings = state.casings
+	s.resetCache()
+}
+
+// loadFollower loads a follower from its database folder without writing to it
+func (s *Store) loadFollower() error {
+	state, err := s.ReadFollowerState()
+	if err != nil {
+		return err
+	}
+
+	s.SwapFollowerState(state)
+	return nil
+}
+
+// readAppendOnlyFile reads the key-value pairs in the file at path, ignoring a torn record at
+// its end, without repairing the file
+func (sep Separators) readAppendOnlyFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return sep.extractKeyValues(sep.withoutTornRecord(data))
+}
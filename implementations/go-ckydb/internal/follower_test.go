@@ -0,0 +1,132 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFollower(t *testing.T) {
+	dbPath, err := filepath.Abs("testFollowerDb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = ClearDummyFileDataInDb(dbPath) }()
+
+	// newLeader returns a loaded store, on an empty database folder, that rolls its log file every few keys
+	newLeader := func(t *testing.T) *Store {
+		err := ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		leader := NewStore(dbPath, 0.1)
+		err = leader.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return leader
+	}
+
+	// refresh reads the follower's state again and swaps it in
+	refresh := func(t *testing.T, follower *Store) {
+		state, err := follower.ReadFollowerState()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		follower.SwapFollowerState(state)
+	}
+
+	t.Run("FollowerShouldSeeTheChangesOfTheLeaderOnceRefreshed", func(t *testing.T) {
+		leader := newLeader(t)
+		assert.Nil(t, leader.Set("cow", "500 months"))
+
+		follower := NewStore(dbPath, 0.1, WithFollower(true))
+		err := follower.Load()
+		assert.Nil(t, err)
+		value, err := follower.Get("cow")
+		assert.Nil(t, err)
+		assert.Equal(t, "500 months", value)
+
+		for i := 0; i < 50; i++ {
+			assert.Nil(t, leader.Set(fmt.Sprintf("key-%d", i), fmt.Sprintf("value-%d", i)))
+		}
+		assert.Nil(t, leader.Set("cow", "501 months"))
+		assert.Nil(t, leader.Delete("key-0"))
+
+		// the follower keeps its old state until it is refreshed
+		assert.False(t, follower.Has("key-1"))
+		value, err = follower.Get("cow")
+		assert.Nil(t, err)
+		assert.Equal(t, "500 months", value)
+
+		refresh(t, follower)
+		assert.Equal(t, leader.Keys(), follower.Keys())
+		for _, k := range leader.Keys() {
+			expected, err := leader.Get(k)
+			assert.Nil(t, err)
+			value, err := follower.Get(k)
+			assert.Nil(t, err)
+			assert.Equal(t, expected, value)
+		}
+	})
+
+	t.Run("FollowerShouldNeverWriteToTheFolder", func(t *testing.T) {
+		leader := newLeader(t)
+		assert.Nil(t, leader.Set("cow", "500 months"))
+
+		// a record torn by a write in progress
+		f, err := os.OpenFile(leader.indexFilePath, os.O_APPEND|os.O_WRONLY, 0666)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = f.WriteString("goat" + KeyValueSeparator)
+		_ = f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		before, err := GetFileOrFolderNamesInFolder(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		index := mustReadFile(t, leader.indexFilePath)
+
+		follower := NewStore(dbPath, 0.1, WithFollower(true))
+		assert.Nil(t, follower.Load())
+		assert.Equal(t, []string{"cow"}, follower.Keys())
+		assert.ErrorIs(t, follower.Set("pig", "70 months"), ErrFollower)
+		assert.ErrorIs(t, follower.Delete("cow"), ErrFollower)
+		assert.ErrorIs(t, follower.Vacuum(), ErrFollower)
+		assert.ErrorIs(t, follower.Clear(), ErrFollower)
+
+		after, err := GetFileOrFolderNamesInFolder(dbPath)
+		assert.Nil(t, err)
+		assert.Equal(t, before, after)
+		assert.Equal(t, index, mustReadFile(t, leader.indexFilePath))
+	})
+
+	t.Run("ReadFollowerStateShouldFailWhileTheLogFileIsRolled", func(t *testing.T) {
+		leader := newLeader(t)
+		assert.Nil(t, leader.Set("cow", "500 months"))
+		follower := NewStore(dbPath, 0.1, WithFollower(true))
+		assert.Nil(t, follower.Load())
+
+		// the new log file is created before the current one is renamed
+		_, _, err := leader.createLogFile()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = follower.ReadFollowerState()
+		assert.NotNil(t, err)
+		value, err := follower.Get("cow")
+		assert.Nil(t, err)
+		assert.Equal(t, "500 months", value)
+	})
+}
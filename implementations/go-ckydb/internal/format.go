@@ -0,0 +1,195 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	FormatVersionFilename = "format.version"
+	// CurrentFormatVersion is the newest version of the disk format this implementation reads and writes.
+	// Folders without a version file are of version 1, the format shared by all implementations, in which
+	// new databases are still created. Version 2 records are length-prefixed instead of separated, and
+	// version 3 index records hold the timestamp of each key instead of its timestamped key
+	CurrentFormatVersion = 3
+)
+
+var (
+	// supportedFormatVersion is the newest version folders can be migrated to and loaded at.
+	// It is only ever different from CurrentFormatVersion in tests, standing in for a future version
+	supportedFormatVersion = CurrentFormatVersion
+	// oldestLoadableFormatVersion is the oldest version folders can be loaded at without being migrated
+	oldestLoadableFormatVersion = 1
+)
+
+// formatMigrations convert a database folder from the version they are keyed by to the next version,
+// and formatDowngrades to the previous one. The store they are given is configured but not loaded
+var (
+	formatMigrations = map[int]func(s *Store) error{1: migrateToLengthPrefixed, 2: migrateToCompactIndex}
+	formatDowngrades = map[int]func(s *Store) error{2: migrateToSeparated, 3: migrateToFullIndex}
+)
+
+// ReadFormatVersion returns the version of the disk format of the database folder at dbPath
+func ReadFormatVersion(dbPath string) (int, error) {
+	data, err := os.ReadFile(filepath.Join(dbPath, FormatVersionFilename))
+	if os.IsNotExist(err) {
+		return 1, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	version, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || version < 1 {
+		return 0, ErrCorruptedData
+	}
+
+	return version, nil
+}
+
+// MigrateFormat upgrades the database folder at dbPath, which must not be open, to the newest
+// supported version. See MigrateFormatTo
+func MigrateFormat(dbPath string, opts ...StoreOption) error {
+	return MigrateFormatTo(dbPath, supportedFormatVersion, opts...)
+}
+
+// MigrateFormatTo converts the database folder at dbPath, which must not be open, to the given version
+// one version at a time, writing the version file after each step. The options configure the conversion
+// e.g. WithRecordHMAC for folders whose records are authenticated. An interrupted conversion is resumed
+// by calling it again, and the folder cannot be loaded until then.
+// It returns an ErrUnsupportedFormatVersion error if the folder or the version is newer than this
+// implementation understands
+func MigrateFormatTo(dbPath string, version int, opts ...StoreOption) error {
+	return NewStore(dbPath, 0, opts...).migrateFormatTo(version)
+}
+
+// MigrateFormatTo converts the loaded database to the given format version, e.g. to the length-prefixed
+// records of version 2 so that keys and values may contain the separators, and then loads it again.
+// Open snapshots are invalidated
+func (s *Store) MigrateFormatTo(version int) error {
+	return s.guardWrite(func() error {
+		err := s.saveUsage(nil)
+		if err != nil {
+			return err
+		}
+
+		err = s.flushIndex(nil)
+		if err != nil {
+			return err
+		}
+
+		err = s.migrateFormatTo(version)
+		if err != nil {
+			return err
+		}
+
+		return s.Load()
+	})
+}
+
+// migrateFormatTo converts the database folder of the store to the target version, first finishing
+// any interrupted conversion
+func (s *Store) migrateFormatTo(target int) error {
+	if target < 1 || target > supportedFormatVersion {
+		return fmt.Errorf("%w: %d", ErrUnsupportedFormatVersion, target)
+	}
+
+	err := s.resumeFormatMigration()
+	if err != nil {
+		return err
+	}
+
+	version, err := ReadFormatVersion(s.dbPath)
+	if err != nil {
+		return err
+	}
+
+	if version > supportedFormatVersion {
+		return fmt.Errorf("%w: %d", ErrUnsupportedFormatVersion, version)
+	}
+
+	for version != target {
+		next, steps := version+1, formatMigrations
+		if target < version {
+			next, steps = version-1, formatDowngrades
+		}
+
+		migrate, ok := steps[version]
+		if !ok {
+			return fmt.Errorf("no migration from format version %d to %d", version, next)
+		}
+
+		err = migrate(s)
+		if err != nil {
+			return err
+		}
+
+		err = s.finishFormatMigration(next)
+		if err != nil {
+			return err
+		}
+
+		version = next
+	}
+
+	return nil
+}
+
+// checkFormatVersion fails fast with an ErrUnsupportedFormatVersion error if the database folder
+// is of a newer version than this implementation understands, an ErrOutdatedFormatVersion error
+// if it needs MigrateFormat first, or an ErrMigrationInProgress error if a migration of it was interrupted.
+// It writes the version file if it is missing
+func (s *Store) checkFormatVersion() error {
+	version, err := checkLoadableFormatVersion(s.dbPath)
+	if err != nil {
+		return err
+	}
+
+	_, err = os.Stat(filepath.Join(s.dbPath, FormatVersionFilename))
+	if os.IsNotExist(err) {
+		return writeFormatVersion(s.fs, s.dbPath, version)
+	}
+
+	return err
+}
+
+// checkLoadableFormatVersion returns the version of the database folder at dbPath, failing
+// if the folder cannot be loaded at it
+func checkLoadableFormatVersion(dbPath string) (int, error) {
+	version, err := ReadFormatVersion(dbPath)
+	if err != nil {
+		return 0, err
+	}
+
+	switch {
+	case version > supportedFormatVersion:
+		return 0, fmt.Errorf("%w: %d", ErrUnsupportedFormatVersion, version)
+	case version < oldestLoadableFormatVersion:
+		return 0, fmt.Errorf("%w: %d", ErrOutdatedFormatVersion, version)
+	}
+
+	_, err = os.Stat(filepath.Join(dbPath, FormatMigrationFilename))
+	if err == nil {
+		return 0, ErrMigrationInProgress
+	}
+	if !os.IsNotExist(err) {
+		return 0, err
+	}
+
+	return version, nil
+}
+
+// writeFormatVersion writes the version file of the database folder at dbPath
+func writeFormatVersion(fs FileSystem, dbPath string, version int) error {
+	path := filepath.Join(dbPath, FormatVersionFilename)
+	tempFilePath := path + "." + TempFileExt
+	err := fs.WriteFile(tempFilePath, []byte(strconv.Itoa(version)))
+	if err != nil {
+		return err
+	}
+
+	return fs.Rename(tempFilePath, path)
+}
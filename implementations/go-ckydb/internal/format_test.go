@@ -0,0 +1,125 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatVersion(t *testing.T) {
+	dbPath, err := filepath.Abs("testFormatVersionDb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	versionFilePath := filepath.Join(dbPath, FormatVersionFilename)
+	defer func() { _ = ClearDummyFileDataInDb(dbPath) }()
+
+	t.Run("LoadShouldWriteTheCurrentVersionToFoldersWithoutOne", func(t *testing.T) {
+		err := ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = AddDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		version, err := ReadFormatVersion(dbPath)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, version)
+
+		store := NewStore(dbPath, 320.0/1024)
+		err = store.Load()
+		assert.Nil(t, err)
+
+		content, err := ReadFileToString(versionFilePath)
+		assert.Nil(t, err)
+		assert.Equal(t, "1", content)
+		value, err := store.Get("cow")
+		assert.Nil(t, err)
+		assert.Equal(t, "500 months", value)
+	})
+
+	t.Run("LoadShouldFailFastOnNewerVersions", func(t *testing.T) {
+		err := ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.MkdirAll(dbPath, 0777)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(versionFilePath, []byte("4"), 0666)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = NewStore(dbPath, 320.0/1024).Load()
+		assert.ErrorIs(t, err, ErrUnsupportedFormatVersion)
+		err = MigrateFormat(dbPath)
+		assert.ErrorIs(t, err, ErrUnsupportedFormatVersion)
+
+		// nothing should have been written to the folder
+		files, err := GetFileOrFolderNamesInFolder(dbPath)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{FormatVersionFilename}, files)
+	})
+
+	t.Run("LoadShouldFailOnCorruptVersionFiles", func(t *testing.T) {
+		err := ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.MkdirAll(dbPath, 0777)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(versionFilePath, []byte("one"), 0666)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = NewStore(dbPath, 320.0/1024).Load()
+		assert.Equal(t, ErrCorruptedData, err)
+	})
+
+	t.Run("MigrateFormatShouldUpgradeOlderVersionsSoThatTheyCanBeLoaded", func(t *testing.T) {
+		err := ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = AddDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var migrated []string
+		supportedFormatVersion, oldestLoadableFormatVersion = 4, 4
+		formatMigrations[3] = func(s *Store) error {
+			migrated = append(migrated, s.dbPath)
+			return nil
+		}
+		defer func() {
+			supportedFormatVersion, oldestLoadableFormatVersion = CurrentFormatVersion, 1
+			delete(formatMigrations, 3)
+		}()
+
+		err = NewStore(dbPath, 320.0/1024).Load()
+		assert.ErrorIs(t, err, ErrOutdatedFormatVersion)
+
+		err = MigrateFormat(dbPath)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{dbPath}, migrated)
+		version, err := ReadFormatVersion(dbPath)
+		assert.Nil(t, err)
+		assert.Equal(t, 4, version)
+
+		err = NewStore(dbPath, 320.0/1024).Load()
+		assert.Nil(t, err)
+		err = MigrateFormat(dbPath)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(migrated))
+	})
+}
@@ -0,0 +1,209 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const TempFileExt = "tmp"
+
+// FileSystem is the set of operations through which the store modifies the files
+// in the database folder
+type FileSystem interface {
+	// WriteFile creates or truncates the file at path and writes data to it
+	WriteFile(path string, data []byte) error
+	// AppendFile creates the file at path if it does not exist and appends data to it,
+	// returning the number of bytes written
+	AppendFile(path string, data []byte) (int, error)
+	// Rename renames the file at oldPath to newPath, replacing newPath if it exists
+	Rename(oldPath string, newPath string) error
+	// Remove removes the file at path
+	Remove(path string) error
+}
+
+// osFileSystem is the FileSystem backed by the operating system
+type osFileSystem struct{}
+
+func (osFileSystem) WriteFile(path string, data []byte) error {
+	return os.WriteFile(path, data, 0666)
+}
+
+func (osFileSystem) AppendFile(path string, data []byte) (int, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := f.Write(data)
+	if err != nil {
+		_ = f.Close()
+		return n, err
+	}
+
+	return n, f.Close()
+}
+
+func (osFileSystem) Rename(oldPath string, newPath string) error {
+	return os.Rename(oldPath, newPath)
+}
+
+func (osFileSystem) Remove(path string) error {
+	return os.Remove(path)
+}
+
+// WithFileSystem sets the FileSystem through which the store modifies its files.
+// It defaults to the operating system's file system
+func WithFileSystem(fs FileSystem) StoreOption {
+	return func(s *Store) {
+		s.fs = fs
+	}
+}
+
+// writeFile replaces the contents of the file at path with data, keeping the
+// checksum and backup of checksummed files, and the manifest of data files, up to date
+func (s *Store) writeFile(path string, data []byte) error {
+	f, ok := s.checksummedFiles[path]
+	if ok {
+		return s.rewriteChecksummedFile(f, data)
+	}
+
+	err := s.replaceFile(path, data)
+	if err != nil {
+		return err
+	}
+
+	return s.recordDataFileHash(path, data)
+}
+
+// replaceFile replaces the contents of the file at path with data. The data is written to
+// a temporary file first which is then renamed to path so that a failure midway
+// leaves the original file intact
+func (s *Store) replaceFile(path string, data []byte) error {
+	tempFilePath := path + "." + TempFileExt
+	err := s.fs.WriteFile(tempFilePath, data)
+	if err != nil {
+		return fileError("writing", path, err)
+	}
+
+	return fileError("writing", path, s.fs.Rename(tempFilePath, path))
+}
+
+// persistMapDataToFile overwrites the data in the file at path with the equivalent of the map data passed
+func (s *Store) persistMapDataToFile(data map[string]string, path string) error {
+	return s.writeFile(path, []byte(s.separators.encodeMapData(data)))
+}
+
+// deleteKeyValuesFromFile deletes the key values corresponding to the keysToDelete
+// if those keys exist in the file at path
+func (s *Store) deleteKeyValuesFromFile(path string, keysToDelete []string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	records, err := s.extractLoadedRecords(path, data, 2, nil)
+	if err != nil {
+		return err
+	}
+
+	return s.writeFile(path, []byte(s.separators.encodeRecordsExcept(records, keysToDelete)))
+}
+
+// appendFile appends data to the file at path, creating it if it does not exist and keeping
+// the checksum of checksummed files up to date
+func (s *Store) appendFile(path string, data []byte) (int, error) {
+	if _, ok := s.checksummedFiles[path]; ok {
+		n, err := s.appendChecksummedFile(path, data)
+		return n, fileError("appending to", path, err)
+	}
+
+	n, err := s.fs.AppendFile(path, data)
+	return n, fileError("appending to", path, err)
+}
+
+// fileError adds what the store was doing to the file at path to err e.g. "appending to 1655375171402014000.log",
+// so that the errors of the file system say which file of the database they are about. It returns nil if err is nil
+func fileError(action string, path string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return fmt.Errorf("%s %s: %w", action, filepath.Base(path), err)
+}
+
+// createFileIfNotExist creates the file at path if it does not exist
+func (s *Store) createFileIfNotExist(path string) error {
+	_, err := s.appendFile(path, nil)
+	return err
+}
+
+// removeTempFiles removes any temporary files left behind by writes that were interrupted,
+// in the database folder and the buckets of WithDataFileBuckets
+func (s *Store) removeTempFiles() error {
+	buckets, err := getBuckets(s.dbPath)
+	if err != nil {
+		return err
+	}
+
+	for _, folder := range append([]string{""}, buckets...) {
+		filesInFolder, err := GetFileOrFolderNamesInFolder(filepath.Join(s.dbPath, folder))
+		if err != nil {
+			return err
+		}
+
+		for _, filename := range filesInFolder {
+			if strings.HasSuffix(filename, "."+TempFileExt) {
+				err = s.fs.Remove(filepath.Join(s.dbPath, folder, filename))
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// repairTornRecord truncates the partially written record, if any, at the end of the
+// append-only file at path. Such a record is left behind if the process crashes midway
+// through appending it
+func (s *Store) repairTornRecord(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	repaired := s.separators.withoutTornRecord(data)
+	if len(repaired) == len(data) {
+		return nil
+	}
+
+	return s.writeFile(path, repaired)
+}
+
+// withoutTornRecord returns the data of an append-only file without the partially written
+// record, if any, at its end
+func (sep Separators) withoutTornRecord(data []byte) []byte {
+	if sep.lengthPrefixed {
+		_, end, err := parseLengthPrefixedRecords(data)
+		if err != nil {
+			return data
+		}
+
+		return data[:end]
+	}
+
+	if len(data) == 0 || bytes.HasSuffix(data, []byte(sep.Token)) {
+		return data
+	}
+
+	end := bytes.LastIndex(data, []byte(sep.Token)) + len(sep.Token)
+	if end < len(sep.Token) {
+		end = 0
+	}
+
+	return data[:end]
+}
@@ -0,0 +1,425 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	errInjected = errors.New("injected fault")
+	errCrashed  = errors.New("crashed")
+)
+
+type faultKind int
+
+const (
+	// faultError fails the operation without touching the disk
+	faultError faultKind = iota
+	// faultPartialWrite writes half of the data of the operation and then crashes
+	faultPartialWrite
+	// faultCrash crashes just before the operation
+	faultCrash
+)
+
+func (k faultKind) String() string {
+	return [...]string{"Error", "PartialWrite", "Crash"}[k]
+}
+
+// faultyFileSystem is a FileSystem that injects a fault at the given operation.
+// After a crash every operation fails, as if the process had died
+type faultyFileSystem struct {
+	osFileSystem
+	failAt     int
+	kind       faultKind
+	ops        int
+	hasCrashed bool
+	lock       sync.Mutex
+}
+
+// arm injects a fault of the given kind at the failAt-th operation from now. Zero means never
+func (f *faultyFileSystem) arm(failAt int, kind faultKind) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.failAt, f.kind, f.ops, f.hasCrashed = failAt, kind, 0, false
+}
+
+// inject returns the fault, if any, for the current operation. For partial writes,
+// it returns the number of bytes of data to write before crashing
+func (f *faultyFileSystem) inject(data []byte) (int, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.hasCrashed {
+		return 0, errCrashed
+	}
+
+	f.ops++
+	if f.ops != f.failAt {
+		return len(data), nil
+	}
+
+	switch f.kind {
+	case faultError:
+		return 0, errInjected
+	case faultPartialWrite:
+		f.hasCrashed = true
+		return len(data) / 2, errCrashed
+	default:
+		f.hasCrashed = true
+		return 0, errCrashed
+	}
+}
+
+func (f *faultyFileSystem) WriteFile(path string, data []byte) error {
+	n, err := f.inject(data)
+	if err != nil {
+		if n > 0 {
+			_ = f.osFileSystem.WriteFile(path, data[:n])
+		}
+		return err
+	}
+
+	return f.osFileSystem.WriteFile(path, data)
+}
+
+func (f *faultyFileSystem) AppendFile(path string, data []byte) (int, error) {
+	n, err := f.inject(data)
+	if err != nil {
+		if n > 0 {
+			_, _ = f.osFileSystem.AppendFile(path, data[:n])
+		}
+		return n, err
+	}
+
+	return f.osFileSystem.AppendFile(path, data)
+}
+
+func (f *faultyFileSystem) Rename(oldPath string, newPath string) error {
+	_, err := f.inject(nil)
+	if err != nil {
+		return err
+	}
+
+	return f.osFileSystem.Rename(oldPath, newPath)
+}
+
+func (f *faultyFileSystem) Remove(path string) error {
+	_, err := f.inject(nil)
+	if err != nil {
+		return err
+	}
+
+	return f.osFileSystem.Remove(path)
+}
+
+func TestCrashConsistency(t *testing.T) {
+	dbPath, err := filepath.Abs("testCrashDb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = ClearDummyFileDataInDb(dbPath) }()
+
+	maxFileSizeKB := 1.0
+	bigValue := strings.Repeat("v", 400)
+	seed := map[string]string{
+		"key-0": bigValue,
+		"key-1": bigValue,
+		"key-2": bigValue,
+		"key-3": "value-3",
+		"key-4": "value-4",
+	}
+
+	// openSeededStore creates a store whose first three keys are in a data file
+	// and the rest are in the log file
+	openSeededStore := func(t *testing.T, fs *faultyFileSystem) *Store {
+		err := ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		fs.arm(0, faultError)
+		store := NewStore(dbPath, maxFileSizeKB, WithFileSystem(fs))
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for i := 0; i < len(seed); i++ {
+			key := fmt.Sprintf("key-%d", i)
+			err = store.Set(key, seed[key])
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if len(store.dataFiles) != 1 {
+			t.Fatalf("expected 1 data file, got %d", len(store.dataFiles))
+		}
+
+		return store
+	}
+
+	withChanges := func(changes map[string]string, deletions ...string) map[string]string {
+		data := map[string]string{}
+		for k, v := range seed {
+			data[k] = v
+		}
+		for k, v := range changes {
+			data[k] = v
+		}
+		for _, k := range deletions {
+			delete(data, k)
+		}
+		return data
+	}
+
+	type testRecord struct {
+		name    string
+		prepare func(store *Store) error
+		op      func(store *Store) error
+		before  map[string]string
+		after   map[string]string
+	}
+
+	testData := []testRecord{
+		{
+			name:   "SetNewKey",
+			op:     func(store *Store) error { return store.Set("new", "value") },
+			before: withChanges(nil),
+			after:  withChanges(map[string]string{"new": "value"}),
+		},
+		{
+			name:   "SetRecentKey",
+			op:     func(store *Store) error { return store.Set("key-3", "updated") },
+			before: withChanges(nil),
+			after:  withChanges(map[string]string{"key-3": "updated"}),
+		},
+		{
+			name:   "SetOldKey",
+			op:     func(store *Store) error { return store.Set("key-0", "updated") },
+			before: withChanges(nil),
+			after:  withChanges(map[string]string{"key-0": "updated"}),
+		},
+		{
+			name:   "SetNewKeyRollingLogFile",
+			op:     func(store *Store) error { return store.Set("new", strings.Repeat("n", 1024)) },
+			before: withChanges(nil),
+			after:  withChanges(map[string]string{"new": strings.Repeat("n", 1024)}),
+		},
+		{
+			name:   "DeleteRecentKey",
+			op:     func(store *Store) error { return store.Delete("key-4") },
+			before: withChanges(nil),
+			after:  withChanges(nil, "key-4"),
+		},
+		{
+			name:   "DeleteOldKey",
+			op:     func(store *Store) error { return store.Delete("key-1") },
+			before: withChanges(nil),
+			after:  withChanges(nil, "key-1"),
+		},
+		{
+			name: "Vacuum",
+			prepare: func(store *Store) error {
+				err := store.Delete("key-1")
+				if err != nil {
+					return err
+				}
+				return store.Delete("key-4")
+			},
+			op:     func(store *Store) error { return store.Vacuum() },
+			before: withChanges(nil, "key-1", "key-4"),
+			after:  withChanges(nil, "key-1", "key-4"),
+		},
+	}
+
+	for _, tr := range testData {
+		// count the file system operations done by the operation when no fault is injected
+		fs := &faultyFileSystem{}
+		store := openSeededStore(t, fs)
+		if tr.prepare != nil {
+			err = tr.prepare(store)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		err = tr.op(store)
+		if err != nil {
+			t.Fatal(err)
+		}
+		numOfOps := fs.ops
+
+		for _, kind := range []faultKind{faultError, faultPartialWrite, faultCrash} {
+			for failAt := 1; failAt <= numOfOps; failAt++ {
+				t.Run(fmt.Sprintf("%s%sAtOperation%d", tr.name, kind, failAt), func(t *testing.T) {
+					fs := &faultyFileSystem{}
+					store := openSeededStore(t, fs)
+					if tr.prepare != nil {
+						err := tr.prepare(store)
+						if err != nil {
+							t.Fatal(err)
+						}
+					}
+
+					fs.arm(failAt, kind)
+					opErr := tr.op(store)
+
+					if kind == faultError {
+						assertStoreIsInEitherState(t, store, tr.before, tr.after, opErr)
+					}
+
+					reopenedStore := NewStore(dbPath, maxFileSizeKB)
+					err := reopenedStore.Load()
+					if err != nil {
+						t.Fatalf("reopening failed: %s", err)
+					}
+					assertStoreIsInEitherState(t, reopenedStore, tr.before, tr.after, opErr)
+
+					// the files should still be usable after recovery
+					err = reopenedStore.Set("later", "value")
+					assert.Nil(t, err)
+					err = reopenedStore.Vacuum()
+					assert.Nil(t, err)
+
+					reopenedStore = NewStore(dbPath, maxFileSizeKB)
+					err = reopenedStore.Load()
+					assert.Nil(t, err)
+					value, err := reopenedStore.Get("later")
+					assert.Nil(t, err)
+					assert.Equal(t, "value", value)
+
+					tempFiles, err := ReadFilesWithExtension(dbPath, TempFileExt)
+					assert.Nil(t, err)
+					assert.Empty(t, tempFiles)
+				})
+			}
+		}
+	}
+}
+
+// assertStoreIsInEitherState asserts that the key-values in the store are exactly those
+// in before or those in after. If opErr is nil, they must be those in after
+func assertStoreIsInEitherState(t *testing.T, store *Store, before map[string]string, after map[string]string, opErr error) {
+	t.Helper()
+
+	got := map[string]string{}
+	for _, key := range store.Keys() {
+		value, err := store.Get(key)
+		if err != nil {
+			t.Errorf("getting %s failed: %s", key, err)
+			continue
+		}
+		got[key] = value
+	}
+
+	if opErr == nil {
+		assert.Equal(t, after, got)
+		return
+	}
+
+	isBefore := assert.ObjectsAreEqual(before, got)
+	isAfter := assert.ObjectsAreEqual(after, got)
+	assert.True(t, isBefore || isAfter, "store is neither in the state before nor after the failed operation: %v", got)
+}
+
+func TestFileSystem(t *testing.T) {
+	dbPath, err := filepath.Abs("testFileSystemDb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = ClearDummyFileDataInDb(dbPath) }()
+
+	t.Run("LoadShouldTruncateTornRecordsInIndexAndDelFiles", func(t *testing.T) {
+		err := ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = AddDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		indexFilePath := filepath.Join(dbPath, IndexFilename)
+		delFilePath := filepath.Join(dbPath, DelFilename)
+		index, err := ReadFileToString(indexFilePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = osFileSystem{}.AppendFile(indexFilePath, []byte("torn><?&(^#1655404"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = osFileSystem{}.AppendFile(delFilePath, []byte("16554"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		store := NewStore(dbPath, 320.0/1024)
+		err = store.Load()
+		assert.Nil(t, err)
+
+		_, err = store.Get("torn")
+		assert.Equal(t, ErrNotFound, err)
+		indexAfterLoad, err := ReadFileToString(indexFilePath)
+		assert.Nil(t, err)
+		assert.Equal(t, index, indexAfterLoad)
+		delAfterLoad, err := ReadFileToString(delFilePath)
+		assert.Nil(t, err)
+		assert.Equal(t, "", delAfterLoad)
+	})
+
+	t.Run("LoadShouldRollAllButTheNewestLogFile", func(t *testing.T) {
+		err := ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = AddDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		newerLogFile := "1655375171402015000"
+		err = os.WriteFile(filepath.Join(dbPath, newerLogFile+"."+LogFileExt), nil, 0666)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		store := NewStore(dbPath, 320.0/1024)
+		err = store.Load()
+		assert.Nil(t, err)
+
+		logFiles, err := ReadFilesWithExtension(dbPath, LogFileExt)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{""}, logFiles)
+		assert.Equal(t, newerLogFile, store.currentLogFile)
+		assert.Contains(t, store.dataFiles, "1655375171402014000")
+	})
+
+	t.Run("FileErrorsShouldNameTheFileTheyAreAbout", func(t *testing.T) {
+		err := ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		fs := &faultyFileSystem{}
+		store := NewStore(dbPath, 320.0/1024, WithFileSystem(fs))
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		fs.arm(1, faultError)
+		err = store.Set("horse", "11 months")
+		assert.ErrorIs(t, err, errInjected)
+		assert.Contains(t, err.Error(), store.currentLogFile+"."+LogFileExt+": "+errInjected.Error())
+	})
+}
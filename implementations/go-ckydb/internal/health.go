@@ -0,0 +1,121 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+const (
+	// probeInterval is the least time between checks of whether a read-only store can write again
+	probeInterval = time.Second
+	probeFilename = "probe." + TempFileExt
+)
+
+// Health is the state of the store's ability to write to disk
+type Health struct {
+	// IsReadOnly is true if the store stopped writing after a disk error e.g. the disk being full
+	IsReadOnly bool
+	// Err is the disk error that made the store read-only
+	Err error
+	// Since is when the store became read-only
+	Since time.Time
+}
+
+// Health returns the current health of the store
+func (s *Store) Health() Health {
+	s.healthLock.Lock()
+	defer s.healthLock.Unlock()
+
+	return s.health
+}
+
+// guardWrite runs fn, a write to disk, unless the store is read-only, in which case it returns
+// an ErrReadOnly error, or a follower, in which case it returns an ErrFollower error. A disk error returned by fn makes the store read-only so that later writes
+// do not act on files the failed write may have left incomplete. Once the disk can be written to
+// again, the store reloads itself from disk and accepts writes again
+func (s *Store) guardWrite(fn func() error) error {
+	if s.isFollower {
+		return ErrFollower
+	}
+
+	err := s.ensureWritable()
+	if err != nil {
+		return err
+	}
+
+	err = fn()
+	if isDiskError(err) {
+		s.setReadOnly(err)
+	}
+
+	return err
+}
+
+// ensureWritable returns an ErrReadOnly error if the store is read-only and the disk still
+// cannot be written to. If it can, the store is reloaded from disk, repairing any records
+// left incomplete by the failed write
+func (s *Store) ensureWritable() error {
+	s.healthLock.Lock()
+	health := s.health
+	isDueForProbe := health.IsReadOnly && s.clock.Now().Sub(s.lastProbe) >= probeInterval
+	if isDueForProbe {
+		s.lastProbe = s.clock.Now()
+	}
+	s.healthLock.Unlock()
+
+	if !health.IsReadOnly {
+		return nil
+	}
+
+	if !isDueForProbe || s.probeDisk() != nil {
+		return fmt.Errorf("%w: %w", ErrReadOnly, health.Err)
+	}
+
+	s.healthLock.Lock()
+	s.health = Health{}
+	s.healthLock.Unlock()
+
+	err := s.Load()
+	if err != nil {
+		s.setReadOnly(err)
+		return fmt.Errorf("%w: %w", ErrReadOnly, err)
+	}
+
+	return nil
+}
+
+// setReadOnly makes the store read-only because of the given error, if it is not already
+func (s *Store) setReadOnly(err error) {
+	s.healthLock.Lock()
+	defer s.healthLock.Unlock()
+
+	if s.health.IsReadOnly {
+		return
+	}
+
+	now := s.clock.Now()
+	s.health = Health{IsReadOnly: true, Err: err, Since: now}
+	s.lastProbe = now
+}
+
+// probeDisk checks whether the disk has room for at least a log file, by writing and removing a file that big
+func (s *Store) probeDisk() error {
+	path := filepath.Join(s.dbPath, probeFilename)
+	err := s.fs.WriteFile(path, make([]byte, max(int(s.maxFileSizeKB*1024), 4096)))
+	if err != nil {
+		_ = s.fs.Remove(path)
+		return err
+	}
+
+	return s.fs.Remove(path)
+}
+
+// isDiskError checks whether err was caused by the disk being full or failing,
+// rather than by what was being written
+func isDiskError(err error) bool {
+	return errors.Is(err, syscall.ENOSPC) || errors.Is(err, syscall.EDQUOT) ||
+		errors.Is(err, syscall.EIO) || errors.Is(err, syscall.EROFS)
+}
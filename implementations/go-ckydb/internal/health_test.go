@@ -0,0 +1,144 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fullFileSystem is a FileSystem whose writes fail with ENOSPC while it is full.
+// A write that fails midway leaves the first half of its data behind
+type fullFileSystem struct {
+	osFileSystem
+	isFull atomic.Bool
+}
+
+func (f *fullFileSystem) WriteFile(path string, data []byte) error {
+	if f.isFull.Load() {
+		_ = f.osFileSystem.WriteFile(path, data[:len(data)/2])
+		return &os.PathError{Op: "write", Path: path, Err: syscall.ENOSPC}
+	}
+
+	return f.osFileSystem.WriteFile(path, data)
+}
+
+func (f *fullFileSystem) AppendFile(path string, data []byte) (int, error) {
+	if f.isFull.Load() {
+		n, _ := f.osFileSystem.AppendFile(path, data[:len(data)/2])
+		return n, &os.PathError{Op: "write", Path: path, Err: syscall.ENOSPC}
+	}
+
+	return f.osFileSystem.AppendFile(path, data)
+}
+
+func TestHealth(t *testing.T) {
+	dbPath, err := filepath.Abs("testHealthDb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2022, 6, 16, 10, 0, 0, 0, time.UTC)
+	defer func() { _ = ClearDummyFileDataInDb(dbPath) }()
+
+	// newStore returns a loaded store, with a few keys, on a disk that can be filled up
+	newStore := func(t *testing.T) (*Store, *fullFileSystem, *FakeClock) {
+		err := ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		fs := &fullFileSystem{}
+		clock := NewFakeClock(start)
+		store := NewStore(dbPath, 1, WithFileSystem(fs), WithClock(clock))
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, k := range []string{"cow", "goat"} {
+			err = store.Set(k, k+" value")
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		return store, fs, clock
+	}
+
+	t.Run("DiskErrorsShouldMakeTheStoreReadOnly", func(t *testing.T) {
+		store, fs, clock := newStore(t)
+		assert.Equal(t, Health{}, store.Health())
+
+		fs.isFull.Store(true)
+		err := store.Set("hen", strings.Repeat("v", 100))
+		assert.ErrorIs(t, err, syscall.ENOSPC)
+
+		health := store.Health()
+		assert.True(t, health.IsReadOnly)
+		assert.ErrorIs(t, health.Err, syscall.ENOSPC)
+		assert.Equal(t, start, health.Since)
+
+		// writes fail fast, even once the disk has room, till it is probed again
+		fs.isFull.Store(false)
+		assert.ErrorIs(t, store.Set("pig", "70 months"), ErrReadOnly)
+		assert.ErrorIs(t, store.Delete("cow"), ErrReadOnly)
+		assert.ErrorIs(t, store.Vacuum(), ErrReadOnly)
+		assert.ErrorIs(t, store.Clear(), ErrReadOnly)
+
+		// reads keep working
+		value, err := store.Get("cow")
+		assert.Nil(t, err)
+		assert.Equal(t, "cow value", value)
+
+		fs.isFull.Store(true)
+		clock.Advance(probeInterval)
+		assert.ErrorIs(t, store.Set("pig", "70 months"), ErrReadOnly)
+		assert.True(t, store.Health().IsReadOnly)
+	})
+
+	t.Run("StoreShouldRecoverOnceTheDiskHasRoom", func(t *testing.T) {
+		store, fs, clock := newStore(t)
+
+		fs.isFull.Store(true)
+		err := store.Set("hen", strings.Repeat("v", 100))
+		assert.ErrorIs(t, err, syscall.ENOSPC)
+
+		fs.isFull.Store(false)
+		clock.Advance(probeInterval)
+		err = store.Set("pig", "70 months")
+		assert.Nil(t, err)
+		assert.Equal(t, Health{}, store.Health())
+
+		_, err = os.Stat(filepath.Join(dbPath, probeFilename))
+		assert.True(t, os.IsNotExist(err))
+
+		// the store is reloaded from disk, without the torn record of the failed write
+		reloaded := NewStore(dbPath, 1)
+		err = reloaded.Load()
+		assert.Nil(t, err)
+		for k, v := range map[string]string{"cow": "cow value", "goat": "goat value", "pig": "70 months"} {
+			value, err := store.Get(k)
+			assert.Nil(t, err)
+			assert.Equal(t, v, value)
+
+			value, err = reloaded.Get(k)
+			assert.Nil(t, err)
+			assert.Equal(t, v, value)
+		}
+		assert.False(t, store.Has("hen"))
+		assert.False(t, reloaded.Has("hen"))
+	})
+
+	t.Run("OtherErrorsShouldNotMakeTheStoreReadOnly", func(t *testing.T) {
+		store, _, _ := newStore(t)
+
+		assert.ErrorIs(t, store.Delete("hen"), ErrNotFound)
+		assert.ErrorIs(t, store.Set("hen", TokenSeparator), ErrInvalidKeyValue)
+		assert.False(t, store.Health().IsReadOnly)
+	})
+}
@@ -0,0 +1,238 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const HistoryFilename = "history.hst"
+
+// The kinds of the records of the history file
+const (
+	historySet    = "s"
+	historyDelete = "d"
+)
+
+// HistoryPolicy bounds the previous versions of keys retained in history mode.
+// The current version of a key is always retained
+type HistoryPolicy struct {
+	// MaxVersions is the maximum number of versions, including the current one, retained per key.
+	// Zero means no limit
+	MaxVersions int
+	// MaxAge is how long a version is retained once it has been replaced. Zero means no limit
+	MaxAge time.Duration
+}
+
+// Version is a version of the value of a key, as retained in history mode
+type Version struct {
+	Value string
+	// Time is when the version was set, or deleted if IsDeleted
+	Time time.Time
+	// IsDeleted is whether the key was deleted at Time rather than set
+	IsDeleted bool
+}
+
+// WithHistory enables history mode, in which every set and delete of a key is appended to the history
+// file, so that the previous versions of keys can be got. The versions beyond the policy are dropped
+// in memory right away, and from the file on the next Vacuum
+func WithHistory(policy HistoryPolicy) StoreOption {
+	return func(s *Store) {
+		s.historyPolicy = &policy
+	}
+}
+
+// GetVersion retrieves the value that the given key had at the given time.
+// It returns an ErrNotFound error if the key did not exist then, or its version has been dropped,
+// and an ErrHistoryDisabled error if history mode is not enabled
+func (s *Store) GetVersion(key string, at time.Time) (string, error) {
+	key = s.foldKey(key)
+	if s.historyPolicy == nil {
+		return "", ErrHistoryDisabled
+	}
+
+	versions := s.history[key]
+	i := sort.Search(len(versions), func(i int) bool { return versions[i].Time.After(at) })
+	if i == 0 || versions[i-1].IsDeleted {
+		return "", ErrNotFound
+	}
+
+	return versions[i-1].Value, nil
+}
+
+// History returns at most limit of the retained versions of the given key, newest first,
+// including its deletions. A limit of zero or less returns all of them.
+// It returns an ErrHistoryDisabled error if history mode is not enabled
+func (s *Store) History(key string, limit int) ([]Version, error) {
+	key = s.foldKey(key)
+	if s.historyPolicy == nil {
+		return nil, ErrHistoryDisabled
+	}
+
+	versions := s.history[key]
+	if limit <= 0 || limit > len(versions) {
+		limit = len(versions)
+	}
+
+	result := make([]Version, limit)
+	for i := range result {
+		result[i] = versions[len(versions)-1-i]
+	}
+
+	return result, nil
+}
+
+// recordVersion appends the new version of the key to the history file, if history mode is enabled
+func (s *Store) recordVersion(key string, value string, isDeleted bool, st *OpStats) error {
+	if s.historyPolicy == nil || s.history == nil {
+		return nil
+	}
+
+	version := Version{Value: value, Time: s.clock.Now(), IsDeleted: isDeleted}
+	n, err := s.appendFile(s.historyFilePath, []byte(s.encodeVersion(key, version)))
+	if err != nil {
+		return err
+	}
+	st.recordWrite(s.historyFilePath, n)
+
+	s.history[key] = s.prunedVersions(append(s.history[key], version))
+	if len(s.history[key]) == 0 {
+		delete(s.history, key)
+	}
+	return nil
+}
+
+// loadHistoryFromDisk loads the versions of keys from the history file, if history mode is enabled.
+// The file is only created once a key is set or deleted
+func (s *Store) loadHistoryFromDisk() error {
+	if s.historyPolicy == nil {
+		return nil
+	}
+
+	s.history = map[string][]Version{}
+	err := s.repairTornRecord(s.historyFilePath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(s.historyFilePath)
+	if err != nil {
+		return err
+	}
+
+	records, err := s.extractLoadedRecords(s.historyFilePath, data, 2, func(fields []string) bool {
+		_, _, err := decodeVersion(fields[0], fields[1])
+		return err == nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		key, version, err := decodeVersion(record[0], record[1])
+		if err != nil {
+			return err
+		}
+
+		s.history[key] = append(s.history[key], version)
+	}
+
+	for key, versions := range s.history {
+		s.history[key] = s.prunedVersions(versions)
+		if len(s.history[key]) == 0 {
+			delete(s.history, key)
+		}
+	}
+
+	return nil
+}
+
+// saveHistory replaces the history file with the versions retained in memory, dropping
+// those beyond the policy
+func (s *Store) saveHistory(st *OpStats) error {
+	if s.history == nil {
+		return nil
+	}
+
+	keys := make([]string, 0, len(s.history))
+	for key, versions := range s.history {
+		s.history[key] = s.prunedVersions(versions)
+		if len(s.history[key]) == 0 {
+			delete(s.history, key)
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var content strings.Builder
+	for _, key := range keys {
+		for _, version := range s.history[key] {
+			content.WriteString(s.encodeVersion(key, version))
+		}
+	}
+
+	err := s.writeFile(s.historyFilePath, []byte(content.String()))
+	if err != nil {
+		return err
+	}
+	st.recordFileRewrite(s.historyFilePath)
+
+	return nil
+}
+
+// prunedVersions returns the versions, oldest first, without those beyond the history policy.
+// A deletion that is the only version left is dropped too, as there is nothing before it to get
+func (s *Store) prunedVersions(versions []Version) []Version {
+	if s.historyPolicy.MaxVersions > 0 && len(versions) > s.historyPolicy.MaxVersions {
+		versions = versions[len(versions)-s.historyPolicy.MaxVersions:]
+	}
+
+	if s.historyPolicy.MaxAge > 0 {
+		// a version is needed until its successor is older than the max age
+		cutoff := s.clock.Now().Add(-s.historyPolicy.MaxAge)
+		start := 0
+		for start < len(versions)-1 && versions[start+1].Time.Before(cutoff) {
+			start++
+		}
+		versions = versions[start:]
+	}
+
+	if len(versions) == 1 && versions[0].IsDeleted {
+		return nil
+	}
+
+	return versions
+}
+
+// encodeVersion encodes the version of the key as a record of the history file
+// i.e. "<key><KeyValue><unixnano>-<kind>-<value><Token>" in the separated format
+func (s *Store) encodeVersion(key string, version Version) string {
+	kind := historySet
+	if version.IsDeleted {
+		kind = historyDelete
+	}
+
+	return s.separators.encodeRecord(key, fmt.Sprintf("%d-%s-%s", version.Time.UnixNano(), kind, version.Value))
+}
+
+// decodeVersion decodes the key and value of a record of the history file into the key and its version
+func decodeVersion(key string, value string) (string, Version, error) {
+	parts := strings.SplitN(value, "-", 3)
+	if len(parts) != 3 || (parts[1] != historySet && parts[1] != historyDelete) {
+		return "", Version{}, ErrCorruptedData
+	}
+
+	nanos, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return "", Version{}, ErrCorruptedData
+	}
+
+	return key, Version{Value: parts[2], Time: time.Unix(0, nanos), IsDeleted: parts[1] == historyDelete}, nil
+}
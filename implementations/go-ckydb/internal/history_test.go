@@ -0,0 +1,192 @@
+package internal
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistory(t *testing.T) {
+	dbPath, err := filepath.Abs("testHistoryDb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2022, 6, 16, 10, 0, 0, 0, time.UTC)
+	defer func() { _ = ClearDummyFileDataInDb(dbPath) }()
+
+	// newStore returns a loaded store in history mode on an empty database folder whose time is controlled by the clock
+	newStore := func(t *testing.T, policy HistoryPolicy) (*Store, *FakeClock) {
+		err := ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		clock := NewFakeClock(start)
+		store := NewStore(dbPath, 320.0/1024, WithClock(clock), WithHistory(policy))
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return store, clock
+	}
+
+	// setEveryMinute sets the key to each of the values, a minute apart
+	setEveryMinute := func(t *testing.T, store *Store, clock *FakeClock, key string, values ...string) {
+		for _, value := range values {
+			err := store.Set(key, value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			clock.Advance(time.Minute)
+		}
+	}
+
+	t.Run("GetVersionShouldGetTheValueAtTheGivenTime", func(t *testing.T) {
+		store, clock := newStore(t, HistoryPolicy{})
+		setEveryMinute(t, store, clock, "cow", "1 month", "2 months", "3 months")
+		err := store.Delete("cow")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = store.GetVersion("cow", start.Add(-time.Second))
+		assert.ErrorIs(t, err, ErrNotFound)
+		value, err := store.GetVersion("cow", start)
+		assert.Nil(t, err)
+		assert.Equal(t, "1 month", value)
+		value, err = store.GetVersion("cow", start.Add(90*time.Second))
+		assert.Nil(t, err)
+		assert.Equal(t, "2 months", value)
+		value, err = store.GetVersion("cow", start.Add(179*time.Second))
+		assert.Nil(t, err)
+		assert.Equal(t, "3 months", value)
+		_, err = store.GetVersion("cow", start.Add(3*time.Minute))
+		assert.ErrorIs(t, err, ErrNotFound)
+		_, err = store.GetVersion("goat", start)
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("HistoryShouldReturnTheVersionsNewestFirst", func(t *testing.T) {
+		store, clock := newStore(t, HistoryPolicy{})
+		setEveryMinute(t, store, clock, "cow", "1 month", "2 months")
+		err := store.Delete("cow")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		versions, err := store.History("cow", 0)
+		assert.Nil(t, err)
+		assert.Equal(t, []Version{
+			{Time: start.Add(2 * time.Minute), IsDeleted: true},
+			{Value: "2 months", Time: start.Add(time.Minute)},
+			{Value: "1 month", Time: start},
+		}, versions)
+
+		versions, err = store.History("cow", 2)
+		assert.Nil(t, err)
+		assert.Len(t, versions, 2)
+		assert.True(t, versions[0].IsDeleted)
+
+		versions, err = store.History("goat", 0)
+		assert.Nil(t, err)
+		assert.Empty(t, versions)
+	})
+
+	t.Run("HistoryShouldSurviveReloads", func(t *testing.T) {
+		store, clock := newStore(t, HistoryPolicy{})
+		setEveryMinute(t, store, clock, "cow", "1 month", "2 months")
+
+		reloaded := NewStore(dbPath, 320.0/1024, WithClock(clock), WithHistory(HistoryPolicy{}))
+		err := reloaded.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		value, err := reloaded.GetVersion("cow", start)
+		assert.Nil(t, err)
+		assert.Equal(t, "1 month", value)
+		versions, err := reloaded.History("cow", 0)
+		assert.Nil(t, err)
+		assert.Len(t, versions, 2)
+	})
+
+	t.Run("MaxVersionsShouldBoundTheVersionsOfEachKey", func(t *testing.T) {
+		store, clock := newStore(t, HistoryPolicy{MaxVersions: 2})
+		setEveryMinute(t, store, clock, "cow", "1 month", "2 months", "3 months")
+
+		versions, err := store.History("cow", 0)
+		assert.Nil(t, err)
+		assert.Equal(t, []Version{
+			{Value: "3 months", Time: start.Add(2 * time.Minute)},
+			{Value: "2 months", Time: start.Add(time.Minute)},
+		}, versions)
+		_, err = store.GetVersion("cow", start)
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		err = store.Vacuum()
+		if err != nil {
+			t.Fatal(err)
+		}
+		reloaded := NewStore(dbPath, 320.0/1024, WithClock(clock), WithHistory(HistoryPolicy{}))
+		err = reloaded.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		versions, err = reloaded.History("cow", 0)
+		assert.Nil(t, err)
+		assert.Len(t, versions, 2)
+	})
+
+	t.Run("MaxAgeShouldDropVersionsReplacedLongerAgo", func(t *testing.T) {
+		store, clock := newStore(t, HistoryPolicy{MaxAge: 90 * time.Second})
+		setEveryMinute(t, store, clock, "cow", "1 month", "2 months", "3 months")
+		setEveryMinute(t, store, clock, "goat", "1 month")
+		err := store.Delete("goat")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		clock.Advance(time.Hour)
+		err = store.Vacuum()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		versions, err := store.History("cow", 0)
+		assert.Nil(t, err)
+		assert.Equal(t, []Version{{Value: "3 months", Time: start.Add(2 * time.Minute)}}, versions)
+		versions, err = store.History("goat", 0)
+		assert.Nil(t, err)
+		assert.Empty(t, versions)
+	})
+
+	t.Run("ClearShouldDropTheHistory", func(t *testing.T) {
+		store, clock := newStore(t, HistoryPolicy{})
+		setEveryMinute(t, store, clock, "cow", "1 month")
+
+		err := store.Clear()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		versions, err := store.History("cow", 0)
+		assert.Nil(t, err)
+		assert.Empty(t, versions)
+	})
+
+	t.Run("GetVersionAndHistoryShouldFailWithoutHistoryMode", func(t *testing.T) {
+		store := NewStore(dbPath, 320.0/1024)
+		err := store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = store.GetVersion("cow", start)
+		assert.ErrorIs(t, err, ErrHistoryDisabled)
+		_, err = store.History("cow", 0)
+		assert.ErrorIs(t, err, ErrHistoryDisabled)
+	})
+}
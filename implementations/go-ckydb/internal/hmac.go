@@ -0,0 +1,154 @@
+package internal
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"path/filepath"
+	"sort"
+)
+
+// recordMACLength is the length of the hex-encoded HMAC-SHA256 in front of every value of a database with record HMACs
+const recordMACLength = 2 * sha256.Size
+
+// hmacKeyCheckMessage is the message whose HMAC is recorded in the metadata file to tell whether a key is the right one
+const hmacKeyCheckMessage = "ckydb"
+
+// TamperedRecord is a record in a log or data file that failed its HMAC check, or a data file that failed its
+// SHA-256 check against the manifest
+type TamperedRecord struct {
+	// Key is the key of the record, as found in the file, or "" for a data file that failed its SHA-256 check
+	Key string
+	// File is the name of the log or data file holding the record
+	File string
+}
+
+// WithRecordHMAC authenticates every record in the log and data files with an HMAC-SHA256 of the given key, so that
+// Gets of records changed outside of the store fail with an ErrTamperedRecord error, and Verify reports them. It only
+// applies to databases created with it, which record a check of the key in their metadata file; loading them
+// without the same key fails with an ErrInvalidHMACKey error. The contents of blobs are not covered
+func WithRecordHMAC(key []byte) StoreOption {
+	return func(s *Store) {
+		s.hmacKey = key
+	}
+}
+
+// Verify checks the HMAC of every record in the log and data files, returning the records that fail it,
+// ordered by file and key, and, with WithManifest, the data files that fail their SHA-256 check, with no Key,
+// ahead of them. It returns an ErrHMACDisabled error if the database has neither record HMACs nor a manifest
+func (s *Store) Verify() ([]TamperedRecord, error) {
+	if !s.recordMACs && !s.isManifestEnabled {
+		return nil, ErrHMACDisabled
+	}
+
+	tampered := []TamperedRecord{}
+	if s.isManifestEnabled {
+		damaged, err := verifyDataFiles(s.dbPath, s.separators)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, filename := range damaged {
+			tampered = append(tampered, TamperedRecord{File: filename})
+		}
+	}
+
+	if !s.recordMACs {
+		return tampered, nil
+	}
+
+	return s.verifyRecordMACs(tampered)
+}
+
+// verifyRecordMACs appends the records of the log and data files that fail their HMAC check to tampered
+func (s *Store) verifyRecordMACs(tampered []TamperedRecord) ([]TamperedRecord, error) {
+	filesInFolder, err := getLogAndDataFilenames(s.dbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, filename := range filesInFolder {
+		data, err := s.separators.readKeyValuesFromFile(filepath.Join(s.dbPath, filename))
+		if err != nil {
+			return nil, err
+		}
+
+		var keys []string
+		for timestampedKey, sealed := range data {
+			if _, err := unsealRecord(timestampedKey, sealed, s.hmacKey); err != nil {
+				keys = append(keys, extractKeyFromTimestampedKey(timestampedKey))
+			}
+		}
+
+		sort.Strings(keys)
+		for _, key := range keys {
+			tampered = append(tampered, TamperedRecord{Key: key, File: filename})
+		}
+	}
+
+	return tampered, nil
+}
+
+// sealRecord puts the HMAC of the record of the timestamped key in front of its stored value,
+// if the database has record HMACs
+func (s *Store) sealRecord(timestampedKey string, stored string) string {
+	if !s.recordMACs {
+		return stored
+	}
+
+	return computeRecordMAC(s.hmacKey, timestampedKey, stored) + stored
+}
+
+// unsealRecord checks the HMAC in front of the sealed value of the record of the timestamped key, returning the value
+// without it. A nil key means the database has no record HMACs, so the value is returned as is
+func unsealRecord(timestampedKey string, sealed string, key []byte) (string, error) {
+	if key == nil {
+		return sealed, nil
+	}
+
+	if len(sealed) < recordMACLength {
+		return "", ErrTamperedRecord
+	}
+
+	mac, stored := sealed[:recordMACLength], sealed[recordMACLength:]
+	if !hmac.Equal([]byte(mac), []byte(computeRecordMAC(key, timestampedKey, stored))) {
+		return "", ErrTamperedRecord
+	}
+
+	return stored, nil
+}
+
+// recordMACKey returns the key that the records of the database are authenticated with, or nil if it has no record HMACs
+func (s *Store) recordMACKey() []byte {
+	if !s.recordMACs {
+		return nil
+	}
+
+	return s.hmacKey
+}
+
+// computeRecordMAC returns the hex-encoded HMAC of the record of the timestamped key. The timestamped key is
+// included so that values cannot be swapped between records
+func computeRecordMAC(key []byte, timestampedKey string, stored string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(timestampedKey))
+	mac.Write([]byte{0})
+	mac.Write([]byte(stored))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// computeHMACKeyCheck returns the check of the key recorded in the metadata file
+func computeHMACKeyCheck(key []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(hmacKeyCheckMessage))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// checkHMACKey checks that the key is the one whose check is recorded in the metadata file
+func checkHMACKey(key []byte, check string) error {
+	if len(key) == 0 || !hmac.Equal([]byte(check), []byte(computeHMACKeyCheck(key))) {
+		return ErrInvalidHMACKey
+	}
+
+	return nil
+}
@@ -0,0 +1,139 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordHMAC(t *testing.T) {
+	dbPath, err := filepath.Abs("testRecordHMACDb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := []byte("a secret key")
+	defer func() { _ = ClearDummyFileDataInDb(dbPath) }()
+
+	// newStore returns a loaded store with record HMACs on an empty database folder, holding cow and goat
+	newStore := func(t *testing.T, opts ...StoreOption) *Store {
+		err := ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		store := NewStore(dbPath, 4, append([]StoreOption{WithRecordHMAC(key)}, opts...)...)
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for k, v := range map[string]string{"cow": "500 months", "goat": "678 months"} {
+			err = store.Set(k, v)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		return store
+	}
+
+	// tamperWithLogFile replaces old with new in the current log file of the store and reloads it
+	tamperWithLogFile := func(t *testing.T, store *Store, old string, new string) *Store {
+		data, err := os.ReadFile(store.currentLogFilePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = os.WriteFile(store.currentLogFilePath, []byte(strings.Replace(string(data), old, new, 1)), 0666)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		reloaded := NewStore(dbPath, 4, WithRecordHMAC(key))
+		err = reloaded.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return reloaded
+	}
+
+	t.Run("GetShouldVerifyTheHMACOfTheRecord", func(t *testing.T) {
+		store := newStore(t)
+		value, err := store.Get("cow")
+		assert.Nil(t, err)
+		assert.Equal(t, "500 months", value)
+
+		tampered, err := store.Verify()
+		assert.Nil(t, err)
+		assert.Empty(t, tampered)
+
+		store = tamperWithLogFile(t, store, "500 months", "999 months")
+		_, err = store.Get("cow")
+		assert.ErrorIs(t, err, ErrTamperedRecord)
+		value, err = store.Get("goat")
+		assert.Nil(t, err)
+		assert.Equal(t, "678 months", value)
+	})
+
+	t.Run("VerifyShouldReportTamperedRecords", func(t *testing.T) {
+		store := newStore(t)
+		store = tamperWithLogFile(t, store, "678 months", "1 month")
+
+		tampered, err := store.Verify()
+		assert.Nil(t, err)
+		assert.Equal(t, []TamperedRecord{{Key: "goat", File: filepath.Base(store.currentLogFilePath)}}, tampered)
+	})
+
+	t.Run("HMACsShouldCoverFlaggedValues", func(t *testing.T) {
+		store := newStore(t, WithValueCompressionThreshold(64))
+		blob := strings.Repeat("a value that compresses well ", 100)
+		assert.Nil(t, store.Set("blob", blob))
+
+		value, err := store.Get("blob")
+		assert.Nil(t, err)
+		assert.Equal(t, blob, value)
+
+		snap, err := store.Snapshot()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = snap.Close() }()
+		values := map[string]string{}
+		err = snap.ForEach(func(key string, value string) error {
+			values[key] = value
+			return nil
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]string{"blob": blob, "cow": "500 months", "goat": "678 months"}, values)
+	})
+
+	t.Run("LoadShouldFailWithAMissingOrWrongKey", func(t *testing.T) {
+		newStore(t)
+
+		for _, wrongKey := range [][]byte{nil, []byte("another key")} {
+			store := NewStore(dbPath, 4, WithRecordHMAC(wrongKey))
+			err := store.Load()
+			assert.ErrorIs(t, err, ErrInvalidHMACKey)
+		}
+	})
+
+	t.Run("VerifyShouldFailWithoutRecordHMACs", func(t *testing.T) {
+		err := ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		store := NewStore(dbPath, 4)
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = store.Verify()
+		assert.ErrorIs(t, err, ErrHMACDisabled)
+	})
+}
@@ -0,0 +1,131 @@
+package internal
+
+import (
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// WithIndexBatching makes the store buffer the index entries of new keys and append them to the index file
+// in batches: once maxKeys are buffered, on the first new key after maxDelay has passed since the oldest was
+// buffered, if maxDelay is not zero, and on Flush. Load adds to the index the keys whose entries were lost in a crash,
+// from the log file and the latest data file, where they are bound to be as the buffer is flushed before the log
+// file is rolled
+func WithIndexBatching(maxKeys int, maxDelay time.Duration) StoreOption {
+	return func(s *Store) {
+		s.indexBatchSize = maxKeys
+		s.indexBatchDelay = maxDelay
+	}
+}
+
+// Flush appends the index entries buffered by index batching to the index file
+func (s *Store) Flush() error {
+	return s.FlushWithStats(nil)
+}
+
+// FlushWithStats is like Flush but it also records what it did in st
+func (s *Store) FlushWithStats(st *OpStats) error {
+	if len(s.pendingIndex) == 0 {
+		return nil
+	}
+
+	return s.guardWrite(func() error { return s.flushIndex(st) })
+}
+
+// bufferIndexEntry buffers the index entry of a new key, flushing the buffer if it is full or has been held
+// for longer than the batch delay. If the flush fails, the entry is taken out of the buffer again
+func (s *Store) bufferIndexEntry(record string, st *OpStats) error {
+	if len(s.pendingIndex) == 0 {
+		s.pendingIndexSince = s.clock.Now()
+	}
+
+	size := len(s.pendingIndex)
+	s.pendingIndex = append(s.pendingIndex, record...)
+	s.pendingIndexKeys++
+	if s.pendingIndexKeys < s.indexBatchSize && (s.indexBatchDelay <= 0 || s.clock.Now().Sub(s.pendingIndexSince) < s.indexBatchDelay) {
+		return nil
+	}
+
+	err := s.flushIndex(st)
+	if err != nil {
+		s.pendingIndex = s.pendingIndex[:size]
+		s.pendingIndexKeys--
+	}
+
+	return err
+}
+
+// flushIndex appends the buffered index entries, if any, to the index file. It is called before the index file
+// is rewritten, copied or the log file rolled, so that the buffer only ever holds keys missing from the index file
+func (s *Store) flushIndex(st *OpStats) error {
+	if len(s.pendingIndex) == 0 {
+		return nil
+	}
+
+	n, err := s.appendFile(s.indexFilePath, s.pendingIndex)
+	if err != nil {
+		return err
+	}
+	st.recordWrite(s.indexFilePath, n)
+
+	s.pendingIndex, s.pendingIndexKeys = nil, 0
+	return nil
+}
+
+// recoverIndex adds to the index, and the index file, the keys in the log file and the latest data file
+// that are missing from the index, as are the keys whose index entries were still buffered in a crash
+func (s *Store) recoverIndex() error {
+	if s.indexBatchSize <= 0 {
+		return nil
+	}
+
+	var timestampedKeys []string
+	for timestampedKey := range s.memtable {
+		timestampedKeys = append(timestampedKeys, timestampedKey)
+	}
+
+	if len(s.dataFiles) > 0 {
+		dataFilePath := s.getDataFilePath(s.dataFiles[len(s.dataFiles)-1])
+		data, err := os.ReadFile(dataFilePath)
+		if err != nil {
+			return err
+		}
+
+		dataAsMap, err := s.extractLoadedKeyValues(dataFilePath, data, isTimestampedKeyValue)
+		if err != nil {
+			return err
+		}
+
+		for timestampedKey := range dataAsMap {
+			timestampedKeys = append(timestampedKeys, timestampedKey)
+		}
+	}
+
+	// key-values marked for deletion are still on disk if Load did not vacuum
+	keysToDelete, err := s.getKeysToDelete()
+	if err != nil {
+		return err
+	}
+	isDeleted := make(map[string]bool, len(keysToDelete))
+	for _, timestampedKey := range keysToDelete {
+		isDeleted[timestampedKey] = true
+	}
+
+	sort.Strings(timestampedKeys)
+	var records strings.Builder
+	for _, timestampedKey := range timestampedKeys {
+		key := extractKeyFromTimestampedKey(timestampedKey)
+		if _, ok := s.index[key]; !ok && !isDeleted[timestampedKey] {
+			records.WriteString(s.separators.encodeIndexRecord(key, timestampedKey))
+			s.index[indexKeyOf(key, timestampedKey)] = timestampedKey
+		}
+	}
+
+	if records.Len() == 0 {
+		return nil
+	}
+
+	_, err = s.appendFile(s.indexFilePath, []byte(records.String()))
+	return err
+}
@@ -0,0 +1,169 @@
+package internal
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIndexBatching(t *testing.T) {
+	dbPath, err := filepath.Abs("testIndexBatchingDb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2022, 6, 16, 10, 0, 0, 0, time.UTC)
+	indexFilePath := filepath.Join(dbPath, IndexFilename)
+	defer func() { _ = ClearDummyFileDataInDb(dbPath) }()
+
+	// newStore returns a loaded store on an empty database folder, batching index entries as configured
+	newStore := func(t *testing.T, maxKeys int, maxDelay time.Duration, opts ...StoreOption) (*Store, *FakeClock) {
+		err := ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		clock := NewFakeClock(start)
+		opts = append([]StoreOption{WithClock(clock), WithIndexBatching(maxKeys, maxDelay)}, opts...)
+		store := NewStore(dbPath, 320.0/1024, opts...)
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return store, clock
+	}
+
+	// indexedKeys returns the keys in the index file
+	indexedKeys := func(t *testing.T) []string {
+		data, err := DefaultSeparators.readAppendOnlyFile(indexFilePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var keys []string
+		for key := range data {
+			keys = append(keys, key)
+		}
+		return keys
+	}
+
+	t.Run("SetShouldAppendToTheIndexFileOnceTheBatchIsFull", func(t *testing.T) {
+		store, _ := newStore(t, 3, 0)
+		for _, key := range []string{"cow", "dog"} {
+			err := store.Set(key, "500 months")
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		assert.Empty(t, indexedKeys(t))
+		value, err := store.Get("dog")
+		assert.Nil(t, err)
+		assert.Equal(t, "500 months", value)
+
+		err = store.Set("goat", "678 months")
+		assert.Nil(t, err)
+		assert.ElementsMatch(t, []string{"cow", "dog", "goat"}, indexedKeys(t))
+
+		err = store.Set("cow", "501 months")
+		assert.Nil(t, err)
+		err = store.Set("hen", "567 months")
+		assert.Nil(t, err)
+		assert.Len(t, indexedKeys(t), 3)
+		err = store.Flush()
+		assert.Nil(t, err)
+		assert.ElementsMatch(t, []string{"cow", "dog", "goat", "hen"}, indexedKeys(t))
+	})
+
+	t.Run("SetShouldAppendToTheIndexFileOnceTheMaxDelayHasPassed", func(t *testing.T) {
+		store, clock := newStore(t, 100, time.Second)
+		err := store.Set("cow", "500 months")
+		if err != nil {
+			t.Fatal(err)
+		}
+		clock.Advance(time.Second / 2)
+		err = store.Set("dog", "23 months")
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Empty(t, indexedKeys(t))
+
+		clock.Advance(time.Second / 2)
+		err = store.Set("goat", "678 months")
+		assert.Nil(t, err)
+		assert.ElementsMatch(t, []string{"cow", "dog", "goat"}, indexedKeys(t))
+	})
+
+	t.Run("LoadShouldRecoverTheKeysWhoseIndexEntriesWereLost", func(t *testing.T) {
+		store, _ := newStore(t, 1000, 0)
+		expected := map[string]string{}
+		for i := 0; i < 20; i++ {
+			key, value := fmt.Sprintf("key-%d", i), fmt.Sprintf("value %d", i)
+			err := store.Set(key, value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected[key] = value
+		}
+		err := store.Delete("key-3")
+		if err != nil {
+			t.Fatal(err)
+		}
+		delete(expected, "key-3")
+		err = store.Set("key-20", "value 20")
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected["key-20"] = "value 20"
+		assert.True(t, store.Stats().DataFiles > 1)
+
+		reloaded := NewStore(dbPath, 320.0/1024, WithIndexBatching(1000, 0))
+		err = reloaded.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Len(t, reloaded.Keys(), len(expected))
+		for k, v := range expected {
+			value, err := reloaded.Get(k)
+			assert.Nil(t, err, k)
+			assert.Equal(t, v, value, k)
+		}
+		assert.ElementsMatch(t, reloaded.Keys(), indexedKeys(t))
+	})
+
+	t.Run("LoadShouldRecoverTheKeysSetBeforeACrash", func(t *testing.T) {
+		for failAt := 1; ; failAt++ {
+			fs := &faultyFileSystem{}
+			store, _ := newStore(t, 4, 0, WithFileSystem(fs))
+			fs.arm(failAt, faultCrash)
+
+			expected := map[string]string{}
+			var err error
+			for i := 0; i < 10 && err == nil; i++ {
+				key, value := fmt.Sprintf("key-%d", i), fmt.Sprintf("value %d", i)
+				err = store.Set(key, value)
+				if err == nil {
+					expected[key] = value
+				}
+			}
+			if err == nil {
+				break
+			}
+
+			reloaded := NewStore(dbPath, 320.0/1024, WithIndexBatching(4, 0))
+			err = reloaded.Load()
+			if err != nil {
+				t.Fatal(failAt, err)
+			}
+			for k, v := range expected {
+				value, err := reloaded.Get(k)
+				assert.Nil(t, err, failAt, k)
+				assert.Equal(t, v, value, failAt, k)
+			}
+		}
+	})
+}
@@ -0,0 +1,106 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// compactIndexFormatVersion is the format version from which each record of the index file holds the key and
+// its timestamp only, instead of repeating the key in its timestamped key, which is derived from the two on load
+const compactIndexFormatVersion = 3
+
+// encodeIndexRecord encodes the record of the index file mapping the key to its timestamped key
+func (sep Separators) encodeIndexRecord(key string, timestampedKey string) string {
+	return sep.encodeRecord(key, sep.indexValue(timestampedKey))
+}
+
+// indexValue returns what the index file holds for the timestamped key: the timestamped key itself,
+// or only its timestamp in the compact index format
+func (sep Separators) indexValue(timestampedKey string) string {
+	if !sep.compactIndex {
+		return timestampedKey
+	}
+
+	timestamp, _, _ := strings.Cut(timestampedKey, "-")
+	return timestamp
+}
+
+// indexEntry returns the timestamped key of the key given the value of its record in the index file,
+// and whether the record is valid
+func (sep Separators) indexEntry(key string, value string) (string, bool) {
+	if !sep.compactIndex {
+		return value, isTimestampedKey(value)
+	}
+
+	return value + "-" + key, value != "" && isDigits([]byte(value))
+}
+
+// expandIndex converts the key-value records of the index file into the index of keys to timestamped keys.
+// In the compact index format, it returns an ErrCorruptedData error if a value is not a timestamp
+func (sep Separators) expandIndex(records map[string]string) (map[string]string, error) {
+	if !sep.compactIndex {
+		return records, nil
+	}
+
+	for key, value := range records {
+		timestampedKey, ok := sep.indexEntry(key, value)
+		if !ok {
+			return nil, ErrCorruptedData
+		}
+
+		records[key] = timestampedKey
+	}
+
+	return records, nil
+}
+
+// isValidIndexRecord checks whether the record of the index file maps the key to a timestamped key
+func (sep Separators) isValidIndexRecord(key string, value string) bool {
+	_, ok := sep.indexEntry(key, value)
+	return ok
+}
+
+// migrateToCompactIndex converts the index file of a database folder of version 2 to the compact index of version 3
+func migrateToCompactIndex(s *Store) error {
+	return s.convertIndexFormat(lengthPrefixedFormatVersion, compactIndexFormatVersion)
+}
+
+// migrateToFullIndex converts the compact index file of a database folder of version 3 back to that of version 2
+func migrateToFullIndex(s *Store) error {
+	return s.convertIndexFormat(compactIndexFormatVersion, lengthPrefixedFormatVersion)
+}
+
+// convertIndexFormat rewrites the index file of the database folder from the index format of one version to that
+// of the other, resuming the conversion recorded in the migration file if there is one
+func (s *Store) convertIndexFormat(from int, to int) error {
+	meta, err := readMetadata(s.dbPath)
+	if err != nil {
+		return err
+	}
+
+	src, dst := meta.separators, meta.separators
+	src.compactIndex = from >= compactIndexFormatVersion
+	dst.compactIndex = to >= compactIndexFormatVersion
+
+	m, err := readFormatMigration(s.dbPath)
+	if os.IsNotExist(err) {
+		m = &formatMigration{path: filepath.Join(s.dbPath, FormatMigrationFilename), from: from, to: to, valueFlags: meta.valueFlags, done: map[string]bool{}}
+		err = s.replaceFile(m.path, m.encode())
+	}
+	if err != nil {
+		return err
+	}
+
+	defer func(sep Separators) { s.separators = sep }(s.separators)
+	s.separators = dst
+
+	return s.convertRecordFile(m, src, recordFile{path: IndexFilename, fields: 2}, func(fields []string) ([]string, error) {
+		timestampedKey, ok := src.indexEntry(fields[0], fields[1])
+		if !ok {
+			return nil, ErrCorruptedData
+		}
+
+		return []string{fields[0], dst.indexValue(timestampedKey)}, nil
+	})
+}
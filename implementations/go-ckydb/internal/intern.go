@@ -0,0 +1,55 @@
+package internal
+
+import "strings"
+
+// indexKeyOf returns the key for the index entry of the timestamped key as a substring of the timestamped key, so
+// that the entry holds one string instead of two copies of the key. It returns key as it is if the timestamped key
+// does not end with it
+func indexKeyOf(key string, timestampedKey string) string {
+	start := len(timestampedKey) - len(key)
+	if start <= 0 || timestampedKey[start:] != key {
+		return key
+	}
+
+	return timestampedKey[start:]
+}
+
+// internTimestampedKeys copies the timestamped keys of the index, once it is loaded, into a single string, each
+// key of the index being a substring of its timestamped key, and makes those of the memtable share the strings of
+// the memtable. The contents of the index file, which the strings read from it were substrings of, or the keys
+// built one by one from a compact index file, can then be freed, so that the bytes of each key are held once
+// rather than duplicated. The index holds as many strings as before. Assigning an equal key to a map replaces the
+// string it holds, which is what drops the copies. The single string stays in memory until all of its keys are
+// deleted or the store is loaded again
+func (s *Store) internTimestampedKeys() {
+	keys := make([]string, 0, len(s.index))
+	timestampedKeys := make([]string, 0, len(s.index))
+	size := 0
+	for key, timestampedKey := range s.index {
+		if _, ok := s.memtable[timestampedKey]; !ok {
+			keys = append(keys, key)
+			timestampedKeys = append(timestampedKeys, timestampedKey)
+			size += len(timestampedKey)
+		}
+	}
+
+	var arena strings.Builder
+	arena.Grow(size)
+	for _, timestampedKey := range timestampedKeys {
+		arena.WriteString(timestampedKey)
+	}
+
+	interned := arena.String()
+	for i, key := range keys {
+		timestampedKey := interned[:len(timestampedKeys[i])]
+		interned = interned[len(timestampedKey):]
+		s.index[indexKeyOf(key, timestampedKey)] = timestampedKey
+	}
+
+	for timestampedKey := range s.memtable {
+		key := extractKeyFromTimestampedKey(timestampedKey)
+		if s.index[key] == timestampedKey {
+			s.index[indexKeyOf(key, timestampedKey)] = timestampedKey
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package internal
+
+import (
+	"path/filepath"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInternTimestampedKeys(t *testing.T) {
+	dbPath, err := filepath.Abs("testInternDb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = ClearDummyFileDataInDb(dbPath) }()
+
+	// sharesBytes checks whether sub is a substring of s in memory, not only in contents
+	sharesBytes := func(s string, sub string) bool {
+		start := uintptr(unsafe.Pointer(unsafe.StringData(s)))
+		subStart := uintptr(unsafe.Pointer(unsafe.StringData(sub)))
+		return subStart >= start && subStart+uintptr(len(sub)) <= start+uintptr(len(s))
+	}
+
+	for _, compactIndex := range []bool{false, true} {
+		name := map[bool]string{false: "IndexFile", true: "CompactIndexFile"}[compactIndex]
+
+		t.Run("LoadShouldShareTheStringsOfTheTimestampedKeysOf"+name, func(t *testing.T) {
+			err := ClearDummyFileDataInDb(dbPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = AddDummyFileDataInDb(dbPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if compactIndex {
+				err = MigrateFormatTo(dbPath, compactIndexFormatVersion)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			store := NewStore(dbPath, 4)
+			err = store.Load()
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = store.Set("new", "key")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Len(t, store.index, 7)
+			for key, timestampedKey := range store.index {
+				assert.True(t, sharesBytes(timestampedKey, key), key)
+			}
+
+			inMemtable := 0
+			for timestampedKey := range store.memtable {
+				if indexed := store.index[extractKeyFromTimestampedKey(timestampedKey)]; indexed == timestampedKey {
+					assert.True(t, sharesBytes(timestampedKey, indexed), timestampedKey)
+					inMemtable++
+				}
+			}
+			assert.Equal(t, 5, inMemtable)
+		})
+	}
+}
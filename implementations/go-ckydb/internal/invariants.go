@@ -0,0 +1,97 @@
+package internal
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+)
+
+// WithInvariantChecks makes the store check, on every Get and Set, that the key is routed to the segment
+// i.e. the memtable or the cached data file, whose range it falls in, and that every Set can be read back.
+// A violation fails the operation with an ErrInvariantViolated error. It is meant for tests, as the checks
+// slow down every operation
+func WithInvariantChecks(isEnabled bool) StoreOption {
+	return func(s *Store) {
+		s.checkInvariants = isEnabled
+	}
+}
+
+// checkSegment checks that the timestamped key falls in the range of the given cache, or in that
+// of the log file if cache is nil. The file whose range a key falls in is the latest one whose name
+// is not after the key
+func (s *Store) checkSegment(timestampedKey string, cache *Cache) error {
+	if !s.checkInvariants {
+		return nil
+	}
+
+	boundaries := append(append([]string{}, s.dataFiles...), s.currentLogFile)
+	for i := 1; i < len(boundaries); i++ {
+		if boundaries[i-1] >= boundaries[i] {
+			return fmt.Errorf("%w: file %s is not before file %s", ErrInvariantViolated, boundaries[i-1], boundaries[i])
+		}
+	}
+
+	i := len(boundaries) - 1
+	for i >= 0 && boundaries[i] > timestampedKey {
+		i--
+	}
+	if i < 0 {
+		return fmt.Errorf("%w: key %s is before every file", ErrInvariantViolated, timestampedKey)
+	}
+
+	isInLogFile := i == len(boundaries)-1
+	if cache == nil {
+		if !isInLogFile {
+			return fmt.Errorf("%w: key %s of file %s is routed to the log file %s",
+				ErrInvariantViolated, timestampedKey, boundaries[i], s.currentLogFile)
+		}
+
+		return nil
+	}
+
+	if isInLogFile || cache.start != boundaries[i] || cache.end != boundaries[i+1] {
+		return fmt.Errorf("%w: key %s of file %s is routed to the cache of %s to %s",
+			ErrInvariantViolated, timestampedKey, boundaries[i], cache.start, cache.end)
+	}
+
+	return nil
+}
+
+// checkReadBack checks that the value of the timestamped key just set is the given value
+func (s *Store) checkReadBack(timestampedKey string, value string) error {
+	if !s.checkInvariants {
+		return nil
+	}
+
+	got, err := s.getValueForKey(timestampedKey, nil)
+	if err != nil {
+		return fmt.Errorf("%w: set of %s is not read back: %s", ErrInvariantViolated, timestampedKey, err)
+	}
+
+	if got != value {
+		return fmt.Errorf("%w: set of %s is read back as %q", ErrInvariantViolated, timestampedKey, got)
+	}
+
+	return nil
+}
+
+// checkStreamedReadBack checks that the value of the timestamped key just set from a stream is the given number
+// of bytes, with the given SHA-256 digest
+func (s *Store) checkStreamedReadBack(timestampedKey string, size int64, digest []byte) error {
+	if !s.checkInvariants {
+		return nil
+	}
+
+	got, err := s.getValueForKey(timestampedKey, nil)
+	if err != nil {
+		return fmt.Errorf("%w: set of %s is not read back: %s", ErrInvariantViolated, timestampedKey, err)
+	}
+
+	gotDigest := sha256.Sum256([]byte(got))
+	if int64(len(got)) != size || !bytes.Equal(gotDigest[:], digest) {
+		return fmt.Errorf("%w: set of %s is read back as %d bytes that differ from the %d streamed",
+			ErrInvariantViolated, timestampedKey, len(got), size)
+	}
+
+	return nil
+}
@@ -0,0 +1,114 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInvariants(t *testing.T) {
+	dbPath, err := filepath.Abs("testInvariantsDb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = ClearDummyFileDataInDb(dbPath) }()
+
+	// newStore returns a loaded store that checks its invariants, rolling its log file every few keys
+	newStore := func(t *testing.T) *Store {
+		err := ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		store := NewStore(dbPath, 0.1, WithInvariantChecks(true))
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for i := 0; i < 100; i++ {
+			err = store.Set(fmt.Sprintf("key-%d", i), fmt.Sprintf("value-%d", i))
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		return store
+	}
+
+	t.Run("GetsAndSetsShouldStayInTheirSegmentsAcrossLogRolls", func(t *testing.T) {
+		store := newStore(t)
+		assert.Less(t, 10, store.Stats().DataFiles)
+
+		for i := 0; i < 100; i += 2 {
+			err := store.Set(fmt.Sprintf("key-%d", i), fmt.Sprintf("new value-%d", i))
+			assert.Nil(t, err)
+		}
+
+		for i := 0; i < 100; i++ {
+			expected := fmt.Sprintf("value-%d", i)
+			if i%2 == 0 {
+				expected = "new " + expected
+			}
+
+			value, err := store.Get(fmt.Sprintf("key-%d", i))
+			assert.Nil(t, err)
+			assert.Equal(t, expected, value)
+		}
+	})
+
+	t.Run("KeysRoutedToTheWrongSegmentShouldFailLoudly", func(t *testing.T) {
+		store := newStore(t)
+		_, err := store.Get("key-0")
+		assert.Nil(t, err)
+
+		// a stale cache whose range runs over the next data files
+		store.cache.end = store.currentLogFile
+		_, err = store.Get("key-50")
+		assert.ErrorIs(t, err, ErrInvariantViolated)
+		err = store.Set("key-50", "value")
+		assert.ErrorIs(t, err, ErrInvariantViolated)
+	})
+
+	t.Run("KeysNamedAfterTheNextFileShouldBeFoundInThePreviousOne", func(t *testing.T) {
+		err := ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.MkdirAll(dbPath, 0777)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// as left behind by an implementation whose clock did not move between the last key of a log file
+		// and the name of the next log file
+		files := map[string]map[string]string{
+			"1655375120328185000.cky": {"1655375120328186000-cow": "500 months"},
+			"1655375120328186000.cky": {"1655375120328186000-dog": "23 months", "1655375120328187000-goat": "678 months"},
+			"1655375120328187000.log": {},
+			IndexFilename:             {"cow": "1655375120328186000-cow", "dog": "1655375120328186000-dog", "goat": "1655375120328187000-goat"},
+			DelFilename:               {},
+		}
+		for filename, data := range files {
+			err = os.WriteFile(filepath.Join(dbPath, filename), []byte(DefaultSeparators.encodeMapData(data)), 0666)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		store := NewStore(dbPath, 320.0/1024, WithInvariantChecks(true))
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for k, v := range map[string]string{"cow": "500 months", "dog": "23 months", "goat": "678 months"} {
+			value, err := store.Get(k)
+			assert.Nil(t, err)
+			assert.Equal(t, v, value)
+		}
+	})
+}
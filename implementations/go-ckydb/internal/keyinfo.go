@@ -0,0 +1,68 @@
+package internal
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// KeyInfo is the metadata of a key in the store
+type KeyInfo struct {
+	// CreatedAt is when the key was first set. Updating the key does not change it
+	CreatedAt time.Time
+	// File is the name of the log file or data file that currently holds the record of the key
+	File string
+	// Size is the size of the value in bytes
+	Size int
+	// OriginalKey is the key with the casing it was last set with, which differs from the key
+	// only if the keys of the store are case-insensitive
+	OriginalKey string
+}
+
+// Describe returns the metadata of the given key. It returns an ErrNotFound error if the key is nonexistent
+func (s *Store) Describe(key string) (KeyInfo, error) {
+	return s.DescribeWithStats(key, nil)
+}
+
+// DescribeWithStats is like Describe but it also records what it did in st. Getting the size
+// of the value of a key in a data file loads that file into the cache, as Get would
+func (s *Store) DescribeWithStats(key string, st *OpStats) (KeyInfo, error) {
+	key = s.foldKey(key)
+	timestampedKey, ok := s.lookup(key)
+	if !ok {
+		return KeyInfo{}, ErrNotFound
+	}
+
+	timestamp, _, _ := strings.Cut(timestampedKey, "-")
+	nanoseconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return KeyInfo{}, ErrCorruptedData
+	}
+
+	file, err := s.getFileHoldingKey(timestampedKey)
+	if err != nil {
+		return KeyInfo{}, err
+	}
+
+	value, err := s.getValueForKey(timestampedKey, st)
+	if err != nil {
+		return KeyInfo{}, err
+	}
+
+	return KeyInfo{CreatedAt: time.Unix(0, nanoseconds), File: file, Size: len(value), OriginalKey: s.originalKey(key)}, nil
+}
+
+// getFileHoldingKey returns the name of the log file or data file whose range the timestamped key falls in
+func (s *Store) getFileHoldingKey(timestampedKey string) (string, error) {
+	if timestampedKey >= s.currentLogFile {
+		return fmt.Sprintf("%s.%s", s.currentLogFile, LogFileExt), nil
+	}
+
+	timestampRange := s.getTimestampRangeForKey(timestampedKey)
+	if timestampRange == nil {
+		return "", ErrCorruptedData
+	}
+
+	return fmt.Sprintf("%s.%s", timestampRange.Start, DataFileExt), nil
+}
@@ -0,0 +1,73 @@
+package internal
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyInfo(t *testing.T) {
+	dbPath, err := filepath.Abs("testKeyInfoDb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = ClearDummyFileDataInDb(dbPath) }()
+
+	t.Run("DescribeShouldReturnTheCreationTimeFileAndSizeOfTheKey", func(t *testing.T) {
+		err := AddDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		store := NewStore(dbPath, 320.0/1024)
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := map[string]KeyInfo{
+			"cow":  {CreatedAt: time.Unix(0, 1655375120328185000), File: "1655375120328185000.cky", Size: len("500 months"), OriginalKey: "cow"},
+			"dog":  {CreatedAt: time.Unix(0, 1655375120328185100), File: "1655375120328185000.cky", Size: len("23 months"), OriginalKey: "dog"},
+			"goat": {CreatedAt: time.Unix(0, 1655404770518678), File: "1655375171402014000.log", Size: len("678 months"), OriginalKey: "goat"},
+		}
+		for k, v := range expected {
+			info, err := store.Describe(k)
+			assert.Nil(t, err)
+			assert.Equal(t, v, info)
+		}
+
+		_, err = store.Describe("bar")
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("DescribeShouldKeepTheCreationTimeOfUpdatedKeys", func(t *testing.T) {
+		err := ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		start := time.Date(2022, 6, 16, 10, 0, 0, 0, time.UTC)
+		clock := NewFakeClock(start)
+		store := NewStore(dbPath, 320.0/1024, WithClock(clock))
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		clock.Advance(time.Minute)
+		err = store.Set("cow", "500 months")
+		if err != nil {
+			t.Fatal(err)
+		}
+		clock.Advance(time.Hour)
+		err = store.Set("cow", "501 months")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		info, err := store.Describe("cow")
+		assert.Nil(t, err)
+		assert.True(t, start.Add(time.Minute).Equal(info.CreatedAt))
+		assert.Equal(t, len("501 months"), info.Size)
+	})
+}
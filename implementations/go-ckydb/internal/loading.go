@@ -0,0 +1,69 @@
+package internal
+
+// setKeysLoaded notes whether Load has loaded the index, the memtable and the expiries, which the reads served
+// while the store is loaded need
+func (s *Store) setKeysLoaded(areLoaded bool) {
+	s.loadLock.Lock()
+	defer s.loadLock.Unlock()
+
+	s.areKeysLoaded = areLoaded
+}
+
+// GetWhileLoadingWithStats is GetWithStats for a store being loaded e.g. in the background, served from what Load
+// has loaded so far, recording what it did in st. It neither fills the cache nor records the use of the key,
+// as Load has yet to create them. It returns an ErrLoading error until the keys of the store are loaded
+func (s *Store) GetWhileLoadingWithStats(key string, st *OpStats) (string, error) {
+	s.loadLock.RLock()
+	defer s.loadLock.RUnlock()
+
+	if !s.areKeysLoaded {
+		return "", ErrLoading
+	}
+
+	timestampedKey, ok := s.lookup(s.foldKey(key))
+	if !ok {
+		return "", ErrNotFound
+	}
+
+	sealed, err := s.getLoadedValueForKey(timestampedKey, st)
+	if err != nil {
+		return "", err
+	}
+
+	return s.openValue(timestampedKey, sealed)
+}
+
+// getLoadedValueForKey gets the sealed value of the timestamped key from the memtable, or from its data file
+// on disk, bypassing the cache
+func (s *Store) getLoadedValueForKey(timestampedKey string, st *OpStats) (string, error) {
+	if timestampedKey >= s.currentLogFile {
+		if value, ok := s.memtable[timestampedKey]; ok {
+			return value, nil
+		}
+
+		return s.getValueBeforeBoundary(timestampedKey, s.currentLogFile, st)
+	}
+
+	cache, err := s.readCacheContainingKeyOnce(timestampedKey, st)
+	if err != nil {
+		return "", err
+	}
+
+	if value, ok := cache.data[timestampedKey]; ok {
+		return value, nil
+	}
+
+	return s.getValueBeforeBoundary(timestampedKey, cache.start, st)
+}
+
+// KeysWhileLoading is Keys for a store being loaded, returning an ErrLoading error until its keys are loaded
+func (s *Store) KeysWhileLoading() ([]string, error) {
+	s.loadLock.RLock()
+	defer s.loadLock.RUnlock()
+
+	if !s.areKeysLoaded {
+		return nil, ErrLoading
+	}
+
+	return s.Keys(), nil
+}
@@ -0,0 +1,53 @@
+package internal
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetWhileLoading(t *testing.T) {
+	dbPath, err := filepath.Abs("testGetWhileLoadingDb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = ClearDummyFileDataInDb(dbPath) }()
+
+	t.Run("GetWhileLoadingShouldFailUntilTheKeysAreLoaded", func(t *testing.T) {
+		err := ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = AddDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		store := NewStore(dbPath, 4)
+		_, err = store.GetWhileLoadingWithStats("cow", nil)
+		assert.ErrorIs(t, err, ErrLoading)
+		_, err = store.KeysWhileLoading()
+		assert.ErrorIs(t, err, ErrLoading)
+
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := map[string]string{"cow": "500 months", "dog": "23 months", "fish": "8990 months", "goat": "678 months"}
+		for key, value := range expected {
+			got, err := store.GetWhileLoadingWithStats(key, nil)
+			assert.Nil(t, err)
+			assert.Equal(t, value, got)
+		}
+		_, err = store.GetWhileLoadingWithStats("unicorn", nil)
+		assert.ErrorIs(t, err, ErrNotFound)
+		keys, err := store.KeysWhileLoading()
+		assert.Nil(t, err)
+		assert.Equal(t, store.Keys(), keys)
+
+		// the cache is left to the gets of the loaded store
+		assert.Empty(t, store.cache.data)
+	})
+}
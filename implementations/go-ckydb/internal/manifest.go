@@ -0,0 +1,218 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// ManifestFilename is the name of the file of WithManifest, mapping each data file to the SHA-256 of its contents
+const ManifestFilename = "manifest.sha"
+
+// WithManifest keeps the SHA-256 of every data file in the manifest file, updated whenever a data file is written,
+// so that Verify, VerifyDataFiles and BackupToDir detect the data files damaged on disk by bit rot or cut short by
+// a partial copy. Load hashes the data files missing from the manifest, so it can be enabled on any database,
+// and removes the manifest of a store without it, as it would go stale
+func WithManifest(isEnabled bool) StoreOption {
+	return func(s *Store) {
+		s.isManifestEnabled = isEnabled
+	}
+}
+
+// hashData returns the hex-encoded SHA-256 of data
+func hashData(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// getDataFileOfPath returns the timestamp of the data file at path, and false if path is not that of a data file
+// of the store, in the database folder or in one of its buckets
+func (s *Store) getDataFileOfPath(path string) (string, bool) {
+	filename := filepath.Base(path)
+	if filepath.Ext(filename) != "."+DataFileExt {
+		return "", false
+	}
+
+	folder, dbPath := filepath.Dir(path), filepath.Clean(s.dbPath)
+	if folder != dbPath && (filepath.Dir(folder) != dbPath || !isBucket(filepath.Base(folder))) {
+		return "", false
+	}
+
+	return filename[:len(filename)-len(DataFileExt)-1], true
+}
+
+// recordDataFileHash records in the manifest the hash of data, written to the file at path, if it is a data file
+func (s *Store) recordDataFileHash(path string, data []byte) error {
+	dataFile, ok := s.getDataFileOfPath(path)
+	if !ok {
+		return nil
+	}
+
+	return s.updateManifest(func(manifest map[string]string) {
+		manifest[dataFile] = hashData(data)
+	})
+}
+
+// hashDataFile records in the manifest the hash of the data file of the given timestamp as it is on disk
+func (s *Store) hashDataFile(dataFile string) error {
+	if !s.isManifestEnabled {
+		return nil
+	}
+
+	data, err := os.ReadFile(s.getDataFilePath(dataFile))
+	if err != nil {
+		return err
+	}
+
+	return s.updateManifest(func(manifest map[string]string) {
+		manifest[dataFile] = hashData(data)
+	})
+}
+
+// removeDataFileHash removes the data file of the given timestamp from the manifest
+func (s *Store) removeDataFileHash(dataFile string) error {
+	return s.updateManifest(func(manifest map[string]string) {
+		delete(manifest, dataFile)
+	})
+}
+
+// updateManifest applies change to a copy of the manifest, which replaces it once it is saved to the manifest file.
+// It does nothing if the manifest is not enabled. Data files are rewritten in parallel, so it holds the manifestLock
+func (s *Store) updateManifest(change func(manifest map[string]string)) error {
+	if !s.isManifestEnabled {
+		return nil
+	}
+
+	s.manifestLock.Lock()
+	defer s.manifestLock.Unlock()
+
+	manifest := make(map[string]string, len(s.manifest)+1)
+	for dataFile, hash := range s.manifest {
+		manifest[dataFile] = hash
+	}
+	change(manifest)
+
+	err := s.replaceFile(filepath.Join(s.dbPath, ManifestFilename), []byte(s.separators.encodeMapData(manifest)))
+	if err != nil {
+		return err
+	}
+
+	s.manifest = manifest
+	return nil
+}
+
+// loadManifest reads the manifest file, before Load vacuums the store so that the data files it rewrites keep the
+// hashes of the others, or removes the manifest file if the manifest is not enabled
+func (s *Store) loadManifest() error {
+	path := filepath.Join(s.dbPath, ManifestFilename)
+	s.manifest = map[string]string{}
+	if !s.isManifestEnabled {
+		err := s.fs.Remove(path)
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		return err
+	}
+
+	manifest, err := s.separators.readKeyValuesFromFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	s.manifest = manifest
+	return nil
+}
+
+// completeManifest hashes the data files missing from the manifest, as in a database that was without it, and drops
+// the data files that no longer exist, once Load has found the data files
+func (s *Store) completeManifest() error {
+	if !s.isManifestEnabled {
+		return nil
+	}
+
+	manifest := make(map[string]string, len(s.dataFiles))
+	isUpToDate := len(s.manifest) == len(s.dataFiles)
+	for _, dataFile := range s.dataFiles {
+		hash, ok := s.manifest[dataFile]
+		if !ok {
+			data, err := os.ReadFile(s.getDataFilePath(dataFile))
+			if err != nil {
+				return err
+			}
+
+			hash, isUpToDate = hashData(data), false
+		}
+		manifest[dataFile] = hash
+	}
+
+	if isUpToDate {
+		return nil
+	}
+
+	return s.updateManifest(func(m map[string]string) {
+		clear(m)
+		for dataFile, hash := range manifest {
+			m[dataFile] = hash
+		}
+	})
+}
+
+// VerifyDataFiles checks the data files of the database folder at dbPath, in the folder itself or in its buckets,
+// against its manifest, returning the names of those whose SHA-256 differs from the one in the manifest or that are
+// missing, sorted. It does not need the database to be loaded, so it suits copies of a database e.g. backups.
+// It returns an ErrNoManifest error if the folder has no manifest file
+func VerifyDataFiles(dbPath string) ([]string, error) {
+	meta, err := readMetadata(dbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return verifyDataFiles(dbPath, meta.separators)
+}
+
+// verifyDataFiles is VerifyDataFiles for a database folder whose separators are known
+func verifyDataFiles(dbPath string, sep Separators) ([]string, error) {
+	manifest, err := sep.readKeyValuesFromFile(filepath.Join(dbPath, ManifestFilename))
+	if os.IsNotExist(err) {
+		return nil, ErrNoManifest
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	paths, err := getLogAndDataFilenames(dbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	dataFileFolders := getDataFileFolders(paths)
+	damaged := []string{}
+	for dataFile, hash := range manifest {
+		filename := fmt.Sprintf("%s.%s", dataFile, DataFileExt)
+		folder, ok := dataFileFolders[dataFile]
+		if !ok {
+			damaged = append(damaged, filename)
+			continue
+		}
+
+		filename = filepath.Join(folder, filename)
+		data, err := os.ReadFile(filepath.Join(dbPath, filename))
+		if err != nil {
+			return nil, err
+		}
+
+		if hashData(data) != hash {
+			damaged = append(damaged, filename)
+		}
+	}
+
+	sort.Strings(damaged)
+	return damaged, nil
+}
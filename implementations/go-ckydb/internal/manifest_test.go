@@ -0,0 +1,151 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManifest(t *testing.T) {
+	dbPath, err := filepath.Abs("testManifestDb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	backupPath := dbPath + "Backup"
+	defer func() {
+		_ = ClearDummyFileDataInDb(dbPath)
+		_ = os.RemoveAll(backupPath)
+	}()
+
+	// newStore returns a store loaded with the given options on the dummy data
+	newStore := func(t *testing.T, opts ...StoreOption) *Store {
+		err := ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = AddDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		store := NewStore(dbPath, 4, opts...)
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return store
+	}
+
+	// readManifest returns the contents of the manifest file, checking that they match the data files on disk
+	readManifest := func(t *testing.T, store *Store) map[string]string {
+		manifest, err := store.separators.readKeyValuesFromFile(filepath.Join(dbPath, ManifestFilename))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, dataFile := range store.dataFiles {
+			data, err := os.ReadFile(store.getDataFilePath(dataFile))
+			assert.Nil(t, err)
+			assert.Equal(t, hashData(data), manifest[dataFile])
+		}
+
+		return manifest
+	}
+
+	t.Run("LoadShouldHashTheDataFiles", func(t *testing.T) {
+		store := newStore(t, WithManifest(true))
+
+		manifest := readManifest(t, store)
+		assert.Len(t, manifest, 2)
+		tampered, err := store.Verify()
+		assert.Nil(t, err)
+		assert.Empty(t, tampered)
+		damaged, err := VerifyDataFiles(dbPath)
+		assert.Nil(t, err)
+		assert.Empty(t, damaged)
+	})
+
+	t.Run("WritesShouldKeepTheManifestUpToDate", func(t *testing.T) {
+		store := newStore(t, WithManifest(true))
+
+		// a value bigger than the maximum file size rolls the log file into a data file
+		err := store.Set("big", strings.Repeat("x", 5*1024))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Len(t, readManifest(t, store), 3)
+
+		err = store.Delete("cow")
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = store.Vacuum()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Len(t, readManifest(t, store), 3)
+
+		err = store.Compact()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Len(t, readManifest(t, store), len(store.dataFiles))
+
+		damaged, err := VerifyDataFiles(dbPath)
+		assert.Nil(t, err)
+		assert.Empty(t, damaged)
+	})
+
+	t.Run("VerifyShouldReportDamagedAndMissingDataFiles", func(t *testing.T) {
+		store := newStore(t, WithManifest(true))
+
+		file, err := os.OpenFile(filepath.Join(dbPath, "1655375120328185000.cky"), os.O_APPEND|os.O_WRONLY, 0666)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = file.WriteString("rot")
+		_ = file.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.Remove(filepath.Join(dbPath, "1655375120328186000.cky"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		tampered, err := store.Verify()
+		assert.Nil(t, err)
+		assert.Equal(t, []TamperedRecord{{File: "1655375120328185000.cky"}, {File: "1655375120328186000.cky"}}, tampered)
+		damaged, err := VerifyDataFiles(dbPath)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"1655375120328185000.cky", "1655375120328186000.cky"}, damaged)
+
+		// backups of damaged data files are not made
+		_ = os.RemoveAll(backupPath)
+		err = store.BackupToDir(backupPath)
+		assert.ErrorIs(t, err, ErrCorruptedData)
+		_, err = os.Stat(backupPath)
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("LoadWithoutTheManifestShouldRemoveIt", func(t *testing.T) {
+		newStore(t, WithManifest(true))
+
+		store := NewStore(dbPath, 4)
+		err := store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = os.Stat(filepath.Join(dbPath, ManifestFilename))
+		assert.True(t, os.IsNotExist(err))
+		_, err = VerifyDataFiles(dbPath)
+		assert.ErrorIs(t, err, ErrNoManifest)
+		_, err = store.Verify()
+		assert.ErrorIs(t, err, ErrHMACDisabled)
+	})
+}
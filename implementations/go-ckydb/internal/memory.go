@@ -0,0 +1,19 @@
+package internal
+
+// ShedMemory frees the memory that the store can do without, for when the process nears its memory limit.
+// It empties the cache and the prefetched cache, which the next Gets read again from disk, and rolls the log file
+// into a data file, if it has any keys, so that the memtable is emptied. Followers only empty their caches, as
+// their log files are written by the database they follow
+func (s *Store) ShedMemory() error {
+	return s.ShedMemoryWithStats(nil)
+}
+
+// ShedMemoryWithStats is like ShedMemory but it also records what it did in st
+func (s *Store) ShedMemoryWithStats(st *OpStats) error {
+	s.resetCache()
+	if s.isFollower || len(s.memtable) == 0 {
+		return nil
+	}
+
+	return s.guardWrite(func() error { return s.rollLogFile(st) })
+}
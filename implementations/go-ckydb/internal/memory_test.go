@@ -0,0 +1,90 @@
+package internal
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShedMemory(t *testing.T) {
+	dbPath, err := filepath.Abs("testShedMemoryDb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = ClearDummyFileDataInDb(dbPath) }()
+
+	// newStore returns a store loaded with the given options on the dummy data
+	newStore := func(t *testing.T, opts ...StoreOption) *Store {
+		err := ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = AddDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		store := NewStore(dbPath, 4, opts...)
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return store
+	}
+
+	t.Run("ShedMemoryShouldEmptyTheCacheAndTheMemtable", func(t *testing.T) {
+		store := newStore(t)
+		_, err := store.Get("cow")
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.NotEmpty(t, store.cache.data)
+		assert.NotEmpty(t, store.memtable)
+		logFile := store.currentLogFile
+
+		st := &OpStats{}
+		err = store.ShedMemoryWithStats(st)
+		assert.Nil(t, err)
+
+		assert.Empty(t, store.cache.data)
+		assert.Empty(t, store.memtable)
+		assert.True(t, st.LogRoll)
+		assert.Equal(t, 3, store.Stats().DataFiles)
+		assert.Contains(t, store.dataFiles, logFile)
+		assert.NotEqual(t, logFile, store.currentLogFile)
+
+		expected := map[string]string{"cow": "500 months", "dog": "23 months", "fish": "8990 months", "goat": "678 months"}
+		for key, value := range expected {
+			got, err := store.Get(key)
+			assert.Nil(t, err)
+			assert.Equal(t, value, got)
+		}
+
+		// with the memtable empty, there is no log file to roll
+		store.resetCache()
+		err = store.ShedMemory()
+		assert.Nil(t, err)
+		assert.Equal(t, 3, store.Stats().DataFiles)
+	})
+
+	t.Run("ShedMemoryOfAFollowerShouldOnlyEmptyTheCache", func(t *testing.T) {
+		newStore(t)
+		store := NewStore(dbPath, 4, WithFollower(true))
+		err := store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = store.Get("cow")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = store.ShedMemory()
+		assert.Nil(t, err)
+		assert.Empty(t, store.cache.data)
+		assert.NotEmpty(t, store.memtable)
+		assert.Equal(t, 2, store.Stats().DataFiles)
+	})
+}
@@ -0,0 +1,94 @@
+package internal
+
+import (
+	"os"
+)
+
+const MetaSegmentFilename = "__meta__.seg"
+
+// SetMeta sets the given key of the metadata segment to the value. The segment is a small map kept in a file
+// of its own, apart from the keys of the store, for applications to record e.g. schema versions and migration
+// state. It is not seen by Keys or snapshots, and has no expiry, eviction, history or oplog.
+// It returns an ErrInvalidKeyValue error if the key or value contains any of the separators
+func (s *Store) SetMeta(key string, value string) error {
+	return s.guardWrite(func() error {
+		err := s.separators.validateKeyValue(key, value)
+		if err != nil {
+			return err
+		}
+
+		data := make(map[string]string, len(s.metaSegment)+1)
+		for k, v := range s.metaSegment {
+			data[k] = v
+		}
+		data[key] = value
+
+		return s.saveMetaSegment(data)
+	})
+}
+
+// GetMeta returns the value of the given key of the metadata segment.
+// It returns an ErrNotFound error if the key is nonexistent
+func (s *Store) GetMeta(key string) (string, error) {
+	value, ok := s.metaSegment[key]
+	if !ok {
+		return "", ErrNotFound
+	}
+
+	return value, nil
+}
+
+// DeleteMeta deletes the given key from the metadata segment.
+// It returns an ErrNotFound error if the key is nonexistent
+func (s *Store) DeleteMeta(key string) error {
+	return s.guardWrite(func() error {
+		if _, ok := s.metaSegment[key]; !ok {
+			return ErrNotFound
+		}
+
+		data := make(map[string]string, len(s.metaSegment))
+		for k, v := range s.metaSegment {
+			if k != key {
+				data[k] = v
+			}
+		}
+
+		return s.saveMetaSegment(data)
+	})
+}
+
+// saveMetaSegment replaces the metadata segment file with data, and then the segment in memory
+// so that a failure leaves both as they were
+func (s *Store) saveMetaSegment(data map[string]string) error {
+	err := s.persistMapDataToFile(data, s.metaSegmentFilePath)
+	if err != nil {
+		return err
+	}
+
+	s.metaSegment = data
+	return nil
+}
+
+// loadMetaSegmentFromDisk reads the metadata segment from its file, if it exists
+func (s *Store) loadMetaSegmentFromDisk() error {
+	data, err := s.readLoadedKeyValues(s.metaSegmentFilePath, nil)
+	if os.IsNotExist(err) {
+		data, err = map[string]string{}, nil
+	}
+	if err != nil {
+		return err
+	}
+
+	s.metaSegment = data
+	return nil
+}
+
+// readMetaSegment reads the metadata segment in the file at path, which is empty if the file does not exist
+func readMetaSegment(sep Separators, path string) (map[string]string, error) {
+	data, err := sep.readKeyValuesFromFile(path)
+	if os.IsNotExist(err) {
+		return map[string]string{}, nil
+	}
+
+	return data, err
+}
@@ -0,0 +1,128 @@
+package internal
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetaSegment(t *testing.T) {
+	dbPath, err := filepath.Abs("testMetaSegmentDb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = ClearDummyFileDataInDb(dbPath) }()
+
+	// newStore returns a loaded store on an empty database folder holding cow
+	newStore := func(t *testing.T, opts ...StoreOption) *Store {
+		err := ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		store := NewStore(dbPath, 4, opts...)
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = store.Set("cow", "500 months")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return store
+	}
+
+	t.Run("SetMetaShouldPersistAcrossLoads", func(t *testing.T) {
+		store := newStore(t)
+		assert.Nil(t, store.SetMeta("schema_version", "3"))
+		assert.Nil(t, store.SetMeta("migration", "users:done"))
+		assert.Nil(t, store.SetMeta("schema_version", "4"))
+
+		reloaded := NewStore(dbPath, 4)
+		err := reloaded.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		value, err := reloaded.GetMeta("schema_version")
+		assert.Nil(t, err)
+		assert.Equal(t, "4", value)
+		value, err = reloaded.GetMeta("migration")
+		assert.Nil(t, err)
+		assert.Equal(t, "users:done", value)
+	})
+
+	t.Run("MetaKeysShouldBeApartFromTheKeysOfTheStore", func(t *testing.T) {
+		store := newStore(t, WithMaxKeys(1, EvictLRU))
+		assert.Nil(t, store.SetMeta("cow", "meta"))
+		assert.Nil(t, store.SetMeta("goat", "meta"))
+
+		assert.Equal(t, []string{"cow"}, store.Keys())
+		value, err := store.Get("cow")
+		assert.Nil(t, err)
+		assert.Equal(t, "500 months", value)
+		_, err = store.Get("goat")
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		snap, err := store.Snapshot()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = snap.Close() }()
+		keys := []string{}
+		err = snap.ForEach(func(key string, value string) error {
+			keys = append(keys, key)
+			return nil
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"cow"}, keys)
+
+		assert.Nil(t, store.Delete("cow"))
+		value, err = store.GetMeta("cow")
+		assert.Nil(t, err)
+		assert.Equal(t, "meta", value)
+	})
+
+	t.Run("DeleteMetaShouldRemoveTheKey", func(t *testing.T) {
+		store := newStore(t)
+		assert.Nil(t, store.SetMeta("schema_version", "3"))
+		assert.Nil(t, store.DeleteMeta("schema_version"))
+
+		_, err := store.GetMeta("schema_version")
+		assert.ErrorIs(t, err, ErrNotFound)
+		assert.ErrorIs(t, store.DeleteMeta("schema_version"), ErrNotFound)
+	})
+
+	t.Run("SetMetaShouldRejectSeparators", func(t *testing.T) {
+		store := newStore(t)
+		assert.ErrorIs(t, store.SetMeta("schema"+TokenSeparator, "3"), ErrInvalidKeyValue)
+		_, err := store.GetMeta("schema" + TokenSeparator)
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("ClearShouldEmptyTheMetaSegment", func(t *testing.T) {
+		store := newStore(t)
+		assert.Nil(t, store.SetMeta("schema_version", "3"))
+		assert.Nil(t, store.Clear())
+
+		_, err := store.GetMeta("schema_version")
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("FollowersShouldSeeTheMetaSegment", func(t *testing.T) {
+		store := newStore(t)
+		assert.Nil(t, store.SetMeta("schema_version", "3"))
+
+		follower := NewStore(dbPath, 4, WithFollower(true))
+		err := follower.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		value, err := follower.GetMeta("schema_version")
+		assert.Nil(t, err)
+		assert.Equal(t, "3", value)
+		assert.ErrorIs(t, follower.SetMeta("schema_version", "4"), ErrFollower)
+	})
+}
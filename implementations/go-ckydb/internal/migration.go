@@ -0,0 +1,402 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const FormatMigrationFilename = "format.migration"
+
+// defaultMigrationCompressionThreshold is the size from which values are compressed when a database is
+// migrated to the length-prefixed format by a store without WithValueCompressionThreshold
+const defaultMigrationCompressionThreshold = 1024
+
+const (
+	migrationFrom       = "from"
+	migrationTo         = "to"
+	migrationValueFlags = "value_flags"
+	migrationDone       = "done"
+)
+
+// formatMigration is the state of a conversion between format versions, kept in the migration file
+// of the database folder so that an interrupted conversion can be resumed
+type formatMigration struct {
+	path string
+	from int
+	to   int
+	// valueFlags is true if the values in the log and data files were flagged before the conversion
+	valueFlags bool
+	// done are the paths, relative to the database folder, of the files that have been converted
+	done map[string]bool
+}
+
+// recordFile is a file of records converted by a migration
+type recordFile struct {
+	// path is relative to the database folder
+	path   string
+	fields int
+	// hasValues is true for the log and data files, whose values are flagged, compressed and sealed
+	hasValues bool
+}
+
+// migrateToLengthPrefixed converts a database folder of version 1 to the length-prefixed records of version 2,
+// compressing the values of at least the compression threshold on the way
+func migrateToLengthPrefixed(s *Store) error {
+	return s.convertRecordFormat(1, lengthPrefixedFormatVersion)
+}
+
+// migrateToSeparated converts a database folder of version 2 back to the separated records of version 1.
+// It returns an ErrInvalidKeyValue error, without converting anything, if any key or value contains
+// one of the separators
+func migrateToSeparated(s *Store) error {
+	return s.convertRecordFormat(lengthPrefixedFormatVersion, 1)
+}
+
+// convertRecordFormat rewrites every file of records of the database folder from the format of one version
+// to that of the other, resuming the conversion recorded in the migration file if there is one.
+// Each file is written to a temporary file which is renamed over it after it has been marked as done,
+// so that a resumed conversion neither skips a file nor converts it twice
+func (s *Store) convertRecordFormat(from int, to int) error {
+	meta, err := readMetadata(s.dbPath)
+	if err != nil {
+		return err
+	}
+
+	files, err := s.getRecordFiles()
+	if err != nil {
+		return err
+	}
+
+	src, dst := meta.separators, meta.separators
+	src.lengthPrefixed = from >= lengthPrefixedFormatVersion
+	dst.lengthPrefixed = to >= lengthPrefixedFormatVersion
+	upgradesValues := !src.lengthPrefixed && dst.lengthPrefixed
+
+	m, err := readFormatMigration(s.dbPath)
+	if os.IsNotExist(err) {
+		if !dst.lengthPrefixed {
+			err = s.checkSeparable(src, dst, files)
+			if err != nil {
+				return err
+			}
+		}
+
+		m = &formatMigration{path: filepath.Join(s.dbPath, FormatMigrationFilename), from: from, to: to, valueFlags: meta.valueFlags, done: map[string]bool{}}
+		err = s.replaceFile(m.path, m.encode())
+	}
+	if err != nil {
+		return err
+	}
+
+	if upgradesValues && meta.hmacKeyCheck != "" {
+		err = checkHMACKey(s.hmacKey, meta.hmacKeyCheck)
+		if err != nil {
+			return err
+		}
+	}
+
+	defer func(sep Separators, valueFlags bool, recordMACs bool, threshold int) {
+		s.separators, s.valueFlags, s.recordMACs, s.compressThreshold = sep, valueFlags, recordMACs, threshold
+	}(s.separators, s.valueFlags, s.recordMACs, s.compressThreshold)
+
+	s.separators, s.valueFlags, s.recordMACs = dst, true, meta.hmacKeyCheck != ""
+	if s.compressThreshold == 0 {
+		s.compressThreshold = defaultMigrationCompressionThreshold
+	}
+
+	for _, file := range files {
+		convert := func(fields []string) ([]string, error) { return fields, nil }
+		if file.hasValues && upgradesValues {
+			convert = func(fields []string) ([]string, error) {
+				value, err := s.upgradeStoredValue(fields[0], fields[1], m.valueFlags)
+				if err != nil {
+					return nil, err
+				}
+
+				return []string{fields[0], value}, nil
+			}
+		}
+
+		err = s.convertRecordFile(m, src, file, convert)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = os.RemoveAll(filepath.Join(s.dbPath, SnapshotsFolderName))
+	if err != nil {
+		return err
+	}
+
+	// the hashes of the manifest are of the old data files, so Load hashes them anew
+	err = s.fs.Remove(filepath.Join(s.dbPath, ManifestFilename))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	meta.valueFlags = m.valueFlags || upgradesValues
+	return s.replaceFile(filepath.Join(s.dbPath, MetadataFilename), meta.encode())
+}
+
+// convertRecordFile rewrites the file with the separators of the store, converting the fields of each record,
+// unless the migration has already done so. The checksum and backup of the file are removed, as they are
+// of the old contents, for Load to write them anew
+func (s *Store) convertRecordFile(m *formatMigration, src Separators, file recordFile, convert func(fields []string) ([]string, error)) error {
+	path := filepath.Join(s.dbPath, file.path)
+	tempFilePath := path + "." + TempFileExt
+	if !m.done[file.path] {
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		records, err := src.extractRecords(src.withoutTornRecord(data), file.fields)
+		if err != nil {
+			return err
+		}
+
+		var content strings.Builder
+		for _, fields := range records {
+			fields, err = convert(fields)
+			if err != nil {
+				return err
+			}
+
+			content.WriteString(s.separators.encodeRecord(fields...))
+		}
+
+		err = s.fs.WriteFile(tempFilePath, []byte(content.String()))
+		if err != nil {
+			return err
+		}
+
+		_, err = s.fs.AppendFile(m.path, []byte(fmt.Sprintf("%s %s\n", migrationDone, strconv.Quote(file.path))))
+		if err != nil {
+			return err
+		}
+		m.done[file.path] = true
+	}
+
+	for _, p := range []string{getChecksumFilePath(path), getBackupFilePath(path), getChecksumFilePath(getBackupFilePath(path))} {
+		err := s.fs.Remove(p)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	err := s.fs.Rename(tempFilePath, path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	return err
+}
+
+// upgradeStoredValue returns the value stored in the record of the timestamped key as it is stored in a database
+// whose values are flagged, compressing it if it is plain and of at least the compression threshold
+func (s *Store) upgradeStoredValue(timestampedKey string, sealed string, valueFlags bool) (string, error) {
+	stored, err := unsealRecord(timestampedKey, sealed, s.recordMACKey())
+	if err != nil {
+		return "", err
+	}
+
+	if !valueFlags {
+		stored = string(plainValueFlag) + stored
+	}
+	if stored == "" {
+		return "", ErrCorruptedData
+	}
+
+	if stored[0] == plainValueFlag {
+		stored, err = s.encodeValue(stored[1:], nil)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return s.sealRecord(timestampedKey, stored), nil
+}
+
+// checkSeparable checks that every field of the records in the files can be written with the separators of dst
+func (s *Store) checkSeparable(src Separators, dst Separators, files []recordFile) error {
+	for _, file := range files {
+		data, err := os.ReadFile(filepath.Join(s.dbPath, file.path))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		records, err := src.extractRecords(src.withoutTornRecord(data), file.fields)
+		if err != nil {
+			return err
+		}
+
+		for _, fields := range records {
+			for _, field := range fields {
+				if dst.validateKeyValue(field, "") != nil {
+					return fmt.Errorf("%w: in %s", ErrInvalidKeyValue, file.path)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// getRecordFiles returns the files of records in the database folder, including those in the
+// archive and oplog folders
+func (s *Store) getRecordFiles() ([]recordFile, error) {
+	files := []recordFile{
+		{path: IndexFilename, fields: 2},
+		{path: DelFilename, fields: 1},
+		{path: ExpiryFilename, fields: 2},
+		{path: UsageFilename, fields: 2},
+		{path: HistoryFilename, fields: 2},
+		{path: TrashFilename, fields: 2},
+		{path: MetaSegmentFilename, fields: 2},
+		{path: CasingFilename, fields: 2},
+	}
+
+	filenames, err := getLogAndDataFilenames(s.dbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, filename := range filenames {
+		files = append(files, recordFile{path: filename, fields: 2, hasValues: true})
+	}
+
+	for _, folder := range []recordFile{
+		{path: ArchiveFolderName, fields: 2, hasValues: true},
+		{path: OplogFolderName, fields: 5},
+	} {
+		ext := "." + DataFileExt
+		if folder.path == OplogFolderName {
+			ext = "." + OplogFileExt
+		}
+
+		filenames, err := GetFileOrFolderNamesInFolder(filepath.Join(s.dbPath, folder.path))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for _, filename := range filenames {
+			if strings.HasSuffix(filename, ext) {
+				files = append(files, recordFile{path: filepath.Join(folder.path, filename), fields: folder.fields, hasValues: folder.hasValues})
+			}
+		}
+	}
+
+	return files, nil
+}
+
+// resumeFormatMigration finishes the conversion that was interrupted, if any
+func (s *Store) resumeFormatMigration() error {
+	m, err := readFormatMigration(s.dbPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	version, err := ReadFormatVersion(s.dbPath)
+	if err != nil {
+		return err
+	}
+
+	if version != m.to {
+		steps := formatMigrations
+		if m.to < m.from {
+			steps = formatDowngrades
+		}
+
+		migrate, ok := steps[m.from]
+		if !ok || version != m.from {
+			return ErrCorruptedData
+		}
+
+		err = migrate(s)
+		if err != nil {
+			return err
+		}
+	}
+
+	return s.finishFormatMigration(m.to)
+}
+
+// finishFormatMigration writes the version the folder has been converted to, and then removes
+// the migration file, if any
+func (s *Store) finishFormatMigration(version int) error {
+	err := writeFormatVersion(s.fs, s.dbPath, version)
+	if err != nil {
+		return err
+	}
+
+	err = s.fs.Remove(filepath.Join(s.dbPath, FormatMigrationFilename))
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	return err
+}
+
+// encode returns the contents of the migration file recording the migration before any file is done
+func (m *formatMigration) encode() []byte {
+	return []byte(fmt.Sprintf("%s %s\n%s %s\n%s %s\n",
+		migrationFrom, strconv.Quote(strconv.Itoa(m.from)),
+		migrationTo, strconv.Quote(strconv.Itoa(m.to)),
+		migrationValueFlags, strconv.Quote(strconv.FormatBool(m.valueFlags))))
+}
+
+// readFormatMigration reads the migration file of the database folder at dbPath, each line of which is
+// a name and its quoted value. A line cut short by a crash while it was appended is ignored
+func readFormatMigration(dbPath string) (*formatMigration, error) {
+	path := filepath.Join(dbPath, FormatMigrationFilename)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &formatMigration{path: path, done: map[string]bool{}}
+	lines := bytes.Split(data, []byte("\n"))
+	for _, line := range lines[:len(lines)-1] {
+		name, quoted, _ := strings.Cut(string(line), " ")
+		value, err := strconv.Unquote(quoted)
+		if err != nil {
+			return nil, ErrCorruptedData
+		}
+
+		switch name {
+		case migrationFrom:
+			m.from, err = strconv.Atoi(value)
+		case migrationTo:
+			m.to, err = strconv.Atoi(value)
+		case migrationValueFlags:
+			m.valueFlags = value == "true"
+		case migrationDone:
+			m.done[value] = true
+		}
+		if err != nil {
+			return nil, ErrCorruptedData
+		}
+	}
+
+	if m.from < 1 || m.to < 1 {
+		return nil, ErrCorruptedData
+	}
+
+	return m, nil
+}
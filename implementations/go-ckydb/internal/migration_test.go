@@ -0,0 +1,286 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatMigration(t *testing.T) {
+	dbPath, err := filepath.Abs("testFormatMigrationDb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = ClearDummyFileDataInDb(dbPath) }()
+
+	dummyData := map[string]string{
+		"cow":  "500 months",
+		"dog":  "23 months",
+		"goat": "678 months",
+		"hen":  "567 months",
+		"pig":  "70 months",
+		"fish": "8990 months",
+	}
+	bigValue := strings.Repeat("moo ", 1024)
+	separators := DefaultSeparators.Token + DefaultSeparators.KeyValue
+
+	// seedStore fills the database folder with the dummy data, a big value, a key with an expiry, a metadata key
+	// and an oplog, and returns the data it should hold
+	seedStore := func(t *testing.T) map[string]string {
+		err := ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = AddDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		store := NewStore(dbPath, 320.0/1024, WithOplog(true))
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = store.Set("big", bigValue)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = store.SetWithTTL("ram", "2 months", time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = store.SetMeta("schema_version", "3")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := map[string]string{"big": bigValue, "ram": "2 months"}
+		for k, v := range dummyData {
+			expected[k] = v
+		}
+		return expected
+	}
+
+	// assertStoreHolds asserts that the store on the database folder loads and holds the data
+	assertStoreHolds := func(t *testing.T, expected map[string]string) *Store {
+		store := NewStore(dbPath, 320.0/1024, WithOplog(true))
+		err := store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for k, v := range expected {
+			value, err := store.Get(k)
+			assert.Nil(t, err, k)
+			assert.Equal(t, v, value, k)
+		}
+
+		ttl, err := store.TTL("ram")
+		assert.Nil(t, err)
+		assert.True(t, ttl > 0)
+		value, err := store.GetMeta("schema_version")
+		assert.Nil(t, err)
+		assert.Equal(t, "3", value)
+		entries := 0
+		for _, err := range store.ReadOplog(0) {
+			assert.Nil(t, err)
+			entries++
+		}
+		assert.Equal(t, 2, entries)
+		return store
+	}
+
+	t.Run("MigrateFormatToShouldConvertToLengthPrefixedRecordsAndBack", func(t *testing.T) {
+		expected := seedStore(t)
+
+		err := MigrateFormatTo(dbPath, 2)
+		assert.Nil(t, err)
+		version, err := ReadFormatVersion(dbPath)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, version)
+		index, err := ReadFileToString(filepath.Join(dbPath, IndexFilename))
+		assert.Nil(t, err)
+		assert.True(t, strings.HasPrefix(index, "3:cow23:1655375120328185000-cow\n"), index)
+		logs, err := ReadFilesWithExtension(dbPath, "."+LogFileExt)
+		assert.Nil(t, err)
+		assert.NotContains(t, strings.Join(logs, ""), bigValue)
+		assert.NoFileExists(t, filepath.Join(dbPath, FormatMigrationFilename))
+
+		store := assertStoreHolds(t, expected)
+		err = store.Set("odd"+separators, "value"+separators)
+		assert.Nil(t, err)
+		value, err := store.Get("odd" + separators)
+		assert.Nil(t, err)
+		assert.Equal(t, "value"+separators, value)
+
+		err = MigrateFormatTo(dbPath, 1)
+		assert.ErrorIs(t, err, ErrInvalidKeyValue)
+		version, err = ReadFormatVersion(dbPath)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, version)
+		assert.NoFileExists(t, filepath.Join(dbPath, FormatMigrationFilename))
+
+		err = store.Delete("odd" + separators)
+		assert.Nil(t, err)
+		err = store.Vacuum()
+		assert.Nil(t, err)
+		err = os.RemoveAll(filepath.Join(dbPath, OplogFolderName))
+		assert.Nil(t, err)
+
+		err = MigrateFormatTo(dbPath, 1)
+		assert.Nil(t, err)
+		version, err = ReadFormatVersion(dbPath)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, version)
+		reloaded := NewStore(dbPath, 320.0/1024)
+		err = reloaded.Load()
+		assert.Nil(t, err)
+		for k, v := range expected {
+			value, err := reloaded.Get(k)
+			assert.Nil(t, err, k)
+			assert.Equal(t, v, value, k)
+		}
+	})
+
+	t.Run("MigrateFormatToShouldConvertToTheCompactIndexAndBack", func(t *testing.T) {
+		expected := seedStore(t)
+		indexFilePath := filepath.Join(dbPath, IndexFilename)
+
+		err := MigrateFormatTo(dbPath, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fullIndex, err := ReadFileToString(indexFilePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = MigrateFormatTo(dbPath, 3)
+		assert.Nil(t, err)
+		version, err := ReadFormatVersion(dbPath)
+		assert.Nil(t, err)
+		assert.Equal(t, 3, version)
+		index, err := ReadFileToString(indexFilePath)
+		assert.Nil(t, err)
+		assert.True(t, strings.HasPrefix(index, "3:cow19:1655375120328185000\n"), index)
+		assert.Less(t, len(index), len(fullIndex))
+
+		assertStoreHolds(t, expected)
+		store := NewStore(dbPath, 320.0/1024)
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = store.Set("hey", "English")
+		assert.Nil(t, err)
+		err = store.Delete("dog")
+		assert.Nil(t, err)
+		err = store.Vacuum()
+		assert.Nil(t, err)
+		delete(expected, "dog")
+		expected["hey"] = "English"
+		assertStoreHolds(t, expected)
+
+		follower := NewStore(dbPath, 320.0/1024, WithFollower(true))
+		err = follower.Load()
+		assert.Nil(t, err)
+		value, err := follower.Get("hey")
+		assert.Nil(t, err)
+		assert.Equal(t, "English", value)
+
+		err = MigrateFormatTo(dbPath, 1)
+		assert.Nil(t, err)
+		index, err = ReadFileToString(indexFilePath)
+		assert.Nil(t, err)
+		assert.True(t, strings.HasPrefix(index, "cow><?&(^#1655375120328185000-cow$%#@*&^&"), index)
+		assertStoreHolds(t, expected)
+	})
+
+	t.Run("MigrateFormatToShouldResumeInterruptedConversions", func(t *testing.T) {
+		for _, kind := range []faultKind{faultCrash, faultPartialWrite, faultError} {
+			for failAt := 1; ; failAt++ {
+				expected := seedStore(t)
+				fs := &faultyFileSystem{}
+				fs.arm(failAt, kind)
+
+				err := MigrateFormatTo(dbPath, 2, WithFileSystem(fs))
+				if err == nil {
+					break
+				}
+
+				_, err = os.Stat(filepath.Join(dbPath, FormatMigrationFilename))
+				if err == nil {
+					err = NewStore(dbPath, 320.0/1024).Load()
+					assert.ErrorIs(t, err, ErrMigrationInProgress, kind, failAt)
+				}
+
+				err = MigrateFormatTo(dbPath, 2)
+				assert.Nil(t, err, kind, failAt)
+				version, err := ReadFormatVersion(dbPath)
+				assert.Nil(t, err)
+				assert.Equal(t, 2, version, kind, failAt)
+				assertStoreHolds(t, expected)
+			}
+		}
+	})
+
+	t.Run("MigrateFormatToShouldNeedTheHMACKeyOfDatabasesWithRecordHMACs", func(t *testing.T) {
+		key := []byte("secret")
+		err := ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		store := NewStore(dbPath, 320.0/1024, WithRecordHMAC(key))
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = store.Set("big", bigValue)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = MigrateFormatTo(dbPath, 2)
+		assert.ErrorIs(t, err, ErrInvalidHMACKey)
+		err = MigrateFormatTo(dbPath, 2, WithRecordHMAC(key))
+		assert.Nil(t, err)
+
+		reloaded := NewStore(dbPath, 320.0/1024, WithRecordHMAC(key))
+		err = reloaded.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		value, err := reloaded.Get("big")
+		assert.Nil(t, err)
+		assert.Equal(t, bigValue, value)
+		tampered, err := reloaded.Verify()
+		assert.Nil(t, err)
+		assert.Empty(t, tampered)
+	})
+
+	t.Run("MigrateFormatToShouldConvertLoadedStores", func(t *testing.T) {
+		expected := seedStore(t)
+		store := NewStore(dbPath, 320.0/1024, WithOplog(true))
+		err := store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = store.MigrateFormatTo(2)
+		assert.Nil(t, err)
+		for k, v := range expected {
+			value, err := store.Get(k)
+			assert.Nil(t, err, k)
+			assert.Equal(t, v, value, k)
+		}
+		err = store.Set("odd"+separators, "value")
+		assert.Nil(t, err)
+
+		err = MigrateFormatTo(dbPath, 4)
+		assert.ErrorIs(t, err, ErrUnsupportedFormatVersion)
+	})
+}
@@ -0,0 +1,232 @@
+package internal
+
+import (
+	"fmt"
+	"iter"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	OplogFolderName = "oplog"
+	OplogFileExt    = "oplog"
+)
+
+const (
+	OplogSet    = "set"
+	OplogDelete = "delete"
+	OplogClear  = "clear"
+)
+
+// OplogEntry is a mutation recorded in the oplog
+type OplogEntry struct {
+	// Seq is the sequence number of the entry. It is one more than that of the entry before it
+	Seq uint64
+	// Op is the kind of mutation i.e. OplogSet, OplogDelete or OplogClear
+	Op    string
+	Key   string
+	Value string
+	// Timestamp is when the mutation was committed, in nanoseconds since the unix epoch
+	Timestamp int64
+}
+
+// oplog is the state of the oplog of a store. The oplog is a folder of append-only files,
+// each named after the sequence number of its first entry, and rolled like the log file
+// once it exceeds the store's maxFileSizeKB
+type oplog struct {
+	path        string
+	nextSeq     uint64
+	currentFile string
+	currentSize int64
+}
+
+// WithOplog makes the store append every mutation to the oplog folder with a sequence number,
+// so that other programs can follow the changes to the store with ReadOplog
+func WithOplog(isEnabled bool) StoreOption {
+	return func(s *Store) {
+		s.isOplogEnabled = isEnabled
+	}
+}
+
+// ReadOplog returns an iterator over the entries of the oplog of the database at dbPath,
+// starting at the entry whose sequence number is fromSeq. It stops at the last entry written
+// so far, so the entries after it are read by calling ReadOplog again with the next sequence number.
+// A database without an oplog has no entries
+func ReadOplog(dbPath string, fromSeq uint64) iter.Seq2[OplogEntry, error] {
+	return func(yield func(OplogEntry, error) bool) {
+		sep, err := ReadSeparators(dbPath)
+		if err != nil {
+			yield(OplogEntry{}, err)
+			return
+		}
+
+		path := filepath.Join(dbPath, OplogFolderName)
+		firstSeqs, err := getOplogFiles(path)
+		if err != nil {
+			yield(OplogEntry{}, err)
+			return
+		}
+
+		// entries before fromSeq are skipped without reading the files they are in
+		start := sort.Search(len(firstSeqs), func(i int) bool { return firstSeqs[i] > fromSeq })
+		for _, firstSeq := range firstSeqs[max(start-1, 0):] {
+			entries, err := sep.readOplogFile(getOplogFilePath(path, firstSeq))
+			if err != nil {
+				yield(OplogEntry{}, err)
+				return
+			}
+
+			for _, entry := range entries {
+				if entry.Seq >= fromSeq && !yield(entry, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// ReadOplog returns an iterator over the entries of the store's oplog starting at the entry
+// whose sequence number is fromSeq
+func (s *Store) ReadOplog(fromSeq uint64) iter.Seq2[OplogEntry, error] {
+	return ReadOplog(s.dbPath, fromSeq)
+}
+
+// loadOplog creates the oplog folder if it does not exist and finds the sequence number
+// of the next entry, repairing the last file of the oplog if its last entry is torn
+func (s *Store) loadOplog() error {
+	if !s.isOplogEnabled {
+		return nil
+	}
+
+	path := filepath.Join(s.dbPath, OplogFolderName)
+	err := os.MkdirAll(path, 0777)
+	if err != nil {
+		return err
+	}
+
+	firstSeqs, err := getOplogFiles(path)
+	if err != nil {
+		return err
+	}
+
+	s.oplog = &oplog{path: path, nextSeq: 1}
+	if len(firstSeqs) == 0 {
+		return nil
+	}
+
+	lastSeq := firstSeqs[len(firstSeqs)-1]
+	currentFile := getOplogFilePath(path, lastSeq)
+	err = s.repairTornRecord(currentFile)
+	if err != nil {
+		return err
+	}
+
+	entries, err := s.separators.readOplogFile(currentFile)
+	if err != nil {
+		return err
+	}
+
+	s.oplog.nextSeq = lastSeq
+	if len(entries) > 0 {
+		s.oplog.nextSeq = entries[len(entries)-1].Seq + 1
+	}
+
+	info, err := os.Stat(currentFile)
+	if err != nil {
+		return err
+	}
+
+	s.oplog.currentFile, s.oplog.currentSize = currentFile, info.Size()
+	return nil
+}
+
+// appendToOplog appends the mutation to the oplog, if it is enabled, starting a new oplog file
+// if the current one is too big
+func (s *Store) appendToOplog(op string, key string, value string) error {
+	if s.oplog == nil {
+		return nil
+	}
+
+	if s.oplog.currentFile == "" || float64(s.oplog.currentSize) >= s.maxFileSizeKB*1024 {
+		s.oplog.currentFile, s.oplog.currentSize = getOplogFilePath(s.oplog.path, s.oplog.nextSeq), 0
+	}
+
+	record := s.separators.encodeRecord(
+		strconv.FormatUint(s.oplog.nextSeq, 10),
+		op,
+		strconv.FormatInt(s.clock.Now().UnixNano(), 10),
+		key,
+		value,
+	)
+
+	n, err := s.appendFile(s.oplog.currentFile, []byte(record))
+	s.oplog.currentSize += int64(n)
+	if err != nil {
+		return err
+	}
+
+	s.oplog.nextSeq++
+	return nil
+}
+
+// getOplogFiles returns the sequence numbers after which the files in the oplog folder
+// at path are named, in ascending order
+func getOplogFiles(path string) ([]uint64, error) {
+	filenames, err := GetFileOrFolderNamesInFolder(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	firstSeqs := make([]uint64, 0, len(filenames))
+	for _, filename := range filenames {
+		name, isOplogFile := strings.CutSuffix(filename, "."+OplogFileExt)
+		seq, err := strconv.ParseUint(name, 10, 64)
+		if isOplogFile && err == nil {
+			firstSeqs = append(firstSeqs, seq)
+		}
+	}
+
+	sort.Slice(firstSeqs, func(i, j int) bool { return firstSeqs[i] < firstSeqs[j] })
+	return firstSeqs, nil
+}
+
+// readOplogFile reads the entries in the oplog file at path, ignoring a torn entry at its end
+func (sep Separators) readOplogFile(path string) ([]OplogEntry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := sep.extractRecords(sep.withoutTornRecord(data), 5)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]OplogEntry, len(records))
+	for i, fields := range records {
+		seq, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			return nil, ErrCorruptedData
+		}
+
+		timestamp, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			return nil, ErrCorruptedData
+		}
+
+		entries[i] = OplogEntry{Seq: seq, Op: fields[1], Key: fields[3], Value: fields[4], Timestamp: timestamp}
+	}
+
+	return entries, nil
+}
+
+// getOplogFilePath returns the path to the file in the oplog folder at path whose first entry has the given sequence number
+func getOplogFilePath(path string, firstSeq uint64) string {
+	return filepath.Join(path, fmt.Sprintf("%d.%s", firstSeq, OplogFileExt))
+}
@@ -0,0 +1,148 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOplog(t *testing.T) {
+	dbPath, err := filepath.Abs("testOplogDb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	oplogPath := filepath.Join(dbPath, OplogFolderName)
+	now := time.Date(2022, 6, 16, 10, 0, 0, 0, time.UTC)
+	defer func() { _ = ClearDummyFileDataInDb(dbPath) }()
+
+	// newStore returns a loaded store with an oplog, in a folder that is cleared first if isFresh
+	newStore := func(t *testing.T, maxFileSizeKB float64, isFresh bool) *Store {
+		if isFresh {
+			err := ClearDummyFileDataInDb(dbPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		store := NewStore(dbPath, maxFileSizeKB, WithOplog(true), WithClock(NewFakeClock(now)))
+		err := store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return store
+	}
+
+	// readAll returns all the entries of the oplog from fromSeq
+	readAll := func(t *testing.T, fromSeq uint64) []OplogEntry {
+		var entries []OplogEntry
+		for entry, err := range ReadOplog(dbPath, fromSeq) {
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			entries = append(entries, entry)
+		}
+
+		return entries
+	}
+
+	t.Run("MutationsShouldBeAppendedWithIncreasingSequenceNumbers", func(t *testing.T) {
+		store := newStore(t, 320.0/1024, true)
+
+		assert.Nil(t, store.Set("cow", "500 months"))
+		assert.Nil(t, store.Set("cow", "510 months"))
+		assert.Nil(t, store.Delete("cow"))
+		assert.ErrorIs(t, store.Delete("cow"), ErrNotFound)
+		assert.ErrorIs(t, store.Set("goat", KeyValueSeparator), ErrInvalidKeyValue)
+		assert.Nil(t, store.Clear())
+
+		timestamp := now.UnixNano()
+		expected := []OplogEntry{
+			{Seq: 1, Op: OplogSet, Key: "cow", Value: "500 months", Timestamp: timestamp},
+			{Seq: 2, Op: OplogSet, Key: "cow", Value: "510 months", Timestamp: timestamp},
+			{Seq: 3, Op: OplogDelete, Key: "cow", Timestamp: timestamp},
+			{Seq: 4, Op: OplogClear, Timestamp: timestamp},
+		}
+		assert.Equal(t, expected, readAll(t, 0))
+		assert.Equal(t, expected[2:], readAll(t, 3))
+		assert.Empty(t, readAll(t, 5))
+	})
+
+	t.Run("SequenceNumbersShouldContinueAcrossRestarts", func(t *testing.T) {
+		store := newStore(t, 320.0/1024, true)
+		assert.Nil(t, store.Set("cow", "500 months"))
+		assert.Nil(t, store.Set("goat", "678 months"))
+
+		store = newStore(t, 320.0/1024, false)
+		assert.Nil(t, store.Set("hen", "567 months"))
+
+		entries := readAll(t, 3)
+		assert.Equal(t, 1, len(entries))
+		assert.Equal(t, uint64(3), entries[0].Seq)
+		assert.Equal(t, "hen", entries[0].Key)
+	})
+
+	t.Run("ReadOplogShouldStartAtFromSeqAcrossOplogFiles", func(t *testing.T) {
+		store := newStore(t, 0.2, true)
+		for i := 1; i <= 20; i++ {
+			assert.Nil(t, store.Set(fmt.Sprintf("key-%d", i), fmt.Sprintf("value-%d", i)))
+		}
+
+		files, err := GetFileOrFolderNamesInFolder(oplogPath)
+		assert.Nil(t, err)
+		assert.Greater(t, len(files), 2)
+
+		entries := readAll(t, 7)
+		assert.Equal(t, 14, len(entries))
+		for i, entry := range entries {
+			assert.Equal(t, uint64(i+7), entry.Seq)
+			assert.Equal(t, fmt.Sprintf("key-%d", i+7), entry.Key)
+		}
+	})
+
+	t.Run("TornEntriesShouldBeIgnoredThenRepaired", func(t *testing.T) {
+		store := newStore(t, 320.0/1024, true)
+		assert.Nil(t, store.Set("cow", "500 months"))
+
+		f, err := os.OpenFile(getOplogFilePath(oplogPath, 1), os.O_APPEND|os.O_WRONLY, 0666)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = f.WriteString("2" + KeyValueSeparator + "se")
+		_ = f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, 1, len(readAll(t, 0)))
+
+		store = newStore(t, 320.0/1024, false)
+		assert.Nil(t, store.Set("goat", "678 months"))
+
+		entries := readAll(t, 0)
+		assert.Equal(t, 2, len(entries))
+		assert.Equal(t, uint64(2), entries[1].Seq)
+		assert.Equal(t, "goat", entries[1].Key)
+	})
+
+	t.Run("StoresWithoutOplogShouldHaveNoEntries", func(t *testing.T) {
+		err := ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		store := NewStore(dbPath, 320.0/1024)
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Nil(t, store.Set("cow", "500 months"))
+
+		_, err = os.Stat(oplogPath)
+		assert.True(t, os.IsNotExist(err))
+		assert.Empty(t, readAll(t, 0))
+	})
+}
@@ -0,0 +1,114 @@
+package internal
+
+import "os"
+
+// OpStats records what a single store operation did, for use in tracing and logging.
+// All its methods are safe to call on a nil *OpStats, in which case nothing is recorded
+type OpStats struct {
+	// CacheHit is true if the value was got from the in-memory cache of an old data file
+	CacheHit bool
+	// CacheReload is true if a data file had to be loaded into the cache
+	CacheReload bool
+	// LogRewrite is true if the whole log file was rewritten
+	LogRewrite bool
+	// LogRoll is true if the log file was rolled into a data file
+	LogRoll bool
+	// FilesTouched are the paths of the files read or written
+	FilesTouched []string
+	BytesRead    int64
+	BytesWritten int64
+}
+
+// recordRead records that the given number of bytes were read from the file at path
+func (st *OpStats) recordRead(path string, bytesRead int) {
+	if st == nil {
+		return
+	}
+
+	st.touch(path)
+	st.BytesRead += int64(bytesRead)
+}
+
+// recordWrite records that the given number of bytes were written to the file at path
+func (st *OpStats) recordWrite(path string, bytesWritten int) {
+	if st == nil {
+		return
+	}
+
+	st.touch(path)
+	st.BytesWritten += int64(bytesWritten)
+}
+
+// recordFileRewrite records that the whole file at path was rewritten
+func (st *OpStats) recordFileRewrite(path string) {
+	if st == nil {
+		return
+	}
+
+	var size int64
+	if info, err := os.Stat(path); err == nil {
+		size = info.Size()
+	}
+
+	st.touch(path)
+	st.BytesWritten += size
+}
+
+// recordFileRemoval records that the file at path was removed
+func (st *OpStats) recordFileRemoval(path string) {
+	if st != nil {
+		st.touch(path)
+	}
+}
+
+// recordCacheHit records whether the cache already had the data needed
+func (st *OpStats) recordCacheHit(isHit bool) {
+	if st == nil {
+		return
+	}
+
+	st.CacheHit = isHit
+	st.CacheReload = !isHit
+}
+
+// recordLogRewrite records that the log file was rewritten
+func (st *OpStats) recordLogRewrite() {
+	if st != nil {
+		st.LogRewrite = true
+	}
+}
+
+// recordLogRoll records that the log file was rolled into a data file
+func (st *OpStats) recordLogRoll() {
+	if st != nil {
+		st.LogRoll = true
+	}
+}
+
+// merge records what the other OpStats recorded, for operations that run parts of themselves in parallel
+func (st *OpStats) merge(other *OpStats) {
+	if st == nil {
+		return
+	}
+
+	st.CacheHit = st.CacheHit || other.CacheHit
+	st.CacheReload = st.CacheReload || other.CacheReload
+	st.LogRewrite = st.LogRewrite || other.LogRewrite
+	st.LogRoll = st.LogRoll || other.LogRoll
+	for _, path := range other.FilesTouched {
+		st.touch(path)
+	}
+	st.BytesRead += other.BytesRead
+	st.BytesWritten += other.BytesWritten
+}
+
+// touch adds the path to the FilesTouched if it is not yet there
+func (st *OpStats) touch(path string) {
+	for _, file := range st.FilesTouched {
+		if file == path {
+			return
+		}
+	}
+
+	st.FilesTouched = append(st.FilesTouched, path)
+}
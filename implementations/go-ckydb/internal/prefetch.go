@@ -0,0 +1,74 @@
+package internal
+
+// WithCachePrefetch makes the store read the next data file in the background whenever
+// a data file is loaded into the cache, so that reading keys in roughly chronological
+// order does not wait on the disk
+func WithCachePrefetch(isEnabled bool) StoreOption {
+	return func(s *Store) {
+		s.isPrefetchEnabled = isEnabled
+	}
+}
+
+// takePrefetchedCache returns the prefetched cache, and clears it, if it contains the timestampedKey
+func (s *Store) takePrefetchedCache(timestampedKey string) *Cache {
+	s.cacheLock.Lock()
+	defer s.cacheLock.Unlock()
+
+	if s.prefetched == nil || !s.prefetched.IsInRange(timestampedKey) {
+		return nil
+	}
+
+	cache := s.prefetched
+	s.prefetched = nil
+	return cache
+}
+
+// prefetchNextCache reads the data file after the given cache into the prefetched
+// cache in the background, if prefetching is enabled
+func (s *Store) prefetchNextCache(cache *Cache) {
+	if !s.isPrefetchEnabled || cache.end >= s.currentLogFile {
+		return
+	}
+
+	timestampRange := s.getTimestampRangeForKey(cache.end)
+	if timestampRange == nil || timestampRange.Start != cache.end {
+		return
+	}
+
+	s.cacheLock.Lock()
+	generation := s.cacheGeneration
+	isPrefetched := s.prefetched != nil && s.prefetched.start == timestampRange.Start
+	s.cacheLock.Unlock()
+
+	if isPrefetched {
+		return
+	}
+
+	s.prefetchWaitGroup.Add(1)
+	go func() {
+		defer s.prefetchWaitGroup.Done()
+
+		// prefetches are counted as reads of gets, which they are made ahead of
+		readSt := &OpStats{}
+		nextCache, err := s.readCache(timestampRange, readSt)
+		s.io.getBytesRead.Add(readSt.BytesRead)
+		if err != nil {
+			return
+		}
+
+		s.cacheLock.Lock()
+		defer s.cacheLock.Unlock()
+
+		// discard it if any data file was changed while it was being read
+		if s.cacheGeneration == generation {
+			s.prefetched = nextCache
+		}
+	}()
+}
+
+// discardPrefetchedCache clears the prefetched cache and stops any prefetch in
+// progress from being kept. It should be called, with the cacheLock held, every time a data file changes
+func (s *Store) discardPrefetchedCache() {
+	s.prefetched = nil
+	s.cacheGeneration++
+}
@@ -0,0 +1,124 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const CorruptFolderName = "corrupt"
+
+// QuarantinedRecord is the report of a record that was moved to the corrupt folder as its value could not be read
+type QuarantinedRecord struct {
+	Key            string `json:"key"`
+	TimestampedKey string `json:"timestamped_key"`
+	// File is the name of the log file or data file that held the record
+	File string `json:"file"`
+	// Value is the value of the record as it was saved in the file
+	Value string `json:"value"`
+	// Err is the error got reading the value
+	Err           string    `json:"err"`
+	QuarantinedAt time.Time `json:"quarantined_at"`
+}
+
+// QuarantineWithStats moves the record of the given key to the corrupt folder if its value cannot be read as it is
+// corrupted, recording what it did in st. The report of the record is saved in the folder and the key is deleted,
+// so that it is no longer found instead of failing with an ErrCorruptedData error on every Get. Keys whose values
+// can be read are left as they are. It returns an ErrNotFound error if the key is nonexistent
+func (s *Store) QuarantineWithStats(key string, st *OpStats) error {
+	key = s.foldKey(key)
+	return s.guardWrite(func() error {
+		timestampedKey, ok := s.lookup(key)
+		if !ok {
+			return ErrNotFound
+		}
+
+		sealed, err := s.getStoredValueForKey(timestampedKey, st)
+		if err != nil {
+			return err
+		}
+
+		_, readErr := s.openValue(timestampedKey, sealed)
+		if !errors.Is(readErr, ErrCorruptedData) {
+			return readErr
+		}
+
+		file, err := s.getFileHoldingKey(timestampedKey)
+		if err != nil {
+			return err
+		}
+
+		record := QuarantinedRecord{
+			Key:            key,
+			TimestampedKey: timestampedKey,
+			File:           file,
+			Value:          sealed,
+			Err:            readErr.Error(),
+			QuarantinedAt:  s.clock.Now(),
+		}
+		err = s.saveQuarantinedRecord(record)
+		if err != nil {
+			return err
+		}
+
+		return s.deleteWithStats(key, st)
+	})
+}
+
+// Quarantined returns the reports of the records moved to the corrupt folder, in the order of their timestamped keys.
+// The folder is kept until the store is cleared
+func (s *Store) Quarantined() ([]QuarantinedRecord, error) {
+	path := filepath.Join(s.dbPath, CorruptFolderName)
+	filenames, err := GetFileOrFolderNamesInFolder(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(filenames)
+
+	var records []QuarantinedRecord
+	for _, filename := range filenames {
+		if !strings.HasSuffix(filename, ".json") {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(path, filename))
+		if err != nil {
+			return nil, err
+		}
+
+		var record QuarantinedRecord
+		err = json.Unmarshal(data, &record)
+		if err != nil {
+			return nil, ErrCorruptedData
+		}
+
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+// saveQuarantinedRecord saves the report of the record in the corrupt folder, named after the timestamp of its key
+// as the key itself may not be a valid filename
+func (s *Store) saveQuarantinedRecord(record QuarantinedRecord) error {
+	path := filepath.Join(s.dbPath, CorruptFolderName)
+	err := os.MkdirAll(path, 0777)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	timestamp, _, _ := strings.Cut(record.TimestampedKey, "-")
+	return s.replaceFile(filepath.Join(path, timestamp+".json"), data)
+}
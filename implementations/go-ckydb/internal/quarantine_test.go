@@ -0,0 +1,97 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuarantine(t *testing.T) {
+	dbPath, err := filepath.Abs("testQuarantineDb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	blob := strings.Repeat("a value spilled to a blob ", 10)
+	defer func() { _ = ClearDummyFileDataInDb(dbPath) }()
+
+	// newStore returns a loaded store whose value of "cow" is corrupted as its blob is missing
+	newStore := func(t *testing.T) *Store {
+		err := ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		store := NewStore(dbPath, 320.0/1024, WithBlobSpillThreshold(64))
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for key, value := range map[string]string{"cow": blob, "dog": "23 months"} {
+			err = store.Set(key, value)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		err = os.RemoveAll(filepath.Join(dbPath, BlobsFolderName))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return store
+	}
+
+	t.Run("QuarantineShouldMoveTheCorruptedRecordToTheCorruptFolder", func(t *testing.T) {
+		store := newStore(t)
+		_, err := store.Get("cow")
+		assert.ErrorIs(t, err, ErrCorruptedData)
+		timestampedKey := store.index["cow"]
+
+		err = store.QuarantineWithStats("cow", nil)
+		assert.Nil(t, err)
+
+		_, err = store.Get("cow")
+		assert.ErrorIs(t, err, ErrNotFound)
+		value, err := store.Get("dog")
+		assert.Nil(t, err)
+		assert.Equal(t, "23 months", value)
+
+		records, err := store.Quarantined()
+		assert.Nil(t, err)
+		if assert.Len(t, records, 1) {
+			assert.Equal(t, "cow", records[0].Key)
+			assert.Equal(t, timestampedKey, records[0].TimestampedKey)
+			assert.Equal(t, store.currentLogFile+"."+LogFileExt, records[0].File)
+			assert.Equal(t, string(spilledValueFlag), records[0].Value[:1])
+			assert.Equal(t, ErrCorruptedData.Error(), records[0].Err)
+		}
+
+		// the reports outlive reloads
+		store = NewStore(dbPath, 320.0/1024)
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		reloaded, err := store.Quarantined()
+		assert.Nil(t, err)
+		assert.Equal(t, records, reloaded)
+	})
+
+	t.Run("QuarantineShouldLeaveReadableRecordsAsTheyAre", func(t *testing.T) {
+		store := newStore(t)
+		err := store.QuarantineWithStats("dog", nil)
+		assert.Nil(t, err)
+
+		value, err := store.Get("dog")
+		assert.Nil(t, err)
+		assert.Equal(t, "23 months", value)
+		records, err := store.Quarantined()
+		assert.Nil(t, err)
+		assert.Empty(t, records)
+
+		err = store.QuarantineWithStats("unknown", nil)
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
@@ -0,0 +1,82 @@
+package internal
+
+import (
+	"io/fs"
+	"path/filepath"
+)
+
+// WithMaxDatabaseSize limits the total size in bytes of the files in the database folder.
+// Once it is reached, Sets fail with an ErrQuotaExceeded error. Zero means no limit
+func WithMaxDatabaseSize(bytes int64) StoreOption {
+	return func(s *Store) {
+		s.maxDatabaseSize = bytes
+	}
+}
+
+// WithQuotaEviction makes Sets that would exceed the maximum database size delete the oldest
+// data files, together with their keys, until the new key-value pair fits
+func WithQuotaEviction(isEnabled bool) StoreOption {
+	return func(s *Store) {
+		s.quotaEviction = isEnabled
+	}
+}
+
+// ensureQuotaFor checks that the key-value pair fits in the maximum database size, evicting
+// the oldest data files to make room for it if quota eviction is enabled.
+// It returns an ErrQuotaExceeded error if the pair does not fit
+func (s *Store) ensureQuotaFor(key string, value string) error {
+	if s.maxDatabaseSize <= 0 {
+		return nil
+	}
+
+	// the pair is written with its timestamp and separators to the log file, and the key to the index
+	needed := int64(2*len(key) + len(value) + 2*len(s.separators.Token) + 2*len(s.separators.KeyValue) + 20)
+	for {
+		usage, err := s.getDiskUsage()
+		if err != nil {
+			return err
+		}
+
+		if usage+needed <= s.maxDatabaseSize {
+			return nil
+		}
+
+		if !s.quotaEviction || len(s.dataFiles) == 0 {
+			return ErrQuotaExceeded
+		}
+
+		err = s.removeOldestDataFile(false)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// getDiskUsage returns the total size in bytes of the files in the database folder. Snapshots
+// are left out since their files are links to the data files
+func (s *Store) getDiskUsage() (int64, error) {
+	var usage int64
+	err := filepath.WalkDir(s.dbPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			if path == filepath.Join(s.dbPath, SnapshotsFolderName) {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		usage += info.Size()
+		return nil
+	})
+
+	return usage, err
+}
@@ -0,0 +1,93 @@
+package internal
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuota(t *testing.T) {
+	dbPath, err := filepath.Abs("testQuotaDb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	value := strings.Repeat("v", 100)
+	defer func() { _ = ClearDummyFileDataInDb(dbPath) }()
+
+	t.Run("SetShouldFailOnceTheMaxDatabaseSizeIsReached", func(t *testing.T) {
+		err := ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		store := NewStore(dbPath, 320.0/1024, WithMaxDatabaseSize(2000))
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var lastKey string
+		for i := 0; ; i++ {
+			key := fmt.Sprintf("key-%d", i)
+			err = store.Set(key, value)
+			if err != nil {
+				lastKey = key
+				break
+			}
+		}
+
+		assert.ErrorIs(t, err, ErrQuotaExceeded)
+		assert.False(t, store.Has(lastKey))
+		usage, err := store.getDiskUsage()
+		assert.Nil(t, err)
+		assert.LessOrEqual(t, usage, int64(2000))
+
+		// deletes and gets still work
+		got, err := store.Get("key-0")
+		assert.Nil(t, err)
+		assert.Equal(t, value, got)
+		assert.Nil(t, store.Delete("key-0"))
+	})
+
+	t.Run("WithQuotaEvictionSetShouldEvictTheOldestDataFiles", func(t *testing.T) {
+		err := ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		store := NewStore(dbPath, 0.5, WithMaxDatabaseSize(3000), WithQuotaEviction(true))
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for i := 0; i < 50; i++ {
+			err = store.Set(fmt.Sprintf("key-%d", i), value)
+			assert.Nil(t, err)
+		}
+
+		usage, err := store.getDiskUsage()
+		assert.Nil(t, err)
+		assert.LessOrEqual(t, usage, int64(3000))
+		assert.False(t, store.Has("key-0"))
+		got, err := store.Get("key-49")
+		assert.Nil(t, err)
+		assert.Equal(t, value, got)
+	})
+
+	t.Run("WithQuotaEvictionSetShouldFailIfThereIsNothingLeftToEvict", func(t *testing.T) {
+		err := ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		store := NewStore(dbPath, 320.0/1024, WithMaxDatabaseSize(100), WithQuotaEviction(true))
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = store.Set("cow", value)
+		assert.ErrorIs(t, err, ErrQuotaExceeded)
+	})
+}
@@ -0,0 +1,211 @@
+package internal
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+)
+
+// lengthPrefixedFormatVersion is the format version from which records are length-prefixed instead of
+// being separated by the separators, so that keys and values may contain any bytes
+const lengthPrefixedFormatVersion = 2
+
+// encodeRecord encodes the fields as a record of a file i.e. "<field><KeyValue><field><Token>", or
+// "<len>:<field><len>:<field>\n" in the length-prefixed format
+func (sep Separators) encodeRecord(fields ...string) string {
+	var record strings.Builder
+	sep.writeRecord(&record, fields...)
+	return record.String()
+}
+
+// writeRecord writes the fields to b as encodeRecord encodes them, so that files of many records are encoded
+// without building each record on its own first
+func (sep Separators) writeRecord(b *strings.Builder, fields ...string) {
+	if !sep.lengthPrefixed {
+		for i, field := range fields {
+			if i > 0 {
+				b.WriteString(sep.KeyValue)
+			}
+			b.WriteString(field)
+		}
+
+		b.WriteString(sep.Token)
+		return
+	}
+
+	for _, field := range fields {
+		b.WriteString(strconv.Itoa(len(field)))
+		b.WriteByte(':')
+		b.WriteString(field)
+	}
+
+	b.WriteByte('\n')
+}
+
+// extractRecords extracts the records of n fields each from a byte array
+func (sep Separators) extractRecords(data []byte, n int) ([][]string, error) {
+	if sep.lengthPrefixed {
+		records, end, err := parseLengthPrefixedRecords(data)
+		if err != nil || end != len(data) {
+			return nil, ErrCorruptedData
+		}
+
+		for _, fields := range records {
+			if len(fields) != n {
+				return nil, ErrCorruptedData
+			}
+		}
+
+		return records, nil
+	}
+
+	dataAsStr := strings.TrimSuffix(string(data), sep.Token)
+	if dataAsStr == "" {
+		return [][]string{}, nil
+	}
+
+	tokens := strings.Split(dataAsStr, sep.Token)
+	records := make([][]string, len(tokens))
+	for i, token := range tokens {
+		fields := []string{token}
+		if n > 1 {
+			fields = strings.Split(token, sep.KeyValue)
+		}
+
+		if len(fields) != n {
+			return nil, ErrCorruptedData
+		}
+
+		records[i] = fields
+	}
+
+	return records, nil
+}
+
+// extractValidRecords extracts the records of n fields each from a byte array, skipping those that are malformed,
+// cut short or rejected by isValid, if it is not nil. It returns the byte offsets at which the skipped records start.
+// A malformed length-prefixed record is skipped up to the end of its line, where parsing resumes
+func (sep Separators) extractValidRecords(data []byte, n int, isValid func(fields []string) bool) ([][]string, []int) {
+	records := [][]string{}
+	var skipped []int
+	accept := func(fields []string, offset int) {
+		if len(fields) != n || (isValid != nil && !isValid(fields)) {
+			skipped = append(skipped, offset)
+			return
+		}
+
+		records = append(records, fields)
+	}
+
+	if sep.lengthPrefixed {
+		for offset := 0; offset < len(data); {
+			fields, size, err := parseLengthPrefixedRecord(data[offset:])
+			if err == nil && size == 0 {
+				skipped = append(skipped, offset)
+				break
+			}
+
+			if err != nil {
+				skipped = append(skipped, offset)
+				next := bytes.IndexByte(data[offset:], '\n')
+				if next < 0 {
+					break
+				}
+
+				offset += next + 1
+				continue
+			}
+
+			accept(fields, offset)
+			offset += size
+		}
+
+		return records, skipped
+	}
+
+	dataAsStr := strings.TrimSuffix(string(data), sep.Token)
+	if dataAsStr == "" {
+		return records, nil
+	}
+
+	offset := 0
+	for _, token := range strings.Split(dataAsStr, sep.Token) {
+		fields := []string{token}
+		if n > 1 {
+			fields = strings.Split(token, sep.KeyValue)
+		}
+
+		accept(fields, offset)
+		offset += len(token) + len(sep.Token)
+	}
+
+	return records, skipped
+}
+
+// parseLengthPrefixedRecords parses the complete length-prefixed records at the start of data, returning them
+// and where the last of them ends. It stops at a record cut short, which only a torn write leaves behind, and
+// fails with an ErrCorruptedData error on anything else that is not a record
+func parseLengthPrefixedRecords(data []byte) ([][]string, int, error) {
+	records := [][]string{}
+	end := 0
+	for end < len(data) {
+		fields, size, err := parseLengthPrefixedRecord(data[end:])
+		if err != nil {
+			return nil, 0, err
+		}
+
+		if size == 0 {
+			break
+		}
+
+		records = append(records, fields)
+		end += size
+	}
+
+	return records, end, nil
+}
+
+// parseLengthPrefixedRecord parses the length-prefixed record at the start of data, returning its fields and
+// its size, which is zero if the record is cut short. It fails with an ErrCorruptedData error if it is not a record
+func parseLengthPrefixedRecord(data []byte) ([]string, int, error) {
+	var fields []string
+	for i := 0; i < len(data); {
+		if data[i] == '\n' {
+			return fields, i + 1, nil
+		}
+
+		colon := bytes.IndexByte(data[i:], ':')
+		if colon < 0 {
+			if !isDigits(data[i:]) {
+				return nil, 0, ErrCorruptedData
+			}
+			break
+		}
+
+		length, err := strconv.Atoi(string(data[i : i+colon]))
+		if err != nil || length < 0 || !isDigits(data[i:i+colon]) {
+			return nil, 0, ErrCorruptedData
+		}
+
+		start := i + colon + 1
+		if len(data)-start < length {
+			break
+		}
+
+		fields = append(fields, string(data[start:start+length]))
+		i = start + length
+	}
+
+	return nil, 0, nil
+}
+
+// isDigits checks if data is made of decimal digits only
+func isDigits(data []byte) bool {
+	for _, b := range data {
+		if b < '0' || b > '9' {
+			return false
+		}
+	}
+
+	return true
+}
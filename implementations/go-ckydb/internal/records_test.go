@@ -0,0 +1,64 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecords(t *testing.T) {
+	lengthPrefixed := DefaultSeparators
+	lengthPrefixed.lengthPrefixed = true
+
+	t.Run("EncodeRecordShouldSeparateOrLengthPrefixTheFields", func(t *testing.T) {
+		assert.Equal(t, "cow><?&(^#500 months$%#@*&^&", DefaultSeparators.encodeRecord("cow", "500 months"))
+		assert.Equal(t, "3:cow10:500 months\n", lengthPrefixed.encodeRecord("cow", "500 months"))
+		assert.Equal(t, "0:\n", lengthPrefixed.encodeRecord(""))
+	})
+
+	t.Run("ExtractRecordsShouldRoundTripFieldsContainingAnyBytes", func(t *testing.T) {
+		fields := [][]string{{"cow", "a$%#@*&^&b"}, {"", "7:\n3:x\n"}, {"1:", ""}}
+		data := ""
+		for _, record := range fields {
+			data += lengthPrefixed.encodeRecord(record...)
+		}
+
+		records, err := lengthPrefixed.extractRecords([]byte(data), 2)
+		assert.Nil(t, err)
+		assert.Equal(t, fields, records)
+
+		_, err = lengthPrefixed.extractRecords([]byte(data), 1)
+		assert.Equal(t, ErrCorruptedData, err)
+	})
+
+	t.Run("ExtractRecordsShouldFailOnCorruptData", func(t *testing.T) {
+		for _, data := range []string{"3:cow", "x:cow\n", "-1:\n", "3:cowmoo\n", "3:cow10:500 months"} {
+			_, err := lengthPrefixed.extractRecords([]byte(data), 2)
+			assert.Equal(t, ErrCorruptedData, err, data)
+		}
+
+		_, err := DefaultSeparators.extractRecords([]byte("cow$%#@*&^&"), 2)
+		assert.Equal(t, ErrCorruptedData, err)
+	})
+
+	t.Run("ExtractValidRecordsShouldSkipTheMalformedRecords", func(t *testing.T) {
+		data := "3:cow10:500 months\nx:dog\n3:hen4:1 mo\n3:pig"
+		records, skipped := lengthPrefixed.extractValidRecords([]byte(data), 2, nil)
+		assert.Equal(t, [][]string{{"cow", "500 months"}, {"hen", "1 mo"}}, records)
+		assert.Equal(t, []int{19, 37}, skipped)
+
+		data = "cow><?&(^#500 months$%#@*&^&goat$%#@*&^&hen><?&(^#1 mo$%#@*&^&"
+		records, skipped = DefaultSeparators.extractValidRecords([]byte(data), 2, func(fields []string) bool {
+			return fields[0] != "hen"
+		})
+		assert.Equal(t, [][]string{{"cow", "500 months"}}, records)
+		assert.Equal(t, []int{28, 40}, skipped)
+	})
+
+	t.Run("WithoutTornRecordShouldCutTheLengthPrefixedRecordCutShort", func(t *testing.T) {
+		data := "3:cow10:500 months\n3:dog9:23 mo"
+		assert.Equal(t, "3:cow10:500 months\n", string(lengthPrefixed.withoutTornRecord([]byte(data))))
+		assert.Equal(t, "3:cow10:500 months\n", string(lengthPrefixed.withoutTornRecord([]byte("3:cow10:500 months\n3"))))
+		assert.Equal(t, "", string(lengthPrefixed.withoutTornRecord([]byte("3:co"))))
+	})
+}
@@ -0,0 +1,21 @@
+package internal
+
+import "strings"
+
+// ReservedKeyPrefix starts the keys reserved for the bookkeeping of ckydb itself. They cannot be set, deleted
+// or given an expiry, and are left out of Keys, so that e.g. deleting every key that Keys returns leaves them be
+const ReservedKeyPrefix = "__ckydb__"
+
+// isReservedKey checks whether the key is in the namespace reserved for ckydb
+func isReservedKey(key string) bool {
+	return strings.HasPrefix(key, ReservedKeyPrefix)
+}
+
+// checkUserKey returns an ErrReservedKey error if the key, given to a write, is in the reserved namespace
+func checkUserKey(key string) error {
+	if isReservedKey(key) {
+		return ErrReservedKey
+	}
+
+	return nil
+}
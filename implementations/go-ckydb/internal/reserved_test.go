@@ -0,0 +1,94 @@
+package internal
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReservedKeys(t *testing.T) {
+	dbPath, err := filepath.Abs("testReservedKeysDb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reservedKey := ReservedKeyPrefix + "marker"
+	defer func() { _ = ClearDummyFileDataInDb(dbPath) }()
+
+	// newStore returns a loaded store on the dummy data with a reserved key saved the way ckydb itself would
+	newStore := func(t *testing.T) *Store {
+		err := ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = AddDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		store := NewStore(dbPath, 4)
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		stored, err := store.encodeValue("1", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = store.saveStoredValue(reservedKey, stored, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return store
+	}
+
+	t.Run("ReservedKeysShouldNotBeWritable", func(t *testing.T) {
+		store := newStore(t)
+		assert.ErrorIs(t, store.Set(ReservedKeyPrefix+"new", "1"), ErrReservedKey)
+		assert.ErrorIs(t, store.SetWithTTL(ReservedKeyPrefix+"new", "1", time.Hour), ErrReservedKey)
+		assert.ErrorIs(t, store.Set(reservedKey, "2"), ErrReservedKey)
+		assert.ErrorIs(t, store.Delete(reservedKey), ErrReservedKey)
+		assert.ErrorIs(t, store.Expire(reservedKey, time.Hour), ErrReservedKey)
+		assert.ErrorIs(t, store.Persist(reservedKey), ErrReservedKey)
+		assert.False(t, store.Has(ReservedKeyPrefix+"new"))
+
+		value, err := store.Get(reservedKey)
+		assert.Nil(t, err)
+		assert.Equal(t, "1", value)
+	})
+
+	t.Run("KeysShouldLeaveOutReservedKeys", func(t *testing.T) {
+		store := newStore(t)
+		assert.True(t, store.Has(reservedKey))
+		assert.Equal(t, []string{"cow", "dog", "fish", "goat", "hen", "pig"}, store.Keys())
+
+		// deleting every key listed leaves the reserved key be
+		for _, key := range store.Keys() {
+			err := store.Delete(key)
+			assert.Nil(t, err)
+		}
+		assert.Empty(t, store.Keys())
+		assert.True(t, store.Has(reservedKey))
+	})
+
+	t.Run("SnapshotShouldLeaveOutReservedKeys", func(t *testing.T) {
+		store := newStore(t)
+		snap, err := store.Snapshot()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = snap.Close() }()
+
+		var keys []string
+		err = snap.ForEach(func(key string, value string) error {
+			keys = append(keys, key)
+			return nil
+		})
+		assert.Nil(t, err)
+		assert.ElementsMatch(t, store.Keys(), keys)
+		assert.Equal(t, len(store.Keys()), snap.Len())
+	})
+}
@@ -0,0 +1,365 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+const ArchiveFolderName = "archive"
+
+type RetentionAction int
+
+const (
+	// RetentionMerge merges the oldest data file into its next neighbour's range
+	// so that the number of data files reduces by one
+	RetentionMerge RetentionAction = iota
+	// RetentionArchive moves the oldest data file into the archive folder
+	// of the database, removing its keys from the index
+	RetentionArchive
+	// RetentionDelete deletes the oldest data file together with its keys
+	RetentionDelete
+)
+
+// RetentionPolicy limits the number or total size of the data files ('.cky')
+// kept in the database folder
+type RetentionPolicy struct {
+	// MaxDataFiles is the maximum number of data files allowed. Zero means no limit
+	MaxDataFiles int
+	// MaxTotalSizeKB is the maximum combined size of all data files allowed.
+	// Zero means no limit. This limit is ignored for RetentionMerge since merging
+	// does not reclaim any space
+	MaxTotalSizeKB float64
+	// Action is what is done to the oldest data file(s) when any of the limits is exceeded
+	Action RetentionAction
+	// MinIdle, if set, is how long the oldest data file must have gone without its keys being read or set, since
+	// the store was loaded, before the Action is applied to it. Until then, the limits are left exceeded
+	MinIdle time.Duration
+	// BeforeEvict, if set, is called with the path of the oldest data file before the
+	// Action is applied to it. Returning false vetoes the action, leaving the files as they are
+	BeforeEvict func(dataFilePath string) bool
+}
+
+// WithRetentionPolicy sets the policy used to limit the data files kept by the store
+func WithRetentionPolicy(policy RetentionPolicy) StoreOption {
+	return func(s *Store) {
+		s.retentionPolicy = &policy
+	}
+}
+
+// WithDroppedKeyHandler sets the function called with the original casing of every key that the store drops
+// together with the data file holding it, as retention and the quota do, rather than on a Delete
+func WithDroppedKeyHandler(fn func(key string)) StoreOption {
+	return func(s *Store) {
+		s.onDroppedKey = fn
+	}
+}
+
+// WithRetention sets the period after which data files are dropped. A data file is dropped
+// only when every key in it is older than the retention period
+func WithRetention(period time.Duration) StoreOption {
+	return func(s *Store) {
+		s.retentionPeriod = period
+	}
+}
+
+// EnforceRetention drops any data files that are past the retention period and
+// enforces the retention policy of the store, if any
+func (s *Store) EnforceRetention() error {
+	return s.guardWrite(s.enforceRetention)
+}
+
+// enforceRetention is EnforceRetention without the guard against writing to a failing disk
+func (s *Store) enforceRetention() error {
+	err := s.dropExpiredDataFiles()
+	if err != nil {
+		return err
+	}
+
+	return s.enforceRetentionPolicy()
+}
+
+// dropExpiredDataFiles deletes the oldest data files whose keys are all older
+// than the retention period
+func (s *Store) dropExpiredDataFiles() error {
+	if s.retentionPeriod <= 0 {
+		return nil
+	}
+
+	cutOff := s.clock.Now().Add(-s.retentionPeriod).UnixNano()
+	for len(s.dataFiles) > 0 {
+		// all keys in a data file are older than the data file or log file after it
+		nextTimestamp := s.currentLogFile
+		if len(s.dataFiles) > 1 {
+			nextTimestamp = s.dataFiles[1]
+		}
+
+		timestamp, err := strconv.ParseInt(nextTimestamp, 10, 64)
+		if err != nil {
+			return ErrCorruptedData
+		}
+
+		if timestamp > cutOff {
+			return nil
+		}
+
+		err = s.removeOldestDataFile(false)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// enforceRetentionPolicy merges, archives or deletes the oldest data files
+// till the retention policy of the store is no longer violated
+func (s *Store) enforceRetentionPolicy() error {
+	if s.retentionPolicy == nil {
+		return nil
+	}
+
+	for {
+		isExceeded, err := s.isRetentionPolicyExceeded()
+		if err != nil || !isExceeded {
+			return err
+		}
+
+		if !s.isIdle(s.dataFiles[0]) {
+			return nil
+		}
+
+		oldestDataFilePath := s.getDataFilePath(s.dataFiles[0])
+		if s.retentionPolicy.BeforeEvict != nil && !s.retentionPolicy.BeforeEvict(oldestDataFilePath) {
+			return nil
+		}
+
+		switch s.retentionPolicy.Action {
+		case RetentionMerge:
+			err = s.mergeDataFiles(0, nil)
+		case RetentionArchive:
+			err = s.removeOldestDataFile(true)
+		default:
+			err = s.removeOldestDataFile(false)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// isRetentionPolicyExceeded checks whether the data files on disk are more or bigger
+// than the retention policy allows
+func (s *Store) isRetentionPolicyExceeded() (bool, error) {
+	policy := s.retentionPolicy
+	numOfDataFiles := len(s.dataFiles)
+
+	if policy.Action == RetentionMerge {
+		return policy.MaxDataFiles > 0 && numOfDataFiles > policy.MaxDataFiles && numOfDataFiles > 1, nil
+	}
+
+	if numOfDataFiles == 0 {
+		return false, nil
+	}
+
+	if policy.MaxDataFiles > 0 && numOfDataFiles > policy.MaxDataFiles {
+		return true, nil
+	}
+
+	if policy.MaxTotalSizeKB > 0 {
+		totalSize := 0.0
+		for _, dataFile := range s.dataFiles {
+			size, err := GetFileSize(s.getDataFilePath(dataFile))
+			if err != nil {
+				return false, err
+			}
+
+			totalSize += size
+		}
+
+		return totalSize > policy.MaxTotalSizeKB, nil
+	}
+
+	return false, nil
+}
+
+// mergeDataFiles merges the data file after the i-th data file into the i-th data file,
+// and removes the data file after the i-th data file
+func (s *Store) mergeDataFiles(i int, st *OpStats) error {
+	oldestDataFilePath := s.getDataFilePath(s.dataFiles[i])
+	nextDataFilePath := s.getDataFilePath(s.dataFiles[i+1])
+
+	oldestData, err := s.separators.readKeyValuesFromFile(oldestDataFilePath)
+	if err != nil {
+		return err
+	}
+
+	nextData, err := s.separators.readKeyValuesFromFile(nextDataFilePath)
+	if err != nil {
+		return err
+	}
+
+	for k, v := range nextData {
+		oldestData[k] = v
+	}
+
+	err = s.persistMapDataToFile(oldestData, oldestDataFilePath)
+	if err != nil {
+		return err
+	}
+	st.recordFileRewrite(oldestDataFilePath)
+
+	err = s.fs.Remove(nextDataFilePath)
+	if err != nil {
+		return err
+	}
+	st.recordFileRemoval(nextDataFilePath)
+	s.removeBucketIfEmpty(nextDataFilePath)
+
+	err = s.removeDataFileHash(s.dataFiles[i+1])
+	if err != nil {
+		return err
+	}
+
+	s.fileAccess.merge(s.dataFiles[i+1], s.dataFiles[i])
+	s.dataFiles = append(s.dataFiles[:i+1], s.dataFiles[i+2:]...)
+	s.resetCache()
+	return nil
+}
+
+// removeOldestDataFile removes the oldest data file from the database folder
+// and removes its keys from the index. If shouldArchive is true, the file
+// is moved into the archive folder instead of being deleted
+func (s *Store) removeOldestDataFile(shouldArchive bool) error {
+	dataFile := s.dataFiles[0]
+	dataFilePath := s.getDataFilePath(dataFile)
+
+	data, err := s.separators.readKeyValuesFromFile(dataFilePath)
+	if err != nil {
+		return err
+	}
+
+	err = s.removeTimestampedKeysFromIndex(data)
+	if err != nil {
+		return err
+	}
+
+	if shouldArchive {
+		archivePath := filepath.Join(s.dbPath, ArchiveFolderName)
+		err = os.MkdirAll(archivePath, 0777)
+		if err != nil {
+			return err
+		}
+
+		err = s.fs.Rename(dataFilePath, filepath.Join(archivePath, filepath.Base(dataFilePath)))
+	} else {
+		err = s.fs.Remove(dataFilePath)
+	}
+
+	if err != nil {
+		return err
+	}
+	s.removeBucketIfEmpty(dataFilePath)
+
+	err = s.removeDataFileHash(dataFile)
+	if err != nil {
+		return err
+	}
+
+	s.fileAccess.remove(dataFile)
+	s.dataFiles = s.dataFiles[1:]
+	s.resetCache()
+	return nil
+}
+
+// removeTimestampedKeysFromIndex removes the keys, whose timestamped keys are in the given map, from the index
+// and the index file, and forgets them as Delete does, but for their records, which are dropped with their data file
+func (s *Store) removeTimestampedKeysFromIndex(data map[string]string) error {
+	var keys []string
+	for timestampedKey := range data {
+		key := extractKeyFromTimestampedKey(timestampedKey)
+		if s.index[key] == timestampedKey {
+			keys = append(keys, key)
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil
+	}
+
+	err := s.flushIndex(nil)
+	if err != nil {
+		return err
+	}
+
+	err = s.deleteKeyValuesFromFile(s.indexFilePath, keys)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		delete(s.index, key)
+	}
+
+	return s.forgetDroppedKeys(keys)
+}
+
+// forgetDroppedKeys forgets the usage, expiries and casings of the keys dropped from the index, records their
+// deletion in the history and the oplog, as deleteWithStats does for a single key, and reports them to the
+// handler of WithDroppedKeyHandler. The expiry and casing files are each rewritten once for all of the keys
+func (s *Store) forgetDroppedKeys(keys []string) error {
+	originals := make([]string, len(keys))
+	var withExpiry, withCasing []string
+	for i, key := range keys {
+		s.forgetKey(key)
+		originals[i] = s.originalKey(key)
+		if _, ok := s.expiries[key]; ok {
+			withExpiry = append(withExpiry, key)
+		}
+		if _, ok := s.casings[key]; ok {
+			withCasing = append(withCasing, key)
+		}
+	}
+
+	if len(withExpiry) > 0 {
+		err := s.deleteKeyValuesFromFile(s.expiryFilePath, withExpiry)
+		if err != nil {
+			return err
+		}
+
+		for _, key := range withExpiry {
+			delete(s.expiries, key)
+		}
+	}
+
+	if len(withCasing) > 0 {
+		err := s.deleteKeyValuesFromFile(s.casingFilePath, withCasing)
+		if err != nil {
+			return err
+		}
+
+		for _, key := range withCasing {
+			delete(s.casings, key)
+		}
+	}
+
+	for i, key := range keys {
+		err := s.recordVersion(key, "", true, nil)
+		if err != nil {
+			return err
+		}
+
+		err = s.appendToOplog(OplogDelete, key, "")
+		if err != nil {
+			return err
+		}
+
+		if s.onDroppedKey != nil {
+			s.onDroppedKey(originals[i])
+		}
+	}
+
+	return nil
+}
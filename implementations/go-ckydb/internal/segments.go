@@ -0,0 +1,104 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// SegmentInfo describes a data file of the store
+type SegmentInfo struct {
+	// Name is the name of the data file e.g. "1655375120328185000.cky"
+	Name string
+	// Start and End bound the creation times of the keys in the data file, from Start up to but excluding End,
+	// which is the start of the next data file or of the current log file
+	Start time.Time
+	End   time.Time
+	// Keys is the number of records in the data file, including those of keys deleted but not yet vacuumed
+	Keys int
+	// Size is the size of the data file in bytes
+	Size int64
+	// CompressedValues is the number of values in the data file that are compressed,
+	// see WithValueCompressionThreshold
+	CompressedValues int
+}
+
+// Segments returns the data files of the store, from the oldest to the newest. Each data file is read
+// to count its records, without loading it into the cache
+func (s *Store) Segments() ([]SegmentInfo, error) {
+	segments := make([]SegmentInfo, 0, len(s.dataFiles))
+	for i, dataFile := range s.dataFiles {
+		end := s.currentLogFile
+		if i+1 < len(s.dataFiles) {
+			end = s.dataFiles[i+1]
+		}
+
+		segment, err := s.describeSegment(dataFile, end)
+		if err != nil {
+			return nil, err
+		}
+
+		segments = append(segments, segment)
+	}
+
+	return segments, nil
+}
+
+// describeSegment returns the SegmentInfo of the data file whose keys are timestamped from start up to end
+func (s *Store) describeSegment(start string, end string) (SegmentInfo, error) {
+	startTime, err := parseTimestamp(start)
+	if err != nil {
+		return SegmentInfo{}, err
+	}
+
+	endTime, err := parseTimestamp(end)
+	if err != nil {
+		return SegmentInfo{}, err
+	}
+
+	data, err := os.ReadFile(s.getDataFilePath(start))
+	if err != nil {
+		return SegmentInfo{}, err
+	}
+
+	records, err := s.separators.extractKeyValues(data)
+	if err != nil {
+		return SegmentInfo{}, err
+	}
+
+	segment := SegmentInfo{
+		Name:  fmt.Sprintf("%s.%s", start, DataFileExt),
+		Start: startTime,
+		End:   endTime,
+		Keys:  len(records),
+		Size:  int64(len(data)),
+	}
+	if !s.valueFlags {
+		return segment, nil
+	}
+
+	for timestampedKey, sealed := range records {
+		// tampered records are left to Verify to report
+		stored, err := unsealRecord(timestampedKey, sealed, s.recordMACKey())
+		if err != nil {
+			continue
+		}
+
+		if stored != "" && stored[0] == compressedValueFlag {
+			segment.CompressedValues++
+		}
+	}
+
+	return segment, nil
+}
+
+// parseTimestamp returns the time of the given timestamp, in nanoseconds, of a file or timestamped key
+func parseTimestamp(timestamp string) (time.Time, error) {
+	nanoseconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return time.Time{}, ErrCorruptedData
+	}
+
+	return time.Unix(0, nanoseconds), nil
+}
@@ -0,0 +1,86 @@
+package internal
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSegments(t *testing.T) {
+	dbPath, err := filepath.Abs("testSegmentsDb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = ClearDummyFileDataInDb(dbPath) }()
+
+	t.Run("SegmentsShouldDescribeEveryDataFile", func(t *testing.T) {
+		err := ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = AddDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		store := NewStore(dbPath, 4)
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		segments, err := store.Segments()
+		assert.Nil(t, err)
+		assert.Equal(t, []SegmentInfo{
+			{
+				Name:  "1655375120328185000.cky",
+				Start: time.Unix(0, 1655375120328185000),
+				End:   time.Unix(0, 1655375120328186000),
+				Keys:  2,
+				Size:  int64(len(dummyDataFileMap["1655375120328185000.cky"])),
+			},
+			{
+				// bar, the only key of the data file, was vacuumed on Load
+				Name:  "1655375120328186000.cky",
+				Start: time.Unix(0, 1655375120328186000),
+				End:   time.Unix(0, 1655375171402014000),
+			},
+		}, segments)
+	})
+
+	t.Run("SegmentsShouldCountTheCompressedValues", func(t *testing.T) {
+		err := ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		store := NewStore(dbPath, 0.1, WithValueCompressionThreshold(64))
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		segments, err := store.Segments()
+		assert.Nil(t, err)
+		assert.Empty(t, segments)
+
+		assert.Nil(t, store.Set("blob", strings.Repeat("a value that compresses well ", 100)))
+		assert.Nil(t, store.Set("cow", "500 months"))
+		for len(store.dataFiles) == 0 {
+			assert.Nil(t, store.Set("filler", strings.Repeat("x", 50)))
+		}
+
+		segments, err = store.Segments()
+		assert.Nil(t, err)
+		assert.Len(t, segments, len(store.dataFiles))
+		compressed := 0
+		for _, segment := range segments {
+			compressed += segment.CompressedValues
+		}
+		assert.Equal(t, 1, compressed)
+		assert.Equal(t, 1, segments[0].CompressedValues, "blob is the first key set")
+	})
+}
@@ -0,0 +1,203 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const MetadataFilename = "format.meta"
+
+const (
+	metaTokenSeparator    = "token_separator"
+	metaKeyValueSeparator = "key_value_separator"
+	metaValueFlags        = "value_flags"
+	metaRecordHMAC        = "record_hmac"
+)
+
+// Separators are the byte sequences that separate the records in the files of a database,
+// and the key from the value in each record. Keys and values may not contain either of them
+type Separators struct {
+	// Token ends every record
+	Token string
+	// KeyValue separates the key from the value of a record
+	KeyValue string
+	// lengthPrefixed is true in databases of the length-prefixed format version, whose records
+	// are not separated by the separators
+	lengthPrefixed bool
+	// compactIndex is true in databases of the compact index format version, whose index holds
+	// the timestamp of each key instead of its timestamped key
+	compactIndex bool
+}
+
+// DefaultSeparators are the separators of databases created without WithSeparators, and of those
+// created before the separators were recorded in the metadata file. All implementations use them
+var DefaultSeparators = Separators{Token: TokenSeparator, KeyValue: KeyValueSeparator}
+
+// WithSeparators sets the separators of databases created by the store. Existing databases keep
+// the separators they were created with, as recorded in their metadata file
+func WithSeparators(sep Separators) StoreOption {
+	return func(s *Store) {
+		s.separators = sep
+	}
+}
+
+// metadata is what the metadata file records about the format of a database
+type metadata struct {
+	separators Separators
+	// valueFlags is true if every value in the log and data files starts with a flag byte
+	// telling whether it is compressed
+	valueFlags bool
+	// hmacKeyCheck is the HMAC of a fixed message with the key that the records are authenticated with,
+	// if they are
+	hmacKeyCheck string
+}
+
+// ReadSeparators returns the separators of the database folder at dbPath. Folders without
+// a metadata file use the DefaultSeparators
+func ReadSeparators(dbPath string) (Separators, error) {
+	meta, err := readMetadata(dbPath)
+	return meta.separators, err
+}
+
+// readMetadata returns the metadata of the database folder at dbPath. Folders without
+// a metadata file use the DefaultSeparators and no value flags
+func readMetadata(dbPath string) (metadata, error) {
+	version, err := ReadFormatVersion(dbPath)
+	if err != nil {
+		return metadata{}, err
+	}
+
+	meta := metadata{separators: DefaultSeparators}
+	data, err := os.ReadFile(filepath.Join(dbPath, MetadataFilename))
+	if err == nil {
+		meta, err = parseMetadata(data)
+	} else if os.IsNotExist(err) {
+		err = nil
+	}
+
+	meta.separators.lengthPrefixed = version >= lengthPrefixedFormatVersion
+	meta.separators.compactIndex = version >= compactIndexFormatVersion
+	return meta, err
+}
+
+// validate checks that the separators can be told apart i.e. that they are not empty,
+// and that neither contains the other
+func (sep Separators) validate() error {
+	if sep.Token == "" || sep.KeyValue == "" ||
+		strings.Contains(sep.Token, sep.KeyValue) || strings.Contains(sep.KeyValue, sep.Token) {
+		return ErrInvalidSeparators
+	}
+
+	return nil
+}
+
+// loadMetadata reads the separators of the database, and whether its values are flagged and its records
+// authenticated, from its metadata file, writing the file if it is missing. New databases get the separators
+// the store was configured with, flagged values if it compresses, deduplicates or spills values, and record
+// HMACs if it has an HMAC key, while existing ones without the file were written with the DefaultSeparators,
+// no value flags and no record HMACs
+func (s *Store) loadMetadata() error {
+	version, err := ReadFormatVersion(s.dbPath)
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(s.dbPath, MetadataFilename)
+	data, err := os.ReadFile(path)
+	if err == nil {
+		meta, err := parseMetadata(data)
+		if err != nil {
+			return err
+		}
+
+		meta.separators.lengthPrefixed = version >= lengthPrefixedFormatVersion
+		meta.separators.compactIndex = version >= compactIndexFormatVersion
+		s.separators, s.valueFlags, s.recordMACs = meta.separators, meta.valueFlags, meta.hmacKeyCheck != ""
+		if s.recordMACs {
+			return checkHMACKey(s.hmacKey, meta.hmacKeyCheck)
+		}
+
+		return nil
+	}
+	if !os.IsNotExist(err) {
+		return err
+	}
+
+	_, err = os.Stat(s.indexFilePath)
+	if err == nil {
+		s.separators, s.valueFlags, s.recordMACs = DefaultSeparators, false, false
+	} else if os.IsNotExist(err) {
+		s.valueFlags = s.compressThreshold > 0 || s.dedupThreshold > 0 || s.spillThreshold > 0
+		s.recordMACs = len(s.hmacKey) > 0
+	} else {
+		return err
+	}
+
+	err = s.separators.validate()
+	if err != nil {
+		return err
+	}
+
+	s.separators.lengthPrefixed = version >= lengthPrefixedFormatVersion
+	s.separators.compactIndex = version >= compactIndexFormatVersion
+	meta := metadata{separators: s.separators, valueFlags: s.valueFlags}
+	if s.recordMACs {
+		meta.hmacKeyCheck = computeHMACKeyCheck(s.hmacKey)
+	}
+	return s.replaceFile(path, meta.encode())
+}
+
+// encode returns the contents of the metadata file recording the metadata
+func (meta metadata) encode() []byte {
+	content := fmt.Sprintf("%s %s\n%s %s\n",
+		metaTokenSeparator, strconv.Quote(meta.separators.Token),
+		metaKeyValueSeparator, strconv.Quote(meta.separators.KeyValue))
+	if meta.valueFlags {
+		content += fmt.Sprintf("%s %s\n", metaValueFlags, strconv.Quote("true"))
+	}
+	if meta.hmacKeyCheck != "" {
+		content += fmt.Sprintf("%s %s\n", metaRecordHMAC, strconv.Quote(meta.hmacKeyCheck))
+	}
+	return []byte(content)
+}
+
+// parseMetadata parses the contents of a metadata file, each line of which is a name and
+// its quoted value. Unknown names are ignored so that older versions can read newer files
+func parseMetadata(data []byte) (metadata, error) {
+	meta := metadata{separators: DefaultSeparators}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		name, quoted, ok := strings.Cut(scanner.Text(), " ")
+		if !ok {
+			continue
+		}
+
+		value, err := strconv.Unquote(quoted)
+		if err != nil {
+			return metadata{}, ErrCorruptedData
+		}
+
+		switch name {
+		case metaTokenSeparator:
+			meta.separators.Token = value
+		case metaKeyValueSeparator:
+			meta.separators.KeyValue = value
+		case metaValueFlags:
+			meta.valueFlags = value == "true"
+		case metaRecordHMAC:
+			meta.hmacKeyCheck = value
+		}
+	}
+
+	err := meta.separators.validate()
+	if err != nil {
+		return metadata{}, ErrCorruptedData
+	}
+
+	return meta, nil
+}
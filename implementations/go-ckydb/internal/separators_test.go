@@ -0,0 +1,151 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSeparators(t *testing.T) {
+	dbPath, err := filepath.Abs("testSeparatorsDb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	custom := Separators{Token: "\n", KeyValue: "\t"}
+	valueWithDefaultSeparators := "a" + TokenSeparator + "b" + KeyValueSeparator + "c"
+	defer func() { _ = ClearDummyFileDataInDb(dbPath) }()
+
+	t.Run("NewDatabasesShouldUseAndRecordTheConfiguredSeparators", func(t *testing.T) {
+		err := ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		store := NewStore(dbPath, 320.0/1024, WithSeparators(custom), WithOplog(true))
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Nil(t, store.Set("cow", valueWithDefaultSeparators))
+		assert.Nil(t, store.Set("goat", "678 months"))
+		assert.Nil(t, store.Delete("goat"))
+		assert.ErrorIs(t, store.Set("hen", "5\t67"), ErrInvalidKeyValue)
+
+		sep, err := ReadSeparators(dbPath)
+		assert.Nil(t, err)
+		assert.Equal(t, custom, sep)
+		index, err := ReadFileToString(filepath.Join(dbPath, IndexFilename))
+		assert.Nil(t, err)
+		assert.True(t, strings.HasPrefix(index, "cow\t"))
+
+		// the separators are read from the metadata file, whatever the store is configured with
+		reloaded := NewStore(dbPath, 320.0/1024)
+		err = reloaded.Load()
+		assert.Nil(t, err)
+		value, err := reloaded.Get("cow")
+		assert.Nil(t, err)
+		assert.Equal(t, valueWithDefaultSeparators, value)
+		assert.False(t, reloaded.Has("goat"))
+
+		snap, err := reloaded.Snapshot()
+		assert.Nil(t, err)
+		err = snap.ForEach(func(key string, value string) error {
+			assert.Equal(t, "cow", key)
+			assert.Equal(t, valueWithDefaultSeparators, value)
+			return nil
+		})
+		assert.Nil(t, err)
+		assert.Nil(t, snap.Close())
+
+		var ops []string
+		for entry, err := range ReadOplog(dbPath, 0) {
+			assert.Nil(t, err)
+			ops = append(ops, entry.Op+" "+entry.Key)
+		}
+		assert.Equal(t, []string{"set cow", "set goat", "delete goat"}, ops)
+	})
+
+	t.Run("ClearShouldKeepTheSeparators", func(t *testing.T) {
+		err := ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		store := NewStore(dbPath, 320.0/1024, WithSeparators(custom))
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Nil(t, store.Clear())
+
+		sep, err := ReadSeparators(dbPath)
+		assert.Nil(t, err)
+		assert.Equal(t, custom, sep)
+	})
+
+	t.Run("ExistingDatabasesWithoutMetadataShouldUseTheDefaultSeparators", func(t *testing.T) {
+		err := ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = AddDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sep, err := ReadSeparators(dbPath)
+		assert.Nil(t, err)
+		assert.Equal(t, DefaultSeparators, sep)
+
+		store := NewStore(dbPath, 320.0/1024, WithSeparators(custom))
+		err = store.Load()
+		assert.Nil(t, err)
+		value, err := store.Get("cow")
+		assert.Nil(t, err)
+		assert.Equal(t, "500 months", value)
+
+		content, err := ReadFileToString(filepath.Join(dbPath, MetadataFilename))
+		assert.Nil(t, err)
+		assert.Equal(t, "token_separator \"$%#@*&^&\"\nkey_value_separator \"><?&(^#\"\n", content)
+	})
+
+	t.Run("LoadShouldFailOnInvalidSeparators", func(t *testing.T) {
+		for _, sep := range []Separators{
+			{Token: "", KeyValue: "\t"},
+			{Token: "\n", KeyValue: ""},
+			{Token: "ab", KeyValue: "b"},
+			{Token: "|", KeyValue: "|"},
+		} {
+			err := ClearDummyFileDataInDb(dbPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = NewStore(dbPath, 320.0/1024, WithSeparators(sep)).Load()
+			assert.ErrorIs(t, err, ErrInvalidSeparators)
+		}
+	})
+
+	t.Run("LoadShouldFailOnCorruptMetadata", func(t *testing.T) {
+		err := ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.MkdirAll(dbPath, 0777)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(filepath.Join(dbPath, MetadataFilename), []byte("token_separator \"\"\n"), 0666)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = NewStore(dbPath, 320.0/1024).Load()
+		assert.ErrorIs(t, err, ErrCorruptedData)
+		_, err = ReadSeparators(dbPath)
+		assert.ErrorIs(t, err, ErrCorruptedData)
+	})
+}
@@ -0,0 +1,154 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+const SnapshotsFolderName = "snapshots"
+
+// Snapshot is a read-only view of the store as it was when the snapshot was taken.
+// Its data files are hard links to the store's data files at that time. Since the store
+// replaces files instead of modifying them in place, the links keep the old contents
+// however much the store changes afterwards
+type Snapshot struct {
+	path       string
+	separators Separators
+	valueFlags bool
+	macKey     []byte
+	index      map[string]string
+	memtable   map[string]string
+	dataFiles  []string
+}
+
+// Snapshot takes a snapshot of the store. It only copies the index and the memtable,
+// and links the data files so it is quick even for big stores. Reserved keys are left out of it, as of Keys.
+// The snapshot should be closed when no longer needed
+func (s *Store) Snapshot() (*Snapshot, error) {
+	path := filepath.Join(s.dbPath, SnapshotsFolderName, fmt.Sprintf("%d", s.nextTimestamp()))
+	err := os.MkdirAll(path, 0777)
+	if err != nil {
+		return nil, err
+	}
+
+	snap := &Snapshot{
+		path:       path,
+		separators: s.separators,
+		valueFlags: s.valueFlags,
+		macKey:     s.recordMACKey(),
+		index:      make(map[string]string, len(s.index)),
+		memtable:   make(map[string]string, len(s.memtable)),
+		dataFiles:  append([]string{}, s.dataFiles...),
+	}
+
+	for k, v := range s.index {
+		if !isReservedKey(k) {
+			snap.index[k] = v
+		}
+	}
+
+	for k, v := range s.memtable {
+		snap.memtable[k] = v
+	}
+
+	for _, dataFile := range s.dataFiles {
+		err = linkOrCopyFile(s.getDataFilePath(dataFile), snap.getDataFilePath(dataFile))
+		if err != nil {
+			_ = snap.Close()
+			return nil, err
+		}
+	}
+
+	err = s.linkBlobsTo(path)
+	if err != nil {
+		_ = snap.Close()
+		return nil, err
+	}
+
+	return snap, nil
+}
+
+// Len returns the number of keys in the snapshot
+func (snap *Snapshot) Len() int {
+	return len(snap.index)
+}
+
+// ForEach calls fn with every key-value pair in the snapshot, stopping at the first error.
+// The pairs are read one data file at a time, from the oldest to the newest, so only
+// one data file is held in memory at any time
+func (snap *Snapshot) ForEach(fn func(key string, value string) error) error {
+	for _, dataFile := range snap.dataFiles {
+		data, err := snap.separators.readKeyValuesFromFile(snap.getDataFilePath(dataFile))
+		if err != nil {
+			return err
+		}
+
+		err = snap.forEachLiveKeyValue(data, fn)
+		if err != nil {
+			return err
+		}
+	}
+
+	return snap.forEachLiveKeyValue(snap.memtable, fn)
+}
+
+// Close removes the snapshot's files
+func (snap *Snapshot) Close() error {
+	return os.RemoveAll(snap.path)
+}
+
+// forEachLiveKeyValue calls fn, in the order of keys, with every key-value pair in data
+// whose timestamped key is still in the index i.e. has not been deleted
+func (snap *Snapshot) forEachLiveKeyValue(data map[string]string, fn func(key string, value string) error) error {
+	var timestampedKeys []string
+	for timestampedKey := range data {
+		if snap.index[extractKeyFromTimestampedKey(timestampedKey)] == timestampedKey {
+			timestampedKeys = append(timestampedKeys, timestampedKey)
+		}
+	}
+
+	sort.Slice(timestampedKeys, func(i, j int) bool {
+		return extractKeyFromTimestampedKey(timestampedKeys[i]) < extractKeyFromTimestampedKey(timestampedKeys[j])
+	})
+
+	for _, timestampedKey := range timestampedKeys {
+		stored, err := unsealRecord(timestampedKey, data[timestampedKey], snap.macKey)
+		if err != nil {
+			return err
+		}
+
+		value, err := decodeValue(stored, snap.valueFlags, filepath.Join(snap.path, BlobsFolderName))
+		if err != nil {
+			return err
+		}
+
+		err = fn(extractKeyFromTimestampedKey(timestampedKey), value)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getDataFilePath returns the path to the snapshot's data file of the given timestamp
+func (snap *Snapshot) getDataFilePath(dataFile string) string {
+	return filepath.Join(snap.path, fmt.Sprintf("%s.%s", dataFile, DataFileExt))
+}
+
+// removeSnapshots removes the snapshots left behind by a previous run of the store
+func (s *Store) removeSnapshots() error {
+	return os.RemoveAll(filepath.Join(s.dbPath, SnapshotsFolderName))
+}
+
+// linkOrCopyFile hard links the file at src to dst, copying it if the file system does not support links
+func linkOrCopyFile(src string, dst string) error {
+	err := os.Link(src, dst)
+	if err == nil {
+		return nil
+	}
+
+	return copyFile(src, dst)
+}
@@ -2,12 +2,20 @@ package internal
 
 import (
 	"fmt"
+	"io"
+	"iter"
 	"os"
 	"path/filepath"
+	"runtime"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/singleflight"
 )
 
 const (
@@ -21,48 +29,307 @@ const (
 	KeyValueSeparator = "><?&(^#"
 )
 
+// minParallelLoadFileSizeKB is the size, of some thousand keys, that both the index file and the log file reach
+// before Load parses them in parallel
+const minParallelLoadFileSizeKB = 64
+
 type Storage interface {
 	Load() error
 	Set(key string, value string) error
 	Get(key string) (string, error)
 	Delete(key string) error
 	Clear() error
+	ClearData() error
 	Vacuum() error
+	SetWithStats(key string, value string, st *OpStats) error
+	GetWithStats(key string, st *OpStats) (string, error)
+	GetManyWithStats(keys []string, st *OpStats) []GetResult
+	FindWithStats(predicate func(key string, value string) bool, limit int, st *OpStats) (map[string]string, error)
+	DescribeWithStats(key string, st *OpStats) (KeyInfo, error)
+	SetWithTTLAndStats(key string, value string, ttl time.Duration, st *OpStats) error
+	TTL(key string) (time.Duration, error)
+	ExpireWithStats(key string, ttl time.Duration, st *OpStats) error
+	PersistWithStats(key string, st *OpStats) error
+	GetVersion(key string, at time.Time) (string, error)
+	History(key string, limit int) ([]Version, error)
+	UndeleteWithStats(key string, st *OpStats) (string, error)
+	SetReaderWithStats(key string, r io.Reader, st *OpStats) error
+	GetReaderWithStats(key string, st *OpStats) (io.ReadCloser, error)
+	Verify() ([]TamperedRecord, error)
+	Segments() ([]SegmentInfo, error)
+	QuarantineWithStats(key string, st *OpStats) error
+	Quarantined() ([]QuarantinedRecord, error)
+	SetMeta(key string, value string) error
+	GetMeta(key string) (string, error)
+	DeleteMeta(key string) error
+	MigrateFormatTo(version int) error
+	DeleteWithStats(key string, st *OpStats) error
+	VacuumWithStats(st *OpStats) error
+	Flush() error
+	FlushWithStats(st *OpStats) error
+	Compact() error
+	CompactWithStats(st *OpStats) error
+	ShedMemoryWithStats(st *OpStats) error
+	ThrottledVacuumWithStats(st *OpStats, pause func(bytesRewritten int64)) error
+	ThrottledCompactWithStats(st *OpStats, pause func(bytesRewritten int64)) error
+	EnforceRetention() error
+	ClearedFolders() ([]string, error)
+	GetWhileLoadingWithStats(key string, st *OpStats) (string, error)
+	KeysWhileLoading() ([]string, error)
+	Keys() []string
+	KeysByAge(limit int, ascending bool) ([]string, error)
+	Has(key string) bool
+	Stats() Stats
+	SetMaxFileSize(maxFileSizeKB float64) error
+	MaxFileSize() float64
+	Snapshot() (*Snapshot, error)
+	BackupToDir(path string) error
+	ReadFollowerState() (*FollowerState, error)
+	SwapFollowerState(state *FollowerState)
+	Health() Health
+	ReadOplog(fromSeq uint64) iter.Seq2[OplogEntry, error]
+}
+
+// GetResult is the outcome of getting one of many keys at once
+type GetResult struct {
+	Value string
+	// Found is false if the key does not exist, which is not an error
+	Found bool
+	// Err is the error got retrieving the value of an existing key, if any
+	Err error
+}
+
+// Stats are the statistics of the store at a given point in time
+type Stats struct {
+	Keys        int
+	DataFiles   int
+	CacheHits   int64
+	CacheMisses int64
+	CacheLoads  int64
+
+	RestoredFiles int64
+	EvictedKeys   int64
+	// SkippedRecords is the number of malformed records skipped as Load is not strict
+	SkippedRecords int64
+	// Files are the reads and writes of the data files and the log file, from the oldest to the newest
+	Files []FileStats
+	// HotGets are the gets served from the memtable or the cache, and ColdGets those that read a data file
+	HotGets  int64
+	ColdGets int64
+	// GetBytesRead are the bytes of data files that gets read from disk, and ValueBytesRead those of the values got
+	GetBytesRead   int64
+	ValueBytesRead int64
+	// BytesWritten are the bytes written to disk, and KeyValueBytesWritten those of the keys and values set
+	BytesWritten         int64
+	KeyValueBytesWritten int64
+	// ReadAmplification is GetBytesRead over ValueBytesRead, and WriteAmplification BytesWritten over
+	// KeyValueBytesWritten, both 0 until there is something to divide by
+	ReadAmplification  float64
+	WriteAmplification float64
 }
 
 type Store struct {
-	dbPath             string
-	maxFileSizeKB      float64
-	cache              *Cache
-	memtable           map[string]string
-	index              map[string]string
-	dataFiles          []string
-	currentLogFile     string
-	currentLogFilePath string
-	delFilePath        string
-	indexFilePath      string
-	cacheLock          sync.Mutex
-	delFileLock        sync.Mutex
+	dbPath              string
+	maxFileSizeKB       float64
+	cache               *Cache
+	memtable            map[string]string
+	index               map[string]string
+	dataFiles           []string
+	currentLogFile      string
+	currentLogFilePath  string
+	delFilePath         string
+	indexFilePath       string
+	expiryFilePath      string
+	expiries            map[string]int64
+	maxKeys             int
+	evictionPolicy      EvictionPolicy
+	usage               *usageTracker
+	usageFilePath       string
+	evictedKeys         atomic.Int64
+	fs                  FileSystem
+	checksummedFiles    map[string]*checksummedFile
+	restoredFiles       atomic.Int64
+	clock               Clock
+	lastTimestamp       atomic.Int64
+	retentionPolicy     *RetentionPolicy
+	retentionPeriod     time.Duration
+	cacheHits           atomic.Int64
+	cacheMisses         atomic.Int64
+	cacheLoads          atomic.Int64
+	isOplogEnabled      bool
+	maxDatabaseSize     int64
+	quotaEviction       bool
+	separators          Separators
+	health              Health
+	lastProbe           time.Time
+	healthLock          sync.Mutex
+	oplog               *oplog
+	cacheLoadGroup      singleflight.Group
+	isPrefetchEnabled   bool
+	checkInvariants     bool
+	isFollower          bool
+	prefetched          *Cache
+	cacheGeneration     uint64
+	prefetchWaitGroup   sync.WaitGroup
+	cacheLock           sync.Mutex
+	delFileLock         sync.Mutex
+	historyPolicy       *HistoryPolicy
+	historyFilePath     string
+	history             map[string][]Version
+	trashRetention      time.Duration
+	trashFilePath       string
+	trash               map[string]trashedValue
+	compressThreshold   int
+	dedupThreshold      int
+	spillThreshold      int
+	valueFlags          bool
+	hmacKey             []byte
+	recordMACs          bool
+	metaSegment         map[string]string
+	metaSegmentFilePath string
+	indexBatchSize      int
+	indexBatchDelay     time.Duration
+	pendingIndex        []byte
+	pendingIndexKeys    int
+	pendingIndexSince   time.Time
+	fileAccess          fileAccessTracker
+	// loadLock is held by the reads served while the store is loaded, and by Load whenever it changes the keys
+	// once they are loaded, as noted by areKeysLoaded
+	loadLock            sync.RWMutex
+	areKeysLoaded       bool
+	lenientLoad         bool
+	onSkippedRecord     func(record SkippedRecord)
+	onDroppedKey        func(key string)
+	skippedRecords      atomic.Int64
+	caseInsensitiveKeys bool
+	casingFilePath      string
+	casings             map[string]string
+	skipVacuumOnLoad    bool
+	warmupKeys          []string
+	warmupSegments      int
+	bucketPeriod        time.Duration
+	dataFileFolders     map[string]string
+	isManifestEnabled   bool
+	manifest            map[string]string
+	manifestLock        sync.Mutex
+	io                  ioCounters
+	maintenanceWorkers  int
+}
+
+// StoreOption configures optional behaviour of a Store
+type StoreOption func(*Store)
+
+// WithVacuumOnLoad sets whether Load vacuums the store before loading it, which it does by default.
+// When it is false, Load is quicker on a big database, and the key-values marked for deletion are left
+// on disk until the next Vacuum
+func WithVacuumOnLoad(isEnabled bool) StoreOption {
+	return func(s *Store) {
+		s.skipVacuumOnLoad = !isEnabled
+	}
+}
+
+// WithMaintenanceWorkers sets the number of files that vacuuming rewrites at a time, so that an application sharing
+// its host can cap the CPU that the store takes up. It defaults to half of GOMAXPROCS, and at least 1, as read when
+// vacuuming starts. Compaction merges one pair of data files at a time, so it stays within any number of workers
+func WithMaintenanceWorkers(n int) StoreOption {
+	return func(s *Store) {
+		s.maintenanceWorkers = n
+	}
+}
+
+// getMaintenanceWorkers returns the number of files that vacuuming rewrites at a time, as set by WithMaintenanceWorkers
+func (s *Store) getMaintenanceWorkers() int {
+	if s.maintenanceWorkers > 0 {
+		return s.maintenanceWorkers
+	}
+
+	return max(runtime.GOMAXPROCS(0)/2, 1)
 }
 
 // NewStore initializes a new Store instance for the given dbPath
-func NewStore(dbPath string, maxFileSizeKB float64) *Store {
-	return &Store{
-		dbPath:        dbPath,
-		maxFileSizeKB: maxFileSizeKB,
-		cache:         NewCache(nil, "0", "0"),
-		delFilePath:   filepath.Join(dbPath, DelFilename),
-		indexFilePath: filepath.Join(dbPath, IndexFilename),
+func NewStore(dbPath string, maxFileSizeKB float64, opts ...StoreOption) *Store {
+	delFilePath := filepath.Join(dbPath, DelFilename)
+	indexFilePath := filepath.Join(dbPath, IndexFilename)
+	s := &Store{
+		dbPath:              dbPath,
+		maxFileSizeKB:       maxFileSizeKB,
+		cache:               NewCache(nil, "0", "0"),
+		delFilePath:         delFilePath,
+		indexFilePath:       indexFilePath,
+		expiryFilePath:      filepath.Join(dbPath, ExpiryFilename),
+		usageFilePath:       filepath.Join(dbPath, UsageFilename),
+		historyFilePath:     filepath.Join(dbPath, HistoryFilename),
+		trashFilePath:       filepath.Join(dbPath, TrashFilename),
+		metaSegmentFilePath: filepath.Join(dbPath, MetaSegmentFilename),
+		casingFilePath:      filepath.Join(dbPath, CasingFilename),
+		checksummedFiles:    map[string]*checksummedFile{},
+		fs:                  osFileSystem{},
+		clock:               RealClock,
+		separators:          DefaultSeparators,
 	}
+	s.checksummedFiles[delFilePath] = &checksummedFile{path: delFilePath, isValid: s.isValidDel}
+	s.checksummedFiles[indexFilePath] = &checksummedFile{path: indexFilePath, isValid: s.isValidIndex}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.fs = countingFileSystem{FileSystem: s.fs, bytesWritten: &s.io.bytesWritten}
+
+	return s
 }
 
 // Load loads the storage from disk
 func (s *Store) Load() error {
+	s.setKeysLoaded(false)
+	s.fileAccess.reset(s.clock.Now())
+	if s.isFollower {
+		return s.loadFollower()
+	}
+
 	err := os.MkdirAll(s.dbPath, 0777)
 	if err != nil {
 		return err
 	}
 
+	err = s.checkFormatVersion()
+	if err != nil {
+		return err
+	}
+
+	err = s.loadMetadata()
+	if err != nil {
+		return err
+	}
+
+	err = s.removeTempFiles()
+	if err != nil {
+		return err
+	}
+
+	err = s.loadManifest()
+	if err != nil {
+		return err
+	}
+
+	// any buffered index entries are left to recoverIndex, as the index is reloaded from disk
+	s.pendingIndex, s.pendingIndexKeys = nil, 0
+
+	// the index and del files are checked before anything is appended to them
+	err = s.loadChecksummedFile(s.indexFilePath)
+	if err != nil {
+		return err
+	}
+
+	err = s.loadChecksummedFile(s.delFilePath)
+	if err != nil {
+		return err
+	}
+
+	err = s.removeSnapshots()
+	if err != nil {
+		return err
+	}
+
 	err = s.createIndexFileIfNotExists()
 	if err != nil {
 		return err
@@ -73,205 +340,730 @@ func (s *Store) Load() error {
 		return err
 	}
 
+	err = s.repairTornRecord(s.indexFilePath)
+	if err != nil {
+		return err
+	}
+
+	err = s.repairTornRecord(s.delFilePath)
+	if err != nil {
+		return err
+	}
+
 	err = s.createLogFileIfNotExists()
 	if err != nil {
 		return err
 	}
 
-	err = s.Vacuum()
+	err = s.loadOplog()
 	if err != nil {
 		return err
 	}
 
+	// the files that Clear moved aside are left to the next Vacuum, as Clear reloads the store
+	if !s.skipVacuumOnLoad {
+		err = s.guardWrite(func() error { return s.vacuumWithStats(nil) })
+		if err != nil {
+			return err
+		}
+	}
+
 	err = s.loadFilePropsFromDisk()
 	if err != nil {
 		return err
 	}
 
-	err = s.loadIndexFromDisk()
+	err = s.completeManifest()
 	if err != nil {
 		return err
 	}
 
-	err = s.loadMemtableFromDisk()
-	return err
+	err = s.loadIndexAndMemtableFromDisk()
+	if err != nil {
+		return err
+	}
+
+	err = s.recoverIndex()
+	if err != nil {
+		return err
+	}
+	s.internTimestampedKeys()
+
+	err = s.loadExpiriesFromDisk()
+	if err != nil {
+		return err
+	}
+
+	s.setKeysLoaded(true)
+	err = failpoint(FailpointDuringLoad)
+	if err != nil {
+		return err
+	}
+
+	err = s.loadUsageFromDisk()
+	if err != nil {
+		return err
+	}
+
+	err = s.loadHistoryFromDisk()
+	if err != nil {
+		return err
+	}
+
+	err = s.loadTrashFromDisk()
+	if err != nil {
+		return err
+	}
+
+	err = s.loadMetaSegmentFromDisk()
+	if err != nil {
+		return err
+	}
+
+	err = s.loadCasingsFromDisk()
+	if err != nil {
+		return err
+	}
+
+	// dropping data files changes the keys that the reads served while loading see
+	s.loadLock.Lock()
+	err = s.EnforceRetention()
+	s.loadLock.Unlock()
+	if err != nil {
+		return err
+	}
+
+	return s.warmUp()
 }
 
 // Set adds or updates the value corresponding to the given key in store
-// It might return an ErrCorruptedData error but if it succeeds, no error is returned
+// It might return an ErrCorruptedData error but if it succeeds, no error is returned.
+// It returns an ErrInvalidKeyValue error if the key or value contains any of the separators
 func (s *Store) Set(key string, value string) error {
-	timestampedKey, isNewKey, err := s.getTimestampedKey(key)
+	return s.SetWithStats(key, value, nil)
+}
+
+// SetWithStats is like Set but it also records what it did in st. Like in Redis, setting a key
+// removes its expiry, if any
+func (s *Store) SetWithStats(key string, value string, st *OpStats) error {
+	original, key := key, s.foldKey(key)
+	return s.guardWrite(func() error {
+		err := s.setWithStats(key, value, st)
+		if err != nil {
+			return err
+		}
+
+		err = s.setCasing(key, original, st)
+		if err != nil {
+			return err
+		}
+
+		return s.removeExpiry(key, st)
+	})
+}
+
+// setWithStats is SetWithStats without the guard against writing to a failing disk
+func (s *Store) setWithStats(key string, value string, st *OpStats) error {
+	err := checkUserKey(key)
 	if err != nil {
-		_ = s.removeTimestampedKeyForKeyIfExists(key)
 		return err
 	}
 
-	oldValue, err := s.saveKeyValuePair(timestampedKey, value)
+	err = s.separators.validateKeyValue(key, value)
+	if err != nil {
+		return err
+	}
+
+	err = s.ensureQuotaFor(key, value)
+	if err != nil {
+		return err
+	}
+
+	err = s.ensureCapacityFor(key, st)
+	if err != nil {
+		return err
+	}
+
+	stored, err := s.encodeValue(value, st)
+	if err != nil {
+		return err
+	}
+
+	timestampedKey, err := s.saveStoredValue(key, stored, st)
+	if err != nil {
+		return err
+	}
+
+	err = s.checkReadBack(timestampedKey, value)
+	if err != nil {
+		return err
+	}
+
+	err = s.recordVersion(key, value, false, st)
+	if err != nil {
+		return err
+	}
+
+	s.useKey(key)
+	s.io.keyValueBytesWritten.Add(int64(len(key) + len(value)))
+	return s.appendToOplog(OplogSet, key, value)
+}
+
+// saveStoredValue saves the value of the key, as it is stored in the log and data files, adding the key
+// to the index if it is new. It returns the timestamped key of the key
+func (s *Store) saveStoredValue(key string, stored string, st *OpStats) (string, error) {
+	timestampedKey, isNewKey := s.getTimestampedKey(key)
+	stored = s.sealRecord(timestampedKey, stored)
+
+	// the value is saved before the key is added to the index so that a failure in between
+	// leaves behind, at worst, a value that no key points to. The files are replaced atomically
+	// so a failed save leaves the old value in place
+	err := s.saveKeyValuePair(timestampedKey, stored, st)
 	if err != nil {
 		if isNewKey {
 			_ = s.deleteKeyValuePairIfExists(timestampedKey)
-			_ = s.removeTimestampedKeyForKeyIfExists(key)
-			return err
 		}
 
-		_, _ = s.saveKeyValuePair(timestampedKey, oldValue)
-		return err
+		return "", err
 	}
 
 	if isNewKey {
-		s.index[key] = timestampedKey
+		err = s.addKeyToIndex(key, timestampedKey, st)
+		if err != nil {
+			_ = s.deleteKeyValuePairIfExists(timestampedKey)
+			return "", err
+		}
+
+		s.index[indexKeyOf(key, timestampedKey)] = timestampedKey
+		err = failpoint(FailpointAfterIndexWrite)
+		if err != nil {
+			return "", err
+		}
 	}
 
-	return nil
+	return timestampedKey, nil
 }
 
 // Get retrieves the value corresponding to the given key
 // It returns a ErrNotFound error if the key is nonexistent
 func (s *Store) Get(key string) (string, error) {
-	timestampedKey, ok := s.index[key]
+	return s.GetWithStats(key, nil)
+}
+
+// GetWithStats is like Get but it also records what it did in st
+func (s *Store) GetWithStats(key string, st *OpStats) (string, error) {
+	key = s.foldKey(key)
+	timestampedKey, ok := s.lookup(key)
 	if !ok {
 		return "", ErrNotFound
 	}
 
-	return s.getValueForKey(timestampedKey)
+	value, err := s.getValueForKey(timestampedKey, st)
+	if err != nil {
+		return "", err
+	}
+
+	s.useKey(key)
+	return value, nil
+}
+
+// GetManyWithStats gets the values of the given keys, recording what it did in st. The result
+// of each key is at the same index as the key. The values are got in the order of their timestamped
+// keys so that each data file is loaded at most once. If the keys are in more than one data file
+// that is not in the cache, those data files are loaded in parallel first
+func (s *Store) GetManyWithStats(keys []string, st *OpStats) []GetResult {
+	if s.caseInsensitiveKeys {
+		folded := make([]string, len(keys))
+		for i, key := range keys {
+			folded[i] = s.foldKey(key)
+		}
+		keys = folded
+	}
+
+	results := make([]GetResult, len(keys))
+	order := make([]int, 0, len(keys))
+	for i, key := range keys {
+		if _, ok := s.lookup(key); ok {
+			order = append(order, i)
+		}
+	}
+
+	sort.Slice(order, func(a, b int) bool { return s.index[keys[order[a]]] < s.index[keys[order[b]]] })
+	coldCaches := s.readColdCaches(keys, order, st)
+	for _, i := range order {
+		value, err := s.getValueFromColdCaches(s.index[keys[i]], coldCaches, st)
+		results[i] = GetResult{Value: value, Found: err == nil, Err: err}
+		if err == nil {
+			s.useKey(keys[i])
+		}
+	}
+
+	return results
+}
+
+// readColdCaches reads the data files of the given keys that are not in the cache, in parallel, returning
+// them by their start. It returns nil if there is at most one such data file, which is loaded on the first get
+// of its keys as usual. The read of a data file that fails is retried, and its error returned, by that get
+func (s *Store) readColdCaches(keys []string, order []int, st *OpStats) map[string]*Cache {
+	s.cacheLock.Lock()
+	cache, prefetched := s.cache, s.prefetched
+	s.cacheLock.Unlock()
+
+	var ranges []*Range
+	for _, i := range order {
+		timestampedKey := s.index[keys[i]]
+		if timestampedKey >= s.currentLogFile || cache.IsInRange(timestampedKey) ||
+			(prefetched != nil && prefetched.IsInRange(timestampedKey)) {
+			continue
+		}
+
+		// the keys are in order so those of the same data file are next to each other
+		timestampRange := s.getTimestampRangeForKey(timestampedKey)
+		if timestampRange != nil && (len(ranges) == 0 || ranges[len(ranges)-1].Start != timestampRange.Start) {
+			ranges = append(ranges, timestampRange)
+		}
+	}
+
+	if len(ranges) < 2 {
+		return nil
+	}
+
+	caches := make([]*Cache, len(ranges))
+	rangeStats := make([]OpStats, len(ranges))
+	var group errgroup.Group
+	group.SetLimit(runtime.GOMAXPROCS(0))
+	for i, timestampRange := range ranges {
+		group.Go(func() error {
+			caches[i], _ = s.readCacheOnce(timestampRange, &rangeStats[i])
+			return nil
+		})
+	}
+	_ = group.Wait()
+
+	coldCaches := make(map[string]*Cache, len(ranges))
+	for i, timestampRange := range ranges {
+		st.merge(&rangeStats[i])
+		if caches[i] != nil {
+			coldCaches[timestampRange.Start] = caches[i]
+		}
+		s.io.getBytesRead.Add(rangeStats[i].BytesRead)
+	}
+
+	// the cache is left holding the latest of the data files, as if they had been loaded one by one
+	if latest := caches[len(caches)-1]; latest != nil {
+		s.cacheLock.Lock()
+		s.cache = latest
+		s.cacheLock.Unlock()
+	}
+
+	return coldCaches
+}
+
+// getValueFromColdCaches gets the value of the timestamped key from the cold cache of its data file if there is one,
+// or as usual otherwise
+func (s *Store) getValueFromColdCaches(timestampedKey string, coldCaches map[string]*Cache, st *OpStats) (string, error) {
+	var cache *Cache
+	if timestampedKey < s.currentLogFile {
+		if timestampRange := s.getTimestampRangeForKey(timestampedKey); timestampRange != nil {
+			cache = coldCaches[timestampRange.Start]
+		}
+	}
+	if cache == nil {
+		return s.getValueForKey(timestampedKey, st)
+	}
+
+	s.cacheMisses.Add(1)
+	s.io.recordColdGet(0)
+	st.recordCacheHit(false)
+	err := s.checkSegment(timestampedKey, cache)
+	if err != nil {
+		return "", err
+	}
+
+	sealed, ok := cache.data[timestampedKey]
+	if !ok {
+		sealed, err = s.getValueBeforeBoundary(timestampedKey, cache.start, st)
+		if err != nil {
+			return "", err
+		}
+	} else {
+		s.fileAccess.recordRead(cache.start, s.clock.Now())
+	}
+
+	value, err := s.openValue(timestampedKey, sealed)
+	s.io.valueBytesRead.Add(int64(len(value)))
+	return value, err
+}
+
+// Delete removes the key-value pair corresponding to the passed key
+// It returns an ErrNotFound error if the key is nonexistent
+func (s *Store) Delete(key string) error {
+	return s.DeleteWithStats(key, nil)
+}
+
+// DeleteWithStats is like Delete but it also records what it did in st
+func (s *Store) DeleteWithStats(key string, st *OpStats) error {
+	key = s.foldKey(key)
+	return s.guardWrite(func() error {
+		err := checkUserKey(key)
+		if err != nil {
+			return err
+		}
+
+		err = s.moveToTrash(key, st)
+		if err != nil {
+			return err
+		}
+
+		return s.deleteWithStats(key, st)
+	})
+}
+
+// deleteWithStats is DeleteWithStats without the guard against writing to a failing disk
+func (s *Store) deleteWithStats(key string, st *OpStats) error {
+	timestampedKey, ok := s.index[key]
+	if !ok {
+		return ErrNotFound
+	}
+
+	err := s.flushIndex(st)
+	if err != nil {
+		return err
+	}
+
+	err = s.deleteKeyValuesFromFile(s.indexFilePath, []string{key})
+	if err != nil {
+		return err
+	}
+	st.recordFileRewrite(s.indexFilePath)
+
+	// the key is no longer in the index file so it is removed from the index
+	// even if it cannot be marked for deletion
+	delete(s.index, key)
+	s.forgetKey(key)
+
+	err = s.removeExpiry(key, st)
+	if err != nil {
+		return err
+	}
+
+	err = s.removeCasing(key, st)
+	if err != nil {
+		return err
+	}
+
+	s.delFileLock.Lock()
+	defer s.delFileLock.Unlock()
+
+	n, err := s.appendFile(s.delFilePath, []byte(s.separators.encodeRecord(timestampedKey)))
+	if err != nil {
+		return err
+	}
+	st.recordWrite(s.delFilePath, n)
+
+	err = s.recordVersion(key, "", true, st)
+	if err != nil {
+		return err
+	}
+
+	return s.appendToOplog(OplogDelete, key, "")
+}
+
+// Keys returns all the keys in the store, sorted in ascending order, except the reserved ones
+func (s *Store) Keys() []string {
+	keys := make([]string, 0, len(s.index))
+	for key := range s.index {
+		if !s.isExpired(key) && !isReservedKey(key) {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+// KeysByAge returns at most limit of the keys in the store, oldest first if ascending and newest first otherwise,
+// except the reserved ones. Keys are ordered by their timestamped keys, like their records in the files, so
+// updating a key does not change its age. A limit of zero or less returns all of them
+func (s *Store) KeysByAge(limit int, ascending bool) ([]string, error) {
+	keys := s.Keys()
+	sort.Slice(keys, func(a, b int) bool {
+		if ascending {
+			return s.index[keys[a]] < s.index[keys[b]]
+		}
+
+		return s.index[keys[a]] > s.index[keys[b]]
+	})
+
+	if limit > 0 && limit < len(keys) {
+		keys = keys[:limit]
+	}
+
+	return keys, nil
+}
+
+// Has checks whether the given key is in the store
+func (s *Store) Has(key string) bool {
+	_, ok := s.lookup(s.foldKey(key))
+	return ok
+}
+
+// Stats returns the current statistics of the store
+func (s *Store) Stats() Stats {
+	return Stats{
+		Keys:        len(s.index),
+		DataFiles:   len(s.dataFiles),
+		CacheHits:   s.cacheHits.Load(),
+		CacheMisses: s.cacheMisses.Load(),
+		CacheLoads:  s.cacheLoads.Load(),
+
+		RestoredFiles:  s.restoredFiles.Load(),
+		EvictedKeys:    s.evictedKeys.Load(),
+		SkippedRecords: s.skippedRecords.Load(),
+		Files:          s.fileStats(),
+
+		HotGets:              s.io.hotGets.Load(),
+		ColdGets:             s.io.coldGets.Load(),
+		GetBytesRead:         s.io.getBytesRead.Load(),
+		ValueBytesRead:       s.io.valueBytesRead.Load(),
+		BytesWritten:         s.io.bytesWritten.Load(),
+		KeyValueBytesWritten: s.io.keyValueBytesWritten.Load(),
+		ReadAmplification:    ratio(s.io.getBytesRead.Load(), s.io.valueBytesRead.Load()),
+		WriteAmplification:   ratio(s.io.bytesWritten.Load(), s.io.keyValueBytesWritten.Load()),
+	}
+}
+
+// SetMaxFileSize changes the size beyond which the log file is rolled into a data file.
+// It takes effect on the next Set of a new key
+func (s *Store) SetMaxFileSize(maxFileSizeKB float64) error {
+	if maxFileSizeKB <= 0 {
+		return ErrOutOfBounds
+	}
+
+	s.maxFileSizeKB = maxFileSizeKB
+	return nil
+}
+
+// MaxFileSize returns the size in kilobytes beyond which the log file is rolled into a data file
+func (s *Store) MaxFileSize() float64 {
+	return s.maxFileSizeKB
+}
+
+// Clear resets the entire Store, and clears everything on disk
+func (s *Store) Clear() error {
+	return s.guardWrite(s.clear)
+}
+
+// clear is Clear without the guard against writing to a failing disk
+func (s *Store) clear() error {
+	s.index = nil
+	s.expiries = nil
+	s.usage = nil
+	s.history = nil
+	s.trash = nil
+	s.metaSegment = nil
+	s.casings = nil
+	s.resetCache()
+	err := s.moveFilesAside(OplogFolderName)
+	if err != nil {
+		return err
+	}
+
+	err = s.Load()
+	if err != nil {
+		return err
+	}
+
+	return s.appendToOplog(OplogClear, "", "")
+}
+
+// Vacuum deletes all key-value pairs that have been previously marked for 'delete'
+// when store.Delete(key) was called on them.
+func (s *Store) Vacuum() error {
+	return s.VacuumWithStats(nil)
+}
+
+// VacuumWithStats is like Vacuum but it also records what it did in st
+func (s *Store) VacuumWithStats(st *OpStats) error {
+	return s.guardWrite(func() error { return s.vacuumWithStats(st) })
 }
 
-// Delete removes the key-value pair corresponding to the passed key
-// It returns an ErrNotFound error if the key is nonexistent
-func (s *Store) Delete(key string) error {
-	timestampedKey, ok := s.index[key]
-	if !ok {
-		return ErrNotFound
+// vacuumWithStats is VacuumWithStats without the guard against writing to a failing disk
+func (s *Store) vacuumWithStats(st *OpStats) error {
+	err := s.vacuumMetadata(st)
+	if err != nil {
+		return err
 	}
 
-	err := DeleteKeyValuesFromFile(s.indexFilePath, []string{key})
+	err = s.vacuumMarkedKeys(st)
 	if err != nil {
 		return err
 	}
 
+	return s.removeUnreferencedBlobs(st)
+}
+
+// vacuumMarkedKeys deletes the key-values marked for deletion from all the log and data files
+func (s *Store) vacuumMarkedKeys(st *OpStats) error {
 	s.delFileLock.Lock()
 	defer s.delFileLock.Unlock()
 
-	f, err := os.OpenFile(s.delFilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0777)
+	keysToDelete, err := s.getKeysToDelete()
 	if err != nil {
 		return err
 	}
-	defer func() { _ = f.Close() }()
 
-	_, err = f.WriteString(fmt.Sprintf("%s%s", timestampedKey, TokenSeparator))
+	if len(keysToDelete) == 0 {
+		return nil
+	}
+
+	defer func() {
+		s.cacheLock.Lock()
+		s.discardPrefetchedCache()
+		s.cacheLock.Unlock()
+	}()
+
+	filenames, err := getLogAndDataFilenames(s.dbPath)
 	if err != nil {
 		return err
 	}
 
-	delete(s.index, key)
-	return nil
-}
+	var filePaths []string
+	for _, filename := range filenames {
+		filePaths = append(filePaths, filepath.Join(s.dbPath, filename))
+	}
 
-// Clear resets the entire Store, and clears everything on disk
-func (s *Store) Clear() error {
-	s.index = nil
-	err := s.clearDisk()
+	// each file is vacuumed independently so they are vacuumed in parallel
+	var group errgroup.Group
+	group.SetLimit(s.getMaintenanceWorkers())
+	for _, filePath := range filePaths {
+		filePath := filePath
+		group.Go(func() error {
+			return s.deleteKeyValuesFromFile(filePath, keysToDelete)
+		})
+	}
+
+	err = group.Wait()
 	if err != nil {
 		return err
 	}
 
-	return s.Load()
-}
+	for _, filePath := range filePaths {
+		st.recordFileRewrite(filePath)
+	}
 
-// Vacuum deletes all key-value pairs that have been previously marked for 'delete'
-// when store.Delete(key) was called on them.
-func (s *Store) Vacuum() error {
-	s.delFileLock.Lock()
-	defer s.delFileLock.Unlock()
+	// Clear del file
+	return s.writeFile(s.delFilePath, nil)
+}
 
-	keysToDelete, err := s.getKeysToDelete()
+// vacuumMetadata deletes the expired keys, saves the usage and history of the keys and purges the trash,
+// which vacuuming does before deleting the key-values marked for deletion
+func (s *Store) vacuumMetadata(st *OpStats) error {
+	err := s.deleteExpiredKeys(st)
 	if err != nil {
 		return err
 	}
 
-	if len(keysToDelete) == 0 {
-		return nil
+	err = s.deleteOrphanedCasings(st)
+	if err != nil {
+		return err
 	}
 
-	filesInFolder, err := GetFileOrFolderNamesInFolder(s.dbPath)
+	err = s.saveUsage(st)
 	if err != nil {
 		return err
 	}
 
-	for _, file := range filesInFolder {
-		if file == DelFilename || file == IndexFilename {
-			continue
-		}
-
-		filePath := filepath.Join(s.dbPath, file)
-		err := DeleteKeyValuesFromFile(filePath, keysToDelete)
-		if err != nil {
-			return err
-		}
+	err = s.saveHistory(st)
+	if err != nil {
+		return err
 	}
 
-	// Clear del file
-	_, err = os.Create(s.delFilePath)
-	return err
+	return s.purgeTrash(st)
 }
 
 // loadFilePropsFromDisk loads the attributes that depend on the things in the folder
 func (s *Store) loadFilePropsFromDisk() error {
 	s.dataFiles = nil
-	filesInFolder, err := GetFileOrFolderNamesInFolder(s.dbPath)
+	filesInFolder, err := getLogAndDataFilenames(s.dbPath)
 	if err != nil {
 		return err
 	}
 
-	for _, filename := range filesInFolder {
+	for _, path := range filesInFolder {
+		filename := filepath.Base(path)
 		filenameLength := len(filename)
-		switch filename[filenameLength-3:] {
-		case LogFileExt:
+		switch filepath.Ext(filename) {
+		case "." + LogFileExt:
 			s.currentLogFile = filename[:filenameLength-4]
-		case DataFileExt:
+		case "." + DataFileExt:
 			s.dataFiles = append(s.dataFiles, filename[:filenameLength-4])
 		}
 	}
+	s.dataFileFolders = getDataFileFolders(filesInFolder)
 
 	// sort these data files
 	sort.Strings(s.dataFiles)
 
-	return nil
+	for _, dataFile := range s.dataFiles {
+		s.observeTimestamp(dataFile)
+	}
+	s.observeTimestamp(s.currentLogFile)
+
+	return s.moveDataFilesToBuckets()
 }
 
 // createIndexFileIfNotExists creates the index file if it does not exist
 func (s *Store) createIndexFileIfNotExists() error {
-	return CreateFileIfNotExist(s.indexFilePath)
+	return s.createFileIfNotExist(s.indexFilePath)
 }
 
 // createDelFileIfNotExists creates the index file if it does not exist
 func (s *Store) createDelFileIfNotExists() error {
-	return CreateFileIfNotExist(s.delFilePath)
+	return s.createFileIfNotExist(s.delFilePath)
 }
 
-// createLogFileIfNotExists creates a new log file if it does not exist
+// createLogFileIfNotExists creates a new log file if it does not exist.
+// If there are many log files, as is the case when a log roll is interrupted,
+// all but the newest are rolled into data files
 func (s *Store) createLogFileIfNotExists() error {
 	filesInFolder, err := GetFileOrFolderNamesInFolder(s.dbPath)
 	if err != nil {
 		return err
 	}
 
+	var logFiles []string
 	for _, filename := range filesInFolder {
-		if strings.HasSuffix(filename, LogFileExt) {
-			s.currentLogFilePath = filepath.Join(s.dbPath, filename)
-			return nil
+		if strings.HasSuffix(filename, "."+LogFileExt) {
+			logFiles = append(logFiles, filename)
+		}
+	}
+
+	if len(logFiles) == 0 {
+		return s.createNewLogFile()
+	}
+
+	sort.Strings(logFiles)
+	for _, filename := range logFiles[:len(logFiles)-1] {
+		dataFile := strings.TrimSuffix(filename, "."+LogFileExt)
+		err = s.createDataFileFolder(dataFile)
+		if err != nil {
+			return err
+		}
+
+		err = s.fs.Rename(filepath.Join(s.dbPath, filename), s.getDataFilePath(dataFile))
+		if err != nil {
+			return err
 		}
 	}
 
-	return s.createNewLogFile()
+	s.currentLogFilePath = filepath.Join(s.dbPath, logFiles[len(logFiles)-1])
+	return nil
 }
 
 // createNewLogFile creates a new log file basing on the current timestamp
 func (s *Store) createNewLogFile() error {
-	logFilename := fmt.Sprintf("%d", time.Now().UnixNano())
-	logFilePath := filepath.Join(s.dbPath, fmt.Sprintf("%s.%s", logFilename, LogFileExt))
-
-	err := CreateFileIfNotExist(logFilePath)
+	logFilename, logFilePath, err := s.createLogFile()
 	if err != nil {
 		return err
 	}
@@ -281,34 +1073,75 @@ func (s *Store) createNewLogFile() error {
 	return nil
 }
 
+// createLogFile creates a log file basing on the current timestamp, returning its name and path
+func (s *Store) createLogFile() (string, string, error) {
+	logFilename := fmt.Sprintf("%d", s.nextTimestamp())
+	logFilePath := filepath.Join(s.dbPath, fmt.Sprintf("%s.%s", logFilename, LogFileExt))
+
+	err := s.createFileIfNotExist(logFilePath)
+	if err != nil {
+		return "", "", err
+	}
+
+	return logFilename, logFilePath, nil
+}
+
 // loadIndexFromDisk loads the index from the index file
 func (s *Store) loadIndexFromDisk() error {
-	data, err := os.ReadFile(s.indexFilePath)
+	dataAsMap, err := s.readLoadedKeyValues(s.indexFilePath, s.separators.isValidIndexRecord)
 	if err != nil {
 		return err
 	}
 
-	dataAsMap, err := ExtractKeyValuesFromByteArray(data)
+	index, err := s.separators.expandIndex(dataAsMap)
 	if err != nil {
 		return err
 	}
 
-	s.index = dataAsMap
+	s.index = index
 	return nil
 }
 
-// loadMemtableFromDisk loads the memtable from the current log file
-func (s *Store) loadMemtableFromDisk() error {
-	data, err := os.ReadFile(s.currentLogFilePath)
+// loadIndexAndMemtableFromDisk loads the index and the memtable, which are independent of each other, in parallel
+// if both the index file and the log file hold enough keys, judged by their sizes, for parsing them to outweigh
+// starting another go routine, and one after the other otherwise
+func (s *Store) loadIndexAndMemtableFromDisk() error {
+	indexFileSize, err := GetFileSize(s.indexFilePath)
+	if err != nil {
+		return err
+	}
+
+	logFileSize, err := GetFileSize(s.currentLogFilePath)
 	if err != nil {
 		return err
 	}
 
-	dataAsMap, err := ExtractKeyValuesFromByteArray(data)
+	if min(indexFileSize, logFileSize) < minParallelLoadFileSizeKB {
+		err = s.loadIndexFromDisk()
+		if err != nil {
+			return err
+		}
+
+		return s.loadMemtableFromDisk()
+	}
+
+	var group errgroup.Group
+	group.Go(s.loadIndexFromDisk)
+	group.Go(s.loadMemtableFromDisk)
+	return group.Wait()
+}
+
+// loadMemtableFromDisk loads the memtable from the current log file
+func (s *Store) loadMemtableFromDisk() error {
+	dataAsMap, err := s.readLoadedKeyValues(s.currentLogFilePath, isTimestampedKeyValue)
 	if err != nil {
 		return err
 	}
 
+	for timestampedKey := range dataAsMap {
+		s.observeTimestamp(strings.SplitN(timestampedKey, "-", 2)[0])
+	}
+
 	s.memtable = dataAsMap
 	return nil
 }
@@ -320,130 +1153,185 @@ func (s *Store) getKeysToDelete() ([]string, error) {
 		return nil, err
 	}
 
-	return ExtractTokensFromByteArray(data)
+	records, err := s.extractLoadedRecords(s.delFilePath, data, 1, func(fields []string) bool {
+		return isTimestampedKey(fields[0])
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	timestampedKeys := make([]string, len(records))
+	for i, record := range records {
+		timestampedKeys[i] = record[0]
+	}
+
+	return timestampedKeys, nil
 }
 
 // getTimestampedKey gets the timestamped key corresponding to the given key in the index
-// If there is none, it creates a new timestamped key and adds it to the index file
-func (s *Store) getTimestampedKey(key string) (string, bool, error) {
-	isNewKey := false
+// If there is none, it creates a new timestamped key
+func (s *Store) getTimestampedKey(key string) (string, bool) {
 	timestampedKey, ok := s.index[key]
-
-	if !ok {
-		isNewKey = true
-		timestampedKey = fmt.Sprintf("%d-%s", time.Now().UnixNano(), key)
-
-		f, err := os.OpenFile(s.indexFilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0777)
-		if err != nil {
-			return "", false, err
-		}
-		defer func() { _ = f.Close() }()
-
-		data := fmt.Sprintf("%s%s%s%s", key, KeyValueSeparator, timestampedKey, TokenSeparator)
-		_, err = f.WriteString(data)
-		if err != nil {
-			return "", false, err
-		}
+	if ok {
+		return timestampedKey, false
 	}
 
-	return timestampedKey, isNewKey, nil
+	return fmt.Sprintf("%d-%s", s.nextTimestamp(), key), true
 }
 
-// removeTimestampedKeyForKeyIfExists removes the key and timestamped key from
-// the index file if it exists
-func (s *Store) removeTimestampedKeyForKeyIfExists(key string) error {
-	_, ok := s.index[key]
-	if !ok {
-		return nil
+// addKeyToIndex appends the key and its timestamped key to the index file, or buffers them with index batching
+func (s *Store) addKeyToIndex(key string, timestampedKey string, st *OpStats) error {
+	data := s.separators.encodeIndexRecord(key, timestampedKey)
+	if s.indexBatchSize > 0 {
+		return s.bufferIndexEntry(data, st)
+	}
+
+	n, err := s.appendFile(s.indexFilePath, []byte(data))
+	if err != nil {
+		return err
 	}
+	st.recordWrite(s.indexFilePath, n)
 
-	return DeleteKeyValuesFromFile(s.indexFilePath, []string{key})
+	return nil
 }
 
 // saveKeyValuePair saves the key value pair in memtable and log file if it is newer than log file
 // or in cache and in the corresponding dataFile if the key is old
-func (s *Store) saveKeyValuePair(timestampedKey string, value string) (string, error) {
+func (s *Store) saveKeyValuePair(timestampedKey string, value string, st *OpStats) error {
 	if timestampedKey >= s.currentLogFile {
-		return s.saveKeyValueToMemtable(timestampedKey, value)
+		err := s.checkSegment(timestampedKey, nil)
+		if err != nil {
+			return err
+		}
+
+		return s.saveKeyValueToMemtable(timestampedKey, value, st)
 	}
 
 	s.cacheLock.Lock()
 	defer s.cacheLock.Unlock()
 
-	if !s.cache.IsInRange(timestampedKey) {
-		err := s.loadCacheContainingKey(timestampedKey)
+	isInCache := s.cache.IsInRange(timestampedKey)
+	st.recordCacheHit(isInCache)
+	if !isInCache {
+		err := s.loadCacheContainingKey(timestampedKey, st)
 		if err != nil {
-			return "", err
+			return err
 		}
 	}
 
-	return s.saveKeyValueToCache(timestampedKey, value)
+	err := s.checkSegment(timestampedKey, s.cache)
+	if err != nil {
+		return err
+	}
+
+	return s.saveKeyValueToCache(timestampedKey, value, st)
 }
 
 // saveKeyValueToMemtable saves the key value pair to memtable and persists memtable
 // to current log file
-func (s *Store) saveKeyValueToMemtable(timestampedKey string, value string) (string, error) {
-	oldValue := s.memtable[timestampedKey]
-	data := map[string]string{}
-	for k, v := range s.memtable {
-		data[k] = v
+func (s *Store) saveKeyValueToMemtable(timestampedKey string, value string, st *OpStats) error {
+	err := failpoint(FailpointBeforeLogAppend)
+	if err != nil {
+		return err
 	}
-	data[timestampedKey] = value
 
-	err := PersistMapDataToFile(data, s.currentLogFilePath)
+	// the memtable is changed in place, instead of being copied on every save, and changed back if it fails
+	oldValue, isUpdate := s.memtable[timestampedKey]
+	s.memtable[timestampedKey] = value
+	err = s.persistMapDataToFile(s.memtable, s.currentLogFilePath)
 	if err != nil {
-		return "", err
+		if isUpdate {
+			s.memtable[timestampedKey] = oldValue
+		} else {
+			delete(s.memtable, timestampedKey)
+		}
+
+		return err
 	}
+	st.recordLogRewrite()
+	st.recordFileRewrite(s.currentLogFilePath)
 
-	s.memtable[timestampedKey] = value
-	err = s.rollLogFileIfTooBig()
-	return oldValue, err
+	s.fileAccess.recordWrite(s.currentLogFile, s.clock.Now())
+	return s.rollLogFileIfTooBig(st)
 }
 
 // saveKeyValueToCache saves the key value pair to cache and persists cache
 // to corresponding data file
-func (s *Store) saveKeyValueToCache(timestampedKey string, value string) (string, error) {
-	oldValue := s.cache.data[timestampedKey]
+func (s *Store) saveKeyValueToCache(timestampedKey string, value string, st *OpStats) error {
 	data := map[string]string{}
 	for k, v := range s.cache.data {
 		data[k] = v
 	}
 	data[timestampedKey] = value
 
-	dataFilePath := filepath.Join(s.dbPath, fmt.Sprintf("%s.%s", s.cache.start, DataFileExt))
-	err := PersistMapDataToFile(data, dataFilePath)
+	dataFilePath := s.getDataFilePath(s.cache.start)
+	err := s.persistMapDataToFile(data, dataFilePath)
 	if err != nil {
-		return "", err
+		return err
 	}
+	st.recordFileRewrite(dataFilePath)
+	s.discardPrefetchedCache()
 
 	s.cache.Update(timestampedKey, value)
-	return oldValue, nil
+	s.fileAccess.recordWrite(s.cache.start, s.clock.Now())
+	return nil
 }
 
 // rollLogFileIfTooBig rolls the log file if it has exceeded the maximum size it should have
-func (s *Store) rollLogFileIfTooBig() error {
+func (s *Store) rollLogFileIfTooBig(st *OpStats) error {
 	logFileSize, err := GetFileSize(s.currentLogFilePath)
 	if err != nil {
 		return err
 	}
 
 	if logFileSize >= s.maxFileSizeKB {
-		newDataFilename := fmt.Sprintf("%s.%s", s.currentLogFile, DataFileExt)
-		err = os.Rename(s.currentLogFilePath, filepath.Join(s.dbPath, newDataFilename))
-		if err != nil {
-			return err
-		}
+		return s.rollLogFile(st)
+	}
 
-		s.memtable = map[string]string{}
-		s.dataFiles = append(s.dataFiles, s.currentLogFile)
-		// ensure these data files are sorted
-		sort.Strings(s.dataFiles)
+	return nil
+}
 
-		err = s.createNewLogFile()
+// rollLogFile renames the current log file into a data file, in a new log file's stead
+func (s *Store) rollLogFile(st *OpStats) error {
+	err := s.flushIndex(st)
+	if err != nil {
 		return err
 	}
 
-	return nil
+	// the new log file is created before the current one is renamed so that an interruption
+	// in between leaves two log files, the older of which is rolled on the next Load
+	newLogFile, newLogFilePath, err := s.createLogFile()
+	if err != nil {
+		return err
+	}
+
+	err = failpoint(FailpointDuringRoll)
+	if err == nil {
+		err = s.createDataFileFolder(s.currentLogFile)
+	}
+	if err == nil {
+		err = s.fs.Rename(s.currentLogFilePath, s.getDataFilePath(s.currentLogFile))
+	}
+	if err != nil {
+		_ = s.fs.Remove(newLogFilePath)
+		return err
+	}
+	st.recordLogRoll()
+
+	s.memtable = map[string]string{}
+	s.dataFiles = append(s.dataFiles, s.currentLogFile)
+	// ensure these data files are sorted
+	sort.Strings(s.dataFiles)
+
+	rolledDataFile := s.currentLogFile
+	s.currentLogFile = newLogFile
+	s.currentLogFilePath = newLogFilePath
+	err = s.hashDataFile(rolledDataFile)
+	if err != nil {
+		return err
+	}
+
+	return s.EnforceRetention()
 }
 
 // getTimestampRangeForKey returns the range of timestamps between which
@@ -465,72 +1353,253 @@ func (s *Store) getTimestampRangeForKey(key string) *Range {
 }
 
 // loadCacheContainingKey loads the cache with data containing the timestampedKey
-func (s *Store) loadCacheContainingKey(timestampedKey string) error {
+func (s *Store) loadCacheContainingKey(timestampedKey string, st *OpStats) error {
 	timestampRange := s.getTimestampRangeForKey(timestampedKey)
 	if timestampRange == nil {
 		return ErrCorruptedData
 	}
 
-	filePath := filepath.Join(s.dbPath, fmt.Sprintf("%s.%s", timestampRange.Start, DataFileExt))
-	data, err := os.ReadFile(filePath)
+	cache, err := s.readCache(timestampRange, st)
 	if err != nil {
 		return err
 	}
 
-	mapData, err := ExtractKeyValuesFromByteArray(data)
+	s.cache = cache
+	return nil
+}
+
+// readCacheContainingKeyOnce reads the data file containing the timestampedKey into a new Cache.
+// Concurrent calls for keys in the same data file share a single read of that file
+func (s *Store) readCacheContainingKeyOnce(timestampedKey string, st *OpStats) (*Cache, error) {
+	timestampRange := s.getTimestampRangeForKey(timestampedKey)
+	if timestampRange == nil {
+		return nil, ErrCorruptedData
+	}
+
+	return s.readCacheOnce(timestampRange, st)
+}
+
+// sharedCacheRead is the outcome of a read of a data file shared by concurrent calls of readCacheOnce
+type sharedCacheRead struct {
+	cache *Cache
+	st    *OpStats
+}
+
+// readCacheOnce reads the data file at the start of the given timestamp range into a new Cache.
+// Concurrent calls for the same data file share a single read of that file, which each of them records in its st
+func (s *Store) readCacheOnce(timestampRange *Range, st *OpStats) (*Cache, error) {
+	read, err, _ := s.cacheLoadGroup.Do(timestampRange.Start, func() (interface{}, error) {
+		readSt := &OpStats{}
+		cache, err := s.readCache(timestampRange, readSt)
+		return sharedCacheRead{cache: cache, st: readSt}, err
+	})
+	shared := read.(sharedCacheRead)
+	st.merge(shared.st)
+	if err != nil {
+		return nil, err
+	}
+
+	return shared.cache, nil
+}
+
+// readCache reads the data file at the start of the given timestamp range into a new Cache
+func (s *Store) readCache(timestampRange *Range, st *OpStats) (*Cache, error) {
+	filePath := s.getDataFilePath(timestampRange.Start)
+	data, err := os.ReadFile(filePath)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	st.recordRead(filePath, len(data))
+	s.cacheLoads.Add(1)
 
-	s.cache = NewCache(mapData, timestampRange.Start, timestampRange.End)
-	return nil
+	mapData, err := s.separators.extractKeyValues(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewCache(mapData, timestampRange.Start, timestampRange.End), nil
 }
 
 // deleteKeyValuePairIfExists deletes the given key value pair from
 // the memtable, the log file or any data file
 func (s *Store) deleteKeyValuePairIfExists(timestampedKey string) error {
 	if s.cache.IsInRange(timestampedKey) {
+		s.cacheLock.Lock()
+		defer s.cacheLock.Unlock()
+
 		s.cache.Remove(timestampedKey)
-		dataFilePath := filepath.Join(s.dbPath, fmt.Sprintf("%s.%s", s.cache.start, DataFileExt))
-		return PersistMapDataToFile(s.cache.data, dataFilePath)
+		s.discardPrefetchedCache()
+		return s.persistMapDataToFile(s.cache.data, s.getDataFilePath(s.cache.start))
 	}
 
 	if timestampedKey >= s.currentLogFile {
 		delete(s.memtable, timestampedKey)
-		return PersistMapDataToFile(s.memtable, s.currentLogFilePath)
+		return s.persistMapDataToFile(s.memtable, s.currentLogFilePath)
 	}
 
 	return nil
 }
 
 // getValueForKey gets the value corresponding to a given timestampedKey
-func (s *Store) getValueForKey(timestampedKey string) (string, error) {
+func (s *Store) getValueForKey(timestampedKey string, st *OpStats) (string, error) {
+	sealed, err := s.getStoredValueForKey(timestampedKey, st)
+	if err != nil {
+		return "", err
+	}
+
+	value, err := s.openValue(timestampedKey, sealed)
+	s.io.valueBytesRead.Add(int64(len(value)))
+	return value, err
+}
+
+// openValue returns the value of the timestamped key from its sealed value, as it is saved in the log or data file
+func (s *Store) openValue(timestampedKey string, sealed string) (string, error) {
+	stored, err := unsealRecord(timestampedKey, sealed, s.recordMACKey())
+	if err != nil {
+		return "", err
+	}
+
+	return decodeValue(stored, s.valueFlags, s.blobsPath())
+}
+
+// getStoredValueForKey gets the value corresponding to a given timestampedKey as it is saved, and sealed,
+// in the log or data file
+func (s *Store) getStoredValueForKey(timestampedKey string, st *OpStats) (string, error) {
 	if timestampedKey >= s.currentLogFile {
+		err := s.checkSegment(timestampedKey, nil)
+		if err != nil {
+			return "", err
+		}
+
 		if value, ok := s.memtable[timestampedKey]; ok {
+			s.io.recordHotGet()
+			s.fileAccess.recordRead(s.currentLogFile, s.clock.Now())
 			return value, nil
 		}
 
-		return "", ErrCorruptedData
+		s.io.recordColdGet(0)
+		return s.getValueBeforeBoundary(timestampedKey, s.currentLogFile, st)
 	}
 
 	s.cacheLock.Lock()
-	defer s.cacheLock.Unlock()
+	cache := s.cache
+	s.cacheLock.Unlock()
+
+	isInCache := cache.IsInRange(timestampedKey)
+	st.recordCacheHit(isInCache)
+	if isInCache {
+		s.cacheHits.Add(1)
+		s.io.recordHotGet()
+	} else {
+		s.cacheMisses.Add(1)
+
+		// the data file is read without holding the cacheLock so that gets of keys
+		// already in the cache are not blocked by the disk IO
+		// the bytes of a prefetched data file were counted when it was prefetched
+		var bytesRead int64
+		cache = s.takePrefetchedCache(timestampedKey)
+		if cache == nil {
+			readSt := &OpStats{}
+			var err error
+			cache, err = s.readCacheContainingKeyOnce(timestampedKey, readSt)
+			st.merge(readSt)
+			if err != nil {
+				return "", err
+			}
+			bytesRead = readSt.BytesRead
+		}
 
-	if !s.cache.IsInRange(timestampedKey) {
-		err := s.loadCacheContainingKey(timestampedKey)
-		if err != nil {
-			return "", err
+		s.cacheLock.Lock()
+		if !s.cache.IsInRange(timestampedKey) {
+			s.cache = cache
 		}
+		s.cacheLock.Unlock()
+
+		s.io.recordColdGet(bytesRead)
+		s.prefetchNextCache(cache)
+	}
+
+	err := s.checkSegment(timestampedKey, cache)
+	if err != nil {
+		return "", err
+	}
+
+	if value, ok := cache.data[timestampedKey]; ok {
+		s.fileAccess.recordRead(cache.start, s.clock.Now())
+		return value, nil
+	}
+
+	return s.getValueBeforeBoundary(timestampedKey, cache.start, st)
+}
+
+// getValueBeforeBoundary gets the value of the timestamped key from the data file before the given boundary
+// i.e. the name of the file that the key was routed to, if the key's timestamp is that boundary. Implementations
+// whose clocks are coarser than nanoseconds can name a new log file after the timestamp of the last key
+// written to the log file it replaces. It returns an ErrCorruptedData error for any other key
+func (s *Store) getValueBeforeBoundary(timestampedKey string, boundary string, st *OpStats) (string, error) {
+	timestamp, _, _ := strings.Cut(timestampedKey, "-")
+	i := sort.SearchStrings(s.dataFiles, boundary)
+	if timestamp != boundary || i == 0 {
+		return "", ErrCorruptedData
+	}
+
+	readSt := &OpStats{}
+	cache, err := s.readCacheOnce(&Range{Start: s.dataFiles[i-1], End: boundary}, readSt)
+	st.merge(readSt)
+	s.io.getBytesRead.Add(readSt.BytesRead)
+	if err != nil {
+		return "", err
 	}
 
-	if value, ok := s.cache.data[timestampedKey]; ok {
+	if value, ok := cache.data[timestampedKey]; ok {
+		s.fileAccess.recordRead(s.dataFiles[i-1], s.clock.Now())
 		return value, nil
 	}
 
 	return "", ErrCorruptedData
 }
 
-// clearDisk deletes all files in the database folder
-func (s *Store) clearDisk() error {
-	return os.RemoveAll(s.dbPath)
+// clearDisk deletes all files in the database folder except the given ones e.g. the oplog, which outlives clears
+// so that those following it also see the clear
+func (s *Store) clearDisk(kept ...string) error {
+	filesInFolder, err := GetFileOrFolderNamesInFolder(s.dbPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, filename := range filesInFolder {
+		if slices.Contains(kept, filename) {
+			continue
+		}
+
+		err = os.RemoveAll(filepath.Join(s.dbPath, filename))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// resetCache clears the cache so that it is reloaded from disk on next access
+func (s *Store) resetCache() {
+	s.cacheLock.Lock()
+	defer s.cacheLock.Unlock()
+
+	s.cache = NewCache(nil, "0", "0")
+	s.discardPrefetchedCache()
+}
+
+// getDataFilePath returns the path to the data file of the given timestamp, in the folder it was loaded from
+// or, if it is new, in the one that WithDataFileBuckets puts it in
+func (s *Store) getDataFilePath(dataFile string) string {
+	folder, ok := s.dataFileFolders[dataFile]
+	if !ok {
+		folder = s.getBucket(dataFile)
+	}
+
+	return filepath.Join(s.dbPath, folder, fmt.Sprintf("%s.%s", dataFile, DataFileExt))
 }
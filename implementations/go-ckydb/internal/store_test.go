@@ -3,10 +3,14 @@ package internal
 import (
 	"errors"
 	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
+	"runtime"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -78,9 +82,72 @@ func TestStore(t *testing.T) {
 		assert.Equal(t, delFilePath, store.delFilePath)
 	})
 
+	t.Run("LoadShouldLoadABigIndexAndLogFileInParallel", func(t *testing.T) {
+		err := ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = ClearDummyFileDataInDb(dbPath) }()
+		numOfKeys := 3000
+		_, err = addManyDataFilesInDb(dbPath, 1, numOfKeys)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		idxFileContent, err := os.ReadFile(indexFilePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		index, err := ExtractKeyValuesFromByteArray(idxFileContent)
+		if err != nil {
+			t.Fatal(err)
+		}
+		logFiles, err := filepath.Glob(filepath.Join(dbPath, "*."+LogFileExt))
+		if err != nil || len(logFiles) != 1 {
+			t.Fatal(logFiles, err)
+		}
+		logFileTimestamp, err := strconv.ParseInt(strings.TrimSuffix(filepath.Base(logFiles[0]), "."+LogFileExt), 10, 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		memtable := make(map[string]string, numOfKeys)
+		for i := 0; i < numOfKeys; i++ {
+			key := fmt.Sprintf("log-key-%d", i)
+			index[key] = fmt.Sprintf("%d-%s", logFileTimestamp+int64(i), key)
+			memtable[index[key]] = fmt.Sprintf("log-value-%d", i)
+		}
+		err = PersistMapDataToFile(index, indexFilePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = PersistMapDataToFile(memtable, logFiles[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		indexFileSize, _ := GetFileSize(indexFilePath)
+		logFileSize, _ := GetFileSize(logFiles[0])
+		assert.GreaterOrEqual(t, min(indexFileSize, logFileSize), float64(minParallelLoadFileSizeKB))
+
+		store := NewStore(dbPath, maxFileSizeKB*1024)
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, 2*numOfKeys, len(store.index))
+		assert.Equal(t, memtable, store.memtable)
+		value, err := store.Get("key-0-7")
+		assert.Nil(t, err)
+		assert.Equal(t, "value-0-7", value)
+		value, err = store.Get("log-key-7")
+		assert.Nil(t, err)
+		assert.Equal(t, "log-value-7", value)
+	})
+
 	t.Run("LoadShouldCreateDatabaseFolderWithIndexAndDelFilesIfNotExist", func(t *testing.T) {
 		expectedCache := NewCache(nil, "0", "0")
-		expectedFiles := []string{DelFilename, IndexFilename}
+		expectedFiles := []string{DelFilename, IndexFilename, FormatVersionFilename, MetadataFilename, DelFilename + "." + ChecksumFileExt, IndexFilename + "." + ChecksumFileExt}
 
 		err := ClearDummyFileDataInDb(dbPath)
 		if err != nil {
@@ -403,7 +470,7 @@ func TestStore(t *testing.T) {
 
 	t.Run("ClearShouldDeleteAllDataOnDiskAndResetAllProperties", func(t *testing.T) {
 		expectedCache := NewCache(nil, "0", "0")
-		expectedFiles := []string{delFilename, indexFilename}
+		expectedFiles := []string{delFilename, indexFilename, FormatVersionFilename, MetadataFilename, DelFilename + "." + ChecksumFileExt, IndexFilename + "." + ChecksumFileExt}
 
 		err := AddDummyFileDataInDb(dbPath)
 		if err != nil {
@@ -543,4 +610,1071 @@ func TestStore(t *testing.T) {
 		assert.Equal(t, expectedDelFileContent, delFileContent)
 		assert.Equal(t, expectedDataFileContent, dataFileContent)
 	})
+
+	t.Run("LoadWithRetentionMergeShouldMergeOldestDataFilesWhenMaxDataFilesIsExceeded", func(t *testing.T) {
+		expectedDataFiles := []string{strings.TrimRight(dataFiles[0], ".cky")}
+		expectedIndex := map[string]string{
+			"cow":  "1655375120328185000-cow",
+			"dog":  "1655375120328185100-dog",
+			"goat": "1655404770518678-goat",
+			"hen":  "1655404670510698-hen",
+			"pig":  "1655404770534578-pig",
+			"fish": "1655403775538278-fish",
+		}
+
+		err := AddDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = ClearDummyFileDataInDb(dbPath) }()
+
+		store := NewStore(dbPath, maxFileSizeKB, WithRetentionPolicy(RetentionPolicy{
+			MaxDataFiles: 1,
+			Action:       RetentionMerge,
+		}))
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ckyFiles, err := ReadFilesWithExtension(dbPath, DataFileExt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		value, err := store.Get("cow")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, expectedDataFiles, store.dataFiles)
+		assert.Equal(t, 1, len(ckyFiles))
+		assert.Equal(t, expectedIndex, store.index)
+		assert.Equal(t, "500 months", value)
+	})
+
+	t.Run("LoadWithRetentionDeleteShouldDeleteOldestDataFileAndItsKeys", func(t *testing.T) {
+		expectedDataFiles := []string{strings.TrimRight(dataFiles[1], ".cky")}
+		expectedIndex := map[string]string{
+			"goat": "1655404770518678-goat",
+			"hen":  "1655404670510698-hen",
+			"pig":  "1655404770534578-pig",
+			"fish": "1655403775538278-fish",
+		}
+
+		err := AddDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = ClearDummyFileDataInDb(dbPath) }()
+
+		store := NewStore(dbPath, maxFileSizeKB, WithRetentionPolicy(RetentionPolicy{
+			MaxDataFiles: 1,
+			Action:       RetentionDelete,
+		}))
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		idxFileContent, err := os.ReadFile(indexFilePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mapFromIdxFile, err := ExtractKeyValuesFromByteArray(idxFileContent)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = store.Get("cow")
+
+		assert.Equal(t, expectedDataFiles, store.dataFiles)
+		assert.Equal(t, expectedIndex, store.index)
+		assert.Equal(t, expectedIndex, mapFromIdxFile)
+		assert.True(t, errors.Is(err, ErrNotFound))
+		assert.NoFileExists(t, filepath.Join(dbPath, dataFiles[0]))
+	})
+
+	t.Run("LoadWithRetentionDeleteShouldForgetTheDroppedKeysAsDeleteDoes", func(t *testing.T) {
+		err := AddDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = ClearDummyFileDataInDb(dbPath) }()
+
+		store := NewStore(dbPath, maxFileSizeKB)
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = store.Expire("cow", time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var dropped []string
+		store = NewStore(dbPath, maxFileSizeKB, WithOplog(true), WithRetentionPolicy(RetentionPolicy{
+			MaxDataFiles: 1,
+			Action:       RetentionDelete,
+		}), WithDroppedKeyHandler(func(key string) { dropped = append(dropped, key) }))
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var deleted []string
+		for entry, err := range store.ReadOplog(0) {
+			if err != nil {
+				t.Fatal(err)
+			}
+			if entry.Op == OplogDelete {
+				deleted = append(deleted, entry.Key)
+			}
+		}
+
+		assert.ElementsMatch(t, []string{"cow", "dog"}, dropped)
+		assert.ElementsMatch(t, []string{"cow", "dog"}, deleted)
+		assert.NotContains(t, store.expiries, "cow")
+
+		store = NewStore(dbPath, maxFileSizeKB)
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.NotContains(t, store.expiries, "cow")
+	})
+
+	t.Run("LoadWithRetentionArchiveShouldMoveOldestDataFileToArchiveFolder", func(t *testing.T) {
+		var evictedFiles []string
+
+		err := AddDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = ClearDummyFileDataInDb(dbPath) }()
+
+		store := NewStore(dbPath, maxFileSizeKB, WithRetentionPolicy(RetentionPolicy{
+			MaxTotalSizeKB: 0.05,
+			Action:         RetentionArchive,
+			BeforeEvict: func(dataFilePath string) bool {
+				evictedFiles = append(evictedFiles, dataFilePath)
+				return true
+			},
+		}))
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = store.Get("dog")
+
+		assert.Equal(t, []string{filepath.Join(dbPath, dataFiles[0])}, evictedFiles)
+		assert.Equal(t, []string{strings.TrimRight(dataFiles[1], ".cky")}, store.dataFiles)
+		assert.FileExists(t, filepath.Join(dbPath, ArchiveFolderName, dataFiles[0]))
+		assert.True(t, errors.Is(err, ErrNotFound))
+	})
+
+	t.Run("RetentionPolicyShouldBeVetoedIfBeforeEvictReturnsFalse", func(t *testing.T) {
+		expectedDataFiles := make([]string, len(dataFiles))
+		for i, file := range dataFiles {
+			expectedDataFiles[i] = strings.TrimRight(file, ".cky")
+		}
+
+		err := AddDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = ClearDummyFileDataInDb(dbPath) }()
+
+		store := NewStore(dbPath, maxFileSizeKB, WithRetentionPolicy(RetentionPolicy{
+			MaxDataFiles: 1,
+			Action:       RetentionDelete,
+			BeforeEvict:  func(dataFilePath string) bool { return false },
+		}))
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		value, err := store.Get("cow")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, expectedDataFiles, store.dataFiles)
+		assert.Equal(t, "500 months", value)
+	})
+
+	t.Run("LoadWithRetentionShouldDropDataFilesWhoseKeysAreAllOlderThanRetentionPeriod", func(t *testing.T) {
+		expectedIndex := map[string]string{
+			"goat": "1655404770518678-goat",
+			"hen":  "1655404670510698-hen",
+			"pig":  "1655404770534578-pig",
+			"fish": "1655403775538278-fish",
+		}
+
+		err := AddDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = ClearDummyFileDataInDb(dbPath) }()
+
+		store := NewStore(dbPath, maxFileSizeKB, WithRetention(24*time.Hour))
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ckyFiles, err := ReadFilesWithExtension(dbPath, DataFileExt)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, 0, len(store.dataFiles))
+		assert.Equal(t, 0, len(ckyFiles))
+		assert.Equal(t, expectedIndex, store.index)
+	})
+
+	t.Run("LoadWithRetentionShouldForgetTheDroppedKeysAsDeleteDoes", func(t *testing.T) {
+		err := AddDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = ClearDummyFileDataInDb(dbPath) }()
+
+		store := NewStore(dbPath, maxFileSizeKB)
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = store.Expire("dog", 100*365*24*time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var dropped []string
+		store = NewStore(dbPath, maxFileSizeKB, WithOplog(true), WithRetention(24*time.Hour),
+			WithDroppedKeyHandler(func(key string) { dropped = append(dropped, key) }))
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var deleted []string
+		for entry, err := range store.ReadOplog(0) {
+			if err != nil {
+				t.Fatal(err)
+			}
+			if entry.Op == OplogDelete {
+				deleted = append(deleted, entry.Key)
+			}
+		}
+
+		assert.ElementsMatch(t, []string{"cow", "dog"}, dropped)
+		assert.ElementsMatch(t, []string{"cow", "dog"}, deleted)
+		assert.NotContains(t, store.expiries, "dog")
+	})
+
+	t.Run("LoadWithRetentionShouldKeepDataFilesWithKeysWithinRetentionPeriod", func(t *testing.T) {
+		expectedDataFiles := make([]string, len(dataFiles))
+		for i, file := range dataFiles {
+			expectedDataFiles[i] = strings.TrimRight(file, ".cky")
+		}
+
+		err := AddDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = ClearDummyFileDataInDb(dbPath) }()
+
+		store := NewStore(dbPath, maxFileSizeKB, WithRetention(100*365*24*time.Hour))
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, expectedDataFiles, store.dataFiles)
+	})
+
+	t.Run("ConcurrentGetsOfColdKeysInSameDataFileShouldLoadTheDataFileOnce", func(t *testing.T) {
+		expected := map[string]string{"cow": "500 months", "dog": "23 months"}
+		var wg sync.WaitGroup
+
+		err := AddDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = ClearDummyFileDataInDb(dbPath) }()
+
+		store := NewStore(dbPath, maxFileSizeKB)
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for i := 0; i < 20; i++ {
+			key := []string{"cow", "dog"}[i%2]
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				value, err := store.Get(key)
+				assert.Nil(t, err)
+				assert.Equal(t, expected[key], value)
+			}()
+		}
+		wg.Wait()
+
+		stats := store.Stats()
+
+		assert.Equal(t, int64(1), stats.CacheLoads)
+		assert.Equal(t, int64(20), stats.CacheHits+stats.CacheMisses)
+	})
+
+	t.Run("GetOldKeyWithCachePrefetchShouldPrefetchTheNextDataFile", func(t *testing.T) {
+		err := AddDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = ClearDummyFileDataInDb(dbPath) }()
+
+		// add "bar" to the second data file
+		err = PersistMapDataToFile(map[string]string{"1655375120328186500-bar": "foo"}, filepath.Join(dbPath, dataFiles[1]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		f, err := os.OpenFile(indexFilePath, os.O_APPEND|os.O_WRONLY, 0777)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = f.WriteString(fmt.Sprintf("bar%s1655375120328186500-bar%s", KeyValueSeparator, TokenSeparator))
+		_ = f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		store := NewStore(dbPath, maxFileSizeKB, WithCachePrefetch(true))
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = store.Get("cow")
+		if err != nil {
+			t.Fatal(err)
+		}
+		store.prefetchWaitGroup.Wait()
+		loadsAfterPrefetch := store.Stats().CacheLoads
+
+		// remove the second data file to show that the value is got from the prefetched cache
+		err = os.Remove(filepath.Join(dbPath, dataFiles[1]))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		value, err := store.Get("bar")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, int64(2), loadsAfterPrefetch)
+		assert.Equal(t, int64(2), store.Stats().CacheLoads)
+		assert.Equal(t, "foo", value)
+		assert.Equal(t, strings.TrimRight(dataFiles[1], ".cky"), store.cache.start)
+	})
+
+	t.Run("SetOldKeyShouldDiscardThePrefetchedCache", func(t *testing.T) {
+		err := AddDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = ClearDummyFileDataInDb(dbPath) }()
+
+		store := NewStore(dbPath, maxFileSizeKB, WithCachePrefetch(true))
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = store.Get("cow")
+		if err != nil {
+			t.Fatal(err)
+		}
+		store.prefetchWaitGroup.Wait()
+		prefetchedBeforeSet := store.prefetched
+
+		err = store.Set("dog", "24 months")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.NotNil(t, prefetchedBeforeSet)
+		assert.Nil(t, store.prefetched)
+	})
+
+	t.Run("SetShouldTimestampKeysAndLogFilesWithTheClock", func(t *testing.T) {
+		now := time.Unix(1700000000, 0)
+		clock := NewFakeClock(now)
+		store := NewStore(dbPath, maxFileSizeKB, WithClock(clock))
+		defer func() { _ = ClearDummyFileDataInDb(dbPath) }()
+		err := ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		clock.Advance(time.Second)
+		err = store.Set("foo", "bar")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, fmt.Sprintf("%d", now.UnixNano()), store.currentLogFile)
+		assert.Equal(t, fmt.Sprintf("%d-foo", now.Add(time.Second).UnixNano()), store.index["foo"])
+	})
+
+	t.Run("TimestampsShouldNotCollideWhenTheClockStandsStill", func(t *testing.T) {
+		clock := NewFakeClock(time.Unix(1700000000, 0))
+		store := NewStore(dbPath, maxFileSizeKB, WithClock(clock))
+		defer func() { _ = ClearDummyFileDataInDb(dbPath) }()
+		err := ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// every few sets roll the log file at the very same instant as the keys were set
+		for i := 0; i < 20; i++ {
+			err = store.Set(fmt.Sprintf("key-%d", i), fmt.Sprintf("value-%d", i))
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		assert.Greater(t, len(store.dataFiles), 1)
+		timestampedKeys := map[string]bool{}
+		for i := 0; i < 20; i++ {
+			key := fmt.Sprintf("key-%d", i)
+			timestampedKeys[store.index[key]] = true
+
+			value, err := store.Get(key)
+			assert.Nil(t, err)
+			assert.Equal(t, fmt.Sprintf("value-%d", i), value)
+		}
+		assert.Equal(t, 20, len(timestampedKeys))
+	})
+
+	t.Run("LoadShouldNotReuseTimestampsWhenTheClockGoesBackwards", func(t *testing.T) {
+		now := time.Unix(1700000000, 0)
+		clock := NewFakeClock(now)
+		defer func() { _ = ClearDummyFileDataInDb(dbPath) }()
+		err := ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		store := NewStore(dbPath, maxFileSizeKB, WithClock(clock))
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = store.Set("foo", "bar")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		clock.Set(now.Add(-time.Hour))
+		store = NewStore(dbPath, maxFileSizeKB, WithClock(clock))
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = store.Set("baz", "qux")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Greater(t, store.index["baz"], store.index["foo"])
+		value, err := store.Get("foo")
+		assert.Nil(t, err)
+		assert.Equal(t, "bar", value)
+	})
+
+	t.Run("LoadWithRetentionShouldUseTheClock", func(t *testing.T) {
+		err := ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = AddDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = ClearDummyFileDataInDb(dbPath) }()
+
+		// with the clock just after the dummy log file was created, none of the data files is expired
+		clock := NewFakeClock(time.Unix(0, 1655375171402014000).Add(time.Minute))
+		store := NewStore(dbPath, maxFileSizeKB, WithClock(clock), WithRetention(time.Hour))
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, []string{"1655375120328185000", "1655375120328186000"}, store.dataFiles)
+	})
+
+	t.Run("CompactShouldMergeAdjacentDataFilesThatFitInTheMaxFileSize", func(t *testing.T) {
+		defer func() { _ = ClearDummyFileDataInDb(dbPath) }()
+		err := ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = addManyDataFilesInDb(dbPath, 6, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// each data file is about 100 bytes, so two fit in 250 bytes but three do not
+		store := NewStore(dbPath, 250.0/1024)
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		originalDataFiles := append([]string{}, store.dataFiles...)
+
+		err = store.Compact()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, []string{originalDataFiles[0], originalDataFiles[2], originalDataFiles[4]}, store.dataFiles)
+		dataFilesOnDisk, err := ReadFilesWithExtension(dbPath, DataFileExt)
+		assert.Nil(t, err)
+		assert.Equal(t, 3, len(dataFilesOnDisk))
+		for i := 0; i < 6; i++ {
+			for j := 0; j < 2; j++ {
+				value, err := store.Get(fmt.Sprintf("key-%d-%d", i, j))
+				assert.Nil(t, err)
+				assert.Equal(t, fmt.Sprintf("value-%d-%d", i, j), value)
+			}
+		}
+	})
+
+	t.Run("SnapshotShouldNotSeeChangesMadeAfterItWasTaken", func(t *testing.T) {
+		defer func() { _ = ClearDummyFileDataInDb(dbPath) }()
+		err := ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = AddDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		store := NewStore(dbPath, maxFileSizeKB)
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := map[string]string{}
+		for _, key := range store.Keys() {
+			expected[key], err = store.Get(key)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		snap, err := store.Snapshot()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = store.Set("cow", "updated")
+		assert.Nil(t, err)
+		err = store.Set("goat", "updated")
+		assert.Nil(t, err)
+		err = store.Set("new", "value")
+		assert.Nil(t, err)
+		err = store.Delete("dog")
+		assert.Nil(t, err)
+		err = store.Delete("pig")
+		assert.Nil(t, err)
+		err = store.Vacuum()
+		assert.Nil(t, err)
+
+		got := map[string]string{}
+		err = snap.ForEach(func(key string, value string) error {
+			got[key] = value
+			return nil
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, expected, got)
+		assert.Equal(t, len(expected), snap.Len())
+
+		err = snap.Close()
+		assert.Nil(t, err)
+		snapshots, err := GetFileOrFolderNamesInFolder(filepath.Join(dbPath, SnapshotsFolderName))
+		assert.Nil(t, err)
+		assert.Empty(t, snapshots)
+	})
+
+	t.Run("LoadShouldRemoveSnapshotsLeftBehind", func(t *testing.T) {
+		defer func() { _ = ClearDummyFileDataInDb(dbPath) }()
+		err := ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = AddDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		store := NewStore(dbPath, maxFileSizeKB)
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = store.Snapshot()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = NewStore(dbPath, maxFileSizeKB).Load()
+		assert.Nil(t, err)
+		_, err = os.Stat(filepath.Join(dbPath, SnapshotsFolderName))
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("GetManyShouldTellMissingKeysFromEmptyValues", func(t *testing.T) {
+		err := AddDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = ClearDummyFileDataInDb(dbPath) }()
+
+		store := NewStore(dbPath, maxFileSizeKB)
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = store.Set("empty", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		results := store.GetManyWithStats([]string{"dog", "missing", "goat", "empty", "cow", "bar"}, nil)
+		assert.Equal(t, []GetResult{
+			{Value: "23 months", Found: true},
+			{},
+			{Value: "678 months", Found: true},
+			{Value: "", Found: true},
+			{Value: "500 months", Found: true},
+			{},
+		}, results)
+		assert.Equal(t, int64(1), store.Stats().CacheLoads)
+	})
+
+	t.Run("GetManyShouldLoadEachColdDataFileOnceInParallel", func(t *testing.T) {
+		err := ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = ClearDummyFileDataInDb(dbPath) }()
+
+		store := NewStore(dbPath, 0.1)
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var keys []string
+		for i := 0; i < 40; i++ {
+			key := fmt.Sprintf("key%d", i)
+			err = store.Set(key, fmt.Sprintf("value of key %d", i))
+			if err != nil {
+				t.Fatal(err)
+			}
+			keys = append(keys, key)
+		}
+		assert.Greater(t, len(store.dataFiles), 2)
+
+		st := &OpStats{}
+		results := store.GetManyWithStats(append(keys, "missing"), st)
+		for i, key := range keys {
+			assert.Equal(t, GetResult{Value: fmt.Sprintf("value of key %d", i), Found: true}, results[i], key)
+		}
+		assert.Equal(t, GetResult{}, results[len(keys)])
+		assert.Equal(t, int64(len(store.dataFiles)), store.Stats().CacheLoads)
+		assert.Len(t, st.FilesTouched, len(store.dataFiles))
+		assert.Equal(t, store.dataFiles[len(store.dataFiles)-1], store.cache.start)
+	})
+
+	t.Run("KeysByAgeShouldOrderKeysByTheirTimestampedKeys", func(t *testing.T) {
+		err := ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = AddDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = ClearDummyFileDataInDb(dbPath) }()
+		store := NewStore(dbPath, maxFileSizeKB)
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		keys, err := store.KeysByAge(0, true)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"cow", "dog", "fish", "hen", "goat", "pig"}, keys)
+
+		// updating a key keeps its age
+		err = store.Set("cow", "501 months")
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys, err = store.KeysByAge(2, true)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"cow", "dog"}, keys)
+
+		err = store.Set("horse", "12 months")
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys, err = store.KeysByAge(2, false)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"horse", "pig"}, keys)
+	})
+
+	t.Run("LoadWithoutVacuumShouldLeaveTheKeysMarkedForDeletionOnDisk", func(t *testing.T) {
+		err := ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = AddDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = ClearDummyFileDataInDb(dbPath) }()
+
+		// index batching rebuilds the keys missing from the index, which must not bring the deleted ones back
+		store := NewStore(dbPath, maxFileSizeKB, WithVacuumOnLoad(false), WithIndexBatching(10, time.Hour))
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.ElementsMatch(t, []string{"cow", "dog", "goat", "hen", "pig", "fish"}, store.Keys())
+		for _, key := range []string{"foo", "bar"} {
+			_, err = store.Get(key)
+			assert.Equal(t, ErrNotFound, err)
+		}
+		keysToDelete, err := store.getKeysToDelete()
+		assert.Nil(t, err)
+		assert.ElementsMatch(t, []string{"1655403795838278-foo", "1655375171402014000-bar"}, keysToDelete)
+
+		err = store.Vacuum()
+		assert.Nil(t, err)
+		keysToDelete, err = store.getKeysToDelete()
+		assert.Nil(t, err)
+		assert.Empty(t, keysToDelete)
+		logContents, err := ReadFilesWithExtension(dbPath, LogFileExt)
+		assert.Nil(t, err)
+		assert.NotContains(t, strings.Join(logContents, ""), "foo")
+	})
+
+	t.Run("ClearShouldMoveTheFilesAsideToBeDeletedLater", func(t *testing.T) {
+		err := AddDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = ClearDummyFileDataInDb(dbPath) }()
+
+		store := NewStore(dbPath, maxFileSizeKB)
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = store.Clear()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		clearedFolders, err := filepath.Glob(dbPath + ClearedFolderInfix + "*")
+		assert.Nil(t, err)
+		assert.Len(t, clearedFolders, 1)
+		ckyFiles, err := ReadFilesWithExtension(clearedFolders[0], DataFileExt)
+		assert.Nil(t, err)
+		assert.Len(t, ckyFiles, 2)
+		assert.Empty(t, store.Keys())
+
+		// reloading leaves them too
+		err = store.Load()
+		assert.Nil(t, err)
+		_, err = os.Stat(clearedFolders[0])
+		assert.Nil(t, err)
+
+		paths, err := store.ClearedFolders()
+		assert.Nil(t, err)
+		assert.Equal(t, clearedFolders, paths)
+		err = RemoveClearedFolders(paths)
+		assert.Nil(t, err)
+		_, err = os.Stat(clearedFolders[0])
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("FailedLogWriteShouldLeaveTheMemtableUnchanged", func(t *testing.T) {
+		err := ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = AddDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = ClearDummyFileDataInDb(dbPath) }()
+
+		fs := &faultyFileSystem{}
+		store := NewStore(dbPath, maxFileSizeKB, WithFileSystem(fs))
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		memtable := maps.Clone(store.memtable)
+
+		for _, key := range []string{"goat", "new"} {
+			timestampedKey, ok := store.index[key]
+			if !ok {
+				timestampedKey = store.currentLogFile + "-" + key
+			}
+
+			fs.arm(1, faultError)
+			err = store.saveKeyValueToMemtable(timestampedKey, "changed", nil)
+			assert.ErrorIs(t, err, errInjected)
+			assert.Equal(t, memtable, store.memtable)
+		}
+	})
+
+	t.Run("VacuumShouldRewriteAtMostMaintenanceWorkersFilesAtATime", func(t *testing.T) {
+		for _, workers := range []int{1, 3} {
+			err := ClearDummyFileDataInDb(dbPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			keysToDelete, err := addManyDataFilesInDb(dbPath, 8, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			fs := &concurrencyFileSystem{}
+			store := NewStore(dbPath, maxFileSizeKB, WithFileSystem(fs), WithMaintenanceWorkers(workers))
+			err = store.Load()
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = os.WriteFile(filepath.Join(dbPath, DelFilename), []byte(keysToDelete), 0777)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			fs.reset()
+			err = store.Vacuum()
+			assert.Nil(t, err)
+			assert.Equal(t, workers, fs.maxWrites)
+		}
+		_ = ClearDummyFileDataInDb(dbPath)
+
+		store := NewStore(dbPath, maxFileSizeKB)
+		assert.Equal(t, max(runtime.GOMAXPROCS(0)/2, 1), store.getMaintenanceWorkers())
+	})
+}
+
+func BenchmarkStoreLoad(b *testing.B) {
+	dbPath, err := filepath.Abs("benchStoreDb")
+	if err != nil {
+		b.Fatal(err)
+	}
+	maxFileSizeKB := 320.0 / 1024
+	defer func() { _ = ClearDummyFileDataInDb(dbPath) }()
+
+	// the files are vacuumed by as many workers as by default, or by one, as before Load was parallelized
+	for _, size := range []struct{ numOfDataFiles, keysPerFile int }{{2, 10}, {20, 1000}, {200, 100}} {
+		for _, workers := range []int{0, 1} {
+			name := fmt.Sprintf("DataFiles=%d/Keys=%d/Workers=%d", size.numOfDataFiles, size.numOfDataFiles*size.keysPerFile, workers)
+			b.Run(name, func(b *testing.B) {
+				err := ClearDummyFileDataInDb(dbPath)
+				if err != nil {
+					b.Fatal(err)
+				}
+
+				keysToDelete, err := addManyDataFilesInDb(dbPath, size.numOfDataFiles, size.keysPerFile)
+				if err != nil {
+					b.Fatal(err)
+				}
+
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					b.StopTimer()
+					// mark some keys for deletion so that every Load has vacuuming to do
+					err = os.WriteFile(filepath.Join(dbPath, DelFilename), []byte(keysToDelete), 0777)
+					if err != nil {
+						b.Fatal(err)
+					}
+					b.StartTimer()
+
+					store := NewStore(dbPath, maxFileSizeKB, WithMaintenanceWorkers(workers))
+					err = store.Load()
+					if err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkSaveKeyValueToMemtable(b *testing.B) {
+	dbPath, err := filepath.Abs("benchMemtableDb")
+	if err != nil {
+		b.Fatal(err)
+	}
+	numOfKeys := 100_000
+
+	err = ClearDummyFileDataInDb(dbPath)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer func() { _ = ClearDummyFileDataInDb(dbPath) }()
+
+	// the log file is never rolled so that every save rewrites it with all the keys
+	store := NewStore(dbPath, 1024*1024)
+	err = store.Load()
+	if err != nil {
+		b.Fatal(err)
+	}
+	for i := 0; i < numOfKeys; i++ {
+		store.memtable[fmt.Sprintf("%s-key-%d", store.currentLogFile, i)] = fmt.Sprintf("value-%d", i)
+	}
+	timestampedKey := store.currentLogFile + "-key-0"
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		err = store.saveKeyValueToMemtable(timestampedKey, fmt.Sprintf("value-%d", i), nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkIndexMemory(b *testing.B) {
+	dbPath, err := filepath.Abs("benchIndexMemoryDb")
+	if err != nil {
+		b.Fatal(err)
+	}
+	numOfDataFiles, keysPerFile := 1000, 1000
+
+	err = ClearDummyFileDataInDb(dbPath)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer func() { _ = ClearDummyFileDataInDb(dbPath) }()
+
+	_, err = addManyDataFilesInDb(dbPath, numOfDataFiles, keysPerFile)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var before, after runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+
+		store := NewStore(dbPath, 320.0/1024)
+		err = store.Load()
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		runtime.GC()
+		runtime.ReadMemStats(&after)
+		b.ReportMetric(float64(after.HeapAlloc-before.HeapAlloc)/float64(len(store.index)), "heap-B/key")
+		b.ReportMetric(float64(after.HeapObjects-before.HeapObjects), "heap-objects")
+		runtime.KeepAlive(store)
+	}
+}
+
+// addManyDataFilesInDb creates a database with the given number of data files, each with
+// keysPerFile keys, returning the del file content for deleting one key in each data file
+func addManyDataFilesInDb(dbPath string, numOfDataFiles int, keysPerFile int) (string, error) {
+	err := os.MkdirAll(dbPath, 0777)
+	if err != nil {
+		return "", err
+	}
+
+	start := time.Now().Add(-time.Hour).UnixNano()
+	index := map[string]string{}
+	keysToDelete := ""
+	for i := 0; i < numOfDataFiles; i++ {
+		fileTimestamp := start + int64(i*keysPerFile*10)
+		data := make(map[string]string, keysPerFile)
+		for j := 0; j < keysPerFile; j++ {
+			key := fmt.Sprintf("key-%d-%d", i, j)
+			timestampedKey := fmt.Sprintf("%d-%s", fileTimestamp+int64(j), key)
+			data[timestampedKey] = fmt.Sprintf("value-%d-%d", i, j)
+			index[key] = timestampedKey
+		}
+
+		keysToDelete += fmt.Sprintf("%d-key-%d-0%s", fileTimestamp, i, TokenSeparator)
+		err = PersistMapDataToFile(data, filepath.Join(dbPath, fmt.Sprintf("%d.%s", fileTimestamp, DataFileExt)))
+		if err != nil {
+			return "", err
+		}
+	}
+
+	err = PersistMapDataToFile(index, filepath.Join(dbPath, IndexFilename))
+	if err != nil {
+		return "", err
+	}
+
+	logFilename := fmt.Sprintf("%d.%s", time.Now().UnixNano(), LogFileExt)
+	err = CreateFileIfNotExist(filepath.Join(dbPath, logFilename))
+	return keysToDelete, err
+}
+
+// concurrencyFileSystem is a FileSystem recording the most files written at the same time, each write taking
+// a while so that concurrent writes overlap
+type concurrencyFileSystem struct {
+	osFileSystem
+	writes    int
+	maxWrites int
+	lock      sync.Mutex
+}
+
+// reset forgets the writes recorded so far
+func (f *concurrencyFileSystem) reset() {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.maxWrites = 0
+}
+
+func (f *concurrencyFileSystem) WriteFile(path string, data []byte) error {
+	f.lock.Lock()
+	f.writes++
+	f.maxWrites = max(f.maxWrites, f.writes)
+	f.lock.Unlock()
+
+	time.Sleep(20 * time.Millisecond)
+	err := f.osFileSystem.WriteFile(path, data)
+
+	f.lock.Lock()
+	f.writes--
+	f.lock.Unlock()
+	return err
 }
@@ -0,0 +1,170 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"hash"
+	"io"
+	"os"
+	"strings"
+)
+
+// SetReader sets the value of the given key to the contents of r, streaming them into a spilled blob so that
+// they never have to fit in memory. Databases without value flags can have no blobs, and the oplog and history
+// mode need whole values, so in those cases the contents are read into memory and set like with Set
+func (s *Store) SetReader(key string, r io.Reader) error {
+	return s.SetReaderWithStats(key, r, nil)
+}
+
+// SetReaderWithStats is like SetReader but it also records what it did in st
+func (s *Store) SetReaderWithStats(key string, r io.Reader, st *OpStats) error {
+	if !s.valueFlags || s.isOplogEnabled || s.historyPolicy != nil {
+		value, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+
+		return s.SetWithStats(key, string(value), st)
+	}
+
+	original, key := key, s.foldKey(key)
+	return s.guardWrite(func() error {
+		err := checkUserKey(key)
+		if err != nil {
+			return err
+		}
+
+		err = s.separators.validateKeyValue(key, "")
+		if err != nil {
+			return err
+		}
+
+		err = s.ensureCapacityFor(key, st)
+		if err != nil {
+			return err
+		}
+
+		// the contents are not kept in memory, so the read back is checked against their digest
+		var digest hash.Hash
+		if s.checkInvariants {
+			digest = sha256.New()
+			r = io.TeeReader(r, digest)
+		}
+
+		name, size, err := s.streamSpilledBlob(r, st)
+		if err != nil {
+			return err
+		}
+
+		// the blob is already on disk so the quota is checked as if the value were empty
+		var timestampedKey string
+		err = s.ensureQuotaFor(key, "")
+		if err == nil {
+			timestampedKey, err = s.saveStoredValue(key, string(spilledValueFlag)+name, st)
+		}
+		if err != nil {
+			_ = s.fs.Remove(getBlobFilePath(s.blobsPath(), name))
+			return err
+		}
+
+		if digest != nil {
+			err = s.checkStreamedReadBack(timestampedKey, size, digest.Sum(nil))
+			if err != nil {
+				return err
+			}
+		}
+
+		s.useKey(key)
+		s.io.keyValueBytesWritten.Add(int64(len(key)) + size)
+		err = s.setCasing(key, original, st)
+		if err != nil {
+			return err
+		}
+
+		return s.removeExpiry(key, st)
+	})
+}
+
+// GetReader returns a reader of the value of the given key, which reads it straight from its blob if it has one,
+// so that it never has to fit in memory. The reader must be closed once done with.
+// It returns an ErrNotFound error if the key is nonexistent
+func (s *Store) GetReader(key string) (io.ReadCloser, error) {
+	return s.GetReaderWithStats(key, nil)
+}
+
+// GetReaderWithStats is like GetReader but it also records what it did in st
+func (s *Store) GetReaderWithStats(key string, st *OpStats) (io.ReadCloser, error) {
+	key = s.foldKey(key)
+	timestampedKey, ok := s.lookup(key)
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	sealed, err := s.getStoredValueForKey(timestampedKey, st)
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := unsealRecord(timestampedKey, sealed, s.recordMACKey())
+	if err != nil {
+		return nil, err
+	}
+
+	if name, ok := blobReference(stored); ok && s.valueFlags {
+		f, err := os.Open(getBlobFilePath(s.blobsPath(), name))
+		if os.IsNotExist(err) {
+			return nil, ErrCorruptedData
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		s.useKey(key)
+		return f, nil
+	}
+
+	value, err := decodeValue(stored, s.valueFlags, s.blobsPath())
+	if err != nil {
+		return nil, err
+	}
+
+	s.useKey(key)
+	return io.NopCloser(strings.NewReader(value)), nil
+}
+
+// streamSpilledBlob copies the contents of r into a new spilled blob, returning its name and size. The contents are
+// written to a temporary file first which is then renamed so that a failure midway leaves no blob behind
+func (s *Store) streamSpilledBlob(r io.Reader, st *OpStats) (string, int64, error) {
+	name, err := newSpilledBlobName()
+	if err != nil {
+		return "", 0, err
+	}
+
+	err = os.MkdirAll(s.blobsPath(), 0777)
+	if err != nil {
+		return "", 0, err
+	}
+
+	path := getBlobFilePath(s.blobsPath(), name)
+	tempFilePath := path + "." + TempFileExt
+	f, err := os.Create(tempFilePath)
+	if err != nil {
+		return "", 0, err
+	}
+
+	n, err := io.Copy(f, r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = s.fs.Rename(tempFilePath, path)
+	}
+	if err != nil {
+		_ = os.Remove(tempFilePath)
+		return "", 0, err
+	}
+	// the blob is written straight to disk rather than through the counting file system
+	s.io.bytesWritten.Add(n)
+	st.recordWrite(path, int(n))
+
+	return name, n, nil
+}
@@ -0,0 +1,127 @@
+package internal
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStream(t *testing.T) {
+	dbPath, err := filepath.Abs("testStreamDb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	blob := strings.Repeat("a streamed value ", 1000)
+	defer func() { _ = ClearDummyFileDataInDb(dbPath) }()
+
+	// newStore returns a loaded store on an empty database folder with the given options
+	newStore := func(t *testing.T, opts ...StoreOption) *Store {
+		err := ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		store := NewStore(dbPath, 4, opts...)
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return store
+	}
+
+	// readAll reads the whole value of the key through GetReader
+	readAll := func(t *testing.T, store *Store, key string) string {
+		r, err := store.GetReader(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = r.Close() }()
+
+		data, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return string(data)
+	}
+
+	t.Run("SetReaderShouldStreamTheValueIntoASpilledBlob", func(t *testing.T) {
+		store := newStore(t, WithBlobSpillThreshold(1<<20))
+		assert.Nil(t, store.SetReader("cow", strings.NewReader(blob)))
+
+		blobs, err := GetFileOrFolderNamesInFolder(filepath.Join(dbPath, BlobsFolderName))
+		assert.Nil(t, err)
+		assert.Len(t, blobs, 1)
+		assert.Equal(t, byte(spilledValueFlag), store.memtable[store.index["cow"]][0])
+
+		value, err := store.Get("cow")
+		assert.Nil(t, err)
+		assert.Equal(t, blob, value)
+		assert.Equal(t, blob, readAll(t, store, "cow"))
+	})
+
+	t.Run("GetReaderShouldReadValuesOfEveryKind", func(t *testing.T) {
+		store := newStore(t, WithValueCompressionThreshold(64))
+		assert.Nil(t, store.Set("cow", blob))
+		assert.Nil(t, store.Set("dog", "23 months"))
+
+		assert.Equal(t, blob, readAll(t, store, "cow"))
+		assert.Equal(t, "23 months", readAll(t, store, "dog"))
+		_, err := store.GetReader("goat")
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("SetReaderShouldSetWholeValuesWithoutValueFlags", func(t *testing.T) {
+		store := newStore(t)
+		assert.Nil(t, store.SetReader("cow", strings.NewReader(blob)))
+
+		assert.NoDirExists(t, filepath.Join(dbPath, BlobsFolderName))
+		assert.Equal(t, blob, readAll(t, store, "cow"))
+	})
+
+	t.Run("SetReaderShouldRejectInvalidKeys", func(t *testing.T) {
+		store := newStore(t, WithBlobSpillThreshold(1<<20))
+		err := store.SetReader("c"+TokenSeparator+"ow", strings.NewReader(blob))
+		assert.ErrorIs(t, err, ErrInvalidKeyValue)
+		assert.NoDirExists(t, filepath.Join(dbPath, BlobsFolderName))
+	})
+
+	t.Run("SetReaderShouldCountTheKeyAndValueBytesWritten", func(t *testing.T) {
+		store := newStore(t, WithBlobSpillThreshold(1<<20))
+		assert.Nil(t, store.SetReader("cow", strings.NewReader(blob)))
+
+		stats := store.Stats()
+		assert.Equal(t, int64(len("cow")+len(blob)), stats.KeyValueBytesWritten)
+		assert.Greater(t, stats.WriteAmplification, 1.0)
+	})
+
+	t.Run("SetReaderShouldCheckThatTheBlobIsReadBackWithInvariantChecks", func(t *testing.T) {
+		store := newStore(t, WithBlobSpillThreshold(1<<20), WithInvariantChecks(true))
+		assert.Nil(t, store.SetReader("cow", strings.NewReader(blob)))
+
+		store = newStore(t, WithBlobSpillThreshold(1<<20), WithInvariantChecks(true),
+			WithFileSystem(blobTruncatingFileSystem{}))
+		err := store.SetReader("cow", strings.NewReader(blob))
+		assert.ErrorIs(t, err, ErrInvariantViolated)
+	})
+}
+
+// blobTruncatingFileSystem is a FileSystem that truncates every blob it renames into place, as a disk
+// losing the end of the blob would
+type blobTruncatingFileSystem struct {
+	osFileSystem
+}
+
+func (f blobTruncatingFileSystem) Rename(oldPath string, newPath string) error {
+	err := f.osFileSystem.Rename(oldPath, newPath)
+	if err != nil || filepath.Ext(newPath) != "."+BlobFileExt {
+		return err
+	}
+
+	return os.Truncate(newPath, 1)
+}
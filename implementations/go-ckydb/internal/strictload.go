@@ -0,0 +1,86 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// SkippedRecord is a malformed record of a file that Load skipped as it is not strict
+type SkippedRecord struct {
+	// File is the name of the file e.g. "index.idx"
+	File string
+	// Offset is the byte offset in the file at which the record starts
+	Offset int
+}
+
+// WithStrictLoad sets whether Load fails with an ErrCorruptedData error on any malformed record, which it does
+// by default. When it is false, the malformed records of the files read by Load and by vacuuming are skipped,
+// counted in Stats and passed to the handler set by WithSkippedRecordHandler, and a corrupted index or del file
+// without a good backup is kept as it is. Files rewritten by vacuuming no longer hold the records skipped.
+// The data files are only parsed leniently by vacuuming, not when they are read into the cache
+func WithStrictLoad(isStrict bool) StoreOption {
+	return func(s *Store) {
+		s.lenientLoad = !isStrict
+	}
+}
+
+// WithSkippedRecordHandler sets the function called with every malformed record skipped when Load is not strict
+func WithSkippedRecordHandler(fn func(record SkippedRecord)) StoreOption {
+	return func(s *Store) {
+		s.onSkippedRecord = fn
+	}
+}
+
+// extractLoadedRecords extracts the records of n fields each from data, the contents of the file at path.
+// If Load is not strict, the records that are malformed or rejected by isValid, if it is not nil, are skipped
+// and reported instead of failing with an ErrCorruptedData error. Strict callers do their own validation
+func (s *Store) extractLoadedRecords(path string, data []byte, n int, isValid func(fields []string) bool) ([][]string, error) {
+	if !s.lenientLoad {
+		return s.separators.extractRecords(data, n)
+	}
+
+	records, skipped := s.separators.extractValidRecords(data, n, isValid)
+	for _, offset := range skipped {
+		s.skippedRecords.Add(1)
+		if s.onSkippedRecord != nil {
+			s.onSkippedRecord(SkippedRecord{File: filepath.Base(path), Offset: offset})
+		}
+	}
+
+	return records, nil
+}
+
+// extractLoadedKeyValues is like extractLoadedRecords but it returns a map of keys and values
+func (s *Store) extractLoadedKeyValues(path string, data []byte, isValid func(key string, value string) bool) (map[string]string, error) {
+	var isValidRecord func(fields []string) bool
+	if isValid != nil {
+		isValidRecord = func(fields []string) bool { return isValid(fields[0], fields[1]) }
+	}
+
+	records, err := s.extractLoadedRecords(path, data, 2, isValidRecord)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]string, len(records))
+	for _, kv := range records {
+		result[kv[0]] = kv[1]
+	}
+
+	return result, nil
+}
+
+// readLoadedKeyValues reads the key value pairs in the file at path, like extractLoadedKeyValues
+func (s *Store) readLoadedKeyValues(path string, isValid func(key string, value string) bool) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.extractLoadedKeyValues(path, data, isValid)
+}
+
+// isTimestampedKeyValue checks whether the key of a record of a log or data file is a timestamped key
+func isTimestampedKeyValue(key string, _ string) bool {
+	return isTimestampedKey(key)
+}
@@ -0,0 +1,103 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStrictLoad(t *testing.T) {
+	dbPath, err := filepath.Abs("testStrictLoadDb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logFilename := "1655375171402014000.log"
+	defer func() { _ = ClearDummyFileDataInDb(dbPath) }()
+
+	// addCorruptedDummyData adds the dummy data with a malformed record appended to the log, index and del files,
+	// returning the records that a lenient Load skips
+	addCorruptedDummyData := func(t *testing.T) []SkippedRecord {
+		err := ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = AddDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var skipped []SkippedRecord
+		for filename, record := range map[string]string{
+			logFilename:   "garbage" + TokenSeparator,
+			IndexFilename: "junk" + KeyValueSeparator + "not-a-timestamped-key" + TokenSeparator,
+			DelFilename:   "junk" + TokenSeparator,
+		} {
+			path := filepath.Join(dbPath, filename)
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = osFileSystem{}.AppendFile(path, []byte(record))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			skipped = append(skipped, SkippedRecord{File: filename, Offset: int(info.Size())})
+		}
+
+		return skipped
+	}
+
+	t.Run("LoadShouldFailOnMalformedRecordsByDefault", func(t *testing.T) {
+		addCorruptedDummyData(t)
+		store := NewStore(dbPath, 4)
+		err := store.Load()
+		assert.Equal(t, ErrCorruptedData, err)
+	})
+
+	t.Run("LenientLoadShouldSkipAndReportMalformedRecords", func(t *testing.T) {
+		expected := addCorruptedDummyData(t)
+		var skipped []SkippedRecord
+		store := NewStore(dbPath, 4, WithStrictLoad(false), WithSkippedRecordHandler(func(record SkippedRecord) {
+			skipped = append(skipped, record)
+		}))
+		err := store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.ElementsMatch(t, expected, skipped)
+		assert.Equal(t, int64(3), store.Stats().SkippedRecords)
+		assert.ElementsMatch(t, []string{"cow", "dog", "goat", "hen", "pig", "fish"}, store.Keys())
+		for key, value := range map[string]string{"cow": "500 months", "goat": "678 months"} {
+			got, err := store.Get(key)
+			assert.Nil(t, err)
+			assert.Equal(t, value, got)
+		}
+
+		_, err = store.Get("junk")
+		assert.Equal(t, ErrNotFound, err)
+	})
+
+	t.Run("LenientLoadShouldRewriteTheVacuumedFilesWithoutTheMalformedRecords", func(t *testing.T) {
+		addCorruptedDummyData(t)
+		store := NewStore(dbPath, 4, WithStrictLoad(false))
+		err := store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := os.ReadFile(filepath.Join(dbPath, logFilename))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.NotContains(t, string(data), "garbage")
+
+		store = NewStore(dbPath, 4, WithStrictLoad(false))
+		err = store.Load()
+		assert.Nil(t, err)
+		assert.Equal(t, int64(1), store.Stats().SkippedRecords, "only the index, which is not vacuumed, still holds its malformed record")
+	})
+}
@@ -1,6 +1,9 @@
 package internal
 
 import (
+	"context"
+	"math/rand/v2"
+	"sync"
 	"time"
 )
 
@@ -8,67 +11,335 @@ type Worker interface {
 	Start() error
 	Stop() error
 	IsRunning() bool
+	RunOnce() error
+	Status() TaskStatus
+	SetInterval(interval time.Duration) error
+}
+
+// TaskStatus is the state of a Task at a given point in time
+type TaskStatus struct {
+	Name      string
+	IsRunning bool
+	// LastRun is when the work last started. It is zero if the work has never run
+	LastRun time.Time
+	// LastError is the error returned by the last run of the work, if any
+	LastError error
+	// NextRun is when the work is next due. It is zero if the task is not running
+	NextRun time.Time
+	// ConsecutiveFailures is the number of runs in a row, up to the last one, whose work returned an error
+	ConsecutiveFailures int
 }
 
 type Task struct {
-	done      chan bool
-	interval  time.Duration
-	work      func()
-	isRunning bool
+	name                string
+	lastRun             time.Time
+	lastError           error
+	nextRun             time.Time
+	run                 *taskRun
+	hasStarted          bool
+	runNow              chan chan bool
+	intervalChanged     chan bool
+	interval            time.Duration
+	initialDelay        time.Duration
+	hasInitialDelay     bool
+	jitter              time.Duration
+	isImmediateFirstRun bool
+	retryDelay          time.Duration
+	maxRetryDelay       time.Duration
+	consecutiveFailures int
+	onFailure           func(err error, consecutiveFailures int)
+	work                func() error
+	clock               Clock
+	lock                sync.Mutex
+}
+
+// taskRun is a single run of a Task, from Start to Stop
+type taskRun struct {
+	ctx      context.Context
+	cancel   context.CancelFunc
+	stopped  chan struct{}
+	stopOnce sync.Once
+}
+
+// TaskOption configures optional behaviour of a Task
+type TaskOption func(*Task)
+
+// NewTask creates a new Task with the given name that runs the work every interval
+func NewTask(name string, interval time.Duration, work func() error, opts ...TaskOption) *Task {
+	t := &Task{
+		name:            name,
+		runNow:          make(chan chan bool),
+		intervalChanged: make(chan bool, 1),
+		interval:        interval,
+		work:            work,
+		clock:           RealClock,
+	}
+
+	for _, opt := range opts {
+		opt(t)
+	}
+
+	return t
 }
 
-// NewTask creates a new Task
-func NewTask(interval time.Duration, work func()) *Task {
-	return &Task{
-		done:      make(chan bool),
-		interval:  interval,
-		work:      work,
-		isRunning: false,
+// WithTaskClock sets the Clock that paces the task. It defaults to the RealClock
+func WithTaskClock(clock Clock) TaskOption {
+	return func(t *Task) {
+		t.clock = clock
+	}
+}
+
+// WithInitialDelay sets how long after Start the work is first run, instead of the interval
+func WithInitialDelay(delay time.Duration) TaskOption {
+	return func(t *Task) {
+		t.initialDelay = delay
+		t.hasInitialDelay = true
+	}
+}
+
+// WithJitter lengthens every wait by a random duration of up to jitter so that
+// tasks started at the same time do not run in lockstep
+func WithJitter(jitter time.Duration) TaskOption {
+	return func(t *Task) {
+		t.jitter = jitter
+	}
+}
+
+// WithImmediateFirstRun makes the task run the work as soon as it is started
+func WithImmediateFirstRun() TaskOption {
+	return func(t *Task) {
+		t.isImmediateFirstRun = true
+	}
+}
+
+// WithRetryBackoff makes the task retry work that failed after delay instead of a full interval, doubling the delay
+// on every failure in a row up to maxDelay. The retries are never further apart than the interval, which is waited
+// for again once the work succeeds
+func WithRetryBackoff(delay time.Duration, maxDelay time.Duration) TaskOption {
+	return func(t *Task) {
+		t.retryDelay = delay
+		t.maxRetryDelay = maxDelay
+	}
+}
+
+// WithFailureHandler sets the function called with the error of every run of the work that fails,
+// and the number of runs in a row that have, e.g. to alert once it has failed a few times
+func WithFailureHandler(fn func(err error, consecutiveFailures int)) TaskOption {
+	return func(t *Task) {
+		t.onFailure = fn
 	}
 }
 
 // Start starts the task that runs the work in a go routine
 func (t *Task) Start() error {
-	if t.isRunning {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.run != nil {
 		return ErrAlreadyRunning
 	}
 
-	go func(ch chan bool, work func()) {
-		tick := time.NewTicker(t.interval)
-		defer tick.Stop()
+	ctx, cancel := context.WithCancel(context.Background())
+	run := &taskRun{ctx: ctx, cancel: cancel, stopped: make(chan struct{})}
+
+	// the first wait is started before the go routine so that it counts from now
+	wait := t.schedule(t.firstDelay())
+	go func() {
+		defer close(run.stopped)
 
 		for {
 			select {
-			case <-ch:
-				// respond back that it is done
-				ch <- true
+			case <-ctx.Done():
 				return
-			case <-tick.C:
-				work()
+			case done := <-t.runNow:
+				t.execute()
+				done <- true
+			case <-wait:
+				t.execute()
+			case <-t.intervalChanged:
 			}
-		}
-	}(t.done, t.work)
 
-	t.isRunning = true
+			t.lock.Lock()
+			wait = t.schedule(t.nextDelay(t.delayAfterRun()))
+			t.lock.Unlock()
+		}
+	}()
 
+	t.run = run
+	t.hasStarted = true
 	return nil
 }
 
-// Stop Sends an instruction to the task to stop running
+// Stop stops the task, waiting for any work in progress to complete.
+// It is safe to call concurrently; all concurrent calls return once the task has stopped.
+// Stopping a stopped task does nothing, while stopping one that was never started returns an ErrNotRunning error
 func (t *Task) Stop() error {
-	if !t.isRunning {
+	t.lock.Lock()
+	run, hasStarted := t.run, t.hasStarted
+	t.lock.Unlock()
+
+	if run == nil && hasStarted {
+		return nil
+	}
+	if run == nil {
 		return ErrNotRunning
 	}
 
-	t.done <- true
+	run.stopOnce.Do(func() {
+		run.cancel()
+		<-run.stopped
+
+		t.lock.Lock()
+		if t.run == run {
+			t.run = nil
+			t.nextRun = time.Time{}
+		}
+		t.lock.Unlock()
+	})
 
-	// wait for the task to respond back
-	<-t.done
-	t.isRunning = false
 	return nil
 }
 
 // IsRunning returns true if the task is still running
 func (t *Task) IsRunning() bool {
-	return t.isRunning
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	return t.run != nil
+}
+
+// RunOnce runs the work right away, returning the work's error when it is done. If the task is
+// running, the work is run in the task's go routine and the next run is a full interval later
+func (t *Task) RunOnce() error {
+	t.lock.Lock()
+	run := t.run
+	t.lock.Unlock()
+
+	if run == nil {
+		return t.execute()
+	}
+
+	done := make(chan bool, 1)
+	select {
+	case t.runNow <- done:
+		<-done
+	case <-run.ctx.Done():
+		// the task was stopped before it could pick up the request
+		return t.execute()
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	return t.lastError
+}
+
+// SetInterval changes the interval between runs of the work. If the task is running,
+// the next run is rescheduled to be the new interval from now
+func (t *Task) SetInterval(interval time.Duration) error {
+	if interval <= 0 {
+		return ErrOutOfBounds
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.interval = interval
+	if t.run != nil {
+		select {
+		case t.intervalChanged <- true:
+		default:
+			// a reschedule is already pending and it will use the new interval
+		}
+	}
+
+	return nil
+}
+
+// Status returns the current status of the task
+func (t *Task) Status() TaskStatus {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	return TaskStatus{
+		Name:      t.name,
+		IsRunning: t.run != nil,
+		LastRun:   t.lastRun,
+		LastError: t.lastError,
+		NextRun:   t.nextRun,
+
+		ConsecutiveFailures: t.consecutiveFailures,
+	}
+}
+
+// execute runs the work, recording when it ran and the error it returned
+func (t *Task) execute() error {
+	t.lock.Lock()
+	t.lastRun = t.clock.Now()
+	t.lock.Unlock()
+
+	err := t.work()
+
+	t.lock.Lock()
+	t.lastError = err
+	t.consecutiveFailures++
+	if err == nil {
+		t.consecutiveFailures = 0
+	}
+	failures, onFailure := t.consecutiveFailures, t.onFailure
+	t.lock.Unlock()
+
+	if err != nil && onFailure != nil {
+		onFailure(err, failures)
+	}
+
+	return err
+}
+
+// delayAfterRun returns how long to wait after the last run before running the work again: the interval,
+// or the retry delay for the number of failures in a row if the last run failed. It requires the lock to be held
+func (t *Task) delayAfterRun() time.Duration {
+	if t.retryDelay <= 0 || t.consecutiveFailures == 0 {
+		return t.interval
+	}
+
+	maxDelay := t.interval
+	if t.maxRetryDelay > 0 {
+		maxDelay = min(t.maxRetryDelay, t.interval)
+	}
+
+	delay := t.retryDelay
+	for i := 1; i < t.consecutiveFailures && delay < maxDelay; i++ {
+		delay *= 2
+	}
+
+	return min(delay, maxDelay)
+}
+
+// schedule returns a channel that receives the time once the delay has elapsed, recording
+// when the next run is due. It requires the lock to be held
+func (t *Task) schedule(delay time.Duration) <-chan time.Time {
+	t.nextRun = t.clock.Now().Add(delay)
+	return t.clock.After(delay)
+}
+
+// firstDelay returns how long to wait before running the work for the first time
+func (t *Task) firstDelay() time.Duration {
+	switch {
+	case t.isImmediateFirstRun:
+		return 0
+	case t.hasInitialDelay:
+		return t.nextDelay(t.initialDelay)
+	default:
+		return t.nextDelay(t.interval)
+	}
+}
+
+// nextDelay returns the given delay lengthened by a random duration of up to the jitter
+func (t *Task) nextDelay(delay time.Duration) time.Duration {
+	if t.jitter <= 0 {
+		return delay
+	}
+
+	return delay + rand.N(t.jitter)
 }
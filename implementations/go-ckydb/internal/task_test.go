@@ -0,0 +1,268 @@
+package internal
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTask(t *testing.T) {
+	interval := time.Minute
+
+	// newCountingTask creates a task on a fake clock that counts the number of times it has run
+	newCountingTask := func(opts ...TaskOption) (*Task, *FakeClock, *atomic.Int64) {
+		clock := NewFakeClock(time.Unix(1700000000, 0))
+		runs := &atomic.Int64{}
+		opts = append(opts, WithTaskClock(clock))
+		task := NewTask("counting", interval, func() error {
+			runs.Add(1)
+			return nil
+		}, opts...)
+		return task, clock, runs
+	}
+
+	// waitForRuns waits for the number of runs to reach n
+	waitForRuns := func(t *testing.T, runs *atomic.Int64, n int64) {
+		assert.Eventually(t, func() bool { return runs.Load() == n }, time.Second, time.Millisecond)
+	}
+
+	// advanceWhenWaiting advances the clock once the task is waiting on it
+	advanceWhenWaiting := func(t *testing.T, clock *FakeClock, d time.Duration) {
+		assert.Eventually(t, func() bool { return clock.Waiters() > 0 }, time.Second, time.Millisecond)
+		clock.Advance(d)
+	}
+
+	t.Run("TaskShouldRunAfterEveryInterval", func(t *testing.T) {
+		task, clock, runs := newCountingTask()
+		err := task.Start()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = task.Stop() }()
+
+		clock.Advance(interval - time.Second)
+		assert.Equal(t, int64(0), runs.Load())
+
+		clock.Advance(time.Second)
+		waitForRuns(t, runs, 1)
+		advanceWhenWaiting(t, clock, interval)
+		waitForRuns(t, runs, 2)
+	})
+
+	t.Run("TaskWithImmediateFirstRunShouldRunOnStart", func(t *testing.T) {
+		task, clock, runs := newCountingTask(WithImmediateFirstRun())
+		err := task.Start()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = task.Stop() }()
+
+		waitForRuns(t, runs, 1)
+		advanceWhenWaiting(t, clock, interval)
+		waitForRuns(t, runs, 2)
+	})
+
+	t.Run("TaskWithInitialDelayShouldFirstRunAfterTheDelay", func(t *testing.T) {
+		delay := 5 * time.Second
+		task, clock, runs := newCountingTask(WithInitialDelay(delay))
+		err := task.Start()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = task.Stop() }()
+
+		clock.Advance(delay)
+		waitForRuns(t, runs, 1)
+
+		advanceWhenWaiting(t, clock, delay)
+		assert.Equal(t, int64(1), runs.Load())
+		clock.Advance(interval - delay)
+		waitForRuns(t, runs, 2)
+	})
+
+	t.Run("TaskWithJitterShouldWaitForUpToTheJitterLonger", func(t *testing.T) {
+		jitter := 10 * time.Second
+		task, _, _ := newCountingTask(WithJitter(jitter))
+
+		for i := 0; i < 100; i++ {
+			delay := task.nextDelay(interval)
+			assert.GreaterOrEqual(t, delay, interval)
+			assert.Less(t, delay, interval+jitter)
+		}
+	})
+
+	t.Run("RunOnceShouldRunTheWorkRightAway", func(t *testing.T) {
+		task, _, runs := newCountingTask()
+
+		err := task.RunOnce()
+		assert.Nil(t, err)
+		assert.Equal(t, int64(1), runs.Load())
+
+		err = task.Start()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = task.Stop() }()
+
+		err = task.RunOnce()
+		assert.Nil(t, err)
+		assert.Equal(t, int64(2), runs.Load())
+	})
+
+	t.Run("ConcurrentStopsShouldAllReturnOnceTheTaskHasStopped", func(t *testing.T) {
+		task, _, _ := newCountingTask()
+		err := task.Start()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var wg sync.WaitGroup
+		errs := make([]error, 10)
+		for i := range errs {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				errs[i] = task.Stop()
+			}(i)
+		}
+		wg.Wait()
+
+		for _, err := range errs {
+			assert.Nil(t, err)
+		}
+		assert.False(t, task.IsRunning())
+	})
+
+	t.Run("StopAfterStopShouldDoNothing", func(t *testing.T) {
+		task, _, _ := newCountingTask()
+		assert.Equal(t, ErrNotRunning, task.Stop())
+
+		err := task.Start()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Nil(t, task.Stop())
+		assert.Nil(t, task.Stop())
+		assert.False(t, task.IsRunning())
+	})
+
+	t.Run("StartAfterStopShouldRunTheTaskAgain", func(t *testing.T) {
+		task, clock, runs := newCountingTask()
+		err := task.Start()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, ErrAlreadyRunning, task.Start())
+		assert.Nil(t, task.Stop())
+
+		err = task.Start()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = task.Stop() }()
+		assert.True(t, task.IsRunning())
+
+		clock.Advance(interval)
+		waitForRuns(t, runs, 1)
+	})
+
+	t.Run("StatusShouldReportTheLastAndNextRuns", func(t *testing.T) {
+		start := time.Unix(1700000000, 0)
+		clock := NewFakeClock(start)
+		workErr := errors.New("failed")
+		task := NewTask("failing", interval, func() error { return workErr }, WithTaskClock(clock))
+
+		assert.Equal(t, TaskStatus{Name: "failing"}, task.Status())
+
+		err := task.Start()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, TaskStatus{Name: "failing", IsRunning: true, NextRun: start.Add(interval)}, task.Status())
+
+		clock.Advance(interval)
+		assert.Eventually(t, func() bool { return task.Status().LastError != nil }, time.Second, time.Millisecond)
+		assert.Eventually(t, func() bool { return task.Status().NextRun.Equal(start.Add(2 * interval)) }, time.Second, time.Millisecond)
+
+		err = task.Stop()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, TaskStatus{Name: "failing", LastRun: start.Add(interval), LastError: workErr, ConsecutiveFailures: 1}, task.Status())
+		assert.Equal(t, workErr, task.RunOnce())
+	})
+
+	t.Run("SetIntervalShouldRescheduleTheNextRun", func(t *testing.T) {
+		task, clock, runs := newCountingTask()
+		start := clock.Now()
+		err := task.Start()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = task.Stop() }()
+
+		advanceWhenWaiting(t, clock, time.Second)
+		err = task.SetInterval(5 * time.Second)
+		assert.Nil(t, err)
+		assert.Eventually(t, func() bool {
+			return task.Status().NextRun.Equal(start.Add(6 * time.Second))
+		}, time.Second, time.Millisecond)
+
+		clock.Advance(5 * time.Second)
+		waitForRuns(t, runs, 1)
+		assert.Equal(t, ErrOutOfBounds, task.SetInterval(0))
+	})
+
+	t.Run("TaskWithRetryBackoffShouldRetryFailedWorkSoonerAndSooner", func(t *testing.T) {
+		start := time.Unix(1700000000, 0)
+		clock := NewFakeClock(start)
+		var shouldFail atomic.Bool
+		shouldFail.Store(true)
+		var failures []int
+		var failuresLock sync.Mutex
+		task := NewTask("flaky", interval, func() error {
+			if shouldFail.Load() {
+				return errors.New("transient")
+			}
+			return nil
+		}, WithTaskClock(clock), WithRetryBackoff(time.Second, 3*time.Second), WithFailureHandler(func(err error, consecutiveFailures int) {
+			failuresLock.Lock()
+			defer failuresLock.Unlock()
+			failures = append(failures, consecutiveFailures)
+		}))
+		err := task.Start()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = task.Stop() }()
+
+		// waitForNextRun waits for the next run to be due at the given time
+		next := start.Add(interval)
+		waitForNextRun := func(t *testing.T, at time.Time) {
+			assert.Eventually(t, func() bool { return task.Status().NextRun.Equal(at) }, time.Second, time.Millisecond)
+		}
+
+		clock.Advance(interval)
+		for _, delay := range []time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 3 * time.Second} {
+			next = next.Add(delay)
+			waitForNextRun(t, next)
+			clock.Advance(delay)
+		}
+		assert.Eventually(t, func() bool { return task.Status().ConsecutiveFailures == 5 }, time.Second, time.Millisecond)
+
+		shouldFail.Store(false)
+		waitForNextRun(t, next.Add(3*time.Second))
+		clock.Advance(3 * time.Second)
+		waitForNextRun(t, next.Add(3*time.Second+interval))
+		assert.Equal(t, 0, task.Status().ConsecutiveFailures)
+
+		failuresLock.Lock()
+		defer failuresLock.Unlock()
+		assert.Equal(t, []int{1, 2, 3, 4, 5}, failures)
+	})
+}
@@ -0,0 +1,129 @@
+package internal
+
+import (
+	"os"
+	"strings"
+)
+
+// ThrottledVacuumWithStats is like VacuumWithStats but it vacuums one file at a time, calling pause with the number
+// of bytes rewritten after each file. As pause may let other operations change the files in the meantime, the next
+// file to vacuum, and the keys marked for deletion in it, are looked up again once it returns
+func (s *Store) ThrottledVacuumWithStats(st *OpStats, pause func(bytesRewritten int64)) error {
+	return s.guardWrite(func() error {
+		err := s.vacuumMetadata(st)
+		if err != nil {
+			return err
+		}
+
+		vacuumed := map[string]bool{}
+		for {
+			path, keysToDelete, err := s.nextFileToVacuum(vacuumed)
+			if err != nil {
+				return err
+			}
+			if path == "" {
+				return s.removeUnreferencedBlobs(st)
+			}
+			vacuumed[path] = true
+
+			bytesRewritten, err := s.vacuumFile(path, keysToDelete, st)
+			if err != nil {
+				return err
+			}
+
+			pause(bytesRewritten)
+		}
+	})
+}
+
+// ThrottledCompactWithStats is like CompactWithStats but it calls pause with the number of bytes rewritten
+// after every merge of two data files
+func (s *Store) ThrottledCompactWithStats(st *OpStats, pause func(bytesRewritten int64)) error {
+	return s.guardWrite(func() error { return s.compact(st, pause) })
+}
+
+// nextFileToVacuum returns the path of the first log or data file, other than those already vacuumed,
+// holding any of the key-values marked for deletion, together with the timestamped keys of those key-values.
+// It returns an empty path if there is none
+func (s *Store) nextFileToVacuum(vacuumed map[string]bool) (string, []string, error) {
+	s.delFileLock.Lock()
+	defer s.delFileLock.Unlock()
+
+	keysToDelete, err := s.getKeysToDelete()
+	if err != nil {
+		return "", nil, err
+	}
+
+	var path string
+	var keysInFile []string
+	for _, timestampedKey := range keysToDelete {
+		filePath := s.getFilePathForKey(timestampedKey)
+		if vacuumed[filePath] || (path != "" && filePath != path) {
+			continue
+		}
+
+		path = filePath
+		keysInFile = append(keysInFile, timestampedKey)
+	}
+
+	return path, keysInFile, nil
+}
+
+// vacuumFile deletes the key-values of the given timestamped keys from the file at path, then unmarks them for
+// deletion, returning the size of the rewritten file
+func (s *Store) vacuumFile(path string, keysToDelete []string, st *OpStats) (int64, error) {
+	s.delFileLock.Lock()
+	defer s.delFileLock.Unlock()
+
+	defer func() {
+		s.cacheLock.Lock()
+		s.discardPrefetchedCache()
+		s.cacheLock.Unlock()
+	}()
+
+	err := s.deleteKeyValuesFromFile(path, keysToDelete)
+	if err != nil && !os.IsNotExist(err) {
+		return 0, err
+	}
+	st.recordFileRewrite(path)
+
+	var size int64
+	if info, err := os.Stat(path); err == nil {
+		size = info.Size()
+	}
+
+	marked, err := s.getKeysToDelete()
+	if err != nil {
+		return 0, err
+	}
+
+	isVacuumed := make(map[string]bool, len(keysToDelete))
+	for _, timestampedKey := range keysToDelete {
+		isVacuumed[timestampedKey] = true
+	}
+
+	var content strings.Builder
+	for _, timestampedKey := range marked {
+		if !isVacuumed[timestampedKey] {
+			content.WriteString(s.separators.encodeRecord(timestampedKey))
+		}
+	}
+
+	err = s.writeFile(s.delFilePath, []byte(content.String()))
+	if err != nil {
+		return 0, err
+	}
+	st.recordFileRewrite(s.delFilePath)
+
+	return size, nil
+}
+
+// getFilePathForKey returns the path of the log or data file that the timestamped key is routed to
+func (s *Store) getFilePathForKey(timestampedKey string) string {
+	timestampRange := s.getTimestampRangeForKey(timestampedKey)
+	if timestampRange == nil {
+		return s.currentLogFilePath
+	}
+
+	return s.getDataFilePath(timestampRange.Start)
+}
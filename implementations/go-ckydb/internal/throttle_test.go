@@ -0,0 +1,136 @@
+package internal
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThrottle(t *testing.T) {
+	dbPath, err := filepath.Abs("testThrottleDb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = ClearDummyFileDataInDb(dbPath) }()
+
+	// newStore returns a loaded store on an empty database folder with the given keys spread across many data files
+	newStore := func(t *testing.T, keys int) *Store {
+		err := ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		store := NewStore(dbPath, 0.1)
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for i := 0; i < keys; i++ {
+			err = store.Set(fmt.Sprintf("key%d", i), fmt.Sprintf("value of key %d", i))
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		return store
+	}
+
+	t.Run("ThrottledVacuumShouldPauseAfterEveryVacuumedFile", func(t *testing.T) {
+		store := newStore(t, 40)
+		for _, key := range []string{"key0", "key1", "key20", "key39"} {
+			err := store.Delete(key)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		timestampedKeys, err := store.getKeysToDelete()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files := map[string]bool{}
+		for _, timestampedKey := range timestampedKeys {
+			files[store.getFilePathForKey(timestampedKey)] = true
+		}
+
+		var pauses []int64
+		err = store.ThrottledVacuumWithStats(nil, func(bytesRewritten int64) {
+			pauses = append(pauses, bytesRewritten)
+		})
+		assert.Nil(t, err)
+		assert.Len(t, pauses, len(files))
+
+		marked, err := store.getKeysToDelete()
+		assert.Nil(t, err)
+		assert.Empty(t, marked)
+		contents, err := ReadFilesWithExtension(dbPath, DataFileExt)
+		assert.Nil(t, err)
+		logContents, err := ReadFilesWithExtension(dbPath, LogFileExt)
+		assert.Nil(t, err)
+		for _, content := range append(contents, logContents...) {
+			for _, timestampedKey := range timestampedKeys {
+				assert.NotContains(t, content, timestampedKey)
+			}
+		}
+	})
+
+	t.Run("ThrottledVacuumShouldKeepTheKeysMarkedDuringPauses", func(t *testing.T) {
+		store := newStore(t, 40)
+		err := store.Delete("key0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		timestampedKey := store.index["key1"]
+
+		err = store.ThrottledVacuumWithStats(nil, func(bytesRewritten int64) {
+			// key1 is in the same data file as key0, which has just been vacuumed
+			_ = store.Delete("key1")
+		})
+		assert.Nil(t, err)
+
+		marked, err := store.getKeysToDelete()
+		assert.Nil(t, err)
+		assert.Equal(t, []string{timestampedKey}, marked)
+
+		err = store.Vacuum()
+		assert.Nil(t, err)
+		marked, err = store.getKeysToDelete()
+		assert.Nil(t, err)
+		assert.Empty(t, marked)
+	})
+
+	t.Run("ThrottledCompactShouldPauseAfterEveryMerge", func(t *testing.T) {
+		store := newStore(t, 40)
+		for i := 0; i < 40; i += 2 {
+			err := store.Delete(fmt.Sprintf("key%d", i))
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		err := store.Vacuum()
+		if err != nil {
+			t.Fatal(err)
+		}
+		dataFiles := len(store.dataFiles)
+		err = store.SetMaxFileSize(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var pauses int
+		err = store.ThrottledCompactWithStats(nil, func(bytesRewritten int64) {
+			assert.Greater(t, bytesRewritten, int64(0))
+			pauses++
+		})
+		assert.Nil(t, err)
+		assert.Greater(t, pauses, 0)
+		assert.Equal(t, dataFiles-pauses, len(store.dataFiles))
+		for i := 1; i < 40; i += 2 {
+			value, err := store.Get(fmt.Sprintf("key%d", i))
+			assert.Nil(t, err)
+			assert.Equal(t, fmt.Sprintf("value of key %d", i), value)
+		}
+	})
+}
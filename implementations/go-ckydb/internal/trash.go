@@ -0,0 +1,172 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const TrashFilename = "trash.trs"
+
+// trashedValue is the value of a deleted key, kept in the trash for it to be undeleted
+type trashedValue struct {
+	value     string
+	deletedAt int64
+}
+
+// WithTrash enables trash mode, in which deleted keys are moved to the trash file, from where they can be
+// undeleted until the retention has passed, after which they are purged on the next Vacuum. Only keys
+// deleted with Delete are moved to the trash, not those that expire or are evicted
+func WithTrash(retention time.Duration) StoreOption {
+	return func(s *Store) {
+		s.trashRetention = retention
+	}
+}
+
+// Undelete restores the value of the given key from the trash, returning the value.
+// It returns an ErrNotFound error if the key is not in the trash or has been there longer than the retention,
+// an ErrConflict error if the key has been set again since it was deleted and an ErrTrashDisabled error
+// if trash mode is not enabled
+func (s *Store) Undelete(key string) (string, error) {
+	return s.UndeleteWithStats(key, nil)
+}
+
+// UndeleteWithStats is like Undelete but it also records what it did in st
+func (s *Store) UndeleteWithStats(key string, st *OpStats) (string, error) {
+	key = s.foldKey(key)
+	var value string
+	err := s.guardWrite(func() error {
+		if s.trashRetention <= 0 {
+			return ErrTrashDisabled
+		}
+
+		trashed, ok := s.trash[key]
+		if !ok || s.isPurgeable(trashed) {
+			return ErrNotFound
+		}
+
+		if _, ok := s.lookup(key); ok {
+			return ErrConflict
+		}
+
+		err := s.setWithStats(key, trashed.value, st)
+		if err != nil {
+			return err
+		}
+
+		err = s.deleteKeyValuesFromFile(s.trashFilePath, []string{key})
+		if err != nil {
+			return err
+		}
+		st.recordFileRewrite(s.trashFilePath)
+
+		delete(s.trash, key)
+		value = trashed.value
+		return nil
+	})
+
+	return value, err
+}
+
+// moveToTrash appends the current value of the key to the trash file, if trash mode is enabled.
+// It is called before the key is deleted so that a failure leaves the key in place
+func (s *Store) moveToTrash(key string, st *OpStats) error {
+	if s.trashRetention <= 0 || s.trash == nil {
+		return nil
+	}
+
+	timestampedKey, ok := s.lookup(key)
+	if !ok {
+		return nil
+	}
+
+	value, err := s.getValueForKey(timestampedKey, st)
+	if err != nil {
+		return err
+	}
+
+	trashed := trashedValue{value: value, deletedAt: s.clock.Now().UnixNano()}
+	data := s.separators.encodeRecord(key, fmt.Sprintf("%d-%s", trashed.deletedAt, value))
+	n, err := s.appendFile(s.trashFilePath, []byte(data))
+	if err != nil {
+		return err
+	}
+	st.recordWrite(s.trashFilePath, n)
+
+	s.trash[key] = trashed
+	return nil
+}
+
+// purgeTrash drops the keys that have been in the trash for longer than the retention
+func (s *Store) purgeTrash(st *OpStats) error {
+	var keysToPurge []string
+	for key, trashed := range s.trash {
+		if s.isPurgeable(trashed) {
+			keysToPurge = append(keysToPurge, key)
+		}
+	}
+
+	if len(keysToPurge) == 0 {
+		return nil
+	}
+
+	err := s.deleteKeyValuesFromFile(s.trashFilePath, keysToPurge)
+	if err != nil {
+		return err
+	}
+	st.recordFileRewrite(s.trashFilePath)
+
+	for _, key := range keysToPurge {
+		delete(s.trash, key)
+	}
+	return nil
+}
+
+// isPurgeable checks whether the trashed value has been in the trash for longer than the retention
+func (s *Store) isPurgeable(trashed trashedValue) bool {
+	return s.clock.Now().UnixNano()-trashed.deletedAt >= int64(s.trashRetention)
+}
+
+// loadTrashFromDisk loads the trashed values from the trash file, if trash mode is enabled.
+// The file is only created once a key is deleted
+func (s *Store) loadTrashFromDisk() error {
+	if s.trashRetention <= 0 {
+		return nil
+	}
+
+	s.trash = map[string]trashedValue{}
+	err := s.repairTornRecord(s.trashFilePath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	data, err := s.readLoadedKeyValues(s.trashFilePath, func(_ string, record string) bool {
+		deletedAt, _, ok := strings.Cut(record, "-")
+		_, err := strconv.ParseInt(deletedAt, 10, 64)
+		return ok && err == nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for key, record := range data {
+		deletedAt, value, ok := strings.Cut(record, "-")
+		if !ok {
+			return ErrCorruptedData
+		}
+
+		nanos, err := strconv.ParseInt(deletedAt, 10, 64)
+		if err != nil {
+			return ErrCorruptedData
+		}
+
+		s.trash[key] = trashedValue{value: value, deletedAt: nanos}
+	}
+
+	return nil
+}
@@ -0,0 +1,132 @@
+package internal
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrash(t *testing.T) {
+	dbPath, err := filepath.Abs("testTrashDb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2022, 6, 16, 10, 0, 0, 0, time.UTC)
+	retention := 24 * time.Hour
+	defer func() { _ = ClearDummyFileDataInDb(dbPath) }()
+
+	// newStore returns a loaded store in trash mode on an empty database folder whose time is controlled by the clock
+	newStore := func(t *testing.T) (*Store, *FakeClock) {
+		err := ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		clock := NewFakeClock(start)
+		store := NewStore(dbPath, 320.0/1024, WithClock(clock), WithTrash(retention))
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = store.Set("cow", "500 months")
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = store.Delete("cow")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return store, clock
+	}
+
+	t.Run("UndeleteShouldRestoreDeletedKeysEvenAfterVacuum", func(t *testing.T) {
+		store, _ := newStore(t)
+		err := store.Vacuum()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		value, err := store.Undelete("cow")
+		assert.Nil(t, err)
+		assert.Equal(t, "500 months", value)
+		value, err = store.Get("cow")
+		assert.Nil(t, err)
+		assert.Equal(t, "500 months", value)
+
+		_, err = store.Undelete("cow")
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("UndeleteShouldFailForKeysSetAgainSinceTheirDelete", func(t *testing.T) {
+		store, _ := newStore(t)
+		err := store.Set("cow", "501 months")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = store.Undelete("cow")
+		assert.ErrorIs(t, err, ErrConflict)
+		value, err := store.Get("cow")
+		assert.Nil(t, err)
+		assert.Equal(t, "501 months", value)
+	})
+
+	t.Run("TrashShouldSurviveReloads", func(t *testing.T) {
+		_, clock := newStore(t)
+
+		reloaded := NewStore(dbPath, 320.0/1024, WithClock(clock), WithTrash(retention))
+		err := reloaded.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		value, err := reloaded.Undelete("cow")
+		assert.Nil(t, err)
+		assert.Equal(t, "500 months", value)
+	})
+
+	t.Run("VacuumShouldPurgeKeysPastTheRetention", func(t *testing.T) {
+		store, clock := newStore(t)
+		clock.Advance(retention)
+
+		_, err := store.Undelete("cow")
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		err = store.Vacuum()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := ReadFileToString(filepath.Join(dbPath, TrashFilename))
+		assert.Nil(t, err)
+		assert.Equal(t, "", content)
+	})
+
+	t.Run("UndeleteShouldFailForKeysNotInTheTrash", func(t *testing.T) {
+		store, _ := newStore(t)
+
+		_, err := store.Undelete("goat")
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		err = store.Clear()
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = store.Undelete("cow")
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("UndeleteShouldFailWithoutTrashMode", func(t *testing.T) {
+		store := NewStore(dbPath, 320.0/1024)
+		err := store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = store.Undelete("cow")
+		assert.ErrorIs(t, err, ErrTrashDisabled)
+	})
+}
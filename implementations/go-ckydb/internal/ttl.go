@@ -0,0 +1,211 @@
+package internal
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+const ExpiryFilename = "expiry.ttl"
+
+// NoExpiry is the TTL of keys that never expire
+const NoExpiry time.Duration = -1
+
+// SetWithTTL is like Set but the key expires after the given ttl, after which it is not found
+// and is deleted on the next Vacuum. A ttl of zero or less deletes the key
+func (s *Store) SetWithTTL(key string, value string, ttl time.Duration) error {
+	return s.SetWithTTLAndStats(key, value, ttl, nil)
+}
+
+// SetWithTTLAndStats is like SetWithTTL but it also records what it did in st
+func (s *Store) SetWithTTLAndStats(key string, value string, ttl time.Duration, st *OpStats) error {
+	original, key := key, s.foldKey(key)
+	return s.guardWrite(func() error {
+		err := s.setWithStats(key, value, st)
+		if err != nil {
+			return err
+		}
+
+		err = s.setCasing(key, original, st)
+		if err != nil {
+			return err
+		}
+
+		return s.expire(key, ttl, st)
+	})
+}
+
+// TTL returns how long the given key has left before it expires, or NoExpiry if it never expires.
+// It returns an ErrNotFound error if the key is nonexistent
+func (s *Store) TTL(key string) (time.Duration, error) {
+	key = s.foldKey(key)
+	_, ok := s.lookup(key)
+	if !ok {
+		return 0, ErrNotFound
+	}
+
+	expiresAt, ok := s.expiries[key]
+	if !ok {
+		return NoExpiry, nil
+	}
+
+	return time.Unix(0, expiresAt).Sub(s.clock.Now()), nil
+}
+
+// Expire makes the given key expire after the given ttl, replacing any earlier expiry.
+// A ttl of zero or less deletes the key. It returns an ErrNotFound error if the key is nonexistent
+func (s *Store) Expire(key string, ttl time.Duration) error {
+	return s.ExpireWithStats(key, ttl, nil)
+}
+
+// ExpireWithStats is like Expire but it also records what it did in st
+func (s *Store) ExpireWithStats(key string, ttl time.Duration, st *OpStats) error {
+	key = s.foldKey(key)
+	return s.guardWrite(func() error {
+		err := checkUserKey(key)
+		if err != nil {
+			return err
+		}
+
+		_, ok := s.lookup(key)
+		if !ok {
+			return ErrNotFound
+		}
+
+		return s.expire(key, ttl, st)
+	})
+}
+
+// Persist removes the expiry of the given key so that it never expires.
+// It returns an ErrNotFound error if the key is nonexistent
+func (s *Store) Persist(key string) error {
+	return s.PersistWithStats(key, nil)
+}
+
+// PersistWithStats is like Persist but it also records what it did in st
+func (s *Store) PersistWithStats(key string, st *OpStats) error {
+	key = s.foldKey(key)
+	return s.guardWrite(func() error {
+		err := checkUserKey(key)
+		if err != nil {
+			return err
+		}
+
+		_, ok := s.lookup(key)
+		if !ok {
+			return ErrNotFound
+		}
+
+		return s.removeExpiry(key, st)
+	})
+}
+
+// lookup returns the timestamped key of the given key from the index, unless the key has expired
+func (s *Store) lookup(key string) (string, bool) {
+	timestampedKey, ok := s.index[key]
+	if !ok || s.isExpired(key) {
+		return "", false
+	}
+
+	return timestampedKey, true
+}
+
+// isExpired checks whether the given key has an expiry that has passed
+func (s *Store) isExpired(key string) bool {
+	expiresAt, ok := s.expiries[key]
+	return ok && s.clock.Now().UnixNano() >= expiresAt
+}
+
+// expire appends the expiry of the key, ttl from now, to the expiry file, whose last record
+// of a key wins. A ttl of zero or less deletes the key right away
+func (s *Store) expire(key string, ttl time.Duration, st *OpStats) error {
+	if ttl <= 0 {
+		return s.deleteWithStats(key, st)
+	}
+
+	expiresAt := s.clock.Now().Add(ttl).UnixNano()
+	data := s.separators.encodeRecord(key, strconv.FormatInt(expiresAt, 10))
+	n, err := s.appendFile(s.expiryFilePath, []byte(data))
+	if err != nil {
+		return err
+	}
+	st.recordWrite(s.expiryFilePath, n)
+
+	if s.expiries == nil {
+		s.expiries = map[string]int64{}
+	}
+	s.expiries[key] = expiresAt
+	return nil
+}
+
+// removeExpiry removes the expiry of the given key, if any, from the expiry file
+func (s *Store) removeExpiry(key string, st *OpStats) error {
+	if _, ok := s.expiries[key]; !ok {
+		return nil
+	}
+
+	err := s.deleteKeyValuesFromFile(s.expiryFilePath, []string{key})
+	if err != nil {
+		return err
+	}
+	st.recordFileRewrite(s.expiryFilePath)
+
+	delete(s.expiries, key)
+	return nil
+}
+
+// deleteExpiredKeys deletes the keys whose expiry has passed, and drops the expiries
+// of keys that no longer exist e.g. because retention dropped them
+func (s *Store) deleteExpiredKeys(st *OpStats) error {
+	for key := range s.expiries {
+		if _, ok := s.index[key]; !ok {
+			err := s.removeExpiry(key, st)
+			if err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if s.isExpired(key) {
+			err := s.deleteWithStats(key, st)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// loadExpiriesFromDisk loads the expiries of keys from the expiry file, if there is one.
+// The file is only created once a key is given an expiry
+func (s *Store) loadExpiriesFromDisk() error {
+	s.expiries = map[string]int64{}
+	err := s.repairTornRecord(s.expiryFilePath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	data, err := s.readLoadedKeyValues(s.expiryFilePath, func(_ string, value string) bool {
+		_, err := strconv.ParseInt(value, 10, 64)
+		return err == nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for key, value := range data {
+		expiresAt, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return ErrCorruptedData
+		}
+
+		s.expiries[key] = expiresAt
+	}
+
+	return nil
+}
@@ -0,0 +1,148 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTTL(t *testing.T) {
+	dbPath, err := filepath.Abs("testTTLDb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2022, 6, 16, 10, 0, 0, 0, time.UTC)
+	defer func() { _ = ClearDummyFileDataInDb(dbPath) }()
+
+	// newStore returns a loaded store on an empty database folder whose time is controlled by the clock
+	newStore := func(t *testing.T) (*Store, *FakeClock) {
+		err := ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		clock := NewFakeClock(start)
+		store := NewStore(dbPath, 320.0/1024, WithClock(clock))
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return store, clock
+	}
+
+	t.Run("KeysShouldNotBeFoundOnceTheyExpire", func(t *testing.T) {
+		store, clock := newStore(t)
+		err := store.SetWithTTL("cow", "500 months", time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = store.Set("goat", "678 months")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ttl, err := store.TTL("cow")
+		assert.Nil(t, err)
+		assert.Equal(t, time.Minute, ttl)
+		value, err := store.Get("cow")
+		assert.Nil(t, err)
+		assert.Equal(t, "500 months", value)
+
+		clock.Advance(time.Minute)
+		_, err = store.Get("cow")
+		assert.ErrorIs(t, err, ErrNotFound)
+		_, err = store.TTL("cow")
+		assert.ErrorIs(t, err, ErrNotFound)
+		assert.False(t, store.Has("cow"))
+		assert.Equal(t, []string{"goat"}, store.Keys())
+		assert.Equal(t, []GetResult{{}, {Value: "678 months", Found: true}}, store.GetManyWithStats([]string{"cow", "goat"}, nil))
+
+		// the expired key is deleted from disk by the vacuum
+		err = store.Vacuum()
+		assert.Nil(t, err)
+		assert.Equal(t, 1, store.Stats().Keys)
+		index, err := DefaultSeparators.readKeyValuesFromFile(filepath.Join(dbPath, IndexFilename))
+		assert.Nil(t, err)
+		assert.NotContains(t, index, "cow")
+		expiries, err := DefaultSeparators.readKeyValuesFromFile(filepath.Join(dbPath, ExpiryFilename))
+		assert.Nil(t, err)
+		assert.Empty(t, expiries)
+	})
+
+	t.Run("ExpireAndPersistShouldUpdateTheExpiryOfExistingKeys", func(t *testing.T) {
+		store, clock := newStore(t)
+		err := store.Set("cow", "500 months")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ttl, err := store.TTL("cow")
+		assert.Nil(t, err)
+		assert.Equal(t, NoExpiry, ttl)
+
+		assert.Nil(t, store.Expire("cow", time.Hour))
+		clock.Advance(time.Minute)
+		ttl, err = store.TTL("cow")
+		assert.Nil(t, err)
+		assert.Equal(t, 59*time.Minute, ttl)
+
+		assert.Nil(t, store.Persist("cow"))
+		ttl, err = store.TTL("cow")
+		assert.Nil(t, err)
+		assert.Equal(t, NoExpiry, ttl)
+
+		// setting a key removes its expiry
+		assert.Nil(t, store.Expire("cow", time.Hour))
+		assert.Nil(t, store.Set("cow", "501 months"))
+		ttl, err = store.TTL("cow")
+		assert.Nil(t, err)
+		assert.Equal(t, NoExpiry, ttl)
+
+		assert.ErrorIs(t, store.Expire("goat", time.Hour), ErrNotFound)
+		assert.ErrorIs(t, store.Persist("goat"), ErrNotFound)
+
+		assert.Nil(t, store.Expire("cow", 0))
+		assert.False(t, store.Has("cow"))
+	})
+
+	t.Run("ExpiriesShouldSurviveReloads", func(t *testing.T) {
+		store, clock := newStore(t)
+		for k, ttl := range map[string]time.Duration{"cow": time.Minute, "goat": time.Hour} {
+			err := store.SetWithTTL(k, "value", ttl)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		assert.Nil(t, store.Expire("goat", 2*time.Hour))
+
+		// a torn record at the end of the expiry file is dropped
+		err := os.WriteFile(filepath.Join(dbPath, ExpiryFilename), append(mustReadFile(t, filepath.Join(dbPath, ExpiryFilename)), "hen"...), 0666)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		clock.Advance(time.Minute)
+		reloaded := NewStore(dbPath, 320.0/1024, WithClock(clock))
+		err = reloaded.Load()
+		assert.Nil(t, err)
+
+		assert.False(t, reloaded.Has("cow"))
+		ttl, err := reloaded.TTL("goat")
+		assert.Nil(t, err)
+		assert.Equal(t, 119*time.Minute, ttl)
+	})
+}
+
+// mustReadFile returns the contents of the file at path, failing the test if it cannot be read
+func mustReadFile(t *testing.T, path string) []byte {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
@@ -20,9 +20,19 @@ type Range struct {
 	End   string
 }
 
-// ClearDummyFileDataInDb clears the files in the given database folder
+// ClearDummyFileDataInDb clears the files in the given database folder, and those that Clear moved aside
 func ClearDummyFileDataInDb(dbPath string) error {
-	return os.RemoveAll(dbPath)
+	err := os.RemoveAll(dbPath)
+	if err != nil {
+		return err
+	}
+
+	clearedFolders, err := (&Store{dbPath: dbPath}).ClearedFolders()
+	if err != nil {
+		return err
+	}
+
+	return RemoveClearedFolders(clearedFolders)
 }
 
 // AddDummyFileDataInDb adds dummy file data in the given database folder
@@ -96,33 +106,43 @@ func CreateFileIfNotExist(filePath string) error {
 }
 
 // ExtractKeyValuesFromByteArray extracts a map of keys and values from a byte array
+// written with the DefaultSeparators
 func ExtractKeyValuesFromByteArray(data []byte) (map[string]string, error) {
-	kvPairStrings, err := ExtractTokensFromByteArray(data)
+	return DefaultSeparators.extractKeyValues(data)
+}
+
+// extractKeyValues extracts a map of keys and values from a byte array
+func (sep Separators) extractKeyValues(data []byte) (map[string]string, error) {
+	records, err := sep.extractRecords(data, 2)
 	if err != nil {
 		return nil, err
 	}
-	result := make(map[string]string, len(kvPairStrings))
+	result := make(map[string]string, len(records))
 
-	for _, kv := range kvPairStrings {
-		kvParts := strings.Split(kv, KeyValueSeparator)
-		if len(kvParts) != 2 {
-			return nil, ErrCorruptedData
-		}
-
-		result[kvParts[0]] = kvParts[1]
+	for _, kv := range records {
+		result[kv[0]] = kv[1]
 	}
 
 	return result, nil
 }
 
-// ExtractTokensFromByteArray extracts tokens from a byte array
+// ExtractTokensFromByteArray extracts tokens from a byte array written with the DefaultSeparators
 func ExtractTokensFromByteArray(data []byte) ([]string, error) {
-	dataAsStr := strings.TrimRight(string(data), TokenSeparator)
-	if dataAsStr == "" {
-		return []string{}, nil
+	return DefaultSeparators.extractTokens(data)
+}
+
+// extractTokens extracts tokens from a byte array
+func (sep Separators) extractTokens(data []byte) ([]string, error) {
+	records, err := sep.extractRecords(data, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens := make([]string, len(records))
+	for i, record := range records {
+		tokens[i] = record[0]
 	}
 
-	tokens := strings.Split(dataAsStr, TokenSeparator)
 	return tokens, nil
 }
 
@@ -134,31 +154,43 @@ func DeleteKeyValuesFromFile(path string, keysToDelete []string) error {
 		return err
 	}
 
-	kvPairStrings, err := ExtractTokensFromByteArray(data)
+	content, err := DefaultSeparators.removeKeyValues(data, keysToDelete)
 	if err != nil {
 		return err
 	}
 
-	prefixesToDelete := make([]string, len(keysToDelete))
-	for i, key := range keysToDelete {
-		prefixesToDelete[i] = fmt.Sprintf("%s%s", key, KeyValueSeparator)
+	return os.WriteFile(path, []byte(content), 0666)
+}
+
+// removeKeyValues returns the content of data without the key values
+// corresponding to the keysToDelete
+func (sep Separators) removeKeyValues(data []byte, keysToDelete []string) (string, error) {
+	records, err := sep.extractRecords(data, 2)
+	if err != nil {
+		return "", err
 	}
 
-	content := ""
-	for _, pairString := range kvPairStrings {
-		if hasAnyOfPrefixes(pairString, prefixesToDelete) {
-			continue
-		}
+	return sep.encodeRecordsExcept(records, keysToDelete), nil
+}
 
-		content = fmt.Sprintf("%s%s%s", content, pairString, TokenSeparator)
+// encodeRecordsExcept encodes the key-value records into the content of a file, without those
+// corresponding to the keysToDelete
+func (sep Separators) encodeRecordsExcept(records [][]string, keysToDelete []string) string {
+	isDeleted := make(map[string]bool, len(keysToDelete))
+	for _, key := range keysToDelete {
+		isDeleted[key] = true
 	}
 
-	err = os.WriteFile(path, []byte(content), 0666)
-	if err != nil {
-		return err
+	var content strings.Builder
+	for _, kv := range records {
+		if isDeleted[kv[0]] {
+			continue
+		}
+
+		content.WriteString(sep.encodeRecord(kv...))
 	}
 
-	return nil
+	return content.String()
 }
 
 // ReadFileToString reads the contents at the given path into a string
@@ -172,15 +204,27 @@ func ReadFileToString(path string) (string, error) {
 }
 
 // PersistMapDataToFile overwrites the data in the file at pathToFile with the
-// equivalent of the map data passed
+// equivalent of the map data passed, written with the DefaultSeparators
 func PersistMapDataToFile(data map[string]string, pathToFile string) error {
-	content := ""
+	return os.WriteFile(pathToFile, []byte(DefaultSeparators.encodeMapData(data)), 0777)
+}
 
+// encodeMapData converts the map data passed into the content of a file
+func (sep Separators) encodeMapData(data map[string]string) string {
+	// the content is sized up front, roughly in the length-prefixed format, as the log file holds every key of
+	// the memtable and is rewritten on each Set
+	size := 0
 	for k, v := range data {
-		content = fmt.Sprintf("%s%s%s%s%s", content, k, KeyValueSeparator, v, TokenSeparator)
+		size += len(k) + len(v) + len(sep.KeyValue) + len(sep.Token)
 	}
 
-	return os.WriteFile(pathToFile, []byte(content), 0777)
+	var content strings.Builder
+	content.Grow(size)
+	for k, v := range data {
+		sep.writeRecord(&content, k, v)
+	}
+
+	return content.String()
 }
 
 // GetFileSize returns the size of the file in kilobytes
@@ -193,12 +237,44 @@ func GetFileSize(pathToFile string) (float64, error) {
 	return float64(info.Size()) / 1024, nil
 }
 
-// hasAnyOfPrefixes checks if the string str has any of the prefixes
-func hasAnyOfPrefixes(str string, prefixes []string) bool {
-	for _, prefix := range prefixes {
-		if strings.HasPrefix(str, prefix) {
-			return true
+// readKeyValuesFromFile reads the key value pairs in the file at the given path
+func (sep Separators) readKeyValuesFromFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return sep.extractKeyValues(data)
+}
+
+// extractKeyFromTimestampedKey returns the user-defined key from the given timestamped key
+func extractKeyFromTimestampedKey(timestampedKey string) string {
+	parts := strings.SplitN(timestampedKey, "-", 2)
+	if len(parts) != 2 {
+		return timestampedKey
+	}
+
+	return parts[1]
+}
+
+// isLogOrDataFile checks if the given filename is that of a log file or a data file
+func isLogOrDataFile(filename string) bool {
+	return strings.HasSuffix(filename, fmt.Sprintf(".%s", LogFileExt)) ||
+		strings.HasSuffix(filename, fmt.Sprintf(".%s", DataFileExt))
+}
+
+// validateKeyValue checks that the key and value can be persisted without corrupting the files
+// i.e. that they contain none of the separators. Any key and value can be in the length-prefixed format
+func (sep Separators) validateKeyValue(key string, value string) error {
+	if sep.lengthPrefixed {
+		return nil
+	}
+
+	for _, str := range []string{key, value} {
+		if strings.Contains(str, sep.Token) || strings.Contains(str, sep.KeyValue) {
+			return ErrInvalidKeyValue
 		}
 	}
-	return false
+
+	return nil
 }
@@ -0,0 +1,136 @@
+package internal
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// randomStringParts are the pieces random keys and values are made of.
+// They include substrings of the separators, unicode and the empty string
+var randomStringParts = []string{
+	"", "a", "key", "value", "-", " ", "\n", "ü", "日本語", "🐄",
+	"$", "%", "#", "@", "*", "&", "^", ">", "<", "?", "(",
+	"$%#", "*&^", "&^&", "><?", "&(^#", "$%#@*&^", "><?&(^",
+	TokenSeparator, KeyValueSeparator,
+}
+
+func TestEncoding(t *testing.T) {
+	t.Run("EncodedKeyValuesShouldRoundTrip", func(t *testing.T) {
+		r := rand.New(rand.NewSource(1))
+
+		for i := 0; i < 10000; i++ {
+			data := map[string]string{}
+			for j := r.Intn(4); j >= 0; j-- {
+				data[randomString(r)] = randomString(r)
+			}
+
+			isValid := true
+			for k, v := range data {
+				if DefaultSeparators.validateKeyValue(k, v) != nil {
+					isValid = false
+				}
+			}
+
+			if !isValid {
+				continue
+			}
+
+			got, err := ExtractKeyValuesFromByteArray([]byte(DefaultSeparators.encodeMapData(data)))
+			assert.Nil(t, err)
+			assert.Equal(t, data, got)
+		}
+	})
+
+	t.Run("EncodedTokensShouldRoundTrip", func(t *testing.T) {
+		r := rand.New(rand.NewSource(2))
+
+		for i := 0; i < 10000; i++ {
+			var tokens []string
+			content := ""
+			for j := r.Intn(4); j >= 0; j-- {
+				token := randomString(r)
+				// tokens are never empty as they are either timestamped keys or key-value pairs
+				if token == "" || strings.Contains(token, TokenSeparator) {
+					continue
+				}
+
+				tokens = append(tokens, token)
+				content += token + TokenSeparator
+			}
+
+			got, err := ExtractTokensFromByteArray([]byte(content))
+			assert.Nil(t, err)
+			if len(tokens) == 0 {
+				assert.Empty(t, got)
+			} else {
+				assert.Equal(t, tokens, got)
+			}
+		}
+	})
+
+	t.Run("ValidateKeyValueShouldRejectSeparators", func(t *testing.T) {
+		assert.Nil(t, DefaultSeparators.validateKeyValue("", ""))
+		assert.Nil(t, DefaultSeparators.validateKeyValue("$%#@*&^", "><?&(^"))
+		assert.Equal(t, ErrInvalidKeyValue, DefaultSeparators.validateKeyValue("a"+TokenSeparator, "b"))
+		assert.Equal(t, ErrInvalidKeyValue, DefaultSeparators.validateKeyValue("a", KeyValueSeparator+"b"))
+	})
+
+	t.Run("ExtractKeyFromTimestampedKeyShouldReturnKeyWithHyphens", func(t *testing.T) {
+		assert.Equal(t, "foo-bar", extractKeyFromTimestampedKey("1655375120328185000-foo-bar"))
+		assert.Equal(t, "", extractKeyFromTimestampedKey("1655375120328185000-"))
+	})
+}
+
+func FuzzExtractKeyValuesFromByteArray(f *testing.F) {
+	for _, content := range dummyDataFileMap {
+		f.Add([]byte(content))
+	}
+	f.Add([]byte(""))
+	f.Add([]byte(KeyValueSeparator + TokenSeparator))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		got, err := ExtractKeyValuesFromByteArray(data)
+		if err != nil {
+			return
+		}
+
+		// whatever is extracted should be extracted as is after being persisted again
+		again, err := ExtractKeyValuesFromByteArray([]byte(DefaultSeparators.encodeMapData(got)))
+		if err != nil {
+			t.Fatalf("re-extracting %q failed: %s", DefaultSeparators.encodeMapData(got), err)
+		}
+		assert.Equal(t, got, again)
+	})
+}
+
+func FuzzEncodeMapData(f *testing.F) {
+	f.Add("cow", "500 months")
+	f.Add("", "")
+	f.Add("日本語", "🐄#")
+	f.Add("$%#@*&^", "><?&(^")
+
+	f.Fuzz(func(t *testing.T, key string, value string) {
+		if DefaultSeparators.validateKeyValue(key, value) != nil {
+			return
+		}
+
+		data := map[string]string{key: value}
+		got, err := ExtractKeyValuesFromByteArray([]byte(DefaultSeparators.encodeMapData(data)))
+		if err != nil {
+			t.Fatalf("extracting %q failed: %s", DefaultSeparators.encodeMapData(data), err)
+		}
+		assert.Equal(t, data, got)
+	})
+}
+
+// randomString creates a random string out of the randomStringParts
+func randomString(r *rand.Rand) string {
+	var builder strings.Builder
+	for i := r.Intn(4); i >= 0; i-- {
+		builder.WriteString(randomStringParts[r.Intn(len(randomStringParts))])
+	}
+	return builder.String()
+}
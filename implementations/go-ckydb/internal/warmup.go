@@ -0,0 +1,75 @@
+package internal
+
+// WithWarmupKeys makes Load read the data files holding the given keys once the store is loaded, so that their
+// first Gets do not wait for the disk. The data file holding the most of them is kept in the cache, while the
+// others are only read into the page cache of the operating system. Keys that are nonexistent or in the log
+// file are skipped
+func WithWarmupKeys(keys []string) StoreOption {
+	return func(s *Store) {
+		s.warmupKeys = keys
+	}
+}
+
+// WithWarmupLastSegments makes Load read the given number of newest data files once the store is loaded, like
+// WithWarmupKeys, keeping the newest of them in the cache unless the keys given to WithWarmupKeys are elsewhere
+func WithWarmupLastSegments(n int) StoreOption {
+	return func(s *Store) {
+		s.warmupSegments = n
+	}
+}
+
+// warmUp reads the data files to warm up as set by WithWarmupKeys and WithWarmupLastSegments, oldest first,
+// keeping the one holding the most warm-up keys, or else the newest, in the cache
+func (s *Store) warmUp() error {
+	if len(s.warmupKeys) == 0 && s.warmupSegments <= 0 {
+		return nil
+	}
+
+	counts := map[string]int{}
+	for i := max(len(s.dataFiles)-s.warmupSegments, 0); i < len(s.dataFiles); i++ {
+		counts[s.dataFiles[i]] = 0
+	}
+
+	for _, key := range s.warmupKeys {
+		timestampedKey, ok := s.lookup(s.foldKey(key))
+		if !ok {
+			continue
+		}
+		if _, isInLogFile := s.memtable[timestampedKey]; isInLogFile {
+			continue
+		}
+
+		timestampRange := s.getTimestampRangeForKey(timestampedKey)
+		if timestampRange != nil {
+			counts[timestampRange.Start]++
+		}
+	}
+
+	var kept *Cache
+	keptCount := -1
+	for _, dataFile := range s.dataFiles {
+		count, ok := counts[dataFile]
+		if !ok {
+			continue
+		}
+
+		cache, err := s.readCache(s.getTimestampRangeForKey(dataFile), nil)
+		if err != nil {
+			return err
+		}
+
+		// the newest wins ties so that it is kept if no warm-up key is in the data files
+		if count >= keptCount {
+			kept, keptCount = cache, count
+		}
+	}
+
+	if kept != nil {
+		s.cacheLock.Lock()
+		s.cache = kept
+		s.discardPrefetchedCache()
+		s.cacheLock.Unlock()
+	}
+
+	return nil
+}
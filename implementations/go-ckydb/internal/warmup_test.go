@@ -0,0 +1,77 @@
+package internal
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWarmup(t *testing.T) {
+	dbPath, err := filepath.Abs("testWarmupDb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = ClearDummyFileDataInDb(dbPath) }()
+
+	// newStore returns a store loaded with the given options on the dummy data, and the number of
+	// data files read by Load without any warm-up
+	newStore := func(t *testing.T, opts ...StoreOption) (*Store, int64) {
+		err := ClearDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = AddDummyFileDataInDb(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		baseline := NewStore(dbPath, 4)
+		err = baseline.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		store := NewStore(dbPath, 4, opts...)
+		err = store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return store, baseline.Stats().CacheLoads
+	}
+
+	t.Run("WarmupKeysShouldLoadTheDataFileHoldingThemIntoTheCache", func(t *testing.T) {
+		store, loads := newStore(t, WithWarmupKeys([]string{"cow", "dog"}))
+		assert.Equal(t, loads+1, store.Stats().CacheLoads)
+		assert.Equal(t, "1655375120328185000", store.cache.start)
+
+		value, err := store.Get("dog")
+		assert.Nil(t, err)
+		assert.Equal(t, "23 months", value)
+		assert.Equal(t, int64(1), store.Stats().CacheHits)
+		assert.Equal(t, loads+1, store.Stats().CacheLoads)
+	})
+
+	t.Run("WarmupKeysShouldSkipNonexistentKeysAndKeysInTheLogFile", func(t *testing.T) {
+		store, loads := newStore(t, WithWarmupKeys([]string{"goat", "mouse", "bar"}))
+		assert.Equal(t, loads, store.Stats().CacheLoads)
+	})
+
+	t.Run("WarmupLastSegmentsShouldLoadTheNewestDataFiles", func(t *testing.T) {
+		store, loads := newStore(t, WithWarmupLastSegments(1))
+		assert.Equal(t, loads+1, store.Stats().CacheLoads)
+		assert.Equal(t, "1655375120328186000", store.cache.start)
+
+		// more segments than there are data files reads them all, keeping the newest
+		store, loads = newStore(t, WithWarmupLastSegments(5))
+		assert.Equal(t, loads+2, store.Stats().CacheLoads)
+		assert.Equal(t, "1655375120328186000", store.cache.start)
+	})
+
+	t.Run("WarmupKeysShouldBeKeptInTheCacheOverTheNewestDataFile", func(t *testing.T) {
+		store, loads := newStore(t, WithWarmupLastSegments(2), WithWarmupKeys([]string{"cow"}))
+		assert.Equal(t, loads+2, store.Stats().CacheLoads)
+		assert.Equal(t, "1655375120328185000", store.cache.start)
+	})
+}
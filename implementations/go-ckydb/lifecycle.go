@@ -0,0 +1,61 @@
+package ckydb
+
+import "github.com/sopherapps/ckydb/implementations/go-ckydb/internal"
+
+var (
+	ErrNotOpened      = internal.ErrNotOpened
+	ErrDatabaseClosed = internal.ErrDatabaseClosed
+	ErrLoading        = internal.ErrLoading
+)
+
+// State is the stage of its lifecycle that a Ckydb is at
+type State int32
+
+const (
+	// StateNew is the state of a Ckydb that has been loaded from disk but not opened yet
+	StateNew State = iota
+	// StateOpen is the state of a Ckydb between Open and Close, the only one in which it may be used
+	StateOpen
+	// StateClosed is the state of a Ckydb after Close, until it is opened again
+	StateClosed
+	// StateLoading is the state of a Ckydb returned by ConnectAsync until it has been loaded from disk and opened
+	StateLoading
+)
+
+func (s State) String() string {
+	switch s {
+	case StateNew:
+		return "new"
+	case StateOpen:
+		return "open"
+	case StateClosed:
+		return "closed"
+	case StateLoading:
+		return "loading"
+	}
+
+	return "unknown"
+}
+
+// State returns the stage of its lifecycle that the database is at. Every method other than State, Stats,
+// Tasks, Health and ReadOplog fails with an ErrNotOpened error before Open, an ErrLoading error while ConnectAsync
+// loads it, but for the reads it serves meanwhile, and an ErrDatabaseClosed error after Close, or returns nothing
+// if it has no error to return
+func (c *Ckydb) State() State {
+	return State(c.state.Load())
+}
+
+// checkState returns an ErrNotOpened, an ErrLoading or an ErrDatabaseClosed error unless the database is open.
+// Methods call it while holding mutLock so that Close waits for the operations under way
+func (c *Ckydb) checkState() error {
+	switch c.State() {
+	case StateNew:
+		return ErrNotOpened
+	case StateClosed:
+		return ErrDatabaseClosed
+	case StateLoading:
+		return ErrLoading
+	}
+
+	return nil
+}
@@ -0,0 +1,64 @@
+package ckydb
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/sopherapps/ckydb/implementations/go-ckydb/internal"
+)
+
+// Logger is what ckydb logs its messages to. *log.Logger satisfies it
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// WithLogger sets the logger to which ckydb logs its messages. It defaults to the standard logger
+func WithLogger(logger Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// WithSlowOpThreshold logs any operation that takes longer than the given threshold, together with
+// what it did e.g. whether it reloaded the cache or rewrote the whole log file
+func WithSlowOpThreshold(threshold time.Duration) Option {
+	return func(o *options) {
+		o.slowOpThreshold = threshold
+	}
+}
+
+// logSlowOp logs the operation if it took longer than the slow-op threshold
+func (c *Ckydb) logSlowOp(op string, key string, duration time.Duration, st *internal.OpStats, err error) {
+	if c.slowOpThreshold <= 0 || duration < c.slowOpThreshold {
+		return
+	}
+
+	details := []string{fmt.Sprintf("slow %s took %s", op, duration)}
+	if key != "" {
+		details = append(details, fmt.Sprintf("key_hash=%s", hashKey(key)))
+	}
+
+	if st != nil {
+		details = append(details,
+			fmt.Sprintf("cache_reload=%t", st.CacheReload),
+			fmt.Sprintf("log_rewrite=%t", st.LogRewrite),
+			fmt.Sprintf("log_roll=%t", st.LogRoll),
+			fmt.Sprintf("bytes_read=%d", st.BytesRead),
+			fmt.Sprintf("bytes_written=%d", st.BytesWritten),
+			fmt.Sprintf("files_touched=%v", st.FilesTouched),
+		)
+	}
+
+	if err != nil {
+		details = append(details, fmt.Sprintf("error=%q", err))
+	}
+
+	c.logger.Printf("ckydb: %s", strings.Join(details, " "))
+}
+
+// defaultLogger returns the logger to use if none is set
+func defaultLogger() Logger {
+	return log.Default()
+}
@@ -0,0 +1,97 @@
+package ckydb
+
+import (
+	"math"
+	"runtime/debug"
+	"runtime/metrics"
+	"time"
+)
+
+// MemoryPolicy configures the background memory governor task
+type MemoryPolicy struct {
+	// SoftLimit is the number of bytes of memory that the process should stay under.
+	// Zero means the soft memory limit of the Go runtime, set by GOMEMLIMIT or debug.SetMemoryLimit
+	SoftLimit int64
+	// Threshold is the fraction of SoftLimit at which memory is shed. Zero means 0.9
+	Threshold float64
+	// Interval is how often the memory in use is checked. Zero means every second
+	Interval time.Duration
+}
+
+const (
+	defaultMemoryThreshold = 0.9
+	defaultMemoryInterval  = time.Second
+	maxMemoryShedBackoff   = 64
+)
+
+// WithMemoryGovernor runs a background task that checks the memory the process has obtained from the operating
+// system, and calls ShedMemory whenever it reaches the threshold of the soft limit, as configured by the policy.
+// The garbage collector can then return the memory freed before the process is killed for running out of it,
+// as in containers. With no soft limit, neither in the policy nor in the Go runtime, the task does nothing
+func WithMemoryGovernor(policy MemoryPolicy) Option {
+	return func(o *options) {
+		if policy.Threshold <= 0 {
+			policy.Threshold = defaultMemoryThreshold
+		}
+		if policy.Interval <= 0 {
+			policy.Interval = defaultMemoryInterval
+		}
+		o.memoryPolicy = &policy
+	}
+}
+
+// ShedMemory empties the cache and rolls the log file into a data file, if it has any keys, emptying the memtable.
+// Followers only empty their cache
+func (c *Ckydb) ShedMemory() error {
+	c.mutLock.Lock()
+	defer c.mutLock.Unlock()
+
+	return c.instrument(opShedMemory, "", c.store.ShedMemoryWithStats)
+}
+
+// shedMemoryIfNeeded sheds memory if the memory in use has reached the threshold of the soft limit of
+// the memory policy, logging any error. It is the work of the memory governor task.
+// The memory in use is that of the whole process, which shedding may not bring under the threshold, so
+// while it stays there, the checks after each shed are skipped, twice as many each time up to 64, rather than
+// rolling the few keys set since the last shed into a tiny data file at every check
+func (c *Ckydb) shedMemoryIfNeeded() error {
+	limit := c.memoryPolicy.SoftLimit
+	if limit <= 0 {
+		limit = debug.SetMemoryLimit(-1)
+	}
+	if limit <= 0 || limit == math.MaxInt64 {
+		return nil
+	}
+
+	if float64(getMemoryInUse()) < c.memoryPolicy.Threshold*float64(limit) {
+		c.memoryShedSkips, c.memoryShedBackoff = 0, 0
+		return nil
+	}
+
+	if c.memoryShedSkips > 0 {
+		c.memoryShedSkips--
+		return nil
+	}
+
+	c.memoryShedSkips = max(c.memoryShedBackoff, 1)
+	c.memoryShedBackoff = min(2*c.memoryShedSkips, maxMemoryShedBackoff)
+
+	err := c.ShedMemory()
+	if err != nil {
+		c.logger.Printf("error: %s", err)
+	}
+
+	return err
+}
+
+// getMemoryInUse returns the bytes of memory that the Go runtime has obtained from the operating system and not
+// returned to it, which the soft memory limit of the runtime is compared against
+func getMemoryInUse() int64 {
+	samples := []metrics.Sample{
+		{Name: "/memory/classes/total:bytes"},
+		{Name: "/memory/classes/heap/released:bytes"},
+	}
+	metrics.Read(samples)
+
+	return int64(samples[0].Value.Uint64()) - int64(samples[1].Value.Uint64())
+}
@@ -0,0 +1,43 @@
+package ckydb
+
+import "github.com/sopherapps/ckydb/implementations/go-ckydb/internal"
+
+// SetMeta sets the given key of the metadata segment of the database to the value. The segment is kept
+// apart from the keys of the database, for applications to record e.g. their schema version and migration
+// state inside the database itself. Its keys are not seen by All, Prefix, snapshots or exports, cannot
+// expire or be evicted, and are not replicated. Clear empties it along with the rest of the database
+func (c *Ckydb) SetMeta(key string, value string) error {
+	c.mutLock.Lock()
+	defer c.mutLock.Unlock()
+
+	return c.instrument(opSetMeta, key, func(st *internal.OpStats) error {
+		return c.store.SetMeta(key, value)
+	})
+}
+
+// GetMeta returns the value of the given key of the metadata segment.
+// It returns an ErrNotFound error if the key is nonexistent
+func (c *Ckydb) GetMeta(key string) (string, error) {
+	c.mutLock.RLock()
+	defer c.mutLock.RUnlock()
+
+	var value string
+	err := c.instrument(opGetMeta, key, func(st *internal.OpStats) error {
+		var err error
+		value, err = c.store.GetMeta(key)
+		return err
+	})
+
+	return value, err
+}
+
+// DeleteMeta deletes the given key from the metadata segment.
+// It returns an ErrNotFound error if the key is nonexistent
+func (c *Ckydb) DeleteMeta(key string) error {
+	c.mutLock.Lock()
+	defer c.mutLock.Unlock()
+
+	return c.instrument(opDeleteMeta, key, func(st *internal.OpStats) error {
+		return c.store.DeleteMeta(key)
+	})
+}
@@ -0,0 +1,43 @@
+package ckydb
+
+import (
+	"time"
+
+	"github.com/sopherapps/ckydb/implementations/go-ckydb/internal"
+)
+
+// OpInfo describes an operation that has completed
+type OpInfo struct {
+	// Name is the name of the operation e.g. "set", "get", "vacuum"
+	Name string
+	// Key is the key the operation was called with. It is empty for operations without a key
+	Key          string
+	Duration     time.Duration
+	BytesRead    int64
+	BytesWritten int64
+	// Err is the error returned by the operation, if any
+	Err error
+}
+
+// WithOnOperation calls fn after each operation with what it did. fn is called synchronously,
+// while the database is locked, so it should be quick e.g. updating counters of custom telemetry
+func WithOnOperation(fn func(op OpInfo)) Option {
+	return func(o *options) {
+		o.onOperation = fn
+	}
+}
+
+// notifyOperation passes the info about the completed operation to the onOperation callback, if any
+func (c *Ckydb) notifyOperation(op string, key string, duration time.Duration, st *internal.OpStats, err error) {
+	if c.onOperation == nil {
+		return
+	}
+
+	info := OpInfo{Name: op, Key: key, Duration: duration, Err: err}
+	if st != nil {
+		info.BytesRead = st.BytesRead
+		info.BytesWritten = st.BytesWritten
+	}
+
+	c.onOperation(info)
+}
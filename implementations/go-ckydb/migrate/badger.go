@@ -0,0 +1,67 @@
+package migrate
+
+import (
+	"errors"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/sopherapps/ckydb/implementations/go-ckydb"
+)
+
+// ImportBadger copies the key-value pairs in the Badger database at path into db,
+// treating keys that already exist as the policy dictates. Expired keys are skipped
+func ImportBadger(db *ckydb.Ckydb, path string, policy ckydb.ImportPolicy) (ckydb.ImportResult, error) {
+	badgerDb, err := badger.Open(badger.DefaultOptions(path).WithReadOnly(true).WithLogger(nil))
+	if err != nil {
+		return ckydb.ImportResult{}, err
+	}
+	defer func() { _ = badgerDb.Close() }()
+
+	data := map[string]string{}
+	err = badgerDb.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			item := it.Item()
+			value, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+
+			data[string(item.KeyCopy(nil))] = string(value)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return ckydb.ImportResult{}, err
+	}
+
+	return db.Import(data, policy)
+}
+
+// ExportBadger copies all key-value pairs in db into the Badger database at path,
+// creating it if it does not exist, and returns the number of pairs copied
+func ExportBadger(db *ckydb.Ckydb, path string) (int, error) {
+	badgerDb, err := badger.Open(badger.DefaultOptions(path).WithLogger(nil))
+	if err != nil {
+		return 0, err
+	}
+
+	batch := badgerDb.NewWriteBatch()
+	count, err := exportSnapshot(db, func(key string, value string) error {
+		return batch.Set([]byte(key), []byte(value))
+	})
+	if err == nil {
+		err = batch.Flush()
+	} else {
+		batch.Cancel()
+	}
+
+	err = errors.Join(err, badgerDb.Close())
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
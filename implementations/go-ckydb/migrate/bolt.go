@@ -0,0 +1,74 @@
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sopherapps/ckydb/implementations/go-ckydb"
+	bolt "go.etcd.io/bbolt"
+)
+
+// boltOpenTimeout is how long to wait for the lock on a bbolt database held by another process
+const boltOpenTimeout = 5 * time.Second
+
+// ImportBolt copies the key-value pairs in the given bucket of the bbolt database at path into db,
+// treating keys that already exist as the policy dictates. Nested buckets are skipped.
+// It returns an ErrBucketNotFound error if there is no such bucket
+func ImportBolt(db *ckydb.Ckydb, path string, bucket string, policy ckydb.ImportPolicy) (ckydb.ImportResult, error) {
+	boltDb, err := bolt.Open(path, 0600, &bolt.Options{Timeout: boltOpenTimeout, ReadOnly: true})
+	if err != nil {
+		return ckydb.ImportResult{}, err
+	}
+	defer func() { _ = boltDb.Close() }()
+
+	data := map[string]string{}
+	err = boltDb.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return fmt.Errorf("%w: %s", ErrBucketNotFound, bucket)
+		}
+
+		return b.ForEach(func(k, v []byte) error {
+			// nested buckets have nil values
+			if v != nil {
+				data[string(k)] = string(v)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return ckydb.ImportResult{}, err
+	}
+
+	return db.Import(data, policy)
+}
+
+// ExportBolt copies all key-value pairs in db into the given bucket of the bbolt database at path,
+// creating both if they do not exist, and returns the number of pairs copied.
+// The copy is done in a single transaction so it is either complete or not done at all
+func ExportBolt(db *ckydb.Ckydb, path string, bucket string) (int, error) {
+	boltDb, err := bolt.Open(path, 0600, &bolt.Options{Timeout: boltOpenTimeout})
+	if err != nil {
+		return 0, err
+	}
+
+	var count int
+	err = boltDb.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(bucket))
+		if err != nil {
+			return err
+		}
+
+		count, err = exportSnapshot(db, func(key string, value string) error {
+			return b.Put([]byte(key), []byte(value))
+		})
+		return err
+	})
+	err = errors.Join(err, boltDb.Close())
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
@@ -0,0 +1,30 @@
+// Package migrate copies key-value pairs between ckydb and other embedded key-value stores,
+// namely bbolt (BoltDB) and Badger, to ease moving data in either direction.
+//
+// Keys and values are copied as they are, so those containing ckydb's separators cannot be imported
+// and fail with a ckydb.ErrInvalidKeyValue error.
+package migrate
+
+import (
+	"errors"
+
+	"github.com/sopherapps/ckydb/implementations/go-ckydb"
+)
+
+var ErrBucketNotFound = errors.New("bucket not found")
+
+// exportSnapshot calls fn with every key-value pair in a snapshot of db, returning the number of pairs
+func exportSnapshot(db *ckydb.Ckydb, fn func(key string, value string) error) (count int, err error) {
+	snap, err := db.Snapshot()
+	if err != nil {
+		return 0, err
+	}
+	defer func() { err = errors.Join(err, snap.Close()) }()
+
+	err = snap.ForEach(func(key string, value string) error {
+		count++
+		return fn(key, value)
+	})
+
+	return count, err
+}
@@ -0,0 +1,139 @@
+package migrate
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/sopherapps/ckydb/implementations/go-ckydb"
+	"github.com/stretchr/testify/assert"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestMigrate(t *testing.T) {
+	vacuumIntervalSec := 60.0
+	maxFileSizeKB := 4.0
+	records := map[string]string{
+		"hey":      "English",
+		"salut":    "French",
+		"hola":     "Spanish",
+		"mulimuta": "Runyoro",
+	}
+
+	// connectToSeededDb connects to a new database with the given records
+	connectToSeededDb := func(t *testing.T, data map[string]string) *ckydb.Ckydb {
+		db, err := ckydb.Connect(filepath.Join(t.TempDir(), "db"), maxFileSizeKB, vacuumIntervalSec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = db.Close() })
+
+		for k, v := range data {
+			err = db.Set(k, v)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		return db
+	}
+
+	getAll := func(db *ckydb.Ckydb) map[string]string {
+		data := map[string]string{}
+		for k, v := range db.All() {
+			data[k] = v
+		}
+		return data
+	}
+
+	t.Run("ExportBoltThenImportBoltShouldCopyAllKeyValues", func(t *testing.T) {
+		boltPath := filepath.Join(t.TempDir(), "bolt.db")
+		source := connectToSeededDb(t, records)
+
+		count, err := ExportBolt(source, boltPath, "kv")
+		assert.Nil(t, err)
+		assert.Equal(t, len(records), count)
+
+		target := connectToSeededDb(t, map[string]string{"hey": "existing"})
+		result, err := ImportBolt(target, boltPath, "kv", ckydb.SkipExisting)
+		assert.Nil(t, err)
+		assert.Equal(t, ckydb.ImportResult{Inserted: 3, Skipped: 1}, result)
+		assert.Equal(t, map[string]string{
+			"hey":      "existing",
+			"salut":    "French",
+			"hola":     "Spanish",
+			"mulimuta": "Runyoro",
+		}, getAll(target))
+	})
+
+	t.Run("ImportBoltShouldSkipNestedBuckets", func(t *testing.T) {
+		boltPath := filepath.Join(t.TempDir(), "bolt.db")
+		boltDb, err := bolt.Open(boltPath, 0600, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = boltDb.Update(func(tx *bolt.Tx) error {
+			b, err := tx.CreateBucket([]byte("kv"))
+			if err != nil {
+				return err
+			}
+			_, err = b.CreateBucket([]byte("nested"))
+			if err != nil {
+				return err
+			}
+			return b.Put([]byte("foo"), []byte("bar"))
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = boltDb.Close()
+
+		target := connectToSeededDb(t, nil)
+		result, err := ImportBolt(target, boltPath, "kv", ckydb.FailOnConflict)
+		assert.Nil(t, err)
+		assert.Equal(t, ckydb.ImportResult{Inserted: 1}, result)
+		assert.Equal(t, map[string]string{"foo": "bar"}, getAll(target))
+
+		_, err = ImportBolt(target, boltPath, "non-existent", ckydb.Overwrite)
+		assert.ErrorIs(t, err, ErrBucketNotFound)
+	})
+
+	t.Run("ExportBadgerThenImportBadgerShouldCopyAllKeyValues", func(t *testing.T) {
+		badgerPath := filepath.Join(t.TempDir(), "badger")
+		source := connectToSeededDb(t, records)
+
+		count, err := ExportBadger(source, badgerPath)
+		assert.Nil(t, err)
+		assert.Equal(t, len(records), count)
+
+		target := connectToSeededDb(t, map[string]string{"hey": "existing"})
+		result, err := ImportBadger(target, badgerPath, ckydb.Overwrite)
+		assert.Nil(t, err)
+		assert.Equal(t, ckydb.ImportResult{Inserted: 3, Overwritten: 1}, result)
+		assert.Equal(t, records, getAll(target))
+	})
+
+	t.Run("ImportBadgerShouldFailOnConflictWithoutImportingAnything", func(t *testing.T) {
+		badgerPath := filepath.Join(t.TempDir(), "badger")
+		badgerDb, err := badger.Open(badger.DefaultOptions(badgerPath).WithLogger(nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = badgerDb.Update(func(txn *badger.Txn) error {
+			err := txn.Set([]byte("hey"), []byte("imported"))
+			if err != nil {
+				return err
+			}
+			return txn.Set([]byte("new"), []byte("imported"))
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = badgerDb.Close()
+
+		target := connectToSeededDb(t, map[string]string{"hey": "existing"})
+		_, err = ImportBadger(target, badgerPath, ckydb.FailOnConflict)
+		assert.ErrorIs(t, err, ckydb.ErrConflict)
+		assert.Equal(t, map[string]string{"hey": "existing"}, getAll(target))
+	})
+}
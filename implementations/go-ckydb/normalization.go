@@ -0,0 +1,51 @@
+package ckydb
+
+import (
+	"github.com/sopherapps/ckydb/implementations/go-ckydb/internal"
+	"golang.org/x/text/unicode/norm"
+)
+
+// KeyNormalization is the Unicode normalization form that keys are converted to
+type KeyNormalization int
+
+const (
+	// NormalizeNone leaves keys as they are. It is the default
+	NormalizeNone KeyNormalization = iota
+	// NormalizeNFC composes keys canonically, so that e.g. "é" as one code point and as "e" followed by
+	// a combining acute accent are the same key
+	NormalizeNFC
+	// NormalizeNFKC also replaces compatibility characters by their equivalents e.g. "ﬁ" by "fi"
+	NormalizeNFKC
+)
+
+// WithKeyNormalization converts every key given to the database, e.g. to Set, Get, Delete or Prefix, to the given
+// Unicode normalization form, so that visually identical keys do not end up as distinct keys. Keys set before it
+// was enabled are only found by keys with the same normalized form if they were normalized already
+func WithKeyNormalization(form KeyNormalization) Option {
+	return func(o *options) {
+		o.keyNormalization = form
+	}
+}
+
+// WithCaseInsensitiveKeys makes keys case-insensitive, for configuration stores, so that e.g. "Timeout"
+// and "timeout" are the same key. Keys are converted to lower case on writes and lookups alike, including
+// the prefixes given to Prefix, and Keys returns them in lower case. The casing that a key was last set with
+// is kept and returned by Describe as KeyInfo.OriginalKey
+func WithCaseInsensitiveKeys(isEnabled bool) Option {
+	return func(o *options) {
+		o.storeOptions = append(o.storeOptions, internal.WithCaseInsensitiveKeys(isEnabled))
+		o.caseInsensitive = isEnabled
+	}
+}
+
+// normalizeKey converts the key to the normalization form of the database
+func (c *Ckydb) normalizeKey(key string) string {
+	switch c.keyNormalization {
+	case NormalizeNFC:
+		return norm.NFC.String(key)
+	case NormalizeNFKC:
+		return norm.NFKC.String(key)
+	default:
+		return key
+	}
+}
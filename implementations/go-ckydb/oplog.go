@@ -0,0 +1,57 @@
+package ckydb
+
+import (
+	"iter"
+	"time"
+
+	"github.com/sopherapps/ckydb/implementations/go-ckydb/internal"
+)
+
+// OplogEntry is a mutation recorded in the oplog, together with its sequence number
+type OplogEntry struct {
+	// Seq is the sequence number of the entry. It is one more than that of the entry before it,
+	// even across restarts and clears
+	Seq uint64
+	Op
+}
+
+// WithOplog appends every committed mutation to the "oplog" folder in the database folder,
+// each with a sequence number, so that consumers can follow the changes with ReadOplog.
+// A mutation that is committed but cannot be appended to the oplog returns the error
+func WithOplog(isEnabled bool) Option {
+	return func(o *options) {
+		o.storeOptions = append(o.storeOptions, internal.WithOplog(isEnabled))
+	}
+}
+
+// ReadOplog returns an iterator over the entries of the oplog of the database at dbPath, starting
+// at the entry whose sequence number is fromSeq. It can be called from another program than the one
+// writing to the database. The iteration stops at the last entry written so far, so a consumer
+// follows the changes by calling ReadOplog again with the sequence number after the last one it saw
+func ReadOplog(dbPath string, fromSeq uint64) iter.Seq2[OplogEntry, error] {
+	return toOplogEntries(internal.ReadOplog(dbPath, fromSeq))
+}
+
+// ReadOplog returns an iterator over the entries of the database's oplog, starting at the entry
+// whose sequence number is fromSeq. Like the ReadOplog function, it only reads the oplog files,
+// so it works whatever the State of the database, e.g. to catch up on the last entries after Close
+func (c *Ckydb) ReadOplog(fromSeq uint64) iter.Seq2[OplogEntry, error] {
+	return toOplogEntries(c.store.ReadOplog(fromSeq))
+}
+
+// toOplogEntries converts the iterator over the store's oplog entries into one over OplogEntry's
+func toOplogEntries(entries iter.Seq2[internal.OplogEntry, error]) iter.Seq2[OplogEntry, error] {
+	return func(yield func(OplogEntry, error) bool) {
+		for entry, err := range entries {
+			if err != nil {
+				yield(OplogEntry{}, err)
+				return
+			}
+
+			op := Op{Type: OpType(entry.Op), Key: entry.Key, Value: entry.Value, Time: time.Unix(0, entry.Timestamp)}
+			if !yield(OplogEntry{Seq: entry.Seq, Op: op}, nil) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package ckydb
+
+import (
+	"io"
+	"time"
+
+	"github.com/sopherapps/ckydb/implementations/go-ckydb/internal"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type RetentionPolicy = internal.RetentionPolicy
+type RetentionAction = internal.RetentionAction
+type EvictionPolicy = internal.EvictionPolicy
+
+type Clock = internal.Clock
+
+// RealClock is the Clock that tells the real time. It is the default Clock
+var RealClock = internal.RealClock
+
+const (
+	RetentionMerge   = internal.RetentionMerge
+	RetentionArchive = internal.RetentionArchive
+	RetentionDelete  = internal.RetentionDelete
+)
+
+const (
+	EvictLRU = internal.EvictLRU
+	EvictLFU = internal.EvictLFU
+)
+
+// Option configures optional behaviour of a Ckydb instance
+type Option func(*options)
+
+type options struct {
+	storeOptions      []internal.StoreOption
+	clock             internal.Clock
+	vacuumTaskOptions []internal.TaskOption
+	compactionPolicy  *CompactionPolicy
+	indexBatchPolicy  *IndexBatchPolicy
+	memoryPolicy      *MemoryPolicy
+	onOperation       func(op OpInfo)
+	expvarPrefix      string
+	tracer            trace.Tracer
+	logger            Logger
+	slowOpThreshold   time.Duration
+	replicationSink   ReplicationSink
+	replicationPolicy ReplicationPolicy
+	conflictResolver  ConflictResolver
+	isFollower        bool
+	followerInterval  time.Duration
+	auditLog          io.Writer
+	hashAuditKeys     bool
+	throttle          *MaintenanceThrottle
+	keyNormalization  KeyNormalization
+	caseInsensitive   bool
+	quarantine        bool
+	engine            Engine
+}
+
+// newOptions creates the options resulting from applying all the given opts
+func newOptions(opts []Option) *options {
+	o := &options{logger: defaultLogger(), clock: internal.RealClock}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return o
+}
+
+// WithRetentionPolicy limits the number or total size of data files kept in the database,
+// merging, archiving or deleting the oldest data files whenever the limits are exceeded.
+// The keys of archived or deleted data files are forgotten as if deleted, and their deletions replicated
+func WithRetentionPolicy(policy RetentionPolicy) Option {
+	return func(o *options) {
+		o.storeOptions = append(o.storeOptions, internal.WithRetentionPolicy(policy))
+	}
+}
+
+// WithRetention drops whole data files once every key in them is older than the given period.
+// This is checked every time the vacuum task runs and whenever the log file is rolled.
+// The keys of the dropped data files are forgotten as if deleted, and their deletions replicated
+func WithRetention(period time.Duration) Option {
+	return func(o *options) {
+		o.storeOptions = append(o.storeOptions, internal.WithRetention(period))
+	}
+}
+
+// WithMaxDatabaseSize limits the total size in bytes of the files in the database folder, so that
+// the database cannot fill the disk. Once it is reached, Sets fail with an ErrQuotaExceeded error
+// unless WithQuotaEviction is enabled. Zero means no limit
+func WithMaxDatabaseSize(bytes int64) Option {
+	return func(o *options) {
+		o.storeOptions = append(o.storeOptions, internal.WithMaxDatabaseSize(bytes))
+	}
+}
+
+// WithQuotaEviction makes Sets that would exceed the maximum database size delete the oldest
+// data files, together with their keys, until the new key-value pair fits
+func WithQuotaEviction(isEnabled bool) Option {
+	return func(o *options) {
+		o.storeOptions = append(o.storeOptions, internal.WithQuotaEviction(isEnabled))
+	}
+}
+
+// WithMaxKeys turns the database into a bounded cache of at most n keys. Setting a new key beyond
+// the limit first evicts the least recently (EvictLRU) or least frequently (EvictLFU) used key. The usage
+// of the keys is tracked in memory and saved every time the vacuum task runs, so the usage since then
+// is lost on a crash. Zero means no limit
+func WithMaxKeys(n int, policy EvictionPolicy) Option {
+	return func(o *options) {
+		o.storeOptions = append(o.storeOptions, internal.WithMaxKeys(n, policy))
+	}
+}
+
+// WithInvariantChecks makes every Get and Set check that the key is routed to the log file or data file
+// whose range it falls in, and that every Set can be read back, failing with an ErrInvariantViolated
+// error otherwise. It is meant for tests, as the checks slow down every operation
+func WithInvariantChecks(isEnabled bool) Option {
+	return func(o *options) {
+		o.storeOptions = append(o.storeOptions, internal.WithInvariantChecks(isEnabled))
+	}
+}
+
+// WithStrictLoad sets whether Connect fails with an ErrCorruptedData error on any malformed record in the files
+// of the database, which it does by default. When it is false, the malformed records are skipped, counted in
+// Stats and logged instead, so that the database opens with whatever can still be read
+func WithStrictLoad(isStrict bool) Option {
+	return func(o *options) {
+		o.storeOptions = append(o.storeOptions, internal.WithStrictLoad(isStrict), internal.WithSkippedRecordHandler(func(record internal.SkippedRecord) {
+			o.logger.Printf("error: skipped the malformed record at byte %d of %s", record.Offset, record.File)
+		}))
+	}
+}
+
+// WithExpvar publishes the database's Stats via expvar under the given name, so that they are
+// served at /debug/vars alongside the other expvar variables of the program
+func WithExpvar(prefix string) Option {
+	return func(o *options) {
+		o.expvarPrefix = prefix
+	}
+}
+
+// WithCachePrefetch makes ckydb read the next data file in the background whenever a data file
+// is loaded into the cache, hiding disk latency for scans of keys in roughly chronological order
+func WithCachePrefetch(isEnabled bool) Option {
+	return func(o *options) {
+		o.storeOptions = append(o.storeOptions, internal.WithCachePrefetch(isEnabled))
+	}
+}
+
+// WithWarmup makes Connect read the data files holding the given keys before returning, so that the first requests
+// for them do not wait for the disk. Keys are looked up as given, before any WithKeyNormalization
+func WithWarmup(keys []string) Option {
+	return func(o *options) {
+		o.storeOptions = append(o.storeOptions, internal.WithWarmupKeys(keys))
+	}
+}
+
+// WithWarmupLastNSegments makes Connect read the n newest data files before returning, like WithWarmup,
+// for workloads that mostly read recently set keys
+func WithWarmupLastNSegments(n int) Option {
+	return func(o *options) {
+		o.storeOptions = append(o.storeOptions, internal.WithWarmupLastSegments(n))
+	}
+}
+
+// WithClock sets the Clock used for timestamped keys, log filenames, retention and the pacing
+// of the background tasks. It is mostly useful for tests that need deterministic time
+func WithClock(clock Clock) Option {
+	return func(o *options) {
+		o.storeOptions = append(o.storeOptions, internal.WithClock(clock))
+		o.clock = clock
+	}
+}
+
+// WithVacuumInitialDelay makes the vacuum task first run the given delay after Open,
+// instead of a full vacuum interval after it. A zero delay vacuums right away
+func WithVacuumInitialDelay(delay time.Duration) Option {
+	return func(o *options) {
+		o.vacuumTaskOptions = append(o.vacuumTaskOptions, internal.WithInitialDelay(delay))
+	}
+}
+
+// WithVacuumJitter lengthens every wait of the vacuum task by a random duration of up to jitter
+// so that many databases opened at the same time do not vacuum in lockstep
+func WithVacuumJitter(jitter time.Duration) Option {
+	return func(o *options) {
+		o.vacuumTaskOptions = append(o.vacuumTaskOptions, internal.WithJitter(jitter))
+	}
+}
+
+// WithVacuumRetry makes the vacuum task retry a failed vacuum, e.g. after a transient disk error, delay later
+// instead of a full vacuum interval later, doubling the delay on every failure in a row up to maxDelay.
+// The number of failures in a row is in Stats().VacuumFailures
+func WithVacuumRetry(delay time.Duration, maxDelay time.Duration) Option {
+	return func(o *options) {
+		o.vacuumTaskOptions = append(o.vacuumTaskOptions, internal.WithRetryBackoff(delay, maxDelay))
+	}
+}
+
+// WithVacuumFailureHandler sets the function called with the error of every failed run of the vacuum task and
+// the number of runs in a row that have failed, e.g. to alert once the vacuum has failed a few times
+func WithVacuumFailureHandler(fn func(err error, consecutiveFailures int)) Option {
+	return func(o *options) {
+		o.vacuumTaskOptions = append(o.vacuumTaskOptions, internal.WithFailureHandler(fn))
+	}
+}
+
+// WithVacuumOnOpen sets whether Connect vacuums the database before loading it, which it does by default.
+// Turning it off makes Connect return sooner on a big database, leaving the key-values deleted before it
+// on disk until the vacuum task runs, which WithVacuumInitialDelay can make sooner
+func WithVacuumOnOpen(isEnabled bool) Option {
+	return func(o *options) {
+		o.storeOptions = append(o.storeOptions, internal.WithVacuumOnLoad(isEnabled))
+	}
+}
+
+// WithMaintenanceWorkers sets the number of files that vacuuming, by the vacuum task, Vacuum or Connect, rewrites
+// in parallel, so that an application embedding ckydb on a shared host can cap the CPU it takes up. It defaults
+// to half of GOMAXPROCS, and at least 1. Compaction merges one pair of ".cky" files at a time whatever it is
+func WithMaintenanceWorkers(n int) Option {
+	return func(o *options) {
+		o.storeOptions = append(o.storeOptions, internal.WithMaintenanceWorkers(n))
+	}
+}
@@ -0,0 +1,107 @@
+package ckydb
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Partitioner decides which node of a Ring each key belongs to, e.g. by a hash of the key
+// or by the tenant or region in its prefix
+type Partitioner interface {
+	// Partition returns the position of the node owning the key out of n nodes.
+	// It is taken modulo n
+	Partition(key string, n int) int
+}
+
+// PartitionerFunc is a function that is a Partitioner
+type PartitionerFunc func(key string, n int) int
+
+// Partition calls f(key, n)
+func (f PartitionerFunc) Partition(key string, n int) int {
+	return f(key, n)
+}
+
+// ConsistentHashPartitioner partitions keys with consistent hashing, so that adding a node
+// only moves the keys that now belong to it. It is the default Partitioner of a Ring
+type ConsistentHashPartitioner struct {
+	points []ringPoint
+	nodes  int
+	lock   sync.Mutex
+}
+
+// ringPoint is a point on the ring, owning the keys whose hashes are after the previous point up to its hash
+type ringPoint struct {
+	hash uint64
+	node int
+}
+
+// NewConsistentHashPartitioner creates a new ConsistentHashPartitioner
+func NewConsistentHashPartitioner() *ConsistentHashPartitioner {
+	return &ConsistentHashPartitioner{}
+}
+
+// Partition returns the position of the node owning the key i.e. that of the first point
+// at or after the key's hash, wrapping around to the first point
+func (p *ConsistentHashPartitioner) Partition(key string, n int) int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	for p.nodes < n {
+		p.addPoints()
+	}
+	if p.nodes > n {
+		p.points, p.nodes = nil, 0
+		for p.nodes < n {
+			p.addPoints()
+		}
+	}
+
+	hash := ringHash(key)
+	i := sort.Search(len(p.points), func(i int) bool { return p.points[i].hash >= hash })
+	if i == len(p.points) {
+		i = 0
+	}
+
+	return p.points[i].node
+}
+
+// addPoints adds the points of the next node
+func (p *ConsistentHashPartitioner) addPoints() {
+	index := p.nodes
+	p.nodes++
+	for i := 0; i < ringReplicas; i++ {
+		p.points = append(p.points, ringPoint{hash: ringHash(fmt.Sprintf("node-%d-%d", index, i)), node: index})
+	}
+
+	sort.Slice(p.points, func(i, j int) bool { return p.points[i].hash < p.points[j].hash })
+}
+
+// PrefixPartitioner partitions keys by their prefix up to the first Separator e.g. the tenant ID in "tenant42:user:1"
+// or the region in "eu/orders/7", keeping all keys of the same prefix on the same node
+type PrefixPartitioner struct {
+	Separator string
+	// Routes are the positions of the nodes owning the keys of given prefixes e.g. {"eu": 0, "us": 1}
+	Routes map[string]int
+	// Fallback partitions the prefixes that are not routed, or the whole keys if they have no Separator.
+	// It defaults to a ConsistentHashPartitioner
+	Fallback Partitioner
+
+	fallbackOnce sync.Once
+}
+
+// Partition returns the position of the node owning the prefix of the key
+func (p *PrefixPartitioner) Partition(key string, n int) int {
+	prefix, _, _ := strings.Cut(key, p.Separator)
+	if node, ok := p.Routes[prefix]; ok {
+		return node
+	}
+
+	p.fallbackOnce.Do(func() {
+		if p.Fallback == nil {
+			p.Fallback = NewConsistentHashPartitioner()
+		}
+	})
+	return p.Fallback.Partition(prefix, n)
+}
@@ -0,0 +1,49 @@
+package ckydb
+
+import "github.com/sopherapps/ckydb/implementations/go-ckydb/internal"
+
+type QuarantinedRecord = internal.QuarantinedRecord
+
+// WithQuarantine moves the record of a key whose value cannot be read by Get as it is corrupted to the "corrupt"
+// folder of the database, with a report of the file it was in and the error got, and deletes the key. The Get still
+// returns the ErrCorruptedData error but later ones return an ErrNotFound error instead, so that the key can be set
+// again. Quarantined lists the reports. Deleting the key is not replicated, as the replicas hold good copies of it
+func WithQuarantine(isEnabled bool) Option {
+	return func(o *options) {
+		o.quarantine = isEnabled
+	}
+}
+
+// Quarantined returns the reports of the records moved to the "corrupt" folder by WithQuarantine,
+// for operators to inspect
+func (c *Ckydb) Quarantined() ([]QuarantinedRecord, error) {
+	c.mutLock.RLock()
+	defer c.mutLock.RUnlock()
+
+	err := c.checkState()
+	if err != nil {
+		return nil, err
+	}
+
+	return c.store.Quarantined()
+}
+
+// quarantineIfEnabled quarantines the record of the key if WithQuarantine is enabled, logging the outcome
+func (c *Ckydb) quarantineIfEnabled(key string) {
+	if !c.quarantine {
+		return
+	}
+
+	c.mutLock.Lock()
+	defer c.mutLock.Unlock()
+
+	err := c.instrument(opQuarantine, key, func(st *internal.OpStats) error {
+		return c.store.QuarantineWithStats(key, st)
+	})
+	if err != nil {
+		c.logger.Printf("error: failed to quarantine the corrupted record of a key: %s", err)
+		return
+	}
+
+	c.logger.Printf("error: quarantined the corrupted record of a key in the %s folder", internal.CorruptFolderName)
+}
@@ -0,0 +1,220 @@
+package ckydb
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sopherapps/ckydb/implementations/go-ckydb/internal"
+)
+
+const (
+	defaultReplicationBufferSize   = 1024
+	defaultReplicationRetryBackoff = 100 * time.Millisecond
+	maxReplicationRetryBackoff     = 30 * time.Second
+)
+
+// OpType is the kind of mutation an Op is
+type OpType string
+
+const (
+	OpSet    OpType = opSet
+	OpDelete OpType = opDelete
+	OpClear  OpType = opClear
+)
+
+// Op is a mutation that has been committed to the database
+type Op struct {
+	Type OpType
+	// Key is the key that was set or deleted. It is empty for OpClear
+	Key string
+	// Value is the value that was set. It is empty for OpDelete and OpClear
+	Value string
+	// Time is when the mutation was committed
+	Time time.Time
+}
+
+// ReplicationSink receives every mutation committed to a database e.g. to forward it to Kafka,
+// another ckydb or a webhook. Apply is called with one Op at a time, in the order they were committed
+type ReplicationSink interface {
+	Apply(op Op) error
+}
+
+// ReplicationPolicy configures how mutations are forwarded to a ReplicationSink
+type ReplicationPolicy struct {
+	// BufferSize is the number of mutations that can wait to be applied. Once it is full,
+	// mutations block until the sink catches up. It defaults to 1024
+	BufferSize int
+	// MaxRetries is the number of times a failed Apply is retried before the op is dropped.
+	// Zero never retries and a negative number retries forever
+	MaxRetries int
+	// RetryBackoff is the wait before the first retry. It doubles with every retry up to 30s.
+	// It defaults to 100ms
+	RetryBackoff time.Duration
+}
+
+// WithReplicationSink forwards every committed mutation to the sink from a background go routine,
+// buffering them and retrying failed ones as configured by the policy
+func WithReplicationSink(sink ReplicationSink, policy ReplicationPolicy) Option {
+	return func(o *options) {
+		if policy.BufferSize <= 0 {
+			policy.BufferSize = defaultReplicationBufferSize
+		}
+
+		if policy.RetryBackoff <= 0 {
+			policy.RetryBackoff = defaultReplicationRetryBackoff
+		}
+
+		o.replicationSink = sink
+		o.replicationPolicy = policy
+	}
+}
+
+// replicator forwards the ops it is given to a ReplicationSink from its own go routine
+type replicator struct {
+	sink    ReplicationSink
+	policy  ReplicationPolicy
+	ops     chan Op
+	logger  Logger
+	clock   internal.Clock
+	stop    chan struct{}
+	stopped chan struct{}
+	dropped atomic.Int64
+	lock    sync.Mutex
+}
+
+// newReplicator creates a replicator that is yet to be started
+func newReplicator(sink ReplicationSink, policy ReplicationPolicy, logger Logger, clock internal.Clock) *replicator {
+	return &replicator{
+		sink:   sink,
+		policy: policy,
+		ops:    make(chan Op, policy.BufferSize),
+		logger: logger,
+		clock:  clock,
+	}
+}
+
+// forward queues the op to be applied, blocking if the buffer is full
+func (r *replicator) forward(op Op) {
+	r.ops <- op
+}
+
+// start starts applying the queued ops in a go routine
+func (r *replicator) start() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.stop != nil {
+		return
+	}
+
+	r.stop = make(chan struct{})
+	r.stopped = make(chan struct{})
+	go r.run(r.stop, r.stopped)
+}
+
+// close waits for the queued ops to be applied, then stops the go routine.
+// Ops forwarded afterwards are buffered until it is started again
+func (r *replicator) close() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.stop == nil {
+		return
+	}
+
+	close(r.stop)
+	<-r.stopped
+	r.stop, r.stopped = nil, nil
+}
+
+// pending returns the number of ops yet to be applied
+func (r *replicator) pending() int {
+	return len(r.ops)
+}
+
+// run applies the queued ops until stop is closed and the buffer has been drained
+func (r *replicator) run(stop chan struct{}, stopped chan struct{}) {
+	defer close(stopped)
+
+	for {
+		select {
+		case op := <-r.ops:
+			r.apply(op, stop)
+		case <-stop:
+			for {
+				select {
+				case op := <-r.ops:
+					r.apply(op, nil)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// apply applies the op to the sink, retrying as configured by the policy. While draining
+// i.e. when stop is nil, it retries without waiting, and does not retry forever,
+// so that closing the database is not held up by a sink that is down. Errors of ckydb itself
+// that are not retryable, e.g. an ErrInvalidKeyValue error of a sink that is a database, are not retried
+func (r *replicator) apply(op Op, stop chan struct{}) {
+	backoff := r.policy.RetryBackoff
+	for retries := 0; ; retries++ {
+		err := r.sink.Apply(op)
+		if err == nil {
+			return
+		}
+
+		isDraining := stop == nil
+		isOutOfRetries := r.policy.MaxRetries >= 0 && retries >= r.policy.MaxRetries
+		if isOutOfRetries || isPermanent(err) || isDraining && r.policy.MaxRetries < 0 {
+			r.dropped.Add(1)
+			r.logger.Printf("error: dropped replication of %s: %s", op.Type, err)
+			return
+		}
+
+		if !isDraining {
+			select {
+			case <-r.clock.After(backoff):
+			case <-stop:
+				stop = nil
+			}
+		}
+
+		backoff = min(2*backoff, maxReplicationRetryBackoff)
+	}
+}
+
+// replicate records the op just committed in the audit log, with the audit metadata of ctx,
+// and forwards it to the replication sink, if any
+func (c *Ckydb) replicate(ctx context.Context, opType OpType, key string, value string) {
+	op := Op{Type: opType, Key: key, Value: value, Time: c.clock.Now()}
+	c.audit(ctx, op, false)
+	c.forward(op)
+}
+
+// forward records the time of the committed op for resolving conflicts with replicated ones,
+// and forwards it to the replication sink, if any
+func (c *Ckydb) forward(op Op) {
+	c.recordWriteTime(op)
+	if c.replicator == nil {
+		return
+	}
+
+	c.replicator.forward(op)
+}
+
+// forwardDroppedKey forwards the deletion of a key dropped by the store with its data file, e.g. by retention.
+// Keys dropped while the database is loaded are held until it is opened, so that Load does not block on
+// a replication buffer that nothing drains yet
+func (c *Ckydb) forwardDroppedKey(key string) {
+	op := Op{Type: OpDelete, Key: key, Time: c.clock.Now()}
+	if c.State() != StateOpen {
+		c.droppedOps = append(c.droppedOps, op)
+		return
+	}
+
+	c.forward(op)
+}
@@ -0,0 +1,188 @@
+package ckydb
+
+import (
+	"errors"
+	"hash/fnv"
+	"iter"
+	"sync"
+)
+
+// ringReplicas is the number of points each node has on the ring, spreading the keys evenly across the nodes
+const ringReplicas = 128
+
+// Ring spreads keys across several databases, local or remote, behind the same Controller interface.
+// By default, keys are spread with consistent hashing, so adding a node moves only the keys that now belong to it
+type Ring struct {
+	nodes       []Controller
+	partitioner Partitioner
+	lock        sync.RWMutex
+}
+
+// RingOption configures optional behaviour of a Ring
+type RingOption func(*Ring)
+
+// WithPartitioner makes the ring route keys to nodes by the given Partitioner instead of consistent hashing
+func WithPartitioner(partitioner Partitioner) RingOption {
+	return func(r *Ring) {
+		r.partitioner = partitioner
+	}
+}
+
+// NewRing creates a new Ring spreading keys across the given nodes. It returns an ErrNoNodes error if there are none.
+// Nodes are told apart by their position, so the same nodes must always be given in the same order
+// for keys to be found where they were set
+func NewRing(nodes []Controller, opts ...RingOption) (*Ring, error) {
+	if len(nodes) == 0 {
+		return nil, ErrNoNodes
+	}
+
+	r := &Ring{nodes: append([]Controller{}, nodes...), partitioner: NewConsistentHashPartitioner()}
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r, nil
+}
+
+// Open opens all nodes
+func (r *Ring) Open() error {
+	return r.forEachNode(Controller.Open)
+}
+
+// Close closes all nodes
+func (r *Ring) Close() error {
+	return r.forEachNode(Controller.Close)
+}
+
+// Set adds or updates the value of the given key in the node that owns it
+func (r *Ring) Set(key string, value string) error {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return r.nodeFor(key).Set(key, value)
+}
+
+// Get retrieves the value of the given key from the node that owns it
+func (r *Ring) Get(key string) (string, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return r.nodeFor(key).Get(key)
+}
+
+// Delete removes the given key from the node that owns it
+func (r *Ring) Delete(key string) error {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return r.nodeFor(key).Delete(key)
+}
+
+// Clear removes all key-value pairs from all nodes
+func (r *Ring) Clear() error {
+	return r.forEachNode(Controller.Clear)
+}
+
+// NodeFor returns the node that owns the given key
+func (r *Ring) NodeFor(key string) Controller {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return r.nodeFor(key)
+}
+
+// AddNode adds a node to the end of the ring and moves the keys that now belong to it from the other nodes.
+// Other operations on the ring wait until the keys are moved
+func (r *Ring) AddNode(node Controller) (moved int, err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.nodes = append(r.nodes, node)
+	return r.rebalance()
+}
+
+// Rebalance moves every key held by a node other than the one owning it to its owner, e.g. after a node
+// was added to a NewRing instead of with AddNode. It returns the number of keys moved.
+// Only keys of nodes that can be iterated over, like *Ckydb, are moved; the others are skipped
+func (r *Ring) Rebalance() (moved int, err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.rebalance()
+}
+
+// rebalance moves the misplaced keys of every node to the nodes owning them, setting each key
+// in its owner before deleting it from the node it was on
+func (r *Ring) rebalance() (moved int, err error) {
+	for i, node := range r.nodes {
+		iterable, ok := node.(interface {
+			All() iter.Seq2[string, string]
+		})
+		if !ok {
+			continue
+		}
+
+		misplaced := map[string]string{}
+		for key, value := range iterable.All() {
+			if r.nodeIndexFor(key) != i {
+				misplaced[key] = value
+			}
+		}
+
+		for key, value := range misplaced {
+			err = r.nodes[r.nodeIndexFor(key)].Set(key, value)
+			if err != nil {
+				return moved, err
+			}
+
+			err = node.Delete(key)
+			if err != nil {
+				return moved, err
+			}
+			moved++
+		}
+	}
+
+	return moved, nil
+}
+
+// nodeFor returns the node owning the given key
+func (r *Ring) nodeFor(key string) Controller {
+	return r.nodes[r.nodeIndexFor(key)]
+}
+
+// nodeIndexFor returns the position of the node owning the given key, as told by the partitioner
+func (r *Ring) nodeIndexFor(key string) int {
+	n := len(r.nodes)
+	return (r.partitioner.Partition(key, n)%n + n) % n
+}
+
+// forEachNode calls fn on every node, returning all the errors joined
+func (r *Ring) forEachNode(fn func(Controller) error) error {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	var errs []error
+	for _, node := range r.nodes {
+		errs = append(errs, fn(node))
+	}
+
+	return errors.Join(errs...)
+}
+
+// ringHash returns the FNV-1a hash of s, with its bits mixed by the finalizer of MurmurHash3
+// as the FNV-1a hashes of similar strings, like the names of the points of a node, are close together
+func ringHash(s string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(s))
+
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
+
+var _ Controller = (*Ring)(nil)
@@ -0,0 +1,22 @@
+package ckydb
+
+import "github.com/sopherapps/ckydb/implementations/go-ckydb/internal"
+
+type SegmentInfo = internal.SegmentInfo
+
+// Segments returns the data files of the database, from the oldest to the newest, each with the range of creation
+// times of its keys, its number of records, its size and how many of its values are compressed, e.g. for admin
+// pages and capacity planning. Each data file is read to count its records, without evicting the cache
+func (c *Ckydb) Segments() ([]SegmentInfo, error) {
+	c.mutLock.RLock()
+	defer c.mutLock.RUnlock()
+
+	var segments []SegmentInfo
+	err := c.instrument(opSegments, "", func(st *internal.OpStats) error {
+		var err error
+		segments, err = c.store.Segments()
+		return err
+	})
+
+	return segments, err
+}
@@ -0,0 +1,41 @@
+package ckydb
+
+import (
+	"github.com/sopherapps/ckydb/implementations/go-ckydb/internal"
+)
+
+// Snapshot is a read-only view of the database as it was when the snapshot was taken.
+// Writes made after it was taken are not seen by it
+type Snapshot = internal.Snapshot
+
+// Snapshot takes a snapshot of the database. Writers are only blocked while the index is copied
+// and the data files are hard-linked, not while the snapshot is read.
+// The snapshot should be closed when no longer needed. Clear invalidates all open snapshots
+func (c *Ckydb) Snapshot() (*Snapshot, error) {
+	c.mutLock.RLock()
+	defer c.mutLock.RUnlock()
+
+	err := c.checkState()
+	if err != nil {
+		return nil, err
+	}
+
+	return c.store.Snapshot()
+}
+
+// BackupToDir makes a copy of the database in a new folder at path that can be opened with Connect.
+// The data files are hard linked where possible, so it is much quicker than exporting for backups on
+// the same file system. Writers are blocked while the other files are copied. The oplog, archive and
+// snapshots folders are not part of the backup. It returns an error wrapping fs.ErrExist if there
+// is already something at path
+func (c *Ckydb) BackupToDir(path string) error {
+	c.mutLock.RLock()
+	defer c.mutLock.RUnlock()
+
+	err := c.checkState()
+	if err != nil {
+		return err
+	}
+
+	return c.store.BackupToDir(path)
+}
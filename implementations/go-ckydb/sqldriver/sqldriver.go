@@ -0,0 +1,326 @@
+// Package sqldriver is a minimal database/sql driver backed by ckydb, registered as "ckydb".
+//
+// The data source name is the path to the database folder, optionally followed by the query
+// parameters "maxFileSizeKB" and "vacuumIntervalSec" e.g. "/path/to/db?maxFileSizeKB=2&vacuumIntervalSec=300".
+// Connections to the same database folder share one database, so they must all give it the same parameters.
+//
+// Only the following statements, on a virtual "kv" table, are supported:
+//
+//	SELECT value FROM kv WHERE key = ?
+//	INSERT INTO kv (key, value) VALUES (?, ?)
+//	REPLACE INTO kv (key, value) VALUES (?, ?)
+//	DELETE FROM kv WHERE key = ?
+package sqldriver
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/sopherapps/ckydb/implementations/go-ckydb"
+)
+
+const (
+	DriverName = "ckydb"
+
+	defaultMaxFileSizeKB     = 4 * 1024
+	defaultVacuumIntervalSec = 5 * 60
+)
+
+var (
+	ErrUnsupportedStatement = errors.New("unsupported statement")
+	ErrUnsupportedArgument  = errors.New("unsupported argument type")
+	ErrTxNotSupported       = errors.New("transactions are not supported")
+	ErrDuplicateKey         = errors.New("duplicate key")
+	ErrConflictingDsn       = errors.New("database is already open with other parameters")
+)
+
+type statementKind int
+
+const (
+	selectStatement statementKind = iota
+	insertStatement
+	replaceStatement
+	deleteStatement
+)
+
+var statementPatterns = map[statementKind]*regexp.Regexp{
+	selectStatement:  regexp.MustCompile(`(?i)^\s*SELECT\s+value\s+FROM\s+kv\s+WHERE\s+key\s*=\s*\?\s*;?\s*$`),
+	insertStatement:  regexp.MustCompile(`(?i)^\s*INSERT\s+INTO\s+kv\s*\(\s*key\s*,\s*value\s*\)\s*VALUES\s*\(\s*\?\s*,\s*\?\s*\)\s*;?\s*$`),
+	replaceStatement: regexp.MustCompile(`(?i)^\s*REPLACE\s+INTO\s+kv\s*\(\s*key\s*,\s*value\s*\)\s*VALUES\s*\(\s*\?\s*,\s*\?\s*\)\s*;?\s*$`),
+	deleteStatement:  regexp.MustCompile(`(?i)^\s*DELETE\s+FROM\s+kv\s+WHERE\s+key\s*=\s*\?\s*;?\s*$`),
+}
+
+func init() {
+	sql.Register(DriverName, &Driver{dbs: map[string]*sharedDb{}})
+}
+
+// sharedDb is a ckydb instance shared by all connections to the same database folder
+type sharedDb struct {
+	db                *ckydb.Ckydb
+	maxFileSizeKB     float64
+	vacuumIntervalSec float64
+	refCount          int
+}
+
+type Driver struct {
+	dbs  map[string]*sharedDb
+	lock sync.Mutex
+}
+
+// Open returns a new connection to the ckydb database described by the data source name. It returns an
+// ErrConflictingDsn error if the database folder is already open with other parameters
+func (d *Driver) Open(dsn string) (driver.Conn, error) {
+	dbPath, maxFileSizeKB, vacuumIntervalSec, err := parseDsn(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	shared, ok := d.dbs[dbPath]
+	if !ok {
+		db, err := ckydb.Connect(dbPath, maxFileSizeKB, vacuumIntervalSec)
+		if err != nil {
+			return nil, err
+		}
+
+		shared = &sharedDb{db: db, maxFileSizeKB: maxFileSizeKB, vacuumIntervalSec: vacuumIntervalSec}
+		d.dbs[dbPath] = shared
+	}
+
+	if shared.maxFileSizeKB != maxFileSizeKB || shared.vacuumIntervalSec != vacuumIntervalSec {
+		return nil, fmt.Errorf("%w: %s is open with maxFileSizeKB=%v&vacuumIntervalSec=%v",
+			ErrConflictingDsn, dbPath, shared.maxFileSizeKB, shared.vacuumIntervalSec)
+	}
+
+	shared.refCount++
+	return &conn{driver: d, dbPath: dbPath, db: shared.db}, nil
+}
+
+// release closes the ckydb instance at dbPath if no connection is using it anymore
+func (d *Driver) release(dbPath string) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	shared, ok := d.dbs[dbPath]
+	if !ok {
+		return nil
+	}
+
+	shared.refCount--
+	if shared.refCount > 0 {
+		return nil
+	}
+
+	delete(d.dbs, dbPath)
+	return shared.db.Close()
+}
+
+type conn struct {
+	driver *Driver
+	dbPath string
+	db     *ckydb.Ckydb
+}
+
+// Prepare returns a prepared statement for the given query
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	for kind, pattern := range statementPatterns {
+		if pattern.MatchString(query) {
+			return &stmt{db: c.db, kind: kind}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedStatement, query)
+}
+
+// Close releases the connection's hold on the underlying database
+func (c *conn) Close() error {
+	return c.driver.release(c.dbPath)
+}
+
+// Begin always fails as transactions are not supported
+func (c *conn) Begin() (driver.Tx, error) {
+	return nil, ErrTxNotSupported
+}
+
+type stmt struct {
+	db   *ckydb.Ckydb
+	kind statementKind
+}
+
+// Close does nothing as statements hold no resources
+func (s *stmt) Close() error {
+	return nil
+}
+
+// NumInput returns the number of placeholder parameters in the statement
+func (s *stmt) NumInput() int {
+	switch s.kind {
+	case insertStatement, replaceStatement:
+		return 2
+	default:
+		return 1
+	}
+}
+
+// Exec executes an INSERT, REPLACE or DELETE statement
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	strArgs, err := toStrings(args)
+	if err != nil {
+		return nil, err
+	}
+
+	switch s.kind {
+	case insertStatement:
+		isSet, err := s.db.SetIfAbsent(strArgs[0], strArgs[1])
+		if err != nil {
+			return nil, err
+		}
+		if !isSet {
+			return nil, fmt.Errorf("%w: %s", ErrDuplicateKey, strArgs[0])
+		}
+
+		return result(1), nil
+	case replaceStatement:
+		err = s.db.Set(strArgs[0], strArgs[1])
+		if err != nil {
+			return nil, err
+		}
+
+		return result(1), nil
+	case deleteStatement:
+		err = s.db.Delete(strArgs[0])
+		if errors.Is(err, ckydb.ErrNotFound) {
+			return result(0), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		return result(1), nil
+	default:
+		return nil, ErrUnsupportedStatement
+	}
+}
+
+// Query executes a SELECT statement
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	if s.kind != selectStatement {
+		return nil, ErrUnsupportedStatement
+	}
+
+	strArgs, err := toStrings(args)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := s.db.Get(strArgs[0])
+	if errors.Is(err, ckydb.ErrNotFound) {
+		return &rows{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &rows{values: []string{value}}, nil
+}
+
+type rows struct {
+	values []string
+	cursor int
+}
+
+// Columns returns the names of the columns in the result
+func (r *rows) Columns() []string {
+	return []string{"value"}
+}
+
+// Close does nothing as the rows are already in memory
+func (r *rows) Close() error {
+	return nil
+}
+
+// Next copies the next row into dest, returning io.EOF when there are no more rows
+func (r *rows) Next(dest []driver.Value) error {
+	if r.cursor >= len(r.values) {
+		return io.EOF
+	}
+
+	dest[0] = r.values[r.cursor]
+	r.cursor++
+	return nil
+}
+
+type result int64
+
+// LastInsertId is not supported as keys are user-defined
+func (r result) LastInsertId() (int64, error) {
+	return 0, errors.New("LastInsertId is not supported")
+}
+
+// RowsAffected returns the number of keys affected by the statement
+func (r result) RowsAffected() (int64, error) {
+	return int64(r), nil
+}
+
+// parseDsn extracts the database path, maxFileSizeKB and vacuumIntervalSec from the data source name
+func parseDsn(dsn string) (string, float64, float64, error) {
+	maxFileSizeKB, vacuumIntervalSec := float64(defaultMaxFileSizeKB), float64(defaultVacuumIntervalSec)
+	parts := strings.SplitN(dsn, "?", 2)
+	if len(parts) == 1 {
+		return parts[0], maxFileSizeKB, vacuumIntervalSec, nil
+	}
+
+	params, err := url.ParseQuery(parts[1])
+	if err != nil {
+		return "", 0, 0, err
+	}
+
+	if v := params.Get("maxFileSizeKB"); v != "" {
+		maxFileSizeKB, err = strconv.ParseFloat(v, 64)
+		if err != nil {
+			return "", 0, 0, err
+		}
+	}
+
+	if v := params.Get("vacuumIntervalSec"); v != "" {
+		vacuumIntervalSec, err = strconv.ParseFloat(v, 64)
+		if err != nil {
+			return "", 0, 0, err
+		}
+	}
+
+	return parts[0], maxFileSizeKB, vacuumIntervalSec, nil
+}
+
+// toStrings converts the statement arguments into strings
+func toStrings(args []driver.Value) ([]string, error) {
+	strArgs := make([]string, len(args))
+	for i, arg := range args {
+		switch v := arg.(type) {
+		case string:
+			strArgs[i] = v
+		case []byte:
+			strArgs[i] = string(v)
+		case int64:
+			strArgs[i] = strconv.FormatInt(v, 10)
+		case float64:
+			strArgs[i] = strconv.FormatFloat(v, 'f', -1, 64)
+		case bool:
+			strArgs[i] = strconv.FormatBool(v)
+		default:
+			return nil, fmt.Errorf("%w: %T", ErrUnsupportedArgument, arg)
+		}
+	}
+
+	return strArgs, nil
+}
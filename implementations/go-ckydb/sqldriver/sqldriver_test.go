@@ -0,0 +1,200 @@
+package sqldriver
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/sopherapps/ckydb/implementations/go-ckydb/internal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSqlDriver(t *testing.T) {
+	dbPath, err := filepath.Abs("testSqlDriverDb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dsn := fmt.Sprintf("%s?maxFileSizeKB=4&vacuumIntervalSec=60", dbPath)
+
+	t.Run("InsertThenSelectShouldReturnTheValue", func(t *testing.T) {
+		db := openTestDb(t, dbPath, dsn)
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+
+		_, err := db.Exec("INSERT INTO kv (key, value) VALUES (?, ?)", "hey", "English")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var value string
+		err = db.QueryRow("SELECT value FROM kv WHERE key = ?", "hey").Scan(&value)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, "English", value)
+	})
+
+	t.Run("InsertExistingKeyShouldFailButReplaceShouldUpdateIt", func(t *testing.T) {
+		db := openTestDb(t, dbPath, dsn)
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+
+		_, err := db.Exec("INSERT INTO kv (key, value) VALUES (?, ?)", "hey", "English")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, errOnInsert := db.Exec("insert into kv(key, value) values(?, ?)", "hey", "Jane")
+		_, err = db.Exec("REPLACE INTO kv (key, value) VALUES (?, ?)", "hey", "John")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var value string
+		err = db.QueryRow("SELECT value FROM kv WHERE key = ?", "hey").Scan(&value)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.True(t, errors.Is(errOnInsert, ErrDuplicateKey))
+		assert.Equal(t, "John", value)
+	})
+
+	t.Run("ConcurrentInsertsOfTheSameKeyShouldLetOnlyOneSucceed", func(t *testing.T) {
+		db := openTestDb(t, dbPath, dsn)
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+
+		const inserts = 20
+		errs := make([]error, inserts)
+		var wg sync.WaitGroup
+		for i := 0; i < inserts; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				_, errs[i] = db.Exec("INSERT INTO kv (key, value) VALUES (?, ?)", "hey", fmt.Sprintf("English %d", i))
+			}(i)
+		}
+		wg.Wait()
+
+		var winner string
+		for i, err := range errs {
+			if err == nil {
+				assert.Empty(t, winner)
+				winner = fmt.Sprintf("English %d", i)
+			} else {
+				assert.True(t, errors.Is(err, ErrDuplicateKey))
+			}
+		}
+
+		var value string
+		err := db.QueryRow("SELECT value FROM kv WHERE key = ?", "hey").Scan(&value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.NotEmpty(t, winner)
+		assert.Equal(t, winner, value)
+	})
+
+	t.Run("DeleteShouldRemoveTheKey", func(t *testing.T) {
+		db := openTestDb(t, dbPath, dsn)
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+
+		_, err := db.Exec("REPLACE INTO kv (key, value) VALUES (?, ?)", "salut", "French")
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := db.Exec("DELETE FROM kv WHERE key = ?", "salut")
+		if err != nil {
+			t.Fatal(err)
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			t.Fatal(err)
+		}
+		resForMissingKey, err := db.Exec("DELETE FROM kv WHERE key = ?", "salut")
+		if err != nil {
+			t.Fatal(err)
+		}
+		affectedForMissingKey, err := resForMissingKey.RowsAffected()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var value string
+		err = db.QueryRow("SELECT value FROM kv WHERE key = ?", "salut").Scan(&value)
+
+		assert.Equal(t, int64(1), affected)
+		assert.Equal(t, int64(0), affectedForMissingKey)
+		assert.True(t, errors.Is(err, sql.ErrNoRows))
+	})
+
+	t.Run("UnsupportedStatementsShouldFail", func(t *testing.T) {
+		db := openTestDb(t, dbPath, dsn)
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+
+		_, err := db.Exec("DROP TABLE kv")
+		_, errOnTx := db.Begin()
+
+		assert.True(t, errors.Is(err, ErrUnsupportedStatement))
+		assert.True(t, errors.Is(errOnTx, ErrTxNotSupported))
+	})
+
+	t.Run("OpeningAnOpenDatabaseWithOtherParametersShouldFail", func(t *testing.T) {
+		db := openTestDb(t, dbPath, dsn)
+		defer func() {
+			_ = db.Close()
+			_ = internal.ClearDummyFileDataInDb(dbPath)
+		}()
+		err := db.Ping()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, otherDsn := range []string{dbPath, fmt.Sprintf("%s?maxFileSizeKB=8&vacuumIntervalSec=60", dbPath)} {
+			other, err := sql.Open(DriverName, otherDsn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.True(t, errors.Is(other.Ping(), ErrConflictingDsn))
+			_ = other.Close()
+		}
+
+		same, err := sql.Open(DriverName, dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = same.Close() }()
+		assert.Nil(t, same.Ping())
+	})
+}
+
+// openTestDb opens an empty database via the database/sql driver
+func openTestDb(t *testing.T, dbPath string, dsn string) *sql.DB {
+	err := internal.ClearDummyFileDataInDb(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open(DriverName, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
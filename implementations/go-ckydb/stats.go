@@ -0,0 +1,210 @@
+package ckydb
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sopherapps/ckydb/implementations/go-ckydb/internal"
+)
+
+type FileStats = internal.FileStats
+
+const (
+	opSet         = "set"
+	opSetWithTTL  = "set_with_ttl"
+	opTTL         = "ttl"
+	opExpire      = "expire"
+	opPersist     = "persist"
+	opSetReader   = "set_reader"
+	opGet         = "get"
+	opGetReader   = "get_reader"
+	opGetMany     = "get_many"
+	opFind        = "find"
+	opGetVersion  = "get_version"
+	opHistory     = "history"
+	opDescribe    = "describe"
+	opDelete      = "delete"
+	opSetGet      = "set_get"
+	opSetIfAbsent = "set_if_absent"
+	opDeleteGet   = "delete_get"
+	opUndelete    = "undelete"
+	opCopy        = "copy"
+	opKeysByAge   = "keys_by_age"
+	opScanRegex   = "scan_regex"
+	opSwap        = "swap"
+	opClear       = "clear"
+	opClearData   = "clear_data"
+	opVacuum      = "vacuum"
+	opLoad        = "load"
+	opCompact     = "compact"
+	opRefresh     = "refresh"
+	opVerify      = "verify"
+	opSegments    = "segments"
+	opQuarantine  = "quarantine"
+	opSetMeta     = "set_meta"
+	opGetMeta     = "get_meta"
+	opDeleteMeta  = "delete_meta"
+	opMigrate     = "migrate_format"
+	opFlush       = "flush"
+	opShedMemory  = "shed_memory"
+)
+
+// Stats are the statistics of a Ckydb instance at a given point in time
+type Stats struct {
+	// Ops is the number of times each operation e.g. "set", "get" has been called
+	Ops map[string]int64
+	// Errors is the number of times each operation has returned an error
+	Errors map[string]int64
+	// Latency summarizes how long each operation has taken, including the calls that returned an error
+	Latency     map[string]LatencyStats
+	Keys        int
+	DataFiles   int
+	CacheHits   int64
+	CacheMisses int64
+	// CacheLoads is the number of times a data file was read into the cache
+	CacheLoads int64
+	// RestoredFiles is the number of times a corrupted index or del file was restored from its backup
+	RestoredFiles int64
+	// EvictedKeys is the number of keys evicted to stay within WithMaxKeys
+	EvictedKeys int64
+	// SkippedRecords is the number of malformed records skipped as WithStrictLoad is false
+	SkippedRecords int64
+	// VacuumFailures is the number of runs in a row of the vacuum task that have failed, up to the last one
+	VacuumFailures int
+	// ReplicationPending is the number of mutations yet to be applied to the replication sink
+	ReplicationPending int
+	// ReplicationDropped is the number of mutations that could not be applied to the replication sink
+	ReplicationDropped int64
+	// Files are the reads and writes of the keys of each ".cky" file and the ".log" file, from the oldest to the
+	// newest, since the database was connected to. They are what WithRetentionPolicy's MinIdle goes by
+	Files []FileStats
+	// HotGets is the number of gets served from memory, and ColdGets that of the gets that read a ".cky" file
+	HotGets  int64
+	ColdGets int64
+	// GetBytesRead is the number of bytes of ".cky" files that gets read from disk, and ValueBytesRead that of the
+	// values they returned
+	GetBytesRead   int64
+	ValueBytesRead int64
+	// BytesWritten is the number of bytes written to the files of the database, and KeyValueBytesWritten that of the
+	// keys and values set
+	BytesWritten         int64
+	KeyValueBytesWritten int64
+	// ReadAmplification is GetBytesRead over ValueBytesRead, the bytes read from disk per byte of value got.
+	// It is 0 until a value is got
+	ReadAmplification float64
+	// WriteAmplification is BytesWritten over KeyValueBytesWritten, the bytes written to disk per byte of key and
+	// value set, including those of log rewrites, log rolls and vacuums. It is 0 until a key is set
+	WriteAmplification float64
+}
+
+// opCounters counts the calls and errors of each operation, and their latencies
+type opCounters struct {
+	ops       map[string]int64
+	errors    map[string]int64
+	latencies map[string]*latencyHistogram
+	lock      sync.Mutex
+}
+
+// newOpCounters creates a new opCounters instance
+func newOpCounters() *opCounters {
+	return &opCounters{ops: map[string]int64{}, errors: map[string]int64{}, latencies: map[string]*latencyHistogram{}}
+}
+
+// record counts a call to the given operation that took the given duration, and its error if any
+func (o *opCounters) record(op string, duration time.Duration, err error) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	o.ops[op]++
+	if err != nil {
+		o.errors[op]++
+	}
+
+	h, ok := o.latencies[op]
+	if !ok {
+		h = &latencyHistogram{}
+		o.latencies[op] = h
+	}
+	h.record(duration)
+}
+
+// latencyStats returns the summaries of the latencies of the operations
+func (o *opCounters) latencyStats() map[string]LatencyStats {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	latencies := make(map[string]LatencyStats, len(o.latencies))
+	for op, h := range o.latencies {
+		latencies[op] = h.stats()
+	}
+
+	return latencies
+}
+
+// snapshot returns copies of the operation and error counts
+func (o *opCounters) snapshot() (map[string]int64, map[string]int64) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	ops := make(map[string]int64, len(o.ops))
+	for k, v := range o.ops {
+		ops[k] = v
+	}
+
+	errors := make(map[string]int64, len(o.errors))
+	for k, v := range o.errors {
+		errors[k] = v
+	}
+
+	return ops, errors
+}
+
+// Stats returns the current statistics of the database
+func (c *Ckydb) Stats() Stats {
+	// ConnectAsync loads the store without holding the mutLock
+	var storeStats internal.Stats
+	if c.State() != StateLoading {
+		c.mutLock.RLock()
+		storeStats = c.store.Stats()
+		c.mutLock.RUnlock()
+	}
+
+	ops, errors := c.counters.snapshot()
+	stats := Stats{
+		Ops:         ops,
+		Errors:      errors,
+		Latency:     c.counters.latencyStats(),
+		Keys:        storeStats.Keys,
+		DataFiles:   storeStats.DataFiles,
+		CacheHits:   storeStats.CacheHits,
+		CacheMisses: storeStats.CacheMisses,
+		CacheLoads:  storeStats.CacheLoads,
+
+		RestoredFiles:  storeStats.RestoredFiles,
+		EvictedKeys:    storeStats.EvictedKeys,
+		SkippedRecords: storeStats.SkippedRecords,
+		Files:          storeStats.Files,
+
+		HotGets:              storeStats.HotGets,
+		ColdGets:             storeStats.ColdGets,
+		GetBytesRead:         storeStats.GetBytesRead,
+		ValueBytesRead:       storeStats.ValueBytesRead,
+		BytesWritten:         storeStats.BytesWritten,
+		KeyValueBytesWritten: storeStats.KeyValueBytesWritten,
+		ReadAmplification:    storeStats.ReadAmplification,
+		WriteAmplification:   storeStats.WriteAmplification,
+	}
+
+	for _, task := range c.getTasks() {
+		if status := task.Status(); status.Name == taskVacuum {
+			stats.VacuumFailures = status.ConsecutiveFailures
+		}
+	}
+
+	if c.replicator != nil {
+		stats.ReplicationPending = c.replicator.pending()
+		stats.ReplicationDropped = c.replicator.dropped.Load()
+	}
+
+	return stats
+}
@@ -0,0 +1,53 @@
+package ckydb
+
+import (
+	"context"
+	"io"
+
+	"github.com/sopherapps/ckydb/implementations/go-ckydb/internal"
+)
+
+// SetReader sets the value of the given key to the contents of r. In databases created with WithBlobSpillThreshold,
+// the contents are streamed into a blob so that they never have to fit in memory, whatever their size. Otherwise, or
+// if the oplog or history mode is enabled, they are read into memory and set like with Set. With a replication sink,
+// the whole value is then loaded back into memory to be replicated, as replicated operations carry their values
+func (c *Ckydb) SetReader(key string, r io.Reader) error {
+	key = c.normalizeKey(key)
+	c.mutLock.Lock()
+	defer c.mutLock.Unlock()
+
+	var value string
+	err := c.instrument(opSetReader, key, func(st *internal.OpStats) error {
+		err := c.store.SetReaderWithStats(key, r, st)
+		if err != nil || c.replicator == nil {
+			return err
+		}
+
+		value, err = c.store.GetWithStats(key, st)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	c.replicate(context.Background(), OpSet, key, value)
+	return nil
+}
+
+// GetReader returns a reader of the value of the given key, which reads it straight from its blob if it was
+// spilled or deduplicated, so that it never has to fit in memory. The reader must be closed once done with.
+// It returns an ErrNotFound error if the key is nonexistent
+func (c *Ckydb) GetReader(key string) (io.ReadCloser, error) {
+	key = c.normalizeKey(key)
+	c.mutLock.RLock()
+	defer c.mutLock.RUnlock()
+
+	var reader io.ReadCloser
+	err := c.instrument(opGetReader, key, func(st *internal.OpStats) error {
+		var err error
+		reader, err = c.store.GetReaderWithStats(key, st)
+		return err
+	})
+
+	return reader, err
+}
@@ -0,0 +1,141 @@
+package ckydb
+
+import (
+	"errors"
+	"time"
+
+	"github.com/sopherapps/ckydb/implementations/go-ckydb/internal"
+)
+
+type TaskStatus = internal.TaskStatus
+
+const (
+	taskVacuum     = "vacuum"
+	taskCompaction = "compaction"
+	taskRefresh    = "refresh"
+	taskIndexFlush = "index_flush"
+	taskMemory     = "memory_governor"
+)
+
+// Tasks returns the status of each background task, as of the last Open
+func (c *Ckydb) Tasks() []TaskStatus {
+	tasks := c.getTasks()
+	statuses := make([]TaskStatus, len(tasks))
+	for i, task := range tasks {
+		statuses[i] = task.Status()
+	}
+
+	return statuses
+}
+
+// SetVacuumInterval changes the interval of the vacuum task without reopening the database.
+// The next vacuum is rescheduled to be the new interval from now
+func (c *Ckydb) SetVacuumInterval(interval time.Duration) error {
+	if interval <= 0 {
+		return ErrOutOfBounds
+	}
+
+	err := c.checkState()
+	if err != nil {
+		return err
+	}
+
+	c.lifecycleLock.Lock()
+	defer c.lifecycleLock.Unlock()
+
+	c.vacuumIntervalSec = interval.Seconds()
+	for _, task := range c.getTasks() {
+		if task.Status().Name == taskVacuum {
+			return task.SetInterval(interval)
+		}
+	}
+
+	return nil
+}
+
+// getTasks returns the background tasks started by the last Open, if any
+func (c *Ckydb) getTasks() []internal.Worker {
+	tasks := c.tasks.Load()
+	if tasks == nil {
+		return nil
+	}
+
+	return *tasks
+}
+
+// newTasks creates the background tasks of the database
+func (c *Ckydb) newTasks() []internal.Worker {
+	var tasks []internal.Worker
+	if c.memoryPolicy != nil {
+		tasks = append(tasks, internal.NewTask(taskMemory, c.memoryPolicy.Interval, c.shedMemoryIfNeeded, internal.WithTaskClock(c.clock)))
+	}
+
+	// followers leave vacuuming and compacting to the database they follow
+	if c.isFollower {
+		if c.followerInterval > 0 {
+			tasks = append(tasks, internal.NewTask(taskRefresh, c.followerInterval, c.Refresh, internal.WithTaskClock(c.clock)))
+		}
+
+		return tasks
+	}
+
+	vacuumTaskOptions := append([]internal.TaskOption{internal.WithTaskClock(c.clock)}, c.vacuumTaskOptions...)
+	tasks = append(tasks, internal.NewTask(taskVacuum, time.Duration(c.vacuumIntervalSec*float64(time.Second)), c.vacuum, vacuumTaskOptions...))
+
+	if c.compactionPolicy != nil {
+		tasks = append(tasks, internal.NewTask(taskCompaction, c.compactionPolicy.Interval, c.compactIfNeeded, internal.WithTaskClock(c.clock)))
+	}
+
+	if c.indexBatchPolicy != nil && c.indexBatchPolicy.MaxDelay > 0 {
+		tasks = append(tasks, internal.NewTask(taskIndexFlush, c.indexBatchPolicy.MaxDelay, c.flush, internal.WithTaskClock(c.clock)))
+	}
+
+	return tasks
+}
+
+// Vacuum deletes the key-values marked for deletion and enforces the retention of data files now,
+// without waiting for the vacuum task. It then deletes the files that Clear moved aside
+func (c *Ckydb) Vacuum() error {
+	return c.vacuumWith(c.store.VacuumWithStats)
+}
+
+// vacuumWith runs the given vacuum of the store and enforces the retention of data files while holding the
+// write lock, and then deletes the files that Clear moved aside without holding it, so that deleting a big
+// cleared database does not block other operations. Failing to delete them is only logged, as the next
+// vacuum deletes them anyway
+func (c *Ckydb) vacuumWith(vacuum func(st *internal.OpStats) error) error {
+	var clearedFolders []string
+	c.mutLock.Lock()
+	err := c.instrument(opVacuum, "", func(st *internal.OpStats) error {
+		var listErr error
+		clearedFolders, listErr = c.store.ClearedFolders()
+		if listErr != nil {
+			c.logger.Printf("error: %s", listErr)
+		}
+
+		return errors.Join(vacuum(st), c.store.EnforceRetention())
+	})
+	c.mutLock.Unlock()
+
+	removeErr := internal.RemoveClearedFolders(clearedFolders)
+	if removeErr != nil {
+		c.logger.Printf("error: %s", removeErr)
+	}
+
+	return err
+}
+
+// vacuum vacuums the database, logging any error. It is the work of the vacuum task
+func (c *Ckydb) vacuum() error {
+	vacuum := c.Vacuum
+	if c.throttle != nil {
+		vacuum = c.throttledVacuum
+	}
+
+	err := vacuum()
+	if err != nil {
+		c.logger.Printf("error: %s", err)
+	}
+
+	return err
+}
@@ -0,0 +1,89 @@
+package ckydb
+
+import (
+	"time"
+
+	"github.com/sopherapps/ckydb/implementations/go-ckydb/internal"
+)
+
+// foregroundActivityWindow is how long after a foreground operation the vacuum and compaction tasks
+// are still throttled
+const foregroundActivityWindow = time.Second
+
+// maxMaintenancePause is the longest that the vacuum and compaction tasks pause for at a time,
+// so that they do not hold up Close for long
+const maxMaintenancePause = time.Second
+
+// MaintenanceThrottle slows down the vacuum and compaction tasks while foreground operations e.g. Get and Set
+// are running, so that rewriting files does not spike their latency on slow disks
+type MaintenanceThrottle struct {
+	// MaxBytesPerSec limits the rate at which files are rewritten. Zero means no limit
+	MaxBytesPerSec int64
+	// PauseBetweenFiles is the least time paused after rewriting each file
+	PauseBetweenFiles time.Duration
+}
+
+// WithMaintenanceThrottle makes the vacuum and compaction tasks rewrite one file at a time, pausing after each,
+// as configured by the throttle, if there has been any foreground operation within the last second. Other
+// operations run during the pauses, each of which lasts at most a second. Calls to Vacuum and Compact
+// are never throttled
+func WithMaintenanceThrottle(throttle MaintenanceThrottle) Option {
+	return func(o *options) {
+		o.throttle = &throttle
+	}
+}
+
+// throttledVacuum is Vacuum, pausing after every file if foreground operations are running.
+// It is the work of the vacuum task if there is a maintenance throttle
+func (c *Ckydb) throttledVacuum() error {
+	return c.vacuumWith(func(st *internal.OpStats) error {
+		return c.store.ThrottledVacuumWithStats(st, c.pauseMaintenance)
+	})
+}
+
+// throttledCompact is Compact, pausing after every merge if foreground operations are running.
+// It is the work of the compaction task if there is a maintenance throttle
+func (c *Ckydb) throttledCompact() error {
+	c.mutLock.Lock()
+	defer c.mutLock.Unlock()
+
+	return c.instrument(opCompact, "", func(st *internal.OpStats) error {
+		return c.store.ThrottledCompactWithStats(st, c.pauseMaintenance)
+	})
+}
+
+// pauseMaintenance is called, with the write lock held, by the vacuum and compaction tasks after rewriting
+// the given number of bytes. If any foreground operation has run recently, it releases the lock for as long
+// as the throttle requires so that foreground operations run in the meantime
+func (c *Ckydb) pauseMaintenance(bytesRewritten int64) {
+	if c.clock.Now().UnixNano()-c.lastForegroundOp.Load() > int64(foregroundActivityWindow) {
+		return
+	}
+
+	pause := c.throttle.PauseBetweenFiles
+	if c.throttle.MaxBytesPerSec > 0 {
+		pause = max(pause, time.Duration(float64(bytesRewritten)/float64(c.throttle.MaxBytesPerSec)*float64(time.Second)))
+	}
+	pause = min(pause, maxMaintenancePause)
+	if pause <= 0 {
+		return
+	}
+
+	c.mutLock.Unlock()
+	<-c.clock.After(pause)
+	c.mutLock.Lock()
+}
+
+// recordForegroundOp records that the given operation ran, if it is a foreground operation,
+// for the maintenance throttle, if any
+func (c *Ckydb) recordForegroundOp(op string) {
+	if c.throttle == nil {
+		return
+	}
+
+	switch op {
+	case opVacuum, opCompact, opLoad, opRefresh:
+	default:
+		c.lastForegroundOp.Store(c.clock.Now().UnixNano())
+	}
+}
@@ -0,0 +1,67 @@
+package ckydb
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+
+	"github.com/sopherapps/ckydb/implementations/go-ckydb/internal"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/sopherapps/ckydb/implementations/go-ckydb"
+
+// WithTracerProvider creates OpenTelemetry spans, from the given provider, for the Set, Get, Delete,
+// Clear, Vacuum and Load operations. The spans have attributes like the hash of the key, whether the
+// cache was hit, the files touched and the bytes written. Those of GetContext, SetContext, DeleteContext and
+// ClearContext are children of the span of their context, if any
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(o *options) {
+		o.tracer = provider.Tracer(tracerName)
+	}
+}
+
+// startSpan starts a new span for the given operation, as a child of the span of ctx if any, if tracing is enabled
+func (c *Ckydb) startSpan(ctx context.Context, op string) trace.Span {
+	if c.tracer == nil {
+		return nil
+	}
+
+	_, span := c.tracer.Start(ctx, fmt.Sprintf("ckydb.%s", op))
+	return span
+}
+
+// endSpan adds the attributes of the operation to the span and ends it
+func endSpan(span trace.Span, key string, st *internal.OpStats, err error) {
+	if key != "" {
+		span.SetAttributes(attribute.String("ckydb.key_hash", hashKey(key)))
+	}
+
+	if st != nil {
+		span.SetAttributes(
+			attribute.Bool("ckydb.cache_hit", st.CacheHit),
+			attribute.Bool("ckydb.cache_reload", st.CacheReload),
+			attribute.Bool("ckydb.log_rewrite", st.LogRewrite),
+			attribute.Bool("ckydb.log_roll", st.LogRoll),
+			attribute.StringSlice("ckydb.files_touched", st.FilesTouched),
+			attribute.Int64("ckydb.bytes_read", st.BytesRead),
+			attribute.Int64("ckydb.bytes_written", st.BytesWritten),
+		)
+	}
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
+
+// hashKey returns the FNV-1a hash of the key so that keys are not leaked into traces
+func hashKey(key string) string {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	return fmt.Sprintf("%016x", h.Sum64())
+}
@@ -0,0 +1,40 @@
+package ckydb
+
+import (
+	"context"
+	"time"
+
+	"github.com/sopherapps/ckydb/implementations/go-ckydb/internal"
+)
+
+// WithTrash enables trash mode, in which Delete moves keys to a trash kept in the database folder,
+// from where Undelete can restore them until the retention has passed. The vacuum task purges the keys
+// that have been in the trash for longer. Keys that expire or are evicted are not moved to the trash
+func WithTrash(retention time.Duration) Option {
+	return func(o *options) {
+		o.storeOptions = append(o.storeOptions, internal.WithTrash(retention))
+	}
+}
+
+// Undelete restores the given deleted key from the trash, sending the set to the replication sink.
+// It returns an ErrNotFound error if the key is not in the trash or has been purged, an ErrConflict
+// error if the key has been set again since it was deleted and an ErrTrashDisabled error if the
+// database is not in trash mode
+func (c *Ckydb) Undelete(key string) error {
+	key = c.normalizeKey(key)
+	c.mutLock.Lock()
+	defer c.mutLock.Unlock()
+
+	var value string
+	err := c.instrument(opUndelete, key, func(st *internal.OpStats) error {
+		var err error
+		value, err = c.store.UndeleteWithStats(key, st)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	c.replicate(context.Background(), OpSet, key, value)
+	return nil
+}
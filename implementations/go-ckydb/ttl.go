@@ -0,0 +1,83 @@
+package ckydb
+
+import (
+	"context"
+	"time"
+
+	"github.com/sopherapps/ckydb/implementations/go-ckydb/internal"
+)
+
+// NoExpiry is the TTL of keys that never expire
+const NoExpiry = internal.NoExpiry
+
+// SetWithTTL is like Set but the key expires after the given ttl. Expired keys are not found
+// and are deleted by the next vacuum. A ttl of zero or less deletes the key. Expiries are kept
+// in the database folder but are not sent to the replication sink, which only gets the set
+func (c *Ckydb) SetWithTTL(key string, value string, ttl time.Duration) error {
+	key = c.normalizeKey(key)
+	c.mutLock.Lock()
+	defer c.mutLock.Unlock()
+
+	err := c.instrument(opSetWithTTL, key, func(st *internal.OpStats) error {
+		return c.store.SetWithTTLAndStats(key, value, ttl, st)
+	})
+	if err != nil {
+		return err
+	}
+
+	if ttl <= 0 {
+		c.replicate(context.Background(), OpDelete, key, "")
+	} else {
+		c.replicate(context.Background(), OpSet, key, value)
+	}
+	return nil
+}
+
+// TTL returns how long the given key has left before it expires, or NoExpiry if it never expires.
+// It returns an ErrNotFound error if the key is nonexistent
+func (c *Ckydb) TTL(key string) (time.Duration, error) {
+	key = c.normalizeKey(key)
+	c.mutLock.RLock()
+	defer c.mutLock.RUnlock()
+
+	var ttl time.Duration
+	err := c.instrument(opTTL, key, func(st *internal.OpStats) error {
+		var err error
+		ttl, err = c.store.TTL(key)
+		return err
+	})
+
+	return ttl, err
+}
+
+// Expire makes the given key expire after the given ttl, replacing any earlier expiry.
+// A ttl of zero or less deletes the key. It returns an ErrNotFound error if the key is nonexistent
+func (c *Ckydb) Expire(key string, ttl time.Duration) error {
+	key = c.normalizeKey(key)
+	c.mutLock.Lock()
+	defer c.mutLock.Unlock()
+
+	err := c.instrument(opExpire, key, func(st *internal.OpStats) error {
+		return c.store.ExpireWithStats(key, ttl, st)
+	})
+	if err != nil {
+		return err
+	}
+
+	if ttl <= 0 {
+		c.replicate(context.Background(), OpDelete, key, "")
+	}
+	return nil
+}
+
+// Persist removes the expiry of the given key so that it never expires.
+// It returns an ErrNotFound error if the key is nonexistent
+func (c *Ckydb) Persist(key string) error {
+	key = c.normalizeKey(key)
+	c.mutLock.Lock()
+	defer c.mutLock.Unlock()
+
+	return c.instrument(opPersist, key, func(st *internal.OpStats) error {
+		return c.store.PersistWithStats(key, st)
+	})
+}
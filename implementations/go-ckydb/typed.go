@@ -0,0 +1,45 @@
+package ckydb
+
+import (
+	"github.com/sopherapps/ckydb/implementations/go-ckydb/codec"
+)
+
+// Typed sets and gets values of type T on a Controller, encoding them into string values with a codec
+type Typed[T any] struct {
+	db    Controller
+	codec codec.Codec
+}
+
+// NewTyped returns a Typed wrapping the given Controller, encoding values with the given codec
+// e.g. NewTyped[User](db, codec.JSON)
+func NewTyped[T any](db Controller, c codec.Codec) *Typed[T] {
+	return &Typed[T]{db: db, codec: c}
+}
+
+// Set encodes the value and sets the key to it
+func (t *Typed[T]) Set(key string, value T) error {
+	encoded, err := codec.EncodeString(t.codec, value)
+	if err != nil {
+		return err
+	}
+
+	return t.db.Set(key, encoded)
+}
+
+// Get gets the value of the key and decodes it.
+// It returns an ErrNotFound error if the key is nonexistent
+func (t *Typed[T]) Get(key string) (T, error) {
+	var value T
+	encoded, err := t.db.Get(key)
+	if err != nil {
+		return value, err
+	}
+
+	err = codec.DecodeString(t.codec, encoded, &value)
+	return value, err
+}
+
+// Delete deletes the key
+func (t *Typed[T]) Delete(key string) error {
+	return t.db.Delete(key)
+}
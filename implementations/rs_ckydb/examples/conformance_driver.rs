@@ -0,0 +1,109 @@
+//! Conformance driver for the Rust implementation of ckydb, used by the conformance suite
+//! in the Go implementation.
+//!
+//! Usage:
+//!     cargo run --example conformance_driver -- <write|verify> <db_path> <script_path> <max_file_size_kb>
+use ckydb::{connect, Controller};
+use std::collections::{BTreeMap, BTreeSet};
+use std::{env, fs, process};
+
+/// Vacuuming is left to the opening of the database so that it does not run midway through a script
+const VACUUM_INTERVAL_SEC: f64 = 3600.0;
+
+fn main() {
+    let args: Vec<String> = env::args().collect();
+    if args.len() != 5 || (args[1] != "write" && args[1] != "verify") {
+        eprintln!("usage: conformance_driver <write|verify> <db_path> <script_path> <max_file_size_kb>");
+        process::exit(2);
+    }
+
+    let ops = parse_script(&args[3]).unwrap_or_else(|err| {
+        eprintln!("{}", err);
+        process::exit(2);
+    });
+    let max_file_size_kb: f64 = args[4].parse().expect("max_file_size_kb should be a number");
+    let mut db = connect(&args[2], max_file_size_kb, VACUUM_INTERVAL_SEC).expect("connect");
+
+    let errors = if args[1] == "write" {
+        write(&mut db, &ops)
+    } else {
+        verify(&mut db, &ops)
+    };
+    db.close().expect("close");
+
+    if !errors.is_empty() {
+        eprintln!("{}", errors.join("\n"));
+        process::exit(1);
+    }
+}
+
+/// Parses the script at path into a list of operations, each a list of fields
+fn parse_script(path: &str) -> Result<Vec<Vec<String>>, String> {
+    let content = fs::read_to_string(path).map_err(|err| err.to_string())?;
+    let mut ops = Vec::new();
+    for (i, line) in content.lines().enumerate() {
+        let line = line.trim();
+        if line.is_empty() || line.starts_with('#') {
+            continue;
+        }
+
+        let fields: Vec<String> = line.split_whitespace().map(String::from).collect();
+        match (fields[0].as_str(), fields.len()) {
+            ("set", 3) | ("delete", 2) | ("clear", 1) => ops.push(fields),
+            _ => return Err(format!("invalid script: {} line {}: {:?}", path, i + 1, line)),
+        }
+    }
+
+    Ok(ops)
+}
+
+/// Runs the operations against the database, returning the errors, if any
+fn write(db: &mut impl Controller, ops: &[Vec<String>]) -> Vec<String> {
+    for op in ops {
+        let result = match op[0].as_str() {
+            "set" => db.set(&op[1], &op[2]).map_err(|err| err.to_string()),
+            "delete" => db.delete(&op[1]).map_err(|err| err.to_string()),
+            _ => db.clear().map_err(|err| err.to_string()),
+        };
+
+        if let Err(err) = result {
+            return vec![format!("{:?}: {}", op, err)];
+        }
+    }
+
+    Vec::new()
+}
+
+/// Returns the differences between the key-values in the database and those the operations leave behind
+fn verify(db: &mut impl Controller, ops: &[Vec<String>]) -> Vec<String> {
+    let mut present: BTreeMap<String, String> = BTreeMap::new();
+    let mut touched: BTreeSet<String> = BTreeSet::new();
+    for op in ops {
+        match op[0].as_str() {
+            "set" => {
+                present.insert(op[1].clone(), op[2].clone());
+                touched.insert(op[1].clone());
+            }
+            "delete" => {
+                present.remove(&op[1]);
+            }
+            _ => present.clear(),
+        }
+    }
+
+    let mut errors = Vec::new();
+    for (key, value) in &present {
+        match db.get(key) {
+            Ok(got) if &got == value => {}
+            got => errors.push(format!("expected {:?} for {:?}, got {:?}", value, key, got)),
+        }
+    }
+
+    for key in touched.iter().filter(|k| !present.contains_key(*k)) {
+        if let Ok(got) = db.get(key) {
+            errors.push(format!("expected {:?} to be not found, got {:?}", key, got));
+        }
+    }
+
+    errors
+}